@@ -19,16 +19,22 @@ import (
 	"github.com/openimsdk/tools/checker"
 	"io"
 	"net/http"
+	"reflect"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/openimsdk/tools/apiresp"
 	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mw"
 	"github.com/openimsdk/tools/utils/jsonutil"
 	"google.golang.org/grpc"
 )
 
 type Option[A, B any] struct {
+	// StrictParams rejects requests setting a field both in the body and in
+	// a query, path or header parameter, see ParseRequestNotCheck. By default
+	// the body wins.
+	StrictParams bool
 	// BindAfter is called after the req is bind from ctx.
 	BindAfter func(*A) error
 	// RespAfter is called after the resp is return from rpc.
@@ -36,7 +42,11 @@ type Option[A, B any] struct {
 }
 
 func Call[A, B, C any](c *gin.Context, rpc func(client C, ctx context.Context, req *A, options ...grpc.CallOption) (*B, error), client C, opts ...*Option[A, B]) {
-	req, err := ParseRequestNotCheck[A](c)
+	strict := false
+	for _, opt := range opts {
+		strict = strict || opt.StrictParams
+	}
+	req, err := parseRequest[A](c, strict)
 	if err != nil {
 		apiresp.GinError(c, err)
 		return
@@ -71,14 +81,35 @@ func Call[A, B, C any](c *gin.Context, rpc func(client C, ctx context.Context, r
 	apiresp.GinSuccess(c, resp) // rpc call success
 }
 
+// ParseRequestNotCheck binds the JSON body of the request into a T. Fields of
+// T tagged form, uri or header are also set from the query parameters, the
+// gin path parameters and the headers, converted to the field type; the body
+// is then optional, so that GET endpoints can be bound as well. A field
+// present in the body keeps its body value.
 func ParseRequestNotCheck[T any](c *gin.Context) (*T, error) {
+	return parseRequest[T](c, false)
+}
+
+func parseRequest[T any](c *gin.Context, strictParams bool) (*T, error) {
 	var req T
-	if err := c.ShouldBindWith(&req, jsonBind); err != nil {
-		return nil, errs.NewCodeError(errs.ArgsError, err.Error())
+	if err := bindRequest(c, &req, strictParams); err != nil {
+		return nil, err
 	}
 	return &req, nil
 }
 
+// bindRequest binds the request into obj, a pointer to a struct, see
+// ParseRequestNotCheck. It is shared by Call and AutoRoute.
+func bindRequest(c *gin.Context, obj any, strictParams bool) error {
+	if fields := paramFields(reflect.TypeOf(obj).Elem()); len(fields) > 0 {
+		return bindWithParams(c, obj, fields, strictParams)
+	}
+	if err := c.ShouldBindWith(obj, jsonBind); err != nil {
+		return errs.NewCodeError(errs.ArgsError, err.Error())
+	}
+	return nil
+}
+
 func ParseRequest[T any](c *gin.Context) (*T, error) {
 	req, err := ParseRequestNotCheck[T](c)
 	if err != nil {
@@ -90,7 +121,11 @@ func ParseRequest[T any](c *gin.Context) (*T, error) {
 	return req, nil
 }
 
-type jsonBinding struct{}
+// jsonBinding decodes request bodies. Strict bindings, and requests that
+// passed mw.StrictJSON, reject unknown fields.
+type jsonBinding struct {
+	strict bool
+}
 
 var jsonBind binding.Binding = jsonBinding{}
 
@@ -106,11 +141,14 @@ func (b jsonBinding) Bind(req *http.Request, obj any) error {
 	if err != nil {
 		return errs.WrapMsg(err, "read request body failed", "method", req.Method, "url", req.URL.String())
 	}
+	if mw.IsStrictJSON(req.Context()) {
+		b.strict = true
+	}
 	return errs.Wrap(b.BindBody(body, obj))
 }
 
-func (jsonBinding) BindBody(body []byte, obj any) error {
-	if err := jsonutil.JsonUnmarshal(body, obj); err != nil {
+func (b jsonBinding) BindBody(body []byte, obj any) error {
+	if err := b.decode(body, obj); err != nil {
 		return err
 	}
 	if binding.Validator == nil {
@@ -118,3 +156,10 @@ func (jsonBinding) BindBody(body []byte, obj any) error {
 	}
 	return errs.Wrap(binding.Validator.ValidateStruct(obj))
 }
+
+func (b jsonBinding) decode(body []byte, obj any) error {
+	if b.strict {
+		return mw.DecodeStrictJSON(body, obj)
+	}
+	return jsonutil.JsonUnmarshal(body, obj)
+}
@@ -0,0 +1,63 @@
+package a2r
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mw"
+)
+
+type groupReq struct {
+	GroupID string `json:"groupID"`
+}
+
+func TestParseRequestStrictJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(mw.StrictJSON(mw.WithLenientPaths("/legacy")))
+	handler := func(c *gin.Context) {
+		req, err := ParseRequestNotCheck[groupReq](c)
+		if err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+		apiresp.GinSuccess(c, req)
+	}
+	engine.POST("/group", handler)
+	engine.POST("/legacy", handler)
+	engine.POST("/batch", func(c *gin.Context) {
+		CallBatch(c, callFake, &fakeClient{}, AllOrNothing)
+	})
+
+	serve := func(path, body string) apiresp.ApiResponse {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		resp := apiresp.ApiResponse{Data: &groupReq{}}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := serve("/group", `{"groupID":"g1"}`); resp.ErrCode != 0 || resp.Data.(*groupReq).GroupID != "g1" {
+		t.Fatalf("valid request: %+v", resp)
+	}
+	resp := serve("/group", `{"groupId":"g1"}`)
+	if resp.ErrCode != errs.ArgsError || !strings.Contains(resp.ErrMsg+resp.ErrDlt, `unknown field "groupId"`) {
+		t.Fatalf("misspelled field: %+v", resp)
+	}
+	if resp := serve("/legacy", `{"groupId":"g1"}`); resp.ErrCode != 0 || resp.Data.(*groupReq).GroupID != "g1" {
+		t.Fatalf("lenient route: %+v", resp)
+	}
+	if resp := serve("/batch", `[{"userID":"u1"},{"userId":"u2"}]`); resp.ErrCode != errs.ArgsError {
+		t.Fatalf("batch item with unknown field: %+v", resp)
+	}
+}
@@ -0,0 +1,213 @@
+package a2r
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/checker"
+	"github.com/openimsdk/tools/errs"
+	"google.golang.org/grpc"
+)
+
+var (
+	contextType    = reflect.TypeOf((*context.Context)(nil)).Elem()
+	callOptionType = reflect.TypeOf([]grpc.CallOption(nil))
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+type autoRouteConfig struct {
+	routes       map[string]string
+	middlewares  map[string][]gin.HandlerFunc
+	bindAfter    map[string]func(req any) error
+	respAfter    map[string]func(resp any) error
+	strictParams bool
+}
+
+type AutoRouteOption func(*autoRouteConfig)
+
+// WithRoutes renames the routes of the given methods. The key is the Go method
+// name; the value replaces its snake_case path segment, and "-" leaves the
+// method unexposed.
+func WithRoutes(routes map[string]string) AutoRouteOption {
+	return func(c *autoRouteConfig) {
+		for method, route := range routes {
+			c.routes[method] = route
+		}
+	}
+}
+
+// WithRouteMiddleware attaches handlers that run before the handler of method.
+func WithRouteMiddleware(method string, handlers ...gin.HandlerFunc) AutoRouteOption {
+	return func(c *autoRouteConfig) {
+		c.middlewares[method] = append(c.middlewares[method], handlers...)
+	}
+}
+
+// WithStrictParams sets Option.StrictParams for every route.
+func WithStrictParams() AutoRouteOption {
+	return func(c *autoRouteConfig) {
+		c.strictParams = true
+	}
+}
+
+// WithBindAfter is Option.BindAfter for the route of method: fn is called with
+// the bound request, a pointer to the request message.
+func WithBindAfter(method string, fn func(req any) error) AutoRouteOption {
+	return func(c *autoRouteConfig) {
+		c.bindAfter[method] = fn
+	}
+}
+
+// WithRespAfter is Option.RespAfter for the route of method: fn is called with
+// the response message returned by the rpc.
+func WithRespAfter(method string, fn func(resp any) error) AutoRouteOption {
+	return func(c *autoRouteConfig) {
+		c.respAfter[method] = fn
+	}
+}
+
+// AutoRoute registers POST <prefix>/<method_snake_case> on router for every
+// unary method of the generated gRPC client, e.g. client.GetUsersInfo becomes
+// /user/get_users_info for prefix "/user". Each handler behaves like Call: it
+// binds the request message with the same binding, honouring mw.StrictJSON and
+// the query, path and header parameters, validates it and writes the rpc
+// result with apiresp. Streaming methods are skipped. Methods are resolved once
+// here, so requests do not pay for reflection lookups. An option naming an
+// unknown method is an error, and then no route is registered.
+//
+// AutoRoute belongs with the gin middlewares but lives here: it binds requests
+// like Call, and a2r imports mw, so mw cannot provide it.
+func AutoRoute(router gin.IRouter, prefix string, client any, opts ...AutoRouteOption) error {
+	value := reflect.ValueOf(client)
+	if client == nil || (value.Kind() == reflect.Pointer && value.IsNil()) {
+		return errs.ErrArgs.WrapMsg("AutoRoute client is nil")
+	}
+	conf := &autoRouteConfig{
+		routes:      make(map[string]string),
+		middlewares: make(map[string][]gin.HandlerFunc),
+		bindAfter:   make(map[string]func(req any) error),
+		respAfter:   make(map[string]func(resp any) error),
+	}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	typ := value.Type()
+	unary := make(map[string]reflect.Type)
+	for i := 0; i < typ.NumMethod(); i++ {
+		if reqType, ok := unaryRequestType(typ.Method(i).Type); ok {
+			unary[typ.Method(i).Name] = reqType
+		}
+	}
+	// Refuse options naming methods that do not exist, so typos are not silently
+	// ignored, before registering anything.
+	var named []string
+	for method := range conf.routes {
+		named = append(named, method)
+	}
+	for method := range conf.middlewares {
+		named = append(named, method)
+	}
+	for method := range conf.bindAfter {
+		named = append(named, method)
+	}
+	for method := range conf.respAfter {
+		named = append(named, method)
+	}
+	for _, method := range named {
+		if _, ok := unary[method]; !ok {
+			return errs.ErrArgs.WrapMsg("AutoRoute unknown unary method", "method", method, "client", typ.String())
+		}
+	}
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		reqType, ok := unary[method.Name]
+		if !ok {
+			continue
+		}
+		route, ok := conf.routes[method.Name]
+		if !ok {
+			route = snakeCase(method.Name)
+		}
+		if route == "-" {
+			continue
+		}
+		handlers := append(append([]gin.HandlerFunc(nil), conf.middlewares[method.Name]...),
+			autoRouteHandler(value.Method(i), reqType, conf.strictParams, conf.bindAfter[method.Name], conf.respAfter[method.Name]))
+		router.POST(strings.TrimSuffix(prefix, "/")+"/"+route, handlers...)
+	}
+	return nil
+}
+
+// unaryRequestType reports whether fn, a method type with receiver, has the
+// shape func(context.Context, *Req, ...grpc.CallOption) (*Resp, error) and
+// returns Req.
+func unaryRequestType(fn reflect.Type) (reflect.Type, bool) {
+	if fn.NumIn() != 4 || !fn.IsVariadic() || fn.NumOut() != 2 {
+		return nil, false
+	}
+	if fn.In(1) != contextType || fn.In(2).Kind() != reflect.Pointer || fn.In(3) != callOptionType {
+		return nil, false
+	}
+	if fn.Out(0).Kind() != reflect.Pointer || fn.Out(1) != errorType {
+		return nil, false
+	}
+	return fn.In(2).Elem(), true
+}
+
+func autoRouteHandler(method reflect.Value, reqType reflect.Type, strictParams bool, bindAfter func(any) error, respAfter func(any) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := reflect.New(reqType)
+		if err := bindRequest(c, req.Interface(), strictParams); err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+		if bindAfter != nil {
+			if err := bindAfter(req.Interface()); err != nil {
+				apiresp.GinError(c, err)
+				return
+			}
+		}
+		if err := checker.Validate(req.Interface()); err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+		out := method.Call([]reflect.Value{reflect.ValueOf(c), req})
+		if err, _ := out[1].Interface().(error); err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+		if respAfter != nil {
+			if err := respAfter(out[0].Interface()); err != nil {
+				apiresp.GinError(c, err)
+				return
+			}
+		}
+		apiresp.GinSuccess(c, out[0].Interface())
+	}
+}
+
+// snakeCase converts a Go method name to snake_case, keeping acronyms
+// together: GetUsersInfo -> get_users_info, GetUserIDs -> get_user_ids.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			// Start a new word after a lower case letter or digit, or at the last
+			// upper case letter of an acronym followed by a word (HTTPServer),
+			// except for a plural acronym at the end (IDs).
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			pluralEnd := i+2 == len(runes) && runes[i+1] == 's'
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower && !pluralEnd) {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
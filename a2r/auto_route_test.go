@@ -0,0 +1,194 @@
+package a2r
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mw"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newHealthClient(t *testing.T) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("user", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func post(r http.Handler, path, body string) (int, map[string]any) {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	var resp map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestAutoRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := AutoRoute(r, "/health", newHealthClient(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := map[string]bool{}
+	for _, route := range r.Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+	if !routes["POST /health/check"] || len(routes) != 1 {
+		t.Fatalf("routes = %v, want only POST /health/check (Watch is streaming)", routes)
+	}
+
+	code, resp := post(r, "/health/check", `{"service":"user"}`)
+	if code != http.StatusOK || resp["errCode"] != float64(0) {
+		t.Fatalf("check: %d %v", code, resp)
+	}
+	if data := resp["data"].(map[string]any); data["status"] != float64(healthpb.HealthCheckResponse_SERVING) {
+		t.Errorf("check data = %v", data)
+	}
+
+	// The rpc error is returned through apiresp like a2r.Call does.
+	_, resp = post(r, "/health/check", `{"service":"unknown"}`)
+	if resp["errCode"] == float64(0) {
+		t.Errorf("check of unknown service succeeded: %v", resp)
+	}
+	_, resp = post(r, "/health/check", `{"service":`)
+	if resp["errCode"] != float64(1001) {
+		t.Errorf("malformed body: %v", resp)
+	}
+
+	if code, _ := post(r, "/health/watch", `{}`); code != http.StatusNotFound {
+		t.Errorf("streaming method route status = %d, want 404", code)
+	}
+	if code, _ := post(r, "/health/nope", `{}`); code != http.StatusNotFound {
+		t.Errorf("unknown method status = %d, want 404", code)
+	}
+}
+
+func TestAutoRouteOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	client := newHealthClient(t)
+
+	r := gin.New()
+	var hits int
+	err := AutoRoute(r, "/health/", client,
+		WithRoutes(map[string]string{"Check": "probe"}),
+		WithRouteMiddleware("Check", func(c *gin.Context) { hits++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := post(r, "/health/probe", `{"service":"user"}`); code != http.StatusOK || resp["errCode"] != float64(0) || hits != 1 {
+		t.Errorf("renamed route: %d %v hits=%d", code, resp, hits)
+	}
+	if code, _ := post(r, "/health/check", `{}`); code != http.StatusNotFound {
+		t.Errorf("original name still routed: %d", code)
+	}
+
+	r = gin.New()
+	if err := AutoRoute(r, "/health", client, WithRoutes(map[string]string{"Check": "-"})); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Routes()) != 0 {
+		t.Errorf("opted-out method registered: %v", r.Routes())
+	}
+
+	r = gin.New()
+	if err := AutoRoute(r, "/health", client, WithRoutes(map[string]string{"Chek": "x"})); err == nil {
+		t.Error("option for unknown method accepted")
+	}
+	if len(r.Routes()) != 0 {
+		t.Errorf("routes registered despite the error: %v", r.Routes())
+	}
+	if err := AutoRoute(gin.New(), "/health", nil); err == nil {
+		t.Error("nil client accepted")
+	}
+}
+
+func TestAutoRouteStrictJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(mw.StrictJSON())
+	if err := AutoRoute(r, "/health", newHealthClient(t)); err != nil {
+		t.Fatal(err)
+	}
+	send := func(body string) map[string]any {
+		req := httptest.NewRequest(http.MethodPost, "/health/check", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	if resp := send(`{"service":"user"}`); resp["errCode"] != float64(0) {
+		t.Fatalf("known fields: %v", resp)
+	}
+	if resp := send(`{"service":"user","servce":"typo"}`); resp["errCode"] != float64(errs.ArgsError) {
+		t.Fatalf("unknown field under StrictJSON: %v", resp)
+	}
+}
+
+func TestAutoRouteHooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	client := newHealthClient(t)
+	r := gin.New()
+	err := AutoRoute(r, "/health", client,
+		WithBindAfter("Check", func(req any) error {
+			req.(*healthpb.HealthCheckRequest).Service = "user"
+			return nil
+		}),
+		WithRespAfter("Check", func(resp any) error {
+			if resp.(*healthpb.HealthCheckResponse).Status != healthpb.HealthCheckResponse_SERVING {
+				return errs.ErrInternalServer.WrapMsg("not serving")
+			}
+			return errs.ErrNoPermission.WrapMsg("hidden")
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// BindAfter rewrote the unknown service, and RespAfter saw it serving.
+	if _, resp := post(r, "/health/check", `{"service":"unknown"}`); resp["errCode"] != float64(errs.NoPermissionError) {
+		t.Fatalf("hooks: %v", resp)
+	}
+	if err := AutoRoute(gin.New(), "/health", client, WithBindAfter("Chek", func(any) error { return nil })); err == nil {
+		t.Error("hook for unknown method accepted")
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"Check":             "check",
+		"GetUsersInfo":      "get_users_info",
+		"GetUserIDs":        "get_user_ids",
+		"GetDesignateUsers": "get_designate_users",
+		"HTTPServerStatus":  "http_server_status",
+		"SetGroupV2":        "set_group_v2",
+	} {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package a2r
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/checker"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mw"
+	"google.golang.org/grpc"
+)
+
+// BatchMode selects how CallBatch handles failed items.
+type BatchMode int
+
+const (
+	// AllOrNothing validates every item before calling the rpc and stops at the
+	// first failure, returning its error for the whole request. Items called
+	// before the failure are not rolled back.
+	AllOrNothing BatchMode = iota
+	// BestEffort calls the rpc for every valid item and answers with
+	// apiresp.ApiPartial, reporting the result of each item.
+	BestEffort
+)
+
+// CallBatch binds a JSON array of requests and calls rpc once per item, in
+// order. Options apply to each item, so NewNilReplaceOption replaces nil values
+// in every per-item payload.
+func CallBatch[A, B, C any](c *gin.Context, rpc func(client C, ctx context.Context, req *A, options ...grpc.CallOption) (*B, error), client C, mode BatchMode, opts ...*Option[A, B]) {
+	items, err := ParseRequestNotCheck[[]json.RawMessage](c)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	reqs := make([]*A, len(*items))
+	bindErrs := make([]error, len(*items))
+	strict := mw.IsStrictJSON(c.Request.Context())
+	for i, item := range *items {
+		reqs[i], bindErrs[i] = bindItem[A](item, strict)
+	}
+	if mode == BestEffort {
+		results := make([]apiresp.ItemResult, len(reqs))
+		for i, req := range reqs {
+			// A nil *B in the any would be encoded as "data":null.
+			var data any
+			err := bindErrs[i]
+			if err == nil {
+				var resp *B
+				if resp, err = callItem(c, rpc, client, req, opts); err == nil && resp != nil {
+					data = resp
+				}
+			}
+			results[i] = apiresp.NewItemResult(i, "", data, err)
+		}
+		apiresp.ApiPartial(c, results)
+		return
+	}
+	for i, req := range reqs {
+		if bindErrs[i] != nil {
+			apiresp.GinError(c, bindErrs[i])
+			return
+		}
+		if err := prepareItem(req, opts); err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+	}
+	resps := make([]*B, 0, len(reqs))
+	for _, req := range reqs {
+		resp, err := invokeItem(c, rpc, client, req, opts)
+		if err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+		resps = append(resps, resp)
+	}
+	apiresp.GinSuccess(c, resps)
+}
+
+// bindItem decodes and struct-validates one element of the batch body.
+func bindItem[A any](item json.RawMessage, strict bool) (*A, error) {
+	if string(item) == "null" {
+		return nil, errs.ErrArgs.WrapMsg("batch item is null")
+	}
+	var req A
+	if err := (jsonBinding{strict: strict}).BindBody(item, &req); err != nil {
+		return nil, errs.NewCodeError(errs.ArgsError, err.Error())
+	}
+	return &req, nil
+}
+
+func callItem[A, B, C any](ctx context.Context, rpc func(client C, ctx context.Context, req *A, options ...grpc.CallOption) (*B, error), client C, req *A, opts []*Option[A, B]) (*B, error) {
+	if err := prepareItem(req, opts); err != nil {
+		return nil, err
+	}
+	return invokeItem(ctx, rpc, client, req, opts)
+}
+
+// prepareItem runs the BindAfter options and validation for one item.
+func prepareItem[A, B any](req *A, opts []*Option[A, B]) error {
+	for _, opt := range opts {
+		if opt.BindAfter == nil {
+			continue
+		}
+		if err := opt.BindAfter(req); err != nil {
+			return err
+		}
+	}
+	return checker.Validate(req)
+}
+
+// invokeItem calls rpc for one item and runs the RespAfter options.
+func invokeItem[A, B, C any](ctx context.Context, rpc func(client C, ctx context.Context, req *A, options ...grpc.CallOption) (*B, error), client C, req *A, opts []*Option[A, B]) (*B, error) {
+	resp, err := rpc(client, ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		if opt.RespAfter == nil {
+			continue
+		}
+		if err := opt.RespAfter(resp); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
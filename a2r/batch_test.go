@@ -0,0 +1,96 @@
+package a2r
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+	"google.golang.org/grpc"
+)
+
+type batchReq struct {
+	UserID string `json:"userID"`
+}
+
+type batchResp struct {
+	UserID  string   `json:"userID"`
+	Friends []string `json:"friends"`
+}
+
+type fakeClient struct{ calls []string }
+
+func (f *fakeClient) get(ctx context.Context, req *batchReq, _ ...grpc.CallOption) (*batchResp, error) {
+	f.calls = append(f.calls, req.UserID)
+	if strings.HasPrefix(req.UserID, "missing") {
+		return nil, errs.ErrRecordNotFound.WrapMsg("user not found", "userID", req.UserID)
+	}
+	return &batchResp{UserID: req.UserID}, nil
+}
+
+func callFake(client *fakeClient, ctx context.Context, req *batchReq, opts ...grpc.CallOption) (*batchResp, error) {
+	return client.get(ctx, req, opts...)
+}
+
+func serveBatch(t *testing.T, mode BatchMode, body string) (*fakeClient, map[string]any) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	client := &fakeClient{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body))
+	CallBatch(c, callFake, client, mode, NewNilReplaceOption(callFake))
+
+	var out map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	return client, out
+}
+
+func TestCallBatchBestEffort(t *testing.T) {
+	client, out := serveBatch(t, BestEffort, `[{"userID":"u1"},{"userID":"missing1"},null]`)
+	if len(client.calls) != 2 {
+		t.Fatalf("rpc called for %v", client.calls)
+	}
+	if out["errCode"] != float64(0) {
+		t.Fatalf("errCode = %v", out["errCode"])
+	}
+	summary := out["summary"].(map[string]any)
+	if summary["total"] != float64(3) || summary["success"] != float64(1) || summary["failed"] != float64(2) {
+		t.Fatalf("summary = %v", summary)
+	}
+	items := out["data"].([]any)
+	first := items[0].(map[string]any)["data"].(map[string]any)
+	if friends, ok := first["friends"].([]any); !ok || len(friends) != 0 {
+		t.Errorf("nil slice not replaced in item payload: %v", first)
+	}
+	if code := items[1].(map[string]any)["errCode"]; code != float64(errs.RecordNotFoundError) {
+		t.Errorf("item 1 errCode = %v", code)
+	}
+	if code := items[2].(map[string]any)["errCode"]; code != float64(errs.ArgsError) {
+		t.Errorf("item 2 errCode = %v", code)
+	}
+	for _, i := range []int{1, 2} {
+		if data, ok := items[i].(map[string]any)["data"]; ok {
+			t.Errorf("failed item %d carries data %v", i, data)
+		}
+	}
+}
+
+func TestCallBatchAllOrNothing(t *testing.T) {
+	client, out := serveBatch(t, AllOrNothing, `[{"userID":"u1"},{"userID":"missing1"},{"userID":"u3"}]`)
+	if len(client.calls) != 2 {
+		t.Errorf("rpc called for %v, want stop after the failed item", client.calls)
+	}
+	if out["errCode"] != float64(errs.RecordNotFoundError) {
+		t.Errorf("errCode = %v", out["errCode"])
+	}
+	if _, ok := out["summary"]; ok {
+		t.Error("all-or-nothing response carries a batch summary")
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package a2r
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mw"
+)
+
+// Sources of request parameters, and the struct tags naming them.
+const (
+	sourceQuery  = "query"
+	sourcePath   = "path"
+	sourceHeader = "header"
+)
+
+// paramTags maps the tags to their sources. A field with several tags is set
+// from each source in turn, the last one present winning.
+var paramTags = []struct{ tag, source string }{
+	{"form", sourceQuery},
+	{"uri", sourcePath},
+	{"header", sourceHeader},
+}
+
+type paramField struct {
+	index  int
+	source string
+	name   string
+	// jsonName is the key of the field in the body.
+	jsonName string
+	typ      string
+}
+
+var paramFieldsCache sync.Map // reflect.Type -> []paramField
+
+// paramFields returns the fields of the struct t bound from the query (tag
+// form), the gin path parameters (tag uri) or the headers (tag header).
+// Fields of embedded structs are not bound.
+func paramFields(t reflect.Type) []paramField {
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	if fields, ok := paramFieldsCache.Load(t); ok {
+		return fields.([]paramField)
+	}
+	var fields []paramField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		for _, pt := range paramTags {
+			name, _, _ := strings.Cut(f.Tag.Get(pt.tag), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if jsonName == "" {
+				jsonName = f.Name
+			}
+			fields = append(fields, paramField{index: i, source: pt.source, name: name, jsonName: jsonName, typ: typeName(f.Type)})
+		}
+	}
+	paramFieldsCache.Store(t, fields)
+	return fields
+}
+
+// bindWithParams decodes the JSON body of the request into obj, if any, then
+// sets the parameter fields, and runs the struct validation of gin. A field
+// present in the body keeps its body value, unless strict is set, in which
+// case the request is rejected.
+func bindWithParams(c *gin.Context, obj any, fields []paramField, strict bool) error {
+	var bodyKeys map[string]json.RawMessage
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return errs.WrapMsg(err, "read request body failed", "method", c.Request.Method, "url", c.Request.URL.String())
+		}
+		if len(body) > 0 {
+			b := jsonBinding{strict: mw.IsStrictJSON(c.Request.Context())}
+			if err := b.decode(body, obj); err != nil {
+				return errs.NewCodeError(errs.ArgsError, err.Error())
+			}
+			_ = json.Unmarshal(body, &bodyKeys)
+		}
+	}
+	v := reflect.ValueOf(obj).Elem()
+	query := c.Request.URL.Query()
+	for _, f := range fields {
+		var values []string
+		switch f.source {
+		case sourceQuery:
+			values = query[f.name]
+		case sourcePath:
+			if p, ok := c.Params.Get(f.name); ok {
+				values = []string{p}
+			}
+		case sourceHeader:
+			values = c.Request.Header.Values(f.name)
+		}
+		if len(values) == 0 || (len(values) == 1 && values[0] == "") {
+			continue
+		}
+		if inBody(bodyKeys, f.jsonName) {
+			if strict {
+				return errs.ErrArgs.WithDetail(fmt.Sprintf("%s is set in both the body and the %s parameter %q", f.jsonName, f.source, f.name))
+			}
+			continue
+		}
+		if err := setParam(v.Field(f.index), values); err != nil {
+			return errs.ErrArgs.WithDetail(fmt.Sprintf("%s parameter %q must be %s", f.source, f.name, f.typ))
+		}
+	}
+	if binding.Validator == nil {
+		return nil
+	}
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		return errs.NewCodeError(errs.ArgsError, err.Error())
+	}
+	return nil
+}
+
+// inBody reports whether the body has the key, matched without case as
+// encoding/json does.
+func inBody(keys map[string]json.RawMessage, name string) bool {
+	for key := range keys {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// setParam sets v from the values of a parameter. Slices take every value,
+// each split on commas; other kinds take the last value.
+func setParam(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setParam(v.Elem(), values)
+	}
+	if v.Kind() == reflect.Slice {
+		var items []string
+		for _, value := range values {
+			items = append(items, strings.Split(value, ",")...)
+		}
+		s := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setScalar(s.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setScalar(v, values[len(values)-1])
+}
+
+func setScalar(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return errs.New("unsupported parameter type", "type", v.Type().String()).Wrap()
+	}
+	return nil
+}
+
+// typeName describes t in conversion errors.
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice {
+		return "a comma separated list of " + kindName(t.Elem()) + "s"
+	}
+	name := kindName(t)
+	if strings.ContainsRune("aeiou", rune(name[0])) {
+		return "an " + name
+	}
+	return "a " + name
+}
+
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return t.String()
+	}
+}
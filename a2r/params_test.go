@@ -0,0 +1,106 @@
+package a2r
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+	"google.golang.org/grpc"
+)
+
+type memberReq struct {
+	GroupID    string   `json:"groupID" uri:"groupID"`
+	PageNumber int32    `json:"pageNumber" form:"pageNumber"`
+	ShowAll    bool     `json:"showAll" form:"showAll"`
+	UserIDs    []string `json:"userIDs" form:"userID"`
+	Roles      []int    `json:"roles" form:"role"`
+	Offset     *uint64  `json:"offset" form:"offset"`
+	Platform   string   `json:"platform" header:"X-Platform"`
+}
+
+type memberResp struct {
+	Req *memberReq `json:"req"`
+}
+
+type memberClient struct{}
+
+func getMembers(_ memberClient, _ context.Context, req *memberReq, _ ...grpc.CallOption) (*memberResp, error) {
+	return &memberResp{Req: req}, nil
+}
+
+func serveMembers(t *testing.T, method, target, body string, header http.Header) apiresp.ApiResponse {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := func(c *gin.Context) { Call(c, getMembers, memberClient{}) }
+	engine.Handle(method, "/group/:groupID/members", handler)
+	engine.Handle(method, "/strict/:groupID/members", func(c *gin.Context) {
+		Call(c, getMembers, memberClient{}, &Option[memberReq, memberResp]{StrictParams: true})
+	})
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	resp := apiresp.ApiResponse{Data: &memberResp{}}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+func TestParamSources(t *testing.T) {
+	resp := serveMembers(t, http.MethodGet,
+		"/group/g1/members?pageNumber=2&showAll=true&userID=u1,u2&userID=u3&role=1&role=2,3&offset=10", "",
+		http.Header{"X-Platform": {"ios"}})
+	if resp.ErrCode != 0 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	got := resp.Data.(*memberResp).Req
+	if got.GroupID != "g1" || got.PageNumber != 2 || !got.ShowAll || got.Platform != "ios" {
+		t.Errorf("req = %+v", got)
+	}
+	if strings.Join(got.UserIDs, " ") != "u1 u2 u3" || len(got.Roles) != 3 || got.Roles[2] != 3 {
+		t.Errorf("repeated params: userIDs = %v, roles = %v", got.UserIDs, got.Roles)
+	}
+	if got.Offset == nil || *got.Offset != 10 {
+		t.Errorf("offset = %v", got.Offset)
+	}
+}
+
+func TestParamConversionErrors(t *testing.T) {
+	for target, want := range map[string]string{
+		"/group/g1/members?pageNumber=two": `query parameter "pageNumber" must be an integer`,
+		"/group/g1/members?showAll=maybe":  `query parameter "showAll" must be a boolean`,
+		"/group/g1/members?role=1,admin":   `query parameter "role" must be a comma separated list of integers`,
+		"/group/g1/members?offset=-1":      `query parameter "offset" must be a non-negative integer`,
+	} {
+		resp := serveMembers(t, http.MethodGet, target, "", nil)
+		if resp.ErrCode != errs.ArgsError || !strings.Contains(resp.ErrMsg+resp.ErrDlt, want) {
+			t.Errorf("%s: %+v, want %s", target, resp, want)
+		}
+	}
+}
+
+func TestParamBodyConflict(t *testing.T) {
+	resp := serveMembers(t, http.MethodPost, "/group/g1/members?pageNumber=2&showAll=true", `{"pageNumber":5}`, nil)
+	got := resp.Data.(*memberResp).Req
+	if resp.ErrCode != 0 || got.PageNumber != 5 || !got.ShowAll || got.GroupID != "g1" {
+		t.Fatalf("body wins: %+v, req = %+v", resp, got)
+	}
+
+	resp = serveMembers(t, http.MethodPost, "/strict/g1/members?pageNumber=2", `{"pageNumber":5}`, nil)
+	if resp.ErrCode != errs.ArgsError || !strings.Contains(resp.ErrMsg+resp.ErrDlt, `pageNumber is set in both the body and the query parameter "pageNumber"`) {
+		t.Fatalf("strict conflict: %+v", resp)
+	}
+	if resp := serveMembers(t, http.MethodPost, "/strict/g1/members?showAll=1", `{"pageNumber":5}`, nil); resp.ErrCode != 0 {
+		t.Fatalf("strict without conflict: %+v", resp)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apimetrics exposes the apiresp counters as Prometheus metrics. It is
+// kept apart from apiresp so that only services that export metrics depend on
+// the Prometheus client.
+package apimetrics
+
+import (
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	deprecatedHitsDesc = prometheus.NewDesc("openim_api_deprecated_route_hits_total",
+		"Number of requests to routes marked deprecated, by route.", []string{"route"}, nil)
+	sunsetDesc = prometheus.NewDesc("openim_api_deprecated_route_sunset_timestamp_seconds",
+		"Sunset date of a deprecated route, as a Unix timestamp.", []string{"route"}, nil)
+)
+
+type collector struct{}
+
+// NewCollector returns a collector of the apiresp metrics, to be registered
+// with the service's Prometheus registry.
+func NewCollector() prometheus.Collector {
+	return collector{}
+}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- deprecatedHitsDesc
+	ch <- sunsetDesc
+}
+
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	for _, h := range apiresp.DeprecatedHits() {
+		ch <- prometheus.MustNewConstMetric(deprecatedHitsDesc, prometheus.CounterValue, float64(h.Hits), h.Route)
+		ch <- prometheus.MustNewConstMetric(sunsetDesc, prometheus.GaugeValue, float64(h.Sunset.Unix()), h.Route)
+	}
+}
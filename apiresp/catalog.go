@@ -0,0 +1,148 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiresp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/jsonutil"
+)
+
+// CatalogEntry documents an error code in the ErrorCatalog.
+type CatalogEntry struct {
+	Code       int               `json:"code"`
+	Name       string            `json:"name"`
+	Category   string            `json:"category"`
+	HTTPStatus int               `json:"httpStatus"`
+	Module     string            `json:"module,omitempty"`
+	Messages   map[string]string `json:"messages,omitempty"`
+}
+
+// ErrorCatalog lists the error codes a service may answer with. Unregistered
+// holds the codes created ad hoc with errs.NewCodeError that no sentinel
+// registered; they should be given one.
+type ErrorCatalog struct {
+	Codes        []CatalogEntry `json:"codes"`
+	Unregistered []CatalogEntry `json:"unregistered,omitempty"`
+}
+
+type catalogConfig struct {
+	modules map[string]bool
+}
+
+type CatalogOption func(*catalogConfig)
+
+// WithCatalogModules restricts the catalog to the codes in the ranges reserved
+// by modules, see errs.ReserveCodes.
+func WithCatalogModules(modules ...string) CatalogOption {
+	return func(c *catalogConfig) {
+		if c.modules == nil {
+			c.modules = make(map[string]bool)
+		}
+		for _, module := range modules {
+			c.modules[module] = true
+		}
+	}
+}
+
+// HTTPStatus returns the HTTP status matching an errs category, for
+// gateways that translate error codes. The API itself always answers 200.
+func HTTPStatus(category string) int {
+	switch category {
+	case errs.CategoryInvalidArgument:
+		return http.StatusBadRequest
+	case errs.CategoryPermission:
+		return http.StatusForbidden
+	case errs.CategoryNotFound:
+		return http.StatusNotFound
+	case errs.CategoryConflict:
+		return http.StatusConflict
+	case errs.CategoryUnavailable:
+		return http.StatusServiceUnavailable
+	case errs.CategoryTimeout:
+		return http.StatusGatewayTimeout
+	case errs.CategoryCancelled:
+		return 499 // client closed request
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// BuildErrorCatalog generates the catalog from the errs registry.
+func BuildErrorCatalog(opts ...CatalogOption) *ErrorCatalog {
+	var conf catalogConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return &ErrorCatalog{
+		Codes:        conf.entries(errs.RegisteredCodes()),
+		Unregistered: conf.entries(errs.UnregisteredCodes()),
+	}
+}
+
+func (c *catalogConfig) entries(infos []errs.CodeInfo) []CatalogEntry {
+	entries := make([]CatalogEntry, 0, len(infos))
+	for _, info := range infos {
+		if c.modules != nil && !c.modules[info.Module] {
+			continue
+		}
+		entries = append(entries, CatalogEntry{
+			Code:       info.Code,
+			Name:       info.Name,
+			Category:   info.Category,
+			HTTPStatus: HTTPStatus(info.Category),
+			Module:     info.Module,
+			Messages:   info.Messages,
+		})
+	}
+	return entries
+}
+
+// ErrorCatalogHandler serves the ErrorCatalog as JSON. The body is generated
+// on every request, codes may be registered late, and carries an ETag so
+// clients revalidate their copy with If-None-Match.
+func ErrorCatalogHandler(opts ...CatalogOption) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := jsonutil.JsonMarshal(BuildErrorCatalog(opts...))
+		if err != nil {
+			GinError(c, errs.WrapMsg(err, "marshal error catalog"))
+			return
+		}
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "no-cache")
+		if etagMatch(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+	return false
+}
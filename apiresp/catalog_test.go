@@ -0,0 +1,124 @@
+package apiresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+)
+
+func init() {
+	if err := errs.ReserveCodes("catalogtest", 91000, 91099); err != nil {
+		panic(err)
+	}
+	if err := errs.ReserveCodes("adhoctest", 91100, 91199); err != nil {
+		panic(err)
+	}
+	errs.DefaultCodeRelation.Add(errs.RecordNotFoundError, 91002)
+	errs.RegisterMessages("en", map[int]string{
+		91001: "The group is full",
+		91002: "The group does not exist",
+	})
+	errs.RegisterMessages("zh", map[int]string{
+		91001: "群成员已满",
+	})
+}
+
+var (
+	_ = errs.NewSentinel(91001, "GroupFullError")
+	_ = errs.NewSentinel(91002, "GroupNotFoundError")
+	_ = errs.NewSentinel(91003, "GroupMutedError")
+)
+
+func serveCatalog(t *testing.T, h gin.HandlerFunc, ifNoneMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/errors", nil)
+	if ifNoneMatch != "" {
+		c.Request.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	h(c)
+	c.Writer.WriteHeaderNow()
+	return w
+}
+
+func TestErrorCatalogGolden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := serveCatalog(t, ErrorCatalogHandler(WithCatalogModules("catalogtest")), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	var got bytes.Buffer
+	if err := json.Indent(&got, w.Body.Bytes(), "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	got.WriteByte('\n')
+	path := filepath.Join("testdata", "catalog.golden")
+	if *update {
+		if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("catalog mismatch\ngot:\n%s\nwant:\n%s", got.Bytes(), want)
+	}
+}
+
+func TestErrorCatalogETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := ErrorCatalogHandler(WithCatalogModules("catalogtest"))
+	etag := serveCatalog(t, h, "").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if w := serveCatalog(t, h, `"stale", `+etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("revalidation: status %d, body %q", w.Code, w.Body.String())
+	}
+	if w := serveCatalog(t, h, `"stale"`); w.Code != http.StatusOK {
+		t.Fatalf("stale ETag: status %d", w.Code)
+	}
+}
+
+func TestErrorCatalogUnregistered(t *testing.T) {
+	_ = errs.NewCodeError(91150, "QuotaExceeded").WithDetail("ad hoc")
+	_ = errs.NewCodeError(errs.ArgsError, "bad userID")
+	catalog := BuildErrorCatalog(WithCatalogModules("adhoctest"))
+	if len(catalog.Codes) != 0 {
+		t.Errorf("codes = %+v, want none", catalog.Codes)
+	}
+	if len(catalog.Unregistered) != 1 {
+		t.Fatalf("unregistered = %+v, want code 91150", catalog.Unregistered)
+	}
+	if u := catalog.Unregistered[0]; u.Code != 91150 || u.Name != "QuotaExceeded" || u.Module != "adhoctest" || u.HTTPStatus != http.StatusInternalServerError {
+		t.Errorf("unregistered = %+v", u)
+	}
+
+	var full ErrorCatalog
+	body := serveCatalog(t, ErrorCatalogHandler(), "").Body.Bytes()
+	if err := json.Unmarshal(body, &full); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range full.Unregistered {
+		if e.Code == errs.ArgsError {
+			t.Errorf("registered code %d flagged as unregistered", e.Code)
+		}
+	}
+	var found bool
+	for _, e := range full.Codes {
+		found = found || (e.Code == errs.ArgsError && e.Module == "errs" && e.HTTPStatus == http.StatusBadRequest)
+	}
+	if !found {
+		t.Errorf("ArgsError missing from the full catalog")
+	}
+}
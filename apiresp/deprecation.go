@@ -0,0 +1,177 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiresp
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
+)
+
+// Headers set on the responses of deprecated routes, see RFC 8594 and
+// draft-ietf-httpapi-deprecation-header.
+const (
+	HeaderDeprecation = "Deprecation"
+	HeaderSunset      = "Sunset"
+	HeaderLink        = "Link"
+)
+
+type deprecatedRoute struct {
+	route     string
+	sunset    time.Time
+	successor string
+	hits      atomic.Uint64
+}
+
+var (
+	deprecationLock sync.RWMutex
+	deprecations    = make(map[string]*deprecatedRoute)
+)
+
+// MarkDeprecated marks route, as registered with gin (see gin.Context.FullPath),
+// as scheduled for removal at sunset. successor is the path of the replacing
+// endpoint and may be empty. Marking a route again replaces its schedule.
+func MarkDeprecated(route string, sunset time.Time, successor string) {
+	deprecationLock.Lock()
+	defer deprecationLock.Unlock()
+	r := &deprecatedRoute{route: route, sunset: sunset, successor: successor}
+	if old, ok := deprecations[route]; ok {
+		r.hits.Store(old.hits.Load())
+	}
+	deprecations[route] = r
+}
+
+func deprecatedRouteOf(route string) *deprecatedRoute {
+	deprecationLock.RLock()
+	defer deprecationLock.RUnlock()
+	return deprecations[route]
+}
+
+// DeprecatedRouteHits is the number of requests served for a deprecated route
+// since the process started.
+type DeprecatedRouteHits struct {
+	Route  string
+	Sunset time.Time
+	Hits   uint64
+}
+
+// DeprecatedHits returns the hit counters of all deprecated routes, sorted by
+// route.
+func DeprecatedHits() []DeprecatedRouteHits {
+	deprecationLock.RLock()
+	defer deprecationLock.RUnlock()
+	hits := make([]DeprecatedRouteHits, 0, len(deprecations))
+	for _, r := range deprecations {
+		hits = append(hits, DeprecatedRouteHits{Route: r.route, Sunset: r.sunset, Hits: r.hits.Load()})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Route < hits[j].Route })
+	return hits
+}
+
+type deprecationConfig struct {
+	enforce bool
+	now     func() time.Time
+}
+
+type DeprecationOption func(*deprecationConfig)
+
+// WithSunsetEnforce rejects requests to routes past their sunset date with
+// errs.ErrEndpointSunset instead of serving them.
+func WithSunsetEnforce() DeprecationOption {
+	return func(c *deprecationConfig) {
+		c.enforce = true
+	}
+}
+
+// logDeprecatedUse is replaced in tests.
+var logDeprecatedUse = func(ctx context.Context, route, client string, sunset time.Time) {
+	log.ZWarn(ctx, "deprecated route used", nil, "route", route, "client", client, "sunset", sunset)
+}
+
+// useThrottle remembers which clients used which route on the current day.
+type useThrottle struct {
+	lock sync.Mutex
+	day  string
+	seen map[[2]string]struct{}
+}
+
+// first reports whether client uses route for the first time on the day of now.
+func (t *useThrottle) first(route, client string, now time.Time) bool {
+	day := now.UTC().Format(time.DateOnly)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.day != day {
+		t.day = day
+		t.seen = make(map[[2]string]struct{})
+	}
+	key := [2]string{route, client}
+	if _, ok := t.seen[key]; ok {
+		return false
+	}
+	t.seen[key] = struct{}{}
+	return true
+}
+
+// GinDeprecation returns a middleware that sets the Deprecation, Sunset and
+// Link headers on routes marked with MarkDeprecated and counts their hits.
+// The first use of a route by a client on each day is logged; clients are
+// identified by the appID in the context, or by their IP if it has none.
+func GinDeprecation(opts ...DeprecationOption) gin.HandlerFunc {
+	cfg := deprecationConfig{now: time.Now}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var throttle useThrottle
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		r := deprecatedRouteOf(route)
+		if r == nil {
+			c.Next()
+			return
+		}
+		r.hits.Add(1)
+		header := c.Writer.Header()
+		header.Set(HeaderDeprecation, "true")
+		header.Set(HeaderSunset, r.sunset.UTC().Format(http.TimeFormat))
+		if r.successor != "" {
+			header.Set(HeaderLink, "<"+r.successor+`>; rel="successor-version"`)
+		}
+		now := cfg.now()
+		client := mcontext.GetAppID(c)
+		if client == "" {
+			client = c.ClientIP()
+		}
+		if throttle.first(route, client, now) {
+			logDeprecatedUse(c, route, client, r.sunset)
+		}
+		if cfg.enforce && !now.Before(r.sunset) {
+			GinError(c, errs.ErrEndpointSunset.WrapMsg("endpoint removed", "route", route, "successor", r.successor))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
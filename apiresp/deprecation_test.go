@@ -0,0 +1,143 @@
+package apiresp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mcontext"
+)
+
+func withNow(now func() time.Time) DeprecationOption {
+	return func(c *deprecationConfig) {
+		c.now = now
+	}
+}
+
+func deprecationEngine(opts ...DeprecationOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		if appID := c.GetHeader("appID"); appID != "" {
+			c.Set(mcontext.AppID, appID)
+		}
+	}, GinDeprecation(opts...))
+	ok := func(c *gin.Context) { GinSuccess(c, "ok") }
+	engine.POST("/user/get_old", ok)
+	engine.POST("/user/get", ok)
+	return engine
+}
+
+func serve(engine *gin.Engine, path, appID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	if appID != "" {
+		req.Header.Set("appID", appID)
+	}
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func hitsOf(route string) uint64 {
+	for _, h := range DeprecatedHits() {
+		if h.Route == route {
+			return h.Hits
+		}
+	}
+	return 0
+}
+
+func TestDeprecationHeaders(t *testing.T) {
+	sunset := time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)
+	MarkDeprecated("/user/get_old", sunset, "/user/get")
+	before := hitsOf("/user/get_old")
+
+	engine := deprecationEngine(WithSunsetEnforce())
+	rec := serve(engine, "/user/get_old", "app1")
+	if got := rec.Header().Get(HeaderDeprecation); got != "true" {
+		t.Errorf("Deprecation = %q", got)
+	}
+	if got := rec.Header().Get(HeaderSunset); got != "Wed, 02 Jan 2030 15:04:05 GMT" {
+		t.Errorf("Sunset = %q", got)
+	}
+	if got := rec.Header().Get(HeaderLink); got != `</user/get>; rel="successor-version"` {
+		t.Errorf("Link = %q", got)
+	}
+	var resp ApiResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.ErrCode != 0 {
+		t.Fatalf("deprecated route not served before sunset: %s", rec.Body.String())
+	}
+	if hits := hitsOf("/user/get_old") - before; hits != 1 {
+		t.Errorf("hits = %d, want 1", hits)
+	}
+
+	rec = serve(engine, "/user/get", "app1")
+	if rec.Header().Get(HeaderDeprecation) != "" || rec.Header().Get(HeaderSunset) != "" {
+		t.Errorf("headers set on a route that is not deprecated: %v", rec.Header())
+	}
+}
+
+func TestDeprecationEnforce(t *testing.T) {
+	sunset := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	MarkDeprecated("/user/get_old", sunset, "/user/get")
+	now := sunset.Add(-time.Second)
+	clock := withNow(func() time.Time { return now })
+
+	lenient := deprecationEngine(clock)
+	enforcing := deprecationEngine(clock, WithSunsetEnforce())
+	code := func(engine *gin.Engine) int {
+		var resp ApiResponse
+		if err := json.Unmarshal(serve(engine, "/user/get_old", "app1").Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.ErrCode
+	}
+
+	if c := code(enforcing); c != 0 {
+		t.Fatalf("rejected before sunset with %d", c)
+	}
+	now = sunset
+	if c := code(enforcing); c != errs.EndpointSunsetError {
+		t.Fatalf("code after sunset = %d, want %d", c, errs.EndpointSunsetError)
+	}
+	if c := code(lenient); c != 0 {
+		t.Fatalf("served without enforce returned %d", c)
+	}
+}
+
+func TestDeprecationThrottledLog(t *testing.T) {
+	MarkDeprecated("/user/get_old", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), "")
+	var logged []string
+	old := logDeprecatedUse
+	logDeprecatedUse = func(_ context.Context, route, client string, _ time.Time) {
+		logged = append(logged, client)
+	}
+	defer func() { logDeprecatedUse = old }()
+
+	now := time.Date(2026, 5, 1, 23, 0, 0, 0, time.UTC)
+	engine := deprecationEngine(withNow(func() time.Time { return now }))
+	serve(engine, "/user/get_old", "app1")
+	serve(engine, "/user/get_old", "app1")
+	serve(engine, "/user/get_old", "app2")
+	serve(engine, "/user/get", "app3")
+	now = now.Add(2 * time.Hour)
+	rec := serve(engine, "/user/get_old", "app1")
+
+	want := []string{"app1", "app2", "app1"}
+	if len(logged) != len(want) {
+		t.Fatalf("logged %v, want %v", logged, want)
+	}
+	for i := range want {
+		if logged[i] != want[i] {
+			t.Fatalf("logged %v, want %v", logged, want)
+		}
+	}
+	if rec.Header().Get(HeaderLink) != "" {
+		t.Errorf("Link set without a successor: %q", rec.Header().Get(HeaderLink))
+	}
+}
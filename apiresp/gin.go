@@ -18,15 +18,42 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/jsonutil"
 )
 
 const (
 	ginApiResponseKey = "gin_api_response_key"
 )
 
-func ginJson(c *gin.Context, resp *ApiResponse) {
+func ginJson(c *gin.Context, status int, resp *ApiResponse) {
+	if resp.Warnings == nil {
+		resp.Warnings = errs.WarningsFrom(c)
+	}
 	c.Set(ginApiResponseKey, resp)
-	c.JSON(http.StatusOK, resp)
+	callback := c.GetString(jsonpCallbackKey)
+	asString := Int64AsString(c)
+	if callback == "" && !asString {
+		c.JSON(status, resp)
+		return
+	}
+	encodeData := jsonutil.JsonMarshal
+	if asString {
+		encodeData = MarshalInt64AsString
+	}
+	body, err := resp.marshal(encodeData)
+	if err != nil {
+		log.ZError(c, "marshal api response failed", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if callback != "" {
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Data(status, "application/javascript; charset=utf-8", jsonp(callback, body))
+		return
+	}
+	c.Data(status, "application/json; charset=utf-8", body)
 }
 
 func GetGinApiResponse(c *gin.Context) *ApiResponse {
@@ -39,9 +66,18 @@ func GetGinApiResponse(c *gin.Context) *ApiResponse {
 }
 
 func GinError(c *gin.Context, err error) {
-	ginJson(c, ParseError(err))
+	ginJson(c, http.StatusOK, ParseError(err))
+}
+
+// GinErrorStatus writes err like GinError, with the HTTP status code status
+// instead of 200. It is meant for the errors raised before any handler runs,
+// such as unknown routes, that clients and proxies expect as HTTP errors.
+func GinErrorStatus(c *gin.Context, status int, err error) {
+	ginJson(c, status, ParseError(err))
 }
 
+// GinSuccess writes data, with the fields the operating user's roles do not
+// grant removed, see FilterFields.
 func GinSuccess(c *gin.Context, data any) {
-	ginJson(c, ApiSuccess(data))
+	ginJson(c, http.StatusOK, ApiSuccess(FilterFieldsContext(c, data)))
 }
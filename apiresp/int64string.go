@@ -0,0 +1,511 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiresp
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+)
+
+// Int64AsStringHeader lets a client ask for int64 fields as strings ("true")
+// or opt out of it ("false") regardless of the server default.
+const Int64AsStringHeader = "X-Int64-As-String"
+
+// int64AsStringKey marks the requests of the routes under GinInt64AsString.
+const int64AsStringKey = "apiresp_int64_as_string"
+
+var int64AsStringDefault atomic.Bool
+
+// SetInt64AsString sets whether responses encode int64 and uint64 values as
+// JSON strings, as protojson does, so that JavaScript clients do not lose
+// precision above 2^53. It is off by default.
+func SetInt64AsString(enabled bool) {
+	int64AsStringDefault.Store(enabled)
+}
+
+// GinInt64AsString enables the int64 as string encoding for the routes it is
+// installed on.
+func GinInt64AsString() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(int64AsStringKey, true)
+		c.Next()
+	}
+}
+
+// Int64AsString reports whether the response to c encodes int64 and uint64
+// values as strings, after the Int64AsStringHeader of the request, the
+// GinInt64AsString routes and the SetInt64AsString default. Middlewares that
+// replay one response to several requests must keep the two encodings apart.
+func Int64AsString(c *gin.Context) bool {
+	if c.Request != nil {
+		if v, err := strconv.ParseBool(c.GetHeader(Int64AsStringHeader)); err == nil {
+			return v
+		}
+	}
+	if c.GetBool(int64AsStringKey) {
+		return true
+	}
+	return int64AsStringDefault.Load()
+}
+
+// MarshalInt64AsString encodes v like encoding/json, except that int64 and
+// uint64 values are quoted. Values implementing json.Marshaler or
+// encoding.TextMarshaler keep their own encoding. The layout of each type is
+// computed once and cached.
+func MarshalInt64AsString(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(256)
+	if err := encodeValue(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, errs.WrapMsg(err, "marshal int64 as string failed")
+	}
+	return buf.Bytes(), nil
+}
+
+type encoderFunc func(buf *bytes.Buffer, v reflect.Value) error
+
+var (
+	encoderCache sync.Map // reflect.Type -> encoderFunc
+	// encoderBuilds counts the types whose encoder was computed, for tests.
+	encoderBuilds atomic.Int64
+
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func encodeValue(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+	return encoderFor(v.Type())(buf, v)
+}
+
+func encoderFor(t reflect.Type) encoderFunc {
+	if f, ok := encoderCache.Load(t); ok {
+		return f.(encoderFunc)
+	}
+	// Recursive types reach themselves while their encoder is being built:
+	// they get an indirection waiting for it.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(buf *bytes.Buffer, v reflect.Value) error {
+		wg.Wait()
+		return f(buf, v)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+	f = newEncoder(t)
+	wg.Done()
+	encoderCache.Store(t, f)
+	encoderBuilds.Add(1)
+	return f
+}
+
+func newEncoder(t reflect.Type) encoderFunc {
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return marshalEncoder
+	}
+	if p := reflect.PointerTo(t); p.Implements(marshalerType) || p.Implements(textMarshalerType) {
+		return addrMarshalEncoder
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return func(buf *bytes.Buffer, v reflect.Value) error {
+			writeString(buf, v.String())
+			return nil
+		}
+	case reflect.Bool:
+		return func(buf *bytes.Buffer, v reflect.Value) error {
+			buf.WriteString(strconv.FormatBool(v.Bool()))
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return func(buf *bytes.Buffer, v reflect.Value) error {
+			buf.Write(strconv.AppendInt(buf.AvailableBuffer(), v.Int(), 10))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uintptr:
+		return func(buf *bytes.Buffer, v reflect.Value) error {
+			buf.Write(strconv.AppendUint(buf.AvailableBuffer(), v.Uint(), 10))
+			return nil
+		}
+	}
+	if !hasInt64(t, make(map[reflect.Type]bool)) {
+		return marshalEncoder
+	}
+	switch t.Kind() {
+	case reflect.Int64:
+		return func(buf *bytes.Buffer, v reflect.Value) error {
+			buf.WriteByte('"')
+			buf.WriteString(strconv.FormatInt(v.Int(), 10))
+			buf.WriteByte('"')
+			return nil
+		}
+	case reflect.Uint64:
+		return func(buf *bytes.Buffer, v reflect.Value) error {
+			buf.WriteByte('"')
+			buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+			buf.WriteByte('"')
+			return nil
+		}
+	case reflect.Interface:
+		return func(buf *bytes.Buffer, v reflect.Value) error {
+			if v.IsNil() {
+				buf.WriteString("null")
+				return nil
+			}
+			return encodeValue(buf, v.Elem())
+		}
+	case reflect.Pointer:
+		elem := encoderFor(t.Elem())
+		return func(buf *bytes.Buffer, v reflect.Value) error {
+			if v.IsNil() {
+				buf.WriteString("null")
+				return nil
+			}
+			return elem(buf, v.Elem())
+		}
+	case reflect.Slice:
+		elem := encoderFor(t.Elem())
+		return func(buf *bytes.Buffer, v reflect.Value) error {
+			if v.IsNil() {
+				buf.WriteString("null")
+				return nil
+			}
+			return encodeArray(buf, v, elem)
+		}
+	case reflect.Array:
+		elem := encoderFor(t.Elem())
+		return func(buf *bytes.Buffer, v reflect.Value) error {
+			return encodeArray(buf, v, elem)
+		}
+	case reflect.Map:
+		return newMapEncoder(t)
+	case reflect.Struct:
+		return newStructEncoder(t)
+	default:
+		return marshalEncoder
+	}
+}
+
+// hasInt64 reports whether values of t may hold an int64 or uint64. Types
+// that do not are encoded by encoding/json directly.
+func hasInt64(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Int64, reflect.Uint64, reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return hasInt64(t.Elem(), seen)
+	case reflect.Map:
+		return hasInt64(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if (f.IsExported() || f.Anonymous) && hasInt64(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func marshalEncoder(buf *bytes.Buffer, v reflect.Value) error {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+func addrMarshalEncoder(buf *bytes.Buffer, v reflect.Value) error {
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	return marshalEncoder(buf, v)
+}
+
+func encodeArray(buf *bytes.Buffer, v reflect.Value, elem encoderFunc) error {
+	buf.WriteByte('[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := elem(buf, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func newMapEncoder(t reflect.Type) encoderFunc {
+	var keyString func(k reflect.Value) string
+	switch t.Key().Kind() {
+	case reflect.String:
+		keyString = func(k reflect.Value) string { return k.String() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		keyString = func(k reflect.Value) string { return strconv.FormatInt(k.Int(), 10) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		keyString = func(k reflect.Value) string { return strconv.FormatUint(k.Uint(), 10) }
+	default:
+		return marshalEncoder
+	}
+	if t.Key().Implements(textMarshalerType) {
+		return marshalEncoder
+	}
+	elem := encoderFor(t.Elem())
+	return func(buf *bytes.Buffer, v reflect.Value) error {
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		type entry struct {
+			key string
+			val reflect.Value
+		}
+		entries := make([]entry, 0, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			entries = append(entries, entry{key: keyString(it.Key()), val: it.Value()})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+		buf.WriteByte('{')
+		for i, e := range entries {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeString(buf, e.key)
+			buf.WriteByte(':')
+			if err := elem(buf, e.val); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+}
+
+// writeString writes s as a JSON string, escaped like encoding/json does.
+func writeString(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			data, _ := json.Marshal(s)
+			buf.Write(data)
+			return
+		}
+	}
+	buf.WriteByte('"')
+	buf.WriteString(s)
+	buf.WriteByte('"')
+}
+
+type structField struct {
+	index     []int
+	prefix    string // "name":
+	omitEmpty bool
+	quoted    bool
+	encode    encoderFunc
+}
+
+// newStructEncoder follows the field rules of encoding/json for tags,
+// omitempty, the string option and embedded structs, except that conflicting
+// promoted names are resolved by depth only.
+func newStructEncoder(t reflect.Type) encoderFunc {
+	fields := structFields(t)
+	return func(buf *bytes.Buffer, v reflect.Value) error {
+		buf.WriteByte('{')
+		first := true
+		for i := range fields {
+			f := &fields[i]
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			buf.WriteString(f.prefix)
+			if f.quoted {
+				if err := encodeQuoted(buf, fv, f.encode); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := f.encode(buf, fv); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+}
+
+func structFields(t reflect.Type) []structField {
+	type candidate struct {
+		structField
+		name  string
+		depth int
+	}
+	var candidates []candidate
+	var walk func(t reflect.Type, index []int, depth int, visited map[reflect.Type]bool)
+	walk = func(t reflect.Type, index []int, depth int, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			ft := sf.Type
+			if sf.Anonymous {
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if name == "" && ft.Kind() == reflect.Struct {
+					walk(ft, append(append([]int(nil), index...), i), depth+1, visited)
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+			} else if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			var prefix bytes.Buffer
+			writeString(&prefix, name)
+			prefix.WriteByte(':')
+			candidates = append(candidates, candidate{
+				name:  name,
+				depth: depth,
+				structField: structField{
+					index:     append(append([]int(nil), index...), i),
+					prefix:    prefix.String(),
+					omitEmpty: hasOption(opts, "omitempty"),
+					quoted:    hasOption(opts, "string") && quotable(sf.Type.Kind()),
+					encode:    encoderFor(sf.Type),
+				},
+			})
+		}
+	}
+	walk(t, nil, 0, make(map[reflect.Type]bool))
+	best := make(map[string]int)
+	for i, c := range candidates {
+		if j, ok := best[c.name]; !ok || c.depth < candidates[j].depth {
+			best[c.name] = i
+		}
+	}
+	fields := make([]structField, 0, len(best))
+	for i, c := range candidates {
+		if best[c.name] == i {
+			fields = append(fields, c.structField)
+		}
+	}
+	return fields
+}
+
+func hasOption(opts, name string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func quotable(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// encodeQuoted implements the string option of encoding/json.
+func encodeQuoted(buf *bytes.Buffer, v reflect.Value, encode encoderFunc) error {
+	var inner bytes.Buffer
+	if err := encode(&inner, v); err != nil {
+		return err
+	}
+	writeString(buf, inner.String())
+	return nil
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex, reporting false on a nil
+// embedded pointer instead of panicking.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
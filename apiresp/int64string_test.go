@@ -0,0 +1,183 @@
+package apiresp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/protocol/sdkws"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const bigSeq = int64(1234567890123456789)
+
+type seqBase struct {
+	ConversationID string `json:"conversationID"`
+	MaxSeq         int64  `json:"maxSeq"`
+}
+
+type seqResp struct {
+	seqBase
+	Seq      int64            `json:"seq"`
+	Seqs     []uint64         `json:"seqs"`
+	BySender map[string]int64 `json:"bySender,omitempty"`
+	Count    int32            `json:"count,string"`
+	Skip     int64            `json:"-"`
+	Empty    int64            `json:"empty,omitempty"`
+	Next     *seqResp         `json:"next,omitempty"`
+	Extra    any              `json:"extra"`
+	At       time.Time        `json:"at"`
+	Raw      json.RawMessage  `json:"raw"`
+	hidden   int64
+}
+
+func TestMarshalInt64AsString(t *testing.T) {
+	v := &seqResp{
+		seqBase:  seqBase{ConversationID: "si_1_2", MaxSeq: bigSeq + 1},
+		Seq:      bigSeq,
+		Seqs:     []uint64{1, 18446744073709551615},
+		BySender: map[string]int64{"b": 2, "a": 1},
+		Count:    3,
+		Skip:     4,
+		Next:     &seqResp{Seq: 5},
+		Extra:    map[string]any{"n": int64(6), "f": 1.5},
+		At:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Raw:      json.RawMessage(`{"x":1}`),
+		hidden:   7,
+	}
+	got, err := MarshalInt64AsString(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"conversationID":"si_1_2","maxSeq":"1234567890123456790","seq":"1234567890123456789",` +
+		`"seqs":["1","18446744073709551615"],"bySender":{"a":"1","b":"2"},"count":"3",` +
+		`"next":{"conversationID":"","maxSeq":"0","seq":"5","seqs":null,"count":"0","extra":null,"at":"0001-01-01T00:00:00Z","raw":null},` +
+		`"extra":{"f":1.5,"n":"6"},"at":"2024-01-02T03:04:05Z","raw":{"x":1}}`
+	if string(got) != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	// Without int64 values the output is the one of encoding/json.
+	plain := struct {
+		A int32   `json:"a"`
+		B []byte  `json:"b"`
+		C float64 `json:"c,omitempty"`
+	}{A: 1, B: []byte("hi")}
+	got, _ = MarshalInt64AsString(plain)
+	std, _ := json.Marshal(plain)
+	if string(got) != string(std) {
+		t.Errorf("got %s, want %s", got, std)
+	}
+}
+
+func serveData(t *testing.T, header string, routeEnabled bool, data any) []byte {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if routeEnabled {
+		r.Use(GinInt64AsString())
+	}
+	r.GET("/", func(c *gin.Context) { GinSuccess(c, data) })
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set(Int64AsStringHeader, header)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Body.Bytes()
+}
+
+func TestGinInt64AsStringStructPath(t *testing.T) {
+	var resp struct {
+		Data struct {
+			Seq json.RawMessage `json:"seq"`
+		} `json:"data"`
+	}
+	for _, c := range []struct {
+		header string
+		route  bool
+		want   string
+	}{
+		{"true", false, `"1234567890123456789"`},
+		{"", true, `"1234567890123456789"`},
+		{"false", true, `1234567890123456789`},
+		{"", false, `1234567890123456789`},
+	} {
+		body := serveData(t, c.header, c.route, &seqResp{Seq: bigSeq})
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if string(resp.Data.Seq) != c.want {
+			t.Errorf("header %q route %v: seq = %s, want %s", c.header, c.route, resp.Data.Seq, c.want)
+		}
+	}
+}
+
+func TestGinInt64AsStringProtoPath(t *testing.T) {
+	msg := &sdkws.MsgData{
+		ServerMsgID: "m1",
+		Seq:         bigSeq,
+		SendTime:    1700000000123456789,
+		CreateTime:  1700000000123,
+		ContentType: 101,
+		Content:     []byte("hello"),
+	}
+	var resp struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(serveData(t, "true", false, msg), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["seq"] != "1234567890123456789" {
+		t.Fatalf("seq = %#v", resp.Data["seq"])
+	}
+	// Every field protojson emits must come out with the same JSON value.
+	native, err := protojson.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want map[string]any
+	if err := json.Unmarshal(native, &want); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range want {
+		if resp.Data[k] != v {
+			t.Errorf("%s = %#v, protojson %#v", k, resp.Data[k], v)
+		}
+	}
+}
+
+func TestInt64AsStringEncoderCache(t *testing.T) {
+	v := &seqResp{Seq: 1}
+	if _, err := MarshalInt64AsString(v); err != nil {
+		t.Fatal(err)
+	}
+	builds := encoderBuilds.Load()
+	for i := 0; i < 10; i++ {
+		if _, err := MarshalInt64AsString(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := encoderBuilds.Load() - builds; n != 0 {
+		t.Fatalf("%d encoders built for an already seen type", n)
+	}
+}
+
+func BenchmarkMarshalInt64AsString(b *testing.B) {
+	v := &sdkws.MsgData{ServerMsgID: "m1", Seq: bigSeq, SendTime: 1700000000123, Content: []byte("hello")}
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = MarshalInt64AsString(v)
+		}
+	})
+	b.Run("encoding/json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = json.Marshal(v)
+		}
+	})
+}
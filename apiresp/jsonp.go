@@ -0,0 +1,61 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiresp
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+)
+
+// JSONPCallbackParam is the query parameter naming the JSONP callback.
+const JSONPCallbackParam = "callback"
+
+// jsonpCallbackKey holds the callback the response of a request is wrapped in.
+const jsonpCallbackKey = "apiresp_jsonp_callback"
+
+// GinJSONP lets the routes it is installed on answer with JSONP, for web
+// clients that cannot use CORS: when the callback query parameter names one of
+// callbacks, the response is the call of that function with the usual body.
+// Other names are rejected with errs.ErrArgs, so that no page can choose the
+// function the response calls. Requests without the parameter get JSON.
+func GinJSONP(callbacks ...string) gin.HandlerFunc {
+	allowed := make(map[string]struct{}, len(callbacks))
+	for _, name := range callbacks {
+		allowed[name] = struct{}{}
+	}
+	return func(c *gin.Context) {
+		if callback, ok := c.GetQuery(JSONPCallbackParam); ok {
+			if _, ok := allowed[callback]; !ok {
+				GinError(c, errs.ErrArgs.WrapMsg("jsonp callback not allowed", "callback", callback))
+				c.Abort()
+				return
+			}
+			c.Set(jsonpCallbackKey, callback)
+		}
+		c.Next()
+	}
+}
+
+// jsonp wraps body in a call of callback. The leading comment keeps the first
+// bytes of the response out of the attacker's control, and encoding/json
+// already escapes U+2028 and U+2029, which JavaScript strings cannot hold.
+func jsonp(callback string, body []byte) []byte {
+	out := make([]byte, 0, len(callback)+len(body)+8)
+	out = append(out, "/**/"...)
+	out = append(out, callback...)
+	out = append(out, '(')
+	out = append(out, body...)
+	return append(out, ");"...)
+}
@@ -0,0 +1,73 @@
+package apiresp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+)
+
+func serveJSONP(t *testing.T, target string, opts ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(opts...)
+	r.GET("/seq", func(c *gin.Context) { GinSuccess(c, &seqResp{Seq: bigSeq}) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestGinJSONP(t *testing.T) {
+	w := serveJSONP(t, "/seq?callback=onSeq", GinJSONP("onSeq"))
+	body := w.Body.String()
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/javascript") {
+		t.Fatalf("content type = %q", ct)
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("missing nosniff")
+	}
+	if !strings.HasPrefix(body, "/**/onSeq(") || !strings.HasSuffix(body, ");") {
+		t.Fatalf("body = %s", body)
+	}
+	var resp struct {
+		Data struct {
+			Seq json.RawMessage `json:"seq"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(body, "/**/onSeq("), ");")), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Data.Seq) != "1234567890123456789" {
+		t.Errorf("seq = %s", resp.Data.Seq)
+	}
+
+	// Both modes combine.
+	body = serveJSONP(t, "/seq?callback=onSeq", GinJSONP("onSeq"), GinInt64AsString()).Body.String()
+	if !strings.Contains(body, `"seq":"1234567890123456789"`) {
+		t.Errorf("int64 as string body = %s", body)
+	}
+}
+
+func TestGinJSONPRejectsCallback(t *testing.T) {
+	for _, target := range []string{"/seq?callback=alert", "/seq?callback=onSeq%28document.cookie%29%3B"} {
+		w := serveJSONP(t, target, GinJSONP("onSeq"))
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Fatalf("%s: content type = %q", target, ct)
+		}
+		if body := w.Body.String(); !strings.Contains(body, fmt.Sprintf(`"errCode":%d`, errs.ArgsError)) || strings.Contains(body, "1234567890123456789") {
+			t.Fatalf("%s: body = %s", target, body)
+		}
+	}
+	// Without the parameter, or the middleware, the response is JSON.
+	for _, w := range []*httptest.ResponseRecorder{serveJSONP(t, "/seq", GinJSONP("onSeq")), serveJSONP(t, "/seq?callback=onSeq")} {
+		if body := w.Body.String(); !strings.HasPrefix(body, "{") {
+			t.Errorf("body = %s, want JSON", body)
+		}
+	}
+}
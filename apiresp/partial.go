@@ -0,0 +1,77 @@
+package apiresp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+)
+
+// PartialFailureCode is the top-level errCode of a batch response in which
+// every item failed.
+var PartialFailureCode = errs.PartialFailureError
+
+// ItemResult is the outcome of one item of a batch request. Index is the
+// position of the item in the request, ID an optional caller-defined key.
+type ItemResult struct {
+	Index   int    `json:"index"`
+	ID      string `json:"id,omitempty"`
+	ErrCode int    `json:"errCode"`
+	ErrMsg  string `json:"errMsg,omitempty"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// PartialSummary counts the items of a batch response.
+type PartialSummary struct {
+	Total   int `json:"total"`
+	Success int `json:"success"`
+	Failed  int `json:"failed"`
+}
+
+// NewItemResult builds the result of item index from its payload and error.
+func NewItemResult(index int, id string, data any, err error) ItemResult {
+	res := ItemResult{Index: index, ID: id}
+	if err == nil {
+		res.Data = data
+		return res
+	}
+	var codeErr errs.CodeError
+	if errors.As(err, &codeErr) {
+		res.ErrCode, res.ErrMsg = codeErr.Code(), codeErr.Msg()
+	} else {
+		res.ErrCode, res.ErrMsg = errs.ServerInternalError, errs.Unwrap(err).Error()
+	}
+	return res
+}
+
+// ApiPartialResponse builds the envelope of a batch request. errCode is 0 when
+// at least one item succeeded, or when there are no items, and
+// PartialFailureCode when all of them failed.
+func ApiPartialResponse(results []ItemResult) *ApiResponse {
+	if results == nil {
+		results = []ItemResult{}
+	}
+	summary := &PartialSummary{Total: len(results)}
+	for _, res := range results {
+		if res.ErrCode == 0 {
+			summary.Success++
+			if format, ok := res.Data.(ApiFormat); ok {
+				format.ApiFormat()
+			}
+		} else {
+			summary.Failed++
+		}
+	}
+	resp := &ApiResponse{Data: results, Summary: summary}
+	if summary.Total > 0 && summary.Success == 0 {
+		resp.ErrCode = PartialFailureCode
+		resp.ErrMsg = "all items failed"
+	}
+	return resp
+}
+
+// ApiPartial writes the partial success envelope of a batch request.
+func ApiPartial(c *gin.Context, results []ItemResult) {
+	ginJson(c, http.StatusOK, ApiPartialResponse(FilterFieldsContext(c, results).([]ItemResult)))
+}
@@ -0,0 +1,82 @@
+package apiresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type batchItem struct {
+	UserID string   `json:"userID"`
+	Tags   []string `json:"tags"`
+}
+
+func TestApiPartialGolden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := map[string][]ItemResult{
+		"all_success": {
+			NewItemResult(0, "u1", &batchItem{UserID: "u1", Tags: []string{"a"}}, nil),
+			NewItemResult(1, "u2", &batchItem{UserID: "u2", Tags: []string{}}, nil),
+		},
+		"mixed": {
+			NewItemResult(0, "u1", &batchItem{UserID: "u1", Tags: []string{}}, nil),
+			NewItemResult(1, "u2", nil, errs.ErrRecordNotFound.WrapMsg("user not found")),
+			NewItemResult(2, "u3", nil, errs.New("connection reset").Wrap()),
+		},
+		"all_fail": {
+			NewItemResult(0, "u1", nil, errs.ErrArgs.WrapMsg("bad userID")),
+			NewItemResult(1, "u2", nil, errs.ErrNoPermission.Wrap()),
+		},
+	}
+	for name, results := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			ApiPartial(c, results)
+
+			var got bytes.Buffer
+			if err := json.Indent(&got, w.Body.Bytes(), "", "  "); err != nil {
+				t.Fatal(err)
+			}
+			got.WriteByte('\n')
+			path := filepath.Join("testdata", "partial_"+name+".golden")
+			if *update {
+				if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("response mismatch\ngot:\n%s\nwant:\n%s", got.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestApiPartialEmpty(t *testing.T) {
+	resp := ApiPartialResponse(nil)
+	if resp.ErrCode != 0 || resp.Summary.Total != 0 {
+		t.Fatalf("empty batch: errCode=%d summary=%+v", resp.ErrCode, resp.Summary)
+	}
+}
+
+func TestPartialFailureCodeConfigurable(t *testing.T) {
+	defer func(code int) { PartialFailureCode = code }(PartialFailureCode)
+	PartialFailureCode = 4999
+	resp := ApiPartialResponse([]ItemResult{NewItemResult(0, "", nil, errs.ErrArgs.Wrap())})
+	if resp.ErrCode != 4999 {
+		t.Fatalf("errCode = %d, want 4999", resp.ErrCode)
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiresp
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/openimsdk/tools/mcontext"
+	"google.golang.org/protobuf/proto"
+)
+
+// PermTag is the struct tag listing the roles allowed to see a field, e.g.
+// `perm:"admin"` or `perm:"admin,auditor"`. Fields the caller may not see are
+// reset to their zero value, so with omitempty they are left out of the JSON.
+const PermTag = "perm"
+
+// permField is a struct field that must be filtered.
+type permField struct {
+	index int
+	// tag is the PermTag of the field: the field is reset when the roles do
+	// not satisfy it.
+	tag string
+	// nested is set when the field value must be filtered recursively.
+	nested bool
+}
+
+var (
+	// permPlans caches the fields to filter per struct type.
+	permPlans sync.Map // reflect.Type -> []permField
+	// permTypes caches whether a type may contain permission tagged fields.
+	permTypes sync.Map // reflect.Type -> bool
+	// mapValues pools the addressable values map entries are read into, per
+	// map value type, since reading them directly allocates.
+	mapValues sync.Map // reflect.Type -> *sync.Pool
+
+	protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+// FilterFieldsContext filters data with the roles of the operating user in ctx.
+func FilterFieldsContext(ctx context.Context, data any) any {
+	return FilterFields(data, mcontext.GetOpUserRoles(ctx))
+}
+
+// FilterFields returns data with every field whose PermTag is not satisfied
+// by roles reset, recursing into nested structs, pointers, slices, arrays,
+// maps and interfaces. data itself is never modified: the parts that change
+// are copied, and data is returned as is when nothing needs filtering.
+// Generated protobuf messages cannot carry the tag and are never walked.
+func FilterFields(data any, roles []string) any {
+	v := reflect.ValueOf(data)
+	if !v.IsValid() || !mayFilter(v.Type()) {
+		return data
+	}
+	out, changed := filterValue(v, roles)
+	if !changed {
+		return data
+	}
+	return out.Interface()
+}
+
+// permitted reports whether one of the comma separated roles of tag is in
+// roles.
+func permitted(tag string, roles []string) bool {
+	for rest := tag; rest != ""; {
+		var role string
+		role, rest, _ = strings.Cut(rest, ",")
+		if role = strings.TrimSpace(role); role == "" {
+			continue
+		}
+		for _, r := range roles {
+			if r == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func mayFilter(t reflect.Type) bool {
+	if v, ok := permTypes.Load(t); ok {
+		return v.(bool)
+	}
+	res := walkPermType(t, make(map[reflect.Type]bool))
+	permTypes.Store(t, res)
+	return res
+}
+
+// walkPermType reports whether values of t may contain permission tagged
+// fields. Interfaces may, their dynamic values are checked by filterValue.
+// Protobuf messages may not: their fields are generated without the tag.
+func walkPermType(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	if t.Kind() == reflect.Struct && reflect.PointerTo(t).Implements(protoMessageType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return walkPermType(t.Elem(), visiting)
+	case reflect.Map:
+		return walkPermType(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if f.Tag.Get(PermTag) != "" || walkPermType(f.Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func structPlan(t reflect.Type) []permField {
+	if v, ok := permPlans.Load(t); ok {
+		return v.([]permField)
+	}
+	var plan []permField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		field := permField{index: i, tag: f.Tag.Get(PermTag), nested: mayFilter(f.Type)}
+		if field.tag != "" || field.nested {
+			plan = append(plan, field)
+		}
+	}
+	permPlans.Store(t, plan)
+	return plan
+}
+
+// filterValue returns the filtered v and whether it differs from v. When it
+// does, the returned value shares nothing that was changed with v.
+func filterValue(v reflect.Value, roles []string) (reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v, false
+		}
+		elem, changed := filterValue(v.Elem(), roles)
+		if !changed {
+			return v, false
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(elem)
+		return p, true
+	case reflect.Interface:
+		if v.IsNil() || !mayFilter(v.Elem().Type()) {
+			return v, false
+		}
+		elem, changed := filterValue(v.Elem(), roles)
+		if !changed {
+			return v, false
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(elem)
+		return out, true
+	case reflect.Struct:
+		var out reflect.Value
+		for _, f := range structPlan(v.Type()) {
+			field := v.Field(f.index)
+			var (
+				val     reflect.Value
+				changed bool
+			)
+			if f.tag != "" && !permitted(f.tag, roles) {
+				if field.IsZero() {
+					continue
+				}
+				val, changed = reflect.Zero(field.Type()), true
+			} else if f.nested {
+				val, changed = filterValue(field, roles)
+			}
+			if !changed {
+				continue
+			}
+			if !out.IsValid() {
+				out = reflect.New(v.Type()).Elem()
+				out.Set(v)
+			}
+			out.Field(f.index).Set(val)
+		}
+		if !out.IsValid() {
+			return v, false
+		}
+		return out, true
+	case reflect.Slice, reflect.Array:
+		if (v.Kind() == reflect.Slice && v.IsNil()) || !mayFilter(v.Type().Elem()) {
+			return v, false
+		}
+		var out reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			elem, changed := filterValue(v.Index(i), roles)
+			if !changed {
+				continue
+			}
+			if !out.IsValid() {
+				if v.Kind() == reflect.Slice {
+					out = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+				} else {
+					out = reflect.New(v.Type()).Elem()
+				}
+				reflect.Copy(out, v)
+			}
+			out.Index(i).Set(elem)
+		}
+		if !out.IsValid() {
+			return v, false
+		}
+		return out, true
+	case reflect.Map:
+		if v.IsNil() || !mayFilter(v.Type().Elem()) {
+			return v, false
+		}
+		pool := mapValuePool(v.Type().Elem())
+		holder := pool.Get()
+		defer pool.Put(holder)
+		value := reflect.ValueOf(holder).Elem()
+		defer value.SetZero()
+		var out reflect.Value
+		iter := v.MapRange()
+		for iter.Next() {
+			value.SetIterValue(iter)
+			elem, changed := filterValue(value, roles)
+			if !changed {
+				continue
+			}
+			if !out.IsValid() {
+				out = reflect.MakeMapWithSize(v.Type(), v.Len())
+				copyIter := v.MapRange()
+				for copyIter.Next() {
+					out.SetMapIndex(copyIter.Key(), copyIter.Value())
+				}
+			}
+			out.SetMapIndex(iter.Key(), elem)
+		}
+		if !out.IsValid() {
+			return v, false
+		}
+		return out, true
+	default:
+		return v, false
+	}
+}
+
+func mapValuePool(t reflect.Type) *sync.Pool {
+	if p, ok := mapValues.Load(t); ok {
+		return p.(*sync.Pool)
+	}
+	p, _ := mapValues.LoadOrStore(t, &sync.Pool{New: func() any { return reflect.New(t).Interface() }})
+	return p.(*sync.Pool)
+}
@@ -0,0 +1,180 @@
+package apiresp
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/mcontext"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type permAddress struct {
+	City   string `json:"city"`
+	Street string `json:"street,omitempty" perm:"admin"`
+}
+
+type permUser struct {
+	UserID    string            `json:"userID"`
+	Phone     string            `json:"phone,omitempty" perm:"admin"`
+	LoginIP   string            `json:"loginIP,omitempty" perm:"admin,auditor"`
+	Address   *permAddress      `json:"address,omitempty"`
+	Addresses []permAddress     `json:"addresses,omitempty"`
+	ByName    map[string]any    `json:"byName,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+type permUsersResp struct {
+	Users []*permUser `json:"users"`
+	Total int         `json:"total"`
+}
+
+func newPermResp() *permUsersResp {
+	return &permUsersResp{
+		Users: []*permUser{
+			{
+				UserID:    "u1",
+				Phone:     "+100",
+				LoginIP:   "10.0.0.1",
+				Address:   &permAddress{City: "a", Street: "s1"},
+				Addresses: []permAddress{{City: "b", Street: "s2"}},
+				ByName:    map[string]any{"home": &permAddress{City: "c", Street: "s3"}},
+			},
+			{UserID: "u2"},
+		},
+		Total: 2,
+	}
+}
+
+func TestFilterFieldsNested(t *testing.T) {
+	orig := newPermResp()
+	got := FilterFields(orig, nil).(*permUsersResp)
+	u := got.Users[0]
+	if u.UserID != "u1" || u.Phone != "" || u.LoginIP != "" {
+		t.Errorf("top level fields not filtered: %+v", u)
+	}
+	if u.Address.City != "a" || u.Address.Street != "" {
+		t.Errorf("nested pointer not filtered: %+v", u.Address)
+	}
+	if u.Addresses[0].City != "b" || u.Addresses[0].Street != "" {
+		t.Errorf("slice element not filtered: %+v", u.Addresses[0])
+	}
+	if home := u.ByName["home"].(*permAddress); home.City != "c" || home.Street != "" {
+		t.Errorf("map value not filtered: %+v", home)
+	}
+	if got.Users[1] != orig.Users[1] {
+		t.Error("unchanged element was copied")
+	}
+
+	// The original must be left intact, it may be cached and shared.
+	want := newPermResp()
+	if o := orig.Users[0]; o.Phone != want.Users[0].Phone || o.Address.Street != "s1" ||
+		o.Addresses[0].Street != "s2" || o.ByName["home"].(*permAddress).Street != "s3" {
+		t.Errorf("original modified: %+v", o)
+	}
+}
+
+func TestFilterFieldsRoles(t *testing.T) {
+	orig := newPermResp()
+	if got := FilterFields(orig, []string{"member", "admin"}); got != any(orig) {
+		t.Error("admin response was copied")
+	}
+	u := FilterFields(orig, []string{"auditor"}).(*permUsersResp).Users[0]
+	if u.Phone != "" || u.LoginIP != "10.0.0.1" {
+		t.Errorf("auditor sees phone=%q loginIP=%q", u.Phone, u.LoginIP)
+	}
+	if got := FilterFields(&permAddress{City: "x"}, nil); got.(*permAddress).City != "x" {
+		t.Errorf("zero tagged field: got %+v", got)
+	}
+	if got := FilterFields("plain", nil); got != "plain" {
+		t.Errorf("untagged value changed: %v", got)
+	}
+}
+
+func TestFilterFieldsCache(t *testing.T) {
+	orig := newPermResp()
+	roleSets := [][]string{nil, {"admin"}, {"auditor"}, {"admin"}, nil, {"auditor", "member"}}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, roles := range roleSets {
+			wg.Add(1)
+			go func(roles []string) {
+				defer wg.Done()
+				u := FilterFields(orig, roles).(*permUsersResp).Users[0]
+				admin := permitted("admin", roles)
+				auditor := admin || permitted("auditor", roles)
+				if (u.Phone != "") != admin || (u.LoginIP != "") != auditor || (u.Address.Street != "") != admin {
+					t.Errorf("roles %v: phone=%q loginIP=%q street=%q", roles, u.Phone, u.LoginIP, u.Address.Street)
+				}
+			}(roles)
+		}
+	}
+	wg.Wait()
+}
+
+func TestFilterFieldsSkipsUntagged(t *testing.T) {
+	msg := structpb.NewStringValue("u1")
+	if got := FilterFields(msg, nil); got != any(msg) {
+		t.Error("protobuf message was copied")
+	}
+	page := &permPage{Data: msg, Extra: map[string]any{"next": "c2", "user": &permAddress{City: "a", Street: "s"}}}
+	got := FilterFields(page, nil).(*permPage)
+	if got.Data != any(msg) || got.Extra["next"] != "c2" || got.Extra["user"].(*permAddress).Street != "" {
+		t.Errorf("filtered page = %+v", got)
+	}
+	if page.Extra["user"].(*permAddress).Street != "s" {
+		t.Error("original map value modified")
+	}
+}
+
+func TestGinSuccessFiltersFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		roles []string
+		phone bool
+	}{{nil, false}, {[]string{"admin"}, true}} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		if tc.roles != nil {
+			c.Set(mcontext.OpUserRoles, tc.roles)
+		}
+		GinSuccess(c, newPermResp())
+		if got := strings.Contains(w.Body.String(), `"phone":"+100"`); got != tc.phone {
+			t.Errorf("roles %v: phone present=%v in %s", tc.roles, got, w.Body.String())
+		}
+	}
+}
+
+// permPage is a response without any permission tagged field.
+type permPage struct {
+	Total int64             `json:"total"`
+	Data  any               `json:"data"`
+	Extra map[string]any    `json:"extra"`
+	Items []*structpb.Value `json:"items"`
+}
+
+func BenchmarkFilterFieldsUnfiltered(b *testing.B) {
+	msg, err := structpb.NewStruct(map[string]any{"name": "group", "members": []any{"u1", "u2"}, "count": 2})
+	if err != nil {
+		b.Fatal(err)
+	}
+	roles := []string{"admin"}
+	for _, bc := range []struct {
+		name string
+		data any
+	}{
+		{"proto", msg},
+		{"oneof", structpb.NewStringValue("u1")},
+		{"struct", &permPage{Total: 2, Data: msg, Extra: map[string]any{"next": "c2", "page": 2}, Items: []*structpb.Value{structpb.NewNumberValue(1)}}},
+		{"map", map[string]any{"userID": "u1", "nickname": "n", "seq": int64(7)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				FilterFields(bc.data, roles)
+			}
+		})
+	}
+}
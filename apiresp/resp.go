@@ -28,11 +28,20 @@ type ApiResponse struct {
 	ErrMsg  string `json:"errMsg"`
 	ErrDlt  string `json:"errDlt"`
 	Data    any    `json:"data,omitempty"`
+	// Summary is only set by batch responses, see ApiPartial.
+	Summary *PartialSummary `json:"summary,omitempty"`
+	// Warnings are the non-fatal issues of the request, see errs.AddWarning.
+	Warnings []errs.Warning `json:"warnings,omitempty"`
 }
 
 func (r *ApiResponse) MarshalJSON() ([]byte, error) {
+	return r.marshal(jsonutil.JsonMarshal)
+}
+
+// marshal encodes the response, with Data encoded by encodeData.
+func (r *ApiResponse) marshal(encodeData func(v any) ([]byte, error)) ([]byte, error) {
 	type apiResponse ApiResponse
-	tmp := (*apiResponse)(r)
+	tmp := *(*apiResponse)(r)
 	if tmp.Data != nil {
 		if format, ok := tmp.Data.(ApiFormat); ok {
 			format.ApiFormat()
@@ -40,14 +49,14 @@ func (r *ApiResponse) MarshalJSON() ([]byte, error) {
 		if isAllFieldsPrivate(tmp.Data) {
 			tmp.Data = nil
 		} else {
-			data, err := jsonutil.JsonMarshal(tmp.Data)
+			data, err := encodeData(tmp.Data)
 			if err != nil {
 				return nil, err
 			}
 			tmp.Data = json.RawMessage(data)
 		}
 	}
-	return jsonutil.JsonMarshal(tmp)
+	return jsonutil.JsonMarshal(&tmp)
 }
 
 func isAllFieldsPrivate(v any) bool {
@@ -0,0 +1,269 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// Kinds of CallbackError.
+const (
+	CallbackFailureConfig      = "config"
+	CallbackFailureDNS         = "dns"
+	CallbackFailureTLS         = "tls"
+	CallbackFailureTimeout     = "timeout"
+	CallbackFailureConnect     = "connect"
+	CallbackFailureClientError = "4xx"
+	CallbackFailureServerError = "5xx"
+)
+
+// Callback is a webhook the service calls, such as callbackBeforeSendSingleMsg.
+type Callback struct {
+	Name   string `yaml:"name"`
+	URL    string `yaml:"url"`
+	Enable bool   `yaml:"enable"`
+}
+
+// CallbackConfig lists the webhooks of the service, whose reachability
+// CheckCallbacks verifies, as misconfigured egress otherwise silently drops
+// every callback.
+type CallbackConfig struct {
+	Callbacks []Callback `yaml:"callbacks"`
+	// Method is the method of the probe request, http.MethodHead by default,
+	// or http.MethodOptions.
+	Method string `yaml:"method"`
+	// WarnOnly reports the unreachable callbacks as warnings instead of
+	// failing the check, as the remote may legitimately be down at deploy
+	// time.
+	WarnOnly bool `yaml:"warnOnly"`
+}
+
+// CallbackError is a callback that failed its probe. It unwraps to
+// errs.ErrConfig for invalid URLs and certificates, and to
+// errs.ErrDependencyUnavailable otherwise.
+type CallbackError struct {
+	Name string
+	// URL is the callback URL without its user info and query, which may
+	// hold credentials.
+	URL  string
+	Kind string
+	// StatusCode is the response status, for the 4xx and 5xx kinds.
+	StatusCode int
+	Detail     string
+}
+
+func (e *CallbackError) Error() string {
+	var b strings.Builder
+	b.WriteString("callback " + e.Name + ": " + e.Kind)
+	if e.StatusCode != 0 {
+		b.WriteString(" " + strconv.Itoa(e.StatusCode))
+	}
+	b.WriteString(", " + e.URL)
+	if e.Detail != "" {
+		b.WriteString(", " + e.Detail)
+	}
+	return b.String()
+}
+
+func (e *CallbackError) Unwrap() error {
+	switch e.Kind {
+	case CallbackFailureConfig, CallbackFailureTLS:
+		return errs.ErrConfig
+	default:
+		return errs.ErrDependencyUnavailable
+	}
+}
+
+// CheckCallbacks probes every enabled callback of conf concurrently: it
+// resolves the host, completes the TLS handshake and sends a HEAD or OPTIONS
+// request, without following redirects. Any response is a success except 4xx
+// and 5xx statuses; 405 is a success too, as the endpoint exists. Failures
+// are *CallbackError values, returned in Err as an *errs.MultiError, or in
+// Warnings when conf.WarnOnly is set. WithResolver and WithProbeTimeout
+// apply.
+func CheckCallbacks(ctx context.Context, conf *CallbackConfig, opts ...EndpointOption) *CheckResult {
+	o := endpointOptions{timeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	res := &CheckResult{Component: NameCallbacks, Extra: make(map[string]string)}
+	start := time.Now()
+	defer func() {
+		res.Latency = time.Since(start)
+	}()
+	method := conf.Method
+	if method == "" {
+		method = http.MethodHead
+	}
+	if method != http.MethodHead && method != http.MethodOptions {
+		res.Err = errs.ErrConfig.WrapMsg("callback probe method must be HEAD or OPTIONS", "method", method)
+		return res
+	}
+	var callbacks []Callback
+	for _, cb := range conf.Callbacks {
+		if cb.Enable {
+			callbacks = append(callbacks, cb)
+			res.Addresses = append(res.Addresses, redactURL(cb.URL))
+		}
+	}
+	results := make([]*CallbackError, len(callbacks))
+	client := callbackClient(o.resolver)
+	defer client.CloseIdleConnections()
+	done := make(chan struct{})
+	for i, cb := range callbacks {
+		go func(i int, cb Callback) {
+			defer func() { done <- struct{}{} }()
+			ctx, cancel := context.WithTimeout(ctx, o.timeout)
+			defer cancel()
+			results[i] = probeCallback(ctx, client, method, cb)
+		}(i, cb)
+	}
+	for range callbacks {
+		<-done
+	}
+
+	var failed errs.MultiError
+	for i, cb := range callbacks {
+		if results[i] == nil {
+			res.Extra[cb.Name] = EndpointOK
+			continue
+		}
+		res.Extra[cb.Name] = results[i].Kind
+		if conf.WarnOnly {
+			res.Warnings = append(res.Warnings, results[i])
+		} else {
+			failed.Append(results[i])
+		}
+	}
+	res.Err = failed.ErrorOrNil()
+	return res
+}
+
+// callbackClient returns a client resolving host names with resolver.
+func callbackClient(resolver Resolver) *http.Client {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	var dialer net.Dialer
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		ips, err := resolver.LookupHost(ctx, host)
+		if err == nil && len(ips) == 0 {
+			err = errors.New("no address found")
+		}
+		if err != nil {
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) {
+				return nil, err
+			}
+			return nil, &net.DNSError{Err: err.Error(), Name: host}
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0], port))
+	}
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func probeCallback(ctx context.Context, client *http.Client, method string, cb Callback) *CallbackError {
+	fail := func(kind string, statusCode int, detail string) *CallbackError {
+		return &CallbackError{Name: cb.Name, URL: redactURL(cb.URL), Kind: kind, StatusCode: statusCode, Detail: detail}
+	}
+	u, err := url.Parse(cb.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fail(CallbackFailureConfig, 0, "url must be an http:// or https:// URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, cb.URL, nil)
+	if err != nil {
+		return fail(CallbackFailureConfig, 0, err.Error())
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The url.Error message would repeat the URL with its query.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fail(classifyCallbackError(ctx, err), 0, err.Error())
+	}
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed:
+		return nil
+	case resp.StatusCode >= 500:
+		return fail(CallbackFailureServerError, resp.StatusCode, "")
+	case resp.StatusCode >= 400:
+		return fail(CallbackFailureClientError, resp.StatusCode, "")
+	}
+	return nil
+}
+
+func classifyCallbackError(ctx context.Context, err error) string {
+	var (
+		dnsErr      *net.DNSError
+		verifyErr   *tls.CertificateVerificationError
+		unknownAuth x509.UnknownAuthorityError
+		hostnameErr x509.HostnameError
+		invalidErr  x509.CertificateInvalidError
+		recordErr   tls.RecordHeaderError
+		netErr      net.Error
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return CallbackFailureDNS
+	case errors.As(err, &verifyErr), errors.As(err, &unknownAuth), errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr), errors.As(err, &recordErr):
+		return CallbackFailureTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout(), ctx.Err() != nil:
+		return CallbackFailureTimeout
+	}
+	return CallbackFailureConnect
+}
+
+// redactURL returns u without its user info and query.
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "invalid url"
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
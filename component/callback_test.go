@@ -0,0 +1,137 @@
+package component
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+func statusServer(t *testing.T, status int) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("probe method = %s", r.Method)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestCheckCallbacksClassification(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer tlsSrv.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + closed.Addr().String() + "/cb"
+	_ = closed.Close()
+
+	want := map[string]string{
+		"ok":          EndpointOK,
+		"redirect":    EndpointOK,
+		"notAllowed":  EndpointOK,
+		"notFound":    CallbackFailureClientError,
+		"unavailable": CallbackFailureServerError,
+		"timeout":     CallbackFailureTimeout,
+		"tls":         CallbackFailureTLS,
+		"dns":         CallbackFailureDNS,
+		"refused":     CallbackFailureConnect,
+		"invalid":     CallbackFailureConfig,
+	}
+	conf := &CallbackConfig{Callbacks: []Callback{
+		{Name: "ok", URL: statusServer(t, http.StatusOK) + "/callbackBeforeSendSingleMsg?secret=s3cr3t", Enable: true},
+		{Name: "redirect", URL: statusServer(t, http.StatusFound), Enable: true},
+		{Name: "notAllowed", URL: statusServer(t, http.StatusMethodNotAllowed), Enable: true},
+		{Name: "notFound", URL: statusServer(t, http.StatusNotFound), Enable: true},
+		{Name: "unavailable", URL: statusServer(t, http.StatusServiceUnavailable), Enable: true},
+		{Name: "timeout", URL: slow.URL, Enable: true},
+		{Name: "tls", URL: tlsSrv.URL, Enable: true},
+		{Name: "dns", URL: "https://callback.saas.example/hook", Enable: true},
+		{Name: "refused", URL: refused, Enable: true},
+		{Name: "invalid", URL: "ftp://callback.saas.example", Enable: true},
+		{Name: "disabled", URL: "http://disabled.example", Enable: false},
+	}}
+	res := CheckCallbacks(context.Background(), conf, WithResolver(stubResolver{}), WithProbeTimeout(200*time.Millisecond))
+	if len(res.Extra) != len(want) || len(res.Addresses) != len(want) {
+		t.Fatalf("Extra = %v, Addresses = %v", res.Extra, res.Addresses)
+	}
+	for name, kind := range want {
+		if res.Extra[name] != kind {
+			t.Errorf("%s: %q, want %q", name, res.Extra[name], kind)
+		}
+	}
+	if strings.Contains(strings.Join(res.Addresses, ","), "s3cr3t") {
+		t.Errorf("addresses leak the query: %v", res.Addresses)
+	}
+
+	var multi *errs.MultiError
+	if !errors.As(res.Err, &multi) || multi.Len() != 7 {
+		t.Fatalf("Err = %v, want the 7 failures", res.Err)
+	}
+	var cbErr *CallbackError
+	if !errors.As(multi.Errors[0], &cbErr) || cbErr.Name != "notFound" || cbErr.StatusCode != http.StatusNotFound {
+		t.Errorf("first failure = %v", multi.Errors[0])
+	}
+	if !errors.Is(multi.Errors[0], errs.ErrDependencyUnavailable) {
+		t.Errorf("4xx does not unwrap to ErrDependencyUnavailable: %v", multi.Errors[0])
+	}
+}
+
+func TestCheckCallbacksWarnOnly(t *testing.T) {
+	conf := &CallbackConfig{
+		Method:   http.MethodOptions,
+		WarnOnly: true,
+		Callbacks: []Callback{
+			{Name: "afterSend", URL: "https://callback.saas.example/hook", Enable: true},
+		},
+	}
+	res := CheckCallbacks(context.Background(), conf, WithResolver(stubResolver{}))
+	if res.Err != nil || len(res.Warnings) != 1 {
+		t.Fatalf("Err = %v, Warnings = %v", res.Err, res.Warnings)
+	}
+
+	var r Report
+	r.AddResults([]*CheckResult{res, {Component: NameRedis}})
+	var out bytes.Buffer
+	if err := r.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if s := r.Summary(); s.Warnings != 1 || s.OK != 1 || r.HasErrors() {
+		t.Fatalf("summary = %+v\n%s", s, out.String())
+	}
+	if !strings.Contains(out.String(), "callback afterSend: dns") {
+		t.Errorf("report = %s", out.String())
+	}
+
+	conf.Method = http.MethodGet
+	if res := CheckCallbacks(context.Background(), conf); !errors.Is(res.Err, errs.ErrConfig) {
+		t.Fatalf("GET probe: Err = %v, want ErrConfig", res.Err)
+	}
+}
+
+func TestCheckAllCallbacks(t *testing.T) {
+	cfg := &Config{Callbacks: &CallbackConfig{Callbacks: []Callback{
+		{Name: "beforeSendSingleMsg", URL: statusServer(t, http.StatusServiceUnavailable), Enable: true},
+	}}}
+	results, err := CheckAll(context.Background(), cfg)
+	if len(results) != 1 || results[0].Component != NameCallbacks || !errors.Is(err, errs.ErrDependencyUnavailable) {
+		t.Fatalf("CheckAll = %v, %v", results, err)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openimsdk/tools/discovery/nacos"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mq/kafka"
+	"github.com/openimsdk/tools/mq/rabbitmq"
+)
+
+// Default limits of CheckAll.
+const (
+	DefaultCheckConcurrency = 4
+	DefaultCheckTimeout     = 30 * time.Second
+)
+
+type checkAllOptions struct {
+	concurrency int
+	timeout     time.Duration
+	deadline    time.Duration
+	skip        map[string]bool
+	report      *ReportTarget
+}
+
+type CheckAllOption func(o *checkAllOptions)
+
+// WithCheckConcurrency bounds the number of checks running at once, see
+// DefaultCheckConcurrency.
+func WithCheckConcurrency(n int) CheckAllOption {
+	return func(o *checkAllOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithCheckTimeout bounds each check, see DefaultCheckTimeout.
+func WithCheckTimeout(timeout time.Duration) CheckAllOption {
+	return func(o *checkAllOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithCheckDeadline bounds the whole run, in addition to the deadline of the
+// context given to CheckAll.
+func WithCheckDeadline(deadline time.Duration) CheckAllOption {
+	return func(o *checkAllOptions) {
+		o.deadline = deadline
+	}
+}
+
+// WithSkipComponents disables the checks of components, named as in
+// CheckResult.Component: "mongo", "redis", "kafka", "zookeeper", "nacos",
+// "minio", "rabbitmq" and "callbacks".
+func WithSkipComponents(components ...string) CheckAllOption {
+	return func(o *checkAllOptions) {
+		for _, c := range components {
+			o.skip[c] = true
+		}
+	}
+}
+
+// WithCheckReport persists the CheckReport of the run to target, whether it
+// succeeds or not.
+func WithCheckReport(target ReportTarget) CheckAllOption {
+	return func(o *checkAllOptions) {
+		o.report = &target
+	}
+}
+
+type namedCheck struct {
+	component string
+	run       func(ctx context.Context) *CheckResult
+}
+
+// checks returns a check for each component configured in cfg.
+func (cfg *Config) checks() []namedCheck {
+	var checks []namedCheck
+	add := func(component string, run func(ctx context.Context) *CheckResult) {
+		checks = append(checks, namedCheck{component: component, run: run})
+	}
+	if cfg.Mongo != nil {
+		add("mongo", func(ctx context.Context) *CheckResult { return CheckMongo(ctx, cfg.Mongo) })
+	}
+	if cfg.Redis != nil {
+		add("redis", func(ctx context.Context) *CheckResult {
+			var opts []RedisOption
+			if cfg.RedisLatencyThreshold > 0 {
+				opts = append(opts, WithRedisLatencyThreshold(cfg.RedisLatencyThreshold))
+			}
+			if cfg.RedisProbeWrite {
+				opts = append(opts, WithRedisWriteProbe(cfg.RedisProbePrefix))
+			}
+			return CheckRedis(ctx, cfg.Redis, opts...)
+		})
+	}
+	if cfg.Kafka != nil {
+		add("kafka", func(ctx context.Context) *CheckResult {
+			var opts []KafkaOption
+			if len(cfg.KafkaGroups) > 0 {
+				opts = append(opts, WithKafkaTopology(cfg.KafkaTopics, cfg.KafkaGroups))
+			}
+			if cfg.KafkaCreateTopics {
+				opts = append(opts, WithKafkaCreateTopics(cfg.KafkaTopics, cfg.KafkaTopicSpec))
+			}
+			if cfg.KafkaTopicRequirements != (kafka.TopicRequirements{}) {
+				opts = append(opts, WithKafkaTopicRequirements(cfg.KafkaTopics, cfg.KafkaTopicRequirements))
+				if cfg.KafkaStrictTopics {
+					opts = append(opts, WithKafkaStrictTopics())
+				}
+			}
+			if cfg.KafkaProbeReadWrite {
+				opts = append(opts, WithKafkaReadWriteProbe(cfg.KafkaProbeTopic))
+			}
+			return CheckKafka(ctx, cfg.Kafka, opts...)
+		})
+	}
+	if cfg.Zookeeper != nil {
+		add("zookeeper", func(ctx context.Context) *CheckResult { return CheckZookeeper(ctx, cfg.Zookeeper) })
+	}
+	if cfg.Nacos != nil {
+		add("nacos", func(ctx context.Context) *CheckResult {
+			return runCheck(ctx, "nacos", cfg.Nacos.ServerAddrs, func(ctx context.Context) error {
+				return nacos.Check(ctx, cfg.Nacos)
+			})
+		})
+	}
+	if cfg.Minio != nil {
+		add("minio", func(ctx context.Context) *CheckResult {
+			var opts []MinioOption
+			if cfg.MinioNoCreateBucket {
+				opts = append(opts, WithMinioNoCreateBucket())
+			}
+			return CheckMinio(ctx, cfg.Minio, opts...)
+		})
+	}
+	if cfg.RabbitMQ != nil {
+		add("rabbitmq", func(ctx context.Context) *CheckResult {
+			return runCheck(ctx, "rabbitmq", []string{rabbitMQAddr(cfg.RabbitMQ)}, func(ctx context.Context) error {
+				_, err := rabbitmq.Check(ctx, cfg.RabbitMQ)
+				return err
+			})
+		})
+	}
+	if cfg.Callbacks != nil {
+		add(NameCallbacks, func(ctx context.Context) *CheckResult { return CheckCallbacks(ctx, cfg.Callbacks) })
+	}
+	return checks
+}
+
+func rabbitMQAddr(conf *rabbitmq.Config) string {
+	if conf.URI != "" {
+		return maskURI(conf.URI)
+	}
+	return net.JoinHostPort(conf.Host, strconv.Itoa(conf.Port))
+}
+
+// CheckAll runs the checks of every component configured in cfg concurrently
+// and returns their results in the order of the Config fields. The error
+// lists every component that failed, as an *errs.MultiError.
+func CheckAll(ctx context.Context, cfg *Config, opts ...CheckAllOption) (results []*CheckResult, err error) {
+	start := time.Now()
+	o := checkAllOptions{
+		concurrency: DefaultCheckConcurrency,
+		timeout:     DefaultCheckTimeout,
+		skip:        make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.report != nil {
+		target := *o.report
+		target.Config = cfg
+		defer func() {
+			rec := newReportRecorder(target)
+			rec.addAttempt(start, err, results)
+			rec.finish(ctx, err)
+		}()
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = 1
+	}
+	if o.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.deadline)
+		defer cancel()
+	}
+
+	var checks []namedCheck
+	for _, c := range cfg.checks() {
+		if !o.skip[c.component] {
+			checks = append(checks, c)
+		}
+	}
+	results = make([]*CheckResult, len(checks))
+	sem := make(chan struct{}, o.concurrency)
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i] = &CheckResult{Component: c.component, Err: errs.WrapMsg(ctx.Err(), c.component+" check not started")}
+				return
+			}
+			ctx, cancel := context.WithTimeout(ctx, o.timeout)
+			defer cancel()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	var failed errs.MultiError
+	for _, res := range results {
+		if res.Err != nil {
+			failed.Append(errs.WrapMsg(res.Err, "component check failed", "component", res.Component, "addr", res.Addresses))
+		}
+	}
+	return results, failed.ErrorOrNil()
+}
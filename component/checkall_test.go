@@ -0,0 +1,63 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/db/redisutil"
+	"github.com/openimsdk/tools/discovery/zookeeper"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mq/kafka"
+)
+
+func TestCheckAll(t *testing.T) {
+	hung := silentListener(t)
+	cfg := &Config{
+		Redis:     &redisutil.Config{Address: []string{hung}},
+		Kafka:     &kafka.Config{Addr: []string{newKafkaBroker(t).Addr()}},
+		Zookeeper: &zookeeper.Config{ZkServers: []string{hung}, Scheme: "openim"},
+	}
+	start := time.Now()
+	results, err := CheckAll(context.Background(), cfg, WithCheckTimeout(300*time.Millisecond), WithSkipComponents("zookeeper"))
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("CheckAll took %s", elapsed)
+	}
+	if len(results) != 2 || results[0].Component != "redis" || results[1].Component != "kafka" {
+		t.Fatalf("results = %+v, want redis then kafka", results)
+	}
+	if results[1].Err != nil {
+		t.Errorf("kafka: %v", results[1].Err)
+	}
+	var multi *errs.MultiError
+	if !errors.As(err, &multi) || multi.Len() != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the redis timeout only", err)
+	}
+	if !strings.Contains(err.Error(), hung) {
+		t.Errorf("err %q does not name the redis address", err)
+	}
+}
+
+func TestCheckAllDeadline(t *testing.T) {
+	hung := silentListener(t)
+	cfg := &Config{
+		Redis: &redisutil.Config{Address: []string{hung}},
+		Kafka: &kafka.Config{Addr: []string{hung}},
+	}
+	start := time.Now()
+	results, err := CheckAll(context.Background(), cfg, WithCheckConcurrency(1), WithCheckDeadline(200*time.Millisecond))
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("CheckAll took %s past its deadline", elapsed)
+	}
+	var multi *errs.MultiError
+	if !errors.As(err, &multi) || multi.Len() != 2 {
+		t.Fatalf("err = %v, want both components reported", err)
+	}
+	for _, res := range results {
+		if !errors.Is(res.Err, context.DeadlineExceeded) {
+			t.Errorf("%s: %v, want deadline exceeded", res.Component, res.Err)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/fileutil"
+	"github.com/openimsdk/tools/utils/runtimeenv"
+)
+
+// DefaultReportPath is the file the check report is written to unless
+// ReportTarget.Path says otherwise.
+const DefaultReportPath = "/tmp/openim-check-report.json"
+
+// reportWebhookTimeout bounds the delivery of the report to the webhook.
+const reportWebhookTimeout = 5 * time.Second
+
+// ReportTarget says where WithCheckReport and WithWaitReport persist the
+// CheckReport of a run, so that the cause of a failed startup outlives the
+// pod of an init container.
+type ReportTarget struct {
+	// Path is the file the report is written to atomically, DefaultReportPath
+	// when empty.
+	Path string
+	// Webhook, when set, also receives the report as a JSON POST request.
+	Webhook string
+	// Config adds the summary of the configuration to the reports of
+	// WaitFor, and its secrets to those removed from them. CheckAll uses its
+	// own configuration.
+	Config *Config
+}
+
+// CheckReport is the persisted outcome of a CheckAll or WaitFor run. The
+// passwords, keys and tokens of the configuration are replaced by "xxxxx"
+// wherever they appear.
+type CheckReport struct {
+	Time        time.Time         `json:"time"`
+	Success     bool              `json:"success"`
+	Error       string            `json:"error,omitempty"`
+	Environment ReportEnvironment `json:"environment"`
+	// Components lists the addresses of every configured component.
+	Components map[string][]string `json:"components,omitempty"`
+	Attempts   []CheckAttempt      `json:"attempts"`
+}
+
+// ReportEnvironment describes where the run happened.
+type ReportEnvironment struct {
+	// Runtime is kubernetes, docker or source.
+	Runtime   string `json:"runtime"`
+	Hostname  string `json:"hostname,omitempty"`
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+// CheckAttempt is one execution of the checks.
+type CheckAttempt struct {
+	Time     time.Time `json:"time"`
+	Duration string    `json:"duration"`
+	Error    string    `json:"error,omitempty"`
+	// Results are the results of the components, for CheckAll.
+	Results []ReportResult `json:"results,omitempty"`
+}
+
+// ReportResult is the persisted form of a CheckResult.
+type ReportResult struct {
+	Component string            `json:"component"`
+	Addresses []string          `json:"addresses,omitempty"`
+	Latency   string            `json:"latency,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Warnings  []string          `json:"warnings,omitempty"`
+	Extra     map[string]string `json:"extra,omitempty"`
+}
+
+// reportRecorder builds the CheckReport of a run.
+type reportRecorder struct {
+	target  ReportTarget
+	secrets []string
+	report  CheckReport
+}
+
+func newReportRecorder(target ReportTarget) *reportRecorder {
+	r := &reportRecorder{target: target}
+	r.report.Environment = ReportEnvironment{
+		Runtime:   runtimeenv.PrintRuntimeEnvironment(),
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	r.report.Environment.Hostname, _ = os.Hostname()
+	if target.Config != nil {
+		r.secrets = target.Config.secrets()
+		r.report.Components = target.Config.summary()
+		for _, addrs := range r.report.Components {
+			for i, addr := range addrs {
+				addrs[i] = r.scrub(addr)
+			}
+		}
+	}
+	return r
+}
+
+// addAttempt records an attempt started at start.
+func (r *reportRecorder) addAttempt(start time.Time, err error, results []*CheckResult) {
+	attempt := CheckAttempt{Time: start, Duration: time.Since(start).String(), Error: r.errString(err)}
+	for _, res := range results {
+		out := ReportResult{Component: res.Component, Error: r.errString(res.Err)}
+		for _, addr := range res.Addresses {
+			out.Addresses = append(out.Addresses, r.scrub(addr))
+		}
+		if res.Latency > 0 {
+			out.Latency = res.Latency.String()
+		}
+		for _, w := range res.Warnings {
+			out.Warnings = append(out.Warnings, r.errString(w))
+		}
+		for k, v := range res.Extra {
+			if out.Extra == nil {
+				out.Extra = make(map[string]string, len(res.Extra))
+			}
+			out.Extra[k] = r.scrub(v)
+		}
+		attempt.Results = append(attempt.Results, out)
+	}
+	r.report.Attempts = append(r.report.Attempts, attempt)
+}
+
+// finish writes the report of a run that ended with err to the target.
+// Failures are logged, they never change the outcome of the run.
+func (r *reportRecorder) finish(ctx context.Context, err error) {
+	r.report.Time = time.Now()
+	r.report.Success = err == nil
+	r.report.Error = r.errString(err)
+	data, mErr := json.MarshalIndent(&r.report, "", "  ")
+	if mErr != nil {
+		log.ZWarn(ctx, "encode check report failed", mErr)
+		return
+	}
+	path := r.target.Path
+	if path == "" {
+		path = DefaultReportPath
+	}
+	if wErr := fileutil.WriteFileAtomic(path, append(data, '\n'), 0o600); wErr != nil {
+		log.ZWarn(ctx, "write check report failed", wErr, "path", path)
+	}
+	if r.target.Webhook != "" {
+		if wErr := postReport(ctx, r.target.Webhook, data); wErr != nil {
+			log.ZWarn(ctx, "send check report failed", wErr, "webhook", redactURL(r.target.Webhook))
+		}
+	}
+}
+
+func postReport(ctx context.Context, webhook string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reportWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(data))
+	if err != nil {
+		return errs.WrapMsg(err, "invalid webhook")
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errs.WrapMsg(err, "POST failed")
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errs.New("webhook rejected the report", "status", resp.StatusCode).Wrap()
+	}
+	return nil
+}
+
+func (r *reportRecorder) errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return r.scrub(errMessage(err))
+}
+
+func (r *reportRecorder) scrub(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "xxxxx")
+	}
+	return s
+}
+
+// secrets returns the passwords, keys and tokens of cfg, each also in the
+// escaped forms a URI built from it carries into error messages.
+func (cfg *Config) secrets() []string {
+	var secrets []string
+	add := func(values ...string) {
+		for _, v := range values {
+			if v == "" {
+				continue
+			}
+			userinfo := strings.TrimPrefix(url.UserPassword("", v).String(), ":")
+			for _, form := range []string{v, userinfo, url.QueryEscape(v), url.PathEscape(v)} {
+				if !slices.Contains(secrets, form) {
+					secrets = append(secrets, form)
+				}
+			}
+		}
+	}
+	addURL := func(raw string) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return
+		}
+		if u.User != nil {
+			p, _ := u.User.Password()
+			add(p)
+		}
+		for _, values := range u.Query() {
+			add(values...)
+		}
+	}
+	if cfg.Mongo != nil {
+		add(cfg.Mongo.Password)
+		addURL(cfg.Mongo.Uri)
+	}
+	if cfg.Redis != nil {
+		add(cfg.Redis.Password)
+	}
+	if cfg.Kafka != nil {
+		add(cfg.Kafka.Password, cfg.Kafka.TLS.ClientKeyPwd)
+	}
+	if cfg.Zookeeper != nil {
+		add(cfg.Zookeeper.Password)
+	}
+	if cfg.Nacos != nil {
+		add(cfg.Nacos.Password)
+	}
+	if cfg.Minio != nil {
+		add(cfg.Minio.SecretAccessKey, cfg.Minio.SessionToken)
+	}
+	if cfg.RabbitMQ != nil {
+		add(cfg.RabbitMQ.Password)
+		addURL(cfg.RabbitMQ.URI)
+	}
+	if cfg.Callbacks != nil {
+		for _, cb := range cfg.Callbacks.Callbacks {
+			addURL(cb.URL)
+		}
+	}
+	return secrets
+}
+
+// summary returns the addresses of the configured components, without
+// credentials.
+func (cfg *Config) summary() map[string][]string {
+	components := make(map[string][]string)
+	if cfg.Mongo != nil {
+		if cfg.Mongo.Uri != "" {
+			components["mongo"] = []string{maskURI(cfg.Mongo.Uri)}
+		} else {
+			components["mongo"] = cfg.Mongo.Address
+		}
+	}
+	if cfg.Redis != nil {
+		components["redis"] = cfg.Redis.Address
+	}
+	if cfg.Kafka != nil {
+		components["kafka"] = cfg.Kafka.Addr
+	}
+	if cfg.Zookeeper != nil {
+		components["zookeeper"] = cfg.Zookeeper.ZkServers
+	}
+	if cfg.Nacos != nil {
+		components["nacos"] = cfg.Nacos.ServerAddrs
+	}
+	if cfg.Minio != nil {
+		components["minio"] = []string{cfg.Minio.Endpoint}
+	}
+	if cfg.RabbitMQ != nil {
+		components["rabbitmq"] = []string{rabbitMQAddr(cfg.RabbitMQ)}
+	}
+	if cfg.Callbacks != nil {
+		var urls []string
+		for _, cb := range cfg.Callbacks.Callbacks {
+			urls = append(urls, redactURL(cb.URL))
+		}
+		components[NameCallbacks] = urls
+	}
+	return components
+}
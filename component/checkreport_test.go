@@ -0,0 +1,166 @@
+package component
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/db/mongoutil"
+	"github.com/openimsdk/tools/db/redisutil"
+	"github.com/openimsdk/tools/errs"
+)
+
+var reportSecrets = []string{"r3dis-s3cret", "cb-s3cret", "tok-s3cret"}
+
+// readReport returns the report written to path, failing if it holds any of
+// reportSecrets.
+func readReport(t *testing.T, path string) CheckReport {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range reportSecrets {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("report leaks %q:\n%s", secret, data)
+		}
+	}
+	var report CheckReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Environment.Runtime == "" || report.Environment.GoVersion == "" || report.Time.IsZero() {
+		t.Errorf("environment missing: %+v", report)
+	}
+	return report
+}
+
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	return addr
+}
+
+func TestCheckAllReport(t *testing.T) {
+	dir := t.TempDir()
+	var webhook []byte
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webhook, _ = io.ReadAll(r.Body)
+	}))
+	defer hook.Close()
+
+	t.Run("failing", func(t *testing.T) {
+		path := filepath.Join(dir, "failing.json")
+		cfg := &Config{
+			Redis: &redisutil.Config{Address: []string{closedAddr(t)}, Password: "r3dis-s3cret"},
+			Callbacks: &CallbackConfig{Callbacks: []Callback{
+				{Name: "beforeSend", URL: "http://user:cb-s3cret@" + closedAddr(t) + "/hook?token=tok-s3cret", Enable: true},
+			}},
+		}
+		_, err := CheckAll(context.Background(), cfg, WithCheckTimeout(5*time.Second),
+			WithCheckReport(ReportTarget{Path: path, Webhook: hook.URL}))
+		if err == nil {
+			t.Fatal("check succeeded")
+		}
+		report := readReport(t, path)
+		if report.Success || report.Error == "" || len(report.Attempts) != 1 {
+			t.Fatalf("report = %+v", report)
+		}
+		results := report.Attempts[0].Results
+		if len(results) != 2 || results[0].Component != "redis" || results[0].Error == "" || results[1].Component != NameCallbacks {
+			t.Errorf("results = %+v", results)
+		}
+		if addrs := report.Components[NameCallbacks]; len(addrs) != 1 || strings.Contains(addrs[0], "user") {
+			t.Errorf("callbacks summary = %v", addrs)
+		}
+		if len(report.Components["redis"]) != 1 {
+			t.Errorf("components = %v", report.Components)
+		}
+		var sent CheckReport
+		if err := json.Unmarshal(webhook, &sent); err != nil || sent.Success || sent.Error != report.Error {
+			t.Errorf("webhook received %s (%v)", webhook, err)
+		}
+	})
+
+	t.Run("passing", func(t *testing.T) {
+		path := filepath.Join(dir, "passing.json")
+		cfg := &Config{Callbacks: &CallbackConfig{Callbacks: []Callback{
+			{Name: "afterSend", URL: statusServer(t, http.StatusOK) + "/hook?token=tok-s3cret", Enable: true},
+		}}}
+		if _, err := CheckAll(context.Background(), cfg, WithCheckReport(ReportTarget{Path: path})); err != nil {
+			t.Fatal(err)
+		}
+		report := readReport(t, path)
+		if !report.Success || report.Error != "" || len(report.Attempts) != 1 || report.Attempts[0].Results[0].Error != "" {
+			t.Fatalf("report = %+v", report)
+		}
+	})
+}
+
+func TestWaitForReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	target := ReportTarget{Path: path, Config: &Config{Redis: &redisutil.Config{Address: []string{"redis:6379"}, Password: "r3dis-s3cret"}}}
+	attempts := 0
+	check := func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errs.New("AUTH r3dis-s3cret rejected").Wrap()
+		}
+		return nil
+	}
+	if err := WaitFor(context.Background(), time.Millisecond, check, WithWaitReport(target)); err != nil {
+		t.Fatal(err)
+	}
+	report := readReport(t, path)
+	if !report.Success || len(report.Attempts) != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	if e := report.Attempts[0].Error; !strings.Contains(e, "AUTH xxxxx rejected") {
+		t.Errorf("attempt error = %q", e)
+	}
+	if report.Attempts[2].Error != "" || report.Attempts[1].Time.Before(report.Attempts[0].Time) {
+		t.Errorf("attempts = %+v", report.Attempts)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := WaitFor(ctx, 5*time.Millisecond, func(context.Context) error { return errors.New("r3dis-s3cret: connection refused") }, WithWaitReport(target))
+	if err == nil {
+		t.Fatal("WaitFor succeeded")
+	}
+	report = readReport(t, path)
+	if report.Success || report.Error == "" || len(report.Attempts) < 2 || report.Attempts[1].Error != "xxxxx: connection refused" {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestReportScrubsEscapedSecrets(t *testing.T) {
+	cfg := &Config{Mongo: &mongoutil.Config{Username: "openim", Password: "s3cr@t w/rd"}}
+	r := &reportRecorder{secrets: cfg.secrets()}
+	uri := (&url.URL{Scheme: "mongodb", User: url.UserPassword("openim", "s3cr@t w/rd"), Host: "10.0.0.1:27017"}).String()
+	for _, msg := range []string{
+		"auth failed: s3cr@t w/rd",
+		"dial " + uri + ": connection refused",
+		"query password=" + url.QueryEscape("s3cr@t w/rd"),
+		"path /" + url.PathEscape("s3cr@t w/rd"),
+	} {
+		if got := r.scrub(msg); strings.Contains(got, "s3cr") {
+			t.Errorf("scrub(%q) = %q", msg, got)
+		}
+	}
+}
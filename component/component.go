@@ -0,0 +1,306 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package component verifies at startup that the external components a
+// service depends on are reachable and correctly configured.
+package component
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openimsdk/tools/db/mongoutil"
+	"github.com/openimsdk/tools/db/redisutil"
+	"github.com/openimsdk/tools/discovery/zookeeper"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/s3/minio"
+	"github.com/openimsdk/tools/utils/network"
+)
+
+// runCheck runs check and returns as soon as ctx is done, with the context
+// error wrapped with the addresses of the component. Clients without context
+// support, such as sarama and go-zookeeper, keep running in the background
+// until their own timeout.
+func runCheck(ctx context.Context, component string, addrs []string, check func(ctx context.Context) error) *CheckResult {
+	res := &CheckResult{Component: component, Addresses: addrs}
+	start := time.Now()
+	defer func() {
+		res.Latency = time.Since(start)
+	}()
+	if err := ctx.Err(); err != nil {
+		res.Err = errs.WrapMsg(err, component+" check cancelled", "addr", addrs)
+		return res
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	select {
+	case err := <-done:
+		if err != nil && ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+			err = errs.WrapMsg(ctx.Err(), component+" check cancelled", "addr", addrs, "err", errs.Unwrap(err).Error())
+		}
+		res.Err = err
+	case <-ctx.Done():
+		res.Err = errs.WrapMsg(ctx.Err(), component+" check cancelled", "addr", addrs)
+	}
+	return res
+}
+
+// normalizedAddrs returns addrs normalized for display, or addrs unchanged if
+// they are invalid; the check reports the error.
+func normalizedAddrs(addrs []string) []string {
+	if normalized, err := network.NormalizeAddrs(addrs); err == nil {
+		return normalized
+	}
+	return addrs
+}
+
+// tcpPrecheckTimeout bounds the dial of each address by tcpPrecheck.
+var tcpPrecheckTimeout = 3 * time.Second
+
+// tcpPrecheck dials the addresses of a component before its client is
+// created, so that an unreachable component fails fast with the address at
+// fault instead of a driver timeout. Host names are dialed on both their A and
+// AAAA records, see network.ProbeTCP. One reachable address is enough, as the
+// clients discover the others from it.
+func tcpPrecheck(ctx context.Context, addrs []string) error {
+	normalized, err := network.NormalizeAddrs(addrs)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, addr := range normalized {
+		err := network.ProbeTCP(ctx, addr, tcpPrecheckTimeout)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return errs.ErrComponentStart.WrapMsg("no address accepts connections", "addr", normalized, "err", errs.Unwrap(firstErr).Error())
+}
+
+// maskURI returns uri with its password replaced.
+func maskURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.User == nil {
+		return uri
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// CheckMongo verifies that MongoDB accepts connections and answers a ping,
+// and that a replica set has a primary. The address reported is the
+// connection URI with the password masked; Extra holds the role and version
+// of the node and, for a replica set, its name, hosts and primary.
+func CheckMongo(ctx context.Context, cfg *mongoutil.Config) *CheckResult {
+	conf := *cfg
+	if err := conf.ValidateAndSetDefaults(); err != nil {
+		return &CheckResult{Component: "mongo", Addresses: cfg.Address, Err: err}
+	}
+	// The check may outlive runCheck on cancellation, hence the channel.
+	topologies := make(chan *mongoutil.Topology, 1)
+	res := runCheck(ctx, "mongo", []string{maskURI(conf.Uri)}, func(ctx context.Context) error {
+		topology, err := mongoutil.CheckTopology(ctx, &conf)
+		topologies <- topology
+		return err
+	})
+	res.Extra = map[string]string{"database": conf.Database}
+	var topology *mongoutil.Topology
+	select {
+	case topology = <-topologies:
+	default:
+	}
+	if topology != nil {
+		res.Extra["role"] = topology.Role
+		if topology.Version != "" {
+			res.Extra["version"] = topology.Version
+		}
+		if topology.SetName != "" {
+			res.Extra["replicaSet"] = topology.SetName
+			res.Extra["hosts"] = strings.Join(topology.Hosts, ",")
+			res.Extra["primary"] = topology.Primary
+		}
+	}
+	return res
+}
+
+// DefaultRedisLatencyThreshold is the PING round trip above which CheckRedis
+// reports a warning.
+const DefaultRedisLatencyThreshold = 100 * time.Millisecond
+
+type redisOptions struct {
+	latencyThreshold time.Duration
+	checkOpts        []redisutil.CheckOption
+}
+
+type RedisOption func(o *redisOptions)
+
+// WithRedisLatencyThreshold replaces DefaultRedisLatencyThreshold.
+func WithRedisLatencyThreshold(threshold time.Duration) RedisOption {
+	return func(o *redisOptions) {
+		o.latencyThreshold = threshold
+	}
+}
+
+// WithRedisWriteProbe makes CheckRedis check that the server accepts writes,
+// with a key named prefix followed by a random suffix, see
+// redisutil.WithWriteProbe. Sentinel mode always reaches the master and does
+// not probe.
+func WithRedisWriteProbe(prefix string) RedisOption {
+	return func(o *redisOptions) {
+		o.checkOpts = append(o.checkOpts, redisutil.WithWriteProbe(prefix))
+	}
+}
+
+// CheckRedis verifies that Redis accepts connections and answers a ping. In
+// sentinel mode, the addresses are the sentinels, and Extra holds the master
+// they resolved. Otherwise Extra holds the ping latency and the version,
+// role, connected clients and maxmemory policy of the server, and a warning
+// is reported when the latency is above the threshold or when Redis refuses
+// writes once out of memory without a memory limit (noeviction with a
+// maxmemory of 0).
+func CheckRedis(ctx context.Context, conf *redisutil.Config, opts ...RedisOption) *CheckResult {
+	o := redisOptions{latencyThreshold: DefaultRedisLatencyThreshold}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if conf.SentinelMasterName == "" {
+		return checkRedisServer(ctx, conf, o)
+	}
+	masters := make(chan string, 1)
+	res := runCheck(ctx, "redis", normalizedAddrs(conf.Address), func(ctx context.Context) error {
+		if err := tcpPrecheck(ctx, conf.Address); err != nil {
+			return err
+		}
+		master, err := redisutil.CheckSentinel(ctx, conf)
+		masters <- master
+		return err
+	})
+	res.Extra = map[string]string{"masterName": conf.SentinelMasterName}
+	select {
+	case master := <-masters:
+		if master != "" {
+			res.Extra["master"] = master
+		}
+	default:
+	}
+	return res
+}
+
+func checkRedisServer(ctx context.Context, conf *redisutil.Config, o redisOptions) *CheckResult {
+	infos := make(chan *redisutil.ServerInfo, 1)
+	res := runCheck(ctx, "redis", normalizedAddrs(conf.Address), func(ctx context.Context) error {
+		if err := tcpPrecheck(ctx, conf.Address); err != nil {
+			return err
+		}
+		info, err := redisutil.CheckServer(ctx, conf, o.checkOpts...)
+		infos <- info
+		return err
+	})
+	var info *redisutil.ServerInfo
+	select {
+	case info = <-infos:
+	default:
+	}
+	if info == nil {
+		return res
+	}
+	res.Extra = map[string]string{
+		"pingLatency":      info.Latency.String(),
+		"version":          info.Version,
+		"role":             info.Role,
+		"connectedClients": strconv.Itoa(info.ConnectedClients),
+		"maxmemoryPolicy":  info.MaxmemoryPolicy,
+	}
+	if o.latencyThreshold > 0 && info.Latency > o.latencyThreshold {
+		res.Warnings = append(res.Warnings, errs.New("Redis ping latency above threshold",
+			"latency", info.Latency, "threshold", o.latencyThreshold).Wrap())
+	}
+	if info.MaxmemoryPolicy == "noeviction" && info.Maxmemory == 0 {
+		res.Warnings = append(res.Warnings, errs.New("Redis maxmemory is 0 with the noeviction policy, writes fail once the host memory is exhausted",
+			"maxmemoryPolicy", info.MaxmemoryPolicy, "maxmemory", info.Maxmemory).Wrap())
+	}
+	return res
+}
+
+// CheckZookeeper verifies that ZooKeeper accepts the session, that the root
+// node of the scheme exists, creating it if missing, and that it is writable,
+// see zookeeper.Check.
+func CheckZookeeper(ctx context.Context, conf *zookeeper.Config) *CheckResult {
+	var opts []zookeeper.ZkOption
+	if conf.Username != "" || conf.Password != "" {
+		opts = append(opts, zookeeper.WithUserNameAndPassword(conf.Username, conf.Password))
+	}
+	if conf.Timeout > 0 {
+		opts = append(opts, zookeeper.WithTimeout(int(conf.Timeout.Seconds())))
+	}
+	opts = append(opts, zookeeper.WithTLS(conf.TLS))
+	res := runCheck(ctx, "zookeeper", normalizedAddrs(conf.ZkServers), func(ctx context.Context) error {
+		if err := tcpPrecheck(ctx, conf.ZkServers); err != nil {
+			return err
+		}
+		return zookeeper.Check(ctx, conf.ZkServers, conf.Scheme, opts...)
+	})
+	res.Extra = map[string]string{"scheme": conf.Scheme}
+	return res
+}
+
+type minioOptions struct {
+	noCreateBucket bool
+}
+
+type MinioOption func(o *minioOptions)
+
+// WithMinioNoCreateBucket makes CheckMinio fail when the bucket is missing,
+// instead of creating it in the region of the configuration.
+func WithMinioNoCreateBucket() MinioOption {
+	return func(o *minioOptions) {
+		o.noCreateBucket = true
+	}
+}
+
+// CheckMinio verifies that MinIO accepts the credentials and that the bucket
+// exists, creating it if missing, see WithMinioNoCreateBucket. Extra holds
+// the bucket, and whether the check created it.
+func CheckMinio(ctx context.Context, conf *minio.Config, opts ...MinioOption) *CheckResult {
+	var o minioOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	created := make(chan bool, 1)
+	res := runCheck(ctx, "minio", []string{conf.Endpoint}, func(ctx context.Context) error {
+		ok, err := minio.CheckBucket(ctx, conf, !o.noCreateBucket)
+		created <- ok
+		return err
+	})
+	res.Extra = map[string]string{"bucket": conf.Bucket}
+	select {
+	case ok := <-created:
+		if ok {
+			res.Extra["created"] = "true"
+		}
+	default:
+	}
+	return res
+}
@@ -0,0 +1,171 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/openimsdk/tools/db/mongoutil"
+	"github.com/openimsdk/tools/db/redisutil"
+	"github.com/openimsdk/tools/discovery/zookeeper"
+	"github.com/openimsdk/tools/mq/kafka"
+)
+
+// silentListener accepts connections and never answers, like a hung server.
+func silentListener(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestChecksHonorDeadline(t *testing.T) {
+	addr := silentListener(t)
+	checks := map[string]func(ctx context.Context) error{
+		"kafka": func(ctx context.Context) error { return CheckKafka(ctx, &kafka.Config{Addr: []string{addr}}).Err },
+		"redis": func(ctx context.Context) error {
+			return CheckRedis(ctx, &redisutil.Config{Address: []string{addr}}).Err
+		},
+		"zookeeper": func(ctx context.Context) error {
+			return CheckZookeeper(ctx, &zookeeper.Config{ZkServers: []string{addr}, Scheme: "openim"}).Err
+		},
+	}
+	for name, check := range checks {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err := check(ctx)
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Fatalf("check returned after %s", elapsed)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("err = %v, want deadline exceeded", err)
+			}
+			if !strings.Contains(err.Error(), addr) {
+				t.Errorf("err %q does not name %s", err, addr)
+			}
+		})
+	}
+}
+
+func TestChecksTCPPrecheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	_ = l.Close()
+	checks := map[string]func(ctx context.Context) error{
+		"kafka": func(ctx context.Context) error { return CheckKafka(ctx, &kafka.Config{Addr: []string{closed}}).Err },
+		"redis": func(ctx context.Context) error {
+			return CheckRedis(ctx, &redisutil.Config{Address: []string{closed}}).Err
+		},
+		"sentinel": func(ctx context.Context) error {
+			return CheckRedis(ctx, &redisutil.Config{Address: []string{closed}, SentinelMasterName: "mymaster"}).Err
+		},
+		"zookeeper": func(ctx context.Context) error {
+			return CheckZookeeper(ctx, &zookeeper.Config{ZkServers: []string{closed}, Scheme: "openim"}).Err
+		},
+	}
+	for name, check := range checks {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err := check(ctx)
+			if err == nil || !strings.Contains(err.Error(), "no address accepts connections") || !strings.Contains(err.Error(), closed) {
+				t.Fatalf("err = %v, want the tcp precheck to name %s", err, closed)
+			}
+		})
+	}
+	if err := tcpPrecheck(context.Background(), []string{closed, silentListener(t)}); err != nil {
+		t.Fatalf("tcpPrecheck = %v, want nil with one address up", err)
+	}
+}
+
+func TestCheckCancelledBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := CheckRedis(ctx, &redisutil.Config{Address: []string{"127.0.0.1:1"}}).Err
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want canceled", err)
+	}
+}
+
+func TestCheckResult(t *testing.T) {
+	addr := newKafkaBroker(t).Addr()
+	res := CheckKafka(context.Background(), &kafka.Config{Addr: []string{addr}})
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if res.Component != "kafka" || res.Latency <= 0 || res.String() != "the addr is:"+addr {
+		t.Fatalf("result = %+v, %q", res, res)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	res = CheckMongo(ctx, &mongoutil.Config{Address: []string{"10.0.0.1:27017", "10.0.0.2:27017"},
+		Database: "openim", Username: "root", Password: "p@ss", AuthSource: "admin", ReplicaSet: "rs0"})
+	if got := res.String(); strings.Contains(got, "p@ss") || !strings.Contains(got, "10.0.0.2:27017") || !strings.Contains(got, "xxxxx") {
+		t.Errorf("mongo address %q", got)
+	}
+	if got := res.String(); !strings.HasSuffix(got, "?authSource=admin&maxPoolSize=100&replicaSet=rs0") {
+		t.Errorf("mongo address %q does not show the options", got)
+	}
+}
+
+// fakeRedis starts a server answering PING and INFO with info.
+func fakeRedis(t *testing.T, info string) string {
+	t.Helper()
+	srv, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	_ = srv.Register("PING", func(c *server.Peer, cmd string, args []string) { c.WriteInline("PONG") })
+	_ = srv.Register("INFO", func(c *server.Peer, cmd string, args []string) { c.WriteBulk(info) })
+	return srv.Addr().String()
+}
+
+func TestCheckRedisInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	const info = "redis_version:7.2.4\r\nconnected_clients:12\r\nmaxmemory:0\r\nmaxmemory_policy:%s\r\nrole:master\r\n"
+
+	res := CheckRedis(ctx, &redisutil.Config{Address: []string{fakeRedis(t, fmt.Sprintf(info, "allkeys-lru"))}}, WithRedisLatencyThreshold(time.Minute))
+	if res.Err != nil || len(res.Warnings) != 0 {
+		t.Fatalf("err = %v, warnings = %v", res.Err, res.Warnings)
+	}
+	for k, v := range map[string]string{"version": "7.2.4", "role": "master", "connectedClients": "12", "maxmemoryPolicy": "allkeys-lru"} {
+		if res.Extra[k] != v {
+			t.Errorf("Extra[%s] = %q, want %q", k, res.Extra[k], v)
+		}
+	}
+	if _, err := time.ParseDuration(res.Extra["pingLatency"]); err != nil {
+		t.Errorf("pingLatency = %q", res.Extra["pingLatency"])
+	}
+
+	res = CheckRedis(ctx, &redisutil.Config{Address: []string{fakeRedis(t, fmt.Sprintf(info, "noeviction"))}}, WithRedisLatencyThreshold(time.Nanosecond))
+	if res.Err != nil || len(res.Warnings) != 2 {
+		t.Fatalf("err = %v, warnings = %v, want latency and noeviction", res.Err, res.Warnings)
+	}
+	if !strings.Contains(res.Warnings[0].Error(), "latency") || !strings.Contains(res.Warnings[1].Error(), "noeviction") {
+		t.Errorf("warnings = %v", res.Warnings)
+	}
+}
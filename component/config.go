@@ -0,0 +1,66 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"time"
+
+	"github.com/openimsdk/tools/db/mongoutil"
+	"github.com/openimsdk/tools/db/redisutil"
+	"github.com/openimsdk/tools/discovery/nacos"
+	"github.com/openimsdk/tools/discovery/zookeeper"
+	"github.com/openimsdk/tools/mq/kafka"
+	"github.com/openimsdk/tools/mq/rabbitmq"
+	"github.com/openimsdk/tools/s3/minio"
+)
+
+// Config holds the configuration of every component a service depends on.
+// Components left nil are not used by the service and are not checked.
+type Config struct {
+	Mongo     *mongoutil.Config `yaml:"mongo"`
+	Redis     *redisutil.Config `yaml:"redis"`
+	Kafka     *kafka.Config     `yaml:"kafka"`
+	Zookeeper *zookeeper.Config `yaml:"zookeeper"`
+	Nacos     *nacos.Config     `yaml:"nacos"`
+	Minio     *minio.Config     `yaml:"minio"`
+	RabbitMQ  *rabbitmq.Config  `yaml:"rabbitmq"`
+	Callbacks *CallbackConfig   `yaml:"callbacks"`
+
+	// KafkaTopics and KafkaGroups describe the topics the deployment uses and
+	// the consumer groups of each service. See kafka.ValidateTopology.
+	KafkaTopics []string                 `yaml:"kafkaTopics"`
+	KafkaGroups []kafka.GroupDeclaration `yaml:"kafkaGroups"`
+	// KafkaCreateTopics enables WithKafkaCreateTopics for KafkaTopics, with
+	// KafkaTopicSpec.
+	KafkaCreateTopics bool            `yaml:"kafkaCreateTopics"`
+	KafkaTopicSpec    kafka.TopicSpec `yaml:"kafkaTopicSpec"`
+	// KafkaTopicRequirements enables WithKafkaTopicRequirements for
+	// KafkaTopics when set, and KafkaStrictTopics WithKafkaStrictTopics.
+	KafkaTopicRequirements kafka.TopicRequirements `yaml:"kafkaTopicRequirements"`
+	KafkaStrictTopics      bool                    `yaml:"kafkaStrictTopics"`
+	// KafkaProbeReadWrite enables WithKafkaReadWriteProbe, with
+	// KafkaProbeTopic.
+	KafkaProbeReadWrite bool   `yaml:"kafkaProbeReadWrite"`
+	KafkaProbeTopic     string `yaml:"kafkaProbeTopic"`
+
+	// RedisLatencyThreshold replaces DefaultRedisLatencyThreshold when set.
+	RedisLatencyThreshold time.Duration `yaml:"redisLatencyThreshold"`
+	// RedisProbeWrite enables WithRedisWriteProbe, with RedisProbePrefix.
+	RedisProbeWrite  bool   `yaml:"redisProbeWrite"`
+	RedisProbePrefix string `yaml:"redisProbePrefix"`
+
+	// MinioNoCreateBucket enables WithMinioNoCreateBucket.
+	MinioNoCreateBucket bool `yaml:"minioNoCreateBucket"`
+}
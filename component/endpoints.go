@@ -0,0 +1,225 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/network"
+)
+
+// Resolver resolves host names to addresses. net.DefaultResolver implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// EndpointPolicy decides the status of a component from the status of its
+// endpoints.
+type EndpointPolicy int
+
+const (
+	// RequireAll fails the component when any endpoint is down.
+	RequireAll EndpointPolicy = iota
+	// RequireQuorum fails the component unless more than half of the
+	// endpoints are up.
+	RequireQuorum
+	// RequireAny fails the component only when every endpoint is down.
+	RequireAny
+)
+
+func (p EndpointPolicy) String() string {
+	switch p {
+	case RequireQuorum:
+		return "quorum"
+	case RequireAny:
+		return "any"
+	default:
+		return "all"
+	}
+}
+
+func (p EndpointPolicy) satisfied(up, total int) bool {
+	switch p {
+	case RequireQuorum:
+		return up > total/2
+	case RequireAny:
+		return up > 0
+	default:
+		return up == total
+	}
+}
+
+// EndpointOK is the Extra value of an endpoint that passed its probe.
+const EndpointOK = "ok"
+
+// CheckResult is the outcome of checking one component.
+type CheckResult struct {
+	Component string
+	Addresses []string
+	Latency   time.Duration
+	Err       error
+	// Warnings are failures that do not fail the component, such as the
+	// callbacks of a CallbackConfig with WarnOnly set.
+	Warnings []error
+	// Extra holds check specific details. CheckEndpoints sets one entry per
+	// probed endpoint and per address that failed to resolve.
+	Extra map[string]string
+}
+
+// String returns the addresses in the format the check functions used to
+// return, "the addr is:" followed by the comma separated addresses.
+func (r *CheckResult) String() string {
+	return "the addr is:" + strings.Join(r.Addresses, ",")
+}
+
+type endpointOptions struct {
+	resolver Resolver
+	policy   EndpointPolicy
+	timeout  time.Duration
+}
+
+type EndpointOption func(o *endpointOptions)
+
+// WithResolver replaces net.DefaultResolver.
+func WithResolver(resolver Resolver) EndpointOption {
+	return func(o *endpointOptions) {
+		o.resolver = resolver
+	}
+}
+
+// WithEndpointPolicy sets the policy, RequireAll by default.
+func WithEndpointPolicy(policy EndpointPolicy) EndpointOption {
+	return func(o *endpointOptions) {
+		o.policy = policy
+	}
+}
+
+// WithProbeTimeout bounds each probe, 5 seconds by default.
+func WithProbeTimeout(timeout time.Duration) EndpointOption {
+	return func(o *endpointOptions) {
+		o.timeout = timeout
+	}
+}
+
+// ExpandAddresses resolves the host of each address to all its A and AAAA
+// records, so a name behind a headless service yields every instance. IP
+// addresses are kept as they are. Addresses that fail to resolve are
+// returned in failed and do not prevent the others from being expanded.
+func ExpandAddresses(ctx context.Context, resolver Resolver, addrs []string) (endpoints []string, failed map[string]error, err error) {
+	addrs, err = network.NormalizeAddrs(addrs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	seen := make(map[string]struct{})
+	add := func(endpoint string) {
+		if _, ok := seen[endpoint]; !ok {
+			seen[endpoint] = struct{}{}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	for _, addr := range addrs {
+		host, port, _ := net.SplitHostPort(addr)
+		if net.ParseIP(host) != nil {
+			add(addr)
+			continue
+		}
+		ips, err := resolver.LookupHost(ctx, host)
+		if err == nil && len(ips) == 0 {
+			err = errs.New("no address found").Wrap()
+		}
+		if err != nil {
+			if failed == nil {
+				failed = make(map[string]error)
+			}
+			failed[addr] = errs.WrapMsg(err, "resolve failed", "host", host)
+			continue
+		}
+		for _, ip := range ips {
+			add(net.JoinHostPort(ip, port))
+		}
+	}
+	return endpoints, failed, nil
+}
+
+// CheckEndpoints expands addrs with ExpandAddresses and runs probe against
+// every endpoint concurrently. The status of each endpoint is reported in
+// Extra; the component fails with errs.ErrComponentStart when the policy is
+// not met, an address that failed to resolve counting as one endpoint down.
+func CheckEndpoints(ctx context.Context, component string, addrs []string, probe func(ctx context.Context, endpoint string) error, opts ...EndpointOption) *CheckResult {
+	o := endpointOptions{timeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	res := &CheckResult{Component: component, Extra: make(map[string]string)}
+	endpoints, failed, err := ExpandAddresses(ctx, o.resolver, addrs)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Addresses = endpoints
+	for addr, err := range failed {
+		res.Extra[addr] = errMessage(err)
+	}
+
+	results := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, o.timeout)
+			defer cancel()
+			results[i] = probe(ctx, endpoint)
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	up := 0
+	var down []string
+	for i, endpoint := range endpoints {
+		if results[i] == nil {
+			up++
+			res.Extra[endpoint] = EndpointOK
+			continue
+		}
+		res.Extra[endpoint] = errMessage(results[i])
+		down = append(down, endpoint)
+	}
+	for addr := range failed {
+		down = append(down, addr)
+	}
+	total := len(endpoints) + len(failed)
+	if !o.policy.satisfied(up, total) {
+		sort.Strings(down)
+		res.Err = errs.ErrComponentStart.WrapMsg(fmt.Sprintf("%s: %d of %d endpoints up", component, up, total),
+			"policy", o.policy.String(), "down", strings.Join(down, ","))
+	}
+	return res
+}
+
+// ProbeTCP is the default endpoint probe, see network.ProbeTCP.
+func ProbeTCP(ctx context.Context, endpoint string) error {
+	return network.ProbeTCP(ctx, endpoint, 0)
+}
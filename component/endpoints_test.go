@@ -0,0 +1,104 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mq/kafka"
+)
+
+type stubResolver map[string][]string
+
+func (r stubResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+var headless = stubResolver{
+	"kafka.openim.svc": {"10.0.0.1", "10.0.0.2", "fd00::3"},
+	"single.svc":       {"10.0.0.1"},
+}
+
+func TestExpandAddresses(t *testing.T) {
+	endpoints, failed, err := ExpandAddresses(context.Background(), headless,
+		[]string{"kafka.openim.svc:9092,10.0.0.9:9092", "single.svc:9092", "missing.svc:9092"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1:9092", "10.0.0.2:9092", "[fd00::3]:9092", "10.0.0.9:9092"}
+	if strings.Join(endpoints, " ") != strings.Join(want, " ") {
+		t.Errorf("endpoints = %v, want %v", endpoints, want)
+	}
+	if len(failed) != 1 || failed["missing.svc:9092"] == nil {
+		t.Errorf("failed = %v", failed)
+	}
+	if _, _, err := ExpandAddresses(context.Background(), headless, []string{"fd00::1:9092"}); err == nil {
+		t.Error("invalid address accepted")
+	}
+}
+
+func TestCheckEndpointsPolicies(t *testing.T) {
+	probe := func(_ context.Context, endpoint string) error {
+		if endpoint == "10.0.0.2:9092" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	for policy, wantErr := range map[EndpointPolicy]bool{RequireAll: true, RequireQuorum: false, RequireAny: false} {
+		res := CheckEndpoints(context.Background(), "kafka", []string{"kafka.openim.svc:9092"}, probe,
+			WithResolver(headless), WithEndpointPolicy(policy))
+		if (res.Err != nil) != wantErr {
+			t.Errorf("%s: err = %v, want error %v", policy, res.Err, wantErr)
+		}
+		if res.Extra["10.0.0.1:9092"] != EndpointOK || res.Extra["[fd00::3]:9092"] != EndpointOK ||
+			!strings.Contains(res.Extra["10.0.0.2:9092"], "connection refused") {
+			t.Errorf("%s: extra = %v", policy, res.Extra)
+		}
+	}
+
+	// A name that does not resolve counts as one endpoint down and does not
+	// prevent the others from being probed.
+	res := CheckEndpoints(context.Background(), "redis", []string{"missing.svc:6379", "single.svc:6379"},
+		func(context.Context, string) error { return nil },
+		WithResolver(headless), WithEndpointPolicy(RequireQuorum))
+	if res.Err == nil || !errs.ErrComponentStart.Is(res.Err) {
+		t.Fatalf("err = %v, want ErrComponentStart", res.Err)
+	}
+	if res.Extra["10.0.0.1:6379"] != EndpointOK || !strings.Contains(res.Extra["missing.svc:6379"], "resolve failed") {
+		t.Errorf("extra = %v", res.Extra)
+	}
+	if !strings.Contains(res.Err.Error(), "1 of 2 endpoints up") {
+		t.Errorf("err = %v", res.Err)
+	}
+}
+
+func TestCheckKafkaExpandAddresses(t *testing.T) {
+	broker := newKafkaBroker(t)
+	_, port, _ := net.SplitHostPort(broker.Addr())
+	// 127.0.0.2 has no broker: the probe of the dead endpoint fails while the
+	// live one passes.
+	resolver := stubResolver{"kafka.openim.svc": {"127.0.0.1", "127.0.0.2"}}
+	res := CheckEndpoints(context.Background(), "kafka", []string{"kafka.openim.svc:" + port},
+		func(ctx context.Context, endpoint string) error {
+			return kafka.ProbeBroker(ctx, &kafka.Config{}, endpoint)
+		}, WithResolver(resolver), WithEndpointPolicy(RequireAny))
+	if res.Err != nil {
+		t.Fatalf("RequireAny: %v", res.Err)
+	}
+	if res.Extra["127.0.0.1:"+port] != EndpointOK || res.Extra["127.0.0.2:"+port] == EndpointOK {
+		t.Fatalf("extra = %v", res.Extra)
+	}
+
+	err := CheckKafka(context.Background(), &kafka.Config{Addr: []string{"kafka.openim.svc:" + port}},
+		WithKafkaExpandAddresses(WithResolver(resolver))).Err
+	if err == nil || !strings.Contains(err.Error(), "127.0.0.2:"+port) {
+		t.Fatalf("err = %v, want the dead broker reported", err)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mq/kafka"
+)
+
+type kafkaOptions struct {
+	extended     bool
+	topics       []string
+	declarations []kafka.GroupDeclaration
+	expand       bool
+	endpointOpts []EndpointOption
+	create       []string
+	topicSpec    kafka.TopicSpec
+	layoutTopics []string
+	requirements kafka.TopicRequirements
+	strict       bool
+	probe        bool
+	probeTopic   string
+}
+
+type KafkaOption func(o *kafkaOptions)
+
+// WithKafkaTopology enables the extended check, which validates the consumer
+// groups declared by each service against the configured topics. See
+// kafka.ValidateTopology.
+func WithKafkaTopology(topics []string, declarations []kafka.GroupDeclaration) KafkaOption {
+	return func(o *kafkaOptions) {
+		o.extended = true
+		o.topics = topics
+		o.declarations = declarations
+	}
+}
+
+// WithKafkaExpandAddresses resolves the configured addresses to every broker
+// behind them and probes each one, see CheckEndpoints. The check fails when
+// the endpoint policy is not met.
+func WithKafkaExpandAddresses(opts ...EndpointOption) KafkaOption {
+	return func(o *kafkaOptions) {
+		o.expand = true
+		o.endpointOpts = opts
+	}
+}
+
+// WithKafkaCreateTopics creates the topics missing on the cluster with spec
+// and fails the check when they still cannot be found, see
+// kafka.EnsureTopics. Extra holds the created topics under "createdTopics".
+func WithKafkaCreateTopics(topics []string, spec kafka.TopicSpec) KafkaOption {
+	return func(o *kafkaOptions) {
+		o.create = topics
+		o.topicSpec = spec
+	}
+}
+
+// WithKafkaTopicRequirements checks the partition count and replication
+// factor of topics against req and the min.insync.replicas of each topic, see
+// kafka.TopicLayout.Problems. Shortfalls are reported as warnings, or fail
+// the check WithKafkaStrictTopics. Extra holds the layout of each topic under
+// "topic:" followed by its name.
+func WithKafkaTopicRequirements(topics []string, req kafka.TopicRequirements) KafkaOption {
+	return func(o *kafkaOptions) {
+		o.layoutTopics = topics
+		o.requirements = req
+	}
+}
+
+// WithKafkaStrictTopics fails the check on the shortfalls found
+// WithKafkaTopicRequirements.
+func WithKafkaStrictTopics() KafkaOption {
+	return func(o *kafkaOptions) {
+		o.strict = true
+	}
+}
+
+// WithKafkaReadWriteProbe produces a message to topic and consumes it back,
+// see kafka.ProbeReadWrite, kafka.DefaultProbeTopic when topic is empty. The
+// message stays in the topic, so the probe is not enabled by default.
+func WithKafkaReadWriteProbe(topic string) KafkaOption {
+	return func(o *kafkaOptions) {
+		o.probe = true
+		o.probeTopic = topic
+	}
+}
+
+// CheckKafka verifies that every broker is reachable and not older than the
+// minimum version, see kafka.CheckMinVersion, and, in extended mode, that the
+// declared topology is consistent. Extra holds the inferred release of each
+// broker under "version:" followed by its address.
+func CheckKafka(ctx context.Context, conf *kafka.Config, opts ...KafkaOption) *CheckResult {
+	var o kafkaOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	start := time.Now()
+	var endpoints *CheckResult
+	if o.expand {
+		probe := func(ctx context.Context, endpoint string) error {
+			return kafka.ProbeBroker(ctx, conf, endpoint)
+		}
+		if endpoints = CheckEndpoints(ctx, "kafka", conf.Addr, probe, o.endpointOpts...); endpoints.Err != nil {
+			endpoints.Latency = time.Since(start)
+			return endpoints
+		}
+	}
+	// The check may outlive runCheck on cancellation, hence the channel.
+	versions := make(chan map[string]string, 1)
+	created := make(chan []string, 1)
+	layouts := make(chan []kafka.TopicLayout, 1)
+	res := runCheck(ctx, "kafka", normalizedAddrs(conf.Addr), func(ctx context.Context) error {
+		if err := tcpPrecheck(ctx, conf.Addr); err != nil {
+			return err
+		}
+		if err := kafka.CheckHealth(ctx, conf); err != nil {
+			return err
+		}
+		brokerVersions, err := kafka.CheckMinVersion(ctx, conf)
+		versions <- brokerVersions
+		if err != nil {
+			return err
+		}
+		if len(o.create) > 0 {
+			topics, err := kafka.EnsureTopics(ctx, conf, o.create, o.topicSpec)
+			created <- topics
+			if err != nil {
+				return err
+			}
+		}
+		if len(o.layoutTopics) > 0 {
+			described, err := kafka.DescribeTopicLayouts(ctx, conf, o.layoutTopics)
+			if err != nil {
+				return err
+			}
+			layouts <- described
+			if problems := layoutProblems(described, o.requirements); o.strict && len(problems) > 0 {
+				return &errs.MultiError{Errors: problems}
+			}
+		}
+		if o.probe {
+			if err := kafka.ProbeReadWrite(ctx, conf, o.probeTopic); err != nil {
+				return err
+			}
+		}
+		if !o.extended {
+			return nil
+		}
+		return kafka.ValidateTopology(ctx, conf, o.topics, o.declarations)
+	})
+	if endpoints != nil {
+		res.Addresses = endpoints.Addresses
+		res.Extra = endpoints.Extra
+	}
+	select {
+	case brokerVersions := <-versions:
+		for addr, v := range brokerVersions {
+			if res.Extra == nil {
+				res.Extra = make(map[string]string)
+			}
+			res.Extra["version:"+addr] = v
+		}
+	default:
+	}
+	select {
+	case topics := <-created:
+		if len(topics) > 0 {
+			if res.Extra == nil {
+				res.Extra = make(map[string]string)
+			}
+			res.Extra["createdTopics"] = strings.Join(topics, ",")
+		}
+	default:
+	}
+	select {
+	case described := <-layouts:
+		if res.Extra == nil {
+			res.Extra = make(map[string]string)
+		}
+		for _, layout := range described {
+			res.Extra["topic:"+layout.Topic] = layout.String()
+		}
+		if !o.strict {
+			res.Warnings = append(res.Warnings, layoutProblems(described, o.requirements)...)
+		}
+	default:
+	}
+	res.Latency = time.Since(start)
+	return res
+}
+
+// layoutProblems returns one error per topic of layouts falling short of req.
+func layoutProblems(layouts []kafka.TopicLayout, req kafka.TopicRequirements) []error {
+	var problems []error
+	for _, layout := range layouts {
+		if p := layout.Problems(req); len(p) > 0 {
+			problems = append(problems, errs.New("Kafka topic layout below requirements", "topic", layout.Topic,
+				"layout", layout.String(), "problems", strings.Join(p, "; ")).Wrap())
+		}
+	}
+	return problems
+}
@@ -0,0 +1,143 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mq/kafka"
+)
+
+func newKafkaBroker(t *testing.T, topics ...string) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	metadata := sarama.NewMockMetadataResponse(t).
+		SetBroker(broker.Addr(), broker.BrokerID()).
+		SetController(broker.BrokerID())
+	for _, topic := range topics {
+		metadata = metadata.SetLeader(topic, 0, broker.BrokerID())
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":    metadata,
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+	})
+	t.Cleanup(broker.Close)
+	return broker
+}
+
+func TestCheckKafka(t *testing.T) {
+	ctx := context.Background()
+	conf := &kafka.Config{Addr: []string{newKafkaBroker(t, "toRedis").Addr()}}
+	if err := CheckKafka(ctx, conf).Err; err != nil {
+		t.Fatalf("basic check: %v", err)
+	}
+
+	decls := []kafka.GroupDeclaration{
+		{Service: "msgtransfer", GroupID: "redis", Consumes: []string{"toRedis"}},
+		{Service: "push", GroupID: "push", Consumes: []string{"toPush"}},
+	}
+	err := CheckKafka(ctx, conf, WithKafkaTopology([]string{"toRedis", "toPush"}, decls)).Err
+	var v *kafka.TopologyViolation
+	if !errors.As(err, &v) || v.Kind != kafka.ViolationTopicMissingBroker || v.Topic != "toPush" {
+		t.Fatalf("extended check: %v", err)
+	}
+}
+
+func TestCheckKafkaMinVersion(t *testing.T) {
+	ctx := context.Background()
+	broker := newKafkaBroker(t)
+	conf := &kafka.Config{Addr: []string{broker.Addr()}}
+	res := CheckKafka(ctx, conf)
+	if res.Err != nil || res.Extra["version:"+broker.Addr()] != "2.3.0" {
+		t.Fatalf("default minimum: err = %v, extra = %v", res.Err, res.Extra)
+	}
+
+	conf.MinVersion = "2.8.0"
+	if err := CheckKafka(ctx, conf).Err; !errs.ErrComponentStart.Is(err) {
+		t.Fatalf("minVersion 2.8.0: err = %v, want ErrComponentStart", err)
+	}
+
+	// Fetch v7 was introduced by Kafka 1.1.
+	old := newKafkaBroker(t)
+	old.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(old.Addr(), old.BrokerID()).
+			SetController(old.BrokerID()),
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t).SetApiKeys([]sarama.ApiVersionsResponseKey{
+			{ApiKey: 0, MinVersion: 0, MaxVersion: 5},
+			{ApiKey: 1, MinVersion: 0, MaxVersion: 7},
+		}),
+	})
+	res = CheckKafka(ctx, &kafka.Config{Addr: []string{old.Addr()}})
+	if !errs.ErrComponentStart.Is(res.Err) || res.Extra["version:"+old.Addr()] != "1.1.0" {
+		t.Fatalf("old broker: err = %v, extra = %v", res.Err, res.Extra)
+	}
+}
+
+func TestCheckKafkaCreateTopics(t *testing.T) {
+	broker := newKafkaBroker(t, "toRedis")
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()).
+			SetLeader("toRedis", 0, broker.BrokerID()),
+		"ApiVersionsRequest":  sarama.NewMockApiVersionsResponse(t),
+		"CreateTopicsRequest": sarama.NewMockCreateTopicsResponse(t),
+	})
+	conf := &kafka.Config{Addr: []string{broker.Addr()}}
+
+	res := CheckKafka(context.Background(), conf, WithKafkaCreateTopics([]string{"toRedis"}, kafka.TopicSpec{}))
+	if res.Err != nil || res.Extra["createdTopics"] != "" {
+		t.Fatalf("existing topic: %v, extra %v", res.Err, res.Extra)
+	}
+	res = CheckKafka(context.Background(), conf, WithKafkaCreateTopics([]string{"toPush"}, kafka.TopicSpec{ReplicationFactor: 2}))
+	if !errs.ErrConfig.Is(res.Err) {
+		t.Fatalf("replication factor above the broker count: %v", res.Err)
+	}
+}
+
+func TestCheckKafkaTopicRequirements(t *testing.T) {
+	broker := newKafkaBroker(t, "toPush")
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()).
+			SetLeader("toPush", 0, broker.BrokerID()),
+		"ApiVersionsRequest":     sarama.NewMockApiVersionsResponse(t),
+		"DescribeConfigsRequest": sarama.NewMockDescribeConfigsResponse(t),
+	})
+	conf := &kafka.Config{Addr: []string{broker.Addr()}}
+	req := kafka.TopicRequirements{MinPartitions: 8, MinReplicationFactor: 3}
+
+	res := CheckKafka(context.Background(), conf, WithKafkaTopicRequirements([]string{"toPush"}, req))
+	if res.Err != nil || len(res.Warnings) != 1 {
+		t.Fatalf("err = %v, warnings = %v", res.Err, res.Warnings)
+	}
+	if got := res.Extra["topic:toPush"]; got != "partitions=1 replicationFactor=1" {
+		t.Errorf("layout = %q", got)
+	}
+	res = CheckKafka(context.Background(), conf, WithKafkaTopicRequirements([]string{"toPush"}, req), WithKafkaStrictTopics())
+	var multi *errs.MultiError
+	if !errors.As(res.Err, &multi) || len(multi.Errors) != 1 || len(res.Warnings) != 0 {
+		t.Fatalf("strict: err = %v, warnings = %v", res.Err, res.Warnings)
+	}
+}
+
+func TestCheckKafkaReadWriteProbe(t *testing.T) {
+	broker := newKafkaBroker(t)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("toPush", 0, broker.BrokerID()),
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"ProduceRequest":     sarama.NewMockProduceResponse(t).SetError("toPush", 0, sarama.ErrTopicAuthorizationFailed),
+	})
+	conf := &kafka.Config{Addr: []string{broker.Addr()}}
+	if res := CheckKafka(context.Background(), conf); res.Err != nil {
+		t.Fatalf("without the probe: %v", res.Err)
+	}
+	if res := CheckKafka(context.Background(), conf, WithKafkaReadWriteProbe("toPush")); !errs.ErrNoPermission.Is(res.Err) {
+		t.Fatalf("probe: %v", res.Err)
+	}
+}
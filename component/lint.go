@@ -0,0 +1,341 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/openimsdk/tools/mq/kafka"
+	"github.com/openimsdk/tools/s3/minio"
+	"github.com/openimsdk/tools/utils/network"
+)
+
+// Component names used in reports.
+const (
+	NameMongo     = "mongo"
+	NameRedis     = "redis"
+	NameKafka     = "kafka"
+	NameZookeeper = "zookeeper"
+	NameNacos     = "nacos"
+	NameMinio     = "minio"
+	NameCallbacks = "callbacks"
+)
+
+const hintLoopback = "use an address reachable from every host and container that runs the service"
+
+// placeholders are values left over from config templates.
+var placeholders = []string{"changeme", "change_me", "change-me", "replaceme", "replace_me", "todo", "xxx"}
+
+// LintConfig validates cfg without any network access, as CI pipelines do
+// before a deployment. Checks that need the component itself are reported as
+// StatusSkippedOffline. Use Report.ExitCode to fail only on errors.
+func LintConfig(cfg *Config) *Report {
+	r := &Report{}
+	if cfg == nil {
+		return r
+	}
+	if cfg.Mongo != nil {
+		lintMongo(r, cfg)
+	}
+	if cfg.Redis != nil {
+		lintRedis(r, cfg)
+	}
+	if cfg.Kafka != nil {
+		lintKafka(r, cfg)
+	}
+	if cfg.Zookeeper != nil {
+		lintZookeeper(r, cfg)
+	}
+	if cfg.Nacos != nil {
+		lintNacos(r, cfg)
+	}
+	if cfg.Minio != nil {
+		lintMinio(r, cfg)
+	}
+	return r
+}
+
+func lintMongo(r *Report, cfg *Config) {
+	conf := *cfg.Mongo
+	conf.Address = append([]string(nil), conf.Address...)
+	if err := conf.ValidateAndSetDefaults(); err != nil {
+		r.add(NameMongo, "config", StatusError, errMessage(err), "")
+	} else {
+		r.add(NameMongo, "config", StatusOK, "", "")
+	}
+	if cfg.Mongo.Uri == "" {
+		lintAddresses(r, NameMongo, cfg.Mongo.Address)
+		lintCredentials(r, NameMongo, cfg.Mongo.Username, cfg.Mongo.Password, false)
+	} else {
+		lintMongoURI(r, cfg.Mongo.Uri)
+	}
+	r.add(NameMongo, "connectivity", StatusSkippedOffline, "", "")
+}
+
+func lintMongoURI(r *Report, uri string) {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "mongodb" && u.Scheme != "mongodb+srv") {
+		r.add(NameMongo, "address", StatusError, "uri must be a mongodb:// or mongodb+srv:// URL", "")
+		return
+	}
+	if u.Scheme == "mongodb" {
+		lintAddresses(r, NameMongo, strings.Split(u.Host, ","))
+	} else {
+		r.add(NameMongo, "address", StatusOK, "", "")
+	}
+	var username, password string
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	lintCredentials(r, NameMongo, username, password, false)
+}
+
+func lintRedis(r *Report, cfg *Config) {
+	conf := cfg.Redis
+	lintAddresses(r, NameRedis, conf.Address)
+	if conf.SentinelMasterName != "" && conf.ClusterMode {
+		r.add(NameRedis, "sentinelMasterName", StatusError, "sentinelMasterName is set with clusterMode",
+			"unset clusterMode for a sentinel deployment, or sentinelMasterName for a cluster")
+	} else if len(conf.Address) > 1 && !conf.ClusterMode && conf.SentinelMasterName == "" {
+		r.add(NameRedis, "clusterMode", StatusWarning, "several addresses are configured but clusterMode is false; a cluster client is used anyway",
+			"set clusterMode to true, or keep a single address for a standalone server")
+	}
+	lintCredentials(r, NameRedis, conf.Username, conf.Password, true)
+	r.add(NameRedis, "connectivity", StatusSkippedOffline, "", "")
+}
+
+func lintKafka(r *Report, cfg *Config) {
+	conf := cfg.Kafka
+	lintAddresses(r, NameKafka, conf.Addr)
+	if conf.Username != "" || conf.Password != "" {
+		lintCredentials(r, NameKafka, conf.Username, conf.Password, false)
+	}
+	if len(cfg.KafkaTopics) > 0 || len(cfg.KafkaGroups) > 0 {
+		err := kafka.ValidateTopologyOffline(cfg.KafkaTopics, cfg.KafkaGroups)
+		var violations interface{ Unwrap() []error }
+		if errors.As(err, &violations) {
+			for _, e := range violations.Unwrap() {
+				var v *kafka.TopologyViolation
+				if errors.As(e, &v) {
+					r.add(NameKafka, "topology", StatusError, topologyMessage(v), v.Hint)
+				}
+			}
+		} else {
+			r.add(NameKafka, "topology", StatusOK, "", "")
+		}
+	}
+	r.add(NameKafka, "connectivity", StatusSkippedOffline, "", "")
+}
+
+func topologyMessage(v *kafka.TopologyViolation) string {
+	msg := v.Kind
+	if v.Group != "" {
+		msg += " group=" + v.Group
+	}
+	if v.Topic != "" {
+		msg += " topic=" + v.Topic
+	}
+	if len(v.Services) > 0 {
+		msg += " services=" + strings.Join(v.Services, ",")
+	}
+	return msg
+}
+
+func lintZookeeper(r *Report, cfg *Config) {
+	conf := cfg.Zookeeper
+	lintAddresses(r, NameZookeeper, conf.ZkServers)
+	if conf.Scheme == "" {
+		r.add(NameZookeeper, "config", StatusError, "scheme is empty", "")
+	} else {
+		r.add(NameZookeeper, "config", StatusOK, "", "")
+	}
+	if conf.Username != "" || conf.Password != "" {
+		lintCredentials(r, NameZookeeper, conf.Username, conf.Password, false)
+	}
+	r.add(NameZookeeper, "connectivity", StatusSkippedOffline, "", "")
+}
+
+func lintNacos(r *Report, cfg *Config) {
+	conf := *cfg.Nacos
+	if err := conf.ValidateAndSetDefaults(); err != nil {
+		r.add(NameNacos, "config", StatusError, errMessage(err), "")
+	} else {
+		r.add(NameNacos, "config", StatusOK, "", "")
+	}
+	if conf.Username != "" || conf.Password != "" {
+		lintCredentials(r, NameNacos, conf.Username, conf.Password, false)
+	}
+	r.add(NameNacos, "connectivity", StatusSkippedOffline, "", "")
+}
+
+func lintMinio(r *Report, cfg *Config) {
+	conf := cfg.Minio
+	if conf.Bucket == "" {
+		r.add(NameMinio, "config", StatusError, "bucket is empty", "")
+	} else {
+		r.add(NameMinio, "config", StatusOK, "", "")
+	}
+	endpoint, err := parseEndpoint(conf.Endpoint)
+	switch {
+	case err != nil:
+		r.add(NameMinio, "address", StatusError, "endpoint: "+err.Error(), "")
+	case isLoopback(endpoint.Hostname()):
+		r.add(NameMinio, "address", StatusWarning, "endpoint "+endpoint.Host+" is a loopback address", hintLoopback)
+	default:
+		r.add(NameMinio, "address", StatusOK, "", "")
+	}
+	lintSignEndpoint(r, conf.SignEndpoint, endpoint)
+	switch {
+	case conf.CredentialsProvider != "" && conf.CredentialsProvider != minio.CredentialsStatic:
+		// Resolved from the environment of the service, at connection time.
+		r.add(NameMinio, "credentials", StatusOK, "", "")
+	case conf.AccessKeyID == "" || conf.SecretAccessKey == "":
+		r.add(NameMinio, "credentials", StatusError, "accessKeyID and secretAccessKey are required", "")
+	case isPlaceholder(conf.AccessKeyID) || isPlaceholder(conf.SecretAccessKey):
+		r.add(NameMinio, "credentials", StatusError, "credentials contain a placeholder value", "replace the template value with the real credentials")
+	default:
+		r.add(NameMinio, "credentials", StatusOK, "", "")
+	}
+	r.add(NameMinio, "connectivity", StatusSkippedOffline, "", "")
+}
+
+// lintSignEndpoint checks the endpoint used in presigned URLs, which clients
+// outside the cluster must be able to reach.
+func lintSignEndpoint(r *Report, signEndpoint string, endpoint *url.URL) {
+	const hint = "set signEndpoint to the address clients use to reach MinIO"
+	if signEndpoint == "" {
+		if endpoint != nil && isLoopback(endpoint.Hostname()) {
+			r.add(NameMinio, "signEndpoint", StatusWarning, "signEndpoint is empty and endpoint is a loopback address, presigned URLs are unusable by clients", hint)
+			return
+		}
+		r.add(NameMinio, "signEndpoint", StatusOK, "", "")
+		return
+	}
+	u, err := parseEndpoint(signEndpoint)
+	switch {
+	case err != nil:
+		r.add(NameMinio, "signEndpoint", StatusError, "signEndpoint: "+err.Error(), "")
+	case isLoopback(u.Hostname()):
+		r.add(NameMinio, "signEndpoint", StatusError, "signEndpoint "+u.Host+" is a loopback address, presigned URLs are unusable by clients", hint)
+	default:
+		r.add(NameMinio, "signEndpoint", StatusOK, "", "")
+	}
+}
+
+func lintAddresses(r *Report, component string, addrs []string) {
+	if len(addrs) == 0 {
+		r.add(component, "address", StatusError, "no address configured", "")
+		return
+	}
+	normalized, err := network.NormalizeAddrs(addrs)
+	if err != nil {
+		r.add(component, "address", StatusError, errMessage(err), "")
+		return
+	}
+	for _, addr := range normalized {
+		host, _, _ := net.SplitHostPort(addr)
+		if isLoopback(host) {
+			r.add(component, "address", StatusWarning, addr+" is a loopback address", hintLoopback)
+			return
+		}
+	}
+	r.add(component, "address", StatusOK, "", "")
+}
+
+// lintCredentials reports placeholder passwords as errors. An empty password
+// is an error when a username is set; otherwise it is a warning if
+// passwordOnly is set, because the component accepts a bare password.
+func lintCredentials(r *Report, component, username, password string, passwordOnly bool) {
+	switch {
+	case isPlaceholder(username) || isPlaceholder(password):
+		r.add(component, "credentials", StatusError, "credentials contain a placeholder value", "replace the template value with the real credentials")
+	case username != "" && password == "":
+		r.add(component, "credentials", StatusError, "username "+username+" has an empty password", "")
+	case password == "" && passwordOnly:
+		r.add(component, "credentials", StatusWarning, "no password configured", "enable authentication outside of development environments")
+	case password == "":
+		r.add(component, "credentials", StatusWarning, "authentication is disabled", "enable authentication outside of development environments")
+	default:
+		r.add(component, "credentials", StatusOK, "", "")
+	}
+}
+
+func isPlaceholder(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return false
+	}
+	if strings.HasPrefix(s, "${") || (strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">")) {
+		return true
+	}
+	for _, p := range placeholders {
+		if s == p {
+			return true
+		}
+	}
+	return false
+}
+
+func isLoopback(host string) bool {
+	// localhost and its subdomains always resolve to a loopback address, see
+	// RFC 6761.
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// parseEndpoint parses an http(s) endpoint, defaulting to http when the
+// scheme is missing.
+func parseEndpoint(endpoint string) (*url.URL, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return nil, errors.New("empty")
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.New("invalid URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return nil, errors.New("missing host")
+	}
+	if err := network.ValidateHost(u.Host); err != nil {
+		return nil, errors.New(errMessage(err))
+	}
+	return u, nil
+}
+
+// errMessage returns the message of err without the stack attached by errs.
+func errMessage(err error) string {
+	for {
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return err.Error()
+		}
+		err = c.Cause()
+	}
+}
@@ -0,0 +1,95 @@
+package component
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openimsdk/tools/db/redisutil"
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestLintConfigGolden(t *testing.T) {
+	for name, wantExit := range map[string]int{"clean": ExitOK, "broken": ExitErrors} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "lint", name+".yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cfg Config
+			if err := yaml.Unmarshal(data, &cfg); err != nil {
+				t.Fatal(err)
+			}
+			report := LintConfig(&cfg)
+			if code := report.ExitCode(); code != wantExit {
+				t.Errorf("exit code %d, want %d", code, wantExit)
+			}
+
+			var text, js bytes.Buffer
+			if err := report.WriteText(&text); err != nil {
+				t.Fatal(err)
+			}
+			if err := report.WriteJSON(&js); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join("testdata", "lint", name+".txt"), text.Bytes())
+			checkGolden(t, filepath.Join("testdata", "lint", name+".json"), js.Bytes())
+		})
+	}
+}
+
+func TestLintConfigWarningsPass(t *testing.T) {
+	report := LintConfig(&Config{Redis: &redisConfigLocal})
+	s := report.Summary()
+	if s.Warnings == 0 || s.Errors != 0 || report.ExitCode() != ExitOK {
+		t.Fatalf("summary %+v, exit %d", s, report.ExitCode())
+	}
+}
+
+func TestIsPlaceholder(t *testing.T) {
+	for _, s := range []string{"CHANGEME", " changeme ", "change_me", "${REDIS_PASSWORD}", "<password>", "TODO"} {
+		if !isPlaceholder(s) {
+			t.Errorf("%q not detected", s)
+		}
+	}
+	for _, s := range []string{"", "changemenot", "openIM123"} {
+		if isPlaceholder(s) {
+			t.Errorf("%q reported as placeholder", s)
+		}
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "127.1.2.3", "::1", "::ffff:127.0.0.1", "localhost", "LOCALHOST.", "minio.localhost"} {
+		if !isLoopback(host) {
+			t.Errorf("%q not detected", host)
+		}
+	}
+	for _, host := range []string{"10.0.0.1", "::", "minio.example.com", "localhost.example.com"} {
+		if isLoopback(host) {
+			t.Errorf("%q reported as loopback", host)
+		}
+	}
+}
+
+var redisConfigLocal = redisutil.Config{Address: []string{"127.0.0.1:6379"}}
@@ -0,0 +1,67 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"context"
+
+	"github.com/openimsdk/tools/db/mongoutil"
+	"github.com/openimsdk/tools/errs"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoSchemaOptions struct {
+	ensure bool
+}
+
+type MongoSchemaOption func(o *mongoSchemaOptions)
+
+// WithEnsureIndexes creates the missing collections and indexes instead of
+// reporting them. See mongoutil.EnsureIndexes.
+func WithEnsureIndexes() MongoSchemaOption {
+	return func(o *mongoSchemaOptions) {
+		o.ensure = true
+	}
+}
+
+// CheckMongoSchema verifies that the database of cfg has the expected
+// collections and indexes. Combine it with WaitFor to block until a migration
+// job has created them.
+func CheckMongoSchema(ctx context.Context, cfg *mongoutil.Config, expectations []mongoutil.CollectionExpectation, opts ...MongoSchemaOption) error {
+	var o mongoSchemaOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	conf := *cfg
+	if err := conf.ValidateAndSetDefaults(); err != nil {
+		return err
+	}
+	clientOpts, err := conf.ClientOptions()
+	if err != nil {
+		return err
+	}
+	client, err := mongo.Connect(ctx, clientOpts)
+	if err != nil {
+		return errs.WrapMsg(err, "MongoDB connect failed", "Database", conf.Database)
+	}
+	defer func() {
+		_ = client.Disconnect(context.Background())
+	}()
+	db := client.Database(conf.Database)
+	if o.ensure {
+		return mongoutil.EnsureIndexes(ctx, db, expectations)
+	}
+	return mongoutil.ValidateSchema(ctx, db, expectations)
+}
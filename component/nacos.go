@@ -0,0 +1,27 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"context"
+
+	"github.com/openimsdk/tools/discovery/nacos"
+)
+
+// CheckNacos verifies that the Nacos servers are reachable and ready and that
+// the configured credentials are accepted.
+func CheckNacos(ctx context.Context, conf *nacos.Config) error {
+	return nacos.Check(ctx, conf)
+}
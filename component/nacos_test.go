@@ -0,0 +1,37 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/tools/discovery/nacos"
+	"github.com/openimsdk/tools/errs"
+)
+
+func TestCheckNacos(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/nacos/v1/auth/login" {
+			http.Error(w, "unknown user!", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	if err := CheckNacos(context.Background(), &nacos.Config{ServerAddrs: []string{addr}}); err != nil {
+		t.Fatalf("CheckNacos without auth: %v", err)
+	}
+	err := CheckNacos(context.Background(), &nacos.Config{ServerAddrs: []string{addr}, Username: "nacos", Password: "wrong"})
+	if !errors.Is(err, errs.ErrNoPermission) {
+		t.Fatalf("CheckNacos = %v, want ErrNoPermission", err)
+	}
+	err = CheckNacos(context.Background(), &nacos.Config{ServerAddrs: []string{"127.0.0.1:1"}})
+	if !errors.Is(err, errs.ErrDependencyUnavailable) {
+		t.Fatalf("CheckNacos unreachable = %v, want ErrDependencyUnavailable", err)
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"context"
+
+	"github.com/openimsdk/tools/mq/rabbitmq"
+)
+
+// CheckRabbitMQ verifies that the broker accepts the configured credentials
+// and vhost and that the required exchanges and queues exist. It returns the
+// product and version the broker reports.
+func CheckRabbitMQ(ctx context.Context, conf *rabbitmq.Config, opts ...rabbitmq.CheckOption) (*rabbitmq.ServerInfo, error) {
+	return rabbitmq.Check(ctx, conf, opts...)
+}
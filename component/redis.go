@@ -0,0 +1,95 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/openimsdk/tools/db/redisutil"
+)
+
+// RedisSmallInstance is the host memory below which a Redis without
+// maxmemory is reported: it grows until the host runs out of memory.
+const RedisSmallInstance = 4 << 30
+
+const hintRedisEviction = "set maxmemory-policy to noeviction and size maxmemory for the token and seq caches; " +
+	"OpenIM does not recover the evicted keys, users are logged out and sequences are reloaded"
+
+// CheckRedisSettings reads the server settings of the Redis of cfg, see
+// redisutil.NodeSettings, and reports:
+//   - maxmemoryPolicy: an error when the policy evicts keys under a maxmemory
+//     limit, since both the allkeys and the volatile policies evict the OpenIM
+//     token and seq keys, a warning when such a policy is set without a limit,
+//     and a warning when the policy cannot be read;
+//   - maxmemory: a warning when there is no limit on a host smaller than
+//     RedisSmallInstance;
+//   - clusterMode: an error when the client configured, a cluster client for
+//     clusterMode or several addresses, does not match the server. It is not
+//     checked in sentinel mode.
+func CheckRedisSettings(ctx context.Context, cfg *Config) *Report {
+	r := &Report{}
+	if cfg == nil || cfg.Redis == nil {
+		return r
+	}
+	conf := cfg.Redis
+	s, err := redisutil.NodeSettings(ctx, conf)
+	if err != nil {
+		r.add(NameRedis, "settings", StatusError, errMessage(err), "")
+		return r
+	}
+
+	switch {
+	case s.MaxmemoryPolicy == "":
+		r.add(NameRedis, "maxmemoryPolicy", StatusWarning, "could not verify maxmemory-policy: "+errMessage(s.ConfigErr),
+			"check that maxmemory-policy is noeviction in the settings of the Redis service")
+	case evictingPolicy(s.MaxmemoryPolicy) && s.Maxmemory > 0:
+		r.add(NameRedis, "maxmemoryPolicy", StatusError,
+			"maxmemory-policy "+s.MaxmemoryPolicy+" evicts OpenIM keys once maxmemory "+strconv.FormatInt(s.Maxmemory, 10)+" is reached", hintRedisEviction)
+	case evictingPolicy(s.MaxmemoryPolicy):
+		r.add(NameRedis, "maxmemoryPolicy", StatusWarning,
+			"maxmemory-policy "+s.MaxmemoryPolicy+" evicts OpenIM keys as soon as a maxmemory limit is set", hintRedisEviction)
+	default:
+		r.add(NameRedis, "maxmemoryPolicy", StatusOK, s.MaxmemoryPolicy, "")
+	}
+
+	if s.MaxmemoryPolicy != "" && s.Maxmemory == 0 && s.TotalSystemMemory > 0 && s.TotalSystemMemory < RedisSmallInstance {
+		r.add(NameRedis, "maxmemory", StatusWarning,
+			"maxmemory is 0 on a host of "+strconv.FormatInt(s.TotalSystemMemory>>20, 10)+" MiB, Redis grows until the host runs out of memory",
+			"set maxmemory below the memory of the host")
+	}
+
+	if conf.SentinelMasterName == "" {
+		clusterClient := conf.ClusterMode || len(conf.Address) > 1
+		switch {
+		case clusterClient && !s.ClusterEnabled:
+			r.add(NameRedis, "clusterMode", StatusError, s.Addr+" is not a cluster node, but clusterMode is set or several addresses are configured",
+				"unset clusterMode and keep a single address for a standalone server")
+		case !clusterClient && s.ClusterEnabled:
+			r.add(NameRedis, "clusterMode", StatusError, s.Addr+" is a cluster node, but clusterMode is false",
+				"set clusterMode to true and list the cluster nodes")
+		default:
+			r.add(NameRedis, "clusterMode", StatusOK, "", "")
+		}
+	}
+	return r
+}
+
+// evictingPolicy reports whether policy evicts keys, as every policy but
+// noeviction does.
+func evictingPolicy(policy string) bool {
+	return strings.HasPrefix(policy, "allkeys-") || strings.HasPrefix(policy, "volatile-")
+}
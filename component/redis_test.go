@@ -0,0 +1,130 @@
+package component
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/openimsdk/tools/db/redisutil"
+)
+
+// fakeRedisConfig starts a server answering INFO with info and CONFIG GET
+// with config, or an error when config is nil.
+func fakeRedisConfig(t *testing.T, info string, config map[string]string) string {
+	t.Helper()
+	srv, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	_ = srv.Register("INFO", func(c *server.Peer, cmd string, args []string) { c.WriteBulk(info) })
+	_ = srv.Register("CONFIG", func(c *server.Peer, cmd string, args []string) {
+		if config == nil {
+			c.WriteError("ERR unknown command 'CONFIG'")
+			return
+		}
+		c.WriteStrings([]string{args[1], config[args[1]]})
+	})
+	return srv.Addr().String()
+}
+
+func findings(r *Report) map[string]Finding {
+	m := map[string]Finding{}
+	for _, f := range r.Findings {
+		m[f.Check] = f
+	}
+	return m
+}
+
+func TestCheckRedisSettings(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	const small = "total_system_memory:1073741824\r\ncluster_enabled:0\r\n"
+
+	tests := []struct {
+		name   string
+		info   string
+		config map[string]string
+		conf   redisutil.Config
+		want   map[string]Status
+	}{
+		{
+			name:   "recommended",
+			info:   "total_system_memory:17179869184\r\ncluster_enabled:0\r\n",
+			config: map[string]string{"maxmemory-policy": "noeviction", "maxmemory": "4294967296"},
+			want:   map[string]Status{"maxmemoryPolicy": StatusOK, "clusterMode": StatusOK},
+		},
+		{
+			name:   "allkeys with limit",
+			info:   small,
+			config: map[string]string{"maxmemory-policy": "allkeys-lru", "maxmemory": "536870912"},
+			want:   map[string]Status{"maxmemoryPolicy": StatusError, "clusterMode": StatusOK},
+		},
+		{
+			name:   "volatile without limit",
+			info:   small,
+			config: map[string]string{"maxmemory-policy": "volatile-lfu", "maxmemory": "0"},
+			want:   map[string]Status{"maxmemoryPolicy": StatusWarning, "maxmemory": StatusWarning, "clusterMode": StatusOK},
+		},
+		{
+			name: "config blocked, policy from info",
+			info: small + "maxmemory:536870912\r\nmaxmemory_policy:allkeys-lfu\r\n",
+			want: map[string]Status{"maxmemoryPolicy": StatusError, "clusterMode": StatusOK},
+		},
+		{
+			name: "config blocked",
+			info: small,
+			want: map[string]Status{"maxmemoryPolicy": StatusWarning, "clusterMode": StatusOK},
+		},
+		{
+			name:   "cluster node, standalone config",
+			info:   "cluster_enabled:1\r\n",
+			config: map[string]string{"maxmemory-policy": "noeviction", "maxmemory": "0"},
+			want:   map[string]Status{"maxmemoryPolicy": StatusOK, "clusterMode": StatusError},
+		},
+		{
+			name:   "standalone node, cluster config",
+			info:   "cluster_enabled:0\r\n",
+			config: map[string]string{"maxmemory-policy": "noeviction", "maxmemory": "0"},
+			conf:   redisutil.Config{Address: []string{"127.0.0.1:1"}},
+			want:   map[string]Status{"maxmemoryPolicy": StatusOK, "clusterMode": StatusError},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := tt.conf
+			conf.Address = append([]string{fakeRedisConfig(t, tt.info, tt.config)}, conf.Address...)
+			got := findings(CheckRedisSettings(ctx, &Config{Redis: &conf}))
+			if len(got) != len(tt.want) {
+				t.Fatalf("findings = %+v, want %v", got, tt.want)
+			}
+			for check, status := range tt.want {
+				if got[check].Status != status {
+					t.Errorf("%s: %+v, want %s", check, got[check], status)
+				}
+				if status == StatusError && got[check].Hint == "" {
+					t.Errorf("%s: no hint", check)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRedisSettingsMiniredis(t *testing.T) {
+	mr := miniredis.NewMiniRedis()
+	if err := mr.Start(); err != nil {
+		t.Skipf("cannot start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	got := findings(CheckRedisSettings(context.Background(), &Config{Redis: &redisutil.Config{Address: []string{mr.Addr()}}}))
+	// miniredis has neither CONFIG nor the memory fields of INFO.
+	if f := got["maxmemoryPolicy"]; f.Status != StatusWarning || !strings.Contains(f.Message, "could not verify") {
+		t.Fatalf("maxmemoryPolicy = %+v, want a could not verify warning", f)
+	}
+	if got["clusterMode"].Status != StatusOK {
+		t.Errorf("clusterMode = %+v", got["clusterMode"])
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// Status is the outcome of a single check in a Report.
+type Status string
+
+const (
+	StatusOK             Status = "ok"
+	StatusWarning        Status = "warning"
+	StatusError          Status = "error"
+	StatusSkippedOffline Status = "skipped (offline)"
+)
+
+// Process exit codes for a Report. Warnings do not fail the run.
+const (
+	ExitOK     = 0
+	ExitErrors = 1
+)
+
+// Finding is the result of one check of one component.
+type Finding struct {
+	Component string `json:"component"`
+	Check     string `json:"check"`
+	Status    Status `json:"status"`
+	Message   string `json:"message,omitempty"`
+	Hint      string `json:"hint,omitempty"`
+}
+
+// Summary counts the findings of a Report by status.
+type Summary struct {
+	OK       int `json:"ok"`
+	Warnings int `json:"warnings"`
+	Errors   int `json:"errors"`
+	Skipped  int `json:"skipped"`
+}
+
+// Report collects the findings of a check run.
+type Report struct {
+	Findings []Finding `json:"findings"`
+}
+
+func (r *Report) add(component, check string, status Status, message, hint string) {
+	r.Findings = append(r.Findings, Finding{Component: component, Check: check, Status: status, Message: message, Hint: hint})
+}
+
+// AddResults adds the connectivity findings of results, as returned by
+// CheckAll: one per failure, the errors of an *errs.MultiError being reported
+// separately, one warning per entry of Warnings, and an ok finding for the
+// other components.
+func (r *Report) AddResults(results []*CheckResult) {
+	for _, res := range results {
+		var multi *errs.MultiError
+		switch {
+		case errors.As(res.Err, &multi):
+			for _, err := range multi.Errors {
+				r.add(res.Component, "connectivity", StatusError, errMessage(err), "")
+			}
+		case res.Err != nil:
+			r.add(res.Component, "connectivity", StatusError, errMessage(res.Err), "")
+		case len(res.Warnings) == 0:
+			r.add(res.Component, "connectivity", StatusOK, "", "")
+		}
+		for _, err := range res.Warnings {
+			r.add(res.Component, "connectivity", StatusWarning, errMessage(err), "")
+		}
+	}
+}
+
+// Summary returns the number of findings per status.
+func (r *Report) Summary() Summary {
+	var s Summary
+	for _, f := range r.Findings {
+		switch f.Status {
+		case StatusOK:
+			s.OK++
+		case StatusWarning:
+			s.Warnings++
+		case StatusError:
+			s.Errors++
+		case StatusSkippedOffline:
+			s.Skipped++
+		}
+	}
+	return s
+}
+
+// HasErrors reports whether any finding has StatusError.
+func (r *Report) HasErrors() bool {
+	return r.Summary().Errors > 0
+}
+
+// ExitCode returns ExitErrors if the report has errors and ExitOK otherwise.
+func (r *Report) ExitCode() int {
+	if r.HasErrors() {
+		return ExitErrors
+	}
+	return ExitOK
+}
+
+// WriteText writes the report as an aligned table followed by a summary line.
+func (r *Report) WriteText(w io.Writer) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMPONENT\tCHECK\tSTATUS\tDETAIL")
+	for _, f := range r.Findings {
+		detail := f.Message
+		if f.Hint != "" {
+			if detail != "" {
+				detail += " "
+			}
+			detail += "(hint: " + f.Hint + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Component, f.Check, f.Status, detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if _, err := io.WriteString(w, strings.TrimRight(line, " ")+"\n"); err != nil {
+			return err
+		}
+	}
+	s := r.Summary()
+	_, err := fmt.Fprintf(w, "summary: %d ok, %d warnings, %d errors, %d skipped\n", s.OK, s.Warnings, s.Errors, s.Skipped)
+	return err
+}
+
+// WriteJSON writes the report and its summary as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Findings []Finding `json:"findings"`
+		Summary  Summary   `json:"summary"`
+	}{r.Findings, r.Summary()})
+}
@@ -0,0 +1,112 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+const (
+	// DefaultMaxRetry is the number of attempts of a check, matching the
+	// loop of the check-component tool.
+	DefaultMaxRetry             = 300
+	DefaultRetryInitialInterval = time.Second
+	DefaultRetryMaxInterval     = 10 * time.Second
+	DefaultRetryMultiplier      = 2.0
+)
+
+// RetryOptions configures CheckWithRetry. Zero fields take the defaults.
+type RetryOptions struct {
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// Multiplier is the growth factor of the interval after each attempt.
+	Multiplier float64
+	// OnFailure, when set, is called after each failed attempt with the
+	// attempt number, from 1, and its error.
+	OnFailure func(attempt int, err error)
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultMaxRetry
+	}
+	if o.InitialInterval <= 0 {
+		o.InitialInterval = DefaultRetryInitialInterval
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = DefaultRetryMaxInterval
+	}
+	if o.MaxInterval < o.InitialInterval {
+		o.MaxInterval = o.InitialInterval
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = DefaultRetryMultiplier
+	}
+	return o
+}
+
+// interval returns the wait after the given failed attempt: the exponential
+// backoff capped at MaxInterval, with a random jitter of up to half of it so
+// that replicas started together do not retry in step.
+func (o RetryOptions) interval(attempt int) time.Duration {
+	d := float64(o.InitialInterval)
+	for i := 1; i < attempt && d < float64(o.MaxInterval); i++ {
+		d *= o.Multiplier
+	}
+	if d > float64(o.MaxInterval) {
+		d = float64(o.MaxInterval)
+	}
+	half := time.Duration(d / 2)
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// CheckWithRetry calls fn until it succeeds, at most opts.MaxAttempts times,
+// waiting with exponential backoff between the attempts, and returns its
+// result. name identifies the component in the error. It stops when ctx is
+// done, returning the error of ctx with the last error of fn attached.
+func CheckWithRetry(ctx context.Context, name string, fn func() (string, error), opts RetryOptions) (string, error) {
+	opts = opts.withDefaults()
+	var err error
+	for attempt := 1; ; attempt++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			kv := []any{"attempts", attempt - 1}
+			if err != nil {
+				kv = append(kv, "lastErr", err.Error())
+			}
+			return "", errs.WrapMsg(ctxErr, name+" check cancelled", kv...)
+		}
+		var res string
+		if res, err = fn(); err == nil {
+			return res, nil
+		}
+		if opts.OnFailure != nil {
+			opts.OnFailure(attempt, err)
+		}
+		if attempt >= opts.MaxAttempts {
+			return "", errs.WrapMsg(err, name+" check failed", "attempts", attempt)
+		}
+		timer := time.NewTimer(opts.interval(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+func TestCheckWithRetry(t *testing.T) {
+	opts := RetryOptions{MaxAttempts: 4, InitialInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond}
+	var failures []int
+	opts.OnFailure = func(attempt int, err error) {
+		failures = append(failures, attempt)
+	}
+
+	calls := 0
+	res, err := CheckWithRetry(context.Background(), "redis", func() (string, error) {
+		if calls++; calls < 3 {
+			return "", errs.ErrDependencyUnavailable.WrapMsg("not yet")
+		}
+		return "the addr is:127.0.0.1:6379", nil
+	}, opts)
+	if err != nil || res != "the addr is:127.0.0.1:6379" || calls != 3 {
+		t.Fatalf("CheckWithRetry = %q, %v after %d calls", res, err, calls)
+	}
+	if len(failures) != 2 || failures[1] != 2 {
+		t.Fatalf("failures = %v, want [1 2]", failures)
+	}
+
+	calls, failures = 0, nil
+	_, err = CheckWithRetry(context.Background(), "redis", func() (string, error) {
+		calls++
+		return "", errs.ErrDependencyUnavailable.WrapMsg("down")
+	}, opts)
+	if !errors.Is(err, errs.ErrDependencyUnavailable) || calls != 4 || len(failures) != 4 {
+		t.Fatalf("exhausted: err = %v, calls = %d, failures = %v", err, calls, failures)
+	}
+}
+
+func TestCheckWithRetryCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	_, err := CheckWithRetry(ctx, "mongo", func() (string, error) {
+		calls++
+		cancel()
+		return "", errs.ErrDependencyUnavailable.WrapMsg("down")
+	}, RetryOptions{InitialInterval: time.Minute})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Fatalf("err = %v after %d calls", err, calls)
+	}
+	if !strings.Contains(err.Error(), "down") {
+		t.Fatalf("err = %v, want the last attempt error attached", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("cancellation took %s", elapsed)
+	}
+}
+
+func TestCheckWithRetryDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	calls := 0
+	_, err := CheckWithRetry(ctx, "kafka", func() (string, error) {
+		calls++
+		return "", errs.ErrDependencyUnavailable.WrapMsg("down")
+	}, RetryOptions{InitialInterval: time.Minute})
+	if !errors.Is(err, context.DeadlineExceeded) || calls != 1 {
+		t.Fatalf("err = %v after %d calls, want DeadlineExceeded", err, calls)
+	}
+}
+
+func TestRetryInterval(t *testing.T) {
+	opts := RetryOptions{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second}.withDefaults()
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := opts.interval(attempt); d < max/2 || d > max {
+				t.Fatalf("interval(%d) = %s, want in [%s, %s]", attempt, d, max/2, max)
+			}
+		}
+	}
+	if opts.MaxAttempts != DefaultMaxRetry || opts.Multiplier != DefaultRetryMultiplier {
+		t.Fatalf("defaults = %+v", opts)
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import "github.com/openimsdk/tools/config"
+
+// JSONSchema describes Config as a JSON Schema, see config.JSONSchema. It is
+// checked against testdata/config.schema.json, so changes to the structures
+// of Config require regenerating the file with go test -update.
+func JSONSchema() ([]byte, error) {
+	return config.JSONSchema(&Config{})
+}
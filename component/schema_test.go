@@ -0,0 +1,18 @@
+package component
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONSchemaGolden(t *testing.T) {
+	data, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(data) {
+		t.Fatalf("invalid JSON:\n%s", data)
+	}
+	checkGolden(t, filepath.Join("testdata", "config.schema.json"), data)
+}
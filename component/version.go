@@ -0,0 +1,200 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// VersionRequirement is the supported version range of a component. Versions
+// older than Min are errors, versions older than Recommended are warnings.
+type VersionRequirement struct {
+	Min         string
+	Recommended string
+}
+
+var (
+	minVersionsLock sync.RWMutex
+	minVersions     = map[string]VersionRequirement{
+		NameMongo: {Min: "4.2", Recommended: "5.0"},
+		NameRedis: {Min: "6.0", Recommended: "6.2"},
+		NameKafka: {Min: "2.0", Recommended: "2.8"},
+	}
+)
+
+// SetMinVersions overrides the requirements of the given components, for
+// forks that support a different range. A requirement with an empty Min
+// removes the check of its component.
+func SetMinVersions(reqs map[string]VersionRequirement) {
+	minVersionsLock.Lock()
+	defer minVersionsLock.Unlock()
+	for name, req := range reqs {
+		if req.Min == "" {
+			delete(minVersions, name)
+			continue
+		}
+		minVersions[name] = req
+	}
+}
+
+// MinVersions returns a copy of the current version requirements.
+func MinVersions() map[string]VersionRequirement {
+	minVersionsLock.RLock()
+	defer minVersionsLock.RUnlock()
+	res := make(map[string]VersionRequirement, len(minVersions))
+	for name, req := range minVersions {
+		res[name] = req
+	}
+	return res
+}
+
+type version struct {
+	parts      []int
+	preRelease bool
+}
+
+// parseVersion reads the leading dotted numbers of s, such as 6.2.14 in
+// "v6.2.14-rc1". A -rc, -alpha or -beta suffix marks a pre-release, which
+// sorts before the release. Other suffixes, such as the "-ent" of MongoDB
+// enterprise builds, are ignored.
+func parseVersion(s string) (version, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	var v version
+	for {
+		end := 0
+		for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		n, err := strconv.Atoi(rest[:end])
+		if err != nil {
+			return version{}, errs.ErrArgs.WrapMsg("invalid version", "version", s)
+		}
+		v.parts = append(v.parts, n)
+		rest = rest[end:]
+		if !strings.HasPrefix(rest, ".") {
+			break
+		}
+		rest = rest[1:]
+	}
+	if len(v.parts) == 0 {
+		return version{}, errs.ErrArgs.WrapMsg("invalid version", "version", s)
+	}
+	suffix := strings.ToLower(rest)
+	for _, pre := range []string{"-rc", "-alpha", "-beta", "rc", "alpha", "beta"} {
+		if strings.HasPrefix(suffix, pre) {
+			v.preRelease = true
+			break
+		}
+	}
+	return v, nil
+}
+
+// CompareVersions compares two version strings as parseVersion reads them and
+// returns -1, 0 or 1. Missing components count as zero, so "6.2" equals "6.2.0".
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(va.parts) || i < len(vb.parts); i++ {
+		var x, y int
+		if i < len(va.parts) {
+			x = va.parts[i]
+		}
+		if i < len(vb.parts) {
+			y = vb.parts[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	switch {
+	case va.preRelease == vb.preRelease:
+		return 0, nil
+	case va.preRelease:
+		return -1, nil
+	default:
+		return 1, nil
+	}
+}
+
+// EvaluateVersion checks version against the requirement of component.
+// Components without a requirement are reported as StatusOK.
+func EvaluateVersion(component, version string) Finding {
+	f := Finding{Component: component, Check: "version", Status: StatusOK, Message: version}
+	minVersionsLock.RLock()
+	req, ok := minVersions[component]
+	minVersionsLock.RUnlock()
+	if !ok {
+		return f
+	}
+	if c, err := CompareVersions(version, req.Min); err != nil {
+		f.Status, f.Message = StatusWarning, "cannot parse version "+strconv.Quote(version)
+		return f
+	} else if c < 0 {
+		f.Status, f.Message = StatusError, version+" is older than the minimum supported "+req.Min
+		f.Hint = "upgrade " + component + " to " + req.Min + " or later"
+		return f
+	}
+	if req.Recommended != "" {
+		if c, err := CompareVersions(version, req.Recommended); err == nil && c < 0 {
+			f.Status, f.Message = StatusWarning, version+" is close to the minimum supported "+req.Min
+			f.Hint = "upgrade " + component + " to " + req.Recommended + " or later"
+		}
+	}
+	return f
+}
+
+// AddVersions adds a version finding for each server version read by the
+// checks of results, as returned by CheckAll, against the requirements set by
+// SetMinVersions. It is a separate pass over the results rather than a check
+// of its own: CheckMongo and CheckRedis report the version of the server
+// under Extra["version"] and CheckKafka that of each broker under
+// "version:" followed by its address. Components whose check could not read
+// a version are left to AddResults.
+func (r *Report) AddVersions(results []*CheckResult) {
+	for _, res := range results {
+		if v := res.Extra["version"]; v != "" {
+			r.Findings = append(r.Findings, EvaluateVersion(res.Component, v))
+		}
+		var addrs []string
+		for key := range res.Extra {
+			if strings.HasPrefix(key, "version:") {
+				addrs = append(addrs, strings.TrimPrefix(key, "version:"))
+			}
+		}
+		sort.Strings(addrs)
+		for _, addr := range addrs {
+			f := EvaluateVersion(res.Component, res.Extra["version:"+addr])
+			f.Message = addr + ": " + f.Message
+			r.Findings = append(r.Findings, f)
+		}
+	}
+}
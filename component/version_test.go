@@ -0,0 +1,106 @@
+package component
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"6.2.14", "6.2", 1},
+		{"6.2", "6.2.0", 0},
+		{"6.2.14-rc1", "6.2.14", -1},
+		{"6.2.14-rc1", "6.2.13", 1},
+		{"7.0.0-RC2", "7.0", -1},
+		{"v7.2.4", "7.2.4", 0},
+		{"5.0.24-ent", "5.0.24", 0},
+		{"4.4.29-modules", "4.2", 1},
+		{"4.0.28", "4.2", -1},
+		{"10.0", "9.9.9", 1},
+		{"3.6.1+build.7", "3.6.1", 0},
+	}
+	for _, c := range cases {
+		got, err := CompareVersions(c.a, c.b)
+		if err != nil || got != c.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, %v; want %d", c.a, c.b, got, err, c.want)
+		}
+	}
+	for _, bad := range []string{"", "unstable", "-rc1"} {
+		if _, err := CompareVersions(bad, "1.0"); err == nil {
+			t.Errorf("CompareVersions(%q) accepted", bad)
+		}
+	}
+}
+
+func TestEvaluateVersion(t *testing.T) {
+	defaults := MinVersions()
+	t.Cleanup(func() {
+		SetMinVersions(map[string]VersionRequirement{"fork": {}})
+		SetMinVersions(defaults)
+	})
+
+	cases := []struct {
+		component, version string
+		want               Status
+	}{
+		{NameRedis, "5.0.14", StatusError},
+		{NameRedis, "6.0.20", StatusWarning},
+		{NameRedis, "6.2.14-rc1", StatusOK},
+		{NameRedis, "6.2.0-rc1", StatusWarning},
+		{NameMongo, "4.0.28", StatusError},
+		{NameMongo, "7.0.2-ent", StatusOK},
+		{NameMongo, "garbage", StatusWarning},
+		{NameZookeeper, "3.4.0", StatusOK},
+	}
+	for _, c := range cases {
+		if f := EvaluateVersion(c.component, c.version); f.Status != c.want {
+			t.Errorf("EvaluateVersion(%s, %s) = %s %q, want %s", c.component, c.version, f.Status, f.Message, c.want)
+		}
+	}
+
+	SetMinVersions(map[string]VersionRequirement{
+		NameRedis: {Min: "5.0"},
+		NameMongo: {},
+		"fork":    {Min: "2.0", Recommended: "3.0"},
+	})
+	if f := EvaluateVersion(NameRedis, "5.0.14"); f.Status != StatusOK {
+		t.Errorf("overridden redis minimum: %s %q", f.Status, f.Message)
+	}
+	if f := EvaluateVersion(NameMongo, "3.6"); f.Status != StatusOK {
+		t.Errorf("removed mongo requirement still checked: %s", f.Status)
+	}
+	if f := EvaluateVersion("fork", "2.5"); f.Status != StatusWarning || f.Hint == "" {
+		t.Errorf("fork requirement: %s %q", f.Status, f.Hint)
+	}
+}
+
+func TestAddVersions(t *testing.T) {
+	var r Report
+	r.AddVersions([]*CheckResult{
+		{Component: NameMongo, Extra: map[string]string{"database": "openim", "version": "7.0.5"}},
+		{Component: NameRedis, Extra: map[string]string{"version": "5.0.14"}},
+		{Component: NameKafka, Extra: map[string]string{"version:b:9092": "2.8", "version:a:9092": "1.1"}},
+		{Component: NameKafka, Err: errors.New("unreachable")},
+	})
+	want := []struct {
+		component, message string
+		status             Status
+	}{
+		{NameMongo, "7.0.5", StatusOK},
+		{NameRedis, "5.0.14 is older than the minimum supported 6.0", StatusError},
+		{NameKafka, "a:9092: 1.1 is older than the minimum supported 2.0", StatusError},
+		{NameKafka, "b:9092: 2.8", StatusOK},
+	}
+	if len(r.Findings) != len(want) {
+		t.Fatalf("findings = %+v", r.Findings)
+	}
+	for i, w := range want {
+		f := r.Findings[i]
+		if f.Component != w.component || f.Check != "version" || f.Status != w.status || f.Message != w.message {
+			t.Errorf("finding %d = %+v, want %+v", i, f, w)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"context"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+)
+
+type waitOptions struct {
+	report *ReportTarget
+}
+
+type WaitOption func(*waitOptions)
+
+// WithWaitReport persists the CheckReport of the run, with the error of every
+// attempt, to target, whether it succeeds or not.
+func WithWaitReport(target ReportTarget) WaitOption {
+	return func(o *waitOptions) {
+		o.report = &target
+	}
+}
+
+// WaitFor calls check every interval until it succeeds. When ctx is done
+// first, the last error of check is returned.
+func WaitFor(ctx context.Context, interval time.Duration, check func(ctx context.Context) error, opts ...WaitOption) (err error) {
+	var o waitOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var rec *reportRecorder
+	if o.report != nil {
+		rec = newReportRecorder(*o.report)
+		defer func() { rec.finish(ctx, err) }()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := check(ctx)
+		if rec != nil {
+			rec.addAttempt(start, err, nil)
+		}
+		if err == nil {
+			return nil
+		}
+		log.ZWarn(ctx, "component not ready, retrying", err, "attempt", attempt, "interval", interval)
+		select {
+		case <-ctx.Done():
+			return errs.WrapMsg(err, "component not ready", "attempts", attempt, "ctxErr", ctx.Err().Error())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+func TestWaitFor(t *testing.T) {
+	calls := 0
+	err := WaitFor(context.Background(), time.Millisecond, func(context.Context) error {
+		if calls++; calls < 3 {
+			return errs.ErrDependencyUnavailable.WrapMsg("not yet")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("WaitFor = %v after %d calls, want success after 3", err, calls)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = WaitFor(ctx, time.Millisecond, func(context.Context) error {
+		return errs.ErrDependencyUnavailable.WrapMsg("never")
+	})
+	if !errors.Is(err, errs.ErrDependencyUnavailable) {
+		t.Fatalf("WaitFor after timeout = %v, want the last check error", err)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// SchemaDraft is the JSON Schema dialect emitted by JSONSchema.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema describes the YAML structure of the struct v as a JSON Schema,
+// for validation by external tools and IDE autocompletion. Property names
+// follow the yaml tags, nested structs are defined once under $defs, and
+// the following tags are honored:
+//
+//	validate:"required,oneof=a b"	required field, allowed values
+//	default:"10"			default value
+//	description:"..."		description
+//
+// Durations accept both a Go duration string and an integer of nanoseconds,
+// as the YAML decoders do.
+func JSONSchema(v any) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errs.ErrArgs.WrapMsg("JSONSchema needs a struct", "type", reflect.TypeOf(v))
+	}
+	g := &schemaGenerator{defs: make(map[string]map[string]any), names: make(map[reflect.Type]string)}
+	root, err := g.structSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	root["$schema"] = SchemaDraft
+	root["title"] = t.String()
+	if len(g.defs) > 0 {
+		root["$defs"] = g.defs
+	}
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, errs.WrapMsg(err, "failed to marshal JSON schema", "type", t.String())
+	}
+	return append(data, '\n'), nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+type schemaGenerator struct {
+	defs  map[string]map[string]any
+	names map[reflect.Type]string
+}
+
+// ref returns a reference to the definition of the struct t, adding it to
+// defs the first time.
+func (g *schemaGenerator) ref(t reflect.Type) (map[string]any, error) {
+	name, ok := g.names[t]
+	if !ok {
+		// Qualified by package, as several packages define a Config.
+		name = t.String()
+		g.names[t] = name
+		schema, err := g.structSchema(t)
+		if err != nil {
+			return nil, err
+		}
+		g.defs[name] = schema
+	}
+	return map[string]any{"$ref": "#/$defs/" + name}, nil
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) (map[string]any, error) {
+	properties := make(map[string]any)
+	var required []string
+	if err := g.addFields(t, properties, &required); err != nil {
+		return nil, err
+	}
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+// addFields adds the fields of the struct t to properties, inlining the
+// structs tagged ",inline".
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]any, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if err := g.addFields(ft, properties, required); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		schema, err := g.fieldSchema(f)
+		if err != nil {
+			return err
+		}
+		properties[name] = schema
+		if hasRule(f.Tag.Get("validate"), "required") {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
+
+func (g *schemaGenerator) fieldSchema(f reflect.StructField) (any, error) {
+	schema, err := g.typeSchema(f.Type)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "unsupported field", "field", f.Name)
+	}
+	description, hasDefault := f.Tag.Get("description"), f.Tag.Get("default") != ""
+	enum := oneOf(f.Tag.Get("validate"))
+	if description == "" && !hasDefault && enum == nil {
+		return schema, nil
+	}
+	// Keywords next to $ref apply as well in draft 2020-12.
+	if description != "" {
+		schema["description"] = description
+	}
+	if hasDefault {
+		schema["default"] = schemaValue(f.Type, f.Tag.Get("default"))
+	}
+	if enum != nil {
+		values := make([]any, len(enum))
+		for i, e := range enum {
+			values[i] = schemaValue(f.Type, e)
+		}
+		schema["enum"] = values
+	}
+	return schema, nil
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) (map[string]any, error) {
+	if t == durationType {
+		return map[string]any{"type": []string{"string", "integer"}, "description": "a duration, such as 1.5s or 300ms"}, nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, errs.ErrArgs.WrapMsg("map keys must be strings", "type", t.String())
+		}
+		values, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return g.ref(t)
+	default:
+		return nil, errs.ErrArgs.WrapMsg("type has no JSON schema", "type", t.String())
+	}
+}
+
+// hasRule reports whether the validate tag holds rule.
+func hasRule(tag, rule string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// oneOf returns the values of the oneof rule of the validate tag, or nil.
+func oneOf(tag string) []string {
+	for _, r := range strings.Split(tag, ",") {
+		if values, ok := strings.CutPrefix(r, "oneof="); ok {
+			return strings.Fields(values)
+		}
+	}
+	return nil
+}
+
+// schemaValue converts the tag value s to the JSON type of t, keeping it as
+// a string when it does not parse.
+func schemaValue(t reflect.Type, s string) any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return s
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
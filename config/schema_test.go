@@ -0,0 +1,101 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type schemaTLS struct {
+	Enable bool   `yaml:"enable"`
+	CA     string `yaml:"ca" description:"path to the CA certificate"`
+}
+
+type schemaNode struct {
+	Name     string        `yaml:"name"`
+	Children []*schemaNode `yaml:"children"`
+}
+
+type schemaBase struct {
+	Zone string `yaml:"zone"`
+}
+
+type schemaSample struct {
+	schemaBase `yaml:",inline"`
+	Addr       []string          `yaml:"addr" validate:"required"`
+	Mode       string            `yaml:"mode" validate:"oneof=standalone cluster" default:"standalone"`
+	Port       int               `yaml:"port" default:"6379"`
+	Retries    uint8             `yaml:"retries"`
+	Timeout    time.Duration     `yaml:"timeout" default:"3s"`
+	Ratio      float64           `yaml:"ratio"`
+	TLS        *schemaTLS        `yaml:"tls" description:"TLS settings"`
+	Labels     map[string]string `yaml:"labels"`
+	Tree       schemaNode        `yaml:"tree"`
+	Untagged   bool
+	Ignored    string `yaml:"-"`
+	internal   string
+}
+
+func TestJSONSchema(t *testing.T) {
+	data, err := JSONSchema(&schemaSample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Schema     string                     `json:"$schema"`
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Schema != SchemaDraft || len(schema.Required) != 1 || schema.Required[0] != "addr" {
+		t.Fatalf("schema = %s", data)
+	}
+	want := map[string]string{
+		"zone":     `{"type":"string"}`,
+		"addr":     `{"items":{"type":"string"},"type":"array"}`,
+		"mode":     `{"default":"standalone","enum":["standalone","cluster"],"type":"string"}`,
+		"port":     `{"default":6379,"type":"integer"}`,
+		"retries":  `{"minimum":0,"type":"integer"}`,
+		"timeout":  `{"default":"3s","description":"a duration, such as 1.5s or 300ms","type":["string","integer"]}`,
+		"ratio":    `{"type":"number"}`,
+		"tls":      `{"$ref":"#/$defs/config.schemaTLS","description":"TLS settings"}`,
+		"labels":   `{"additionalProperties":{"type":"string"},"type":"object"}`,
+		"tree":     `{"$ref":"#/$defs/config.schemaNode"}`,
+		"untagged": `{"type":"boolean"}`,
+	}
+	if len(schema.Properties) != len(want) {
+		t.Errorf("%d properties, want %d: %s", len(schema.Properties), len(want), data)
+	}
+	for name, w := range want {
+		got, err := json.Marshal(schema.Properties[name])
+		if err != nil || compactJSON(t, got) != w {
+			t.Errorf("%s = %s, want %s", name, got, w)
+		}
+	}
+	if got := compactJSON(t, schema.Defs["config.schemaNode"]); got != `{"additionalProperties":false,"properties":{"children":{"items":{"$ref":"#/$defs/config.schemaNode"},"type":"array"},"name":{"type":"string"}},"type":"object"}` {
+		t.Errorf("recursive definition = %s", got)
+	}
+	if len(schema.Defs) != 2 {
+		t.Errorf("$defs = %v", schema.Defs)
+	}
+
+	if _, err := JSONSchema("not a struct"); err == nil {
+		t.Error("schema of a string")
+	}
+	if _, err := JSONSchema(struct{ C chan int }{}); err == nil {
+		t.Error("schema of a channel")
+	}
+}
+
+func compactJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
@@ -0,0 +1,293 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+)
+
+// Sources reported for a tunable value.
+const (
+	SourceDefault = "default"
+	SourceConfig  = "config"
+	SourceRuntime = "runtime"
+)
+
+// maxTunablesBody limits the size of a PATCH request body.
+const maxTunablesBody = 1 << 20
+
+// DefaultTunables is the registry used by RegisterTunable callers that do not
+// manage their own and served by TunablesHandler.
+var DefaultTunables = NewTunableRegistry()
+
+// TunableState is the GET representation of a single tunable.
+type TunableState struct {
+	Name      string `json:"name"`
+	Value     any    `json:"value"`
+	Default   any    `json:"default"`
+	Source    string `json:"source"`
+	Ephemeral bool   `json:"ephemeral"`
+}
+
+// TunableChange describes a value replaced by a PATCH request.
+type TunableChange struct {
+	Name string
+	Old  any
+	New  any
+}
+
+type tunable interface {
+	state() TunableState
+	// decode parses and validates raw without applying it.
+	decode(raw json.RawMessage) (any, error)
+	// swap stores a value returned by decode and returns the previous one.
+	swap(v any, source string) any
+}
+
+// Tunable is a configuration value that may be changed at runtime.
+// Reads are lock-free, so Get can be used on hot paths.
+type Tunable[T any] struct {
+	name     string
+	def      T
+	validate func(T) error
+	value    atomic.Pointer[T]
+	source   atomic.Value
+	registry *TunableRegistry
+}
+
+// Get returns the current value.
+func (t *Tunable[T]) Get() T {
+	return *t.value.Load()
+}
+
+// Name returns the name the tunable was registered with.
+func (t *Tunable[T]) Name() string {
+	return t.name
+}
+
+// Init sets the value loaded from the configuration file. It is validated like
+// a runtime update but does not trigger change callbacks.
+func (t *Tunable[T]) Init(v T) error {
+	if t.validate != nil {
+		if err := t.validate(v); err != nil {
+			return errs.ErrArgs.WrapMsg(err.Error(), "tunable", t.name)
+		}
+	}
+	t.registry.update.Lock()
+	defer t.registry.update.Unlock()
+	t.registry.lock.Lock()
+	defer t.registry.lock.Unlock()
+	t.swap(v, SourceConfig)
+	return nil
+}
+
+func (t *Tunable[T]) state() TunableState {
+	source := t.source.Load().(string)
+	return TunableState{
+		Name:      t.name,
+		Value:     t.Get(),
+		Default:   t.def,
+		Source:    source,
+		Ephemeral: source == SourceRuntime,
+	}
+}
+
+func (t *Tunable[T]) decode(raw json.RawMessage) (any, error) {
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, errs.ErrArgs.WrapMsg("invalid value type", "tunable", t.name, "err", err.Error())
+	}
+	if t.validate != nil {
+		if err := t.validate(v); err != nil {
+			return nil, errs.ErrArgs.WrapMsg(err.Error(), "tunable", t.name)
+		}
+	}
+	return v, nil
+}
+
+func (t *Tunable[T]) swap(v any, source string) any {
+	val := v.(T)
+	old := t.value.Swap(&val)
+	t.source.Store(source)
+	return *old
+}
+
+// TunableRegistry holds the set of runtime tunable values of a process.
+type TunableRegistry struct {
+	// update serializes the updates with their OnChange callbacks, while lock
+	// only guards the fields below, so that callbacks can read the registry.
+	update    sync.Mutex
+	lock      sync.Mutex
+	tunables  map[string]tunable
+	listeners []ChangeFunc
+}
+
+func NewTunableRegistry() *TunableRegistry {
+	return &TunableRegistry{tunables: make(map[string]tunable)}
+}
+
+// RegisterTunable declares a runtime-tunable value with its default and an
+// optional validator. Names must be unique within a registry.
+func RegisterTunable[T any](r *TunableRegistry, name string, def T, validate func(T) error) (*Tunable[T], error) {
+	if name == "" {
+		return nil, errs.ErrArgs.WrapMsg("tunable name is empty")
+	}
+	if validate != nil {
+		if err := validate(def); err != nil {
+			return nil, errs.ErrArgs.WrapMsg("invalid default: "+err.Error(), "tunable", name)
+		}
+	}
+	t := &Tunable[T]{name: name, def: def, validate: validate, registry: r}
+	t.value.Store(&def)
+	t.source.Store(SourceDefault)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.tunables[name]; ok {
+		return nil, errs.ErrDuplicateKey.WrapMsg("tunable already registered", "tunable", name)
+	}
+	r.tunables[name] = t
+	return t, nil
+}
+
+// OnChange registers fn to be called on every update with a JSON object of the
+// name to new value of each tunable it changes, the same shape as a PATCH
+// body. Callbacks run in registration order and are serialized with updates.
+// The new values are visible through Get while fn runs; an error rolls the
+// whole update back, so fn can reject it like a Watch callback. fn may read
+// the registry, through States or a GET of Handler, but must not update it:
+// Apply and Init wait for fn to return.
+func (r *TunableRegistry) OnChange(fn ChangeFunc) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// States returns all registered tunables sorted by name.
+func (r *TunableRegistry) States() []TunableState {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	states := make([]TunableState, 0, len(r.tunables))
+	for _, t := range r.tunables {
+		states = append(states, t.state())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// Apply validates every value in updates and, only if all of them are valid
+// and accepted by the OnChange callbacks, applies them as a single change.
+// Unknown names are rejected.
+func (r *TunableRegistry) Apply(updates map[string]json.RawMessage) ([]TunableChange, error) {
+	if len(updates) == 0 {
+		return nil, errs.ErrArgs.WrapMsg("no tunable values given")
+	}
+	r.update.Lock()
+	defer r.update.Unlock()
+	values, err := r.decode(updates)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "marshal tunable change failed")
+	}
+	changes := make([]TunableChange, 0, len(values))
+	sources := make([]string, 0, len(values))
+	r.lock.Lock()
+	for name, v := range values {
+		t := r.tunables[name]
+		sources = append(sources, t.state().Source)
+		changes = append(changes, TunableChange{Name: name, Old: t.swap(v, SourceRuntime), New: v})
+	}
+	listeners := append([]ChangeFunc(nil), r.listeners...)
+	r.lock.Unlock()
+	for _, fn := range listeners {
+		if err := fn(data); err != nil {
+			r.lock.Lock()
+			for i, c := range changes {
+				r.tunables[c.Name].swap(c.Old, sources[i])
+			}
+			r.lock.Unlock()
+			return nil, errs.WrapMsg(err, "tunable change rejected")
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes, nil
+}
+
+// decode validates every value in updates and returns them by name.
+func (r *TunableRegistry) decode(updates map[string]json.RawMessage) (map[string]any, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	values := make(map[string]any, len(updates))
+	for name, raw := range updates {
+		t, ok := r.tunables[name]
+		if !ok {
+			return nil, errs.ErrArgs.WrapMsg("field is not tunable", "name", name)
+		}
+		v, err := t.decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+// Handler serves the registry over HTTP. GET lists all tunables, PATCH takes a
+// JSON object of name to new value. Runtime changes are kept in memory only.
+// The handler performs no authentication: mount it behind mw.RequireAdmin, as
+// mw.TunablesHandlers does.
+func (r *TunableRegistry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+			apiresp.HttpSuccess(w, r.States())
+		case http.MethodPatch:
+			body, err := io.ReadAll(io.LimitReader(req.Body, maxTunablesBody))
+			if err != nil {
+				apiresp.HttpError(w, errs.ErrArgs.WrapMsg("read body failed", "err", err.Error()))
+				return
+			}
+			var updates map[string]json.RawMessage
+			if err := json.Unmarshal(body, &updates); err != nil {
+				apiresp.HttpError(w, errs.ErrArgs.WrapMsg("body must be a JSON object", "err", err.Error()))
+				return
+			}
+			if _, err := r.Apply(updates); err != nil {
+				apiresp.HttpError(w, err)
+				return
+			}
+			apiresp.HttpSuccess(w, r.States())
+		default:
+			w.Header().Set("Allow", "GET, PATCH")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// TunablesHandler returns the Handler of DefaultTunables.
+func TunablesHandler() http.Handler {
+	return DefaultTunables.Handler()
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type tunablesResp struct {
+	ErrCode int            `json:"errCode"`
+	ErrMsg  string         `json:"errMsg"`
+	Data    []TunableState `json:"data"`
+}
+
+func patchTunables(t *testing.T, h http.Handler, body string) tunablesResp {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/tunables", strings.NewReader(body)))
+	var resp tunablesResp
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+func newTestRegistry(t *testing.T) (*TunableRegistry, *Tunable[string], *Tunable[int]) {
+	r := NewTunableRegistry()
+	level, err := RegisterTunable(r, "log.level", "info", func(v string) error {
+		switch v {
+		case "debug", "info", "warn", "error":
+			return nil
+		}
+		return errors.New("unknown log level")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rate, err := RegisterTunable(r, "rateLimit.qps", 100, func(v int) error {
+		if v <= 0 {
+			return errors.New("qps must be positive")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, level, rate
+}
+
+func TestTunablesGet(t *testing.T) {
+	r, level, _ := newTestRegistry(t)
+	if err := level.Init("warn"); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tunables", nil))
+	var resp tunablesResp
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 2 {
+		t.Fatalf("got %d tunables, want 2", len(resp.Data))
+	}
+	got := resp.Data[0]
+	if got.Name != "log.level" || got.Value != "warn" || got.Default != "info" || got.Source != SourceConfig || got.Ephemeral {
+		t.Errorf("unexpected state %+v", got)
+	}
+	if resp.Data[1].Source != SourceDefault {
+		t.Errorf("rateLimit.qps source = %s, want %s", resp.Data[1].Source, SourceDefault)
+	}
+}
+
+func TestTunablesPatch(t *testing.T) {
+	r, level, rate := newTestRegistry(t)
+	var notified []map[string]any
+	r.OnChange(func(data []byte) error {
+		var changes map[string]any
+		if err := json.Unmarshal(data, &changes); err != nil {
+			return err
+		}
+		notified = append(notified, changes)
+		return nil
+	})
+
+	resp := patchTunables(t, r.Handler(), `{"log.level":"debug","rateLimit.qps":500}`)
+	if resp.ErrCode != 0 {
+		t.Fatalf("patch failed: %d %s", resp.ErrCode, resp.ErrMsg)
+	}
+	if level.Get() != "debug" || rate.Get() != 500 {
+		t.Fatalf("values not applied: %s %d", level.Get(), rate.Get())
+	}
+	for _, s := range resp.Data {
+		if s.Source != SourceRuntime || !s.Ephemeral {
+			t.Errorf("%s: source=%s ephemeral=%v", s.Name, s.Source, s.Ephemeral)
+		}
+	}
+	if len(notified) != 1 || notified[0]["log.level"] != "debug" || notified[0]["rateLimit.qps"] != 500.0 {
+		t.Errorf("unexpected notifications %+v", notified)
+	}
+}
+
+func TestTunablesPatchRejectedByCallback(t *testing.T) {
+	r, level, rate := newTestRegistry(t)
+	if err := level.Init("warn"); err != nil {
+		t.Fatal(err)
+	}
+	r.OnChange(func(data []byte) error {
+		if level.Get() != "debug" {
+			t.Errorf("callback sees log.level %s, want the new value", level.Get())
+		}
+		return errors.New("rejected")
+	})
+
+	resp := patchTunables(t, r.Handler(), `{"log.level":"debug","rateLimit.qps":500}`)
+	if resp.ErrCode == 0 {
+		t.Fatal("patch rejected by the callback accepted")
+	}
+	if level.Get() != "warn" || rate.Get() != 100 {
+		t.Errorf("rejected patch not rolled back: %s %d", level.Get(), rate.Get())
+	}
+	for _, s := range r.States() {
+		if s.Source == SourceRuntime {
+			t.Errorf("%s: source %s after rollback", s.Name, s.Source)
+		}
+	}
+}
+
+func TestTunablesCallbackReadsRegistry(t *testing.T) {
+	r, _, _ := newTestRegistry(t)
+	var audit []TunableState
+	r.OnChange(func(data []byte) error {
+		audit = r.States()
+		return nil
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Apply(map[string]json.RawMessage{"log.level": json.RawMessage(`"debug"`)})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback reading the registry deadlocked")
+	}
+	for _, s := range audit {
+		if s.Name == "log.level" && (s.Value != "debug" || s.Source != SourceRuntime) {
+			t.Errorf("callback saw %+v", s)
+		}
+	}
+	if len(audit) == 0 {
+		t.Error("callback not called")
+	}
+}
+
+func TestTunablesPatchRejected(t *testing.T) {
+	r, level, rate := newTestRegistry(t)
+	calls := 0
+	r.OnChange(func([]byte) error {
+		calls++
+		return nil
+	})
+
+	cases := map[string]string{
+		"validation":  `{"log.level":"debug","rateLimit.qps":-1}`,
+		"type":        `{"rateLimit.qps":"fast"}`,
+		"non-tunable": `{"log.level":"debug","mongo.uri":"mongodb://evil"}`,
+		"not object":  `["log.level"]`,
+		"empty":       `{}`,
+	}
+	for name, body := range cases {
+		t.Run(name, func(t *testing.T) {
+			resp := patchTunables(t, r.Handler(), body)
+			if resp.ErrCode == 0 {
+				t.Fatalf("patch %s accepted", body)
+			}
+		})
+	}
+	if level.Get() != "info" || rate.Get() != 100 {
+		t.Errorf("rejected patch partially applied: %s %d", level.Get(), rate.Get())
+	}
+	if calls != 0 {
+		t.Errorf("change callback called %d times", calls)
+	}
+}
+
+func TestTunablesConcurrentPatch(t *testing.T) {
+	r, _, rate := newTestRegistry(t)
+	var (
+		lock sync.Mutex
+		last int
+		seen int
+	)
+	r.OnChange(func(data []byte) error {
+		lock.Lock()
+		defer lock.Unlock()
+		var changes map[string]int
+		if err := json.Unmarshal(data, &changes); err != nil {
+			return err
+		}
+		// Updates are serialized, so no other one may have replaced the value yet.
+		if got := rate.Get(); got != changes["rateLimit.qps"] {
+			t.Errorf("callback for %d sees %d", changes["rateLimit.qps"], got)
+		}
+		last = changes["rateLimit.qps"]
+		seen++
+		return nil
+	})
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if resp := patchTunables(t, r.Handler(), fmt.Sprintf(`{"rateLimit.qps":%d}`, i)); resp.ErrCode != 0 {
+				t.Errorf("patch %d failed: %s", i, resp.ErrMsg)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if seen != n {
+		t.Errorf("got %d notifications, want %d", seen, n)
+	}
+	if rate.Get() != last {
+		t.Errorf("final value %d, last notified %v", rate.Get(), last)
+	}
+}
+
+func TestRegisterTunableDuplicate(t *testing.T) {
+	r, _, _ := newTestRegistry(t)
+	if _, err := RegisterTunable(r, "log.level", "info", nil); err == nil {
+		t.Fatal("duplicate registration accepted")
+	}
+	if _, err := RegisterTunable(r, "bad", 0, func(v int) error { return errors.New("never valid") }); err == nil {
+		t.Fatal("invalid default accepted")
+	}
+}
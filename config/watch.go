@@ -0,0 +1,71 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+)
+
+// ChangeFunc is called with new configuration content: by Watch when a source
+// changes and by TunableRegistry when tunables are updated at runtime. An
+// error rejects the change.
+type ChangeFunc func(data []byte) error
+
+// Watch polls source every interval and calls onChange with the new content
+// each time it differs from the previous read. The first read only records the
+// baseline: callers load the initial configuration themselves. Read errors and
+// errors returned by onChange are logged and the previous content is kept, so
+// a rejected change is retried only once the source changes again. Watch
+// blocks until ctx is done.
+func Watch(ctx context.Context, source ConfigSource, interval time.Duration, onChange ChangeFunc) error {
+	if interval <= 0 {
+		return errs.ErrArgs.WrapMsg("watch interval must be positive", "interval", interval)
+	}
+	last, err := source.Read()
+	failing := err != nil
+	if failing {
+		log.ZWarn(ctx, "config watch read failed", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		data, err := source.Read()
+		if err != nil {
+			if !failing {
+				log.ZWarn(ctx, "config watch read failed", err)
+			}
+			failing = true
+			continue
+		}
+		failing = false
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		if err := onChange(data); err != nil {
+			log.ZWarn(ctx, "config change rejected", err)
+		}
+	}
+}
@@ -17,6 +17,8 @@ package mongoutil
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -27,20 +29,31 @@ const (
 	defaultMaxRetry    = 3
 )
 
+// buildMongoURI builds the URI of config, with the credentials, the database
+// and the options percent-encoded as the connection string format requires.
+// The options are in a fixed order: authSource, maxPoolSize, replicaSet and
+// directConnection.
 func buildMongoURI(config *Config, authSource string) string {
+	// An empty user info is rejected by the driver, so "@" is only written
+	// with credentials.
 	credentials := ""
-
 	if config.Username != "" && config.Password != "" {
-		credentials = fmt.Sprintf("%s:%s", config.Username, config.Password)
+		credentials = url.UserPassword(config.Username, config.Password).String() + "@"
 	}
 
+	query := "authSource=" + url.QueryEscape(authSource) + "&maxPoolSize=" + strconv.Itoa(config.MaxPoolSize)
+	if config.ReplicaSet != "" {
+		query += "&replicaSet=" + url.QueryEscape(config.ReplicaSet)
+	}
+	if config.DirectConnection {
+		query += "&directConnection=true"
+	}
 	return fmt.Sprintf(
-		"mongodb://%s@%s/%s?authSource=%s&maxPoolSize=%d",
+		"mongodb://%s%s/%s?%s",
 		credentials,
 		strings.Join(config.Address, ","),
-		config.Database,
-		authSource,
-		config.MaxPoolSize,
+		url.PathEscape(config.Database),
+		query,
 	)
 }
 
@@ -19,8 +19,10 @@ import (
 	"time"
 
 	"github.com/openimsdk/tools/db/tx"
+	"github.com/openimsdk/tools/env"
 	"github.com/openimsdk/tools/errs"
 	"github.com/openimsdk/tools/mw/specialerror"
+	"github.com/openimsdk/tools/utils/tlsutil"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -41,6 +43,33 @@ type Config struct {
 	AuthSource  string
 	MaxPoolSize int
 	MaxRetry    int
+	TLS         tlsutil.Config
+	// ReplicaSet is the name of the replica set to connect to, such as rs0.
+	ReplicaSet string
+	// DirectConnection connects to the single Address only, without
+	// discovering the other members, for diagnosis.
+	DirectConnection bool
+}
+
+// ApplyEnv overrides the URI with the MONGO_URI environment variable, when
+// set. The URI wins over Address, Username, Password and AuthSource.
+func (c *Config) ApplyEnv() {
+	c.Uri = env.GetString("MONGO_URI", c.Uri)
+}
+
+// ClientOptions returns the options of a client connecting as configured,
+// with the TLS configuration applied over the tls options of the URI. It must
+// be called after ValidateAndSetDefaults.
+func (c *Config) ClientOptions() (*options.ClientOptions, error) {
+	opts := options.Client().ApplyURI(c.Uri).SetMaxPoolSize(uint64(c.MaxPoolSize))
+	if c.TLS.EnableTLS {
+		conf, err := c.TLS.Load()
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(conf)
+	}
+	return opts, nil
 }
 
 type Client struct {
@@ -61,11 +90,11 @@ func NewMongoDB(ctx context.Context, config *Config) (*Client, error) {
 	if err := config.ValidateAndSetDefaults(); err != nil {
 		return nil, err
 	}
-	opts := options.Client().ApplyURI(config.Uri).SetMaxPoolSize(uint64(config.MaxPoolSize))
-	var (
-		cli *mongo.Client
-		err error
-	)
+	opts, err := config.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	var cli *mongo.Client
 	for i := 0; i < config.MaxRetry; i++ {
 		cli, err = connectMongo(ctx, opts)
 		if err != nil && shouldRetry(ctx, err) {
@@ -0,0 +1,279 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongoutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/openimsdk/tools/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Kinds of SchemaViolation.
+const (
+	SchemaMissingCollection = "missing_collection"
+	SchemaMissingIndex      = "missing_index"
+	SchemaIndexMismatch     = "index_mismatch"
+)
+
+// IndexExpectation is an index a collection must have. Keys is the key
+// pattern in order, e.g. bson.D{{"user_id", 1}, {"create_time", -1}}.
+type IndexExpectation struct {
+	// Name is optional. When set, the index found under that name must have
+	// the expected keys.
+	Name   string
+	Keys   bson.D
+	Unique bool
+}
+
+// CollectionExpectation lists the indexes a collection must have.
+type CollectionExpectation struct {
+	Collection string
+	Indexes    []IndexExpectation
+}
+
+// SchemaViolation is a difference between the expected and the actual schema.
+type SchemaViolation struct {
+	Kind       string
+	Collection string
+	// Index is the key pattern of the expected index, e.g. "user_id_1_create_time_-1".
+	Index  string
+	Detail string
+}
+
+func (v *SchemaViolation) Error() string {
+	var b strings.Builder
+	b.WriteString("mongo schema: ")
+	b.WriteString(v.Kind)
+	b.WriteString(", collection=" + v.Collection)
+	if v.Index != "" {
+		b.WriteString(", index=" + v.Index)
+	}
+	if v.Detail != "" {
+		b.WriteString(", " + v.Detail)
+	}
+	return b.String()
+}
+
+type actualIndex struct {
+	Name   string `bson:"name"`
+	Key    bson.D `bson:"key"`
+	Unique bool   `bson:"unique"`
+}
+
+// ValidateSchema checks that every expected collection exists with the
+// expected indexes. Compound keys are compared in order, and the unique flag
+// must match. All violations are returned together in an *errs.MultiError
+// whose elements are *SchemaViolation.
+func ValidateSchema(ctx context.Context, db *mongo.Database, expectations []CollectionExpectation) error {
+	violations, err := schemaViolations(ctx, db, expectations)
+	if err != nil {
+		return err
+	}
+	return violations.ErrorOrNil()
+}
+
+// EnsureIndexes creates the expected indexes that are missing, and with them
+// the missing collections, using background builds. Mismatched indexes are
+// not changed and are returned like ValidateSchema does.
+func EnsureIndexes(ctx context.Context, db *mongo.Database, expectations []CollectionExpectation) error {
+	violations, err := schemaViolations(ctx, db, expectations)
+	if err != nil {
+		return err
+	}
+	remaining := &errs.MultiError{}
+	created := make(map[string]bool)
+	for _, e := range violations.Errors {
+		v := e.(*SchemaViolation)
+		if v.Kind == SchemaIndexMismatch {
+			remaining.Append(v)
+			continue
+		}
+		if created[v.Collection] {
+			continue
+		}
+		created[v.Collection] = true
+		if err := createMissingIndexes(ctx, db, expectations, violations, v.Collection); err != nil {
+			return err
+		}
+	}
+	return remaining.ErrorOrNil()
+}
+
+func createMissingIndexes(ctx context.Context, db *mongo.Database, expectations []CollectionExpectation, violations *errs.MultiError, collection string) error {
+	missing := make(map[string]bool)
+	for _, e := range violations.Errors {
+		v := e.(*SchemaViolation)
+		if v.Collection != collection {
+			continue
+		}
+		// A missing collection lacks all of its indexes.
+		if v.Kind == SchemaMissingCollection {
+			missing[""] = true
+		} else if v.Kind == SchemaMissingIndex {
+			missing[v.Index] = true
+		}
+	}
+	var models []mongo.IndexModel
+	for _, exp := range expectations {
+		if exp.Collection != collection {
+			continue
+		}
+		for _, idx := range exp.Indexes {
+			if !missing[""] && !missing[keyString(idx.Keys)] {
+				continue
+			}
+			opts := options.Index().SetBackground(true)
+			if idx.Unique {
+				opts.SetUnique(true)
+			}
+			if idx.Name != "" {
+				opts.SetName(idx.Name)
+			}
+			models = append(models, mongo.IndexModel{Keys: idx.Keys, Options: opts})
+		}
+	}
+	if len(models) == 0 {
+		if missing[""] {
+			if err := db.CreateCollection(ctx, collection); err != nil {
+				return errs.WrapMsg(err, "create collection failed", "collection", collection)
+			}
+		}
+		return nil
+	}
+	if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+		return errs.WrapMsg(err, "create indexes failed", "collection", collection)
+	}
+	return nil
+}
+
+func schemaViolations(ctx context.Context, db *mongo.Database, expectations []CollectionExpectation) (*errs.MultiError, error) {
+	names, err := db.ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return nil, errs.WrapMsg(err, "list collections failed", "database", db.Name())
+	}
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[name] = true
+	}
+	violations := &errs.MultiError{}
+	for _, exp := range expectations {
+		if !existing[exp.Collection] {
+			violations.Append(&SchemaViolation{Kind: SchemaMissingCollection, Collection: exp.Collection})
+			continue
+		}
+		cur, err := db.Collection(exp.Collection).Indexes().List(ctx)
+		if err != nil {
+			return nil, errs.WrapMsg(err, "list indexes failed", "collection", exp.Collection)
+		}
+		var actual []actualIndex
+		if err := cur.All(ctx, &actual); err != nil {
+			return nil, errs.WrapMsg(err, "decode indexes failed", "collection", exp.Collection)
+		}
+		for _, idx := range exp.Indexes {
+			if v := compareIndex(exp.Collection, idx, actual); v != nil {
+				violations.Append(v)
+			}
+		}
+	}
+	return violations, nil
+}
+
+func compareIndex(collection string, idx IndexExpectation, actual []actualIndex) *SchemaViolation {
+	key := keyString(idx.Keys)
+	mismatch := func(format string, args ...any) *SchemaViolation {
+		return &SchemaViolation{Kind: SchemaIndexMismatch, Collection: collection, Index: key, Detail: fmt.Sprintf(format, args...)}
+	}
+	for _, a := range actual {
+		if idx.Name != "" && a.Name == idx.Name && !sameKeys(idx.Keys, a.Key) {
+			return mismatch("index %s has keys %s", a.Name, keyString(a.Key))
+		}
+	}
+	for _, a := range actual {
+		if !sameKeys(idx.Keys, a.Key) {
+			continue
+		}
+		if a.Unique != idx.Unique {
+			return mismatch("index %s has unique=%t, want %t", a.Name, a.Unique, idx.Unique)
+		}
+		return nil
+	}
+	for _, a := range actual {
+		if sameKeySet(idx.Keys, a.Key) {
+			return mismatch("index %s has the keys in a different order: %s", a.Name, keyString(a.Key))
+		}
+	}
+	return &SchemaViolation{Kind: SchemaMissingIndex, Collection: collection, Index: key}
+}
+
+// keyString formats a key pattern like the default index name.
+func keyString(keys bson.D) string {
+	parts := make([]string, 0, len(keys))
+	for _, e := range keys {
+		parts = append(parts, e.Key+"_"+keyValue(e.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// keyValue formats the direction or type of an index key, so that 1,
+// int32(1) and 1.0 compare equal.
+func keyValue(v any) string {
+	switch n := v.(type) {
+	case int:
+		return strconv.FormatInt(int64(n), 10)
+	case int32:
+		return strconv.FormatInt(int64(n), 10)
+	case int64:
+		return strconv.FormatInt(n, 10)
+	case float64:
+		if n == float64(int64(n)) {
+			return strconv.FormatInt(int64(n), 10)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+func sameKeys(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || keyValue(a[i].Value) != keyValue(b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameKeySet(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	values := make(map[string]string, len(a))
+	for _, e := range a {
+		values[e.Key] = keyValue(e.Value)
+	}
+	for _, e := range b {
+		if v, ok := values[e.Key]; !ok || v != keyValue(e.Value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package mongoutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func listCollections(mt *mtest.T, names ...string) bson.D {
+	docs := make([]bson.D, 0, len(names))
+	for _, name := range names {
+		docs = append(docs, bson.D{{Key: "name", Value: name}, {Key: "type", Value: "collection"}})
+	}
+	return mtest.CreateCursorResponse(0, mt.DB.Name()+".$cmd.listCollections", mtest.FirstBatch, docs...)
+}
+
+func listIndexes(mt *mtest.T, collection string, indexes ...bson.D) bson.D {
+	return mtest.CreateCursorResponse(0, mt.DB.Name()+"."+collection, mtest.FirstBatch, indexes...)
+}
+
+func index(name string, unique bool, keys ...bson.E) bson.D {
+	d := bson.D{{Key: "v", Value: 2}, {Key: "key", Value: bson.D(keys)}, {Key: "name", Value: name}}
+	if unique {
+		d = append(d, bson.E{Key: "unique", Value: true})
+	}
+	return d
+}
+
+func violationKinds(t *testing.T, err error) map[string]string {
+	t.Helper()
+	var multi *errs.MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("err = %v, want *errs.MultiError", err)
+	}
+	kinds := make(map[string]string)
+	for _, e := range multi.Errors {
+		v := e.(*SchemaViolation)
+		kinds[v.Collection+"/"+v.Index] = v.Kind
+	}
+	return kinds
+}
+
+var testExpectations = []CollectionExpectation{
+	{
+		Collection: "msg",
+		Indexes: []IndexExpectation{
+			{Keys: bson.D{{Key: "conversation_id", Value: 1}, {Key: "seq", Value: -1}}, Unique: true},
+			{Keys: bson.D{{Key: "send_time", Value: 1}}},
+		},
+	},
+	{
+		Collection: "user",
+		Indexes:    []IndexExpectation{{Name: "user_id_unique", Keys: bson.D{{Key: "user_id", Value: 1}}, Unique: true}},
+	},
+}
+
+func TestValidateSchema(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("consistent", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			listCollections(mt, "msg", "user"),
+			listIndexes(mt, "msg",
+				index("_id_", false, bson.E{Key: "_id", Value: 1}),
+				index("conversation_id_1_seq_-1", true, bson.E{Key: "conversation_id", Value: int32(1)}, bson.E{Key: "seq", Value: int32(-1)}),
+				index("send_time_1", false, bson.E{Key: "send_time", Value: 1.0})),
+			listIndexes(mt, "user", index("user_id_unique", true, bson.E{Key: "user_id", Value: 1})),
+		)
+		if err := ValidateSchema(context.Background(), mt.DB, testExpectations); err != nil {
+			t.Fatalf("ValidateSchema: %v", err)
+		}
+	})
+
+	mt.Run("violations", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			listCollections(mt, "msg"),
+			listIndexes(mt, "msg",
+				// Compound keys in the wrong order.
+				index("seq_-1_conversation_id_1", true, bson.E{Key: "seq", Value: -1}, bson.E{Key: "conversation_id", Value: 1})),
+		)
+		kinds := violationKinds(t, ValidateSchema(context.Background(), mt.DB, testExpectations))
+		want := map[string]string{
+			"msg/conversation_id_1_seq_-1": SchemaIndexMismatch,
+			"msg/send_time_1":              SchemaMissingIndex,
+			"user/":                        SchemaMissingCollection,
+		}
+		if len(kinds) != len(want) {
+			t.Fatalf("violations = %v, want %v", kinds, want)
+		}
+		for k, v := range want {
+			if kinds[k] != v {
+				t.Errorf("%s: %s, want %s", k, kinds[k], v)
+			}
+		}
+	})
+
+	mt.Run("unique flag", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			listCollections(mt, "msg", "user"),
+			listIndexes(mt, "msg",
+				index("conversation_id_1_seq_-1", false, bson.E{Key: "conversation_id", Value: 1}, bson.E{Key: "seq", Value: -1}),
+				index("send_time_1", true, bson.E{Key: "send_time", Value: 1})),
+			listIndexes(mt, "user", index("user_id_unique", true, bson.E{Key: "uid", Value: 1})),
+		)
+		kinds := violationKinds(t, ValidateSchema(context.Background(), mt.DB, testExpectations))
+		for _, k := range []string{"msg/conversation_id_1_seq_-1", "msg/send_time_1", "user/user_id_1"} {
+			if kinds[k] != SchemaIndexMismatch {
+				t.Errorf("%s: %q, want %s (all: %v)", k, kinds[k], SchemaIndexMismatch, kinds)
+			}
+		}
+	})
+}
+
+func TestEnsureIndexes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("creates missing", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			listCollections(mt, "msg"),
+			listIndexes(mt, "msg",
+				index("conversation_id_1_seq_-1", false, bson.E{Key: "conversation_id", Value: 1}, bson.E{Key: "seq", Value: -1})),
+			mtest.CreateSuccessResponse(), // msg: send_time_1
+			mtest.CreateSuccessResponse(), // user: user_id_unique
+		)
+		kinds := violationKinds(t, EnsureIndexes(context.Background(), mt.DB, testExpectations))
+		if len(kinds) != 1 || kinds["msg/conversation_id_1_seq_-1"] != SchemaIndexMismatch {
+			t.Fatalf("remaining violations = %v, want the unique mismatch only", kinds)
+		}
+		var created []bson.Raw
+		for _, ev := range mt.GetAllStartedEvents() {
+			if ev.CommandName == "createIndexes" {
+				created = append(created, ev.Command)
+			}
+		}
+		if len(created) != 2 {
+			t.Fatalf("createIndexes sent %d times, want 2", len(created))
+		}
+		msgIndexes := mustValues(t, created[0].Lookup("indexes").Array())
+		if len(msgIndexes) != 1 {
+			t.Fatalf("msg createIndexes = %s", created[0])
+		}
+		msgIndex := msgIndexes[0].Document()
+		if keys := msgIndex.Lookup("key").Document(); keyString(mustD(t, keys)) != "send_time_1" {
+			t.Errorf("msg index keys = %s", keys)
+		}
+		if !msgIndex.Lookup("background").Boolean() {
+			t.Errorf("index not built in background: %s", msgIndex)
+		}
+		userIndex := mustValues(t, created[1].Lookup("indexes").Array())[0].Document()
+		if userIndex.Lookup("name").StringValue() != "user_id_unique" || !userIndex.Lookup("unique").Boolean() {
+			t.Errorf("user index = %s", userIndex)
+		}
+	})
+}
+
+func mustValues(t *testing.T, arr bson.Raw) []bson.RawValue {
+	t.Helper()
+	values, err := arr.Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func mustD(t *testing.T, raw bson.Raw) bson.D {
+	t.Helper()
+	var d bson.D
+	if err := bson.Unmarshal(raw, &d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
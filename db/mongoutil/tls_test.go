@@ -0,0 +1,81 @@
+package mongoutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/tlsutil"
+	"github.com/openimsdk/tools/utils/tlsutil/tlstest"
+)
+
+func TestClientOptionsTLS(t *testing.T) {
+	_, _, caPath := tlstest.NewCA(t, t.TempDir(), "mongo-ca")
+	conf := &Config{Address: []string{"mongo-0:27017"}, Database: "openim", TLS: tlsutil.Config{EnableTLS: true, CAFile: caPath}}
+	if err := conf.ValidateAndSetDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := conf.ClientOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.TLSConfig == nil || opts.TLSConfig.RootCAs == nil || opts.TLSConfig.InsecureSkipVerify {
+		t.Fatalf("TLSConfig = %+v", opts.TLSConfig)
+	}
+
+	conf.TLS = tlsutil.Config{}
+	if opts, err := conf.ClientOptions(); err != nil || opts.TLSConfig != nil {
+		t.Fatalf("without TLS: TLSConfig = %+v, %v", opts.TLSConfig, err)
+	}
+}
+
+func TestClientOptionsTLSErrors(t *testing.T) {
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "malformed.pem")
+	if err := os.WriteFile(malformed, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+	for name, tlsConf := range map[string]tlsutil.Config{
+		"missing ca":     {EnableTLS: true, CAFile: missing},
+		"malformed ca":   {EnableTLS: true, CAFile: malformed},
+		"malformed cert": {EnableTLS: true, CertFile: malformed, KeyFile: malformed},
+	} {
+		conf := &Config{Address: []string{"mongo-0:27017"}, Database: "openim", TLS: tlsConf}
+		if err := conf.ValidateAndSetDefaults(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		_, err := conf.ClientOptions()
+		if !errors.Is(err, errs.ErrConfig) {
+			t.Errorf("%s: err = %v, want ErrConfig", name, err)
+		}
+		if file := tlsConf.CAFile + tlsConf.CertFile; err != nil && !strings.Contains(err.Error(), file) {
+			t.Errorf("%s: err = %v, want the file path", name, err)
+		}
+	}
+
+	for name, tlsConf := range map[string]tlsutil.Config{
+		"cert without key": {EnableTLS: true, CertFile: malformed},
+		"tls disabled":     {CAFile: malformed},
+	} {
+		conf := &Config{Address: []string{"mongo-0:27017"}, Database: "openim", TLS: tlsConf}
+		if err := conf.ValidateAndSetDefaults(); !errors.Is(err, errs.ErrConfig) {
+			t.Errorf("%s: err = %v, want ErrConfig", name, err)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("MONGO_URI", "mongodb://atlas.example.com/openim?tls=true")
+	conf := &Config{Address: []string{"mongo-0:27017"}, Database: "openim"}
+	conf.ApplyEnv()
+	if err := conf.ValidateAndSetDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Uri != "mongodb://atlas.example.com/openim?tls=true" {
+		t.Fatalf("uri = %s, want MONGO_URI", conf.Uri)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongoutil
+
+import (
+	"context"
+	"errors"
+
+	"github.com/openimsdk/tools/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Roles of a node in Topology.
+const (
+	RoleStandalone = "standalone"
+	RolePrimary    = "primary"
+	RoleSecondary  = "secondary"
+	RoleMongos     = "mongos"
+	RoleOther      = "other"
+)
+
+// Topology is the deployment as seen by the node answering hello.
+type Topology struct {
+	Role string
+	// SetName is the replica set name, empty for standalone and mongos.
+	SetName string
+	// Primary is the address of the primary known to the node, empty when
+	// the set has none.
+	Primary string
+	Hosts   []string
+	// Version is the server version reported by buildInfo, empty when it
+	// could not be read.
+	Version string
+}
+
+// Err returns an errs.ErrComponentStart wrap when the node is in a replica
+// set without a reachable primary: reads may work, but every write fails.
+func (t *Topology) Err() error {
+	if t.SetName != "" && t.Primary == "" {
+		return errs.ErrComponentStart.WrapMsg("MongoDB replica set has no primary", "replicaSet", t.SetName, "hosts", t.Hosts)
+	}
+	return nil
+}
+
+// commandNotFound is the code of the error returned for unknown commands.
+const commandNotFound = 59
+
+// ReadTopology runs the hello command, or isMaster on servers older than
+// 4.4.2, on any reachable node, as the primary may be missing.
+func ReadTopology(ctx context.Context, client *mongo.Client) (*Topology, error) {
+	var reply struct {
+		IsWritablePrimary bool     `bson:"isWritablePrimary"`
+		IsMaster          bool     `bson:"ismaster"`
+		Secondary         bool     `bson:"secondary"`
+		SetName           string   `bson:"setName"`
+		Primary           string   `bson:"primary"`
+		Hosts             []string `bson:"hosts"`
+		Msg               string   `bson:"msg"`
+	}
+	admin := client.Database("admin")
+	opts := options.RunCmd().SetReadPreference(readpref.Nearest())
+	err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}, opts).Decode(&reply)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == commandNotFound {
+		err = admin.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}, opts).Decode(&reply)
+	}
+	if err != nil {
+		return nil, errs.WrapMsg(err, "MongoDB hello failed")
+	}
+	t := &Topology{SetName: reply.SetName, Primary: reply.Primary, Hosts: reply.Hosts}
+	primary := reply.IsWritablePrimary || reply.IsMaster
+	switch {
+	case reply.Msg == "isdbgrid":
+		t.Role = RoleMongos
+	case reply.SetName == "":
+		t.Role = RoleStandalone
+	case primary:
+		t.Role = RolePrimary
+	case reply.Secondary:
+		t.Role = RoleSecondary
+	default:
+		t.Role = RoleOther
+	}
+	return t, nil
+}
@@ -18,20 +18,34 @@ import (
 	"context"
 
 	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/network"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // CheckMongo tests the MongoDB connection without retries.
 func Check(ctx context.Context, config *Config) error {
+	_, err := CheckTopology(ctx, config)
+	return err
+}
+
+// CheckTopology is Check returning the topology and version reported by the
+// server. The ping goes to any reachable node, so that a replica set without
+// a primary fails with the error of Topology.Err rather than a selection
+// timeout.
+func CheckTopology(ctx context.Context, config *Config) (*Topology, error) {
 	if err := config.ValidateAndSetDefaults(); err != nil {
-		return err
+		return nil, err
 	}
 
-	clientOpts := options.Client().ApplyURI(config.Uri)
+	clientOpts, err := config.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
 	mongoClient, err := mongo.Connect(ctx, clientOpts)
 	if err != nil {
-		return errs.WrapMsg(err, "MongoDB connect failed", "URI", config.Uri, "Database", config.Database, "MaxPoolSize", config.MaxPoolSize)
+		return nil, errs.WrapMsg(err, "MongoDB connect failed", "URI", config.Uri, "Database", config.Database, "MaxPoolSize", config.MaxPoolSize)
 	}
 
 	defer func() {
@@ -40,11 +54,29 @@ func Check(ctx context.Context, config *Config) error {
 		}
 	}()
 
-	if err = mongoClient.Ping(ctx, nil); err != nil {
-		return errs.WrapMsg(err, "MongoDB ping failed", "URI", config.Uri, "Database", config.Database, "MaxPoolSize", config.MaxPoolSize)
+	if err = mongoClient.Ping(ctx, readpref.Nearest()); err != nil {
+		return nil, errs.WrapMsg(err, "MongoDB ping failed", "URI", config.Uri, "Database", config.Database, "MaxPoolSize", config.MaxPoolSize)
 	}
 
-	return nil
+	topology, err := ReadTopology(ctx, mongoClient)
+	if err != nil {
+		return nil, err
+	}
+	if version, err := ServerVersion(ctx, mongoClient); err == nil {
+		topology.Version = version
+	}
+	return topology, topology.Err()
+}
+
+// ServerVersion returns the version reported by the buildInfo command.
+func ServerVersion(ctx context.Context, client *mongo.Client) (string, error) {
+	var info struct {
+		Version string `bson:"version"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&info); err != nil {
+		return "", errs.WrapMsg(err, "MongoDB buildInfo failed")
+	}
+	return info.Version, nil
 }
 
 // ValidateAndSetDefaults validates the configuration and sets default values.
@@ -61,7 +93,18 @@ func (c *Config) ValidateAndSetDefaults() error {
 	if c.MaxRetry <= 0 {
 		c.MaxRetry = defaultMaxRetry
 	}
+	if err := c.TLS.Validate(); err != nil {
+		return err
+	}
 	if c.Uri == "" {
+		addrs, err := network.NormalizeAddrs(c.Address)
+		if err != nil {
+			return err
+		}
+		c.Address = addrs
+		if c.DirectConnection && len(c.Address) > 1 {
+			return errs.ErrConfig.WrapMsg("mongo directConnection needs a single address", "address", c.Address)
+		}
 		// if authSource is not provided, default to database name
 		if c.AuthSource == "" {
 			c.Uri = buildMongoURI(c, c.Database)
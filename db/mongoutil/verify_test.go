@@ -0,0 +1,158 @@
+package mongoutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestValidateAndSetDefaultsAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		address []string
+		uri     string
+	}{
+		{"ipv4", []string{"10.0.0.1:27017"}, "mongodb://10.0.0.1:27017/openim?authSource=openim&maxPoolSize=100"},
+		{"hostname", []string{"mongo-0:27017", "mongo-1:27017"}, "mongodb://mongo-0:27017,mongo-1:27017/openim?authSource=openim&maxPoolSize=100"},
+		{"bracketed ipv6", []string{"[fd00::1]:27017,[fd00::2]:27017"}, "mongodb://[fd00::1]:27017,[fd00::2]:27017/openim?authSource=openim&maxPoolSize=100"},
+	}
+	conf := &Config{Address: []string{"mongo-0:27017"}, Database: "openim", AuthSource: "admin", ReplicaSet: "rs0", DirectConnection: true}
+	if err := conf.ValidateAndSetDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "mongodb://mongo-0:27017/openim?authSource=admin&maxPoolSize=100&replicaSet=rs0&directConnection=true"; conf.Uri != want {
+		t.Fatalf("uri = %s, want %s", conf.Uri, want)
+	}
+	opts := options.Client().ApplyURI(conf.Uri)
+	if opts.Validate() != nil || *opts.ReplicaSet != "rs0" || !*opts.Direct {
+		t.Fatalf("options = %+v", opts)
+	}
+	conf = &Config{Address: []string{"mongo-0:27017", "mongo-1:27017"}, Database: "openim", DirectConnection: true}
+	if err := conf.ValidateAndSetDefaults(); !errors.Is(err, errs.ErrConfig) {
+		t.Fatalf("directConnection with two addresses: err = %v, want ErrConfig", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &Config{Address: tt.address, Database: "openim"}
+			if err := conf.ValidateAndSetDefaults(); err != nil {
+				t.Fatal(err)
+			}
+			if conf.Uri != tt.uri {
+				t.Errorf("uri = %s, want %s", conf.Uri, tt.uri)
+			}
+		})
+	}
+
+	conf = &Config{Address: []string{"fd00::1:27017"}, Database: "openim"}
+	var codeErr errs.CodeError
+	if err := conf.ValidateAndSetDefaults(); !errors.As(err, &codeErr) || codeErr.Code() != errs.ConfigError {
+		t.Fatalf("unbracketed ipv6: err = %v, want ErrConfig", err)
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("buildInfo", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(
+			bson.E{Key: "version", Value: "7.0.2-ent"},
+			bson.E{Key: "modules", Value: bson.A{"enterprise"}},
+		))
+		v, err := ServerVersion(context.Background(), mt.Client)
+		if err != nil || v != "7.0.2-ent" {
+			t.Fatalf("ServerVersion = %q, %v", v, err)
+		}
+	})
+}
+
+func TestReadTopology(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	hosts := bson.A{"mongo-0:27017", "mongo-1:27017"}
+	mt.Run("primary", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(
+			bson.E{Key: "isWritablePrimary", Value: true},
+			bson.E{Key: "setName", Value: "rs0"},
+			bson.E{Key: "hosts", Value: hosts},
+			bson.E{Key: "primary", Value: "mongo-0:27017"},
+		))
+		topology, err := ReadTopology(context.Background(), mt.Client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if topology.Role != RolePrimary || topology.SetName != "rs0" || len(topology.Hosts) != 2 || topology.Err() != nil {
+			t.Fatalf("topology = %+v, err %v", topology, topology.Err())
+		}
+	})
+	mt.Run("no primary", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(
+			bson.E{Key: "isWritablePrimary", Value: false},
+			bson.E{Key: "secondary", Value: true},
+			bson.E{Key: "setName", Value: "rs0"},
+			bson.E{Key: "hosts", Value: hosts},
+		))
+		topology, err := ReadTopology(context.Background(), mt.Client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if topology.Role != RoleSecondary {
+			t.Errorf("role = %s, want secondary", topology.Role)
+		}
+		if err := topology.Err(); !errors.Is(err, errs.ErrComponentStart) {
+			t.Fatalf("Err() = %v, want ErrComponentStart", err)
+		}
+	})
+	mt.Run("standalone", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "isWritablePrimary", Value: true}))
+		topology, err := ReadTopology(context.Background(), mt.Client)
+		if err != nil || topology.Role != RoleStandalone || topology.Err() != nil {
+			t.Fatalf("topology = %+v, %v", topology, err)
+		}
+	})
+	mt.Run("isMaster fallback", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 59, Name: "CommandNotFound", Message: "no such command: 'hello'"}),
+			mtest.CreateSuccessResponse(bson.E{Key: "ismaster", Value: true}, bson.E{Key: "msg", Value: "isdbgrid"}),
+		)
+		topology, err := ReadTopology(context.Background(), mt.Client)
+		if err != nil || topology.Role != RoleMongos {
+			t.Fatalf("topology = %+v, %v", topology, err)
+		}
+	})
+}
+
+func TestValidateAndSetDefaultsEscapesCredentials(t *testing.T) {
+	for _, password := range []string{"p@ss", "a:b", "x/y", "100%", "p@ss:w/rd%?#[]"} {
+		conf := &Config{Address: []string{"mongo-0:27017"}, Database: "openim", Username: "root@admin", Password: password}
+		if err := conf.ValidateAndSetDefaults(); err != nil {
+			t.Fatal(err)
+		}
+		opts := options.Client().ApplyURI(conf.Uri)
+		if err := opts.Validate(); err != nil {
+			t.Fatalf("%q: uri %s does not parse: %v", password, conf.Uri, err)
+		}
+		if opts.Auth == nil || opts.Auth.Username != "root@admin" || opts.Auth.Password != password {
+			t.Errorf("%q: auth = %+v", password, opts.Auth)
+		}
+		if len(opts.Hosts) != 1 || opts.Hosts[0] != "mongo-0:27017" {
+			t.Errorf("%q: hosts = %v", password, opts.Hosts)
+		}
+	}
+
+	conf := &Config{Address: []string{"mongo-0:27017"}, Database: "im%db", AuthSource: "admin&x"}
+	if err := conf.ValidateAndSetDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "mongodb://mongo-0:27017/im%25db?authSource=admin%26x&maxPoolSize=100"; conf.Uri != want {
+		t.Fatalf("uri = %s, want %s", conf.Uri, want)
+	}
+
+	conf = &Config{Uri: "mongodb://u:p@ss@host/db", Address: []string{"mongo-0:27017"}, Database: "openim", Password: "p@ss"}
+	if err := conf.ValidateAndSetDefaults(); err != nil || conf.Uri != "mongodb://u:p@ss@host/db" {
+		t.Fatalf("uri = %s, %v, want the configured uri untouched", conf.Uri, err)
+	}
+}
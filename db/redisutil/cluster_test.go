@@ -0,0 +1,101 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/openimsdk/tools/errs"
+)
+
+// startCluster starts a fake single node cluster answering CLUSTER INFO with
+// state, CLUSTER SLOTS with slots, and CLUSTER NODES with nodes after its own
+// line, and INFO with clusterNodeInfo.
+func startCluster(t *testing.T, state string, slots [][2]int, nodes ...string) string {
+	t.Helper()
+	srv, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	addr := srv.Addr()
+	_ = srv.Register("PING", func(c *server.Peer, cmd string, args []string) {
+		c.WriteInline("PONG")
+	})
+	_ = srv.Register("INFO", func(c *server.Peer, cmd string, args []string) {
+		c.WriteBulk(clusterNodeInfo)
+	})
+	_ = srv.Register("CLUSTER", func(c *server.Peer, cmd string, args []string) {
+		switch strings.ToUpper(args[0]) {
+		case "INFO":
+			c.WriteBulk("cluster_state:" + state + "\r\ncluster_slots_assigned:0\r\n")
+		case "SLOTS":
+			c.WriteLen(len(slots))
+			for _, s := range slots {
+				c.WriteLen(3)
+				c.WriteInt(s[0])
+				c.WriteInt(s[1])
+				c.WriteLen(3)
+				c.WriteBulk(addr.IP.String())
+				c.WriteInt(addr.Port)
+				c.WriteBulk("09dbe9720cda62f7865eabc5fd8857c5d2678366")
+			}
+		case "NODES":
+			self := "09dbe9720cda62f7865eabc5fd8857c5d2678366 " + addr.String() + "@1 myself,master - 0 0 1 connected 0-1000"
+			c.WriteBulk(strings.Join(append([]string{self}, nodes...), "\n") + "\n")
+		default:
+			c.WriteError("ERR unsupported")
+		}
+	})
+	return addr.String()
+}
+
+const clusterNodeInfo = "# Server\r\nredis_version:7.2.4\r\nredis_mode:cluster\r\n# Clients\r\nconnected_clients:3\r\n" +
+	"# Memory\r\nmaxmemory:1073741824\r\nmaxmemory_policy:allkeys-lru\r\n# Replication\r\nrole:master\r\n"
+
+func TestCheckCluster(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	healthy := startCluster(t, "ok", [][2]int{{0, 8191}, {8192, 16383}})
+	if err := Check(ctx, &Config{ClusterMode: true, Address: []string{healthy}}); err != nil {
+		t.Fatalf("healthy cluster: %v", err)
+	}
+
+	failed := "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 10.0.0.2:6379@16379 master,fail - 0 0 2 disconnected"
+	for name, addr := range map[string]string{
+		"missing slots": startCluster(t, "ok", [][2]int{{0, 1000}, {1001, 12000}}, failed),
+		"state fail":    startCluster(t, "fail", [][2]int{{0, 16383}}, failed),
+	} {
+		err := Check(ctx, &Config{ClusterMode: true, Address: []string{addr}})
+		if !errors.Is(err, errs.ErrComponentStart) {
+			t.Fatalf("%s: err = %v, want ErrComponentStart", name, err)
+		}
+		for _, want := range []string{"coveredSlots=", "10.0.0.2:6379(master,fail,disconnected)"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: err = %v, want %q", name, err, want)
+			}
+		}
+	}
+	err := Check(ctx, &Config{ClusterMode: true, Address: []string{startCluster(t, "ok", [][2]int{{0, 1000}, {1001, 12000}})}})
+	if err == nil || !strings.Contains(err.Error(), "coveredSlots="+strconv.Itoa(12001)) {
+		t.Fatalf("err = %v, want 12001 covered slots", err)
+	}
+}
+
+func TestFailingNodes(t *testing.T) {
+	nodes := strings.Join([]string{
+		"a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460",
+		"b 10.0.0.2:6379@16379 master,fail? - 0 0 2 connected 5461-10922",
+		"c 10.0.0.3:6379@16379 slave a 0 0 3 disconnected",
+		"",
+	}, "\n")
+	got := failingNodes(nodes)
+	if len(got) != 2 || got[0] != "10.0.0.2:6379(master,fail?,connected)" || got[1] != "10.0.0.3:6379(slave,disconnected)" {
+		t.Fatalf("failing nodes = %v", got)
+	}
+}
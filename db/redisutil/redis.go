@@ -19,6 +19,8 @@ import (
 
 	"github.com/openimsdk/tools/errs"
 	"github.com/openimsdk/tools/mw/specialerror"
+	"github.com/openimsdk/tools/utils/network"
+	"github.com/openimsdk/tools/utils/tlsutil"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -38,35 +40,66 @@ type Config struct {
 	MaxRetry    int      // Maximum number of retries for a command.
 	DB          int      // Database number to connect to, for non-cluster mode.
 	PoolSize    int      // Number of connections to pool.
+	TLS         tlsutil.Config
+	// SentinelMasterName, when set, connects to the master of that name
+	// through Redis Sentinel, Address being the sentinel addresses.
+	SentinelMasterName string
 }
 
 func NewRedisClient(ctx context.Context, config *Config) (redis.UniversalClient, error) {
 	if len(config.Address) == 0 {
 		return nil, errs.New("redis address is empty").Wrap()
 	}
+	addrs, err := network.NormalizeAddrs(config.Address)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf, err := config.TLS.Load()
+	if err != nil {
+		return nil, err
+	}
 	var cli redis.UniversalClient
-	if config.ClusterMode || len(config.Address) > 1 {
+	if config.SentinelMasterName != "" {
+		if config.ClusterMode {
+			return nil, errs.ErrConfig.WrapMsg("redis clusterMode and sentinelMasterName are exclusive")
+		}
+		opt := &redis.FailoverOptions{
+			MasterName:    config.SentinelMasterName,
+			SentinelAddrs: addrs,
+			Username:      config.Username,
+			Password:      config.Password,
+			DB:            config.DB,
+			PoolSize:      config.PoolSize,
+			MaxRetries:    config.MaxRetry,
+			TLSConfig:     tlsConf,
+		}
+		cli = redis.NewFailoverClient(opt)
+	} else if config.ClusterMode || len(addrs) > 1 {
 		opt := &redis.ClusterOptions{
-			Addrs:      config.Address,
+			Addrs:      addrs,
 			Username:   config.Username,
 			Password:   config.Password,
 			PoolSize:   config.PoolSize,
 			MaxRetries: config.MaxRetry,
+			TLSConfig:  tlsConf,
 		}
 		cli = redis.NewClusterClient(opt)
 	} else {
 		opt := &redis.Options{
-			Addr:       config.Address[0],
+			Addr:       addrs[0],
 			Username:   config.Username,
 			Password:   config.Password,
 			DB:         config.DB,
 			PoolSize:   config.PoolSize,
 			MaxRetries: config.MaxRetry,
+			TLSConfig:  tlsConf,
 		}
 		cli = redis.NewClient(opt)
 	}
 	if err := cli.Ping(ctx).Err(); err != nil {
-		return nil, errs.WrapMsg(err, "Redis Ping failed", "Address", config.Address, "Username", config.Username, "ClusterMode", config.ClusterMode)
+		_ = cli.Close()
+		return nil, errs.WrapMsg(err, "Redis Ping failed", "Address", config.Address, "Username", config.Username, "ClusterMode", config.ClusterMode,
+			"SentinelMasterName", config.SentinelMasterName)
 	}
 	return cli, nil
 }
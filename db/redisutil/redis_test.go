@@ -0,0 +1,138 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/openimsdk/tools/errs"
+)
+
+func isConfigErr(err error) bool {
+	var codeErr errs.CodeError
+	return errors.As(err, &codeErr) && codeErr.Code() == errs.ConfigError
+}
+
+func startMiniredis(t *testing.T, addr string) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.NewMiniRedis()
+	if err := mr.StartAddr(addr); err != nil {
+		t.Skipf("cannot listen on %s: %v", addr, err)
+	}
+	t.Cleanup(mr.Close)
+	return mr
+}
+
+func TestCheckAddressForms(t *testing.T) {
+	ctx := context.Background()
+	v4 := startMiniredis(t, "127.0.0.1:0")
+	_, v4Port, _ := net.SplitHostPort(v4.Addr())
+
+	t.Run("ipv4", func(t *testing.T) {
+		if err := Check(ctx, &Config{Address: []string{v4.Addr()}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("hostname", func(t *testing.T) {
+		if err := Check(ctx, &Config{Address: []string{net.JoinHostPort("localhost", v4Port)}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("bracketed ipv6", func(t *testing.T) {
+		v6 := startMiniredis(t, "[::1]:0")
+		if err := Check(ctx, &Config{Address: []string{v6.Addr()}}); err != nil {
+			t.Fatalf("Check(%s): %v", v6.Addr(), err)
+		}
+	})
+	t.Run("unbracketed ipv6", func(t *testing.T) {
+		if err := Check(ctx, &Config{Address: []string{"::1:" + v4Port}}); !isConfigErr(err) {
+			t.Fatalf("err = %v, want ErrConfig", err)
+		}
+	})
+	t.Run("comma separated", func(t *testing.T) {
+		if err := Check(ctx, &Config{Address: []string{v4.Addr() + ",fd00::1:6379"}}); !isConfigErr(err) {
+			t.Fatalf("err = %v, want ErrConfig", err)
+		}
+	})
+}
+
+func TestInfoField(t *testing.T) {
+	info := "# Server\r\nredis_version:6.2.14-rc1\r\nredis_git_sha1:00000000\r\nredis_mode:cluster\r\n"
+	if v, ok := infoField(info, "redis_version"); !ok || v != "6.2.14-rc1" {
+		t.Fatalf("redis_version = %q, %v", v, ok)
+	}
+	if v, ok := infoField(info, "redis_mode"); !ok || v != "cluster" {
+		t.Fatalf("redis_mode = %q, %v", v, ok)
+	}
+	if _, ok := infoField(info, "redis"); ok {
+		t.Fatal("matched a field by prefix")
+	}
+}
+
+func TestCheckServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mr := startMiniredis(t, "127.0.0.1:0")
+	info, err := CheckServer(ctx, &Config{Address: []string{mr.Addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// miniredis only reports the clients section.
+	if info.ConnectedClients != 1 || info.Latency <= 0 || info.Version != "" {
+		t.Fatalf("standalone info = %+v", info)
+	}
+
+	addr := startCluster(t, "ok", [][2]int{{0, 16383}})
+	info, err = CheckServer(ctx, &Config{ClusterMode: true, Address: []string{addr}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ServerInfo{Latency: info.Latency, Version: "7.2.4", Role: "master", ConnectedClients: 3, MaxmemoryPolicy: "allkeys-lru", Maxmemory: 1 << 30}
+	if *info != want || info.Latency <= 0 {
+		t.Fatalf("cluster info = %+v, want %+v", info, want)
+	}
+}
+
+func TestCheckServerWriteProbe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mr := startMiniredis(t, "127.0.0.1:0")
+	if _, err := CheckServer(ctx, &Config{Address: []string{mr.Addr()}}, WithWriteProbe("")); err != nil {
+		t.Fatal(err)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Fatalf("probe keys left: %v", keys)
+	}
+
+	srv, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	var setArgs []string
+	_ = srv.Register("PING", func(c *server.Peer, cmd string, args []string) { c.WriteInline("PONG") })
+	_ = srv.Register("INFO", func(c *server.Peer, cmd string, args []string) { c.WriteBulk("role:slave\r\n") })
+	_ = srv.Register("SET", func(c *server.Peer, cmd string, args []string) {
+		setArgs = args
+		c.WriteError("READONLY You can't write against a read only replica.")
+	})
+	_, err = CheckServer(ctx, &Config{Address: []string{srv.Addr().String()}}, WithWriteProbe("app:check:"))
+	if !errors.Is(err, errs.ErrComponentStart) {
+		t.Fatalf("err = %v, want ErrComponentStart", err)
+	}
+	for _, want := range []string{"role=slave", "READONLY"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want %q", err, want)
+		}
+	}
+	if len(setArgs) != 4 || !strings.HasPrefix(setArgs[0], "app:check:") || !strings.EqualFold(setArgs[2], "ex") {
+		t.Errorf("SET %v, want a prefixed key with a TTL", setArgs)
+	}
+}
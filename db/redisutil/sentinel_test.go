@@ -0,0 +1,72 @@
+package redisutil
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+// startSentinel starts a fake sentinel resolving the master "mymaster" to
+// masterAddr.
+func startSentinel(t *testing.T, masterAddr string) string {
+	t.Helper()
+	srv, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	host, port, _ := net.SplitHostPort(masterAddr)
+	err = srv.Register("SENTINEL", func(c *server.Peer, cmd string, args []string) {
+		switch {
+		case len(args) == 2 && strings.EqualFold(args[0], "get-master-addr-by-name") && args[1] == "mymaster":
+			c.WriteStrings([]string{host, port})
+		case len(args) == 2 && strings.EqualFold(args[0], "get-master-addr-by-name"):
+			c.WriteNull()
+		case len(args) >= 1 && strings.EqualFold(args[0], "sentinels"):
+			c.WriteLen(0)
+		default:
+			c.WriteError("ERR unsupported sentinel command")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv.Addr().String()
+}
+
+func TestCheckSentinel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	master := startMiniredis(t, "127.0.0.1:0")
+	sentinel := startSentinel(t, master.Addr())
+
+	got, err := CheckSentinel(ctx, &Config{Address: []string{"127.0.0.1:1", sentinel}, SentinelMasterName: "mymaster"})
+	if err != nil {
+		t.Fatalf("CheckSentinel: %v", err)
+	}
+	if got != master.Addr() {
+		t.Fatalf("master = %s, want %s", got, master.Addr())
+	}
+	if err := Check(ctx, &Config{Address: []string{sentinel}, SentinelMasterName: "mymaster"}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	_, err = CheckSentinel(ctx, &Config{Address: []string{sentinel}, SentinelMasterName: "other"})
+	if err == nil || !strings.Contains(err.Error(), "unknown master") {
+		t.Fatalf("unknown master: err = %v", err)
+	}
+
+	// The sentinel resolves a master that is down.
+	down := startSentinel(t, "127.0.0.1:1")
+	if _, err := CheckSentinel(ctx, &Config{Address: []string{down}, SentinelMasterName: "mymaster"}); err == nil {
+		t.Fatal("CheckSentinel succeeded with the master down")
+	}
+
+	if err := Check(ctx, &Config{Address: []string{sentinel}, SentinelMasterName: "mymaster", ClusterMode: true}); !isConfigErr(err) {
+		t.Fatalf("cluster and sentinel: err = %v, want ErrConfig", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisutil
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/network"
+	"github.com/redis/go-redis/v9"
+)
+
+// Settings are the server settings of a Redis node that decide whether the
+// OpenIM caches survive memory pressure.
+type Settings struct {
+	// Addr is the node the settings were read from: the first address, or
+	// the master in sentinel mode.
+	Addr           string
+	ClusterEnabled bool
+	// MaxmemoryPolicy and Maxmemory come from CONFIG GET, or from INFO when
+	// CONFIG is not allowed. MaxmemoryPolicy is empty when neither reports it.
+	MaxmemoryPolicy string
+	Maxmemory       int64
+	// TotalSystemMemory is the memory of the host, 0 if not reported.
+	TotalSystemMemory int64
+	// ConfigErr is the error of CONFIG GET, which managed Redis services
+	// often disable.
+	ConfigErr error
+}
+
+// NodeSettings connects to a single node of config, without the cluster or
+// failover client, so that it also works when the cluster mode of config does
+// not match the server, and reads its Settings.
+func NodeSettings(ctx context.Context, config *Config) (*Settings, error) {
+	addrs, err := network.NormalizeAddrs(config.Address)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, errs.New("redis address is empty").Wrap()
+	}
+	addr := addrs[0]
+	if config.SentinelMasterName != "" {
+		if addr, err = SentinelMaster(ctx, config); err != nil {
+			return nil, err
+		}
+	}
+	tlsConf, err := config.TLS.Load()
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:       addr,
+		Username:   config.Username,
+		Password:   config.Password,
+		MaxRetries: config.MaxRetry,
+		TLSConfig:  tlsConf,
+	})
+	defer client.Close()
+
+	info, err := client.Info(ctx).Result()
+	if err != nil {
+		return nil, errs.WrapMsg(err, "Redis INFO failed", "addr", addr)
+	}
+	s := &Settings{Addr: addr}
+	if v, ok := infoField(info, "cluster_enabled"); ok {
+		s.ClusterEnabled = v == "1"
+	} else if v, ok := infoField(info, "redis_mode"); ok {
+		s.ClusterEnabled = v == "cluster"
+	}
+	if v, ok := infoField(info, "total_system_memory"); ok {
+		s.TotalSystemMemory, _ = strconv.ParseInt(v, 10, 64)
+	}
+	policy, maxmemory, err := configMaxmemory(ctx, client)
+	if err == nil {
+		s.MaxmemoryPolicy, s.Maxmemory = policy, maxmemory
+		return s, nil
+	}
+	s.ConfigErr = err
+	s.MaxmemoryPolicy, _ = infoField(info, "maxmemory_policy")
+	if v, ok := infoField(info, "maxmemory"); ok {
+		s.Maxmemory, _ = strconv.ParseInt(v, 10, 64)
+	}
+	return s, nil
+}
+
+func configMaxmemory(ctx context.Context, client *redis.Client) (string, int64, error) {
+	policy, err := client.ConfigGet(ctx, "maxmemory-policy").Result()
+	if err != nil {
+		return "", 0, errs.WrapMsg(err, "Redis CONFIG GET failed")
+	}
+	maxmemory, err := client.ConfigGet(ctx, "maxmemory").Result()
+	if err != nil {
+		return "", 0, errs.WrapMsg(err, "Redis CONFIG GET failed")
+	}
+	if policy["maxmemory-policy"] == "" {
+		return "", 0, errs.New("CONFIG GET maxmemory-policy returned nothing").Wrap()
+	}
+	n, err := strconv.ParseInt(maxmemory["maxmemory"], 10, 64)
+	if err != nil {
+		return "", 0, errs.WrapMsg(err, "invalid maxmemory", "maxmemory", maxmemory["maxmemory"])
+	}
+	return policy["maxmemory-policy"], n, nil
+}
@@ -0,0 +1,31 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisutil
+
+import (
+	"github.com/openimsdk/tools/env"
+	"github.com/openimsdk/tools/errs"
+)
+
+// ApplyEnv enables TLS when the REDIS_TLS environment variable is true, and
+// disables it when false.
+func (c *Config) ApplyEnv() error {
+	enable, err := env.GetBool("REDIS_TLS", c.TLS.EnableTLS)
+	if err != nil {
+		return errs.ErrConfig.WrapMsg("invalid REDIS_TLS", "err", errs.Unwrap(err).Error())
+	}
+	c.TLS.EnableTLS = enable
+	return nil
+}
@@ -0,0 +1,113 @@
+package redisutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/tlsutil"
+	"github.com/openimsdk/tools/utils/tlsutil/tlstest"
+)
+
+// startTLSMiniredis starts a miniredis accepting TLS only, with a certificate
+// for 127.0.0.1 signed by a CA written in dir, and returns the CA path.
+func startTLSMiniredis(t *testing.T, dir string) (*miniredis.Miniredis, string) {
+	t.Helper()
+	ca, caKey, caPath := tlstest.NewCA(t, dir, "redis-ca")
+	mr := miniredis.NewMiniRedis()
+	if err := mr.StartTLS(&tls.Config{Certificates: []tls.Certificate{tlstest.ServerCert(t, ca, caKey, "redis")}}); err != nil {
+		t.Skipf("cannot start miniredis with TLS: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	return mr, caPath
+}
+
+func TestCheckTLS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+	mr, caPath := startTLSMiniredis(t, dir)
+
+	if err := Check(ctx, &Config{Address: []string{mr.Addr()}, TLS: tlsutil.Config{EnableTLS: true, CAFile: caPath}}); err != nil {
+		t.Fatalf("Check with caFile: %v", err)
+	}
+	if err := Check(ctx, &Config{Address: []string{mr.Addr()}, TLS: tlsutil.Config{EnableTLS: true, InsecureSkipVerify: true}}); err != nil {
+		t.Fatalf("Check with insecureSkipVerify: %v", err)
+	}
+	if err := Check(ctx, &Config{Address: []string{mr.Addr()}, TLS: tlsutil.Config{EnableTLS: true}}); err == nil {
+		t.Fatal("Check accepted a certificate signed by an unknown CA")
+	}
+}
+
+func TestCheckMutualTLS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+	ca, caKey, caPath := tlstest.NewCA(t, dir, "redis-ca")
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	mr := miniredis.NewMiniRedis()
+	err := mr.StartTLS(&tls.Config{
+		Certificates: []tls.Certificate{tlstest.ServerCert(t, ca, caKey, "redis")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	if err != nil {
+		t.Skipf("cannot start miniredis with TLS: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	certPath, keyPath := tlstest.ClientCert(t, ca, caKey, dir, "client")
+
+	conf := &Config{Address: []string{mr.Addr()}, TLS: tlsutil.Config{EnableTLS: true, CAFile: caPath, CertFile: certPath, KeyFile: keyPath}}
+	if err := Check(ctx, conf); err != nil {
+		t.Fatalf("Check with a client certificate: %v", err)
+	}
+	conf.TLS.CertFile, conf.TLS.KeyFile = "", ""
+	if err := Check(ctx, conf); err == nil {
+		t.Fatal("Check without a client certificate accepted")
+	}
+}
+
+func TestCheckTLSErrors(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "malformed.pem")
+	if err := os.WriteFile(malformed, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for name, conf := range map[string]Config{
+		"missing caFile":   {TLS: tlsutil.Config{EnableTLS: true, CAFile: filepath.Join(dir, "missing.pem")}},
+		"malformed caFile": {TLS: tlsutil.Config{EnableTLS: true, CAFile: malformed}},
+		"cluster":          {ClusterMode: true, TLS: tlsutil.Config{EnableTLS: true, CAFile: malformed}},
+		"tls disabled":     {TLS: tlsutil.Config{CAFile: malformed}},
+	} {
+		conf.Address = []string{"127.0.0.1:1"}
+		err := Check(ctx, &conf)
+		if !isConfigErr(err) {
+			t.Errorf("%s: err = %v, want ErrConfig", name, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), conf.TLS.CAFile) {
+			t.Errorf("%s: err = %v, want the file path", name, err)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	conf := &Config{Address: []string{"127.0.0.1:6379"}}
+	t.Setenv("REDIS_TLS", "true")
+	if err := conf.ApplyEnv(); err != nil || !conf.TLS.EnableTLS {
+		t.Fatalf("REDIS_TLS=true: EnableTLS = %v, %v", conf.TLS.EnableTLS, err)
+	}
+	t.Setenv("REDIS_TLS", "yes please")
+	if err := conf.ApplyEnv(); !errors.Is(err, errs.ErrConfig) {
+		t.Fatalf("err = %v, want ErrConfig", err)
+	}
+}
@@ -16,22 +16,281 @@ package redisutil
 
 import (
 	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/network"
+	"github.com/redis/go-redis/v9"
 )
 
-// CheckRedis checks the Redis connection.
+// CheckRedis checks the Redis connection. With SentinelMasterName, it pings
+// the master resolved by the sentinels, see CheckSentinel. In cluster mode, it
+// also checks that the cluster is healthy, see CheckCluster.
 func Check(ctx context.Context, config *Config) error {
+	if config.SentinelMasterName != "" {
+		_, err := CheckSentinel(ctx, config)
+		return err
+	}
+	_, err := CheckServer(ctx, config)
+	return err
+}
+
+// ServerInfo is the PING round trip and a few INFO fields of a Redis server.
+// Fields missing from INFO are left empty.
+type ServerInfo struct {
+	Latency          time.Duration
+	Version          string
+	Role             string
+	ConnectedClients int
+	MaxmemoryPolicy  string
+	Maxmemory        int64
+}
+
+// DefaultProbePrefix is the prefix of the key written by WithWriteProbe.
+const DefaultProbePrefix = "openim:component:check:"
+
+// probeTTL bounds the life of the probe key, should its deletion fail.
+const probeTTL = 30 * time.Second
+
+type checkOptions struct {
+	probeWrite  bool
+	probePrefix string
+}
+
+type CheckOption func(o *checkOptions)
+
+// WithWriteProbe makes CheckServer set, read back and delete a key named
+// prefix followed by a random suffix, with a short TTL: a PING succeeds on a
+// replica that rejects writes. An empty prefix means DefaultProbePrefix; set
+// one to match the key patterns the ACL of the user allows.
+func WithWriteProbe(prefix string) CheckOption {
+	return func(o *checkOptions) {
+		o.probeWrite = true
+		o.probePrefix = prefix
+	}
+}
+
+// CheckServer checks the Redis connection like Check, without sentinel
+// support, and returns the ping latency and the INFO fields of the server. In
+// cluster mode the INFO fields are those of one of the nodes.
+func CheckServer(ctx context.Context, config *Config, opts ...CheckOption) (*ServerInfo, error) {
+	o := checkOptions{probePrefix: DefaultProbePrefix}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.probePrefix == "" {
+		o.probePrefix = DefaultProbePrefix
+	}
 	client, err := NewRedisClient(ctx, config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer client.Close()
 
 	// Ping the Redis server to check connectivity.
+	start := time.Now()
 	if err := client.Ping(ctx).Err(); err != nil {
-		return errs.WrapMsg(err, "Redis ping failed", "config", config)
+		return nil, errs.WrapMsg(err, "Redis ping failed", "config", config)
+	}
+	latency := time.Since(start)
+
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		if err := CheckCluster(ctx, cluster); err != nil {
+			return nil, err
+		}
+	}
+	info, err := ReadServerInfo(ctx, client)
+	if err != nil {
+		return nil, err
 	}
+	info.Latency = latency
+	if o.probeWrite {
+		if err := probeWrite(ctx, client, o.probePrefix+uuid.NewString()); err != nil {
+			return nil, errs.ErrComponentStart.WrapMsg("Redis rejects writes", "role", info.Role, "err", err.Error())
+		}
+	}
+	return info, nil
+}
 
+// probeWrite sets key, checks that it reads back and deletes it.
+func probeWrite(ctx context.Context, client redis.UniversalClient, key string) error {
+	value := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := client.Set(ctx, key, value, probeTTL).Err(); err != nil {
+		return errs.New("SET failed", "key", key, "err", err.Error())
+	}
+	defer client.Del(context.WithoutCancel(ctx), key)
+	got, err := client.Get(ctx, key).Result()
+	if err != nil {
+		return errs.New("GET failed", "key", key, "err", err.Error())
+	}
+	if got != value {
+		return errs.New("GET returned another value", "key", key, "value", got, "want", value)
+	}
 	return nil
 }
+
+// ReadServerInfo returns the INFO fields of ServerInfo, its Latency unset.
+func ReadServerInfo(ctx context.Context, client redis.UniversalClient) (*ServerInfo, error) {
+	raw, err := client.Info(ctx).Result()
+	if err != nil {
+		return nil, errs.WrapMsg(err, "Redis INFO failed")
+	}
+	info := &ServerInfo{}
+	info.Version, _ = infoField(raw, "redis_version")
+	info.Role, _ = infoField(raw, "role")
+	info.MaxmemoryPolicy, _ = infoField(raw, "maxmemory_policy")
+	if v, ok := infoField(raw, "connected_clients"); ok {
+		info.ConnectedClients, _ = strconv.Atoi(v)
+	}
+	if v, ok := infoField(raw, "maxmemory"); ok {
+		info.Maxmemory, _ = strconv.ParseInt(v, 10, 64)
+	}
+	return info, nil
+}
+
+// clusterSlots is the number of hash slots of a Redis cluster.
+const clusterSlots = 16384
+
+// CheckCluster returns an ErrComponentStart wrap when the cluster state is not
+// ok or when some hash slots are not assigned, with the number of slots
+// covered and the nodes in fail state.
+func CheckCluster(ctx context.Context, client *redis.ClusterClient) error {
+	info, err := client.ClusterInfo(ctx).Result()
+	if err != nil {
+		return errs.WrapMsg(err, "Redis CLUSTER INFO failed")
+	}
+	state, _ := infoField(info, "cluster_state")
+	slots, err := client.ClusterSlots(ctx).Result()
+	if err != nil {
+		return errs.WrapMsg(err, "Redis CLUSTER SLOTS failed")
+	}
+	var covered [clusterSlots]bool
+	count := 0
+	for _, slot := range slots {
+		for i := max(slot.Start, 0); i <= min(slot.End, clusterSlots-1); i++ {
+			if !covered[i] {
+				covered[i] = true
+				count++
+			}
+		}
+	}
+	if state == "ok" && count == clusterSlots {
+		return nil
+	}
+	nodes, err := client.ClusterNodes(ctx).Result()
+	if err != nil {
+		return errs.WrapMsg(err, "Redis CLUSTER NODES failed")
+	}
+	return errs.ErrComponentStart.WrapMsg("Redis cluster is not healthy", "clusterState", state,
+		"coveredSlots", count, "totalSlots", clusterSlots, "failingNodes", failingNodes(nodes))
+}
+
+// failingNodes returns "addr(flags)" for the nodes of CLUSTER NODES in fail
+// or pfail state, or disconnected.
+func failingNodes(nodes string) []string {
+	var failing []string
+	for _, line := range strings.Split(nodes, "\n") {
+		// <id> <ip:port@cport> <flags> <master> <ping-sent> <pong-recv> <config-epoch> <link-state> <slot>...
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			continue
+		}
+		flags := fields[2]
+		failed := fields[7] == "disconnected"
+		for _, flag := range strings.Split(flags, ",") {
+			if flag == "fail" || flag == "fail?" {
+				failed = true
+			}
+		}
+		if failed {
+			addr, _, _ := strings.Cut(fields[1], "@")
+			failing = append(failing, addr+"("+flags+","+fields[7]+")")
+		}
+	}
+	return failing
+}
+
+// CheckSentinel checks that a sentinel resolves the master named
+// SentinelMasterName and that the master answers a ping, and returns the
+// master address.
+func CheckSentinel(ctx context.Context, config *Config) (string, error) {
+	master, err := SentinelMaster(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	client, err := NewRedisClient(ctx, config)
+	if err != nil {
+		return master, err
+	}
+	defer client.Close()
+	return master, nil
+}
+
+// SentinelMaster returns the address of the master named SentinelMasterName,
+// as resolved by the first sentinel of Address that knows it.
+func SentinelMaster(ctx context.Context, config *Config) (string, error) {
+	if config.SentinelMasterName == "" {
+		return "", errs.ErrConfig.WrapMsg("redis sentinelMasterName is empty")
+	}
+	addrs, err := network.NormalizeAddrs(config.Address)
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) == 0 {
+		return "", errs.New("redis address is empty").Wrap()
+	}
+	tlsConf, err := config.TLS.Load()
+	if err != nil {
+		return "", err
+	}
+	var lastErr error
+	for _, addr := range addrs {
+		sentinel := redis.NewSentinelClient(&redis.Options{Addr: addr, TLSConfig: tlsConf})
+		master, err := sentinel.GetMasterAddrByName(ctx, config.SentinelMasterName).Result()
+		_ = sentinel.Close()
+		switch {
+		case err == nil && len(master) == 2:
+			return net.JoinHostPort(master[0], master[1]), nil
+		case errors.Is(err, redis.Nil):
+			lastErr = errs.New("unknown master", "sentinel", addr)
+		case err == nil:
+			lastErr = errs.New("invalid master address", "sentinel", addr, "reply", master)
+		default:
+			lastErr = errs.New(err.Error(), "sentinel", addr)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return "", errs.WrapMsg(lastErr, "Redis sentinels cannot resolve the master", "masterName", config.SentinelMasterName,
+		"sentinels", addrs, "err", lastErr.Error())
+}
+
+// ServerVersion returns the redis_version reported by INFO server. In cluster
+// mode the version of one of the nodes is returned.
+func ServerVersion(ctx context.Context, client redis.UniversalClient) (string, error) {
+	info, err := client.Info(ctx, "server").Result()
+	if err != nil {
+		return "", errs.WrapMsg(err, "Redis INFO failed")
+	}
+	v, ok := infoField(info, "redis_version")
+	if !ok {
+		return "", errs.New("redis_version missing from INFO server").Wrap()
+	}
+	return v, nil
+}
+
+// infoField returns the value of field in the output of the INFO command.
+func infoField(info string, field string) (string, bool) {
+	for _, line := range strings.Split(info, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), field+":"); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,408 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformancetest checks that a discovery.SvcDiscoveryRegistry
+// implementation follows the behavior the rest of OpenIM relies on.
+//
+// The contract asserted by Run:
+//   - Register makes host:port resolvable by every registry sharing the backend,
+//     and GetSelfConnTarget returns that address.
+//   - GetConns returns a slice the caller owns; modifying it does not affect
+//     later calls.
+//   - GetConns on a service without instances fails with an error matching
+//     discovery.ErrServiceNotFound.
+//   - Registrations and unregistrations converge to the latest state, and an
+//     UnRegister becomes visible to other registries.
+//   - GetConn and GetConns are safe for concurrent use.
+//   - Options given to AddOption, including interceptors that attach outgoing
+//     metadata, apply to connections returned afterwards.
+//   - Close may be called more than once.
+//   - Registries implementing discovery.Drainer stop resolving a draining
+//     instance on every registry and resolve it again once it stops draining.
+//
+// The kubernetes registry does not run the suite: the cluster registers its
+// instances, so its Register and UnRegister do nothing and the contract does
+// not apply.
+package conformancetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/discovery"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Factory returns a new registry connected to the backend under test. Every
+// call must return an independent client of the same backend. watch holds the
+// service names the test will resolve, for implementations that need them up
+// front. The suite closes the registries it gets.
+type Factory func(t *testing.T, watch []string) discovery.SvcDiscoveryRegistry
+
+// Timeout bounds how long the suite waits for a change to become visible.
+var Timeout = 15 * time.Second
+
+const (
+	pingMethod  = "/conformancetest.Conformance/Ping"
+	metadataKey = "x-conformance"
+)
+
+var serviceSeq atomic.Int64
+
+// Run executes the conformance suite against the registries built by factory.
+func Run(t *testing.T, factory Factory) {
+	t.Run("RegisterResolve", func(t *testing.T) { testRegisterResolve(t, factory) })
+	t.Run("GetConnsReturnsCopy", func(t *testing.T) { testGetConnsCopy(t, factory) })
+	t.Run("EmptyService", func(t *testing.T) { testEmptyService(t, factory) })
+	t.Run("UnRegisterVisibility", func(t *testing.T) { testUnRegister(t, factory) })
+	t.Run("UpdateOrdering", func(t *testing.T) { testUpdateOrdering(t, factory) })
+	t.Run("ConcurrentGetConn", func(t *testing.T) { testConcurrentGetConn(t, factory) })
+	t.Run("MetadataPropagation", func(t *testing.T) { testMetadataPropagation(t, factory) })
+	t.Run("CloseIdempotent", func(t *testing.T) { testCloseIdempotent(t, factory) })
+	t.Run("Draining", func(t *testing.T) { testDraining(t, factory) })
+}
+
+func newServiceName() string {
+	return fmt.Sprintf("conformance-%d-%d", time.Now().UnixNano(), serviceSeq.Add(1))
+}
+
+func newRegistry(t *testing.T, factory Factory, watch ...string) discovery.SvcDiscoveryRegistry {
+	t.Helper()
+	r := factory(t, watch)
+	if r == nil {
+		t.Fatal("factory returned nil registry")
+	}
+	r.AddOption(grpc.WithTransportCredentials(insecure.NewCredentials()))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// startServer runs a gRPC server that answers every method with
+// codes.Unimplemented and echoes the metadataKey header in the status message.
+func startServer(t *testing.T) (string, int) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		return status.Errorf(codes.Unimplemented, "%s=%v", metadataKey, md.Get(metadataKey))
+	}))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	addr := lis.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func register(t *testing.T, r discovery.SvcDiscoveryRegistry, service string) string {
+	t.Helper()
+	host, port := startServer(t)
+	if err := r.Register(service, host, port, grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+		t.Fatalf("Register(%s): %v", service, err)
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	if got := r.GetSelfConnTarget(); got != addr {
+		t.Errorf("GetSelfConnTarget() = %q, want %q", got, addr)
+	}
+	return addr
+}
+
+// eventually polls cond until it returns nil or Timeout expires.
+func eventually(t *testing.T, what string, cond func() error) {
+	t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for {
+		err := cond()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: %v", what, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func targets(conns []*grpc.ClientConn) map[string]bool {
+	set := make(map[string]bool, len(conns))
+	for _, conn := range conns {
+		if conn != nil {
+			set[conn.Target()] = true
+		}
+	}
+	return set
+}
+
+// waitTargets waits until GetConns resolves exactly want.
+func waitTargets(t *testing.T, r discovery.SvcDiscoveryRegistry, service string, want ...string) {
+	t.Helper()
+	eventually(t, "GetConns("+service+")", func() error {
+		conns, err := r.GetConns(context.Background(), service)
+		if err != nil {
+			return err
+		}
+		got := targets(conns)
+		if len(got) != len(want) {
+			return fmt.Errorf("resolved %v, want %v", got, want)
+		}
+		for _, addr := range want {
+			if !got[addr] {
+				return fmt.Errorf("resolved %v, want %v", got, want)
+			}
+		}
+		return nil
+	})
+}
+
+// waitNotFound waits until GetConns reports discovery.ErrServiceNotFound.
+func waitNotFound(t *testing.T, r discovery.SvcDiscoveryRegistry, service string) {
+	t.Helper()
+	eventually(t, "GetConns("+service+") after unregister", func() error {
+		conns, err := r.GetConns(context.Background(), service)
+		if errors.Is(err, discovery.ErrServiceNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unexpected error type %T: %v", err, err)
+		}
+		return fmt.Errorf("still resolved %v", targets(conns))
+	})
+}
+
+// ping invokes an unknown method through conn and returns the status message.
+func ping(ctx context.Context, conn *grpc.ClientConn) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	err := conn.Invoke(ctx, pingMethod, &emptypb.Empty{}, &emptypb.Empty{}, grpc.WaitForReady(true))
+	st := status.Convert(err)
+	if st.Code() != codes.Unimplemented {
+		return "", fmt.Errorf("ping did not reach the server: %v", err)
+	}
+	return st.Message(), nil
+}
+
+func testRegisterResolve(t *testing.T, factory Factory) {
+	service := newServiceName()
+	pub := newRegistry(t, factory, service)
+	obs := newRegistry(t, factory, service)
+	addr := register(t, pub, service)
+
+	waitTargets(t, obs, service, addr)
+
+	conn, err := obs.GetConn(context.Background(), service)
+	if err != nil {
+		t.Fatalf("GetConn: %v", err)
+	}
+	defer obs.CloseConn(conn)
+	if _, err := ping(context.Background(), conn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testGetConnsCopy(t *testing.T, factory Factory) {
+	service := newServiceName()
+	pub := newRegistry(t, factory, service)
+	obs := newRegistry(t, factory, service)
+	addr := register(t, pub, service)
+	waitTargets(t, obs, service, addr)
+
+	conns, err := obs.GetConns(context.Background(), service)
+	if err != nil {
+		t.Fatalf("GetConns: %v", err)
+	}
+	for i := range conns {
+		conns[i] = nil
+	}
+
+	again, err := obs.GetConns(context.Background(), service)
+	if err != nil {
+		t.Fatalf("GetConns: %v", err)
+	}
+	if len(again) == 0 || again[0] == nil {
+		t.Fatal("modifying the slice returned by GetConns changed the registry state")
+	}
+}
+
+func testEmptyService(t *testing.T, factory Factory) {
+	service := newServiceName()
+	obs := newRegistry(t, factory, service)
+	conns, err := obs.GetConns(context.Background(), service)
+	if !errors.Is(err, discovery.ErrServiceNotFound) {
+		t.Fatalf("GetConns on empty service = %v, %v; want discovery.ErrServiceNotFound", conns, err)
+	}
+}
+
+func testUnRegister(t *testing.T, factory Factory) {
+	service := newServiceName()
+	pub := newRegistry(t, factory, service)
+	obs := newRegistry(t, factory, service)
+	addr := register(t, pub, service)
+	waitTargets(t, obs, service, addr)
+
+	if err := pub.UnRegister(); err != nil {
+		t.Fatalf("UnRegister: %v", err)
+	}
+	waitNotFound(t, obs, service)
+}
+
+func testUpdateOrdering(t *testing.T, factory Factory) {
+	service := newServiceName()
+	pubA := newRegistry(t, factory, service)
+	pubB := newRegistry(t, factory, service)
+	obs := newRegistry(t, factory, service)
+
+	addrA := register(t, pubA, service)
+	waitTargets(t, obs, service, addrA)
+	addrB := register(t, pubB, service)
+	waitTargets(t, obs, service, addrA, addrB)
+
+	// Changes must not be applied out of order: the observer has to end up with
+	// the state after the last change, not an intermediate one.
+	if err := pubA.UnRegister(); err != nil {
+		t.Fatalf("UnRegister A: %v", err)
+	}
+	waitTargets(t, obs, service, addrB)
+	if err := pubB.UnRegister(); err != nil {
+		t.Fatalf("UnRegister B: %v", err)
+	}
+	waitNotFound(t, obs, service)
+}
+
+func testConcurrentGetConn(t *testing.T, factory Factory) {
+	service := newServiceName()
+	pub := newRegistry(t, factory, service)
+	obs := newRegistry(t, factory, service)
+	addr := register(t, pub, service)
+	waitTargets(t, obs, service, addr)
+
+	const workers = 16
+	var wg sync.WaitGroup
+	errCh := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			conn, err := obs.GetConn(context.Background(), service)
+			if err != nil {
+				errCh <- fmt.Errorf("GetConn: %w", err)
+				return
+			}
+			if _, err := ping(context.Background(), conn); err != nil {
+				errCh <- err
+			}
+			obs.CloseConn(conn)
+		}()
+		go func() {
+			defer wg.Done()
+			conns, err := obs.GetConns(context.Background(), service)
+			if err != nil {
+				errCh <- fmt.Errorf("GetConns: %w", err)
+				return
+			}
+			if !targets(conns)[addr] {
+				errCh <- fmt.Errorf("GetConns resolved %v, want %s", targets(conns), addr)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Error(err)
+	}
+}
+
+func testMetadataPropagation(t *testing.T, factory Factory) {
+	service := newServiceName()
+	pub := newRegistry(t, factory, service)
+	obs := newRegistry(t, factory, service)
+	obs.AddOption(grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(metadata.AppendToOutgoingContext(ctx, metadataKey, "propagated"), method, req, reply, cc, opts...)
+	}))
+	addr := register(t, pub, service)
+	waitTargets(t, obs, service, addr)
+
+	want := metadataKey + "=[propagated]"
+	conn, err := obs.GetConn(context.Background(), service)
+	if err != nil {
+		t.Fatalf("GetConn: %v", err)
+	}
+	defer obs.CloseConn(conn)
+	if msg, err := ping(context.Background(), conn); err != nil || msg != want {
+		t.Errorf("GetConn: server saw %q, %v; want %q", msg, err, want)
+	}
+
+	conns, err := obs.GetConns(context.Background(), service)
+	if err != nil {
+		t.Fatalf("GetConns: %v", err)
+	}
+	for _, conn := range conns {
+		if msg, err := ping(context.Background(), conn); err != nil || msg != want {
+			t.Errorf("GetConns %s: server saw %q, %v; want %q", conn.Target(), msg, err, want)
+		}
+	}
+}
+
+func testCloseIdempotent(t *testing.T, factory Factory) {
+	r := factory(t, []string{newServiceName()})
+	r.Close()
+	defer func() {
+		if p := recover(); p != nil {
+			t.Fatalf("second Close panicked: %v", p)
+		}
+	}()
+	r.Close()
+}
+
+func testDraining(t *testing.T, factory Factory) {
+	service := newServiceName()
+	pub := newRegistry(t, factory, service)
+	drainer, ok := pub.(discovery.Drainer)
+	if !ok {
+		t.Skip("registry does not implement discovery.Drainer")
+	}
+	obs := newRegistry(t, factory, service)
+	addr := register(t, pub, service)
+	waitTargets(t, obs, service, addr)
+
+	start := time.Now()
+	if err := drainer.SetDraining(context.Background(), true); err != nil {
+		t.Fatalf("SetDraining(true): %v", err)
+	}
+	if !drainer.IsDraining() {
+		t.Error("IsDraining() = false after SetDraining(true)")
+	}
+	waitNotFound(t, obs, service)
+	t.Logf("draining instance excluded after %s", time.Since(start))
+
+	if err := drainer.SetDraining(context.Background(), false); err != nil {
+		t.Fatalf("SetDraining(false): %v", err)
+	}
+	if drainer.IsDraining() {
+		t.Error("IsDraining() = true after SetDraining(false)")
+	}
+	waitTargets(t, obs, service, addr)
+}
@@ -0,0 +1,122 @@
+package conformancetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/openimsdk/tools/discovery"
+	"google.golang.org/grpc"
+)
+
+// memBackend is the shared state behind memRegistry instances.
+type memBackend struct {
+	lock     sync.Mutex
+	services map[string]map[string]bool // addr -> serving, false while draining
+}
+
+// memRegistry is a minimal in-memory registry used to check the suite itself.
+type memRegistry struct {
+	backend  *memBackend
+	lock     sync.Mutex
+	opts     []grpc.DialOption
+	service  string
+	addr     string
+	draining bool
+}
+
+func (m *memRegistry) GetConns(ctx context.Context, serviceName string, opts ...grpc.DialOption) ([]*grpc.ClientConn, error) {
+	m.backend.lock.Lock()
+	addrs := make([]string, 0, len(m.backend.services[serviceName]))
+	for addr, serving := range m.backend.services[serviceName] {
+		if serving {
+			addrs = append(addrs, addr)
+		}
+	}
+	m.backend.lock.Unlock()
+	if len(addrs) == 0 {
+		return nil, discovery.ErrServiceNotFound.WrapMsg("no conn for service", "serviceName", serviceName)
+	}
+	conns := make([]*grpc.ClientConn, 0, len(addrs))
+	for _, addr := range addrs {
+		conn, err := m.dial(addr, opts)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
+
+func (m *memRegistry) GetConn(ctx context.Context, serviceName string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	conns, err := m.GetConns(ctx, serviceName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return conns[0], nil
+}
+
+func (m *memRegistry) dial(addr string, opts []grpc.DialOption) (*grpc.ClientConn, error) {
+	m.lock.Lock()
+	dialOpts := append(append([]grpc.DialOption(nil), m.opts...), opts...)
+	m.lock.Unlock()
+	return grpc.Dial(addr, dialOpts...)
+}
+
+func (m *memRegistry) GetSelfConnTarget() string { return m.addr }
+
+func (m *memRegistry) AddOption(opts ...grpc.DialOption) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.opts = append(m.opts, opts...)
+}
+
+func (m *memRegistry) CloseConn(conn *grpc.ClientConn) { _ = conn.Close() }
+
+func (m *memRegistry) Register(serviceName, host string, port int, opts ...grpc.DialOption) error {
+	m.backend.lock.Lock()
+	defer m.backend.lock.Unlock()
+	m.service, m.addr = serviceName, fmt.Sprintf("%s:%d", host, port)
+	if m.backend.services[serviceName] == nil {
+		m.backend.services[serviceName] = make(map[string]bool)
+	}
+	m.backend.services[serviceName][m.addr] = !m.draining
+	return nil
+}
+
+func (m *memRegistry) UnRegister() error {
+	m.backend.lock.Lock()
+	defer m.backend.lock.Unlock()
+	delete(m.backend.services[m.service], m.addr)
+	return nil
+}
+
+func (m *memRegistry) SetDraining(ctx context.Context, draining bool) error {
+	m.backend.lock.Lock()
+	defer m.backend.lock.Unlock()
+	m.draining = draining
+	if _, ok := m.backend.services[m.service][m.addr]; ok {
+		m.backend.services[m.service][m.addr] = !draining
+	}
+	return nil
+}
+
+func (m *memRegistry) IsDraining() bool {
+	m.backend.lock.Lock()
+	defer m.backend.lock.Unlock()
+	return m.draining
+}
+
+func (m *memRegistry) Close() {}
+
+func (m *memRegistry) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
+	return "", nil
+}
+
+func TestRunInMemory(t *testing.T) {
+	backend := &memBackend{services: make(map[string]map[string]bool)}
+	Run(t, func(t *testing.T, watch []string) discovery.SvcDiscoveryRegistry {
+		return &memRegistry{backend: backend}
+	})
+}
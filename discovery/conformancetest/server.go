@@ -0,0 +1,94 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformancetest
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// FreeAddr returns a 127.0.0.1 address that no listener used at the time of
+// the call, for a server started with StartServer.
+func FreeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// StartServer runs the backend binary at the path in the environment variable
+// env, or else the one named name on PATH, with args. It returns once addr
+// accepts connections and kills the server when t ends. It skips t when the
+// binary is not found, so that the suite runs against a real backend wherever
+// one is installed.
+func StartServer(t *testing.T, env, name, addr string, args ...string) {
+	t.Helper()
+	bin := os.Getenv(env)
+	if bin == "" {
+		var err error
+		if bin, err = exec.LookPath(name); err != nil {
+			t.Skipf("%s not found on PATH and %s not set", name, env)
+		}
+	}
+	logPath := filepath.Join(t.TempDir(), name+".log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		t.Fatalf("create server log: %v", err)
+	}
+	cmd := exec.Command(bin, args...)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	if err := cmd.Start(); err != nil {
+		_ = logFile.Close()
+		t.Fatalf("start %s: %v", bin, err)
+	}
+	exited := make(chan struct{})
+	var waitErr error
+	go func() {
+		waitErr = cmd.Wait()
+		_ = logFile.Close()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		<-exited
+	})
+	serverLog := func() string {
+		b, _ := os.ReadFile(logPath)
+		return string(b)
+	}
+	deadline := time.Now().Add(Timeout)
+	for {
+		if conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond); err == nil {
+			_ = conn.Close()
+			return
+		}
+		select {
+		case <-exited:
+			t.Fatalf("%s exited before accepting connections: %v\n%s", name, waitErr, serverLog())
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s does not accept connections on %s\n%s", name, addr, serverLog())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
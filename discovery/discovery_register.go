@@ -17,9 +17,14 @@ package discovery
 import (
 	"context"
 
+	"github.com/openimsdk/tools/errs"
 	"google.golang.org/grpc"
 )
 
+// ErrServiceNotFound is returned by GetConns when no instance of the requested
+// service is registered.
+var ErrServiceNotFound = errs.New("service not found")
+
 //
 //type Conn interface {
 //	GetConns(ctx context.Context, serviceName string, opts ...grpc.DialOption) ([]*grpc.ClientConn, error) //1
@@ -0,0 +1,105 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// DefaultWeight is the weight of an instance that is not draining.
+const DefaultWeight = 1
+
+// drainPollInterval is how often DrainAndWait asks the caller if it is idle.
+const drainPollInterval = 100 * time.Millisecond
+
+// Drainer is implemented by registries that can take their own instance out
+// of rotation while it finishes the work it has already accepted. The
+// zookeeper, etcd and nacos registries implement it. The kubernetes registry
+// does not, since the cluster picks the pods that get traffic: there, failing
+// the readiness probe drains the instance.
+type Drainer interface {
+	// SetDraining marks the registered instance as draining, or serving again.
+	// Resolvers of every client exclude draining instances once the change
+	// has propagated through the registry watch.
+	SetDraining(ctx context.Context, draining bool) error
+	// IsDraining reports whether the instance is draining. Readiness probes
+	// should fail while it is; liveness probes should not, the instance is
+	// still healthy.
+	IsDraining() bool
+}
+
+// InstanceMeta is the metadata an instance publishes in the registry.
+type InstanceMeta struct {
+	Addr     string `json:"addr"`
+	Weight   int    `json:"weight"`
+	Draining bool   `json:"draining,omitempty"`
+}
+
+// EncodeInstance returns meta as stored in the registry. Instances with the
+// default weight that are not draining are stored as their bare address, the
+// format clients without metadata support read.
+func EncodeInstance(meta InstanceMeta) []byte {
+	if meta.Draining {
+		meta.Weight = 0
+	} else if meta.Weight == DefaultWeight {
+		return []byte(meta.Addr)
+	}
+	data, _ := json.Marshal(meta)
+	return data
+}
+
+// DecodeInstance parses data written by EncodeInstance.
+func DecodeInstance(data []byte) (InstanceMeta, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return InstanceMeta{Addr: string(data), Weight: DefaultWeight}, nil
+	}
+	var meta InstanceMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return InstanceMeta{}, errs.WrapMsg(err, "invalid instance metadata", "data", string(data))
+	}
+	return meta, nil
+}
+
+// DrainAndWait marks the instance draining, then waits until checkIdle, which
+// typically reads the caller's in-flight request counter, reports no work
+// left. The first check happens one poll interval after SetDraining so that
+// clients have a chance to see the change. It fails with errs.ErrTimeout if
+// the instance is still busy after timeout; the instance stays draining.
+func DrainAndWait(ctx context.Context, d Drainer, checkIdle func() bool, timeout time.Duration) error {
+	if err := d.SetDraining(ctx, true); err != nil {
+		return err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errs.Wrap(ctx.Err())
+		case <-timer.C:
+			return errs.ErrTimeout.WrapMsg("instance still busy after drain timeout", "timeout", timeout)
+		case <-ticker.C:
+			if checkIdle() {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+type fakeDrainer struct {
+	draining atomic.Bool
+}
+
+func (f *fakeDrainer) SetDraining(_ context.Context, draining bool) error {
+	f.draining.Store(draining)
+	return nil
+}
+
+func (f *fakeDrainer) IsDraining() bool { return f.draining.Load() }
+
+func TestInstanceMeta(t *testing.T) {
+	plain := EncodeInstance(InstanceMeta{Addr: "10.0.0.1:10110", Weight: DefaultWeight})
+	if string(plain) != "10.0.0.1:10110" {
+		t.Fatalf("serving instance encoded as %q, want the bare address", plain)
+	}
+	draining := EncodeInstance(InstanceMeta{Addr: "10.0.0.1:10110", Weight: DefaultWeight, Draining: true})
+	for _, data := range [][]byte{plain, draining} {
+		meta, err := DecodeInstance(data)
+		if err != nil || meta.Addr != "10.0.0.1:10110" {
+			t.Fatalf("DecodeInstance(%q) = %+v, %v", data, meta, err)
+		}
+	}
+	if meta, _ := DecodeInstance(draining); !meta.Draining || meta.Weight != 0 {
+		t.Errorf("draining instance decoded as %+v, want weight 0", meta)
+	}
+}
+
+func TestDrainAndWait(t *testing.T) {
+	var (
+		d        fakeDrainer
+		inflight atomic.Int32
+	)
+	inflight.Store(3)
+	ready := func() bool { return !d.IsDraining() }
+	go func() {
+		for inflight.Load() > 0 {
+			time.Sleep(50 * time.Millisecond)
+			inflight.Add(-1)
+		}
+	}()
+	if err := DrainAndWait(context.Background(), &d, func() bool { return inflight.Load() == 0 }, 5*time.Second); err != nil {
+		t.Fatalf("DrainAndWait: %v", err)
+	}
+	if ready() {
+		t.Error("instance ready while draining")
+	}
+	if inflight.Load() != 0 {
+		t.Errorf("returned with %d requests in flight", inflight.Load())
+	}
+}
+
+func TestDrainAndWaitTimeout(t *testing.T) {
+	var d fakeDrainer
+	err := DrainAndWait(context.Background(), &d, func() bool { return false }, 300*time.Millisecond)
+	if !errors.Is(err, errs.ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	if !d.IsDraining() {
+		t.Error("instance stopped draining after the timeout")
+	}
+}
@@ -0,0 +1,41 @@
+package etcd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/tools/discovery"
+	"github.com/openimsdk/tools/discovery/conformancetest"
+)
+
+var _ discovery.Drainer = (*SvcDiscoveryRegistryImpl)(nil)
+
+// TestConformance runs the discovery conformance suite against the etcd
+// cluster given in ETCD_ADDR, e.g. ETCD_ADDR=127.0.0.1:2379, or else against
+// a single member started from the etcd binary on PATH or in ETCD_BIN.
+func TestConformance(t *testing.T) {
+	addr := os.Getenv("ETCD_ADDR")
+	if addr == "" {
+		addr = startEtcd(t)
+	}
+	conformancetest.Run(t, func(t *testing.T, watch []string) discovery.SvcDiscoveryRegistry {
+		r, err := NewSvcDiscoveryRegistry("conformance", strings.Split(addr, ","), watch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
+
+func startEtcd(t *testing.T) string {
+	client, peer := conformancetest.FreeAddr(t), conformancetest.FreeAddr(t)
+	conformancetest.StartServer(t, "ETCD_BIN", "etcd", client,
+		"--data-dir", t.TempDir(),
+		"--listen-client-urls", "http://"+client,
+		"--advertise-client-urls", "http://"+client,
+		"--listen-peer-urls", "http://"+peer,
+		"--initial-advertise-peer-urls", "http://"+peer,
+		"--initial-cluster", "default=http://"+peer)
+	return client
+}
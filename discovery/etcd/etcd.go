@@ -2,16 +2,20 @@ package etcd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/openimsdk/tools/discovery"
 	"github.com/openimsdk/tools/errs"
 	"github.com/openimsdk/tools/log"
 	"github.com/openimsdk/tools/utils/datautil"
+	"github.com/openimsdk/tools/utils/network"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/naming/endpoints"
 	"go.etcd.io/etcd/client/v3/naming/resolver"
@@ -44,6 +48,10 @@ type SvcDiscoveryRegistryImpl struct {
 
 	mu      sync.RWMutex
 	connMap map[string][]*addrConn
+
+	regLock    sync.Mutex
+	registered bool
+	draining   bool
 }
 
 func createNoOpLogger() *zap.Logger {
@@ -88,7 +96,7 @@ func NewSvcDiscoveryRegistry(rootDirectory string, endpoints []string, watchName
 
 	s := &SvcDiscoveryRegistryImpl{
 		client:        client,
-		resolver:      r,
+		resolver:      drainFilter{Builder: r},
 		rootDirectory: rootDirectory,
 		connMap:       make(map[string][]*addrConn),
 		watchNames:    watchNames,
@@ -128,6 +136,9 @@ func (r *SvcDiscoveryRegistryImpl) initializeConnMap(opts ...grpc.DialOption) er
 			if prefix != fullPrefix {
 				continue
 			}
+			if isDraining(kv.Value) {
+				continue
+			}
 
 			if conn, ok := addrMap[addr]; ok {
 				conn.isConnected = true
@@ -200,7 +211,11 @@ func (r *SvcDiscoveryRegistryImpl) GetConns(ctx context.Context, serviceName str
 	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return datautil.Batch(func(t *addrConn) *grpc.ClientConn { return t.conn }, r.connMap[fullServiceKey]), nil
+	conns := r.connMap[fullServiceKey]
+	if len(conns) == 0 {
+		return nil, discovery.ErrServiceNotFound.WrapMsg("no conn for service", "serviceName", serviceName, "rootDirectory", r.rootDirectory)
+	}
+	return datautil.Batch(func(t *addrConn) *grpc.ClientConn { return t.conn }, conns), nil
 }
 
 // GetConn returns a single gRPC client connection for a given service name
@@ -237,6 +252,8 @@ func (r *SvcDiscoveryRegistryImpl) CloseConn(conn *grpc.ClientConn) {
 
 // Register registers a new service endpoint with etcd
 func (r *SvcDiscoveryRegistryImpl) Register(serviceName, host string, port int, opts ...grpc.DialOption) error {
+	r.regLock.Lock()
+	defer r.regLock.Unlock()
 	r.serviceKey = fmt.Sprintf("%s/%s/%s:%d", r.rootDirectory, serviceName, host, port)
 	em, err := endpoints.NewManager(r.client, r.rootDirectory+"/"+serviceName)
 	if err != nil {
@@ -251,12 +268,12 @@ func (r *SvcDiscoveryRegistryImpl) Register(serviceName, host string, port int,
 	r.leaseID = leaseResp.ID
 
 	r.rpcRegisterTarget = fmt.Sprintf("%s:%d", host, port)
-	endpoint := endpoints.Endpoint{Addr: r.rpcRegisterTarget}
 
-	err = em.AddEndpoint(context.TODO(), r.serviceKey, endpoint, clientv3.WithLease(leaseResp.ID))
+	err = em.AddEndpoint(context.TODO(), r.serviceKey, r.endpoint(), clientv3.WithLease(leaseResp.ID))
 	if err != nil {
 		return err
 	}
+	r.registered = true
 
 	go r.keepAliveLease(r.leaseID)
 	return nil
@@ -301,8 +318,82 @@ func (r *SvcDiscoveryRegistryImpl) splitEndpoint(input string) (string, string)
 	return input, ""
 }
 
+// endpoint returns the endpoint Register publishes. A draining instance
+// carries its discovery.InstanceMeta as the endpoint metadata.
+func (r *SvcDiscoveryRegistryImpl) endpoint() endpoints.Endpoint {
+	endpoint := endpoints.Endpoint{Addr: r.rpcRegisterTarget}
+	if r.draining {
+		endpoint.Metadata = discovery.InstanceMeta{Addr: r.rpcRegisterTarget, Draining: true}
+	}
+	return endpoint
+}
+
+// SetDraining publishes the draining state in the metadata of the registered
+// endpoint. Clients drop the instance from GetConns and from the resolvers of
+// GetConn on the resulting watch event. The state applies to later
+// registrations too.
+func (r *SvcDiscoveryRegistryImpl) SetDraining(ctx context.Context, draining bool) error {
+	r.regLock.Lock()
+	defer r.regLock.Unlock()
+	r.draining = draining
+	if !r.registered {
+		return nil
+	}
+	if err := r.endpointMgr.AddEndpoint(ctx, r.serviceKey, r.endpoint(), clientv3.WithLease(r.leaseID)); err != nil {
+		return errs.WrapMsg(err, "update endpoint error", "key", r.serviceKey, "draining", draining)
+	}
+	log.ZInfo(ctx, "etcd instance draining state changed", "key", r.serviceKey, "draining", draining)
+	return nil
+}
+
+// IsDraining reports whether SetDraining(ctx, true) is in effect.
+func (r *SvcDiscoveryRegistryImpl) IsDraining() bool {
+	r.regLock.Lock()
+	defer r.regLock.Unlock()
+	return r.draining
+}
+
+// isDraining reports whether value, an endpoint stored by Register, belongs
+// to a draining instance.
+func isDraining(value []byte) bool {
+	var endpoint struct {
+		Metadata discovery.InstanceMeta
+	}
+	_ = json.Unmarshal(value, &endpoint)
+	return endpoint.Metadata.Draining
+}
+
+// drainFilter wraps the etcd resolver builder so that the resolvers of GetConn
+// leave out the addresses of draining instances.
+type drainFilter struct {
+	gresolver.Builder
+}
+
+func (b drainFilter) Build(target gresolver.Target, cc gresolver.ClientConn, opts gresolver.BuildOptions) (gresolver.Resolver, error) {
+	return b.Builder.Build(target, drainFilterConn{ClientConn: cc}, opts)
+}
+
+type drainFilterConn struct {
+	gresolver.ClientConn
+}
+
+func (c drainFilterConn) UpdateState(state gresolver.State) error {
+	addrs := make([]gresolver.Address, 0, len(state.Addresses))
+	for _, addr := range state.Addresses {
+		// The etcd resolver passes the endpoint metadata as decoded JSON.
+		if md, ok := addr.Metadata.(map[string]any); ok && md["draining"] == true {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	state.Addresses = addrs
+	return c.ClientConn.UpdateState(state)
+}
+
 // UnRegister removes the service endpoint from etcd
 func (r *SvcDiscoveryRegistryImpl) UnRegister() error {
+	r.regLock.Lock()
+	defer r.regLock.Unlock()
 	if r.endpointMgr == nil {
 		return fmt.Errorf("endpoint manager is not initialized")
 	}
@@ -310,6 +401,7 @@ func (r *SvcDiscoveryRegistryImpl) UnRegister() error {
 	if err != nil {
 		return err
 	}
+	r.registered = false
 	return nil
 }
 
@@ -325,6 +417,10 @@ func (r *SvcDiscoveryRegistryImpl) Close() {
 
 // Check verifies if etcd is running by checking the existence of the root node and optionally creates it with a lease
 func Check(ctx context.Context, etcdServers []string, etcdRoot string, createIfNotExist bool, options ...ZkOption) error {
+	etcdServers, err := normalizeEndpoints(etcdServers)
+	if err != nil {
+		return err
+	}
 	cfg := clientv3.Config{
 		Endpoints: etcdServers,
 	}
@@ -377,6 +473,33 @@ func Check(ctx context.Context, etcdServers []string, etcdRoot string, createIfN
 	return nil
 }
 
+// normalizeEndpoints validates etcd endpoints, which are either host:port or URLs.
+func normalizeEndpoints(endpoints []string) ([]string, error) {
+	var res []string
+	for _, endpoint := range endpoints {
+		if !strings.Contains(endpoint, "://") {
+			addrs, err := network.SplitHostPortList(endpoint)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, addrs...)
+			continue
+		}
+		u, err := url.Parse(strings.TrimSpace(endpoint))
+		if err != nil {
+			return nil, errs.ErrConfig.WrapMsg("invalid etcd endpoint", "endpoint", endpoint, "err", err.Error())
+		}
+		if err := network.ValidateHost(u.Host); err != nil {
+			return nil, err
+		}
+		res = append(res, u.String())
+	}
+	if len(res) == 0 {
+		return nil, errs.ErrConfig.WrapMsg("etcd endpoints are empty")
+	}
+	return res, nil
+}
+
 func (r *SvcDiscoveryRegistryImpl) GetClient() *clientv3.Client {
 	return r.client
 }
@@ -0,0 +1,72 @@
+package etcd
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+	"go.etcd.io/etcd/client/v3/naming/endpoints"
+	gresolver "google.golang.org/grpc/resolver"
+)
+
+func TestNormalizeEndpoints(t *testing.T) {
+	got, err := normalizeEndpoints([]string{"10.0.0.1:2379", "[fd00::1]:2379,etcd-1:2379", "https://[fd00::2]:2379", "http://etcd-2:2379"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1:2379", "[fd00::1]:2379", "etcd-1:2379", "https://[fd00::2]:2379", "http://etcd-2:2379"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, bad := range []string{"fd00::1:2379", "http://fd00::1:2379", ""} {
+		_, err := normalizeEndpoints([]string{bad})
+		var codeErr errs.CodeError
+		if !errors.As(err, &codeErr) || codeErr.Code() != errs.ConfigError {
+			t.Errorf("normalizeEndpoints(%q) err = %v, want ErrConfig", bad, err)
+		}
+	}
+}
+
+type stateRecorder struct {
+	gresolver.ClientConn
+	state gresolver.State
+}
+
+func (s *stateRecorder) UpdateState(state gresolver.State) error {
+	s.state = state
+	return nil
+}
+
+func TestDrainingEndpoints(t *testing.T) {
+	serving := &SvcDiscoveryRegistryImpl{rpcRegisterTarget: "10.0.0.1:10110"}
+	draining := &SvcDiscoveryRegistryImpl{rpcRegisterTarget: "10.0.0.2:10110", draining: true}
+	var addrs []gresolver.Address
+	for _, r := range []*SvcDiscoveryRegistryImpl{serving, draining} {
+		value, err := json.Marshal(r.endpoint())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isDraining(value); got != r.draining {
+			t.Errorf("isDraining(%s) = %v, want %v", value, got, r.draining)
+		}
+		// Decode the endpoint the way the etcd resolver does.
+		var endpoint endpoints.Endpoint
+		if err := json.Unmarshal(value, &endpoint); err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, gresolver.Address{Addr: endpoint.Addr, Metadata: endpoint.Metadata})
+	}
+	if isDraining([]byte("not json")) {
+		t.Error("a value that is not an endpoint is draining")
+	}
+
+	cc := &stateRecorder{}
+	if err := (drainFilterConn{ClientConn: cc}).UpdateState(gresolver.State{Addresses: addrs}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cc.state.Addresses) != 1 || cc.state.Addresses[0].Addr != "10.0.0.1:10110" {
+		t.Fatalf("resolver addresses = %v, want only the serving instance", cc.state.Addresses)
+	}
+}
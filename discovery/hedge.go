@@ -0,0 +1,159 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/statreg"
+	"google.golang.org/grpc"
+)
+
+// MetricHedgedCalls is the counter of the HedgedCall calls reported to the
+// recorder of WithHedgeMetrics, by service, method and outcome.
+const MetricHedgedCalls = "discovery_hedged_calls"
+
+// Outcomes of a HedgedCall, see MetricHedgedCalls.
+const (
+	HedgeNotIssued = "not_issued" // the first call ended before the hedge delay
+	HedgeLost      = "lost"       // the hedge was issued, its response was not used
+	HedgeWon       = "won"        // the response of the hedge was used
+)
+
+var (
+	idempotentLock    sync.RWMutex
+	idempotentMethods = make(map[string]struct{})
+)
+
+// RegisterIdempotent allows HedgedCall to issue the given full method names,
+// such as "/openim.msg.msg/PullMessageBySeqs". Only register methods that are
+// safe to execute twice.
+func RegisterIdempotent(methods ...string) {
+	idempotentLock.Lock()
+	defer idempotentLock.Unlock()
+	for _, method := range methods {
+		idempotentMethods[method] = struct{}{}
+	}
+}
+
+func isIdempotent(method string) bool {
+	idempotentLock.RLock()
+	defer idempotentLock.RUnlock()
+	_, ok := idempotentMethods[method]
+	return ok
+}
+
+type hedgeOptions struct {
+	metrics  statreg.Recorder
+	callOpts []grpc.CallOption
+}
+
+type HedgeOption func(*hedgeOptions)
+
+// WithHedgeMetrics reports every call to recorder, see MetricHedgedCalls.
+func WithHedgeMetrics(recorder statreg.Recorder) HedgeOption {
+	return func(o *hedgeOptions) {
+		o.metrics = recorder
+	}
+}
+
+// WithHedgeCallOptions passes opts to both calls.
+func WithHedgeCallOptions(opts ...grpc.CallOption) HedgeOption {
+	return func(o *hedgeOptions) {
+		o.callOpts = append(o.callOpts, opts...)
+	}
+}
+
+// hedgePick chooses the instance of the first call; replaced in tests.
+var hedgePick = rand.Intn
+
+type hedgeResult struct {
+	resp  any
+	err   error
+	hedge bool
+}
+
+// HedgedCall invokes method on one instance of service and, if no response
+// arrived after hedgeDelay, on a second instance too. The first successful
+// response is returned and the other call is cancelled. If the first call
+// fails before the hedge is issued its error is returned, since hedging is
+// meant for slow instances, not as a retry. respFactory returns a new response
+// message for each call. method must have been registered with
+// RegisterIdempotent.
+func HedgedCall(ctx context.Context, conn Conn, service, method string, req any, respFactory func() any, hedgeDelay time.Duration, opts ...HedgeOption) (any, error) {
+	if !isIdempotent(method) {
+		return nil, errs.ErrArgs.WrapMsg("method is not registered as idempotent", "method", method)
+	}
+	var o hedgeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	conns, err := conn.GetConns(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	if len(conns) == 0 {
+		return nil, errs.WrapMsg(ErrServiceNotFound, "no instance to call", "service", service)
+	}
+	first := hedgePick(len(conns))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan hedgeResult, 2)
+	call := func(cc *grpc.ClientConn, hedge bool) {
+		resp := respFactory()
+		err := cc.Invoke(ctx, method, req, resp, o.callOpts...)
+		results <- hedgeResult{resp: resp, err: err, hedge: hedge}
+	}
+	go call(conns[first], false)
+
+	var timer <-chan time.Time
+	if len(conns) > 1 {
+		t := time.NewTimer(hedgeDelay)
+		defer t.Stop()
+		timer = t.C
+	}
+	hedged, pending := false, 1
+	for {
+		select {
+		case <-timer:
+			timer = nil
+			hedged = true
+			pending++
+			go call(conns[(first+1+hedgePick(len(conns)-1))%len(conns)], true)
+		case res := <-results:
+			pending--
+			if res.err == nil || pending == 0 || !hedged {
+				if o.metrics != nil {
+					outcome := HedgeNotIssued
+					if res.err == nil && res.hedge {
+						outcome = HedgeWon
+					} else if hedged {
+						outcome = HedgeLost
+					}
+					o.metrics.Inc(MetricHedgedCalls, service, method, outcome)
+				}
+				if res.err != nil {
+					return nil, res.err
+				}
+				return res.resp, nil
+			}
+		}
+	}
+}
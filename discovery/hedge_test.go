@@ -0,0 +1,160 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/leaktest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const pullMethod = "/test.Msg/Pull"
+
+type backend struct {
+	name      string
+	delay     time.Duration
+	calls     chan struct{}
+	cancelled chan struct{}
+}
+
+// startBackend serves pullMethod, answering with the backend name after delay.
+func startBackend(t *testing.T, name string, delay time.Duration) (*backend, *grpc.ClientConn) {
+	t.Helper()
+	b := &backend{name: name, delay: delay, calls: make(chan struct{}, 10), cancelled: make(chan struct{}, 10)}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Msg",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Pull",
+			Handler: func(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+				var req wrapperspb.StringValue
+				if err := dec(&req); err != nil {
+					return nil, err
+				}
+				b.calls <- struct{}{}
+				select {
+				case <-time.After(b.delay):
+					return wrapperspb.String(b.name), nil
+				case <-ctx.Done():
+					b.cancelled <- struct{}{}
+					return nil, ctx.Err()
+				}
+			},
+		}},
+	}, struct{}{})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	cc, err := grpc.Dial("passthrough:///"+name,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cc.Close() })
+	return b, cc
+}
+
+type staticConns struct {
+	Conn
+	conns []*grpc.ClientConn
+}
+
+func (s staticConns) GetConns(context.Context, string, ...grpc.DialOption) ([]*grpc.ClientConn, error) {
+	return s.conns, nil
+}
+
+type recorder struct {
+	lock     sync.Mutex
+	outcomes []string
+}
+
+func (r *recorder) Inc(name string, labels ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if name == MetricHedgedCalls && len(labels) == 3 && labels[0] == "msg" && labels[1] == pullMethod {
+		r.outcomes = append(r.outcomes, labels[2])
+	}
+}
+
+func (r *recorder) ObserveDuration(string, time.Duration, ...string) {}
+
+func pickFirst(t *testing.T) {
+	old := hedgePick
+	hedgePick = func(int) int { return 0 }
+	t.Cleanup(func() { hedgePick = old })
+}
+
+func newString() any { return &wrapperspb.StringValue{} }
+
+func TestHedgedCallSlowPrimary(t *testing.T) {
+	leaktest.Check(t)
+	pickFirst(t)
+	RegisterIdempotent(pullMethod)
+	slow, slowConn := startBackend(t, "slow", 10*time.Second)
+	fast, fastConn := startBackend(t, "fast", 0)
+	rec := &recorder{}
+
+	start := time.Now()
+	resp, err := HedgedCall(context.Background(), staticConns{conns: []*grpc.ClientConn{slowConn, fastConn}},
+		"msg", pullMethod, wrapperspb.String("req"), newString, 50*time.Millisecond, WithHedgeMetrics(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.(*wrapperspb.StringValue).GetValue(); got != "fast" {
+		t.Fatalf("response from %s, want fast", got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("call took %s, hedge did not fire", elapsed)
+	}
+	if len(slow.calls) != 1 || len(fast.calls) != 1 {
+		t.Fatalf("calls: slow=%d fast=%d", len(slow.calls), len(fast.calls))
+	}
+	select {
+	case <-slow.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancellation did not reach the slow backend")
+	}
+	if len(rec.outcomes) != 1 || rec.outcomes[0] != HedgeWon {
+		t.Fatalf("metrics = %+v", rec.outcomes)
+	}
+}
+
+func TestHedgedCallFastPrimary(t *testing.T) {
+	leaktest.Check(t)
+	pickFirst(t)
+	RegisterIdempotent(pullMethod)
+	fast, fastConn := startBackend(t, "fast", 0)
+	other, otherConn := startBackend(t, "other", 0)
+	rec := &recorder{}
+
+	resp, err := HedgedCall(context.Background(), staticConns{conns: []*grpc.ClientConn{fastConn, otherConn}},
+		"msg", pullMethod, wrapperspb.String("req"), newString, time.Second, WithHedgeMetrics(rec))
+	if err != nil || resp.(*wrapperspb.StringValue).GetValue() != "fast" {
+		t.Fatalf("resp = %v, %v", resp, err)
+	}
+	if len(fast.calls) != 1 || len(other.calls) != 0 {
+		t.Fatalf("hedge fired for a fast primary: fast=%d other=%d", len(fast.calls), len(other.calls))
+	}
+	if len(rec.outcomes) != 1 || rec.outcomes[0] != HedgeNotIssued {
+		t.Fatalf("metrics = %+v", rec.outcomes)
+	}
+}
+
+func TestHedgedCallRequiresIdempotent(t *testing.T) {
+	leaktest.Check(t)
+	_, err := HedgedCall(context.Background(), staticConns{}, "msg", "/test.Msg/Send", nil, newString, time.Millisecond)
+	var codeErr errs.CodeError
+	if !errors.As(err, &codeErr) || codeErr.Code() != errs.ArgsError {
+		t.Fatalf("err = %v, want ArgsError", err)
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nacos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// beatCodeNotFound is returned by the beat API when the instance is unknown,
+// for example after its beats were missed during an outage.
+const beatCodeNotFound = 20404
+
+// drainingKey is the metadata key set to "true" on a draining instance.
+const drainingKey = "draining"
+
+// instance is a service instance as returned by the instance list API.
+type instance struct {
+	IP       string            `json:"ip"`
+	Port     int               `json:"port"`
+	Weight   float64           `json:"weight"`
+	Healthy  bool              `json:"healthy"`
+	Enabled  bool              `json:"enabled"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type beatInfo struct {
+	ServiceName string            `json:"serviceName"`
+	IP          string            `json:"ip"`
+	Port        int               `json:"port"`
+	Cluster     string            `json:"cluster"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Scheduled   bool              `json:"scheduled"`
+}
+
+// api is a client of the Nacos open API (v1). It fails over between servers
+// and logs in again when the access token expires.
+type api struct {
+	conf    *Config
+	servers []string
+	client  *http.Client
+
+	lock        sync.Mutex
+	current     int
+	token       string
+	tokenExpiry time.Time
+}
+
+func newAPI(conf *Config) (*api, error) {
+	servers := make([]string, 0, len(conf.ServerAddrs))
+	for _, addr := range conf.ServerAddrs {
+		u, err := serverURL(addr)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, u)
+	}
+	return &api{conf: conf, servers: servers, client: &http.Client{Timeout: conf.RequestTimeout}}, nil
+}
+
+// statusError is a non-2xx response of the Nacos API.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return "nacos responded " + strconv.Itoa(e.code) + ": " + e.body
+}
+
+func isAuthError(err error) bool {
+	se, ok := err.(*statusError)
+	return ok && (se.code == http.StatusUnauthorized || se.code == http.StatusForbidden)
+}
+
+// do calls path on the current server, moving on to the next server on
+// network errors and 5xx responses.
+func (a *api) do(ctx context.Context, method, path string, params url.Values) ([]byte, error) {
+	var lastErr error
+	for i := 0; i < len(a.servers); i++ {
+		a.lock.Lock()
+		server := a.servers[a.current]
+		a.lock.Unlock()
+
+		body, err := a.doServer(ctx, server, method, path, params)
+		if err == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			return nil, errs.WrapMsg(ctx.Err(), "nacos request canceled", "server", server, "path", path)
+		}
+		if errors.Is(err, errs.ErrNoPermission) {
+			return nil, err
+		}
+		if isAuthError(err) {
+			return nil, errs.ErrNoPermission.WrapMsg("nacos denied access", "server", server, "path", path, "err", err.Error())
+		}
+		if se, ok := err.(*statusError); ok && se.code < http.StatusInternalServerError {
+			return nil, errs.WrapMsg(err, "nacos request failed", "server", server, "path", path)
+		}
+		lastErr = err
+		a.lock.Lock()
+		if a.servers[a.current] == server {
+			a.current = (a.current + 1) % len(a.servers)
+		}
+		a.lock.Unlock()
+	}
+	return nil, errs.ErrDependencyUnavailable.WrapMsg("nacos unreachable", "servers", a.servers, "err", lastErr.Error())
+}
+
+func (a *api) doServer(ctx context.Context, server, method, path string, params url.Values) ([]byte, error) {
+	token, err := a.accessToken(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	for k, v := range params {
+		q[k] = v
+	}
+	if token != "" {
+		q.Set("accessToken", token)
+	}
+	if a.conf.Namespace != "" {
+		q.Set("namespaceId", a.conf.Namespace)
+	}
+	body, err := a.send(ctx, method, server+path, q)
+	if isAuthError(err) && token != "" {
+		// The token may have been revoked or the server restarted; log in again once.
+		a.lock.Lock()
+		a.token = ""
+		a.lock.Unlock()
+		if token, err = a.accessToken(ctx, server); err != nil {
+			return nil, err
+		}
+		q.Set("accessToken", token)
+		body, err = a.send(ctx, method, server+path, q)
+	}
+	return body, err
+}
+
+func (a *api) send(ctx context.Context, method, rawURL string, q url.Values) ([]byte, error) {
+	var (
+		req *http.Request
+		err error
+	)
+	if method == http.MethodPost {
+		req, err = http.NewRequestWithContext(ctx, method, rawURL, strings.NewReader(q.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, rawURL+"?"+q.Encode(), nil)
+	}
+	if err != nil {
+		return nil, errs.WrapMsg(err, "build nacos request failed", "url", rawURL)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
+
+// accessToken returns a valid token, logging in if needed. It returns an
+// empty token when no username is configured.
+func (a *api) accessToken(ctx context.Context, server string) (string, error) {
+	if a.conf.Username == "" {
+		return "", nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.token != "" && time.Now().Before(a.tokenExpiry) {
+		return a.token, nil
+	}
+	form := url.Values{"username": {a.conf.Username}, "password": {a.conf.Password}}
+	body, err := a.send(ctx, http.MethodPost, server+"/v1/auth/login", form)
+	if err != nil {
+		if isAuthError(err) {
+			return "", errs.ErrNoPermission.WrapMsg("nacos login failed", "server", server, "username", a.conf.Username)
+		}
+		return "", err
+	}
+	var resp struct {
+		AccessToken string `json:"accessToken"`
+		TokenTTL    int64  `json:"tokenTtl"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.AccessToken == "" {
+		return "", errs.ErrNoPermission.WrapMsg("nacos login returned no token", "server", server)
+	}
+	a.token = resp.AccessToken
+	// Renew a little before the server side expiry.
+	a.tokenExpiry = time.Now().Add(time.Duration(resp.TokenTTL) * time.Second * 9 / 10)
+	return a.token, nil
+}
+
+func (a *api) instanceParams(service, ip string, port int) url.Values {
+	return url.Values{
+		"serviceName": {service},
+		"groupName":   {a.conf.Group},
+		"clusterName": {a.conf.Cluster},
+		"ip":          {ip},
+		"port":        {strconv.Itoa(port)},
+		"ephemeral":   {"true"},
+	}
+}
+
+// instanceForm returns the parameters that register or update an instance.
+// A draining instance gets weight 0, which other Nacos clients skip too.
+func (a *api) instanceForm(service, ip string, port int, metadata map[string]string, draining bool) (url.Values, error) {
+	params := a.instanceParams(service, ip, port)
+	params.Set("healthy", "true")
+	params.Set("enabled", "true")
+	params.Set("weight", "1")
+	if draining {
+		params.Set("weight", "0")
+	}
+	if len(metadata) > 0 {
+		md, err := json.Marshal(metadata)
+		if err != nil {
+			return nil, errs.WrapMsg(err, "marshal metadata failed")
+		}
+		params.Set("metadata", string(md))
+	}
+	return params, nil
+}
+
+func (a *api) register(ctx context.Context, service, ip string, port int, metadata map[string]string, draining bool) error {
+	params, err := a.instanceForm(service, ip, port, metadata, draining)
+	if err != nil {
+		return err
+	}
+	_, err = a.do(ctx, http.MethodPost, "/v1/ns/instance", params)
+	return err
+}
+
+// update replaces the weight and metadata of a registered instance.
+func (a *api) update(ctx context.Context, service, ip string, port int, metadata map[string]string, draining bool) error {
+	params, err := a.instanceForm(service, ip, port, metadata, draining)
+	if err != nil {
+		return err
+	}
+	_, err = a.do(ctx, http.MethodPut, "/v1/ns/instance", params)
+	return err
+}
+
+func (a *api) deregister(ctx context.Context, service, ip string, port int) error {
+	_, err := a.do(ctx, http.MethodDelete, "/v1/ns/instance", a.instanceParams(service, ip, port))
+	return err
+}
+
+// beat sends a heartbeat and reports whether the server knows the instance.
+func (a *api) beat(ctx context.Context, service, ip string, port int, metadata map[string]string) (bool, error) {
+	beat, err := json.Marshal(beatInfo{ServiceName: a.conf.Group + "@@" + service, IP: ip, Port: port,
+		Cluster: a.conf.Cluster, Metadata: metadata, Scheduled: true})
+	if err != nil {
+		return false, errs.WrapMsg(err, "marshal beat failed")
+	}
+	params := url.Values{
+		"serviceName": {service},
+		"groupName":   {a.conf.Group},
+		"ephemeral":   {"true"},
+		"beat":        {string(beat)},
+	}
+	body, err := a.do(ctx, http.MethodPut, "/v1/ns/instance/beat", params)
+	if err != nil {
+		return false, err
+	}
+	var resp struct {
+		Code int `json:"code"`
+	}
+	_ = json.Unmarshal(body, &resp)
+	return resp.Code != beatCodeNotFound, nil
+}
+
+// list returns the healthy, enabled instances of service that are not draining.
+func (a *api) list(ctx context.Context, service string) ([]instance, error) {
+	params := url.Values{
+		"serviceName": {service},
+		"groupName":   {a.conf.Group},
+		"healthyOnly": {"true"},
+	}
+	body, err := a.do(ctx, http.MethodGet, "/v1/ns/instance/list", params)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Hosts []instance `json:"hosts"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, errs.WrapMsg(err, "decode nacos instance list failed", "service", service)
+	}
+	hosts := resp.Hosts[:0]
+	for _, h := range resp.Hosts {
+		if h.Healthy && h.Enabled && h.Metadata[drainingKey] != "true" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, nil
+}
@@ -0,0 +1,122 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nacos
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openimsdk/tools/env"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/network"
+)
+
+const (
+	DefaultGroup   = "DEFAULT_GROUP"
+	DefaultCluster = "DEFAULT"
+
+	defaultRefreshInterval   = 5 * time.Second
+	defaultHeartbeatInterval = 5 * time.Second
+	defaultStaleLimit        = time.Minute
+	defaultRequestTimeout    = 5 * time.Second
+)
+
+// Config is the nacos block of the service configuration.
+type Config struct {
+	// ServerAddrs are the host:port addresses of the Nacos servers, or URLs
+	// when a scheme or context path other than http and /nacos is needed.
+	ServerAddrs []string `yaml:"serverAddrs"`
+	Namespace   string   `yaml:"namespace"`
+	Group       string   `yaml:"group"`
+	Cluster     string   `yaml:"cluster"`
+	Username    string   `yaml:"username"`
+	Password    string   `yaml:"password"`
+
+	// RefreshInterval is how often the instances of subscribed services are
+	// fetched again.
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+	// HeartbeatInterval is how often registered instances send a beat.
+	HeartbeatInterval time.Duration `yaml:"heartbeatInterval"`
+	// StaleLimit is how long the last known instances of a service are served
+	// while Nacos cannot be reached. After that GetConns fails.
+	StaleLimit time.Duration `yaml:"staleLimit"`
+	// RequestTimeout bounds every call to the Nacos API.
+	RequestTimeout time.Duration `yaml:"requestTimeout"`
+}
+
+// ApplyEnv overrides the configuration with the NACOS_SERVER_ADDRS (comma
+// separated), NACOS_NAMESPACE, NACOS_GROUP, NACOS_CLUSTER, NACOS_USERNAME and
+// NACOS_PASSWORD environment variables, when set.
+func (c *Config) ApplyEnv() {
+	if addrs := env.GetString("NACOS_SERVER_ADDRS", ""); addrs != "" {
+		c.ServerAddrs = strings.Split(addrs, ",")
+	}
+	c.Namespace = env.GetString("NACOS_NAMESPACE", c.Namespace)
+	c.Group = env.GetString("NACOS_GROUP", c.Group)
+	c.Cluster = env.GetString("NACOS_CLUSTER", c.Cluster)
+	c.Username = env.GetString("NACOS_USERNAME", c.Username)
+	c.Password = env.GetString("NACOS_PASSWORD", c.Password)
+}
+
+// ValidateAndSetDefaults validates the configuration and sets default values.
+func (c *Config) ValidateAndSetDefaults() error {
+	if len(c.ServerAddrs) == 0 {
+		return errs.ErrConfig.WrapMsg("nacos serverAddrs is empty")
+	}
+	for _, addr := range c.ServerAddrs {
+		if _, err := serverURL(addr); err != nil {
+			return err
+		}
+	}
+	if c.Group == "" {
+		c.Group = DefaultGroup
+	}
+	if c.Cluster == "" {
+		c.Cluster = DefaultCluster
+	}
+	if c.RefreshInterval <= 0 {
+		c.RefreshInterval = defaultRefreshInterval
+	}
+	if c.HeartbeatInterval <= 0 {
+		c.HeartbeatInterval = defaultHeartbeatInterval
+	}
+	if c.StaleLimit <= 0 {
+		c.StaleLimit = defaultStaleLimit
+	}
+	if c.RequestTimeout <= 0 {
+		c.RequestTimeout = defaultRequestTimeout
+	}
+	return nil
+}
+
+// serverURL returns the API base URL of a server address.
+func serverURL(addr string) (string, error) {
+	addr = strings.TrimSpace(addr)
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return "", errs.ErrConfig.WrapMsg("invalid nacos server address", "addr", addr, "err", err.Error())
+		}
+		if err := network.ValidateHost(u.Host); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(addr, "/"), nil
+	}
+	if _, _, err := network.SplitHostPort(addr); err != nil {
+		return "", err
+	}
+	return "http://" + addr + "/nacos", nil
+}
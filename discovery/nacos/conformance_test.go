@@ -0,0 +1,28 @@
+package nacos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/discovery"
+	"github.com/openimsdk/tools/discovery/conformancetest"
+)
+
+var _ discovery.Drainer = (*SvcDiscoveryRegistryImpl)(nil)
+
+func TestConformance(t *testing.T) {
+	_, addr := newFakeNacos(t, "nacos", "secret")
+	conformancetest.Run(t, func(t *testing.T, watch []string) discovery.SvcDiscoveryRegistry {
+		r, err := NewSvcDiscoveryRegistry(Config{
+			ServerAddrs:     []string{addr},
+			Namespace:       "conformance",
+			Username:        "nacos",
+			Password:        "secret",
+			RefreshInterval: 100 * time.Millisecond,
+		}, watch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
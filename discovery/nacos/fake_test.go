@@ -0,0 +1,154 @@
+package nacos
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeNacos implements the part of the Nacos open API used by this package.
+type fakeNacos struct {
+	username, password string
+	down               atomic.Bool
+
+	lock      sync.Mutex
+	tokens    map[string]bool
+	instances map[string]map[string]instance // namespace/group/service -> ip:port -> instance
+	beats     int
+}
+
+func newFakeNacos(t *testing.T, username, password string) (*fakeNacos, string) {
+	f := &fakeNacos{
+		username:  username,
+		password:  password,
+		tokens:    make(map[string]bool),
+		instances: make(map[string]map[string]instance),
+	}
+	srv := httptest.NewServer(http.StripPrefix("/nacos", f))
+	t.Cleanup(srv.Close)
+	return f, strings.TrimPrefix(srv.URL, "http://")
+}
+
+func (f *fakeNacos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.down.Load() {
+		http.Error(w, "server is down", http.StatusServiceUnavailable)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Path == "/v1/auth/login" {
+		f.login(w, r)
+		return
+	}
+	if f.username != "" {
+		f.lock.Lock()
+		ok := f.tokens[r.Form.Get("accessToken")]
+		f.lock.Unlock()
+		if !ok {
+			http.Error(w, "unknown user!", http.StatusForbidden)
+			return
+		}
+	}
+	switch r.Method + " " + r.URL.Path {
+	case "GET /v1/console/health/readiness":
+		_, _ = w.Write([]byte("OK"))
+	case "GET /v1/ns/service/list":
+		_, _ = w.Write([]byte(`{"count":0,"doms":[]}`))
+	case "POST /v1/ns/instance", "PUT /v1/ns/instance":
+		port, _ := strconv.Atoi(r.Form.Get("port"))
+		weight, _ := strconv.ParseFloat(r.Form.Get("weight"), 64)
+		inst := instance{IP: r.Form.Get("ip"), Port: port, Weight: weight, Healthy: true, Enabled: true}
+		if md := r.Form.Get("metadata"); md != "" {
+			_ = json.Unmarshal([]byte(md), &inst.Metadata)
+		}
+		addr := net.JoinHostPort(inst.IP, r.Form.Get("port"))
+		f.lock.Lock()
+		key := f.key(r)
+		if _, ok := f.instances[key][addr]; !ok && r.Method == http.MethodPut {
+			f.lock.Unlock()
+			http.Error(w, "no ips found for cluster", http.StatusBadRequest)
+			return
+		}
+		if f.instances[key] == nil {
+			f.instances[key] = make(map[string]instance)
+		}
+		f.instances[key][addr] = inst
+		f.lock.Unlock()
+		_, _ = w.Write([]byte("ok"))
+	case "DELETE /v1/ns/instance":
+		f.lock.Lock()
+		delete(f.instances[f.key(r)], net.JoinHostPort(r.Form.Get("ip"), r.Form.Get("port")))
+		f.lock.Unlock()
+		_, _ = w.Write([]byte("ok"))
+	case "PUT /v1/ns/instance/beat":
+		var beat beatInfo
+		_ = json.Unmarshal([]byte(r.Form.Get("beat")), &beat)
+		f.lock.Lock()
+		f.beats++
+		_, ok := f.instances[f.key(r)][net.JoinHostPort(beat.IP, strconv.Itoa(beat.Port))]
+		f.lock.Unlock()
+		code := 10200
+		if !ok {
+			code = beatCodeNotFound
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"clientBeatInterval": 5000, "code": code})
+	case "GET /v1/ns/instance/list":
+		f.lock.Lock()
+		hosts := make([]instance, 0)
+		for _, inst := range f.instances[f.key(r)] {
+			hosts = append(hosts, inst)
+		}
+		f.lock.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": r.Form.Get("serviceName"), "hosts": hosts})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeNacos) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.PostForm.Get("username") != f.username || r.PostForm.Get("password") != f.password {
+		http.Error(w, "unknown user!", http.StatusForbidden)
+		return
+	}
+	f.lock.Lock()
+	token := "token-" + strconv.Itoa(len(f.tokens))
+	f.tokens[token] = true
+	f.lock.Unlock()
+	_ = json.NewEncoder(w).Encode(map[string]any{"accessToken": token, "tokenTtl": 18000, "globalAdmin": false})
+}
+
+func (f *fakeNacos) key(r *http.Request) string {
+	return r.Form.Get("namespaceId") + "/" + r.Form.Get("groupName") + "/" + r.Form.Get("serviceName")
+}
+
+// remove drops an instance, like the server does when its beats time out.
+func (f *fakeNacos) remove(service, addr string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for key, insts := range f.instances {
+		if strings.HasSuffix(key, "/"+service) {
+			delete(insts, addr)
+		}
+	}
+}
+
+func (f *fakeNacos) instance(service, addr string) (instance, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for key, insts := range f.instances {
+		if strings.HasSuffix(key, "/"+service) {
+			if inst, ok := insts[addr]; ok {
+				return inst, true
+			}
+		}
+	}
+	return instance{}, false
+}
@@ -0,0 +1,418 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nacos implements discovery.SvcDiscoveryRegistry on top of the Nacos
+// naming service, using its HTTP open API.
+//
+// Registered instances are ephemeral and kept alive by heartbeats. Services
+// that are resolved are subscribed to and refreshed periodically; while Nacos
+// cannot be reached the last known instances keep being served for up to
+// Config.StaleLimit.
+package nacos
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openimsdk/tools/discovery"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"google.golang.org/grpc"
+)
+
+const scheme = "nacos"
+
+type Option func(*SvcDiscoveryRegistryImpl)
+
+// WithDialOptions sets the gRPC dial options used for every connection.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(r *SvcDiscoveryRegistryImpl) {
+		r.dialOptions = append(r.dialOptions, opts...)
+	}
+}
+
+// WithMetadata sets the instance metadata published by Register.
+func WithMetadata(md map[string]string) Option {
+	return func(r *SvcDiscoveryRegistryImpl) {
+		r.metadata = md
+	}
+}
+
+type serviceState struct {
+	addrs     []string
+	lastSync  time.Time
+	conns     []*grpc.ClientConn
+	resolvers map[*nacosResolver]struct{}
+	stale     bool
+}
+
+type registration struct {
+	service string
+	host    string
+	port    int
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// SvcDiscoveryRegistryImpl is a discovery.SvcDiscoveryRegistry backed by Nacos.
+type SvcDiscoveryRegistryImpl struct {
+	conf        *Config
+	api         *api
+	dialOptions []grpc.DialOption
+	metadata    map[string]string
+	now         func() time.Time
+
+	lock     sync.Mutex
+	services map[string]*serviceState
+
+	regLock           sync.Mutex
+	reg               *registration
+	rpcRegisterTarget string
+	draining          atomic.Bool
+
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewSvcDiscoveryRegistry connects to the Nacos servers of conf and subscribes
+// to watchNames.
+func NewSvcDiscoveryRegistry(conf Config, watchNames []string, options ...Option) (*SvcDiscoveryRegistryImpl, error) {
+	if err := conf.ValidateAndSetDefaults(); err != nil {
+		return nil, err
+	}
+	a, err := newAPI(&conf)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &SvcDiscoveryRegistryImpl{
+		conf:     &conf,
+		api:      a,
+		now:      time.Now,
+		services: make(map[string]*serviceState),
+		cancel:   cancel,
+	}
+	for _, opt := range options {
+		opt(r)
+	}
+	for _, name := range watchNames {
+		r.services[name] = &serviceState{resolvers: make(map[*nacosResolver]struct{})}
+	}
+	r.wg.Add(1)
+	go r.refreshLoop(ctx)
+	return r, nil
+}
+
+func (r *SvcDiscoveryRegistryImpl) refreshLoop(ctx context.Context) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.conf.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		r.lock.Lock()
+		names := make([]string, 0, len(r.services))
+		for name := range r.services {
+			names = append(names, name)
+		}
+		r.lock.Unlock()
+		for _, name := range names {
+			if err := r.refresh(ctx, name); err != nil && ctx.Err() == nil {
+				log.ZWarn(ctx, "nacos refresh failed", err, "service", name)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// subscribe returns the state of name, creating it if needed.
+func (r *SvcDiscoveryRegistryImpl) subscribe(name string) *serviceState {
+	st, ok := r.services[name]
+	if !ok {
+		st = &serviceState{resolvers: make(map[*nacosResolver]struct{})}
+		r.services[name] = st
+	}
+	return st
+}
+
+// refresh fetches the instances of name. On failure the last known instances
+// are kept until they are older than the stale limit.
+func (r *SvcDiscoveryRegistryImpl) refresh(ctx context.Context, name string) error {
+	hosts, err := r.api.list(ctx, name)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	st := r.subscribe(name)
+	if err != nil {
+		if !st.lastSync.IsZero() && !st.stale && r.now().Sub(st.lastSync) > r.conf.StaleLimit {
+			st.stale = true
+			for res := range st.resolvers {
+				res.cc.ReportError(r.staleError(name, st))
+			}
+		}
+		return err
+	}
+	addrs := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		addrs = append(addrs, net.JoinHostPort(h.IP, strconv.Itoa(h.Port)))
+	}
+	sort.Strings(addrs)
+	wasStale := st.stale
+	st.lastSync = r.now()
+	st.stale = false
+	changed := !equalStrings(addrs, st.addrs)
+	if !changed && !wasStale {
+		return nil
+	}
+	if changed {
+		closeConns(ctx, st.conns)
+		st.conns = nil
+		st.addrs = addrs
+	}
+	for res := range st.resolvers {
+		res.update(name, st.addrs)
+	}
+	return nil
+}
+
+func (r *SvcDiscoveryRegistryImpl) staleError(name string, st *serviceState) error {
+	return errs.ErrDependencyUnavailable.WrapMsg("nacos unreachable and cached instances are stale", "service", name,
+		"lastSync", st.lastSync.Format(time.RFC3339), "staleLimit", r.conf.StaleLimit)
+}
+
+// GetConns returns a connection to every healthy instance of serviceName.
+func (r *SvcDiscoveryRegistryImpl) GetConns(ctx context.Context, serviceName string, opts ...grpc.DialOption) ([]*grpc.ClientConn, error) {
+	r.lock.Lock()
+	st := r.subscribe(serviceName)
+	synced := !st.lastSync.IsZero()
+	r.lock.Unlock()
+	if !synced {
+		if err := r.refresh(ctx, serviceName); err != nil {
+			return nil, err
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.now().Sub(st.lastSync) > r.conf.StaleLimit {
+		return nil, r.staleError(serviceName, st)
+	}
+	if len(st.addrs) == 0 {
+		return nil, discovery.ErrServiceNotFound.WrapMsg("no healthy instance", "serviceName", serviceName,
+			"namespace", r.conf.Namespace, "group", r.conf.Group)
+	}
+	if st.conns == nil {
+		dialOpts := append(append([]grpc.DialOption(nil), r.dialOptions...), opts...)
+		conns := make([]*grpc.ClientConn, 0, len(st.addrs))
+		for _, addr := range st.addrs {
+			conn, err := grpc.DialContext(ctx, addr, dialOpts...)
+			if err != nil {
+				closeConns(ctx, conns)
+				return nil, errs.WrapMsg(err, "DialContext failed", "addr", addr)
+			}
+			conns = append(conns, conn)
+		}
+		st.conns = conns
+	}
+	// Return a copy so callers cannot modify the cached slice.
+	return append([]*grpc.ClientConn(nil), st.conns...), nil
+}
+
+// GetConn returns a connection balancing over the instances of serviceName
+// and following their changes.
+func (r *SvcDiscoveryRegistryImpl) GetConn(ctx context.Context, serviceName string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	r.lock.Lock()
+	dialOpts := append([]grpc.DialOption(nil), r.dialOptions...)
+	r.lock.Unlock()
+	dialOpts = append(dialOpts,
+		grpc.WithResolvers(&resolverBuilder{registry: r}),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy": "round_robin"}`))
+	return grpc.DialContext(ctx, scheme+":///"+serviceName, append(dialOpts, opts...)...)
+}
+
+func (r *SvcDiscoveryRegistryImpl) GetSelfConnTarget() string {
+	r.regLock.Lock()
+	defer r.regLock.Unlock()
+	return r.rpcRegisterTarget
+}
+
+// AddOption appends dial options. Connections cached for GetConns are closed
+// so that the options apply to the next call.
+func (r *SvcDiscoveryRegistryImpl) AddOption(opts ...grpc.DialOption) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.dialOptions = append(r.dialOptions, opts...)
+	for _, st := range r.services {
+		closeConns(context.Background(), st.conns)
+		st.conns = nil
+	}
+}
+
+func (r *SvcDiscoveryRegistryImpl) CloseConn(conn *grpc.ClientConn) {
+	conn.Close()
+}
+
+func (r *SvcDiscoveryRegistryImpl) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
+	return "", nil
+}
+
+// Register publishes host:port as an ephemeral instance of serviceName and
+// keeps it alive with heartbeats until UnRegister or Close.
+func (r *SvcDiscoveryRegistryImpl) Register(serviceName, host string, port int, opts ...grpc.DialOption) error {
+	r.regLock.Lock()
+	defer r.regLock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), r.conf.RequestTimeout)
+	defer cancel()
+	if err := r.api.register(ctx, serviceName, host, port, r.instanceMetadata(), r.draining.Load()); err != nil {
+		return err
+	}
+	if r.reg != nil {
+		r.stopHeartbeat(r.reg)
+	}
+	beatCtx, beatCancel := context.WithCancel(context.Background())
+	reg := &registration{service: serviceName, host: host, port: port, cancel: beatCancel, done: make(chan struct{})}
+	r.reg = reg
+	r.rpcRegisterTarget = net.JoinHostPort(host, strconv.Itoa(port))
+	go r.heartbeat(beatCtx, reg)
+	return nil
+}
+
+func (r *SvcDiscoveryRegistryImpl) heartbeat(ctx context.Context, reg *registration) {
+	defer close(reg.done)
+	ticker := time.NewTicker(r.conf.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		found, err := r.api.beat(ctx, reg.service, reg.host, reg.port, r.instanceMetadata())
+		if err != nil {
+			if ctx.Err() == nil {
+				log.ZWarn(ctx, "nacos heartbeat failed", err, "service", reg.service)
+			}
+			continue
+		}
+		if !found {
+			// The server dropped the instance, e.g. after an outage longer than the beat timeout.
+			if err := r.api.register(ctx, reg.service, reg.host, reg.port, r.instanceMetadata(), r.draining.Load()); err != nil && ctx.Err() == nil {
+				log.ZWarn(ctx, "nacos re-register failed", err, "service", reg.service)
+			}
+		}
+	}
+}
+
+// instanceMetadata returns the metadata of WithMetadata, plus the draining
+// key while the instance is draining.
+func (r *SvcDiscoveryRegistryImpl) instanceMetadata() map[string]string {
+	if !r.draining.Load() {
+		return r.metadata
+	}
+	md := make(map[string]string, len(r.metadata)+1)
+	for k, v := range r.metadata {
+		md[k] = v
+	}
+	md[drainingKey] = "true"
+	return md
+}
+
+// SetDraining publishes the draining state in the metadata and the weight of
+// the registered instance. Clients drop the instance on their next refresh.
+// The state applies to later registrations too.
+func (r *SvcDiscoveryRegistryImpl) SetDraining(ctx context.Context, draining bool) error {
+	r.regLock.Lock()
+	defer r.regLock.Unlock()
+	r.draining.Store(draining)
+	reg := r.reg
+	if reg == nil {
+		return nil
+	}
+	if err := r.api.update(ctx, reg.service, reg.host, reg.port, r.instanceMetadata(), draining); err != nil {
+		return err
+	}
+	log.ZInfo(ctx, "nacos instance draining state changed", "service", reg.service, "draining", draining)
+	return nil
+}
+
+// IsDraining reports whether SetDraining(ctx, true) is in effect.
+func (r *SvcDiscoveryRegistryImpl) IsDraining() bool {
+	return r.draining.Load()
+}
+
+func (r *SvcDiscoveryRegistryImpl) stopHeartbeat(reg *registration) {
+	reg.cancel()
+	<-reg.done
+}
+
+// UnRegister removes the instance published by Register.
+func (r *SvcDiscoveryRegistryImpl) UnRegister() error {
+	r.regLock.Lock()
+	defer r.regLock.Unlock()
+	reg := r.reg
+	if reg == nil {
+		return nil
+	}
+	r.stopHeartbeat(reg)
+	r.reg = nil
+	ctx, cancel := context.WithTimeout(context.Background(), r.conf.RequestTimeout)
+	defer cancel()
+	return r.api.deregister(ctx, reg.service, reg.host, reg.port)
+}
+
+// Close unregisters the instance, stops the subscriptions and closes every
+// connection. It may be called more than once.
+func (r *SvcDiscoveryRegistryImpl) Close() {
+	r.closeOnce.Do(func() {
+		if err := r.UnRegister(); err != nil {
+			log.ZWarn(context.Background(), "nacos unregister on close failed", err)
+		}
+		r.cancel()
+		r.wg.Wait()
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		for _, st := range r.services {
+			closeConns(context.Background(), st.conns)
+			st.conns = nil
+		}
+	})
+}
+
+func closeConns(ctx context.Context, conns []*grpc.ClientConn) {
+	for _, conn := range conns {
+		if err := conn.Close(); err != nil {
+			log.ZWarn(ctx, "close conn err", err)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package nacos
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/discovery"
+	"github.com/openimsdk/tools/errs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func newTestRegistry(t *testing.T, addr string, conf Config, opts ...Option) *SvcDiscoveryRegistryImpl {
+	t.Helper()
+	conf.ServerAddrs = []string{addr}
+	if conf.RefreshInterval == 0 {
+		conf.RefreshInterval = 20 * time.Millisecond
+	}
+	opts = append(opts, WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	r, err := NewSvcDiscoveryRegistry(conf, nil, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Close)
+	return r
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStaleInstances(t *testing.T) {
+	fake, addr := newFakeNacos(t, "", "")
+	r := newTestRegistry(t, addr, Config{StaleLimit: 300 * time.Millisecond})
+	if err := r.Register("svc", "127.0.0.1", 10001, grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if conns, err := r.GetConns(ctx, "svc"); err != nil || len(conns) != 1 {
+		t.Fatalf("GetConns = %d conns, %v", len(conns), err)
+	}
+
+	fake.down.Store(true)
+	if conns, err := r.GetConns(ctx, "svc"); err != nil || len(conns) != 1 {
+		t.Fatalf("during outage GetConns = %d conns, %v; want last known instance", len(conns), err)
+	}
+	waitFor(t, "stale limit", func() bool {
+		_, err := r.GetConns(ctx, "svc")
+		return errors.Is(err, errs.ErrDependencyUnavailable)
+	})
+
+	fake.down.Store(false)
+	waitFor(t, "recovery", func() bool {
+		conns, err := r.GetConns(ctx, "svc")
+		return err == nil && len(conns) == 1
+	})
+}
+
+func TestHeartbeatReRegisters(t *testing.T) {
+	fake, addr := newFakeNacos(t, "", "")
+	r := newTestRegistry(t, addr, Config{HeartbeatInterval: 20 * time.Millisecond},
+		WithMetadata(map[string]string{"version": "v1"}))
+	if err := r.Register("svc", "127.0.0.1", 10002); err != nil {
+		t.Fatal(err)
+	}
+	target := net.JoinHostPort("127.0.0.1", strconv.Itoa(10002))
+	inst, ok := fake.instance("svc", target)
+	if !ok || inst.Metadata["version"] != "v1" {
+		t.Fatalf("registered instance = %+v, %v", inst, ok)
+	}
+	fake.remove("svc", target)
+	waitFor(t, "re-registration", func() bool {
+		_, ok := fake.instance("svc", target)
+		return ok
+	})
+
+	if err := r.UnRegister(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.instance("svc", target); ok {
+		t.Fatal("instance still registered after UnRegister")
+	}
+}
+
+func TestDrainingSurvivesReRegister(t *testing.T) {
+	fake, addr := newFakeNacos(t, "", "")
+	r := newTestRegistry(t, addr, Config{HeartbeatInterval: 20 * time.Millisecond},
+		WithMetadata(map[string]string{"version": "v1"}))
+	if err := r.Register("svc", "127.0.0.1", 10004); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetDraining(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	target := net.JoinHostPort("127.0.0.1", strconv.Itoa(10004))
+	draining := func(inst instance) bool {
+		return inst.Weight == 0 && inst.Metadata[drainingKey] == "true" && inst.Metadata["version"] == "v1"
+	}
+	if inst, _ := fake.instance("svc", target); !draining(inst) {
+		t.Fatalf("draining instance = %+v, want weight 0 and the draining key", inst)
+	}
+	fake.remove("svc", target)
+	waitFor(t, "re-registration", func() bool {
+		_, ok := fake.instance("svc", target)
+		return ok
+	})
+	if inst, _ := fake.instance("svc", target); !draining(inst) {
+		t.Fatalf("re-registered instance = %+v, want it still draining", inst)
+	}
+
+	if err := r.SetDraining(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	if inst, _ := fake.instance("svc", target); inst.Weight != 1 || inst.Metadata[drainingKey] != "" {
+		t.Fatalf("serving instance = %+v, want weight 1 without the draining key", inst)
+	}
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	_, addr := newFakeNacos(t, "", "")
+	pub := newTestRegistry(t, addr, Config{Namespace: "a"})
+	obs := newTestRegistry(t, addr, Config{Namespace: "b"})
+	if err := pub.Register("svc", "127.0.0.1", 10003); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := obs.GetConns(context.Background(), "svc"); !errors.Is(err, discovery.ErrServiceNotFound) {
+		t.Fatalf("GetConns across namespaces = %v, want ErrServiceNotFound", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	_, addr := newFakeNacos(t, "nacos", "secret")
+	ctx := context.Background()
+	if err := Check(ctx, &Config{ServerAddrs: []string{addr}, Username: "nacos", Password: "secret"}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	err := Check(ctx, &Config{ServerAddrs: []string{addr}, Username: "nacos", Password: "wrong"})
+	if !errors.Is(err, errs.ErrNoPermission) {
+		t.Fatalf("Check with wrong password = %v, want ErrNoPermission", err)
+	}
+	err = Check(ctx, &Config{ServerAddrs: []string{addr}})
+	if !errors.Is(err, errs.ErrNoPermission) {
+		t.Fatalf("Check without credentials = %v, want ErrNoPermission", err)
+	}
+	err = Check(ctx, &Config{ServerAddrs: []string{"127.0.0.1:1", addr}, Username: "nacos", Password: "secret"})
+	if err != nil {
+		t.Fatalf("Check with one server down: %v", err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("NACOS_SERVER_ADDRS", "10.0.0.1:8848,10.0.0.2:8848")
+	t.Setenv("NACOS_NAMESPACE", "prod")
+	conf := Config{ServerAddrs: []string{"127.0.0.1:8848"}, Namespace: "dev", Group: "openim"}
+	conf.ApplyEnv()
+	if len(conf.ServerAddrs) != 2 || conf.Namespace != "prod" || conf.Group != "openim" {
+		t.Fatalf("ApplyEnv = %+v", conf)
+	}
+	if err := conf.ValidateAndSetDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Cluster != DefaultCluster || conf.StaleLimit != defaultStaleLimit {
+		t.Fatalf("defaults not set: %+v", conf)
+	}
+	if err := (&Config{ServerAddrs: []string{"no-port"}}).ValidateAndSetDefaults(); err == nil {
+		t.Fatal("invalid address accepted")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nacos
+
+import (
+	"context"
+	"strings"
+
+	"github.com/openimsdk/tools/discovery"
+	"google.golang.org/grpc/resolver"
+)
+
+type resolverBuilder struct {
+	registry *SvcDiscoveryRegistryImpl
+}
+
+func (b *resolverBuilder) Scheme() string { return scheme }
+
+func (b *resolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	name := strings.TrimPrefix(target.URL.Path, "/")
+	r := b.registry
+	res := &nacosResolver{registry: r, name: name, cc: cc}
+
+	r.lock.Lock()
+	st := r.subscribe(name)
+	st.resolvers[res] = struct{}{}
+	synced := !st.lastSync.IsZero()
+	if synced {
+		res.update(name, st.addrs)
+	}
+	r.lock.Unlock()
+	if !synced {
+		go res.ResolveNow(resolver.ResolveNowOptions{})
+	}
+	return res, nil
+}
+
+type nacosResolver struct {
+	registry *SvcDiscoveryRegistryImpl
+	name     string
+	cc       resolver.ClientConn
+}
+
+// update pushes addrs to the ClientConn. It is called with the registry lock held.
+func (res *nacosResolver) update(name string, addrs []string) {
+	if len(addrs) == 0 {
+		res.cc.ReportError(discovery.ErrServiceNotFound.WrapMsg("no healthy instance", "serviceName", name))
+		return
+	}
+	state := resolver.State{Addresses: make([]resolver.Address, 0, len(addrs))}
+	for _, addr := range addrs {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: addr, ServerName: name})
+	}
+	_ = res.cc.UpdateState(state)
+}
+
+// ResolveNow fetches the instances again instead of waiting for the next refresh.
+func (res *nacosResolver) ResolveNow(resolver.ResolveNowOptions) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), res.registry.conf.RequestTimeout)
+		defer cancel()
+		_ = res.registry.refresh(ctx, res.name)
+	}()
+}
+
+func (res *nacosResolver) Close() {
+	r := res.registry
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if st, ok := r.services[res.name]; ok {
+		delete(st.resolvers, res)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nacos
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Check verifies that the Nacos servers of conf are reachable and ready, and
+// that the configured credentials may query the naming service.
+func Check(ctx context.Context, conf *Config) error {
+	c := *conf
+	if err := c.ValidateAndSetDefaults(); err != nil {
+		return err
+	}
+	a, err := newAPI(&c)
+	if err != nil {
+		return err
+	}
+	if _, err := a.do(ctx, http.MethodGet, "/v1/console/health/readiness", nil); err != nil {
+		return err
+	}
+	// Listing services needs read permission on the namespace, so it also
+	// validates the credentials when auth is enabled.
+	params := url.Values{"groupName": {c.Group}, "pageNo": {"1"}, "pageSize": {"1"}}
+	_, err = a.do(ctx, http.MethodGet, "/v1/ns/service/list", params)
+	return err
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/go-zookeeper/zk"
 	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/tlsutil"
 )
 
 type Config struct {
@@ -27,6 +28,7 @@ type Config struct {
 	Username  string
 	Password  string
 	Timeout   time.Duration
+	TLS       tlsutil.Config
 }
 
 func (s *ZkClient) RegisterConf2Registry(key string, conf []byte) error {
@@ -0,0 +1,48 @@
+package zookeeper
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/tools/discovery"
+	"github.com/openimsdk/tools/discovery/conformancetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var _ discovery.Drainer = (*ZkClient)(nil)
+
+// TestConformance runs the discovery conformance suite against the ZooKeeper
+// cluster given in ZK_ADDR, e.g. ZK_ADDR=127.0.0.1:2181, or else against a
+// standalone server started from the zkServer.sh on PATH or in ZK_SERVER.
+func TestConformance(t *testing.T) {
+	addr := os.Getenv("ZK_ADDR")
+	if addr == "" {
+		addr = startZooKeeper(t)
+	}
+	conformancetest.Run(t, func(t *testing.T, watch []string) discovery.SvcDiscoveryRegistry {
+		client, err := NewZkClient(strings.Split(addr, ","), "conformance",
+			WithRoundRobin(), WithOptions(grpc.WithTransportCredentials(insecure.NewCredentials())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	})
+}
+
+func startZooKeeper(t *testing.T) string {
+	addr := conformancetest.FreeAddr(t)
+	_, port, _ := net.SplitHostPort(addr)
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "zoo.cfg")
+	conf := "tickTime=500\ndataDir=" + dir + "\nclientPortAddress=127.0.0.1\nclientPort=" + port + "\nadmin.enableServer=false\n"
+	if err := os.WriteFile(cfg, []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ZOO_LOG_DIR", dir)
+	conformancetest.StartServer(t, "ZK_SERVER", "zkServer.sh", addr, "start-foreground", cfg)
+	return addr
+}
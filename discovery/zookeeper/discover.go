@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"github.com/go-zookeeper/zk"
+	"github.com/openimsdk/tools/discovery"
 	"github.com/openimsdk/tools/errs"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/resolver"
@@ -44,6 +45,11 @@ func (s *ZkClient) watch(ctx context.Context) {
 				case zk.StateHasSession:
 					if s.isRegistered && !s.isStateDisconnected {
 						s.logger.Debug(ctx, "zk session event stateHasSession, client prepare to create new temp node", "event", event)
+						if s.draining {
+							if err := s.createDrainNode(s.rpcRegisterName, s.rpcRegisterAddr); err != nil {
+								s.logger.Error(ctx, "zk session event stateHasSession, create drain node error", err, "event", event)
+							}
+						}
 						node, err := s.CreateTempNode(s.rpcRegisterName, s.rpcRegisterAddr)
 						if err != nil {
 							s.logger.Error(ctx, "zk session event stateHasSession, create temp node error", err, "event", event)
@@ -89,6 +95,10 @@ func (s *ZkClient) GetConnsRemote(ctx context.Context, serviceName string) (conn
 	if err != nil {
 		return nil, errs.WrapMsg(err, "children watch error", "path", path)
 	}
+	draining, err := s.drainingAddrs(serviceName)
+	if err != nil {
+		return nil, err
+	}
 	childNodes, _, err := s.conn.Children(path)
 	if err != nil {
 		return nil, errs.WrapMsg(err, "get children error", "path", path)
@@ -100,12 +110,33 @@ func (s *ZkClient) GetConnsRemote(ctx context.Context, serviceName string) (conn
 				return nil, errs.WrapMsg(err, "get children error", "fullPath", fullPath)
 			}
 			s.logger.Debug(ctx, "get addr from remote", "conn", string(data))
+			if draining[string(data)] {
+				continue
+			}
 			conns = append(conns, resolver.Address{Addr: string(data), ServerName: serviceName})
 		}
 	}
 	return conns, nil
 }
 
+// drainingAddrs returns the addresses of the draining instances of
+// serviceName, watching the drain path for changes.
+func (s *ZkClient) drainingAddrs(serviceName string) (map[string]bool, error) {
+	if err := s.ensureDrainPath(serviceName); err != nil {
+		return nil, err
+	}
+	path := s.getDrainPath(serviceName)
+	addrs, _, _, err := s.conn.ChildrenW(path)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "children watch error", "path", path)
+	}
+	draining := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		draining[addr] = true
+	}
+	return draining, nil
+}
+
 func (s *ZkClient) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
 	s.logger.Warn(ctx, "not implement", errs.New("zkclinet not implement GetUserIdHashGatewayHost method"))
 	return "", nil
@@ -123,7 +154,7 @@ func (s *ZkClient) GetConns(ctx context.Context, serviceName string, opts ...grp
 			return nil, err
 		}
 		if len(addrs) == 0 {
-			return nil, errs.New("addr is empty").WrapMsg("no conn for service", "serviceName",
+			return nil, discovery.ErrServiceNotFound.WrapMsg("no conn for service", "serviceName",
 				serviceName, "local conn", s.localConns, "ZkServers", s.ZkServers, "zkRoot", s.zkRoot)
 		}
 		for _, addr := range addrs {
@@ -136,7 +167,8 @@ func (s *ZkClient) GetConns(ctx context.Context, serviceName string, opts ...grp
 		}
 		s.localConns[serviceName] = conns
 	}
-	return conns, nil
+	// Return a copy so callers cannot modify the cached slice.
+	return append([]*grpc.ClientConn(nil), conns...), nil
 }
 
 func (s *ZkClient) GetConn(ctx context.Context, serviceName string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
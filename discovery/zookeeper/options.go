@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/tlsutil"
 	"google.golang.org/grpc"
 )
 
@@ -36,6 +37,13 @@ func WithUserNameAndPassword(userName, password string) ZkOption {
 	}
 }
 
+// WithTLS connects to ZooKeeper over TLS when conf.EnableTLS is set.
+func WithTLS(conf tlsutil.Config) ZkOption {
+	return func(client *ZkClient) {
+		client.tls = conf
+	}
+}
+
 func WithOptions(opts ...grpc.DialOption) ZkOption {
 	return func(client *ZkClient) {
 		client.options = opts
@@ -15,6 +15,7 @@
 package zookeeper
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-zookeeper/zk"
@@ -52,6 +53,11 @@ func (s *ZkClient) Register(rpcRegisterName, host string, port int, opts ...grpc
 	if err != nil {
 		return errs.WrapMsg(err, "grpc dial error", "addr", addr)
 	}
+	if s.draining {
+		if err := s.createDrainNode(rpcRegisterName, addr); err != nil {
+			return err
+		}
+	}
 	node, err := s.CreateTempNode(rpcRegisterName, addr)
 	if err != nil {
 		return err
@@ -70,6 +76,11 @@ func (s *ZkClient) UnRegister() error {
 	if err != nil {
 		return errs.WrapMsg(err, "delete node error", "node", s.node)
 	}
+	if s.draining {
+		if err := s.deleteDrainNode(s.rpcRegisterName, s.rpcRegisterAddr); err != nil {
+			return err
+		}
+	}
 	time.Sleep(time.Second)
 	s.node = ""
 	s.rpcRegisterName = ""
@@ -79,3 +90,55 @@ func (s *ZkClient) UnRegister() error {
 	s.resolvers = make(map[string]*Resolver)
 	return nil
 }
+
+// SetDraining publishes the draining state as an ephemeral node named after
+// the instance address under the drain path of the service, leaving the data
+// of the instance node, which older clients dial, untouched. Clients watching
+// the drain path drop the instance from their resolvers on the resulting
+// children change event. The state survives session re-creation.
+func (s *ZkClient) SetDraining(ctx context.Context, draining bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.draining = draining
+	if !s.isRegistered {
+		return nil
+	}
+	var err error
+	if draining {
+		err = s.createDrainNode(s.rpcRegisterName, s.rpcRegisterAddr)
+	} else {
+		err = s.deleteDrainNode(s.rpcRegisterName, s.rpcRegisterAddr)
+	}
+	if err != nil {
+		return err
+	}
+	s.logger.Info(ctx, "zk instance draining state changed", "node", s.node, "draining", draining)
+	return nil
+}
+
+func (s *ZkClient) createDrainNode(rpcRegisterName, addr string) error {
+	if err := s.ensureDrainPath(rpcRegisterName); err != nil {
+		return err
+	}
+	node := s.getDrainPath(rpcRegisterName) + "/" + addr
+	_, err := s.conn.Create(node, []byte(addr), zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+	if err != nil && err != zk.ErrNodeExists {
+		return errs.WrapMsg(err, "create drain node error", "node", node)
+	}
+	return nil
+}
+
+func (s *ZkClient) deleteDrainNode(rpcRegisterName, addr string) error {
+	node := s.getDrainPath(rpcRegisterName) + "/" + addr
+	if err := s.conn.Delete(node, -1); err != nil && err != zk.ErrNoNode {
+		return errs.WrapMsg(err, "delete drain node error", "node", node)
+	}
+	return nil
+}
+
+// IsDraining reports whether SetDraining(ctx, true) is in effect.
+func (s *ZkClient) IsDraining() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.draining
+}
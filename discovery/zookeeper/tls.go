@@ -0,0 +1,86 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/openimsdk/tools/errs"
+)
+
+// tlsDialer opens the ZooKeeper connections and completes the TLS handshake
+// within the dial timeout. The zk client retries failed dials without
+// reporting them, so the last handshake error is kept for lastErr.
+type tlsDialer struct {
+	conf *tls.Config
+
+	mu  sync.Mutex
+	err error
+}
+
+func (d *tlsDialer) dial(network, address string, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conf := d.conf.Clone()
+	if conf.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			conf.ServerName = host
+		}
+	}
+	tlsConn := tls.Client(conn, conf)
+	_ = tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		_ = conn.Close()
+		err = errs.WrapMsg(err, "zookeeper TLS handshake failed", "server", address)
+		d.mu.Lock()
+		d.err = err
+		d.mu.Unlock()
+		return nil, err
+	}
+	_ = tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+func (d *tlsDialer) lastErr() error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+// connect opens the session to ZkServers, over TLS when configured, and
+// returns the TLS dialer, nil otherwise.
+func (s *ZkClient) connect() (*zk.Conn, <-chan zk.Event, *tlsDialer, error) {
+	sessionTimeout := time.Duration(s.timeout) * time.Second
+	conf, err := s.tls.Load()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if conf == nil {
+		conn, events, err := zk.Connect(s.ZkServers, sessionTimeout, zk.WithLogger(nilLog{}))
+		return conn, events, nil, err
+	}
+	d := &tlsDialer{conf: conf}
+	conn, events, err := zk.Connect(s.ZkServers, sessionTimeout, zk.WithLogger(nilLog{}), zk.WithDialer(d.dial))
+	return conn, events, d, err
+}
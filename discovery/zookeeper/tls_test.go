@@ -0,0 +1,80 @@
+package zookeeper
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/utils/tlsutil"
+	"github.com/openimsdk/tools/utils/tlsutil/tlstest"
+)
+
+func TestTLSDialer(t *testing.T) {
+	dir := t.TempDir()
+	addr, caPath := tlstest.StartSilentServer(t, dir, "zookeeper")
+	conf, err := (&tlsutil.Config{EnableTLS: true, CAFile: caPath}).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &tlsDialer{conf: conf}
+	conn, err := d.dial("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatalf("trusted CA: %v", err)
+	}
+	if _, ok := conn.(*tls.Conn); !ok {
+		t.Errorf("conn is %T, want *tls.Conn", conn)
+	}
+	_ = conn.Close()
+
+	_, _, otherCA := tlstest.NewCA(t, dir, "other-ca")
+	conf, err = (&tlsutil.Config{EnableTLS: true, CAFile: otherCA}).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = &tlsDialer{conf: conf}
+	if _, err := d.dial("tcp", addr, time.Second); err == nil || !strings.Contains(err.Error(), addr) {
+		t.Fatalf("untrusted CA: err = %v, want handshake error naming %s", err, addr)
+	}
+	if d.lastErr() == nil {
+		t.Error("handshake error not kept")
+	}
+}
+
+func TestCheckReportsHandshakeFailure(t *testing.T) {
+	dir := t.TempDir()
+	addr, _ := tlstest.StartSilentServer(t, dir, "zookeeper")
+	_, _, otherCA := tlstest.NewCA(t, dir, "other-ca")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := Check(ctx, []string{addr}, "openim", WithTimeout(1), WithTLS(tlsutil.Config{EnableTLS: true, CAFile: otherCA}))
+	if err == nil || !strings.Contains(err.Error(), "handshake") || !strings.Contains(err.Error(), addr) {
+		t.Fatalf("err = %v, want handshake error naming %s", err, addr)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("check waited for the context deadline")
+	}
+}
+
+func TestCheckPlaintextNoSession(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+	err = Check(context.Background(), []string{lis.Addr().String()}, "openim", WithTimeout(1))
+	if err == nil || !strings.Contains(err.Error(), "not established") {
+		t.Fatalf("err = %v, want session timeout", err)
+	}
+}
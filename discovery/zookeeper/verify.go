@@ -16,15 +16,28 @@ package zookeeper
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/go-zookeeper/zk"
+	"github.com/google/uuid"
 	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/network"
 	"google.golang.org/grpc"
 )
 
+// Check verifies that ZooKeeper accepts the session, that the root node of
+// scheme exists, creating it if missing, that the credentials grant reading
+// it, and that the session may create and delete children of it, as
+// registration does. The child is
+// ephemeral, so an aborted check leaves nothing behind. Refusals are
+// errs.ErrComponentStart errors naming the path and scheme.
 func Check(ctx context.Context, ZkServers []string, scheme string, options ...ZkOption) error {
+	ZkServers, err := network.NormalizeAddrs(ZkServers)
+	if err != nil {
+		return err
+	}
 	client := &ZkClient{
 		ZkServers:  ZkServers,
 		zkRoot:     "/",
@@ -40,10 +53,14 @@ func Check(ctx context.Context, ZkServers []string, scheme string, options ...Zk
 	}
 
 	// Establish a Zookeeper connection with a specified timeout and handle authentication.
-	conn, eventChan, err := zk.Connect(ZkServers, time.Duration(client.timeout)*time.Second)
+	conn, eventChan, dialer, err := client.connect()
 	if err != nil {
 		return errs.WrapMsg(err, "connect failed", "ZkServers", ZkServers)
 	}
+	if err := waitSession(ctx, ZkServers, eventChan, time.Duration(client.timeout)*time.Second, dialer); err != nil {
+		conn.Close()
+		return err
+	}
 
 	_, cancel := context.WithCancel(context.Background())
 	client.cancel = cancel
@@ -54,7 +71,7 @@ func Check(ctx context.Context, ZkServers []string, scheme string, options ...Zk
 		auth := []byte(client.username + ":" + client.password)
 		if err := conn.AddAuth("digest", auth); err != nil {
 			conn.Close()
-			return errs.WrapMsg(err, "AddAuth failed", "userName", client.username, "password", client.password)
+			return errs.WrapMsg(err, "AddAuth failed", "username", client.username)
 		}
 	}
 
@@ -62,10 +79,82 @@ func Check(ctx context.Context, ZkServers []string, scheme string, options ...Zk
 	client.eventChan = eventChan
 	client.conn = conn
 
+	defer conn.Close()
+
 	// Verify root node existence and create if missing.
 	if err := client.ensureRoot(); err != nil {
-		conn.Close()
+		if accessDenied(err) {
+			return client.accessError(err, client.zkRoot)
+		}
 		return errs.WrapMsg(err, "ensureRoot failed", "zkRoot", client.zkRoot)
 	}
+	// AddAuth accepts any password, the credentials are only checked against
+	// the ACL of the nodes they are used on.
+	if client.username != "" {
+		if _, _, err := conn.Get(client.zkRoot); err != nil {
+			if accessDenied(err) {
+				return client.accessError(err, client.zkRoot)
+			}
+			return errs.WrapMsg(err, "Get failed", "zkRoot", client.zkRoot)
+		}
+	}
+	return client.checkWritable()
+}
+
+// waitSession waits up to timeout for the session to be established. On
+// timeout it returns the last TLS handshake error of dialer, which names the
+// server, if any.
+func waitSession(ctx context.Context, servers []string, events <-chan zk.Event, timeout time.Duration, dialer *tlsDialer) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case ev := <-events:
+			switch ev.State {
+			case zk.StateHasSession:
+				return nil
+			case zk.StateAuthFailed:
+				return errs.ErrComponentStart.WrapMsg("zookeeper authentication failed", "server", ev.Server)
+			}
+		case <-timer.C:
+			if err := dialer.lastErr(); err != nil {
+				return err
+			}
+			return errs.New("zookeeper session not established in time", "ZkServers", servers, "timeout", timeout).Wrap()
+		case <-ctx.Done():
+			if err := dialer.lastErr(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// checkWritable creates and deletes an ephemeral child of the root node.
+func (s *ZkClient) checkWritable() error {
+	path := s.zkRoot + "/component-check-" + uuid.NewString()
+	if _, err := s.conn.Create(path, nil, zk.FlagEphemeral, zk.WorldACL(zk.PermAll)); err != nil {
+		if accessDenied(err) {
+			return s.accessError(err, path)
+		}
+		return errs.WrapMsg(err, "Create failed", "path", path)
+	}
+	if err := s.conn.Delete(path, -1); err != nil {
+		if accessDenied(err) {
+			return s.accessError(err, path)
+		}
+		return errs.WrapMsg(err, "Delete failed", "path", path)
+	}
 	return nil
 }
+
+func accessDenied(err error) bool {
+	return errors.Is(err, zk.ErrNoAuth) || errors.Is(err, zk.ErrAuthFailed) || errors.Is(err, zk.ErrInvalidACL)
+}
+
+// accessError reports a refusal of ZooKeeper, with the username but never the
+// password.
+func (s *ZkClient) accessError(err error, path string) error {
+	return errs.ErrComponentStart.WrapMsg("ZooKeeper denied access to the OpenIM root node",
+		"path", path, "scheme", s.scheme, "username", s.username, "err", err.Error())
+}
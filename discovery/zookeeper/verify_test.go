@@ -0,0 +1,46 @@
+package zookeeper
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/openimsdk/tools/errs"
+)
+
+func TestCheckRejectsUnbracketedIPv6(t *testing.T) {
+	for _, servers := range [][]string{{"fd00::1:2181"}, {"127.0.0.1:2181,::1:2181"}, {}} {
+		err := Check(context.Background(), servers, "openim")
+		var codeErr errs.CodeError
+		if !errors.As(err, &codeErr) || codeErr.Code() != errs.ConfigError {
+			t.Errorf("Check(%v) err = %v, want ErrConfig", servers, err)
+		}
+	}
+}
+
+func TestAccessError(t *testing.T) {
+	for _, err := range []error{zk.ErrNoAuth, zk.ErrAuthFailed, errs.WrapMsg(zk.ErrInvalidACL, "Create failed")} {
+		if !accessDenied(err) {
+			t.Errorf("%v not reported as denied", err)
+		}
+	}
+	if accessDenied(zk.ErrNoNode) {
+		t.Error("ErrNoNode reported as denied")
+	}
+	client := &ZkClient{scheme: "openim", username: "openIM", password: "s3cret"}
+	err := client.accessError(zk.ErrNoAuth, "/openim/component-check-1")
+	if !errs.ErrComponentStart.Is(err) {
+		t.Fatalf("err = %v, want ErrComponentStart", err)
+	}
+	msg := err.Error()
+	for _, want := range []string{"/openim/component-check-1", "scheme=openim", "username=openIM"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("err = %q, want %s", msg, want)
+		}
+	}
+	if strings.Contains(msg, "s3cret") {
+		t.Errorf("err = %q leaks the password", msg)
+	}
+}
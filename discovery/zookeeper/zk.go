@@ -24,6 +24,7 @@ import (
 	"github.com/go-zookeeper/zk"
 	"github.com/openimsdk/tools/errs"
 	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/tlsutil"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/resolver"
 )
@@ -38,10 +39,12 @@ type ZkClient struct {
 	zkRoot    string
 	username  string
 	password  string
+	tls       tlsutil.Config
 
 	rpcRegisterName string
 	rpcRegisterAddr string
 	isRegistered    bool
+	draining        bool
 	scheme          string
 
 	timeout   int
@@ -79,7 +82,7 @@ func NewZkClient(ZkServers []string, scheme string, options ...ZkOption) (*ZkCli
 	}
 
 	// Establish a Zookeeper connection with a specified timeout and handle authentication.
-	conn, eventChan, err := zk.Connect(ZkServers, time.Duration(client.timeout)*time.Second, zk.WithLogger(nilLog{}))
+	conn, eventChan, _, err := client.connect()
 	if err != nil {
 		return nil, errs.WrapMsg(err, "connect failed", "ZkServers", ZkServers)
 	}
@@ -93,7 +96,7 @@ func NewZkClient(ZkServers []string, scheme string, options ...ZkOption) (*ZkCli
 		auth := []byte(client.username + ":" + client.password)
 		if err := conn.AddAuth("digest", auth); err != nil {
 			conn.Close()
-			return nil, errs.WrapMsg(err, "AddAuth failed", "username", client.username, "password", client.password)
+			return nil, errs.WrapMsg(err, "AddAuth failed", "username", client.username)
 		}
 	}
 
@@ -187,6 +190,21 @@ func (s *ZkClient) getPath(rpcRegisterName string) string {
 	return s.zkRoot + "/" + rpcRegisterName
 }
 
+// drainDir holds, per service, the nodes of the draining instances. It is not
+// under the service nodes, whose children older clients dial as addresses.
+const drainDir = "_draining"
+
+func (s *ZkClient) getDrainPath(rpcRegisterName string) string {
+	return s.zkRoot + "/" + drainDir + "/" + rpcRegisterName
+}
+
+func (s *ZkClient) ensureDrainPath(rpcRegisterName string) error {
+	if err := s.ensureAndCreate(s.zkRoot + "/" + drainDir); err != nil {
+		return err
+	}
+	return s.ensureAndCreate(s.getDrainPath(rpcRegisterName))
+}
+
 func (s *ZkClient) getAddr(host string, port int) string {
 	return net.JoinHostPort(host, strconv.Itoa(port))
 }
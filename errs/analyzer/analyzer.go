@@ -0,0 +1,112 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analyzer reports comparisons of errors with the sentinels of
+// github.com/openimsdk/tools/errs using == or switch, which never match
+// wrapped errors.
+//
+// It is a separate module, so that the tools module does not depend on
+// golang.org/x/tools. Run it with
+//
+//	go install github.com/openimsdk/tools/errs/analyzer/cmd/errscompare@latest
+//	go vet -vettool=$(which errscompare) ./...
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const errsPath = "github.com/openimsdk/tools/errs"
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "errscompare",
+	Doc:      "report == comparisons and switch cases against errs sentinels, use errors.Is instead",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	if pass.Pkg.Path() == errsPath {
+		// The package compares its sentinels by identity on purpose.
+		return nil, nil
+	}
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	nodes := []ast.Node{(*ast.BinaryExpr)(nil), (*ast.SwitchStmt)(nil)}
+	ins.Preorder(nodes, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.BinaryExpr:
+			if n.Op != token.EQL && n.Op != token.NEQ {
+				return
+			}
+			for _, operand := range []ast.Expr{n.X, n.Y} {
+				if name, ok := sentinel(pass, operand); ok {
+					pass.Reportf(n.Pos(), "comparison with errs.%s using %s never matches wrapped errors, use errors.Is", name, n.Op)
+					return
+				}
+			}
+		case *ast.SwitchStmt:
+			if n.Tag == nil {
+				return
+			}
+			for _, stmt := range n.Body.List {
+				for _, expr := range stmt.(*ast.CaseClause).List {
+					if name, ok := sentinel(pass, expr); ok {
+						pass.Reportf(expr.Pos(), "switch case errs.%s never matches wrapped errors, use errors.Is", name)
+					}
+				}
+			}
+		}
+	})
+	return nil, nil
+}
+
+// sentinel reports whether expr is a package-level variable of errs holding
+// a code error, and returns its name.
+func sentinel(pass *analysis.Pass, expr ast.Expr) (string, bool) {
+	var id *ast.Ident
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		id = e
+	case *ast.SelectorExpr:
+		id = e.Sel
+	default:
+		return "", false
+	}
+	v, ok := pass.TypesInfo.Uses[id].(*types.Var)
+	if !ok || v.Pkg() == nil || v.Pkg().Path() != errsPath || v.Parent() != v.Pkg().Scope() {
+		return "", false
+	}
+	return v.Name(), hasCode(v.Type())
+}
+
+// hasCode reports whether t has the Code() int method of errs.CodeError.
+func hasCode(t types.Type) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "Code")
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return false
+	}
+	basic, ok := sig.Results().At(0).Type().(*types.Basic)
+	return ok && basic.Kind() == types.Int
+}
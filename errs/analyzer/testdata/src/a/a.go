@@ -0,0 +1,35 @@
+package a
+
+import (
+	"errors"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+func compare(err error) {
+	if err == errs.ErrArgs { // want `comparison with errs.ErrArgs using == never matches wrapped errors, use errors.Is`
+	}
+	if (errs.ErrRecordNotFound) != err { // want `comparison with errs.ErrRecordNotFound using != never matches wrapped errors, use errors.Is`
+	}
+	if errors.Is(err, errs.ErrArgs) {
+	}
+	if err == nil || errs.DefaultName == "errs" {
+	}
+	local := errs.ErrArgs
+	if err == local {
+	}
+}
+
+func classify(err error) string {
+	switch err {
+	case nil:
+		return "ok"
+	case errs.ErrArgs, errs.ErrRecordNotFound: // want `switch case errs.ErrArgs` `switch case errs.ErrRecordNotFound`
+		return "code"
+	}
+	switch {
+	case errors.Is(err, errs.ErrArgs):
+		return "args"
+	}
+	return ""
+}
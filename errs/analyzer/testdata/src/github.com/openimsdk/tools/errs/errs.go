@@ -0,0 +1,23 @@
+package errs
+
+type CodeError interface {
+	Code() int
+	error
+}
+
+type codeError struct{ code int }
+
+func (e *codeError) Code() int     { return e.code }
+func (e *codeError) Error() string { return "code error" }
+
+var (
+	ErrArgs           CodeError = &codeError{1001}
+	ErrRecordNotFound CodeError = &codeError{1004}
+
+	// DefaultName is not a code error.
+	DefaultName = "errs"
+)
+
+func identity(err error) bool {
+	return err == ErrArgs
+}
@@ -0,0 +1,161 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errs
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// callbackNextContinue is the nextCode by which a callback server asks to go
+// on with the original data.
+const callbackNextContinue = 1
+
+// callbackBodySnippet bounds the part of a malformed body kept in errors.
+const callbackBodySnippet = 128
+
+// CallbackError is the refusal of a callback server, with the code and
+// message it returned. It matches ErrCallbackRefused.
+type CallbackError struct {
+	Status     int // HTTP status of the response
+	RemoteCode int
+	RemoteMsg  string
+	RemoteDlt  string
+}
+
+func (e *CallbackError) Error() string {
+	v := []string{"callback refused", "errCode=" + strconv.Itoa(e.RemoteCode)}
+	if e.RemoteMsg != "" {
+		v = append(v, "errMsg="+e.RemoteMsg)
+	}
+	if e.RemoteDlt != "" {
+		v = append(v, "errDlt="+e.RemoteDlt)
+	}
+	return strings.Join(v, " ")
+}
+
+func (e *CallbackError) Unwrap() error {
+	return ErrCallbackRefused
+}
+
+// ParseCallbackError interprets the response of a webhook callback server,
+// the JSON object
+//
+//	{"actionCode":0,"errCode":0,"errMsg":"","errDlt":"","nextCode":0}
+//
+// whose keys may also be snake_case, and codes JSON strings. It returns
+//   - a *CallbackError, matching ErrCallbackRefused, when actionCode or
+//     errCode is not 0, with errCode, or else actionCode, as RemoteCode;
+//   - ErrCallbackContinue when nextCode is 1: the operation goes on with the
+//     original data, ignoring the rest of the response;
+//   - ErrCallbackMalformed when the body is not such an object, as the HTML
+//     error pages of proxies, or a code is not an integer;
+//   - ErrDependencyUnavailable for other responses with a non 2xx status;
+//   - nil otherwise.
+func ParseCallbackError(status int, body []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return callbackMalformed(status, body, "callback response is not a JSON object")
+	}
+	var (
+		actionCode, errCode, nextCode int
+		errMsg, errDlt                string
+		known                         bool
+	)
+	for key, raw := range fields {
+		var err error
+		switch strings.ToLower(strings.ReplaceAll(key, "_", "")) {
+		case "actioncode":
+			actionCode, err = callbackCode(raw)
+		case "errcode":
+			errCode, err = callbackCode(raw)
+		case "nextcode":
+			nextCode, err = callbackCode(raw)
+		case "errmsg":
+			errMsg, err = callbackText(raw)
+		case "errdlt":
+			errDlt, err = callbackText(raw)
+		default:
+			continue
+		}
+		if err != nil {
+			return callbackMalformed(status, body, "invalid "+key+" in callback response")
+		}
+		known = true
+	}
+	if !known {
+		return callbackMalformed(status, body, "no callback field in response")
+	}
+	if actionCode != 0 || errCode != 0 {
+		code := errCode
+		if code == 0 {
+			code = actionCode
+		}
+		return Wrap(&CallbackError{Status: status, RemoteCode: code, RemoteMsg: errMsg, RemoteDlt: errDlt})
+	}
+	if nextCode == callbackNextContinue {
+		return ErrCallbackContinue.Wrap()
+	}
+	if status < 200 || status > 299 {
+		return ErrDependencyUnavailable.WrapMsg("callback server returned an error status", "status", status)
+	}
+	return nil
+}
+
+// callbackCode decodes a code sent as a JSON number or string.
+func callbackCode(raw json.RawMessage) (int, error) {
+	raw = bytes.TrimSpace(raw)
+	if bytes.Equal(raw, []byte("null")) {
+		return 0, nil
+	}
+	if len(raw) > 0 && raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return 0, err
+		}
+		if s = strings.TrimSpace(s); s == "" {
+			return 0, nil
+		}
+		return strconv.Atoi(s)
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return 0, err
+	}
+	code, err := n.Int64()
+	return int(code), err
+}
+
+func callbackText(raw json.RawMessage) (string, error) {
+	var s *string
+	if err := json.Unmarshal(raw, &s); err != nil || s == nil {
+		return "", err
+	}
+	return *s, nil
+}
+
+func callbackMalformed(status int, body []byte, msg string) error {
+	snippet := bytes.TrimSpace(body)
+	if len(snippet) > callbackBodySnippet {
+		snippet = snippet[:callbackBodySnippet]
+		for len(snippet) > 0 && !utf8.Valid(snippet) {
+			snippet = snippet[:len(snippet)-1]
+		}
+	}
+	return ErrCallbackMalformed.WrapMsg(msg, "status", status, "body", string(snippet))
+}
@@ -0,0 +1,77 @@
+package errs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseCallbackError(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		want   error // nil, or the sentinel the result must match
+		code   int   // RemoteCode of refusals
+	}{
+		{"success", 200, `{"actionCode":0,"errCode":0,"errMsg":"","errDlt":"","nextCode":0}`, nil, 0},
+		{"partial success", 200, `{"errCode":0}`, nil, 0},
+		{"continue", 200, `{"actionCode":0,"errCode":0,"nextCode":1}`, ErrCallbackContinue, 0},
+		{"continue string", 200, `{"action_code":"0","next_code":"1"}`, ErrCallbackContinue, 0},
+		{"refused", 200, `{"actionCode":1,"errCode":5001,"errMsg":"sensitive word","errDlt":"word: xx"}`, ErrCallbackRefused, 5001},
+		{"refused snake", 200, `{"action_code":1,"err_code":5002,"err_msg":"banned"}`, ErrCallbackRefused, 5002},
+		{"refused string code", 200, `{"actionCode":"1","errCode":" 5003 ","errMsg":"blocked"}`, ErrCallbackRefused, 5003},
+		{"refused action only", 200, `{"actionCode":7}`, ErrCallbackRefused, 7},
+		{"refused on error status", 403, `{"errCode":401,"errMsg":"unauthorized"}`, ErrCallbackRefused, 401},
+		{"refusal wins over continue", 200, `{"errCode":9,"nextCode":1}`, ErrCallbackRefused, 9},
+		{"null fields", 200, `{"errCode":null,"errMsg":null,"nextCode":0}`, nil, 0},
+		{"error status", 500, `{"actionCode":0,"errCode":0}`, ErrDependencyUnavailable, 0},
+		{"html proxy page", 502, "<html>\r\n<head><title>502 Bad Gateway</title></head>\r\n<body><center><h1>502 Bad Gateway</h1></center><hr><center>nginx</center></body></html>", ErrCallbackMalformed, 0},
+		{"html on 200", 200, "<!DOCTYPE html><html><body>Login required</body></html>", ErrCallbackMalformed, 0},
+		{"empty", 200, ``, ErrCallbackMalformed, 0},
+		{"truncated", 200, `{"errCode":50`, ErrCallbackMalformed, 0},
+		{"array", 200, `[{"errCode":1}]`, ErrCallbackMalformed, 0},
+		{"null", 200, `null`, ErrCallbackMalformed, 0},
+		{"gateway json", 504, `{"message":"upstream request timeout"}`, ErrCallbackMalformed, 0},
+		{"float code", 200, `{"errCode":1.5}`, ErrCallbackMalformed, 0},
+		{"word code", 200, `{"errCode":"denied"}`, ErrCallbackMalformed, 0},
+		{"object message", 200, `{"errCode":1,"errMsg":{"text":"x"}}`, ErrCallbackMalformed, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ParseCallbackError(c.status, []byte(c.body))
+			if c.want == nil {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, c.want) || !c.want.(CodeError).Is(err) {
+				t.Fatalf("err = %v, want %v", err, c.want)
+			}
+			var cbErr *CallbackError
+			if errors.As(err, &cbErr) != (c.code != 0) {
+				t.Fatalf("err = %v, CallbackError expected: %v", err, c.code != 0)
+			}
+			if cbErr != nil && (cbErr.RemoteCode != c.code || cbErr.Status != c.status) {
+				t.Errorf("refusal = %+v, want code %d status %d", cbErr, c.code, c.status)
+			}
+		})
+	}
+}
+
+func TestCallbackErrorText(t *testing.T) {
+	err := ParseCallbackError(200, []byte(`{"actionCode":1,"errCode":5001,"errMsg":"sensitive word","errDlt":"word: xx"}`))
+	if msg := err.Error(); !strings.Contains(msg, "errCode=5001") || !strings.Contains(msg, "sensitive word") || !strings.Contains(msg, "word: xx") {
+		t.Errorf("Error() = %q", msg)
+	}
+	if Code(err) != CallbackRefusedError || Category(err) != CategoryPermission {
+		t.Errorf("code = %d, category = %q", Code(err), Category(err))
+	}
+
+	long := "<html>" + strings.Repeat("é", 200) + "</html>"
+	msg := ParseCallbackError(502, []byte(long)).Error()
+	if strings.Contains(msg, "</html>") || !strings.Contains(msg, "<html>") {
+		t.Errorf("body not truncated: %q", msg)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errs
+
+import "errors"
+
+// Categories group error codes by how callers react to them, for instance to
+// decide whether a failed job is retried.
+const (
+	CategoryNone            = "" // not a code error
+	CategoryInvalidArgument = "invalid_argument"
+	CategoryPermission      = "permission"
+	CategoryNotFound        = "not_found"
+	CategoryConflict        = "conflict"
+	CategoryUnavailable     = "unavailable"
+	CategoryTimeout         = "timeout"
+	CategoryCancelled       = "cancelled"
+	CategoryInternal        = "internal"
+	CategoryOther           = "other" // application codes with no predefined parent
+)
+
+var categories = map[int]string{
+	ServerInternalError:        CategoryInternal,
+	ArgsError:                  CategoryInvalidArgument,
+	NoPermissionError:          CategoryPermission,
+	DuplicateKeyError:          CategoryConflict,
+	RecordNotFoundError:        CategoryNotFound,
+	DependencyUnavailableError: CategoryUnavailable,
+	TimeoutError:               CategoryTimeout,
+	PartialFailureError:        CategoryInternal,
+	ConfigError:                CategoryInternal,
+	ComponentStartError:        CategoryUnavailable,
+	EndpointSunsetError:        CategoryInvalidArgument,
+	CallerCancelledError:       CategoryCancelled,
+	CallbackRefusedError:       CategoryPermission,
+	CallbackMalformedError:     CategoryUnavailable,
+	TokenExpiredError:          CategoryPermission,
+	TokenInvalidError:          CategoryPermission,
+	TokenMalformedError:        CategoryPermission,
+	TokenNotValidYetError:      CategoryPermission,
+	TokenUnknownError:          CategoryPermission,
+	TokenKickedError:           CategoryPermission,
+	TokenNotExistError:         CategoryPermission,
+	TokenDeviceMismatchError:   CategoryPermission,
+}
+
+// Code returns the code of the first CodeError in the chain of err, or 0.
+func Code(err error) int {
+	var codeErr CodeError
+	if !errors.As(err, &codeErr) {
+		return 0
+	}
+	return codeErr.Code()
+}
+
+// Category returns the category of the code of err. Application codes added
+// to DefaultCodeRelation under a predefined code take the category of that
+// code, other application codes are CategoryOther.
+func Category(err error) string {
+	var codeErr CodeError
+	if !errors.As(err, &codeErr) {
+		return CategoryNone
+	}
+	return codeCategory(codeErr.Code())
+}
+
+// CodeCategory returns the category of code, as Category does for errors.
+func CodeCategory(code int) string {
+	return codeCategory(code)
+}
+
+func codeCategory(code int) string {
+	if category, ok := categories[code]; ok {
+		return category
+	}
+	category, parent := CategoryOther, 0
+	for c, cat := range categories {
+		if (parent == 0 || c < parent) && DefaultCodeRelation.Is(c, code) {
+			category, parent = cat, c
+		}
+	}
+	return category
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/openimsdk/tools/errs/stack"
 )
@@ -12,6 +13,8 @@ const stackSkip = 4
 
 var DefaultCodeRelation = newCodeRelation()
 
+// CodeError is an error with a numeric code. Code errors are not comparable
+// with ==, as wrapping returns new values: use errors.Is, or Equal and KeyOf.
 type CodeError interface {
 	Code() int
 	Msg() string
@@ -21,12 +24,28 @@ type CodeError interface {
 }
 
 func NewCodeError(code int, msg string) CodeError {
+	noteCode(code, msg)
 	return &codeError{
 		code: code,
 		msg:  msg,
 	}
 }
 
+// NewSentinel creates a CodeError meant to be stored in a package-level
+// variable and shared by all callers. A codeError is never modified after it
+// is created: WithDetail, Wrap and WrapMsg always return new values. Builds
+// with the errs_debug tag panic if a sentinel is found modified anyway.
+func NewSentinel(code int, msg string) CodeError {
+	e := &codeError{
+		code: code,
+		msg:  msg,
+	}
+	registerSentinel(e)
+	registerCode(code, msg)
+	return e
+}
+
+// codeError is immutable; methods must copy it rather than assign to its fields.
 type codeError struct {
 	code   int
 	msg    string
@@ -46,6 +65,7 @@ func (e *codeError) Detail() string {
 }
 
 func (e *codeError) WithDetail(detail string) CodeError {
+	checkSentinel(e)
 	var d string
 	if e.detail == "" {
 		d = detail
@@ -60,10 +80,12 @@ func (e *codeError) WithDetail(detail string) CodeError {
 }
 
 func (e *codeError) Wrap() error {
+	checkSentinel(e)
 	return stack.New(e, stackSkip)
 }
 
 func (e *codeError) WrapMsg(msg string, kv ...any) error {
+	checkSentinel(e)
 	return WrapMsg(e, msg, kv...)
 }
 
@@ -137,7 +159,8 @@ func newCodeRelation() CodeRelation {
 }
 
 type codeRelation struct {
-	m map[int]map[int]struct{}
+	lock sync.RWMutex
+	m    map[int]map[int]struct{}
 }
 
 const minimumCodesLength = 2
@@ -146,6 +169,8 @@ func (r *codeRelation) Add(codes ...int) error {
 	if len(codes) < minimumCodesLength {
 		return New("codes length must be greater than 2", "codes", codes).Wrap()
 	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	for i := 1; i < len(codes); i++ {
 		parent := codes[i-1]
 		s, ok := r.m[parent]
@@ -164,6 +189,8 @@ func (r *codeRelation) Is(parent, child int) bool {
 	if parent == child {
 		return true
 	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 	s, ok := r.m[parent]
 	if !ok {
 		return false
@@ -0,0 +1,85 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const hammerGoroutines = 100
+
+// hammer calls wrap concurrently on a single sentinel and checks that every
+// result carries exactly its own detail.
+func hammer(t *testing.T, wrap func(detail string) error) {
+	t.Helper()
+	before := ErrComponentStart.Error()
+	results := make([]error, hammerGoroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < hammerGoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = wrap(fmt.Sprintf("component-%03d", i))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range results {
+		msg := err.Error()
+		if n := strings.Count(msg, "component-"); n != 1 {
+			t.Errorf("result %d has %d details: %q", i, n, msg)
+		}
+		if want := fmt.Sprintf("component-%03d", i); !strings.Contains(msg, want) {
+			t.Errorf("result %d = %q, want detail %q", i, msg, want)
+		}
+		if !errors.Is(err, ErrComponentStart) {
+			t.Errorf("result %d does not match ErrComponentStart", i)
+		}
+	}
+	if after := ErrComponentStart.Error(); after != before || ErrComponentStart.Detail() != "" {
+		t.Errorf("sentinel changed from %q to %q", before, after)
+	}
+}
+
+func TestSentinelConcurrentWithDetail(t *testing.T) {
+	hammer(t, func(detail string) error { return ErrComponentStart.WithDetail(detail) })
+}
+
+func TestSentinelConcurrentWrapMsg(t *testing.T) {
+	hammer(t, func(detail string) error { return ErrComponentStart.WrapMsg(detail) })
+}
+
+func TestSentinelConcurrentWrap(t *testing.T) {
+	hammer(t, func(detail string) error { return ErrComponentStart.WithDetail(detail).Wrap() })
+}
+
+func TestWithDetailChain(t *testing.T) {
+	base := ErrArgs.WithDetail("a")
+	b := base.WithDetail("b")
+	c := base.WithDetail("c")
+	if base.Detail() != "a" || b.Detail() != "a, b" || c.Detail() != "a, c" {
+		t.Fatalf("details: %q %q %q", base.Detail(), b.Detail(), c.Detail())
+	}
+}
+
+func TestCodeRelationConcurrent(t *testing.T) {
+	r := newCodeRelation()
+	var wg sync.WaitGroup
+	for i := 0; i < hammerGoroutines; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_ = r.Add(i, i+1000)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			r.Is(i, i+1000)
+		}(i)
+	}
+	wg.Wait()
+	if !r.Is(7, 1007) {
+		t.Fatal("relation lost")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errs
+
+import (
+	"context"
+	"errors"
+)
+
+// ContextErrorKind tells why a request failed with a context error.
+type ContextErrorKind int
+
+const (
+	// NotContextError is a failure unrelated to the request context, including
+	// a context cancelled by the server itself while the request was alive.
+	NotContextError ContextErrorKind = iota
+	// CallerCancelled means the caller gave up, for instance because the
+	// client app was closed. It is not a server failure.
+	CallerCancelled
+	// DeadlineExceeded means the request ran out of time, whether the
+	// deadline came from the caller or from the server.
+	DeadlineExceeded
+)
+
+func (k ContextErrorKind) String() string {
+	switch k {
+	case CallerCancelled:
+		return "CallerCancelled"
+	case DeadlineExceeded:
+		return "DeadlineExceeded"
+	default:
+		return "NotContextError"
+	}
+}
+
+// ClassifyContextError tells whether err, returned while serving ctx, is due
+// to the context of the request. ctx.Err() is authoritative: handlers often
+// report a cancellation as a CodeError built from the message of the context
+// error, which drops it from the chain. When ctx is still alive, a deadline in
+// the chain comes from a call made with a shorter server side timeout, and a
+// cancellation from the server aborting its own work, which is a failure.
+func ClassifyContextError(ctx context.Context, err error) ContextErrorKind {
+	if err == nil {
+		return NotContextError
+	}
+	switch ctx.Err() {
+	case nil:
+	case context.Canceled:
+		return CallerCancelled
+	default:
+		return DeadlineExceeded
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return DeadlineExceeded
+	}
+	return NotContextError
+}
@@ -0,0 +1,37 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClassifyContextError(t *testing.T) {
+	alive := context.Background()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel2 := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel2()
+
+	cases := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want ContextErrorKind
+	}{
+		{"nil error", cancelled, nil, NotContextError},
+		{"genuine failure", alive, errors.New("boom"), NotContextError},
+		{"caller cancelled", cancelled, context.Canceled, CallerCancelled},
+		{"cancel wrapped in a CodeError", cancelled, ErrInternalServer.WrapMsg(context.Canceled.Error()), CallerCancelled},
+		{"deadline", expired, WrapMsg(context.DeadlineExceeded, "find"), DeadlineExceeded},
+		{"deadline wrapped in a CodeError", expired, NewCodeError(ServerInternalError, "context deadline exceeded").Wrap(), DeadlineExceeded},
+		{"server side timeout", alive, WrapMongoError(context.DeadlineExceeded), DeadlineExceeded},
+		{"server abort", alive, Wrap(context.Canceled), NotContextError},
+	}
+	for _, c := range cases {
+		if got := ClassifyContextError(c.ctx, c.err); got != c.want {
+			t.Errorf("%s: %s, want %s", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openimsdk/tools/errs/stack"
+)
+
+// MaxPhases bounds the distinct phase names of a Budget. The time of the
+// phases recorded past it is added to a phase named "other".
+const MaxPhases = 16
+
+// BudgetKey is the context key of the Budget of a request. It is a string so
+// that gin handlers can attach it with gin.Context.Set.
+const BudgetKey = "errsBudget"
+
+// Phase is the time a request spent in one kind of operation.
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Budget tracks where the time of a request goes, for WrapDeadline to
+// explain a deadline error. Its methods are safe for concurrent use and do
+// nothing on a nil Budget.
+type Budget struct {
+	start  time.Time
+	now    func() time.Time
+	mu     sync.Mutex
+	phases []Phase
+}
+
+// NewBudget returns a Budget starting now.
+func NewBudget() *Budget {
+	return &Budget{start: time.Now(), now: time.Now}
+}
+
+// Record adds d to the phase name. Phases of the same name are summed.
+func (b *Budget) Record(name string, d time.Duration) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.phases {
+		if b.phases[i].Name == name {
+			b.phases[i].Duration += d
+			return
+		}
+	}
+	if len(b.phases) >= MaxPhases-1 && name != "other" {
+		name = "other"
+		for i := range b.phases {
+			if b.phases[i].Name == name {
+				b.phases[i].Duration += d
+				return
+			}
+		}
+	}
+	b.phases = append(b.phases, Phase{Name: name, Duration: d})
+}
+
+// Phases returns the phases recorded, in the order they were first recorded.
+func (b *Budget) Phases() []Phase {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Phase(nil), b.phases...)
+}
+
+// Elapsed returns the time since the Budget started.
+func (b *Budget) Elapsed() time.Duration {
+	if b == nil {
+		return 0
+	}
+	return b.now().Sub(b.start)
+}
+
+// WithBudget attaches b to ctx, see mcontext.RecordPhase.
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, BudgetKey, b)
+}
+
+// GetBudget returns the Budget attached to ctx, or nil.
+func GetBudget(ctx context.Context) *Budget {
+	b, _ := ctx.Value(BudgetKey).(*Budget)
+	return b
+}
+
+type deadlineError struct {
+	ErrWrapper
+}
+
+// WrapDeadline annotates a deadline error of the operation op with the time
+// elapsed, the budget the request started with and, when a Budget is
+// attached to ctx, the phases it recorded, such as
+// "GetUser: deadline exceeded after 5s (budget 5s): mongo_query=4.2s, redis=0.3s".
+// Other errors, and errors already annotated by an inner call, are returned
+// unchanged.
+func WrapDeadline(ctx context.Context, err error, op string) error {
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var annotated *deadlineError
+	if errors.As(err, &annotated) {
+		return err
+	}
+	var buf strings.Builder
+	if op != "" {
+		buf.WriteString(op)
+		buf.WriteString(": ")
+	}
+	buf.WriteString("deadline exceeded")
+	b := GetBudget(ctx)
+	deadline, hasDeadline := ctx.Deadline()
+	switch {
+	case b != nil && hasDeadline:
+		buf.WriteString(" after " + formatSeconds(b.Elapsed()) + " (budget " + formatSeconds(deadline.Sub(b.start)) + ")")
+	case b != nil:
+		buf.WriteString(" after " + formatSeconds(b.Elapsed()))
+	case hasDeadline:
+		buf.WriteString(" (deadline " + deadline.Format(time.RFC3339Nano) + ")")
+	}
+	for i, p := range b.Phases() {
+		if i == 0 {
+			buf.WriteString(": ")
+		} else {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(p.Name + "=" + formatSeconds(p.Duration))
+	}
+	return stack.New(&deadlineError{NewErrorWrapper(err, buf.String())}, stackSkip)
+}
+
+// formatSeconds returns d in seconds, to the millisecond, such as "4.2s".
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Round(time.Millisecond).Seconds(), 'f', -1, 64) + "s"
+}
@@ -0,0 +1,101 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrapDeadlineBreakdown(t *testing.T) {
+	start := time.Now()
+	b := &Budget{start: start, now: func() time.Time { return start.Add(5 * time.Second) }}
+	ctx, cancel := context.WithDeadline(WithBudget(context.Background(), b), start.Add(5*time.Second))
+	defer cancel()
+
+	// A handler querying MongoDB then Redis, the second Redis call timing out.
+	b.Record("mongo_query", 4200*time.Millisecond)
+	b.Record("redis", 100*time.Millisecond)
+	b.Record("redis", 200*time.Millisecond)
+	err := WrapDeadline(ctx, WrapMsg(context.DeadlineExceeded, "redis get failed"), "GetUser")
+
+	want := "GetUser: deadline exceeded after 5s (budget 5s): mongo_query=4.2s, redis=0.3s"
+	if !strings.Contains(err.Error(), want+" |") {
+		t.Fatalf("err = %q, want %q", err, want)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("the deadline error was dropped from the chain")
+	}
+	if outer := WrapDeadline(ctx, Wrap(err), "api"); strings.Contains(outer.Error(), "api") {
+		t.Fatalf("annotated twice: %q", outer)
+	}
+}
+
+func TestWrapDeadlineTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(WithBudget(context.Background(), NewBudget()), 50*time.Millisecond)
+	defer cancel()
+	call := func(ctx context.Context, name string, d time.Duration) error {
+		start := time.Now()
+		defer func() { GetBudget(ctx).Record(name, time.Since(start)) }()
+		select {
+		case <-time.After(d):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	var err error
+	for _, step := range []struct {
+		name string
+		d    time.Duration
+	}{{"mongo_query", 20 * time.Millisecond}, {"redis", time.Second}} {
+		if err = call(ctx, step.name, step.d); err != nil {
+			break
+		}
+	}
+	err = WrapDeadline(ctx, err, "SendMsg")
+	msg := err.Error()
+	for _, want := range []string{"SendMsg: deadline exceeded after ", "(budget 0.05s): mongo_query=", ", redis="} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("err = %q, want %q", msg, want)
+		}
+	}
+}
+
+func TestWrapDeadlineOthers(t *testing.T) {
+	ctx := context.Background()
+	if err := WrapDeadline(ctx, nil, "op"); err != nil {
+		t.Fatalf("nil error wrapped: %v", err)
+	}
+	plain := New("boom").Wrap()
+	if err := WrapDeadline(ctx, plain, "op"); err != plain {
+		t.Fatalf("non deadline error changed: %v", err)
+	}
+	if err := WrapDeadline(ctx, context.DeadlineExceeded, "op"); !strings.Contains(err.Error(), "op: deadline exceeded |") {
+		t.Fatalf("without budget nor deadline: %q", err)
+	}
+}
+
+func TestBudgetBounded(t *testing.T) {
+	b := NewBudget()
+	for i := 0; i < 100; i++ {
+		b.Record("phase"+strconv.Itoa(i), time.Millisecond)
+	}
+	phases := b.Phases()
+	if len(phases) != MaxPhases {
+		t.Fatalf("%d phases, want %d", len(phases), MaxPhases)
+	}
+	if last := phases[MaxPhases-1]; last.Name != "other" || last.Duration != 85*time.Millisecond {
+		t.Fatalf("last phase = %+v, want other=85ms", last)
+	}
+	var nilBudget *Budget
+	nilBudget.Record("x", time.Second)
+	if nilBudget.Phases() != nil {
+		t.Fatal("nil budget recorded")
+	}
+	if n := testing.AllocsPerRun(100, func() { b.Record("phase3", time.Millisecond) }); n != 0 {
+		t.Errorf("Record allocates %v times", n)
+	}
+}
@@ -0,0 +1,273 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
+
+	"github.com/openimsdk/tools/errs/stack"
+)
+
+// Error messages and codes of the database and mq drivers, matched without importing the drivers
+// so that errs stays dependency free. Messages are only consulted when no typed check applies.
+const (
+	mongoNoDocumentsMsg       = "mongo: no documents in result"
+	mongoClientDisconnectMsg  = "client is disconnected"
+	mongoServerSelectionMsg   = "server selection error"
+	mongoDuplicateKeyMsg      = "E11000 duplicate key"
+	mongoNetworkErrorLabel    = "NetworkError"
+	mongoNetworkTimeoutLabel  = "NetworkTimeoutError"
+	mongoExceededTimeLabel    = "ExceededTimeLimitError"
+	mongoMaxTimeMSExpiredCode = 50
+
+	redisNilMsg         = "redis: nil"
+	redisClosedMsg      = "redis: client is closed"
+	redisPoolTimeoutMsg = "redis: connection pool timeout"
+
+	kafkaOutOfBrokersMsg = "kafka: client has run out of available brokers to talk to"
+	kafkaNotConnectedMsg = "kafka: broker not connected"
+	kafkaClosedMsg       = "kafka: tried to use a client that was closed"
+)
+
+var (
+	mongoDuplicateKeyCodes = []int{11000, 11001, 12582}
+
+	// redisUnavailablePrefixes are server replies meaning the node cannot serve the request right now.
+	redisUnavailablePrefixes = []string{"CLUSTERDOWN", "LOADING", "MASTERDOWN", "TRYAGAIN", "READONLY"}
+
+	// kafkaCodes maps sarama.KError values to the shared sentinels.
+	kafkaCodes = map[int64]CodeError{
+		3:  ErrRecordNotFound,        // UnknownTopicOrPartition
+		5:  ErrDependencyUnavailable, // LeaderNotAvailable
+		6:  ErrDependencyUnavailable, // NotLeaderForPartition
+		7:  ErrTimeout,               // RequestTimedOut
+		8:  ErrDependencyUnavailable, // BrokerNotAvailable
+		15: ErrDependencyUnavailable, // ConsumerCoordinatorNotAvailable
+		19: ErrDependencyUnavailable, // NotEnoughReplicas
+		20: ErrDependencyUnavailable, // NotEnoughReplicasAfterAppend
+		36: ErrArgs,                  // TopicAlreadyExists
+	}
+)
+
+// WrapMongoError classifies a mongo driver error into the shared sentinels
+// (ErrRecordNotFound, ErrDuplicateKey, ErrDependencyUnavailable, ErrTimeout).
+// The original error stays reachable through errors.Is/As. Unclassified errors are only wrapped.
+func WrapMongoError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return classify(err, classifyMongo(err))
+}
+
+// WrapRedisError classifies a go-redis error, see WrapMongoError.
+func WrapRedisError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return classify(err, classifyRedis(err))
+}
+
+// WrapKafkaError classifies a sarama error, see WrapMongoError. Creating a
+// topic that exists is ErrArgs, since no record is duplicated.
+func WrapKafkaError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return classify(err, classifyKafka(err))
+}
+
+func IsRecordNotFound(err error) bool {
+	return errors.Is(err, ErrRecordNotFound)
+}
+
+func IsDuplicateKey(err error) bool {
+	return errors.Is(err, ErrDuplicateKey)
+}
+
+func IsDependencyUnavailable(err error) bool {
+	return errors.Is(err, ErrDependencyUnavailable)
+}
+
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+func classify(err error, sentinel CodeError) error {
+	if sentinel == nil {
+		return stack.New(err, stackSkip)
+	}
+	return stack.New(&driverError{CodeError: sentinel, cause: err}, stackSkip)
+}
+
+// driverError is a sentinel carrying the driver error it was classified from.
+type driverError struct {
+	CodeError
+	cause error
+}
+
+func (e *driverError) Detail() string {
+	return e.cause.Error()
+}
+
+func (e *driverError) Error() string {
+	return e.CodeError.Error() + ": " + e.cause.Error()
+}
+
+func (e *driverError) Unwrap() []error {
+	return []error{e.CodeError, e.cause}
+}
+
+func classifyMongo(err error) CodeError {
+	switch {
+	case chainAny(err, matchMsg(mongoNoDocumentsMsg)):
+		return ErrRecordNotFound
+	case chainAny(err, isMongoDuplicateKey):
+		return ErrDuplicateKey
+	case isTimeout(err) || chainAny(err, isMongoTimeout):
+		return ErrTimeout
+	case isNetwork(err) || chainAny(err, isMongoUnavailable):
+		return ErrDependencyUnavailable
+	}
+	return nil
+}
+
+func classifyRedis(err error) CodeError {
+	switch {
+	case chainAny(err, matchMsg(redisNilMsg)):
+		return ErrRecordNotFound
+	case isTimeout(err) || chainAny(err, matchMsg(redisPoolTimeoutMsg)):
+		return ErrTimeout
+	case isNetwork(err) || chainAny(err, isRedisUnavailable):
+		return ErrDependencyUnavailable
+	}
+	return nil
+}
+
+func classifyKafka(err error) CodeError {
+	var sentinel CodeError
+	if chainAny(err, func(e error) bool {
+		code, ok := kafkaErrorCode(e)
+		if ok {
+			sentinel = kafkaCodes[code]
+		}
+		return sentinel != nil
+	}) {
+		return sentinel
+	}
+	switch {
+	case isTimeout(err):
+		return ErrTimeout
+	case isNetwork(err) || chainAny(err, matchMsg(kafkaOutOfBrokersMsg, kafkaNotConnectedMsg, kafkaClosedMsg)):
+		return ErrDependencyUnavailable
+	}
+	return nil
+}
+
+// kafkaErrorCode extracts the numeric code of a sarama.KError (an int16 named type).
+func kafkaErrorCode(err error) (int64, bool) {
+	t := reflect.TypeOf(err)
+	if t.Name() != "KError" || t.Kind() != reflect.Int16 || !strings.HasSuffix(t.PkgPath(), "sarama") {
+		return 0, false
+	}
+	return reflect.ValueOf(err).Int(), true
+}
+
+func isMongoDuplicateKey(err error) bool {
+	if e, ok := err.(interface{ HasErrorCode(int) bool }); ok {
+		for _, code := range mongoDuplicateKeyCodes {
+			if e.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+	return strings.Contains(err.Error(), mongoDuplicateKeyMsg)
+}
+
+func isMongoTimeout(err error) bool {
+	if e, ok := err.(interface{ HasErrorLabel(string) bool }); ok {
+		if e.HasErrorLabel(mongoNetworkTimeoutLabel) || e.HasErrorLabel(mongoExceededTimeLabel) {
+			return true
+		}
+	}
+	if e, ok := err.(interface{ HasErrorCode(int) bool }); ok && e.HasErrorCode(mongoMaxTimeMSExpiredCode) {
+		return true
+	}
+	return false
+}
+
+func isMongoUnavailable(err error) bool {
+	if e, ok := err.(interface{ HasErrorLabel(string) bool }); ok && e.HasErrorLabel(mongoNetworkErrorLabel) {
+		return true
+	}
+	return matchMsg(mongoClientDisconnectMsg)(err) || strings.HasPrefix(err.Error(), mongoServerSelectionMsg)
+}
+
+func isRedisUnavailable(err error) bool {
+	if matchMsg(redisClosedMsg)(err) {
+		return true
+	}
+	msg := err.Error()
+	for _, prefix := range redisUnavailablePrefixes {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isNetwork(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+func matchMsg(msgs ...string) func(error) bool {
+	return func(err error) bool {
+		s := err.Error()
+		for _, msg := range msgs {
+			if s == msg {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// chainAny reports whether fn matches err or any error it wraps, following both
+// Unwrap() error and Unwrap() []error.
+func chainAny(err error, fn func(error) bool) bool {
+	if err == nil {
+		return false
+	}
+	if fn(err) {
+		return true
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return chainAny(e.Unwrap(), fn)
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			if chainAny(err, fn) {
+				return true
+			}
+		}
+	}
+	return false
+}
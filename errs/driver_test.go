@@ -0,0 +1,85 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestWrapDriverErrors(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	tests := []struct {
+		name     string
+		wrap     func(error) error
+		err      error
+		sentinel CodeError
+	}{
+		{"mongo no documents", WrapMongoError, mongo.ErrNoDocuments, ErrRecordNotFound},
+		{"mongo wrapped no documents", WrapMongoError, fmt.Errorf("find user: %w", mongo.ErrNoDocuments), ErrRecordNotFound},
+		{"mongo duplicate key", WrapMongoError, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}}, ErrDuplicateKey},
+		{"mongo duplicate command", WrapMongoError, mongo.CommandError{Code: 11000}, ErrDuplicateKey},
+		{"mongo max time", WrapMongoError, mongo.CommandError{Code: 50, Name: "MaxTimeMSExpired"}, ErrTimeout},
+		{"mongo deadline", WrapMongoError, context.DeadlineExceeded, ErrTimeout},
+		{"mongo network label", WrapMongoError, mongo.CommandError{Labels: []string{"NetworkError"}}, ErrDependencyUnavailable},
+		{"mongo disconnected", WrapMongoError, mongo.ErrClientDisconnected, ErrDependencyUnavailable},
+		{"mongo dial", WrapMongoError, dialErr, ErrDependencyUnavailable},
+		{"redis nil", WrapRedisError, redis.Nil, ErrRecordNotFound},
+		{"redis closed", WrapRedisError, redis.ErrClosed, ErrDependencyUnavailable},
+		// pool timeouts and server replies are unexported driver types, only their text is stable
+		{"redis pool timeout", WrapRedisError, errors.New("redis: connection pool timeout"), ErrTimeout},
+		{"redis cluster down", WrapRedisError, errors.New("CLUSTERDOWN The cluster is down"), ErrDependencyUnavailable},
+		{"redis dial", WrapRedisError, dialErr, ErrDependencyUnavailable},
+		{"kafka not leader", WrapKafkaError, sarama.ErrNotLeaderForPartition, ErrDependencyUnavailable},
+		{"kafka request timeout", WrapKafkaError, sarama.ErrRequestTimedOut, ErrTimeout},
+		{"kafka unknown topic", WrapKafkaError, fmt.Errorf("produce: %w", sarama.ErrUnknownTopicOrPartition), ErrRecordNotFound},
+		{"kafka topic exists", WrapKafkaError, sarama.ErrTopicAlreadyExists, ErrArgs},
+		{"kafka out of brokers", WrapKafkaError, sarama.ErrOutOfBrokers, ErrDependencyUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.wrap(tt.err)
+			if !errors.Is(err, tt.sentinel) {
+				t.Fatalf("%v is not classified as %v", err, tt.sentinel)
+			}
+			// driver errors such as mongo.WriteException are not comparable, so look them up by type
+			target := reflect.New(reflect.TypeOf(tt.err))
+			if !errors.As(err, target.Interface()) {
+				t.Fatalf("original error %v is not reachable from %v", tt.err, err)
+			}
+			codeErr, ok := Unwrap(err).(CodeError)
+			if !ok || codeErr.Code() != tt.sentinel.Code() {
+				t.Fatalf("Unwrap(%v) is not a CodeError with code %d", err, tt.sentinel.Code())
+			}
+		})
+	}
+}
+
+func TestWrapDriverErrorsUnclassified(t *testing.T) {
+	orig := errors.New("something else")
+	for _, wrap := range []func(error) error{WrapMongoError, WrapRedisError, WrapKafkaError} {
+		if wrap(nil) != nil {
+			t.Fatal("nil error must stay nil")
+		}
+		err := wrap(orig)
+		if !errors.Is(err, orig) {
+			t.Fatalf("%v lost the original error", err)
+		}
+		if IsRecordNotFound(err) || IsDuplicateKey(err) || IsDependencyUnavailable(err) || IsTimeout(err) {
+			t.Fatalf("%v must not be classified", err)
+		}
+	}
+}
+
+func TestWrapKafkaTopicAlreadyExists(t *testing.T) {
+	err := WrapKafkaError(sarama.ErrTopicAlreadyExists)
+	if IsDuplicateKey(err) {
+		t.Fatalf("%v must not be ErrDuplicateKey", err)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errs
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Key identifies a code error by its code and message, without its detail
+// and wrapping. Unlike the errors themselves, keys can be used as map keys
+// and compared with ==.
+type Key struct {
+	Code int
+	Msg  string
+}
+
+// KeyOf returns the key of the first CodeError in the chain of err. ok is
+// false if err has no CodeError.
+func KeyOf(err error) (key Key, ok bool) {
+	var codeErr CodeError
+	if !errors.As(err, &codeErr) {
+		return Key{}, false
+	}
+	return Key{Code: codeErr.Code(), Msg: codeErr.Msg()}, true
+}
+
+// Equal reports whether a and b are the same error: code errors with the same
+// code and message, whatever their details and wrapping, or other errors
+// whose roots are ==. To test for a sentinel, errors.Is should be preferred,
+// as it also matches the child codes of DefaultCodeRelation.
+//
+// Code errors must not be compared with ==: WithDetail, Wrap and WrapMsg
+// return new values, which are never == to the sentinel they come from.
+func Equal(a, b error) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ka, okA := KeyOf(a)
+	kb, okB := KeyOf(b)
+	if okA || okB {
+		return okA && okB && ka == kb
+	}
+	ra, rb := Unwrap(a), Unwrap(b)
+	if reflect.TypeOf(ra) != reflect.TypeOf(rb) || !reflect.TypeOf(ra).Comparable() {
+		return false
+	}
+	return ra == rb
+}
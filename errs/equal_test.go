@@ -0,0 +1,52 @@
+package errs
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+type uncomparableError []string
+
+func (e uncomparableError) Error() string { return "uncomparable" }
+
+func TestEqual(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		a, b error
+		want bool
+	}{
+		{"nil", nil, nil, true},
+		{"nil and error", nil, ErrArgs, false},
+		{"same sentinel", ErrArgs, ErrArgs, true},
+		{"detail", ErrArgs.WithDetail("userID"), ErrArgs, true},
+		{"wrapped", WrapMsg(ErrArgs.WithDetail("a"), "check"), ErrArgs.WithDetail("b").Wrap(), true},
+		{"other code", ErrArgs, ErrRecordNotFound, false},
+		{"same code, other message", NewCodeError(ArgsError, "BadRequest"), ErrArgs, false},
+		{"code and plain error", ErrArgs, io.EOF, false},
+		{"plain errors", Wrap(io.EOF), io.EOF, true},
+		{"different plain errors", io.EOF, io.ErrUnexpectedEOF, false},
+		{"uncomparable", uncomparableError{"a"}, uncomparableError{"a"}, false},
+	} {
+		if got := Equal(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: Equal = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKeyOf(t *testing.T) {
+	counts := make(map[Key]int)
+	for _, err := range []error{ErrArgs.WithDetail("a"), WrapMsg(ErrArgs, "b"), ErrRecordNotFound.Wrap()} {
+		key, ok := KeyOf(err)
+		if !ok {
+			t.Fatalf("KeyOf(%v) not ok", err)
+		}
+		counts[key]++
+	}
+	if counts[Key{Code: ArgsError, Msg: ErrArgs.Msg()}] != 2 || len(counts) != 2 {
+		t.Fatalf("counts = %v", counts)
+	}
+	if _, ok := KeyOf(errors.New("plain")); ok {
+		t.Fatal("KeyOf returned a key for a plain error")
+	}
+}
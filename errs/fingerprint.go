@@ -0,0 +1,97 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errsDir is the directory of this package, whose frames are skipped when
+// looking for the call site of a wrap.
+var errsDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// Fingerprint identifies where and how err happened, so that repeated
+// occurrences of the same failure can be grouped, for instance by alerting.
+// It hashes the code of err, the call site of the innermost Wrap or WrapMsg
+// and the message of the root error with digit runs replaced, so that
+// embedded IDs and counts do not matter. Fingerprints only depend on function
+// names and line numbers, and are therefore stable across restarts of the
+// same build.
+func Fingerprint(err error) string {
+	if err == nil {
+		return ""
+	}
+	h := sha256.New()
+	h.Write([]byte(strconv.Itoa(Code(err))))
+	h.Write([]byte{0})
+	h.Write([]byte(wrapSite(err)))
+	h.Write([]byte{0})
+	h.Write([]byte(messageTemplate(Unwrap(err).Error())))
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// wrapSite returns "function:line" of the first frame outside this package
+// in the stack of the innermost wrap of err, or "" if err has no stack.
+func wrapSite(err error) string {
+	var pcs []uintptr
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if s, ok := e.(interface{ Callers() []uintptr }); ok {
+			pcs = s.Callers()
+		}
+	}
+	if len(pcs) == 0 {
+		return ""
+	}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		dir := filepath.Dir(frame.File)
+		inErrs := (dir == errsDir || strings.HasPrefix(dir, errsDir+"/")) && !strings.HasSuffix(frame.File, "_test.go")
+		if !inErrs {
+			return frame.Function + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// messageTemplate replaces the digit runs of msg with '#'.
+func messageTemplate(msg string) string {
+	var b strings.Builder
+	inDigits := false
+	for _, r := range msg {
+		if unicode.IsDigit(r) {
+			if !inDigits {
+				b.WriteByte('#')
+			}
+			inDigits = true
+			continue
+		}
+		inDigits = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
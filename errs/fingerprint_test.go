@@ -0,0 +1,63 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func notFound(id int) error {
+	return ErrRecordNotFound.WrapMsg(fmt.Sprintf("user %d", id))
+}
+
+func timedOut(ms int) error {
+	return New(fmt.Sprintf("redis timeout after %dms", ms)).Wrap()
+}
+
+func TestFingerprint(t *testing.T) {
+	if Fingerprint(notFound(1)) != Fingerprint(notFound(2)) {
+		t.Error("same code and call site, different fingerprints")
+	}
+	if Fingerprint(timedOut(10)) != Fingerprint(timedOut(2500)) {
+		t.Error("digits in the root message change the fingerprint")
+	}
+	// Outer wraps do not matter, only the innermost one.
+	if Fingerprint(WrapMsg(notFound(1), "get user")) != Fingerprint(Wrap(notFound(3))) {
+		t.Error("outer wraps change the fingerprint")
+	}
+
+	distinct := map[string]error{
+		"call site": ErrRecordNotFound.WrapMsg("user 1"),
+		"code":      ErrArgs.WrapMsg("user 1"),
+		"message":   New("redis refused").Wrap(),
+		"no stack":  ErrRecordNotFound,
+	}
+	seen := map[string]string{Fingerprint(notFound(1)): "base", Fingerprint(timedOut(1)): "timeout"}
+	for name, err := range distinct {
+		fp := Fingerprint(err)
+		if other, ok := seen[fp]; ok {
+			t.Errorf("%s: same fingerprint as %s", name, other)
+		}
+		seen[fp] = name
+	}
+
+	if fp := Fingerprint(errors.New("plain")); len(fp) != 16 || fp != Fingerprint(errors.New("plain")) {
+		t.Errorf("plain error fingerprint %q", fp)
+	}
+	if Fingerprint(nil) != "" {
+		t.Error("nil error has a fingerprint")
+	}
+}
+
+func TestWrapSite(t *testing.T) {
+	site := wrapSite(WrapMsg(notFound(1), "outer"))
+	// The frames of this package are skipped, so the site is notFound and
+	// not codeError.WrapMsg. It holds no address, only a name and a line.
+	if !strings.HasPrefix(site, "github.com/openimsdk/tools/errs.notFound:") {
+		t.Fatalf("site = %s", site)
+	}
+	if strings.Contains(site, "0x") || strings.Contains(site, "/errs/") {
+		t.Fatalf("site = %s depends on addresses or paths", site)
+	}
+}
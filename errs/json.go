@@ -0,0 +1,246 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// jsonError is the JSON form of an error chain, see ToJSON.
+type jsonError struct {
+	Code   int      `json:"code"`
+	Msg    string   `json:"msg"`
+	Detail []string `json:"detail,omitempty"`
+	// Wrapped counts the leading Detail entries that are wrap messages, the
+	// others make up the detail of the code error.
+	Wrapped  int      `json:"wrapped,omitempty"`
+	Category string   `json:"category,omitempty"`
+	Stack    []string `json:"stack,omitempty"`
+}
+
+type jsonConfig struct {
+	stack bool
+}
+
+type JSONOption func(*jsonConfig)
+
+// WithStack includes the stack recorded by the outermost Wrap or WrapMsg, as
+// "function file:line" strings.
+func WithStack() JSONOption {
+	return func(c *jsonConfig) {
+		c.stack = true
+	}
+}
+
+// ToJSON encodes err for persistence, for instance in the record of a failed
+// job, as
+//
+//	{"code":1004,"msg":"RecordNotFoundError","detail":["load user","uid 42"],"wrapped":1,"category":"not_found"}
+//
+// code and msg come from the first CodeError of the chain, or are 0 and the
+// error text for errors without code. detail lists the WrapMsg messages,
+// outermost first, followed by the detail of the code error; wrapped counts
+// the former. category is informational, FromJSON derives it from code again.
+// The stack is left out unless WithStack is given. A nil err encodes as null.
+func ToJSON(err error, opts ...JSONOption) ([]byte, error) {
+	if err == nil {
+		return []byte("null"), nil
+	}
+	var cfg jsonConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return json.Marshal(encodeChain(err, cfg))
+}
+
+// FromJSON restores an error encoded by ToJSON or by the MarshalJSON methods
+// of the errors of this package. Code, Category, errors.Is against sentinels
+// and code relations, and predicates such as IsRecordNotFound behave as they
+// did for the original error; the original driver errors and error types are
+// not restored. Unknown fields are ignored. null restores a nil error; data
+// that is not an encoded error is reported with the second result.
+func FromJSON(data []byte) (error, error) {
+	var v *jsonError
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, ErrArgs.WrapMsg("decode error JSON", "err", err.Error())
+	}
+	if v == nil {
+		return nil, nil
+	}
+	return v.restore(), nil
+}
+
+// JSONError stores an error in a JSON document or column: it encodes like
+// ToJSON, with the stack if Stack is set, and decodes like FromJSON.
+type JSONError struct {
+	Err   error
+	Stack bool
+}
+
+func (e JSONError) MarshalJSON() ([]byte, error) {
+	if e.Stack {
+		return ToJSON(e.Err, WithStack())
+	}
+	return ToJSON(e.Err)
+}
+
+func (e *JSONError) UnmarshalJSON(data []byte) error {
+	err, decodeErr := FromJSON(data)
+	if decodeErr != nil {
+		return decodeErr
+	}
+	e.Err = err
+	return nil
+}
+
+// MarshalJSON encodes the error like ToJSON. A codeError cannot be decoded in
+// place since sentinels must never change, decode with FromJSON or JSONError.
+func (e *codeError) MarshalJSON() ([]byte, error) {
+	return ToJSON(e)
+}
+
+func (e *errorWrapper) MarshalJSON() ([]byte, error) {
+	return ToJSON(e)
+}
+
+func (e *errorString) MarshalJSON() ([]byte, error) {
+	return ToJSON(e)
+}
+
+func (e *driverError) MarshalJSON() ([]byte, error) {
+	return ToJSON(e)
+}
+
+func encodeChain(err error, cfg jsonConfig) *jsonError {
+	v := &jsonError{}
+	var own string
+	for err != nil {
+		if codeErr, ok := err.(CodeError); ok {
+			v.Code, v.Msg, own = codeErr.Code(), codeErr.Msg(), codeErr.Detail()
+			break
+		}
+		switch e := err.(type) {
+		case *errorWrapper:
+			v.Detail = append(v.Detail, e.s)
+			err = e.error
+			continue
+		case *restoredStack:
+			if cfg.stack && v.Stack == nil {
+				v.Stack = e.frames
+			}
+			err = e.err
+			continue
+		case interface{ Callers() []uintptr }:
+			if cfg.stack && v.Stack == nil {
+				v.Stack = formatFrames(e.Callers())
+			}
+			err = errors.Unwrap(err)
+			continue
+		}
+		// An error of another package: keep its text, and the code of the
+		// first CodeError it wraps if any.
+		var codeErr CodeError
+		if !errors.As(err, &codeErr) {
+			v.Msg = err.Error()
+			break
+		}
+		v.Detail = append(v.Detail, foreignText(err))
+		v.Code, v.Msg, own = codeErr.Code(), codeErr.Msg(), codeErr.Detail()
+		break
+	}
+	v.Wrapped = len(v.Detail)
+	if own != "" {
+		v.Detail = append(v.Detail, own)
+	}
+	if v.Code != 0 {
+		v.Category = codeCategory(v.Code)
+	}
+	return v
+}
+
+// foreignText returns the text of err with the stack of the first error of
+// this package it wraps left out.
+func foreignText(err error) string {
+	text := err.Error()
+	for e := errors.Unwrap(err); e != nil; e = errors.Unwrap(e) {
+		if _, ok := e.(interface{ Callers() []uintptr }); ok {
+			return strings.Replace(text, e.Error(), errors.Unwrap(e).Error(), 1)
+		}
+	}
+	return text
+}
+
+func formatFrames(pcs []uintptr) []string {
+	frames := runtime.CallersFrames(pcs)
+	var out []string
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "runtime.") {
+			break
+		}
+		out = append(out, frame.Function+" "+frame.File+":"+strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	return out
+}
+
+func (v *jsonError) restore() error {
+	wrapped := min(max(v.Wrapped, 0), len(v.Detail))
+	var err error
+	if v.Code != 0 {
+		err = &codeError{code: v.Code, msg: v.Msg, detail: strings.Join(v.Detail[wrapped:], ", ")}
+	} else {
+		err = &errorString{s: v.Msg}
+		for _, d := range v.Detail[wrapped:] {
+			err = &errorWrapper{error: err, s: d}
+		}
+	}
+	for i := wrapped - 1; i >= 0; i-- {
+		err = &errorWrapper{error: err, s: v.Detail[i]}
+	}
+	if len(v.Stack) > 0 {
+		err = &restoredStack{err: err, frames: v.Stack}
+	}
+	return err
+}
+
+// restoredStack is a stack decoded by FromJSON, printed like the stack of
+// Wrap.
+type restoredStack struct {
+	err    error
+	frames []string
+}
+
+func (e *restoredStack) Unwrap() error {
+	return e.err
+}
+
+func (e *restoredStack) Error() string {
+	var sb strings.Builder
+	sb.WriteString("Error: ")
+	sb.WriteString(e.err.Error())
+	sb.WriteString(" |")
+	for _, frame := range e.frames {
+		sb.WriteString(" -> ")
+		sb.WriteString(frame)
+	}
+	return sb.String()
+}
@@ -0,0 +1,177 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var errAppNotFound = NewSentinel(93001, "AppNotFound")
+
+func init() {
+	_ = DefaultCodeRelation.Add(RecordNotFoundError, 93001)
+}
+
+var jsonSentinels = []error{
+	ErrArgs, ErrNoPermission, ErrInternalServer, ErrRecordNotFound, ErrDuplicateKey,
+	ErrDependencyUnavailable, ErrTimeout, ErrCallerCancelled, ErrTokenExpired, errAppNotFound,
+}
+
+func jsonCases() map[string]error {
+	return map[string]error{
+		"sentinel":      ErrArgs,
+		"wrap msg":      ErrRecordNotFound.WrapMsg("load user", "uid", 42),
+		"detail":        ErrArgs.WithDetail("field groupID").WrapMsg("bind request"),
+		"nested wrap":   WrapMsg(ErrDuplicateKey.WrapMsg("insert"), "create group", "groupID", "g1"),
+		"driver":        WrapMongoError(errors.New("mongo: no documents in result")),
+		"kafka driver":  WrapKafkaError(errors.New("kafka: client has run out of available brokers to talk to")),
+		"app code":      errAppNotFound.WrapMsg("lookup app"),
+		"foreign":       fmt.Errorf("call rpc: %w", ErrTimeout.Wrap()),
+		"no code":       New("plain failure", "k", "v").Wrap(),
+		"unwrapped std": errors.New("std error"),
+	}
+}
+
+func assertSameBehavior(t *testing.T, orig, restored error) {
+	t.Helper()
+	if Code(restored) != Code(orig) {
+		t.Errorf("code = %d, want %d", Code(restored), Code(orig))
+	}
+	if Category(restored) != Category(orig) {
+		t.Errorf("category = %q, want %q", Category(restored), Category(orig))
+	}
+	predicates := map[string]func(error) bool{
+		"IsRecordNotFound": IsRecordNotFound, "IsDuplicateKey": IsDuplicateKey,
+		"IsDependencyUnavailable": IsDependencyUnavailable, "IsTimeout": IsTimeout,
+	}
+	for name, is := range predicates {
+		if is(restored) != is(orig) {
+			t.Errorf("%s = %t, want %t", name, is(restored), is(orig))
+		}
+	}
+	for _, sentinel := range jsonSentinels {
+		if errors.Is(restored, sentinel) != errors.Is(orig, sentinel) {
+			t.Errorf("errors.Is(restored, %v) = %t", sentinel, errors.Is(restored, sentinel))
+		}
+	}
+	var origCode, restoredCode CodeError
+	if errors.As(orig, &origCode) {
+		if !errors.As(restored, &restoredCode) {
+			t.Fatalf("restored %v has no CodeError", restored)
+		}
+		if restoredCode.Msg() != origCode.Msg() || restoredCode.Detail() != origCode.Detail() {
+			t.Errorf("code error = %q %q, want %q %q", restoredCode.Msg(), restoredCode.Detail(), origCode.Msg(), origCode.Detail())
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for name, orig := range jsonCases() {
+		t.Run(name, func(t *testing.T) {
+			data, err := ToJSON(orig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "json_test.go") {
+				t.Errorf("stack included by default: %s", data)
+			}
+			restored, err := FromJSON(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameBehavior(t, orig, restored)
+			// Driver errors restore as plain code errors.
+			if got, want := Unwrap(restored).Error(), Unwrap(orig).Error(); got != want && !strings.Contains(name, "driver") {
+				t.Errorf("message = %q, want %q", got, want)
+			}
+			again, _ := ToJSON(restored)
+			if string(again) != string(data) {
+				t.Errorf("re-encoded = %s, want %s", again, data)
+			}
+		})
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	data, _ := json.Marshal(ErrRecordNotFound.WithDetail("uid 42").WrapMsg("load user"))
+	want := `{"code":1004,"msg":"RecordNotFoundError","detail":["load user","uid 42"],"wrapped":1,"category":"not_found"}`
+	if string(data) != want {
+		t.Errorf("json.Marshal = %s, want %s", data, want)
+	}
+	data, _ = json.Marshal(ErrArgs)
+	if want := `{"code":1001,"msg":"ArgsError","category":"invalid_argument"}`; string(data) != want {
+		t.Errorf("sentinel = %s, want %s", data, want)
+	}
+}
+
+// jobRecord is an async job row whose last error is kept in a JSON column.
+type jobRecord struct {
+	ID        int       `json:"id"`
+	Attempts  int       `json:"attempts"`
+	LastError JSONError `json:"lastError"`
+}
+
+func TestJSONColumn(t *testing.T) {
+	column := make(map[int]string) // id -> JSON column value
+	for i, orig := range []error{ErrRecordNotFound.WrapMsg("webhook target"), WrapRedisError(errors.New("redis: connection pool timeout")), nil} {
+		row, err := json.Marshal(jobRecord{ID: i, Attempts: 3, LastError: JSONError{Err: orig}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		column[i] = string(row)
+
+		var loaded jobRecord
+		if err := json.Unmarshal([]byte(column[i]), &loaded); err != nil {
+			t.Fatal(err)
+		}
+		if orig == nil {
+			if loaded.LastError.Err != nil {
+				t.Errorf("nil error restored as %v", loaded.LastError.Err)
+			}
+			continue
+		}
+		assertSameBehavior(t, orig, loaded.LastError.Err)
+	}
+}
+
+func TestJSONStack(t *testing.T) {
+	orig := ErrTimeout.WrapMsg("send push")
+	data, err := ToJSON(orig, WithStack())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "json_test.go") || !strings.Contains(string(data), "TestJSONStack") {
+		t.Fatalf("stack missing: %s", data)
+	}
+	restored, _ := FromJSON(data)
+	if msg := restored.Error(); !strings.HasPrefix(msg, "Error: send push |") || !strings.Contains(msg, "json_test.go") {
+		t.Errorf("restored message = %q", msg)
+	}
+	assertSameBehavior(t, orig, restored)
+	again, _ := ToJSON(restored, WithStack())
+	if string(again) != string(data) {
+		t.Errorf("re-encoded = %s, want %s", again, data)
+	}
+}
+
+func TestFromJSONCompatibility(t *testing.T) {
+	restored, err := FromJSON([]byte(`{"code":1004,"msg":"RecordNotFoundError","detail":["uid 42"],"retryAfter":30,"origin":{"service":"push"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsRecordNotFound(restored) {
+		t.Errorf("%v is not a RecordNotFound error", restored)
+	}
+	var codeErr CodeError
+	if !errors.As(restored, &codeErr) || codeErr.Detail() != "uid 42" {
+		t.Errorf("detail lost: %v", restored)
+	}
+	if restored, err := FromJSON([]byte("null")); restored != nil || err != nil {
+		t.Errorf("null = %v, %v", restored, err)
+	}
+	if _, err := FromJSON([]byte(`"text"`)); err == nil {
+		t.Error("no error for a JSON string")
+	}
+}
@@ -0,0 +1,41 @@
+package errs
+
+import "strings"
+
+// MultiError collects several independent errors, such as all violations found
+// by a validation pass. It supports errors.Is and errors.As on every element.
+type MultiError struct {
+	Errors []error
+}
+
+// Append adds err if it is not nil.
+func (m *MultiError) Append(err error) {
+	if err != nil {
+		m.Errors = append(m.Errors, err)
+	}
+}
+
+// Len returns the number of collected errors.
+func (m *MultiError) Len() int {
+	return len(m.Errors)
+}
+
+// ErrorOrNil returns m if it holds at least one error, nil otherwise.
+func (m *MultiError) ErrorOrNil() error {
+	if m == nil || len(m.Errors) == 0 {
+		return nil
+	}
+	return m
+}
+
+func (m *MultiError) Error() string {
+	msgs := make([]string, len(m.Errors))
+	for i, err := range m.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (m *MultiError) Unwrap() []error {
+	return m.Errors
+}
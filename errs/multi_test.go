@@ -0,0 +1,30 @@
+package errs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMultiError(t *testing.T) {
+	var m MultiError
+	m.Append(nil)
+	if m.ErrorOrNil() != nil {
+		t.Fatal("empty MultiError is not nil")
+	}
+	m.Append(ErrArgs.WithDetail("first"))
+	m.Append(ErrConfig.WithDetail("second"))
+	err := m.ErrorOrNil()
+	if err == nil || m.Len() != 2 {
+		t.Fatalf("got %v", err)
+	}
+	if !errors.Is(err, ErrConfig) {
+		t.Error("errors.Is does not see the second element")
+	}
+	var codeErr CodeError
+	if !errors.As(err, &codeErr) || codeErr.Code() != ArgsError {
+		t.Errorf("errors.As = %v", codeErr)
+	}
+	if want := "1001 ArgsError first; 1008 ConfigError second"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
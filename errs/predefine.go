@@ -8,26 +8,79 @@ const (
 	DuplicateKeyError   = 1003
 	RecordNotFoundError = 1004 // Record does not exist
 
-	TokenExpiredError     = 1501
-	TokenInvalidError     = 1502
-	TokenMalformedError   = 1503
-	TokenNotValidYetError = 1504
-	TokenUnknownError     = 1505
-	TokenKickedError      = 1506
-	TokenNotExistError    = 1507
+	DependencyUnavailableError = 1005 // A backing service (database, cache, mq) cannot be reached
+	TimeoutError               = 1006 // A call to a backing service timed out
+	PartialFailureError        = 1007 // Every item of a batch request failed
+	ConfigError                = 1008 // Invalid configuration
+	ComponentStartError        = 1009 // A component failed to start
+	EndpointSunsetError        = 1010 // The endpoint passed its sunset date and is no longer served
+	CallerCancelledError       = 1011 // The caller cancelled the request, not a server failure
+	CallbackContinueError      = 1012 // The callback server asked to continue with the original data
+	CallbackRefusedError       = 1013 // The callback server refused the operation, see CallbackError
+	CallbackMalformedError     = 1014 // The callback server sent a response that is not a callback response
+
+	TokenExpiredError        = 1501
+	TokenInvalidError        = 1502
+	TokenMalformedError      = 1503
+	TokenNotValidYetError    = 1504
+	TokenUnknownError        = 1505
+	TokenKickedError         = 1506
+	TokenNotExistError       = 1507
+	TokenDeviceMismatchError = 1508 // The token is bound to another device, or the device is compromised
 )
 
 var (
-	ErrArgs             = NewCodeError(ArgsError, "ArgsError")
-	ErrNoPermission     = NewCodeError(NoPermissionError, "NoPermissionError")
-	ErrInternalServer   = NewCodeError(ServerInternalError, "ServerInternalError")
-	ErrRecordNotFound   = NewCodeError(RecordNotFoundError, "RecordNotFoundError")
-	ErrDuplicateKey     = NewCodeError(DuplicateKeyError, "DuplicateKeyError")
-	ErrTokenExpired     = NewCodeError(TokenExpiredError, "TokenExpiredError")
-	ErrTokenInvalid     = NewCodeError(TokenInvalidError, "TokenInvalidError")
-	ErrTokenMalformed   = NewCodeError(TokenMalformedError, "TokenMalformedError")
-	ErrTokenNotValidYet = NewCodeError(TokenNotValidYetError, "TokenNotValidYetError")
-	ErrTokenUnknown     = NewCodeError(TokenUnknownError, "TokenUnknownError")
-	ErrTokenKicked      = NewCodeError(TokenKickedError, "TokenKickedError")
-	ErrTokenNotExist    = NewCodeError(TokenNotExistError, "TokenNotExistError")
+	ErrArgs                  = NewSentinel(ArgsError, "ArgsError")
+	ErrNoPermission          = NewSentinel(NoPermissionError, "NoPermissionError")
+	ErrInternalServer        = NewSentinel(ServerInternalError, "ServerInternalError")
+	ErrRecordNotFound        = NewSentinel(RecordNotFoundError, "RecordNotFoundError")
+	ErrDuplicateKey          = NewSentinel(DuplicateKeyError, "DuplicateKeyError")
+	ErrDependencyUnavailable = NewSentinel(DependencyUnavailableError, "DependencyUnavailableError")
+	ErrTimeout               = NewSentinel(TimeoutError, "TimeoutError")
+	ErrPartialFailure        = NewSentinel(PartialFailureError, "PartialFailureError")
+	ErrConfig                = NewSentinel(ConfigError, "ConfigError")
+	ErrComponentStart        = NewSentinel(ComponentStartError, "ComponentStartError")
+	ErrEndpointSunset        = NewSentinel(EndpointSunsetError, "EndpointSunsetError")
+	ErrCallerCancelled       = NewSentinel(CallerCancelledError, "CallerCancelledError")
+	ErrCallbackContinue      = NewSentinel(CallbackContinueError, "CallbackContinueError")
+	ErrCallbackRefused       = NewSentinel(CallbackRefusedError, "CallbackRefusedError")
+	ErrCallbackMalformed     = NewSentinel(CallbackMalformedError, "CallbackMalformedError")
+	ErrTokenExpired          = NewSentinel(TokenExpiredError, "TokenExpiredError")
+	ErrTokenInvalid          = NewSentinel(TokenInvalidError, "TokenInvalidError")
+	ErrTokenMalformed        = NewSentinel(TokenMalformedError, "TokenMalformedError")
+	ErrTokenNotValidYet      = NewSentinel(TokenNotValidYetError, "TokenNotValidYetError")
+	ErrTokenUnknown          = NewSentinel(TokenUnknownError, "TokenUnknownError")
+	ErrTokenKicked           = NewSentinel(TokenKickedError, "TokenKickedError")
+	ErrTokenNotExist         = NewSentinel(TokenNotExistError, "TokenNotExistError")
+	ErrTokenDeviceMismatch   = NewSentinel(TokenDeviceMismatchError, "TokenDeviceMismatchError")
 )
+
+func init() {
+	_ = ReserveCodes("errs", 1000, 1099)
+	_ = ReserveCodes("errs", 1500, 1599)
+	RegisterMessages("en", map[int]string{
+		ArgsError:                  "The request arguments are invalid",
+		NoPermissionError:          "You do not have permission for this operation",
+		ServerInternalError:        "The server encountered an internal error",
+		RecordNotFoundError:        "The record does not exist",
+		DuplicateKeyError:          "The record already exists",
+		DependencyUnavailableError: "A service the server depends on is unavailable",
+		TimeoutError:               "The operation timed out",
+		PartialFailureError:        "Every item of the batch failed",
+		ConfigError:                "The server configuration is invalid",
+		ComponentStartError:        "A server component failed to start",
+		EndpointSunsetError:        "The endpoint is no longer served",
+		CallerCancelledError:       "The request was cancelled by the caller",
+		CallbackContinueError:      "The callback kept the original data",
+		CallbackRefusedError:       "The callback refused the operation",
+		CallbackMalformedError:     "The callback server returned an invalid response",
+		TokenExpiredError:          "The token has expired",
+		TokenInvalidError:          "The token is invalid",
+		TokenMalformedError:        "The token is malformed",
+		TokenNotValidYetError:      "The token is not valid yet",
+		TokenUnknownError:          "The token could not be verified",
+		TokenKickedError:           "The token was revoked by a newer login",
+		TokenNotExistError:         "The token does not exist",
+		TokenDeviceMismatchError:   "The token is bound to another device",
+	})
+}
@@ -0,0 +1,183 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errs
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// maxUnregisteredCodes bounds the ad-hoc codes remembered by NewCodeError,
+// codes relayed from remote services are arbitrary.
+const maxUnregisteredCodes = 256
+
+// CodeInfo documents an error code, as listed by RegisteredCodes.
+type CodeInfo struct {
+	Code     int
+	Name     string
+	Category string
+	Module   string            // owner of the reserved range holding Code, if any
+	Messages map[string]string // message templates by language
+}
+
+// CodeRange is a block of codes reserved by a module with ReserveCodes.
+type CodeRange struct {
+	Module string
+	Min    int
+	Max    int
+}
+
+var registry = struct {
+	lock         sync.RWMutex
+	names        map[int]string
+	messages     map[int]map[string]string
+	ranges       []CodeRange
+	unregistered map[int]string
+}{
+	names:        make(map[int]string),
+	messages:     make(map[int]map[string]string),
+	unregistered: make(map[int]string),
+}
+
+// knownCodes holds every code registered or noted, and unregisteredFull is set
+// once maxUnregisteredCodes are noted, so that NewCodeError takes no lock for
+// the codes it has already seen.
+var (
+	knownCodes       sync.Map
+	unregisteredFull atomic.Bool
+)
+
+// ReserveCodes reserves the codes from min to max, inclusive, for module. The
+// owning module is reported with the code in RegisteredCodes, and ranges may
+// not overlap.
+func ReserveCodes(module string, min, max int) error {
+	if module == "" || min > max {
+		return ErrArgs.WrapMsg("invalid code range", "module", module, "min", min, "max", max)
+	}
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	for _, r := range registry.ranges {
+		if min <= r.Max && r.Min <= max {
+			return ErrArgs.WrapMsg("code range overlaps a reserved range", "module", module, "min", min, "max", max, "owner", r.Module)
+		}
+	}
+	registry.ranges = append(registry.ranges, CodeRange{Module: module, Min: min, Max: max})
+	sort.Slice(registry.ranges, func(i, j int) bool { return registry.ranges[i].Min < registry.ranges[j].Min })
+	return nil
+}
+
+// CodeRanges returns the reserved ranges, ordered by their first code.
+func CodeRanges() []CodeRange {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	return append([]CodeRange(nil), registry.ranges...)
+}
+
+// CodeModule returns the module that reserved the range holding code, or "".
+func CodeModule(code int) string {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	return codeModule(code)
+}
+
+func codeModule(code int) string {
+	for _, r := range registry.ranges {
+		if r.Min <= code && code <= r.Max {
+			return r.Module
+		}
+	}
+	return ""
+}
+
+// RegisterMessages adds the message templates of lang, keyed by code, for
+// instance RegisterMessages("en", map[int]string{ArgsError: "..."}).
+func RegisterMessages(lang string, templates map[int]string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	for code, template := range templates {
+		m := registry.messages[code]
+		if m == nil {
+			m = make(map[string]string)
+			registry.messages[code] = m
+		}
+		m[lang] = template
+	}
+}
+
+// registerCode records the code of a sentinel created by NewSentinel.
+func registerCode(code int, name string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if _, ok := registry.names[code]; !ok {
+		registry.names[code] = name
+	}
+	delete(registry.unregistered, code)
+	knownCodes.Store(code, struct{}{})
+}
+
+// noteCode remembers the codes passed to NewCodeError that no sentinel
+// registered, with the first message seen for them.
+func noteCode(code int, msg string) {
+	if _, ok := knownCodes.Load(code); ok || unregisteredFull.Load() {
+		return
+	}
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if _, ok := registry.names[code]; ok {
+		return
+	}
+	if _, ok := registry.unregistered[code]; ok {
+		return
+	}
+	if len(registry.unregistered) >= maxUnregisteredCodes {
+		unregisteredFull.Store(true)
+		return
+	}
+	registry.unregistered[code] = msg
+	knownCodes.Store(code, struct{}{})
+}
+
+// RegisteredCodes returns the codes of all sentinels created by NewSentinel,
+// ordered by code.
+func RegisteredCodes() []CodeInfo {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	return codeInfos(registry.names)
+}
+
+// UnregisteredCodes returns the codes passed to NewCodeError that no sentinel
+// registered, ordered by code. Name holds the first message seen for the code.
+func UnregisteredCodes() []CodeInfo {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	return codeInfos(registry.unregistered)
+}
+
+func codeInfos(names map[int]string) []CodeInfo {
+	infos := make([]CodeInfo, 0, len(names))
+	for code, name := range names {
+		info := CodeInfo{Code: code, Name: name, Category: codeCategory(code), Module: codeModule(code)}
+		if m := registry.messages[code]; len(m) > 0 {
+			info.Messages = make(map[string]string, len(m))
+			for lang, template := range m {
+				info.Messages[lang] = template
+			}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Code < infos[j].Code })
+	return infos
+}
@@ -0,0 +1,37 @@
+package errs
+
+import "testing"
+
+func unregisteredName(code int) (string, bool) {
+	for _, info := range UnregisteredCodes() {
+		if info.Code == code {
+			return info.Name, true
+		}
+	}
+	return "", false
+}
+
+func TestUnregisteredCodes(t *testing.T) {
+	const code = 987650
+	NewCodeError(code, "first")
+	NewCodeError(code, "second")
+	if name, ok := unregisteredName(code); !ok || name != "first" {
+		t.Fatalf("unregistered code %d = %q, %v, want the first message", code, name, ok)
+	}
+	NewSentinel(code, "sentinel")
+	if _, ok := unregisteredName(code); ok {
+		t.Errorf("code %d still unregistered after NewSentinel", code)
+	}
+	NewCodeError(code, "again")
+	if _, ok := unregisteredName(code); ok {
+		t.Errorf("registered code %d noted again", code)
+	}
+}
+
+func BenchmarkNewCodeError(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = NewCodeError(ArgsError, "bench")
+		}
+	})
+}
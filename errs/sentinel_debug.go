@@ -0,0 +1,25 @@
+//go:build errs_debug
+
+package errs
+
+import (
+	"fmt"
+	"sync"
+)
+
+// sentinels maps every registered sentinel to a copy taken at registration.
+var sentinels sync.Map
+
+func registerSentinel(e *codeError) {
+	sentinels.Store(e, *e)
+}
+
+func checkSentinel(e *codeError) {
+	v, ok := sentinels.Load(e)
+	if !ok {
+		return
+	}
+	if orig := v.(codeError); orig != *e {
+		panic(fmt.Sprintf("errs: sentinel %d %q modified after registration: %q -> %q", orig.code, orig.msg, orig.Error(), e.Error()))
+	}
+}
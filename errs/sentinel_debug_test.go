@@ -0,0 +1,29 @@
+//go:build errs_debug
+
+package errs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSentinelMutationPanics(t *testing.T) {
+	e := NewSentinel(99999, "TestSentinel").(*codeError)
+	e.detail = "leaked"
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("mutated sentinel did not panic")
+		}
+		if s, _ := r.(string); !strings.Contains(s, "TestSentinel") {
+			t.Fatalf("unexpected panic %v", r)
+		}
+	}()
+	_ = e.WrapMsg("boom")
+}
+
+func TestNewCodeErrorNotRegistered(t *testing.T) {
+	e := NewCodeError(99998, "AdHoc").(*codeError)
+	e.detail = "changed"
+	_ = e.Wrap()
+}
@@ -1,6 +1,7 @@
 package stack
 
 import (
+	"encoding/json"
 	"errors"
 	"path"
 	"runtime"
@@ -35,6 +36,12 @@ func (e *stackError) Cause() error {
 	return e.err
 }
 
+// Callers returns the program counters recorded when the error was wrapped,
+// innermost call first.
+func (e *stackError) Callers() []uintptr {
+	return e.stack
+}
+
 func (e *stackError) Is(err error) bool {
 	if e == nil && err == nil {
 		return true
@@ -48,6 +55,18 @@ func (e *stackError) Is(err error) bool {
 	return errors.Is(e.err, err)
 }
 
+// MarshalJSON encodes the wrapped error without the stack, errs.ToJSON
+// includes it on request.
+func (e *stackError) MarshalJSON() ([]byte, error) {
+	if m, ok := e.err.(json.Marshaler); ok {
+		return m.MarshalJSON()
+	}
+	return json.Marshal(struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}{Msg: e.err.Error()})
+}
+
 func (e *stackError) Error() string {
 	if len(e.stack) == 0 {
 		return e.err.Error()
@@ -0,0 +1,49 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errs
+
+import "errors"
+
+// StreamAbortError reports a server stream that failed after sending part of
+// its messages. ResumeToken tells the client where to resume, e.g. the last
+// sequence it received.
+type StreamAbortError struct {
+	CodeError
+	ResumeToken string
+}
+
+// StreamAbort returns the error a streaming handler returns when it fails
+// mid-stream. The streaming interceptors of the mw package carry the resume
+// token to the client, where ParseStreamAbort recovers it.
+func StreamAbort(code int, msg string, resumeToken string) error {
+	return &StreamAbortError{CodeError: NewCodeError(code, msg), ResumeToken: resumeToken}
+}
+
+func (e *StreamAbortError) Error() string {
+	return e.CodeError.Error() + " resumeToken=" + e.ResumeToken
+}
+
+func (e *StreamAbortError) Unwrap() error {
+	return e.CodeError
+}
+
+// ParseStreamAbort returns the StreamAbortError in err's chain, if any.
+func ParseStreamAbort(err error) (*StreamAbortError, bool) {
+	var abort *StreamAbortError
+	if errors.As(err, &abort) {
+		return abort, true
+	}
+	return nil, false
+}
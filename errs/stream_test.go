@@ -0,0 +1,21 @@
+package errs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStreamAbort(t *testing.T) {
+	err := WrapMsg(StreamAbort(ServerInternalError, "db down", "seq:42"), "sync failed")
+	abort, ok := ParseStreamAbort(err)
+	if !ok || abort.ResumeToken != "seq:42" || abort.Code() != ServerInternalError {
+		t.Fatalf("ParseStreamAbort = %+v, %v", abort, ok)
+	}
+	var codeErr CodeError
+	if !errors.As(err, &codeErr) || codeErr.Msg() != "db down" {
+		t.Fatalf("stream abort is not a CodeError: %v", err)
+	}
+	if _, ok := ParseStreamAbort(ErrArgs.WrapMsg("bad")); ok {
+		t.Fatal("ParseStreamAbort matched a plain error")
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// DefaultMaxWarnings is the number of distinct warnings a request keeps when
+// NewWarnings is given no limit.
+const DefaultMaxWarnings = 16
+
+// WarningsKey is the context key of the Warnings of a request. It is a string
+// so that gin handlers can attach them with gin.Context.Set.
+const WarningsKey = "errsWarnings"
+
+// Warning is a non-fatal issue of an operation that succeeded, such as a
+// message sent without its offline push. The fields follow the error fields
+// of the API envelope.
+type Warning struct {
+	ErrCode int    `json:"errCode"`
+	ErrMsg  string `json:"errMsg"`
+	ErrDlt  string `json:"errDlt,omitempty"`
+	// Count is the number of times the warning was added.
+	Count int `json:"count"`
+	// Fingerprint deduplicates the warning, see Fingerprint.
+	Fingerprint string `json:"-"`
+}
+
+// NewWarning describes err as a warning.
+func NewWarning(err error) Warning {
+	w := Warning{Count: 1, Fingerprint: Fingerprint(err)}
+	var codeErr CodeError
+	if errors.As(err, &codeErr) {
+		w.ErrCode, w.ErrMsg, w.ErrDlt = codeErr.Code(), codeErr.Msg(), codeErr.Detail()
+	} else {
+		w.ErrCode, w.ErrMsg = ServerInternalError, Unwrap(err).Error()
+	}
+	// The message of WrapMsg tells what the warning is about, without the
+	// stack of Error.
+	var wrapper *errorWrapper
+	if w.ErrDlt == "" && errors.As(err, &wrapper) {
+		w.ErrDlt = wrapper.s
+	}
+	return w
+}
+
+// Warnings accumulates the warnings of a request, deduplicated by
+// fingerprint. Once it holds its maximum, new warnings are dropped and only
+// counted. It is safe for concurrent use, and a nil *Warnings discards
+// everything.
+type Warnings struct {
+	mu      sync.Mutex
+	max     int
+	list    []Warning
+	index   map[string]int
+	dropped int
+}
+
+// NewWarnings returns an accumulator keeping at most max distinct warnings,
+// DefaultMaxWarnings if max is not positive.
+func NewWarnings(max int) *Warnings {
+	if max <= 0 {
+		max = DefaultMaxWarnings
+	}
+	return &Warnings{max: max, index: make(map[string]int)}
+}
+
+// Add adds err as a warning. It does nothing if err is nil.
+func (w *Warnings) Add(err error) {
+	if w == nil || err == nil {
+		return
+	}
+	w.Append(NewWarning(err))
+}
+
+// Append adds warnings produced elsewhere, such as by a downstream RPC,
+// merging their counts with the known fingerprints.
+func (w *Warnings) Append(warnings ...Warning) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, warning := range warnings {
+		if warning.Count <= 0 {
+			warning.Count = 1
+		}
+		if warning.Fingerprint == "" {
+			warning.Fingerprint = warningKey(warning)
+		}
+		if i, ok := w.index[warning.Fingerprint]; ok {
+			w.list[i].Count += warning.Count
+			continue
+		}
+		if len(w.list) >= w.max {
+			w.dropped++
+			continue
+		}
+		w.index[warning.Fingerprint] = len(w.list)
+		w.list = append(w.list, warning)
+	}
+}
+
+// List returns a copy of the warnings, in the order they were first added.
+func (w *Warnings) List() []Warning {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.list) == 0 {
+		return nil
+	}
+	return append([]Warning(nil), w.list...)
+}
+
+// Dropped returns the number of distinct warnings dropped over the maximum.
+func (w *Warnings) Dropped() int {
+	if w == nil {
+		return 0
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dropped
+}
+
+// warningKey deduplicates the warnings received without a fingerprint.
+func warningKey(w Warning) string {
+	return strconv.Itoa(w.ErrCode) + ":" + messageTemplate(w.ErrMsg)
+}
+
+// WithWarnings attaches w to ctx, see AddWarning.
+func WithWarnings(ctx context.Context, w *Warnings) context.Context {
+	return context.WithValue(ctx, WarningsKey, w)
+}
+
+// GetWarnings returns the accumulator attached to ctx, or nil.
+func GetWarnings(ctx context.Context) *Warnings {
+	w, _ := ctx.Value(WarningsKey).(*Warnings)
+	return w
+}
+
+// AddWarning records err as a warning of the request of ctx, to be returned
+// alongside its successful result. It does nothing when no accumulator is
+// attached to ctx.
+func AddWarning(ctx context.Context, err error) {
+	GetWarnings(ctx).Add(err)
+}
+
+// WarningsFrom returns the warnings recorded in ctx.
+func WarningsFrom(ctx context.Context) []Warning {
+	return GetWarnings(ctx).List()
+}
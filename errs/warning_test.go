@@ -0,0 +1,76 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestWarnings(t *testing.T) {
+	w := NewWarnings(2)
+	ctx := WithWarnings(context.Background(), w)
+	AddWarning(ctx, notFound(1))
+	AddWarning(ctx, notFound(2))
+	AddWarning(ctx, errors.New("offline push skipped"))
+	AddWarning(ctx, timedOut(5))
+	AddWarning(ctx, nil)
+
+	list := WarningsFrom(ctx)
+	if len(list) != 2 {
+		t.Fatalf("warnings = %+v, want 2", list)
+	}
+	if list[0].ErrCode != RecordNotFoundError || list[0].ErrDlt != "user 1" || list[0].Count != 2 {
+		t.Errorf("first warning = %+v, want the not found one twice", list[0])
+	}
+	if list[1].ErrCode != ServerInternalError || list[1].ErrMsg != "offline push skipped" {
+		t.Errorf("second warning = %+v", list[1])
+	}
+	if w.Dropped() != 1 {
+		t.Errorf("dropped = %d, want 1", w.Dropped())
+	}
+
+	w.Append(Warning{ErrCode: list[1].ErrCode, ErrMsg: list[1].ErrMsg, Count: 3, Fingerprint: list[1].Fingerprint})
+	if got := w.List()[1].Count; got != 4 {
+		t.Errorf("count after append = %d, want 4", got)
+	}
+}
+
+func TestWarningsWithoutAccumulator(t *testing.T) {
+	ctx := context.Background()
+	AddWarning(ctx, notFound(1))
+	if list := WarningsFrom(ctx); list != nil {
+		t.Fatalf("warnings = %+v, want none", list)
+	}
+	var w *Warnings
+	w.Append(Warning{ErrMsg: "ignored"})
+	if w.List() != nil || w.Dropped() != 0 {
+		t.Fatal("nil Warnings kept a warning")
+	}
+}
+
+func TestWarningsDeduplicateWithoutFingerprint(t *testing.T) {
+	w := NewWarnings(0)
+	w.Append(Warning{ErrCode: 1, ErrMsg: "user 12 offline"}, Warning{ErrCode: 1, ErrMsg: "user 13 offline"})
+	if list := w.List(); len(list) != 1 || list[0].Count != 2 {
+		t.Fatalf("warnings = %+v, want one counted twice", list)
+	}
+}
+
+func TestWarningsConcurrent(t *testing.T) {
+	w := NewWarnings(0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				w.Add(notFound(j))
+			}
+		}()
+	}
+	wg.Wait()
+	if list := w.List(); len(list) != 1 || list[0].Count != 800 {
+		t.Fatalf("warnings = %+v, want one counted 800 times", list)
+	}
+}
@@ -0,0 +1,281 @@
+package log
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+const (
+	defaultAggregatorTopK     = 5
+	defaultAggregatorCapacity = 1024
+	maxTemplateLen            = 128
+)
+
+// aggregator is the ErrorAggregator fed by every ZapLogger.Error call, if any.
+var aggregator atomic.Pointer[ErrorAggregator]
+
+// ErrorCount is the number of Error-level entries seen for one code and message
+// template.
+type ErrorCount struct {
+	Code     int    `json:"code"`
+	Template string `json:"template"`
+	Count    uint64 `json:"count"`
+}
+
+// ErrorAggregatorStats holds the counters of an ErrorAggregator since it was
+// created.
+type ErrorAggregatorStats struct {
+	// Total counts every recorded entry, including those of evicted templates.
+	Total uint64 `json:"total"`
+	// Evicted counts the templates dropped to keep memory bounded.
+	Evicted uint64 `json:"evicted"`
+	// Counts lists the tracked templates, most frequent first.
+	Counts []ErrorCount `json:"counts"`
+}
+
+type aggregatorKey struct {
+	code     int
+	template string
+}
+
+type aggregatorEntry struct {
+	key    aggregatorKey
+	window uint64
+	total  uint64
+}
+
+// ErrorAggregator counts Error-level log entries by errs code and message
+// template and periodically logs a single summary of the most frequent ones. At
+// most capacity templates are tracked; the least recently seen one is evicted
+// when a new template arrives.
+type ErrorAggregator struct {
+	lock        sync.Mutex
+	topK        int
+	capacity    int
+	entries     map[aggregatorKey]*list.Element
+	lru         *list.List
+	windowTotal uint64
+	total       uint64
+	evicted     uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type AggregatorOption func(a *ErrorAggregator)
+
+// WithAggregatorTopK sets how many templates are listed in each summary. The
+// default is 5.
+func WithAggregatorTopK(k int) AggregatorOption {
+	return func(a *ErrorAggregator) {
+		if k > 0 {
+			a.topK = k
+		}
+	}
+}
+
+// WithAggregatorCapacity sets the maximum number of distinct templates kept in
+// memory. The default is 1024.
+func WithAggregatorCapacity(n int) AggregatorOption {
+	return func(a *ErrorAggregator) {
+		if n > 0 {
+			a.capacity = n
+		}
+	}
+}
+
+// NewErrorAggregator returns an aggregator that is not attached to the logger.
+// Use EnableErrorAggregation for the usual setup.
+func NewErrorAggregator(opts ...AggregatorOption) *ErrorAggregator {
+	a := &ErrorAggregator{
+		topK:     defaultAggregatorTopK,
+		capacity: defaultAggregatorCapacity,
+		entries:  make(map[aggregatorKey]*list.Element),
+		lru:      list.New(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// EnableErrorAggregation starts counting the entries logged at Error level and
+// logs a summary every interval, such as
+// "last 1m0s: 1240 errors, top: code=1004 x900 (token expired), ...".
+// It replaces a previously enabled aggregator. Call Stop on the result to
+// disable it.
+func EnableErrorAggregation(interval time.Duration, opts ...AggregatorOption) *ErrorAggregator {
+	a := NewErrorAggregator(opts...)
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	if old := aggregator.Swap(a); old != nil {
+		old.Stop()
+	}
+	go a.run(interval)
+	return a
+}
+
+func (a *ErrorAggregator) run(interval time.Duration) {
+	defer close(a.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.emit(interval)
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// Stop detaches the aggregator from the logger and stops the periodic summary.
+func (a *ErrorAggregator) Stop() {
+	aggregator.CompareAndSwap(a, nil)
+	if a.stop == nil {
+		return
+	}
+	select {
+	case <-a.stop:
+	default:
+		close(a.stop)
+	}
+	<-a.done
+}
+
+// Record counts one Error-level entry logged with msg and err.
+func (a *ErrorAggregator) Record(msg string, err error) {
+	key := aggregatorKey{template: errorTemplate(msg)}
+	if codeErr, ok := errs.Unwrap(err).(errs.CodeError); ok {
+		key.code = codeErr.Code()
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.total++
+	a.windowTotal++
+	if elem, ok := a.entries[key]; ok {
+		e := elem.Value.(*aggregatorEntry)
+		e.window++
+		e.total++
+		a.lru.MoveToFront(elem)
+		return
+	}
+	if a.lru.Len() >= a.capacity {
+		oldest := a.lru.Back()
+		delete(a.entries, oldest.Value.(*aggregatorEntry).key)
+		a.lru.Remove(oldest)
+		a.evicted++
+	}
+	a.entries[key] = a.lru.PushFront(&aggregatorEntry{key: key, window: 1, total: 1})
+}
+
+// Stats returns the counters accumulated since the aggregator was created.
+func (a *ErrorAggregator) Stats() ErrorAggregatorStats {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	stats := ErrorAggregatorStats{Total: a.total, Evicted: a.evicted, Counts: make([]ErrorCount, 0, a.lru.Len())}
+	for elem := a.lru.Front(); elem != nil; elem = elem.Next() {
+		e := elem.Value.(*aggregatorEntry)
+		stats.Counts = append(stats.Counts, ErrorCount{Code: e.key.code, Template: e.key.template, Count: e.total})
+	}
+	sortCounts(stats.Counts)
+	return stats
+}
+
+// flush returns the counts of the current window, most frequent first, and
+// starts a new window.
+func (a *ErrorAggregator) flush() (uint64, []ErrorCount) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	total := a.windowTotal
+	a.windowTotal = 0
+	var counts []ErrorCount
+	for elem := a.lru.Front(); elem != nil; elem = elem.Next() {
+		e := elem.Value.(*aggregatorEntry)
+		if e.window > 0 {
+			counts = append(counts, ErrorCount{Code: e.key.code, Template: e.key.template, Count: e.window})
+			e.window = 0
+		}
+	}
+	sortCounts(counts)
+	if len(counts) > a.topK {
+		counts = counts[:a.topK]
+	}
+	return total, counts
+}
+
+// emit logs the summary of the current window, if it recorded any error.
+func (a *ErrorAggregator) emit(window time.Duration) {
+	total, top := a.flush()
+	if total == 0 {
+		return
+	}
+	ZInfo(context.Background(), "error summary", "window", window.String(), "errors", total,
+		"summary", formatSummary(window, total, top), "top", top)
+}
+
+func formatSummary(window time.Duration, total uint64, top []ErrorCount) string {
+	var b strings.Builder
+	b.WriteString("last ")
+	b.WriteString(window.String())
+	b.WriteString(": ")
+	b.WriteString(strconv.FormatUint(total, 10))
+	b.WriteString(" errors, top: ")
+	for i, c := range top {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("code=")
+		b.WriteString(strconv.Itoa(c.Code))
+		b.WriteString(" x")
+		b.WriteString(strconv.FormatUint(c.Count, 10))
+		b.WriteString(" (")
+		b.WriteString(c.Template)
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+func sortCounts(counts []ErrorCount) {
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		if counts[i].Code != counts[j].Code {
+			return counts[i].Code < counts[j].Code
+		}
+		return counts[i].Template < counts[j].Template
+	})
+}
+
+// errorTemplate reduces msg to a template by replacing digit runs with '#' and
+// truncating it, so messages that embed IDs or counts do not each become a
+// template of their own.
+func errorTemplate(msg string) string {
+	var b strings.Builder
+	inDigits := false
+	for _, r := range msg {
+		if b.Len() >= maxTemplateLen {
+			break
+		}
+		if unicode.IsDigit(r) {
+			if !inDigits {
+				b.WriteByte('#')
+			}
+			inDigits = true
+			continue
+		}
+		inDigits = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
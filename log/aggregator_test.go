@@ -0,0 +1,84 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+func TestErrorAggregatorSummary(t *testing.T) {
+	a := NewErrorAggregator(WithAggregatorTopK(2))
+	for i := 0; i < 900; i++ {
+		a.Record("token expired", errs.ErrRecordNotFound.WrapMsg("user"))
+	}
+	for i := 0; i < 300; i++ {
+		a.Record(fmt.Sprintf("redis timeout after %dms", i), errs.NewCodeError(6000, "RedisError"))
+	}
+	for i := 0; i < 40; i++ {
+		a.Record("plain", errors.New("boom"))
+	}
+
+	total, top := a.flush()
+	got := formatSummary(time.Minute, total, top)
+	want := "last 1m0s: 1240 errors, top: code=1004 x900 (token expired), code=6000 x300 (redis timeout after #ms)"
+	if got != want {
+		t.Fatalf("summary\n got %q\nwant %q", got, want)
+	}
+
+	if total, top := a.flush(); total != 0 || len(top) != 0 {
+		t.Fatalf("window not reset: %d %v", total, top)
+	}
+	stats := a.Stats()
+	if stats.Total != 1240 || len(stats.Counts) != 3 || stats.Counts[2] != (ErrorCount{Code: 0, Template: "plain", Count: 40}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestErrorAggregatorBounded(t *testing.T) {
+	const capacity = 16
+	a := NewErrorAggregator(WithAggregatorCapacity(capacity))
+	for i := 0; i < 10000; i++ {
+		// A hot template seen between every distinct one must survive eviction.
+		a.Record("hot", errs.ErrInternalServer)
+		a.Record("distinct "+string(rune('a'+i%26))+string(rune('a'+i/26%26))+string(rune('a'+i/676%26)), nil)
+	}
+	stats := a.Stats()
+	if len(stats.Counts) != capacity {
+		t.Fatalf("tracked %d templates, want %d", len(stats.Counts), capacity)
+	}
+	if stats.Counts[0].Template != "hot" || stats.Counts[0].Count != 10000 {
+		t.Fatalf("hot template lost: %+v", stats.Counts[0])
+	}
+	if stats.Total != 20000 || stats.Evicted == 0 {
+		t.Fatalf("unexpected totals %+v", stats)
+	}
+}
+
+func TestEnableErrorAggregation(t *testing.T) {
+	logs := observeLogger(t)
+	a := EnableErrorAggregation(20 * time.Millisecond)
+	ZError(context.Background(), "token expired", errs.ErrTokenExpired.Wrap())
+	ZError(context.Background(), "token expired", errs.ErrTokenExpired.Wrap())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.FilterMessage("error summary").Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no summary logged")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	a.Stop()
+	entry := logs.FilterMessage("error summary").All()[0]
+	if summary := entry.ContextMap()["summary"]; summary != "last 20ms: 2 errors, top: code=1501 x2 (token expired)" {
+		t.Fatalf("unexpected summary %v", summary)
+	}
+
+	ZError(context.Background(), "after stop", nil)
+	if a.Stats().Total != 2 {
+		t.Fatalf("stopped aggregator still counting: %+v", a.Stats())
+	}
+}
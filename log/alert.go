@@ -0,0 +1,218 @@
+package log
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+const (
+	defaultAlertWindow   = time.Minute
+	defaultAlertCooldown = 5 * time.Minute
+	defaultAlertCapacity = 1024
+)
+
+// Alert reports the occurrences of one failure, identified by its
+// errs.Fingerprint, during an alert window, or that it went quiet.
+type Alert struct {
+	Fingerprint string `json:"fingerprint"`
+	Code        int    `json:"code"`
+	// Msg and Err are those of the first occurrence.
+	Msg string `json:"msg"`
+	Err string `json:"err,omitempty"`
+	// Count is the number of occurrences since the previous alert for the
+	// fingerprint, Total since the first one.
+	Count     uint64    `json:"count"`
+	Total     uint64    `json:"total"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	// Resolved is set on the notice sent once the fingerprint was not seen
+	// for the cool-down period. It carries no new occurrences.
+	Resolved bool `json:"resolved"`
+}
+
+type alertGroup struct {
+	alert   Alert
+	pending uint64
+}
+
+// AlertGrouper turns Error-level entries into alerts grouped by
+// errs.Fingerprint: at most one alert per fingerprint and window, counting the
+// occurrences, and a resolution notice when the fingerprint goes quiet. It
+// feeds a sender such as a chat webhook, which is not called on the logging
+// goroutine.
+type AlertGrouper struct {
+	lock     sync.Mutex
+	window   time.Duration
+	cooldown time.Duration
+	capacity int
+	send     func(Alert)
+	now      func() time.Time
+	groups   map[string]*alertGroup
+	dropped  uint64
+
+	removeHook func()
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+type AlertOption func(g *AlertGrouper)
+
+// WithAlertWindow sets how long occurrences are grouped before an alert is
+// sent. The default is 1m.
+func WithAlertWindow(d time.Duration) AlertOption {
+	return func(g *AlertGrouper) {
+		if d > 0 {
+			g.window = d
+		}
+	}
+}
+
+// WithAlertCooldown sets how long a fingerprint must be quiet before its
+// resolution notice is sent. The default is 5m.
+func WithAlertCooldown(d time.Duration) AlertOption {
+	return func(g *AlertGrouper) {
+		if d > 0 {
+			g.cooldown = d
+		}
+	}
+}
+
+// WithAlertCapacity sets the maximum number of fingerprints tracked at once.
+// Occurrences of new fingerprints beyond it are dropped. The default is 1024.
+func WithAlertCapacity(n int) AlertOption {
+	return func(g *AlertGrouper) {
+		if n > 0 {
+			g.capacity = n
+		}
+	}
+}
+
+// NewAlertGrouper returns a grouper that is not attached to the logger. Use
+// EnableAlertGrouping for the usual setup.
+func NewAlertGrouper(send func(Alert), opts ...AlertOption) *AlertGrouper {
+	g := &AlertGrouper{
+		window:   defaultAlertWindow,
+		cooldown: defaultAlertCooldown,
+		capacity: defaultAlertCapacity,
+		send:     send,
+		now:      time.Now,
+		groups:   make(map[string]*alertGroup),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// EnableAlertGrouping registers an ErrorHook that groups the entries logged
+// at Error level and sends the alerts to send at the end of every window.
+// Call Stop on the result to disable it.
+func EnableAlertGrouping(send func(Alert), opts ...AlertOption) *AlertGrouper {
+	g := NewAlertGrouper(send, opts...)
+	g.stop = make(chan struct{})
+	g.done = make(chan struct{})
+	g.removeHook = AddErrorHook(func(_ context.Context, msg string, err error) {
+		g.Record(msg, err)
+	})
+	go g.run()
+	return g
+}
+
+func (g *AlertGrouper) run() {
+	defer close(g.done)
+	ticker := time.NewTicker(g.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.tick(g.now())
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// Stop removes the hook and sends the occurrences of the current window.
+func (g *AlertGrouper) Stop() {
+	if g.stop == nil {
+		return
+	}
+	g.removeHook()
+	select {
+	case <-g.stop:
+		return
+	default:
+		close(g.stop)
+	}
+	<-g.done
+	g.tick(g.now())
+}
+
+// Record counts one Error-level entry logged with msg and err.
+func (g *AlertGrouper) Record(msg string, err error) {
+	fingerprint := errs.Fingerprint(err)
+	if err == nil {
+		fingerprint = "msg:" + errorTemplate(msg)
+	}
+	now := g.now()
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	group, ok := g.groups[fingerprint]
+	if !ok {
+		if len(g.groups) >= g.capacity {
+			g.dropped++
+			return
+		}
+		group = &alertGroup{alert: Alert{Fingerprint: fingerprint, Code: errs.Code(err), Msg: msg, FirstSeen: now}}
+		if err != nil {
+			group.alert.Err = errs.Unwrap(err).Error()
+		}
+		g.groups[fingerprint] = group
+	}
+	group.pending++
+	group.alert.Total++
+	group.alert.LastSeen = now
+}
+
+// Dropped returns the number of occurrences dropped because too many
+// fingerprints were tracked.
+func (g *AlertGrouper) Dropped() uint64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.dropped
+}
+
+// tick ends the current window: it sends an alert for every fingerprint seen
+// during it, and a resolution notice for those quiet for the cool-down period.
+func (g *AlertGrouper) tick(now time.Time) {
+	var alerts []Alert
+	g.lock.Lock()
+	for fingerprint, group := range g.groups {
+		switch {
+		case group.pending > 0:
+			alert := group.alert
+			alert.Count = group.pending
+			group.pending = 0
+			alerts = append(alerts, alert)
+		case now.Sub(group.alert.LastSeen) >= g.cooldown:
+			alert := group.alert
+			alert.Resolved = true
+			alerts = append(alerts, alert)
+			delete(g.groups, fingerprint)
+		}
+	}
+	g.lock.Unlock()
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].FirstSeen.Equal(alerts[j].FirstSeen) {
+			return alerts[i].FirstSeen.Before(alerts[j].FirstSeen)
+		}
+		return alerts[i].Fingerprint < alerts[j].Fingerprint
+	})
+	for _, alert := range alerts {
+		g.send(alert)
+	}
+}
@@ -0,0 +1,129 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+func dbFailure(shard int) error {
+	return errs.ErrDependencyUnavailable.WrapMsg(fmt.Sprintf("shard %d down", shard))
+}
+
+func tokenFailure() error {
+	return errs.ErrTokenInvalid.WrapMsg("bad signature")
+}
+
+type alertRecorder struct {
+	lock   sync.Mutex
+	alerts []Alert
+}
+
+func (r *alertRecorder) send(a Alert) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.alerts = append(r.alerts, a)
+}
+
+func (r *alertRecorder) take() []Alert {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	alerts := r.alerts
+	r.alerts = nil
+	return alerts
+}
+
+func TestAlertGrouping(t *testing.T) {
+	var rec alertRecorder
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	g := NewAlertGrouper(rec.send, WithAlertCooldown(3*time.Minute))
+	g.now = func() time.Time { return now }
+
+	for i := 0; i < 500; i++ {
+		now = start.Add(time.Duration(i) * 100 * time.Millisecond)
+		g.Record("query failed", dbFailure(i))
+	}
+	g.Record("auth failed", tokenFailure())
+	g.Record("auth failed", tokenFailure())
+
+	g.tick(start.Add(time.Minute))
+	alerts := rec.take()
+	if len(alerts) != 2 {
+		t.Fatalf("sent %d alerts, want one per fingerprint: %+v", len(alerts), alerts)
+	}
+	db, auth := alerts[0], alerts[1]
+	if db.Count != 500 || db.Code != errs.DependencyUnavailableError || db.Msg != "query failed" || db.Resolved ||
+		!db.FirstSeen.Equal(start) || !db.LastSeen.Equal(start.Add(49900*time.Millisecond)) {
+		t.Errorf("db alert = %+v", db)
+	}
+	if auth.Count != 2 || auth.Code != errs.TokenInvalidError || auth.Fingerprint == db.Fingerprint {
+		t.Errorf("auth alert = %+v", auth)
+	}
+
+	// The database keeps failing, the token errors stop.
+	now = start.Add(90 * time.Second)
+	g.Record("query failed", dbFailure(7))
+	g.tick(start.Add(2 * time.Minute))
+	alerts = rec.take()
+	if len(alerts) != 1 || alerts[0].Fingerprint != db.Fingerprint || alerts[0].Count != 1 || alerts[0].Total != 501 ||
+		!alerts[0].FirstSeen.Equal(start) {
+		t.Fatalf("second window = %+v", alerts)
+	}
+
+	// Within the cool-down nothing is sent, then each fingerprint resolves
+	// once.
+	g.tick(start.Add(3 * time.Minute))
+	if alerts := rec.take(); len(alerts) != 0 {
+		t.Fatalf("quiet window sent %+v", alerts)
+	}
+	g.tick(start.Add(4 * time.Minute))
+	alerts = rec.take()
+	if len(alerts) != 1 || alerts[0].Fingerprint != auth.Fingerprint || !alerts[0].Resolved || alerts[0].Total != 2 {
+		t.Fatalf("auth resolution = %+v", alerts)
+	}
+	g.tick(start.Add(5 * time.Minute))
+	alerts = rec.take()
+	if len(alerts) != 1 || alerts[0].Fingerprint != db.Fingerprint || !alerts[0].Resolved {
+		t.Fatalf("db resolution = %+v", alerts)
+	}
+	g.tick(start.Add(10 * time.Minute))
+	if alerts := rec.take(); len(alerts) != 0 {
+		t.Fatalf("resolved twice: %+v", alerts)
+	}
+
+	// A failure coming back is a new incident.
+	now = start.Add(11 * time.Minute)
+	g.Record("auth failed", tokenFailure())
+	g.tick(start.Add(12 * time.Minute))
+	if alerts := rec.take(); len(alerts) != 1 || alerts[0].Total != 1 || !alerts[0].FirstSeen.Equal(now) {
+		t.Fatalf("new incident = %+v", alerts)
+	}
+}
+
+func TestAlertGroupingCapacity(t *testing.T) {
+	var rec alertRecorder
+	g := NewAlertGrouper(rec.send, WithAlertCapacity(1))
+	g.Record("a", tokenFailure())
+	g.Record("b", dbFailure(1))
+	g.tick(time.Now())
+	if alerts := rec.take(); len(alerts) != 1 || g.Dropped() != 1 {
+		t.Fatalf("alerts = %+v, dropped = %d", alerts, g.Dropped())
+	}
+}
+
+func TestEnableAlertGrouping(t *testing.T) {
+	var rec alertRecorder
+	g := EnableAlertGrouping(rec.send, WithAlertWindow(time.Hour))
+	runErrorHooks(context.Background(), "query failed", dbFailure(1))
+	runErrorHooks(context.Background(), "query failed", dbFailure(2))
+	g.Stop()
+	runErrorHooks(context.Background(), "query failed", dbFailure(3))
+	if alerts := rec.take(); len(alerts) != 1 || alerts[0].Count != 2 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+}
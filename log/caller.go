@@ -0,0 +1,52 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// Caller attribution: loggers built by NewZapLogger and NewConsoleZapLogger
+// report the caller of their methods, and the package functions such as ZInfo
+// add their own frame through callDepth. Helpers that log on behalf of their
+// caller add theirs with WithCallerSkip or Logger.WithCallDepth.
+
+// WithCallerSkip returns the package logger, with entries reporting the caller
+// n frames above the function calling its methods, for helpers that would
+// otherwise show up as the origin of every entry:
+//
+//	func logFor(ctx context.Context, msg string) {
+//		log.WithCallerSkip(1).Info(ctx, msg)
+//	}
+//
+// The skip applies to that logger only, not to the calls ctx is passed on to.
+func WithCallerSkip(n int) Logger {
+	return getPkgLogger().WithCallDepth(n - callDepth)
+}
+
+var callerFunction atomic.Bool
+
+// SetCallerFunction adds the name of the calling function to every entry, under
+// the "func" key, in loggers created afterwards.
+func SetCallerFunction(enabled bool) {
+	callerFunction.Store(enabled)
+}
+
+// callerOptions makes a zap logger report the caller of the ZapLogger method.
+func callerOptions() []zap.Option {
+	return []zap.Option{zap.AddCaller(), zap.AddCallerSkip(1)}
+}
@@ -0,0 +1,122 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// observeCallers installs a package logger built like NewZapLogger's that
+// records entries with their caller.
+func observeCallers(t *testing.T) (*ZapLogger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := &ZapLogger{zap: zap.New(core, callerOptions()...).Sugar(), level: zapcore.DebugLevel}
+	old := getPkgLogger()
+	setPkgLogger(l.WithCallDepth(callDepth))
+	t.Cleanup(func() { setPkgLogger(old) })
+	return l, logs
+}
+
+// line returns the line following its call.
+func line() int {
+	_, _, l, _ := runtime.Caller(1)
+	return l + 1
+}
+
+func assertCaller(t *testing.T, logs *observer.ObservedLogs, msg string, wantLine int) {
+	t.Helper()
+	entries := logs.FilterMessage(msg).All()
+	if len(entries) != 1 {
+		t.Fatalf("%q logged %d times", msg, len(entries))
+	}
+	caller := entries[0].Caller
+	if filepath.Base(caller.File) != "caller_test.go" || caller.Line != wantLine {
+		t.Errorf("%q reported at %s:%d, want caller_test.go:%d", msg, caller.File, caller.Line, wantLine)
+	}
+}
+
+// logFor is a user-built wrapper that logs on behalf of its caller.
+func logFor(ctx context.Context, msg string) {
+	WithCallerSkip(1).Info(ctx, msg)
+}
+
+func TestCaller(t *testing.T) {
+	l, logs := observeCallers(t)
+	ctx := context.Background()
+
+	want := line()
+	ZInfo(ctx, "zinfo")
+	assertCaller(t, logs, "zinfo", want)
+
+	want = line()
+	ZAdaptive(ctx, "zadaptive", nil)
+	assertCaller(t, logs, "zadaptive", want)
+
+	want = line()
+	l.Error(ctx, "method", nil)
+	assertCaller(t, logs, "method", want)
+
+	want = line()
+	logFor(ctx, "wrapper")
+	assertCaller(t, logs, "wrapper", want)
+
+	done := make(chan int)
+	SafeGo(ctx, "test", func(ctx context.Context) {
+		want := line()
+		ZError(ctx, "inside safego", nil)
+		done <- want
+	})
+	assertCaller(t, logs, "inside safego", <-done)
+
+	SafeGo(ctx, "test", func(ctx context.Context) {
+		var m map[string]int
+		done <- line()
+		m["x"] = 1
+	})
+	want = <-done
+	deadline := time.Now().Add(time.Second)
+	for logs.FilterMessage("goroutine panic").Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assertCaller(t, logs, "goroutine panic", want)
+}
+
+func TestCallerFunction(t *testing.T) {
+	SetCallerFunction(true)
+	defer SetCallerFunction(false)
+	f, err := os.CreateTemp(t.TempDir(), "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	l, err := NewConsoleZapLogger("caller-test", LevelDebug, true, "test", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := line()
+	l.Info(context.Background(), "hello")
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	if caller, _ := entry["caller"].(string); !strings.HasSuffix(caller, "caller_test.go:"+strconv.Itoa(want)) {
+		t.Errorf("caller = %q, want caller_test.go:%d", caller, want)
+	}
+	if fn, _ := entry["func"].(string); !strings.HasSuffix(fn, ".TestCallerFunction") {
+		t.Errorf("func = %q", fn)
+	}
+}
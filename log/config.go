@@ -0,0 +1,199 @@
+package log
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/termutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	EncoderConsole = "console"
+	EncoderJSON    = "json"
+)
+
+// Config is the log block of the service configuration. Its zero value gives
+// the logger installed at startup: debug level, console encoding, on stdout
+// and in ./logs/ rotated daily.
+type Config struct {
+	// Prefix is the name of the log files, before their date.
+	Prefix  string `yaml:"prefix"`
+	Module  string `yaml:"module"`
+	Version string `yaml:"version"`
+	SDKType string `yaml:"sdkType"`
+	// Platform is the platform name written by SDK loggers.
+	Platform string `yaml:"platform"`
+
+	// Level is debug, info, warn, error, panic, fatal or debugWithSQL, or the
+	// number of one of the Level constants.
+	Level   string `yaml:"level"`
+	Encoder string `yaml:"encoder"`
+	// Stdout also writes the entries to stdout. It defaults to true.
+	Stdout *bool `yaml:"isStdout"`
+	// StorageLocation is the directory of the log files.
+	StorageLocation string `yaml:"storageLocation"`
+	// DisableFile only writes to stdout.
+	DisableFile bool `yaml:"disableFile"`
+	// RotationTime is the lifetime of a log file, in hours.
+	RotationTime uint `yaml:"rotationTime"`
+	// RemainRotationCount is the number of log files kept.
+	RemainRotationCount uint `yaml:"remainRotationCount"`
+
+	Simplify       bool `yaml:"simplify"`
+	CallerFunction bool `yaml:"callerFunction"`
+	// Color is auto, always or never, see termutil.ColorEnabled. It applies to
+	// the whole process, including the loggers created afterwards.
+	Color string `yaml:"color"`
+
+	// Sampling, when set, limits the entries with the same level and message
+	// logged every Tick: the first Initial are written, then every Thereafter-th.
+	Sampling *SamplingConfig `yaml:"sampling"`
+	// ErrorAggregation, when positive, is the interval of the error summary,
+	// see EnableErrorAggregation.
+	ErrorAggregation time.Duration `yaml:"errorAggregation"`
+
+	level int
+	color termutil.ColorMode
+}
+
+type SamplingConfig struct {
+	Initial    int           `yaml:"initial"`
+	Thereafter int           `yaml:"thereafter"`
+	Tick       time.Duration `yaml:"tick"`
+}
+
+var levelNames = map[string]int{
+	"fatal":        LevelFatal,
+	"panic":        LevelPanic,
+	"error":        LevelError,
+	"warn":         LevelWarn,
+	"info":         LevelInfo,
+	"debug":        LevelDebug,
+	"debugwithsql": LevelDebugWithSQL,
+}
+
+// ValidateAndSetDefaults validates the configuration and sets default values.
+func (c *Config) ValidateAndSetDefaults() error {
+	if c.Prefix == "" {
+		c.Prefix = "DefaultLogger"
+	}
+	if c.Module == "" {
+		c.Module = "DefaultLoggerModule"
+	}
+	if c.Version == "" {
+		c.Version = version
+	}
+	if c.Level == "" {
+		c.level = LevelDebug
+	} else if level, ok := levelNames[strings.ToLower(c.Level)]; ok {
+		c.level = level
+	} else if level, err := strconv.Atoi(c.Level); err == nil && level >= LevelFatal && level <= LevelDebugWithSQL {
+		c.level = level
+	} else {
+		return errs.ErrConfig.WrapMsg("unknown log level", "level", c.Level)
+	}
+	switch c.Encoder {
+	case "":
+		c.Encoder = EncoderConsole
+	case EncoderConsole, EncoderJSON:
+	default:
+		return errs.ErrConfig.WrapMsg("unknown log encoder, want console or json", "encoder", c.Encoder)
+	}
+	color, err := termutil.ParseColorMode(c.Color)
+	if err != nil {
+		return errs.ErrConfig.WrapMsg("unknown log color, want auto, always or never", "color", c.Color)
+	}
+	c.color = color
+	if c.Stdout == nil {
+		stdout := true
+		c.Stdout = &stdout
+	}
+	if c.DisableFile {
+		if !*c.Stdout {
+			return errs.ErrConfig.WrapMsg("log disableFile with isStdout false leaves no output")
+		}
+		c.StorageLocation = ""
+	} else if c.StorageLocation == "" {
+		c.StorageLocation = logPath
+	}
+	if c.RotationTime == 0 {
+		c.RotationTime = hoursPerDay
+	}
+	if c.RemainRotationCount == 0 {
+		c.RemainRotationCount = rotateCount
+	}
+	if s := c.Sampling; s != nil {
+		if s.Initial <= 0 {
+			return errs.ErrConfig.WrapMsg("log sampling.initial must be positive", "initial", s.Initial)
+		}
+		if s.Thereafter < 0 {
+			return errs.ErrConfig.WrapMsg("log sampling.thereafter is negative", "thereafter", s.Thereafter)
+		}
+		if s.Tick <= 0 {
+			s.Tick = time.Second
+		}
+		if c.level == LevelDebugWithSQL {
+			return errs.ErrConfig.WrapMsg("log sampling drops SQL traces, it cannot be used at level debugWithSQL")
+		}
+	}
+	if c.ErrorAggregation < 0 {
+		return errs.ErrConfig.WrapMsg("log errorAggregation is negative", "errorAggregation", c.ErrorAggregation)
+	}
+	if c.ErrorAggregation > 0 && c.level < LevelError {
+		return errs.ErrConfig.WrapMsg("log errorAggregation needs a level of error or more verbose", "level", c.Level)
+	}
+	return nil
+}
+
+var (
+	initMu sync.Mutex
+	// configAggregator is the aggregator enabled by the last InitFromConfig.
+	configAggregator *ErrorAggregator
+)
+
+// InitFromConfig initializes the package logger from cfg. It can be called
+// again when the configuration is reloaded: the new logger is built before it
+// replaces the current one, so the entries logged meanwhile go to the old
+// outputs, which are then flushed and closed.
+func InitFromConfig(cfg Config) error {
+	if err := cfg.ValidateAndSetDefaults(); err != nil {
+		return err
+	}
+	initMu.Lock()
+	defer initMu.Unlock()
+	SetCallerFunction(cfg.CallerFunction)
+	termutil.SetColorMode(cfg.color)
+	isJson := cfg.Encoder == EncoderJSON
+	l, err := NewZapLogger(cfg.Prefix, cfg.Module, cfg.SDKType, cfg.Platform, cfg.level, *cfg.Stdout, isJson,
+		cfg.StorageLocation, cfg.RemainRotationCount, cfg.RotationTime, cfg.Version, cfg.Simplify)
+	if err != nil {
+		return errs.WrapMsg(err, "build logger from config")
+	}
+	if s := cfg.Sampling; s != nil {
+		l.zap = l.zap.Desugar().WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, s.Tick, s.Initial, s.Thereafter)
+		})).Sugar()
+	}
+	old := getPkgLogger()
+	setPkgLogger(packageLogger(l, cfg.Module, isJson))
+	if z, ok := old.(*ZapLogger); ok {
+		_ = z.zap.Sync()
+		if z.writer != nil {
+			_ = z.writer.Close()
+		}
+	}
+
+	if configAggregator != nil {
+		configAggregator.Stop()
+		configAggregator = nil
+	}
+	if cfg.ErrorAggregation > 0 {
+		configAggregator = EnableErrorAggregation(cfg.ErrorAggregation)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/termutil"
+	"gopkg.in/yaml.v2"
+)
+
+// initFixture initializes the package logger from testdata/name, writing its
+// files in a temporary directory, and returns the contents reader.
+func initFixture(t *testing.T, name string) func() string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cfg.StorageLocation = dir
+	old := getPkgLogger()
+	t.Cleanup(func() {
+		setPkgLogger(old)
+		SetCallerFunction(false)
+		termutil.SetColorMode(termutil.ColorAuto)
+	})
+	if err := InitFromConfig(cfg); err != nil {
+		t.Fatalf("InitFromConfig(%s): %v", name, err)
+	}
+	return func() string {
+		files, err := filepath.Glob(filepath.Join(dir, cfg.Prefix+".*"))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("log files = %v, %v", files, err)
+		}
+		b, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+}
+
+func jsonEntries(t *testing.T, out string) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var e map[string]any
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("entry %q is not JSON: %v", line, err)
+		}
+		// The messages are padded to align the fields.
+		e["msg"] = strings.TrimSpace(e["msg"].(string))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestConfigDefaults(t *testing.T) {
+	var cfg Config
+	if err := cfg.ValidateAndSetDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.level != LevelDebug || cfg.Encoder != EncoderConsole || !*cfg.Stdout || cfg.StorageLocation != logPath ||
+		cfg.RotationTime != hoursPerDay || cfg.RemainRotationCount != rotateCount || cfg.Version != version {
+		t.Fatalf("defaults = %+v", cfg)
+	}
+}
+
+func TestInitFromConfig(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("console debug", func(t *testing.T) {
+		read := initFixture(t, "console_debug.yml")
+		ZDebug(ctx, "debug entry", "k", "v")
+		out := read()
+		if !strings.Contains(out, "debug entry") || !strings.Contains(out, "console-module") {
+			t.Fatalf("output = %q", out)
+		}
+		if json.Valid([]byte(strings.TrimSpace(out))) {
+			t.Fatalf("console encoder wrote JSON: %q", out)
+		}
+	})
+
+	t.Run("console without colors", func(t *testing.T) {
+		read := initFixture(t, "console_plain.yml")
+		ZWarn(ctx, "plain entry", nil)
+		out := read()
+		if strings.Contains(out, "\x1b[") {
+			t.Fatalf("output has ANSI escapes: %q", out)
+		}
+		if !strings.Contains(out, "WARN") || !strings.Contains(out, "plain-module") {
+			t.Fatalf("output = %q, want the level and module as text", out)
+		}
+	})
+
+	t.Run("json info", func(t *testing.T) {
+		read := initFixture(t, "json_info.yml")
+		ZDebug(ctx, "filtered")
+		ZInfo(ctx, "info entry")
+		ZError(ctx, "error entry", errors.New("boom"))
+		entries := jsonEntries(t, read())
+		if len(entries) != 2 {
+			t.Fatalf("entries = %v, want info and error", entries)
+		}
+		e := entries[0]
+		if e["msg"] != "info entry" || e["logger"] != "json-module" || e["version"] != "v1.2.3" {
+			t.Errorf("entry = %v", e)
+		}
+		if fn, _ := e["func"].(string); !strings.Contains(fn, "TestInitFromConfig.func") {
+			t.Errorf("func = %q", fn)
+		}
+	})
+
+	t.Run("sampling", func(t *testing.T) {
+		read := initFixture(t, "sampling.yml")
+		for i := 0; i < 5; i++ {
+			ZInfo(ctx, "repeated")
+		}
+		ZInfo(ctx, "other")
+		entries := jsonEntries(t, read())
+		if len(entries) != 3 {
+			t.Fatalf("entries = %v, want 2 repeated and 1 other", entries)
+		}
+	})
+
+	t.Run("reinit", func(t *testing.T) {
+		readFirst := initFixture(t, "console_debug.yml")
+		ZInfo(ctx, "before reload")
+		readSecond := initFixture(t, "json_info.yml")
+		ZInfo(ctx, "after reload")
+		if first := readFirst(); !strings.Contains(first, "before reload") || strings.Contains(first, "after reload") {
+			t.Errorf("first logger output = %q", first)
+		}
+		if entries := jsonEntries(t, readSecond()); len(entries) != 1 || entries[0]["msg"] != "after reload" {
+			t.Errorf("second logger entries = %v", entries)
+		}
+	})
+}
+
+func TestInitFromConfigClosesFiles(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("open files cannot be counted:", err)
+	}
+	openFiles := func() int {
+		fds, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(fds)
+	}
+	ctx := context.Background()
+	initFixture(t, "json_info.yml")
+	ZInfo(ctx, "first")
+	before := openFiles()
+	for i := 0; i < 10; i++ {
+		initFixture(t, "json_info.yml")
+		ZInfo(ctx, "reloaded")
+	}
+	if after := openFiles(); after > before {
+		t.Errorf("open files grew from %d to %d over 10 reloads", before, after)
+	}
+}
+
+func TestInitFromConfigInvalid(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "conflict.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conflict Config
+	if err := yaml.Unmarshal(data, &conflict); err != nil {
+		t.Fatal(err)
+	}
+	for name, cfg := range map[string]Config{
+		"no output":        conflict,
+		"unknown level":    {Level: "verbose"},
+		"unknown encoder":  {Encoder: "logfmt"},
+		"unknown color":    {Color: "rainbow"},
+		"sampling initial": {Sampling: &SamplingConfig{Thereafter: 10}},
+		"sampling sql":     {Level: "debugWithSQL", Sampling: &SamplingConfig{Initial: 1}},
+		"aggregation":      {Level: "fatal", ErrorAggregation: 1},
+	} {
+		old := getPkgLogger()
+		err := InitFromConfig(cfg)
+		if !errors.Is(err, errs.ErrConfig) {
+			t.Errorf("%s: err = %v, want ErrConfig", name, err)
+		}
+		if getPkgLogger() != old {
+			t.Errorf("%s: logger replaced by an invalid config", name)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrorHook is called for every entry logged at Error level, before the
+// level filter of the logger applies. Hooks run synchronously on the logging
+// goroutine and must not block.
+type ErrorHook func(ctx context.Context, msg string, err error)
+
+var (
+	hookLock   sync.Mutex
+	hookSeq    uint64
+	errorHooks atomic.Pointer[[]registeredHook]
+)
+
+type registeredHook struct {
+	id   uint64
+	hook ErrorHook
+}
+
+// AddErrorHook registers hook and returns a function that removes it.
+func AddErrorHook(hook ErrorHook) (remove func()) {
+	hookLock.Lock()
+	defer hookLock.Unlock()
+	hookSeq++
+	id := hookSeq
+	var hooks []registeredHook
+	if old := errorHooks.Load(); old != nil {
+		hooks = append(hooks, *old...)
+	}
+	hooks = append(hooks, registeredHook{id: id, hook: hook})
+	errorHooks.Store(&hooks)
+	return func() { removeErrorHook(id) }
+}
+
+func removeErrorHook(id uint64) {
+	hookLock.Lock()
+	defer hookLock.Unlock()
+	old := errorHooks.Load()
+	if old == nil {
+		return
+	}
+	hooks := make([]registeredHook, 0, len(*old))
+	for _, h := range *old {
+		if h.id != id {
+			hooks = append(hooks, h)
+		}
+	}
+	errorHooks.Store(&hooks)
+}
+
+func runErrorHooks(ctx context.Context, msg string, err error) {
+	if hooks := errorHooks.Load(); hooks != nil {
+		for _, h := range *hooks {
+			h.hook(ctx, msg, err)
+		}
+	}
+}
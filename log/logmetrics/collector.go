@@ -0,0 +1,56 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logmetrics exposes the log counters as Prometheus metrics. It is
+// kept apart from the log package so that only services that export metrics
+// depend on the Prometheus client.
+package logmetrics
+
+import (
+	"github.com/openimsdk/tools/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	entriesDesc = prometheus.NewDesc("openim_log_entries_total",
+		"Number of log entries written, by module and level.", []string{"module", "level"}, nil)
+	levelDesc = prometheus.NewDesc("openim_log_level",
+		"Configured minimum log level per module, as a zap level (-1 debug, 0 info, 1 warn, 2 error).", []string{"module"}, nil)
+	droppedDesc = prometheus.NewDesc("openim_log_dropped_entries_total",
+		"Number of log entries lost because the log writer failed.", nil, nil)
+)
+
+type collector struct{}
+
+// NewCollector returns a collector of the log metrics, to be registered
+// with the service's Prometheus registry.
+func NewCollector() prometheus.Collector {
+	return collector{}
+}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- entriesDesc
+	ch <- levelDesc
+	ch <- droppedDesc
+}
+
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range log.Metrics() {
+		ch <- prometheus.MustNewConstMetric(levelDesc, prometheus.GaugeValue, float64(m.Level), m.Module)
+		for level, n := range m.Entries {
+			ch <- prometheus.MustNewConstMetric(entriesDesc, prometheus.CounterValue, float64(n), m.Module, level.String())
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.CounterValue, float64(log.DroppedEntries()))
+}
@@ -0,0 +1,75 @@
+package logmetrics
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/openimsdk/tools/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCollector(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	l, err := log.NewConsoleZapLogger("collector-test", log.LevelWarn, true, "test", devNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	l.Info(ctx, "filtered")
+	l.Warn(ctx, "slow", nil)
+	l.Error(ctx, "failed", nil)
+	l.Error(ctx, "failed", nil)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewCollector())
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := make(map[string][]*dto.Metric)
+	for _, f := range families {
+		metrics[f.GetName()] = f.GetMetric()
+	}
+
+	entries := make(map[string]float64)
+	for _, m := range metrics["openim_log_entries_total"] {
+		if label(m, "module") == "collector-test" {
+			entries[label(m, "level")] = m.GetCounter().GetValue()
+		}
+	}
+	want := map[string]float64{"debug": 0, "info": 0, "warn": 1, "error": 2, "panic": 0}
+	for level, n := range want {
+		if entries[level] != n {
+			t.Errorf("entries{level=%s} = %v, want %v", level, entries[level], n)
+		}
+	}
+
+	var level *float64
+	for _, m := range metrics["openim_log_level"] {
+		if label(m, "module") == "collector-test" {
+			v := m.GetGauge().GetValue()
+			level = &v
+		}
+	}
+	if level == nil || *level != 1 {
+		t.Errorf("openim_log_level = %v, want 1 (warn)", level)
+	}
+	if len(metrics["openim_log_dropped_entries_total"]) != 1 {
+		t.Error("dropped entries counter missing")
+	}
+}
+
+func label(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
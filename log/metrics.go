@@ -0,0 +1,117 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// metricLevels are the levels reported per module.
+var metricLevels = []zapcore.Level{
+	zapcore.DebugLevel,
+	zapcore.InfoLevel,
+	zapcore.WarnLevel,
+	zapcore.ErrorLevel,
+	zapcore.PanicLevel,
+}
+
+// moduleMetrics counts the entries written by the loggers of one module. A
+// logger resolves its moduleMetrics once, so counting is a single atomic add.
+type moduleMetrics struct {
+	level   atomic.Int32
+	entries [zapcore.PanicLevel - zapcore.DebugLevel + 1]atomic.Uint64
+}
+
+func (m *moduleMetrics) inc(level zapcore.Level) {
+	if m == nil {
+		return
+	}
+	m.entries[level-zapcore.DebugLevel].Add(1)
+}
+
+var (
+	metricsLock sync.Mutex
+	modules     = make(map[string]*moduleMetrics)
+
+	droppedEntries atomic.Uint64
+)
+
+// metricsFor returns the counters of module, recording level as its current
+// configured level.
+func metricsFor(module string, level zapcore.Level) *moduleMetrics {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	m, ok := modules[module]
+	if !ok {
+		m = &moduleMetrics{}
+		modules[module] = m
+	}
+	m.level.Store(int32(level))
+	return m
+}
+
+// ModuleMetrics is a snapshot of the entries logged by a module.
+type ModuleMetrics struct {
+	Module string
+	// Level is the level the module's logger was configured with.
+	Level zapcore.Level
+	// Entries counts the entries written at each level.
+	Entries map[zapcore.Level]uint64
+}
+
+// Metrics returns the counters of every module that created a logger, sorted
+// by module name.
+func Metrics() []ModuleMetrics {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	res := make([]ModuleMetrics, 0, len(modules))
+	for name, m := range modules {
+		mm := ModuleMetrics{
+			Module:  name,
+			Level:   zapcore.Level(m.level.Load()),
+			Entries: make(map[zapcore.Level]uint64, len(metricLevels)),
+		}
+		for _, level := range metricLevels {
+			mm.Entries[level] = m.entries[level-zapcore.DebugLevel].Load()
+		}
+		res = append(res, mm)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Module < res[j].Module })
+	return res
+}
+
+// DroppedEntries returns how many entries could not be written because the
+// log file writer failed.
+func DroppedEntries() uint64 {
+	return droppedEntries.Load()
+}
+
+// countingWriter counts failed writes as dropped entries. The core writes
+// one entry per call.
+type countingWriter struct {
+	zapcore.WriteSyncer
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteSyncer.Write(p)
+	if err != nil {
+		droppedEntries.Add(1)
+	}
+	return n, err
+}
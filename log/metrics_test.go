@@ -0,0 +1,68 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestModuleMetrics(t *testing.T) {
+	core, _ := observer.New(zapcore.DebugLevel)
+	l := &ZapLogger{zap: zap.New(core).Sugar(), level: zapcore.InfoLevel, metrics: metricsFor("metrics-test", zapcore.InfoLevel)}
+	ctx := context.Background()
+	l.Debug(ctx, "filtered")
+	l.Info(ctx, "a")
+	l.Info(ctx, "b")
+	l.WithName("sub").Warn(ctx, "c", nil)
+	l.Error(ctx, "d", errors.New("boom"))
+
+	var got *ModuleMetrics
+	for _, m := range Metrics() {
+		if m.Module == "metrics-test" {
+			got = &m
+		}
+	}
+	if got == nil {
+		t.Fatal("module not reported")
+	}
+	want := map[zapcore.Level]uint64{zapcore.DebugLevel: 0, zapcore.InfoLevel: 2, zapcore.WarnLevel: 1, zapcore.ErrorLevel: 1, zapcore.PanicLevel: 0}
+	for level, n := range want {
+		if got.Entries[level] != n {
+			t.Errorf("%s entries = %d, want %d", level, got.Entries[level], n)
+		}
+	}
+	if got.Level != zapcore.InfoLevel {
+		t.Errorf("level = %s, want info", got.Level)
+	}
+	if n := testing.AllocsPerRun(100, func() { l.metrics.inc(zapcore.InfoLevel) }); n != 0 {
+		t.Errorf("counting allocates %v times", n)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+func (failingWriter) Sync() error               { return nil }
+
+func TestDroppedEntries(t *testing.T) {
+	before := DroppedEntries()
+	w := countingWriter{failingWriter{}}
+	_, _ = w.Write([]byte("entry"))
+	if got := DroppedEntries() - before; got != 1 {
+		t.Fatalf("dropped = %d, want 1", got)
+	}
+}
+
+// BenchmarkEntryCounter measures the cost the metrics add to every entry.
+func BenchmarkEntryCounter(b *testing.B) {
+	m := metricsFor("bench", zapcore.InfoLevel)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			m.inc(zapcore.InfoLevel)
+		}
+	})
+}
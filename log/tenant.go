@@ -0,0 +1,180 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/openimsdk/tools/mcontext"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultMaxTenants is the number of tenant configurations kept before the
+// least recently used one is evicted.
+const DefaultMaxTenants = 1024
+
+type tenantConfig struct {
+	hasLevel bool
+	level    zapcore.Level
+	core     zapcore.Core
+	hook     ErrorHook
+	lastUsed atomic.Uint64
+}
+
+type TenantOption func(*tenantConfig)
+
+// WithTenantLevel sets the minimum level of the tenant's entries. A level
+// above the global one silences the tenant; a level below it only reaches
+// the tenant's own output, so the global outputs are not flooded.
+func WithTenantLevel(level int) TenantOption {
+	return func(c *tenantConfig) {
+		c.hasLevel = true
+		c.level = logLevelMap[level]
+	}
+}
+
+// WithTenantWriter writes the tenant's entries to w as JSON lines, in addition
+// to the global outputs.
+func WithTenantWriter(w io.Writer) TenantOption {
+	return func(c *tenantConfig) {
+		enc := zap.NewProductionEncoderConfig()
+		enc.EncodeTime = zapcore.ISO8601TimeEncoder
+		enc.MessageKey = "msg"
+		enc.TimeKey = "time"
+		c.core = zapcore.NewCore(zapcore.NewJSONEncoder(enc), zapcore.Lock(zapcore.AddSync(w)), zapcore.DebugLevel)
+	}
+}
+
+// WithTenantErrorHook runs hook for the errors logged for the tenant, in
+// addition to the hooks registered with AddErrorHook.
+func WithTenantErrorHook(hook ErrorHook) TenantOption {
+	return func(c *tenantConfig) {
+		c.hook = hook
+	}
+}
+
+var (
+	tenantLock  sync.RWMutex
+	tenants     = make(map[string]*tenantConfig)
+	maxTenants  = DefaultMaxTenants
+	tenantClock atomic.Uint64
+	// tenantCount lets loggers skip the lookup while no tenant is configured.
+	tenantCount atomic.Int32
+)
+
+// ConfigureTenant replaces the configuration of tenantID, which applies to
+// entries logged with a context carrying it, see mcontext.SetTenantID.
+// Tenants that are not configured use the global configuration. When more
+// than SetMaxTenants tenants are configured, the one used least recently is
+// evicted and returns to the defaults.
+func ConfigureTenant(tenantID string, opts ...TenantOption) {
+	c := &tenantConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.lastUsed.Store(tenantClock.Add(1))
+	tenantLock.Lock()
+	defer tenantLock.Unlock()
+	tenants[tenantID] = c
+	evictTenants()
+	tenantCount.Store(int32(len(tenants)))
+}
+
+// RemoveTenant returns tenantID to the global configuration.
+func RemoveTenant(tenantID string) {
+	tenantLock.Lock()
+	defer tenantLock.Unlock()
+	delete(tenants, tenantID)
+	tenantCount.Store(int32(len(tenants)))
+}
+
+// SetMaxTenants bounds the number of tenant configurations, evicting the
+// least recently used ones if there are more already.
+func SetMaxTenants(n int) {
+	tenantLock.Lock()
+	defer tenantLock.Unlock()
+	maxTenants = max(n, 1)
+	evictTenants()
+	tenantCount.Store(int32(len(tenants)))
+}
+
+// evictTenants drops the least recently used configurations over the cap.
+// Eviction only happens when tenants are configured, so a linear scan is
+// cheaper than maintaining an ordered list on every log call.
+func evictTenants() {
+	for len(tenants) > maxTenants {
+		var (
+			oldest   string
+			oldestAt uint64
+		)
+		for id, c := range tenants {
+			if at := c.lastUsed.Load(); oldest == "" || at < oldestAt {
+				oldest, oldestAt = id, at
+			}
+		}
+		delete(tenants, oldest)
+	}
+}
+
+// tenantFor returns the configuration of the tenant of ctx, or nil.
+func tenantFor(ctx context.Context) *tenantConfig {
+	if ctx == nil || tenantCount.Load() == 0 {
+		return nil
+	}
+	tenantID := mcontext.GetTenantID(ctx)
+	if tenantID == "" {
+		return nil
+	}
+	tenantLock.RLock()
+	c := tenants[tenantID]
+	tenantLock.RUnlock()
+	if c != nil {
+		c.lastUsed.Store(tenantClock.Add(1))
+	}
+	return c
+}
+
+// loggerFor returns the logger an entry at level for ctx is written with, or
+// nil if the entry is filtered out.
+func (l *ZapLogger) loggerFor(ctx context.Context, level zapcore.Level) *zap.SugaredLogger {
+	c := tenantFor(ctx)
+	if c == nil {
+		if level < l.level {
+			return nil
+		}
+		return l.zap
+	}
+	minLevel := l.level
+	if c.hasLevel {
+		minLevel = c.level
+	}
+	if level < minLevel {
+		return nil
+	}
+	if c.core == nil {
+		if level < l.level {
+			return nil
+		}
+		return l.zap
+	}
+	// The global cores keep filtering at the global level.
+	return l.zap.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, c.core)
+	}))
+}
@@ -0,0 +1,121 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/tools/mcontext"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func resetTenants(t *testing.T) {
+	t.Cleanup(func() {
+		tenantLock.Lock()
+		tenants = make(map[string]*tenantConfig)
+		maxTenants = DefaultMaxTenants
+		tenantCount.Store(0)
+		tenantLock.Unlock()
+	})
+}
+
+func TestTenantRouting(t *testing.T) {
+	resetTenants(t)
+	core, global := observer.New(zapcore.InfoLevel)
+	l := &ZapLogger{zap: zap.New(core).Sugar(), level: zapcore.InfoLevel}
+
+	var verbose bytes.Buffer
+	var hooked []string
+	ConfigureTenant("verbose", WithTenantLevel(LevelDebug), WithTenantWriter(&verbose),
+		WithTenantErrorHook(func(_ context.Context, msg string, _ error) { hooked = append(hooked, msg) }))
+	ConfigureTenant("quiet", WithTenantLevel(LevelError))
+
+	verboseCtx := mcontext.SetTenantID(context.Background(), "verbose")
+	quietCtx := mcontext.SetTenantID(context.Background(), "quiet")
+	otherCtx := mcontext.SetTenantID(context.Background(), "other")
+
+	l.Debug(verboseCtx, "verbose debug")
+	l.Info(verboseCtx, "verbose info")
+	l.Error(verboseCtx, "verbose error", errors.New("boom"))
+	l.Info(quietCtx, "quiet info")
+	l.Error(quietCtx, "quiet error", nil)
+	l.Debug(otherCtx, "other debug")
+	l.Info(otherCtx, "other info")
+
+	var globalMsgs []string
+	for _, e := range global.All() {
+		globalMsgs = append(globalMsgs, e.Message)
+	}
+	if got, want := strings.Join(globalMsgs, ","), "verbose info,verbose error,quiet error,other info"; got != want {
+		t.Errorf("global output = %s, want %s", got, want)
+	}
+	for _, e := range global.All() {
+		if e.ContextMap()[mcontext.TenantID] == nil {
+			t.Errorf("entry %q has no tenantID field", e.Message)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(verbose.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"msg":"verbose debug"`) || !strings.Contains(lines[0], `"tenantID":"verbose"`) {
+		t.Errorf("tenant output = %q", verbose.String())
+	}
+	if len(hooked) != 1 || hooked[0] != "verbose error" {
+		t.Errorf("tenant error hook ran for %v", hooked)
+	}
+
+	// Reconfiguring applies immediately.
+	ConfigureTenant("verbose")
+	l.Debug(verboseCtx, "after reconfigure")
+	if strings.Contains(verbose.String(), "after reconfigure") || global.FilterMessage("after reconfigure").Len() != 0 {
+		t.Error("old tenant configuration still applied")
+	}
+}
+
+func TestTenantEviction(t *testing.T) {
+	resetTenants(t)
+	core, global := observer.New(zapcore.DebugLevel)
+	l := &ZapLogger{zap: zap.New(core).Sugar(), level: zapcore.DebugLevel}
+
+	SetMaxTenants(2)
+	ConfigureTenant("a", WithTenantLevel(LevelError))
+	ConfigureTenant("b", WithTenantLevel(LevelError))
+	// Using a makes b the least recently used tenant.
+	l.Info(mcontext.SetTenantID(context.Background(), "a"), "a info")
+	ConfigureTenant("c", WithTenantLevel(LevelError))
+
+	tenantLock.RLock()
+	_, hasA := tenants["a"]
+	_, hasB := tenants["b"]
+	_, hasC := tenants["c"]
+	tenantLock.RUnlock()
+	if !hasA || hasB || !hasC {
+		t.Fatalf("tenants after eviction: a=%v b=%v c=%v, want a and c", hasA, hasB, hasC)
+	}
+	// The evicted tenant is back to the defaults.
+	l.Info(mcontext.SetTenantID(context.Background(), "b"), "b info")
+	if global.FilterMessage("b info").Len() != 1 || global.FilterMessage("a info").Len() != 0 {
+		t.Errorf("unexpected global entries %v", global.All())
+	}
+
+	SetMaxTenants(1)
+	if tenantCount.Load() != 1 {
+		t.Errorf("%d tenants kept after lowering the cap, want 1", tenantCount.Load())
+	}
+	RemoveTenant("c")
+	if tenantCount.Load() != 0 {
+		t.Errorf("%d tenants after RemoveTenant", tenantCount.Load())
+	}
+}
+
+func BenchmarkLogWithoutTenants(b *testing.B) {
+	core, _ := observer.New(zapcore.ErrorLevel)
+	l := &ZapLogger{zap: zap.New(core).Sugar(), level: zapcore.InfoLevel}
+	ctx := mcontext.SetTenantID(context.Background(), "t")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Debug(ctx, "filtered")
+	}
+}
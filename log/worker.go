@@ -0,0 +1,81 @@
+package log
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mcontext"
+)
+
+var workerSeq atomic.Uint64
+
+// NewWorkerContext returns a context for a long-lived background worker that has no request to inherit from.
+// The generated workerID (name plus a process-unique instance suffix) stands in for the operationID, so every
+// entry logged and every RPC issued with the context can be attributed to the worker.
+func NewWorkerContext(name string) context.Context {
+	workerID := name + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatUint(workerSeq.Add(1), 10)
+	ctx := mcontext.SetOperationID(context.Background(), workerID)
+	return mcontext.SetWorkerID(ctx, workerID)
+}
+
+// CronWrapper adapts fn to a func() suitable for cron-style schedulers.
+// All runs share one worker context; each run logs its start and end with the elapsed time,
+// and a returned error or panic is logged through ZError with the worker identity.
+func CronWrapper(name string, fn func(ctx context.Context) error) func() {
+	ctx := NewWorkerContext(name)
+	return func() {
+		start := time.Now()
+		ZInfo(ctx, "worker run start", "worker", name)
+		defer func() {
+			if r := recover(); r != nil {
+				ZError(ctx, "worker run panic", errs.ErrPanic(r), "worker", name, "cost", time.Since(start))
+			}
+		}()
+		if err := fn(ctx); err != nil {
+			ZError(ctx, "worker run failed", err, "worker", name, "cost", time.Since(start))
+			return
+		}
+		ZInfo(ctx, "worker run end", "worker", name, "cost", time.Since(start))
+	}
+}
+
+// SafeGo runs fn in a new goroutine. A panic is recovered and logged through
+// ZError with the stack; the entry reports the line that panicked rather
+// than the recovery code.
+func SafeGo(ctx context.Context, name string, fn func(ctx context.Context)) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				WithCallerSkip(panicSkip()).Error(ctx, "goroutine panic", errs.ErrPanic(r), "goroutine", name)
+			}
+		}()
+		fn(ctx)
+	}()
+}
+
+// panicSkip returns how many frames above the deferred function calling it
+// the panic was raised, skipping the runtime frames in between, or 0 when it
+// is not called during a panic.
+func panicSkip() int {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	panicking := false
+	for i := 0; ; i++ {
+		frame, more := frames.Next()
+		switch {
+		case frame.Function == "runtime.gopanic":
+			panicking = true
+		case panicking && !strings.HasPrefix(frame.Function, "runtime."):
+			return i
+		}
+		if !more {
+			return 0
+		}
+	}
+}
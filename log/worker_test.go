@@ -0,0 +1,81 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/mcontext"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func observeLogger(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zapcore.DebugLevel)
+	old := getPkgLogger()
+	setPkgLogger(&ZapLogger{zap: zap.New(core).Sugar(), level: zapcore.DebugLevel})
+	t.Cleanup(func() { setPkgLogger(old) })
+	return logs
+}
+
+func TestNewWorkerContext(t *testing.T) {
+	logs := observeLogger(t)
+	ctx := NewWorkerContext("cacheRefresher")
+	workerID := mcontext.GetWorkerID(ctx)
+	if !strings.HasPrefix(workerID, "cacheRefresher-") {
+		t.Fatalf("unexpected workerID %q", workerID)
+	}
+	if mcontext.GetOperationID(ctx) != workerID {
+		t.Fatalf("operationID %q should equal workerID %q", mcontext.GetOperationID(ctx), workerID)
+	}
+	if other := mcontext.GetWorkerID(NewWorkerContext("cacheRefresher")); other == workerID {
+		t.Fatalf("two workers share the same id %q", workerID)
+	}
+
+	ZInfo(ctx, "refresh")
+	entry := logs.All()[0].ContextMap()
+	if entry[mcontext.WorkerID] != workerID || entry[constant.OperationID] != workerID {
+		t.Fatalf("worker identity missing from entry: %v", entry)
+	}
+}
+
+func TestCronWrapper(t *testing.T) {
+	logs := observeLogger(t)
+	var workerID string
+	run := CronWrapper("purge", func(ctx context.Context) error {
+		workerID = mcontext.GetWorkerID(ctx)
+		return errors.New("purge failed")
+	})
+	run()
+	run()
+
+	entries := logs.All()
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	for _, e := range entries {
+		if e.ContextMap()[mcontext.WorkerID] != workerID {
+			t.Fatalf("entry %q has workerID %v, want %s", e.Message, e.ContextMap()[mcontext.WorkerID], workerID)
+		}
+	}
+	if entries[1].Level != zapcore.ErrorLevel || entries[1].ContextMap()["error"] != "purge failed" {
+		t.Fatalf("error not routed through ZError: %+v", entries[1])
+	}
+	if _, ok := entries[1].ContextMap()["cost"]; !ok {
+		t.Fatal("missing duration on end entry")
+	}
+}
+
+func TestCronWrapperPanic(t *testing.T) {
+	logs := observeLogger(t)
+	CronWrapper("panicky", func(ctx context.Context) error {
+		panic("boom")
+	})()
+	entries := logs.FilterMessage("worker run panic").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected panic entry, got %d", len(entries))
+	}
+}
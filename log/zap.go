@@ -3,14 +3,17 @@ package log
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"github.com/openimsdk/tools/errs"
 
 	rotatelogs "github.com/openimsdk/tools/log/file-rotatelogs"
 	"github.com/openimsdk/tools/utils/stringutil"
+	"github.com/openimsdk/tools/utils/termutil"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -22,7 +25,8 @@ import (
 var (
 	AdaptiveDefaultLevel   = LevelWarn
 	AdaptiveErrorCodeLevel = map[int]int{
-		errs.ErrInternalServer.Code(): LevelError,
+		errs.ErrInternalServer.Code():  LevelError,
+		errs.ErrCallerCancelled.Code(): LevelInfo,
 	}
 )
 
@@ -41,7 +45,9 @@ const (
 )
 
 var (
-	pkgLogger   Logger
+	// pkgLogger holds a loggerBox, so that InitFromConfig can swap the package
+	// logger while other goroutines log through it.
+	pkgLogger   atomic.Value
 	osStdout    Logger
 	sp          = string(filepath.Separator)
 	logLevelMap = map[int]zapcore.Level{
@@ -56,7 +62,7 @@ var (
 )
 
 const (
-	callDepth   int    = 2
+	callDepth   int    = 1
 	rotateCount uint   = 1
 	hoursPerDay uint   = 24
 	logPath     string = "./logs/"
@@ -99,11 +105,28 @@ func InitLoggerFromConfig(
 		return err
 	}
 
-	pkgLogger = l.WithCallDepth(callDepth)
+	setPkgLogger(packageLogger(l, moduleName, isJson))
+	return nil
+}
+
+// loggerBox gives every value stored in pkgLogger the same concrete type.
+type loggerBox struct{ Logger }
+
+func getPkgLogger() Logger {
+	return pkgLogger.Load().(loggerBox).Logger
+}
+
+func setPkgLogger(l Logger) {
+	pkgLogger.Store(loggerBox{l})
+}
+
+// packageLogger adapts l to be called through the Z* functions.
+func packageLogger(l *ZapLogger, moduleName string, isJson bool) Logger {
+	pl := l.WithCallDepth(callDepth)
 	if isJson {
-		pkgLogger = pkgLogger.WithName(moduleName)
+		pl = pl.WithName(moduleName)
 	}
-	return nil
+	return pl
 }
 
 // InitConsoleLogger init osStdout and osStderr.
@@ -123,23 +146,23 @@ func InitConsoleLogger(moduleName string,
 }
 
 func ZDebug(ctx context.Context, msg string, keysAndValues ...any) {
-	pkgLogger.Debug(ctx, msg, keysAndValues...)
+	getPkgLogger().Debug(ctx, msg, keysAndValues...)
 }
 
 func ZInfo(ctx context.Context, msg string, keysAndValues ...any) {
-	pkgLogger.Info(ctx, msg, keysAndValues...)
+	getPkgLogger().Info(ctx, msg, keysAndValues...)
 }
 
 func ZWarn(ctx context.Context, msg string, err error, keysAndValues ...any) {
-	pkgLogger.Warn(ctx, msg, err, keysAndValues...)
+	getPkgLogger().Warn(ctx, msg, err, keysAndValues...)
 }
 
 func ZError(ctx context.Context, msg string, err error, keysAndValues ...any) {
-	pkgLogger.Error(ctx, msg, err, keysAndValues...)
+	getPkgLogger().Error(ctx, msg, err, keysAndValues...)
 }
 
 func ZPanic(ctx context.Context, msg string, err error, keysAndValues ...any) {
-	pkgLogger.Error(ctx, msg, err, keysAndValues...)
+	getPkgLogger().Error(ctx, msg, err, keysAndValues...)
 }
 
 func ZAdaptive(ctx context.Context, msg string, err error, keysAndValues ...any) {
@@ -155,15 +178,15 @@ func ZAdaptive(ctx context.Context, msg string, err error, keysAndValues ...any)
 	switch level {
 	case LevelDebug:
 
-		pkgLogger.Debug(ctx, msg, appendError(keysAndValues, err)...)
+		getPkgLogger().Debug(ctx, msg, appendError(keysAndValues, err)...)
 	case LevelInfo:
-		pkgLogger.Info(ctx, msg, appendError(keysAndValues, err)...)
+		getPkgLogger().Info(ctx, msg, appendError(keysAndValues, err)...)
 	case LevelWarn:
-		pkgLogger.Warn(ctx, msg, err, keysAndValues...)
+		getPkgLogger().Warn(ctx, msg, err, keysAndValues...)
 	case LevelError:
-		pkgLogger.Error(ctx, msg, err, keysAndValues...)
+		getPkgLogger().Error(ctx, msg, err, keysAndValues...)
 	case LevelPanic:
-		pkgLogger.Error(ctx, msg, err, keysAndValues...)
+		getPkgLogger().Error(ctx, msg, err, keysAndValues...)
 	default:
 	}
 }
@@ -185,6 +208,10 @@ type ZapLogger struct {
 	sdkType          string
 	platformName     string
 	isSimplify       bool
+	metrics          *moduleMetrics
+	// writer is the rotating file writer, closed when InitFromConfig replaces
+	// the logger.
+	writer io.Closer
 }
 
 func NewZapLogger(
@@ -215,12 +242,13 @@ func NewZapLogger(
 		sdkType:          sdkType,
 		platformName:     platformName,
 		isSimplify:       isSimplify,
+		metrics:          metricsFor(moduleName, logLevelMap[logLevel]),
 	}
 	opts, err := zl.cores(isStdout, isJson, logLocation, rotateCount)
 	if err != nil {
 		return nil, err
 	}
-	l, err := zapConfig.Build(opts)
+	l, err := zapConfig.Build(append(callerOptions(), opts)...)
 	if err != nil {
 		return nil, err
 	}
@@ -243,12 +271,13 @@ func NewConsoleZapLogger(
 	} else {
 		zapConfig.Encoding = "console"
 	}
-	zl := &ZapLogger{level: logLevelMap[logLevel], moduleName: moduleName, moduleVersion: moduleVersion}
+	zl := &ZapLogger{level: logLevelMap[logLevel], moduleName: moduleName, moduleVersion: moduleVersion,
+		metrics: metricsFor(moduleName, logLevelMap[logLevel])}
 	opts, err := zl.consoleCores(outPut, isJson)
 	if err != nil {
 		return nil, err
 	}
-	l, err := zapConfig.Build(opts)
+	l, err := zapConfig.Build(append(callerOptions(), opts)...)
 	if err != nil {
 		return nil, err
 	}
@@ -265,30 +294,23 @@ func (l *ZapLogger) cores(isStdout bool, isJson bool, logLocation string, rotate
 	c.TimeKey = "time"
 	c.CallerKey = "caller"
 	c.NameKey = "logger"
-	var fileEncoder zapcore.Encoder
-	if isJson {
-		c.EncodeLevel = zapcore.CapitalLevelEncoder
-		fileEncoder = zapcore.NewJSONEncoder(c)
-		fileEncoder.AddInt("PID", os.Getpid())
-		fileEncoder.AddString("version", l.moduleVersion)
-	} else {
-		c.EncodeLevel = l.capitalColorLevelEncoder
-		c.EncodeCaller = l.customCallerEncoder
-		fileEncoder = zapcore.NewConsoleEncoder(c)
+	if callerFunction.Load() {
+		c.FunctionKey = "func"
 	}
-	fileEncoder = &alignEncoder{Encoder: fileEncoder}
 	writer, err := l.getWriter(logLocation, rotateCount)
 	if err != nil {
 		return nil, err
 	}
 	var cores []zapcore.Core
 	if logLocation != "" {
+		fileEncoder := &alignEncoder{Encoder: l.encoder(c, isJson, termutil.ColorAllowed())}
 		cores = []zapcore.Core{
 			zapcore.NewCore(fileEncoder, writer, zap.NewAtomicLevelAt(l.level)),
 		}
 	}
 	if isStdout {
-		cores = append(cores, zapcore.NewCore(fileEncoder, zapcore.Lock(os.Stdout), zap.NewAtomicLevelAt(l.level)))
+		stdoutEncoder := &alignEncoder{Encoder: l.encoder(c, isJson, termutil.ColorEnabled(os.Stdout))}
+		cores = append(cores, zapcore.NewCore(stdoutEncoder, zapcore.Lock(os.Stdout), zap.NewAtomicLevelAt(l.level)))
 		// cores = append(cores, zapcore.NewCore(fileEncoder, zapcore.Lock(os.Stderr), zap.NewAtomicLevelAt(l.level)))
 	}
 	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
@@ -305,17 +327,10 @@ func (l *ZapLogger) consoleCores(outPut *os.File, isJson bool) (zap.Option, erro
 	c.TimeKey = "time"
 	c.CallerKey = "caller"
 	c.NameKey = "logger"
-	var fileEncoder zapcore.Encoder
-	if isJson {
-		c.EncodeLevel = zapcore.CapitalLevelEncoder
-		fileEncoder = zapcore.NewJSONEncoder(c)
-		fileEncoder.AddInt("PID", os.Getpid())
-		fileEncoder.AddString("version", l.moduleVersion)
-	} else {
-		c.EncodeLevel = l.capitalColorLevelEncoder
-		c.EncodeCaller = l.customCallerEncoder
-		fileEncoder = zapcore.NewConsoleEncoder(c)
+	if callerFunction.Load() {
+		c.FunctionKey = "func"
 	}
+	fileEncoder := l.encoder(c, isJson, termutil.ColorEnabled(outPut))
 	var cores []zapcore.Core
 	cores = append(cores, zapcore.NewCore(fileEncoder, zapcore.Lock(outPut), zap.NewAtomicLevelAt(l.level)))
 
@@ -324,6 +339,25 @@ func (l *ZapLogger) consoleCores(outPut *os.File, isJson bool) (zap.Option, erro
 	}), nil
 }
 
+// encoder returns the JSON or console encoder of c. Without colors, the
+// console encoder writes the same columns as plain text.
+func (l *ZapLogger) encoder(c zapcore.EncoderConfig, isJson bool, colored bool) zapcore.Encoder {
+	if isJson {
+		c.EncodeLevel = zapcore.CapitalLevelEncoder
+		enc := zapcore.NewJSONEncoder(c)
+		enc.AddInt("PID", os.Getpid())
+		enc.AddString("version", l.moduleVersion)
+		return enc
+	}
+	if colored {
+		c.EncodeLevel = l.capitalColorLevelEncoder
+	} else {
+		c.EncodeLevel = l.capitalLevelEncoder
+	}
+	c.EncodeCaller = l.customCallerEncoder
+	return zapcore.NewConsoleEncoder(c)
+}
+
 func (l *ZapLogger) customCallerEncoder(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
 	if l.sdkType != "" && l.platformName != "" {
 		fixedLength := 50
@@ -391,7 +425,8 @@ func (l *ZapLogger) getWriter(logLocation string, rorateCount uint) (zapcore.Wri
 	if err != nil {
 		return nil, err
 	}
-	return zapcore.AddSync(logf), nil
+	l.writer = logf
+	return countingWriter{zapcore.AddSync(logf)}, nil
 }
 
 func (l *ZapLogger) capitalColorLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
@@ -399,13 +434,24 @@ func (l *ZapLogger) capitalColorLevelEncoder(level zapcore.Level, enc zapcore.Pr
 	if !ok {
 		s = _unknownLevelColor[zapcore.ErrorLevel]
 	}
-	pid := stringutil.FormatString(fmt.Sprintf("[PID:%d]", os.Getpid()), 15, true)
 	color := _levelToColor[level]
 	enc.AppendString(s)
-	enc.AppendString(color.Add(pid))
+	l.appendLevelColumns(enc, color.Add)
+}
+
+func (l *ZapLogger) capitalLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(level.CapitalString())
+	l.appendLevelColumns(enc, func(s string) string { return s })
+}
+
+// appendLevelColumns appends the columns following the level, the pid and
+// module colored by paint.
+func (l *ZapLogger) appendLevelColumns(enc zapcore.PrimitiveArrayEncoder, paint func(string) string) {
+	pid := stringutil.FormatString(fmt.Sprintf("[PID:%d]", os.Getpid()), 15, true)
+	enc.AppendString(paint(pid))
 	if l.moduleName != "" {
 		moduleName := stringutil.FormatString(l.moduleName, 25, true)
-		enc.AppendString(color.Add(moduleName))
+		enc.AppendString(paint(moduleName))
 	}
 	if l.moduleVersion != "" {
 		moduleVersion := stringutil.FormatString(fmt.Sprintf("[%s]", l.moduleVersion), 30, true)
@@ -418,43 +464,60 @@ func (l *ZapLogger) ToZap() *zap.SugaredLogger {
 }
 
 func (l *ZapLogger) Debug(ctx context.Context, msg string, keysAndValues ...any) {
-	if l.level > zapcore.DebugLevel {
+	zl := l.loggerFor(ctx, zapcore.DebugLevel)
+	if zl == nil {
 		return
 	}
 	keysAndValues = l.kvAppend(ctx, keysAndValues)
-	l.zap.Debugw(msg, keysAndValues...)
+	l.metrics.inc(zapcore.DebugLevel)
+	zl.Debugw(msg, keysAndValues...)
 }
 
 func (l *ZapLogger) Info(ctx context.Context, msg string, keysAndValues ...any) {
-	if l.level > zapcore.InfoLevel {
+	zl := l.loggerFor(ctx, zapcore.InfoLevel)
+	if zl == nil {
 		return
 	}
 	keysAndValues = l.kvAppend(ctx, keysAndValues)
-	l.zap.Infow(msg, keysAndValues...)
+	l.metrics.inc(zapcore.InfoLevel)
+	zl.Infow(msg, keysAndValues...)
 }
 
 func (l *ZapLogger) Warn(ctx context.Context, msg string, err error, keysAndValues ...any) {
-	if l.level > zapcore.WarnLevel {
+	zl := l.loggerFor(ctx, zapcore.WarnLevel)
+	if zl == nil {
 		return
 	}
 	keysAndValues = l.kvAppend(ctx, appendError(keysAndValues, err))
-	l.zap.Warnw(msg, keysAndValues...)
+	l.metrics.inc(zapcore.WarnLevel)
+	zl.Warnw(msg, keysAndValues...)
 }
 
 func (l *ZapLogger) Error(ctx context.Context, msg string, err error, keysAndValues ...any) {
-	if l.level > zapcore.ErrorLevel {
+	if a := aggregator.Load(); a != nil {
+		a.Record(msg, err)
+	}
+	runErrorHooks(ctx, msg, err)
+	if c := tenantFor(ctx); c != nil && c.hook != nil {
+		c.hook(ctx, msg, err)
+	}
+	zl := l.loggerFor(ctx, zapcore.ErrorLevel)
+	if zl == nil {
 		return
 	}
 	keysAndValues = l.kvAppend(ctx, appendError(keysAndValues, err))
-	l.zap.Errorw(msg, keysAndValues...)
+	l.metrics.inc(zapcore.ErrorLevel)
+	zl.Errorw(msg, keysAndValues...)
 }
 
 func (l *ZapLogger) Panic(ctx context.Context, msg string, err error, keysAndValues ...any) {
-	if l.level > zapcore.PanicLevel {
+	zl := l.loggerFor(ctx, zapcore.PanicLevel)
+	if zl == nil {
 		return
 	}
 	keysAndValues = l.kvAppend(ctx, appendError(keysAndValues, err))
-	l.zap.Panicw(msg, keysAndValues...)
+	l.metrics.inc(zapcore.PanicLevel)
+	zl.Panicw(msg, keysAndValues...)
 }
 
 func (l *ZapLogger) kvAppend(ctx context.Context, keysAndValues []any) []any {
@@ -467,6 +530,8 @@ func (l *ZapLogger) kvAppend(ctx context.Context, keysAndValues []any) []any {
 	triggerID := mcontext.GetTriggerID(ctx)
 	opUserPlatform := mcontext.GetOpUserPlatform(ctx)
 	remoteAddr := mcontext.GetRemoteAddr(ctx)
+	workerID := mcontext.GetWorkerID(ctx)
+	tenantID := mcontext.GetTenantID(ctx)
 
 	if l.isSimplify {
 		if len(keysAndValues)%2 == 0 {
@@ -483,6 +548,9 @@ func (l *ZapLogger) kvAppend(ctx context.Context, keysAndValues []any) []any {
 	if opUserID != "" {
 		keysAndValues = append([]any{constant.OpUserID, opUserID}, keysAndValues...)
 	}
+	if workerID != "" {
+		keysAndValues = append([]any{mcontext.WorkerID, workerID}, keysAndValues...)
+	}
 	if operationID != "" {
 		keysAndValues = append([]any{constant.OperationID, operationID}, keysAndValues...)
 	}
@@ -498,6 +566,9 @@ func (l *ZapLogger) kvAppend(ctx context.Context, keysAndValues []any) []any {
 	if remoteAddr != "" {
 		keysAndValues = append([]any{constant.RemoteAddr, remoteAddr}, keysAndValues...)
 	}
+	if tenantID != "" {
+		keysAndValues = append([]any{mcontext.TenantID, tenantID}, keysAndValues...)
+	}
 	return keysAndValues
 }
 
@@ -16,11 +16,29 @@ package mcontext
 
 import (
 	"context"
+	"time"
 
 	"github.com/openimsdk/protocol/constant"
 	"github.com/openimsdk/tools/errs"
 )
 
+// WorkerID is the context key carrying the identity of a background worker.
+// It is propagated to downstream RPCs alongside the operationID.
+const WorkerID = "workerID"
+
+// OpUserRoles is the context key carrying the roles of the operating user, as
+// a []string. It is propagated to downstream RPCs and used to filter response
+// fields, see apiresp.FilterFields.
+const OpUserRoles = "opUserRoles"
+
+// TenantID is the context key carrying the tenant app a request belongs to,
+// on clusters shared by several tenants. It is propagated to downstream RPCs
+// and added to every log entry.
+const TenantID = "tenantID"
+
+// AppID is the context key carrying the identifier of the calling application.
+const AppID = "appID"
+
 var mapper = []string{constant.OperationID, constant.OpUserID, constant.OpUserPlatform, constant.ConnID}
 
 func WithOpUserIDContext(ctx context.Context, opUserID string) context.Context {
@@ -53,6 +71,37 @@ func SetConnID(ctx context.Context, connID string) context.Context {
 	return context.WithValue(ctx, constant.ConnID, connID)
 }
 
+func SetWorkerID(ctx context.Context, workerID string) context.Context {
+	return context.WithValue(ctx, WorkerID, workerID)
+}
+
+func SetOpUserRoles(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, OpUserRoles, roles)
+}
+
+func GetOpUserRoles(ctx context.Context) []string {
+	roles, _ := ctx.Value(OpUserRoles).([]string)
+	return roles
+}
+
+func SetTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, TenantID, tenantID)
+}
+
+func GetTenantID(ctx context.Context) string {
+	tenantID, _ := ctx.Value(TenantID).(string)
+	return tenantID
+}
+
+func SetAppID(ctx context.Context, appID string) context.Context {
+	return context.WithValue(ctx, AppID, appID)
+}
+
+func GetAppID(ctx context.Context) string {
+	appID, _ := ctx.Value(AppID).(string)
+	return appID
+}
+
 func GetOperationID(ctx context.Context) string {
 	if ctx.Value(constant.OperationID) != nil {
 		s, ok := ctx.Value(constant.OperationID).(string)
@@ -113,6 +162,16 @@ func GetRemoteAddr(ctx context.Context) string {
 	return ""
 }
 
+func GetWorkerID(ctx context.Context) string {
+	if ctx.Value(WorkerID) != nil {
+		s, ok := ctx.Value(WorkerID).(string)
+		if ok {
+			return s
+		}
+	}
+	return ""
+}
+
 func GetMustCtxInfo(ctx context.Context) (operationID, opUserID, platform, connID string, err error) {
 	operationID, ok := ctx.Value(constant.OperationID).(string)
 	if !ok {
@@ -152,3 +211,15 @@ func WithMustInfoCtx(values []string) context.Context {
 	}
 	return ctx
 }
+
+// WithBudget attaches a new errs.Budget to ctx, starting now, for RecordPhase
+// and errs.WrapDeadline.
+func WithBudget(ctx context.Context) context.Context {
+	return errs.WithBudget(ctx, errs.NewBudget())
+}
+
+// RecordPhase adds d to the phase name of the budget of ctx, see WithBudget.
+// It does nothing when ctx has no budget.
+func RecordPhase(ctx context.Context, name string, d time.Duration) {
+	errs.GetBudget(ctx).Record(name, d)
+}
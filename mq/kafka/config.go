@@ -14,6 +14,11 @@
 
 package kafka
 
+import (
+	"github.com/openimsdk/tools/env"
+	"github.com/openimsdk/tools/errs"
+)
+
 type TLSConfig struct {
 	EnableTLS          bool   `yaml:"enableTLS"`
 	CACrt              string `yaml:"caCrt"`
@@ -24,10 +29,27 @@ type TLSConfig struct {
 }
 
 type Config struct {
-	Username     string    `yaml:"username"`
-	Password     string    `yaml:"password"`
-	ProducerAck  string    `yaml:"producerAck"`
-	CompressType string    `yaml:"compressType"`
-	Addr         []string  `yaml:"addr"`
-	TLS          TLSConfig `yaml:"tls"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// SASLMechanism is the SASL mechanism used with Username and Password:
+	// PLAIN, the default, SCRAM-SHA-256 or SCRAM-SHA-512.
+	SASLMechanism string    `yaml:"saslMechanism"`
+	ProducerAck   string    `yaml:"producerAck"`
+	CompressType  string    `yaml:"compressType"`
+	Addr          []string  `yaml:"addr"`
+	TLS           TLSConfig `yaml:"tls"`
+	// MinVersion is the oldest Kafka release accepted by CheckMinVersion,
+	// DefaultMinVersion when empty.
+	MinVersion string `yaml:"minVersion"`
+}
+
+// ApplyEnv enables TLS when the KAFKA_TLS environment variable is true, and
+// disables it when false.
+func (c *Config) ApplyEnv() error {
+	enable, err := env.GetBool("KAFKA_TLS", c.TLS.EnableTLS)
+	if err != nil {
+		return errs.ErrConfig.WrapMsg("invalid KAFKA_TLS", "err", errs.Unwrap(err).Error())
+	}
+	c.TLS.EnableTLS = enable
+	return nil
 }
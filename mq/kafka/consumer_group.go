@@ -24,11 +24,34 @@ import (
 
 type MConsumerGroup struct {
 	sarama.ConsumerGroup
-	groupID string
-	topics  []string
+	groupID    string
+	topics     []string
+	keys       KeyProvider
+	deadLetter DeadLetterFunc
 }
 
-func NewMConsumerGroup(conf *Config, groupID string, topics []string, autoCommitEnable bool) (*MConsumerGroup, error) {
+type ConsumerOption func(*MConsumerGroup)
+
+// WithPayloadDecryption decrypts the payloads encrypted by a producer
+// created WithPayloadEncryption before they reach the handler. Plaintext
+// messages are passed through unchanged. A message whose key cannot be
+// resolved holds up its partition, retried until the key resolves.
+func WithPayloadDecryption(keys KeyProvider) ConsumerOption {
+	return func(mc *MConsumerGroup) {
+		mc.keys = keys
+	}
+}
+
+// WithDeadLetter routes messages that cannot be handed to the handler, such
+// as the ones whose payload fails decryption, to fn. Without it they are
+// logged and skipped.
+func WithDeadLetter(fn DeadLetterFunc) ConsumerOption {
+	return func(mc *MConsumerGroup) {
+		mc.deadLetter = fn
+	}
+}
+
+func NewMConsumerGroup(conf *Config, groupID string, topics []string, autoCommitEnable bool, opts ...ConsumerOption) (*MConsumerGroup, error) {
 	config, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, autoCommitEnable)
 	if err != nil {
 		return nil, err
@@ -37,11 +60,15 @@ func NewMConsumerGroup(conf *Config, groupID string, topics []string, autoCommit
 	if err != nil {
 		return nil, err
 	}
-	return &MConsumerGroup{
+	mc := &MConsumerGroup{
 		ConsumerGroup: group,
 		groupID:       groupID,
 		topics:        topics,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(mc)
+	}
+	return mc, nil
 }
 
 func (mc *MConsumerGroup) GetContextFromMsg(cMsg *sarama.ConsumerMessage) context.Context {
@@ -49,6 +76,9 @@ func (mc *MConsumerGroup) GetContextFromMsg(cMsg *sarama.ConsumerMessage) contex
 }
 
 func (mc *MConsumerGroup) RegisterHandleAndConsumer(ctx context.Context, handler sarama.ConsumerGroupHandler) {
+	if mc.keys != nil {
+		handler = &decryptHandler{ConsumerGroupHandler: handler, keys: mc.keys, deadLetter: mc.deadLetter}
+	}
 	for {
 		err := mc.ConsumerGroup.Consume(ctx, mc.topics, handler)
 		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
@@ -0,0 +1,457 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"google.golang.org/protobuf/proto"
+)
+
+// Headers carried by the messages of the delay tiers.
+const (
+	HeaderDelayTarget = "openim-delay-target"
+	HeaderDelayDue    = "openim-delay-due"
+	HeaderDelayWake   = "openim-delay-wake"
+)
+
+// DelayTier is an internal topic holding messages for about Delay before they
+// move to a lower tier or to their target topic.
+type DelayTier struct {
+	Topic string
+	Delay time.Duration
+}
+
+// DefaultDelayTiers are the tiers used by SendDelayed and DelayRelay unless
+// configured otherwise. Both sides must use the same tiers.
+var DefaultDelayTiers = []DelayTier{
+	{Topic: "openim-delay-5s", Delay: 5 * time.Second},
+	{Topic: "openim-delay-1m", Delay: time.Minute},
+	{Topic: "openim-delay-10m", Delay: 10 * time.Minute},
+	{Topic: "openim-delay-1h", Delay: time.Hour},
+}
+
+// WithDelayTiers sets the tiers SendDelayed writes to.
+func WithDelayTiers(tiers ...DelayTier) ProducerOption {
+	return func(p *Producer) {
+		p.delayTiers = sortTiers(tiers)
+	}
+}
+
+func sortTiers(tiers []DelayTier) []DelayTier {
+	sorted := append([]DelayTier(nil), tiers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Delay < sorted[j].Delay })
+	return sorted
+}
+
+// selectTier returns the tier a message due in remaining is written to and
+// the time the relay acts on it: the largest tier not longer than remaining,
+// so that each hop parks a message for at most the delay of its tier.
+func selectTier(tiers []DelayTier, now time.Time, remaining time.Duration) (DelayTier, time.Time) {
+	tier := tiers[0]
+	for _, t := range tiers[1:] {
+		if t.Delay <= remaining {
+			tier = t
+		}
+	}
+	return tier, now.Add(min(tier.Delay, remaining))
+}
+
+// SendDelayed sends msg to topic once delay has elapsed, through the delay
+// tiers forwarded by a DelayRelay. A non positive delay sends it right away.
+// The payload is encrypted for topic, not for the tier.
+func (p *Producer) SendDelayed(ctx context.Context, topic string, key string, msg proto.Message, delay time.Duration) (int32, int64, error) {
+	kMsg, err := p.buildMessage(ctx, topic, key, msg)
+	if err != nil {
+		return 0, 0, err
+	}
+	if delay > 0 {
+		tiers := p.delayTiers
+		if len(tiers) == 0 {
+			tiers = DefaultDelayTiers
+		}
+		now := time.Now()
+		tier, wake := selectTier(tiers, now, delay)
+		kMsg.Topic = tier.Topic
+		kMsg.Headers = append(kMsg.Headers,
+			sarama.RecordHeader{Key: []byte(HeaderDelayTarget), Value: []byte(topic)},
+			sarama.RecordHeader{Key: []byte(HeaderDelayDue), Value: formatMillis(now.Add(delay))},
+			sarama.RecordHeader{Key: []byte(HeaderDelayWake), Value: formatMillis(wake)},
+		)
+	}
+	return p.send(kMsg)
+}
+
+func formatMillis(t time.Time) []byte {
+	return strconv.AppendInt(nil, t.UnixMilli(), 10)
+}
+
+type DelayRelayOption func(*DelayRelay)
+
+// WithRelayTiers sets the tiers the relay consumes, see WithDelayTiers.
+func WithRelayTiers(tiers ...DelayTier) DelayRelayOption {
+	return func(r *DelayRelay) {
+		r.tiers = sortTiers(tiers)
+	}
+}
+
+// WithRelayTolerance forwards messages up to early before they are due and
+// reports the ones forwarded more than late after it. Both default to 100ms.
+func WithRelayTolerance(early, late time.Duration) DelayRelayOption {
+	return func(r *DelayRelay) {
+		r.maxEarly = early
+		r.maxLate = late
+	}
+}
+
+// WithRelayMaxParked sets how many messages a partition parks in memory before
+// it is paused. It defaults to 10000.
+func WithRelayMaxParked(n int) DelayRelayOption {
+	return func(r *DelayRelay) {
+		r.maxParked = n
+	}
+}
+
+// WithRelayClock replaces time.Now, for tests.
+func WithRelayClock(now func() time.Time) DelayRelayOption {
+	return func(r *DelayRelay) {
+		r.now = now
+	}
+}
+
+// DelayRelay consumes the delay tiers, parks messages until they are due and
+// forwards them to their target topic, or to a lower tier when they are due
+// later than the delay of their tier. The offset of a message is committed
+// only once it has been forwarded, so parked messages are consumed again
+// after a restart: delivery is at least once.
+type DelayRelay struct {
+	group    sarama.ConsumerGroup
+	producer sarama.SyncProducer
+	groupID  string
+	tiers    []DelayTier
+
+	now       func() time.Time
+	poll      time.Duration
+	maxEarly  time.Duration
+	maxLate   time.Duration
+	maxParked int
+
+	forwarded atomic.Int64
+	late      atomic.Int64
+}
+
+func NewDelayRelay(conf *Config, groupID string, opts ...DelayRelayOption) (*DelayRelay, error) {
+	producerConf, err := BuildProducerConfig(*conf)
+	if err != nil {
+		return nil, err
+	}
+	producer, err := NewProducer(producerConf, conf.Addr)
+	if err != nil {
+		return nil, err
+	}
+	config, err := BuildConsumerGroupConfig(conf, sarama.OffsetOldest, true)
+	if err != nil {
+		_ = producer.Close()
+		return nil, err
+	}
+	group, err := NewConsumerGroup(config, conf.Addr, groupID)
+	if err != nil {
+		_ = producer.Close()
+		return nil, err
+	}
+	return newDelayRelay(group, producer, groupID, opts...), nil
+}
+
+func newDelayRelay(group sarama.ConsumerGroup, producer sarama.SyncProducer, groupID string, opts ...DelayRelayOption) *DelayRelay {
+	r := &DelayRelay{
+		group:     group,
+		producer:  producer,
+		groupID:   groupID,
+		tiers:     DefaultDelayTiers,
+		now:       time.Now,
+		poll:      100 * time.Millisecond,
+		maxEarly:  100 * time.Millisecond,
+		maxLate:   100 * time.Millisecond,
+		maxParked: 10000,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Forwarded returns how many messages reached their target topic.
+func (r *DelayRelay) Forwarded() int64 {
+	return r.forwarded.Load()
+}
+
+// Late returns how many messages reached their target topic later than the
+// late tolerance.
+func (r *DelayRelay) Late() int64 {
+	return r.late.Load()
+}
+
+// Run consumes the delay tiers until ctx is done or the relay is closed.
+func (r *DelayRelay) Run(ctx context.Context) {
+	topics := make([]string, 0, len(r.tiers))
+	for _, tier := range r.tiers {
+		topics = append(topics, tier.Topic)
+	}
+	h := &delayHandler{relay: r}
+	for {
+		err := r.group.Consume(ctx, topics, h)
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			return
+		}
+		if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.ZWarn(ctx, "delay relay consume err", err, "topic", topics, "groupID", r.groupID)
+		}
+	}
+}
+
+func (r *DelayRelay) Close() error {
+	err := r.group.Close()
+	if pErr := r.producer.Close(); err == nil {
+		err = pErr
+	}
+	return err
+}
+
+type delayHandler struct {
+	relay *DelayRelay
+}
+
+func (h *delayHandler) Setup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *delayHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *delayHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	r := h.relay
+	topic, partition := claim.Topic(), claim.Partition()
+	p := &parkedPartition{next: -1}
+	paused := false
+	defer func() {
+		if paused {
+			r.resume(topic, partition)
+		}
+	}()
+	ticker := time.NewTicker(r.poll)
+	defer ticker.Stop()
+	msgs := claim.Messages()
+	for {
+		in := msgs
+		if p.Len() >= r.maxParked {
+			// Stop reading the claim and let the other partitions go on.
+			in = nil
+			if !paused {
+				r.pause(topic, partition)
+				paused = true
+			}
+		} else if paused {
+			r.resume(topic, partition)
+			paused = false
+		}
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				// Parked messages are left uncommitted and consumed again
+				// by the next owner of the partition.
+				return nil
+			}
+			r.park(session.Context(), p, msg)
+		case <-ticker.C:
+		case <-session.Context().Done():
+			return nil
+		}
+		r.flush(session.Context(), p)
+		if offset := p.committable(); offset >= 0 {
+			session.MarkOffset(topic, partition, offset, "")
+		}
+	}
+}
+
+func (r *DelayRelay) pause(topic string, partition int32) {
+	if r.group != nil {
+		r.group.Pause(map[string][]int32{topic: {partition}})
+	}
+}
+
+func (r *DelayRelay) resume(topic string, partition int32) {
+	if r.group != nil {
+		r.group.Resume(map[string][]int32{topic: {partition}})
+	}
+}
+
+func (r *DelayRelay) park(ctx context.Context, p *parkedPartition, msg *sarama.ConsumerMessage) {
+	p.next = msg.Offset + 1
+	target := headerValue(msg.Headers, HeaderDelayTarget)
+	due, dueErr := strconv.ParseInt(headerValue(msg.Headers, HeaderDelayDue), 10, 64)
+	wake, wakeErr := strconv.ParseInt(headerValue(msg.Headers, HeaderDelayWake), 10, 64)
+	if target == "" || dueErr != nil || wakeErr != nil {
+		log.ZWarn(ctx, "delay relay dropped message without schedule", nil, "topic", msg.Topic,
+			"partition", msg.Partition, "offset", msg.Offset)
+		return
+	}
+	p.push(&parkedMessage{
+		msg:    msg,
+		target: target,
+		due:    time.UnixMilli(due),
+		wake:   time.UnixMilli(wake),
+	})
+}
+
+// flush forwards the parked messages whose wake time has come. A message that
+// fails to be sent stays parked and is retried on the next tick.
+func (r *DelayRelay) flush(ctx context.Context, p *parkedPartition) {
+	for p.Len() > 0 {
+		m := p.peek()
+		now := r.now()
+		if now.Before(m.wake.Add(-r.maxEarly)) {
+			return
+		}
+		remaining := m.due.Sub(now)
+		var err error
+		if remaining <= r.maxEarly {
+			err = r.forward(m)
+			if err == nil {
+				r.forwarded.Add(1)
+				if lateness := -remaining; lateness > r.maxLate {
+					r.late.Add(1)
+					log.ZWarn(ctx, "delayed message forwarded late", nil, "target", m.target, "late", lateness)
+				}
+			}
+		} else {
+			err = r.reschedule(m, now, remaining)
+		}
+		if err != nil {
+			log.ZWarn(ctx, "delay relay forward failed", err, "target", m.target, "offset", m.msg.Offset)
+			return
+		}
+		p.pop()
+	}
+}
+
+func (r *DelayRelay) forward(m *parkedMessage) error {
+	_, _, err := r.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   m.target,
+		Key:     sarama.ByteEncoder(m.msg.Key),
+		Value:   sarama.ByteEncoder(m.msg.Value),
+		Headers: copyHeaders(m.msg.Headers, nil),
+	})
+	return errs.WrapMsg(err, "forward delayed message failed", "target", m.target)
+}
+
+func (r *DelayRelay) reschedule(m *parkedMessage, now time.Time, remaining time.Duration) error {
+	tier, wake := selectTier(r.tiers, now, remaining)
+	headers := copyHeaders(m.msg.Headers, []sarama.RecordHeader{
+		{Key: []byte(HeaderDelayTarget), Value: []byte(m.target)},
+		{Key: []byte(HeaderDelayDue), Value: formatMillis(m.due)},
+		{Key: []byte(HeaderDelayWake), Value: formatMillis(wake)},
+	})
+	_, _, err := r.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   tier.Topic,
+		Key:     sarama.ByteEncoder(m.msg.Key),
+		Value:   sarama.ByteEncoder(m.msg.Value),
+		Headers: headers,
+	})
+	return errs.WrapMsg(err, "reschedule delayed message failed", "tier", tier.Topic)
+}
+
+func headerValue(headers []*sarama.RecordHeader, key string) string {
+	for _, h := range headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// copyHeaders returns the headers without the delay ones, followed by extra.
+func copyHeaders(headers []*sarama.RecordHeader, extra []sarama.RecordHeader) []sarama.RecordHeader {
+	res := make([]sarama.RecordHeader, 0, len(headers)+len(extra))
+	for _, h := range headers {
+		switch string(h.Key) {
+		case HeaderDelayTarget, HeaderDelayDue, HeaderDelayWake:
+			continue
+		}
+		res = append(res, *h)
+	}
+	return append(res, extra...)
+}
+
+type parkedMessage struct {
+	msg    *sarama.ConsumerMessage
+	target string
+	due    time.Time
+	wake   time.Time
+}
+
+// parkedPartition holds the messages of a partition waiting for their wake
+// time, ordered by it, and the offsets they keep uncommitted.
+type parkedPartition struct {
+	byWake  []*parkedMessage
+	offsets []int64 // ascending: messages are parked in offset order
+	next    int64
+}
+
+func (p *parkedPartition) Len() int { return len(p.byWake) }
+
+func (p *parkedPartition) Less(i, j int) bool { return p.byWake[i].wake.Before(p.byWake[j].wake) }
+
+func (p *parkedPartition) Swap(i, j int) { p.byWake[i], p.byWake[j] = p.byWake[j], p.byWake[i] }
+
+func (p *parkedPartition) Push(x any) { p.byWake = append(p.byWake, x.(*parkedMessage)) }
+
+func (p *parkedPartition) Pop() any {
+	n := len(p.byWake)
+	m := p.byWake[n-1]
+	p.byWake = p.byWake[:n-1]
+	return m
+}
+
+func (p *parkedPartition) push(m *parkedMessage) {
+	heap.Push(p, m)
+	p.offsets = append(p.offsets, m.msg.Offset)
+}
+
+func (p *parkedPartition) peek() *parkedMessage { return p.byWake[0] }
+
+func (p *parkedPartition) pop() {
+	m := heap.Pop(p).(*parkedMessage)
+	i := sort.Search(len(p.offsets), func(i int) bool { return p.offsets[i] >= m.msg.Offset })
+	p.offsets = append(p.offsets[:i], p.offsets[i+1:]...)
+}
+
+// committable returns the offset to commit: the oldest parked message, or
+// the one after the last consumed when none is parked. It is -1 before the
+// first message.
+func (p *parkedPartition) committable() int64 {
+	if len(p.offsets) > 0 {
+		return p.offsets[0]
+	}
+	return p.next
+}
@@ -0,0 +1,333 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/protocol/sdkws"
+	"github.com/openimsdk/tools/mcontext"
+)
+
+type recordProducer struct {
+	sarama.SyncProducer
+	lock sync.Mutex
+	sent []*sarama.ProducerMessage
+}
+
+func (p *recordProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.sent = append(p.sent, msg)
+	return 0, int64(len(p.sent) - 1), nil
+}
+
+func (p *recordProducer) messages() []*sarama.ProducerMessage {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]*sarama.ProducerMessage(nil), p.sent...)
+}
+
+type fakeClock struct{ nanos atomic.Int64 }
+
+func (c *fakeClock) Now() time.Time { return time.Unix(0, c.nanos.Load()) }
+
+func (c *fakeClock) Set(t time.Time) { c.nanos.Store(t.UnixNano()) }
+
+type relaySession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked atomic.Int64
+}
+
+func (s *relaySession) Context() context.Context { return s.ctx }
+
+func (s *relaySession) MarkOffset(_ string, _ int32, offset int64, _ string) {
+	s.marked.Store(offset)
+}
+
+type tierClaim struct {
+	fakeClaim
+	topic string
+}
+
+func (c *tierClaim) Topic() string { return c.topic }
+
+func (c *tierClaim) Partition() int32 { return 0 }
+
+type pauseGroup struct {
+	sarama.ConsumerGroup
+	paused atomic.Int64
+	resume atomic.Int64
+}
+
+func (g *pauseGroup) Pause(map[string][]int32) { g.paused.Add(1) }
+
+func (g *pauseGroup) Resume(map[string][]int32) { g.resume.Add(1) }
+
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// scheduled builds a tier record as written by SendDelayed.
+func scheduled(offset int64, tier string, due, wake time.Time) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{
+		Topic:  tier,
+		Offset: offset,
+		Key:    []byte("k" + strconv.FormatInt(offset, 10)),
+		Value:  []byte("v"),
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte(constant.OperationID), Value: []byte("op")},
+			{Key: []byte(HeaderDelayTarget), Value: []byte("push")},
+			{Key: []byte(HeaderDelayDue), Value: formatMillis(due)},
+			{Key: []byte(HeaderDelayWake), Value: formatMillis(wake)},
+		},
+	}
+}
+
+type relayRun struct {
+	claim   *tierClaim
+	session *relaySession
+	done    chan struct{}
+}
+
+func startRelay(r *DelayRelay, tier string, msgs ...*sarama.ConsumerMessage) *relayRun {
+	run := &relayRun{
+		claim:   &tierClaim{fakeClaim: fakeClaim{msgs: make(chan *sarama.ConsumerMessage, len(msgs))}, topic: tier},
+		session: &relaySession{ctx: context.Background()},
+		done:    make(chan struct{}),
+	}
+	run.session.marked.Store(-1)
+	for _, msg := range msgs {
+		run.claim.msgs <- msg
+	}
+	go func() {
+		defer close(run.done)
+		_ = (&delayHandler{relay: r}).ConsumeClaim(run.session, run.claim)
+	}()
+	return run
+}
+
+func (run *relayRun) stop() {
+	close(run.claim.msgs)
+	<-run.done
+}
+
+func newTestRelay(clock *fakeClock, producer *recordProducer, opts ...DelayRelayOption) *DelayRelay {
+	r := newDelayRelay(nil, producer, "delay", append([]DelayRelayOption{WithRelayClock(clock.Now)}, opts...)...)
+	r.poll = time.Millisecond
+	return r
+}
+
+func TestSelectTier(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cases := []struct {
+		delay time.Duration
+		topic string
+		wake  time.Duration
+	}{
+		{2 * time.Second, "openim-delay-5s", 2 * time.Second},
+		{30 * time.Second, "openim-delay-5s", 5 * time.Second},
+		{time.Minute, "openim-delay-1m", time.Minute},
+		{30 * time.Minute, "openim-delay-10m", 10 * time.Minute},
+		{3 * time.Hour, "openim-delay-1h", time.Hour},
+	}
+	for _, c := range cases {
+		tier, wake := selectTier(DefaultDelayTiers, now, c.delay)
+		if tier.Topic != c.topic || wake.Sub(now) != c.wake {
+			t.Errorf("delay %s: tier %s wake +%s, want %s +%s", c.delay, tier.Topic, wake.Sub(now), c.topic, c.wake)
+		}
+	}
+}
+
+func TestSendDelayed(t *testing.T) {
+	rec := &recordProducer{}
+	p := &Producer{topic: "msg", producer: rec}
+	ctx := mcontext.NewCtx("op1")
+	before := time.Now()
+	if _, _, err := p.SendDelayed(ctx, "push", "key", &sdkws.MsgData{Content: []byte("later")}, 30*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.SendDelayed(ctx, "push", "key", &sdkws.MsgData{Content: []byte("now")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	sent := rec.messages()
+	if sent[0].Topic != "openim-delay-10m" || sent[1].Topic != "push" {
+		t.Fatalf("topics = %s, %s", sent[0].Topic, sent[1].Topic)
+	}
+	headers := make(map[string]string)
+	for _, h := range sent[0].Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	due, _ := strconv.ParseInt(headers[HeaderDelayDue], 10, 64)
+	wake, _ := strconv.ParseInt(headers[HeaderDelayWake], 10, 64)
+	if headers[HeaderDelayTarget] != "push" || headers[constant.OperationID] != "op1" {
+		t.Fatalf("headers = %v", headers)
+	}
+	if d := time.UnixMilli(due).Sub(before); d < 30*time.Minute-time.Second || d > 30*time.Minute+time.Second {
+		t.Errorf("due in %s, want 30m", d)
+	}
+	if d := time.UnixMilli(due).Sub(time.UnixMilli(wake)); d != 20*time.Minute {
+		t.Errorf("wake %s before due, want 20m", d)
+	}
+	for _, h := range sent[1].Headers {
+		if string(h.Key) == HeaderDelayTarget {
+			t.Error("immediate message carries delay headers")
+		}
+	}
+}
+
+func TestDelayRelayForwardsOnTime(t *testing.T) {
+	clock := &fakeClock{}
+	t0 := time.Unix(1000, 0)
+	clock.Set(t0)
+	rec := &recordProducer{}
+	r := newTestRelay(clock, rec)
+	tier := "openim-delay-5s"
+	run := startRelay(r, tier,
+		scheduled(0, tier, t0.Add(4*time.Second), t0.Add(4*time.Second)),
+		scheduled(1, tier, t0.Add(2*time.Second), t0.Add(2*time.Second)),
+		scheduled(2, tier, t0.Add(5*time.Second), t0.Add(5*time.Second)),
+	)
+	defer run.stop()
+	waitUntil(t, "messages parked", func() bool { return run.session.marked.Load() == 0 })
+	if len(rec.messages()) != 0 {
+		t.Fatal("forwarded before due")
+	}
+
+	// Within the early tolerance.
+	clock.Set(t0.Add(2*time.Second - 50*time.Millisecond))
+	waitUntil(t, "first forward", func() bool { return len(rec.messages()) == 1 })
+	if key := string(rec.messages()[0].Key.(sarama.ByteEncoder)); key != "k1" {
+		t.Fatalf("forwarded %s first, want k1", key)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if m := run.session.marked.Load(); m != 0 {
+		t.Fatalf("marked %d while offset 0 is parked", m)
+	}
+
+	clock.Set(t0.Add(4 * time.Second))
+	waitUntil(t, "second forward", func() bool { return run.session.marked.Load() == 2 })
+	clock.Set(t0.Add(5 * time.Second))
+	waitUntil(t, "all forwarded", func() bool { return run.session.marked.Load() == 3 })
+	sent := rec.messages()
+	if len(sent) != 3 || string(sent[1].Key.(sarama.ByteEncoder)) != "k0" {
+		t.Fatalf("forwarded %d messages", len(sent))
+	}
+	for _, msg := range sent {
+		if msg.Topic != "push" {
+			t.Errorf("forwarded to %s", msg.Topic)
+		}
+		if len(msg.Headers) != 1 || string(msg.Headers[0].Key) != constant.OperationID {
+			t.Errorf("forwarded headers = %v", msg.Headers)
+		}
+	}
+	if r.Forwarded() != 3 || r.Late() != 0 {
+		t.Errorf("forwarded %d, late %d", r.Forwarded(), r.Late())
+	}
+}
+
+func TestDelayRelayCascades(t *testing.T) {
+	clock := &fakeClock{}
+	t0 := time.Unix(1000, 0)
+	clock.Set(t0)
+	rec := &recordProducer{}
+	r := newTestRelay(clock, rec)
+	tier := "openim-delay-10m"
+	run := startRelay(r, tier, scheduled(0, tier, t0.Add(25*time.Minute), t0.Add(10*time.Minute)))
+	defer run.stop()
+
+	clock.Set(t0.Add(10 * time.Minute))
+	waitUntil(t, "reschedule", func() bool { return len(rec.messages()) == 1 })
+	msg := rec.messages()[0]
+	headers := make(map[string]string)
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	if msg.Topic != tier || headers[HeaderDelayTarget] != "push" ||
+		headers[HeaderDelayDue] != string(formatMillis(t0.Add(25*time.Minute))) ||
+		headers[HeaderDelayWake] != string(formatMillis(t0.Add(20*time.Minute))) {
+		t.Fatalf("rescheduled to %s with %v", msg.Topic, headers)
+	}
+	if r.Forwarded() != 0 {
+		t.Fatal("intermediate hop counted as forwarded")
+	}
+}
+
+func TestDelayRelayRestartRecovery(t *testing.T) {
+	clock := &fakeClock{}
+	t0 := time.Unix(1000, 0)
+	clock.Set(t0)
+	tier := "openim-delay-1m"
+	msgs := []*sarama.ConsumerMessage{
+		scheduled(0, tier, t0.Add(time.Minute), t0.Add(time.Minute)),
+		scheduled(1, tier, t0.Add(2*time.Second), t0.Add(2*time.Second)),
+	}
+
+	rec := &recordProducer{}
+	run := startRelay(newTestRelay(clock, rec), tier, msgs...)
+	clock.Set(t0.Add(2 * time.Second))
+	waitUntil(t, "due message", func() bool { return len(rec.messages()) == 1 })
+	run.stop()
+	committed := run.session.marked.Load()
+	if committed != 0 {
+		t.Fatalf("committed %d past a parked message", committed)
+	}
+
+	// The next owner of the partition starts from the committed offset.
+	rec2 := &recordProducer{}
+	run2 := startRelay(newTestRelay(clock, rec2), tier, msgs[committed:]...)
+	defer run2.stop()
+	clock.Set(t0.Add(time.Minute))
+	waitUntil(t, "recovered messages", func() bool { return run2.session.marked.Load() == 2 })
+	var recovered bool
+	for _, msg := range rec2.messages() {
+		if string(msg.Key.(sarama.ByteEncoder)) == "k0" {
+			recovered = true
+		}
+	}
+	if !recovered {
+		t.Fatal("parked message lost across restart")
+	}
+}
+
+func TestDelayRelayPausesFullPartition(t *testing.T) {
+	clock := &fakeClock{}
+	t0 := time.Unix(1000, 0)
+	clock.Set(t0)
+	rec := &recordProducer{}
+	r := newTestRelay(clock, rec, WithRelayMaxParked(1), WithRelayTolerance(0, time.Second))
+	group := &pauseGroup{}
+	r.group = group
+	tier := "openim-delay-5s"
+	run := startRelay(r, tier,
+		scheduled(0, tier, t0.Add(time.Second), t0.Add(time.Second)),
+		scheduled(1, tier, t0.Add(time.Second), t0.Add(time.Second)),
+	)
+	defer run.stop()
+	waitUntil(t, "pause", func() bool { return group.paused.Load() == 1 })
+	if n := len(run.claim.msgs); n != 1 {
+		t.Fatalf("%d messages left in the claim, want 1", n)
+	}
+
+	clock.Set(t0.Add(3 * time.Second))
+	waitUntil(t, "drain", func() bool { return run.session.marked.Load() == 2 })
+	if group.resume.Load() == 0 {
+		t.Fatal("partition never resumed")
+	}
+	if r.Late() != 2 {
+		t.Errorf("late = %d, want 2", r.Late())
+	}
+}
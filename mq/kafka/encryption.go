@@ -0,0 +1,207 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/encrypt"
+)
+
+// HeaderKeyID is the record header carrying the ID of the key a payload was
+// encrypted with. Messages without it are treated as plaintext.
+const HeaderKeyID = "openim-enc-key-id"
+
+// ReasonDecryptFailed is the dead-letter reason of messages whose payload
+// fails authentication under their key.
+const ReasonDecryptFailed = "decrypt_failed"
+
+// KeyProvider supplies the AES keys used to encrypt message payloads.
+type KeyProvider interface {
+	// CurrentKey returns the key new messages of topic are encrypted with.
+	CurrentKey(ctx context.Context, topic string) (keyID string, key []byte, err error)
+	// Key resolves keyID for topic. It must keep resolving keys retired by a
+	// rotation for as long as messages encrypted with them may be consumed:
+	// consumers retry a message until its key resolves.
+	Key(ctx context.Context, topic string, keyID string) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider backed by a fixed key set. Rotating a key
+// means adding the new key to Keys and pointing Current or Default to it,
+// while keeping the old key until its messages have been consumed.
+type StaticKeyProvider struct {
+	// Keys maps key IDs to 16, 24 or 32 byte AES keys.
+	Keys map[string][]byte
+	// Current maps a topic to the ID of its encryption key.
+	Current map[string]string
+	// Default is the key ID of the topics not listed in Current.
+	Default string
+}
+
+func (p *StaticKeyProvider) CurrentKey(ctx context.Context, topic string) (string, []byte, error) {
+	keyID, ok := p.Current[topic]
+	if !ok {
+		keyID = p.Default
+	}
+	key, err := p.Key(ctx, topic, keyID)
+	if err != nil {
+		return "", nil, err
+	}
+	return keyID, key, nil
+}
+
+func (p *StaticKeyProvider) Key(_ context.Context, topic string, keyID string) ([]byte, error) {
+	key, ok := p.Keys[keyID]
+	if !ok {
+		return nil, errs.ErrRecordNotFound.WrapMsg("unknown encryption key", "topic", topic, "keyID", keyID)
+	}
+	return key, nil
+}
+
+// DeadLetterFunc receives the messages a consumer could not hand to its handler.
+type DeadLetterFunc func(ctx context.Context, msg *sarama.ConsumerMessage, reason string, err error)
+
+// encryptPayload encrypts value with the current key of topic and returns the
+// header that identifies the key.
+func encryptPayload(ctx context.Context, keys KeyProvider, topic string, value []byte) ([]byte, sarama.RecordHeader, error) {
+	keyID, key, err := keys.CurrentKey(ctx, topic)
+	if err != nil {
+		return nil, sarama.RecordHeader{}, err
+	}
+	data, err := encrypt.AesGcmEncrypt(value, key)
+	if err != nil {
+		return nil, sarama.RecordHeader{}, errs.WrapMsg(err, "encrypt kafka payload failed", "topic", topic, "keyID", keyID)
+	}
+	return data, sarama.RecordHeader{Key: []byte(HeaderKeyID), Value: []byte(keyID)}, nil
+}
+
+// DecryptMessage returns msg with its payload decrypted and the key header
+// removed. Messages without a key header are returned unchanged, so plaintext
+// messages produced before encryption was enabled keep being consumed.
+func DecryptMessage(ctx context.Context, keys KeyProvider, msg *sarama.ConsumerMessage) (*sarama.ConsumerMessage, error) {
+	idx := keyHeader(msg)
+	if idx < 0 {
+		return msg, nil
+	}
+	key, err := keys.Key(ctx, msg.Topic, string(msg.Headers[idx].Value))
+	if err != nil {
+		return nil, err
+	}
+	return openMessage(msg, idx, key)
+}
+
+// keyHeader returns the index of the key header of msg, -1 without one.
+func keyHeader(msg *sarama.ConsumerMessage) int {
+	for i, h := range msg.Headers {
+		if h != nil && string(h.Key) == HeaderKeyID {
+			return i
+		}
+	}
+	return -1
+}
+
+// openMessage decrypts msg with key, the one named by its header at idx.
+func openMessage(msg *sarama.ConsumerMessage, idx int, key []byte) (*sarama.ConsumerMessage, error) {
+	value, err := encrypt.AesGcmDecrypt(msg.Value, key)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "decrypt kafka payload failed", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "keyID", string(msg.Headers[idx].Value))
+	}
+	plain := *msg
+	plain.Value = value
+	plain.Headers = make([]*sarama.RecordHeader, 0, len(msg.Headers)-1)
+	plain.Headers = append(plain.Headers, msg.Headers[:idx]...)
+	plain.Headers = append(plain.Headers, msg.Headers[idx+1:]...)
+	return &plain, nil
+}
+
+// The wait between two attempts to resolve the key of a message, doubled
+// after every failure; variables for tests.
+var (
+	keyRetryMin = 100 * time.Millisecond
+	keyRetryMax = 10 * time.Second
+)
+
+// decryptHandler decrypts every claimed message before the wrapped handler sees it.
+type decryptHandler struct {
+	sarama.ConsumerGroupHandler
+	keys       KeyProvider
+	deadLetter DeadLetterFunc
+}
+
+func (h *decryptHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	return h.ConsumerGroupHandler.ConsumeClaim(session, h.wrapClaim(session.Context(), claim))
+}
+
+func (h *decryptHandler) wrapClaim(ctx context.Context, claim sarama.ConsumerGroupClaim) sarama.ConsumerGroupClaim {
+	msgs := make(chan *sarama.ConsumerMessage)
+	go func() {
+		defer close(msgs)
+		for msg := range claim.Messages() {
+			idx := keyHeader(msg)
+			plain := msg
+			if idx >= 0 {
+				key, ok := h.resolveKey(ctx, msg, string(msg.Headers[idx].Value))
+				if !ok {
+					// The session ended first: the message is not marked, so the
+					// next session consumes it again.
+					return
+				}
+				var err error
+				if plain, err = openMessage(msg, idx, key); err != nil {
+					mctx := GetContextWithMQHeader(msg.Headers)
+					if h.deadLetter == nil {
+						log.ZError(mctx, "drop undecryptable kafka message", err, "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
+					} else {
+						h.deadLetter(mctx, msg, ReasonDecryptFailed, err)
+					}
+					continue
+				}
+			}
+			select {
+			case msgs <- plain:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return &channelClaim{ConsumerGroupClaim: claim, msgs: msgs}
+}
+
+// resolveKey returns the key keyID of msg, retrying until ctx is done: unlike
+// a payload failing authentication, a key that cannot be resolved may be a
+// key store outage, and skipping the message would lose it.
+func (h *decryptHandler) resolveKey(ctx context.Context, msg *sarama.ConsumerMessage, keyID string) ([]byte, bool) {
+	wait := keyRetryMin
+	for {
+		key, err := h.keys.Key(ctx, msg.Topic, keyID)
+		if err == nil {
+			return key, true
+		}
+		log.ZWarn(ctx, "resolve kafka message key failed", err, "topic", msg.Topic, "partition", msg.Partition,
+			"offset", msg.Offset, "keyID", keyID, "retryIn", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, false
+		}
+		wait = min(wait*2, keyRetryMax)
+	}
+}
@@ -0,0 +1,174 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/openimsdk/protocol/sdkws"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mcontext"
+	"google.golang.org/protobuf/proto"
+)
+
+type collectHandler struct {
+	nopHandler
+	got []*sarama.ConsumerMessage
+}
+
+func (h *collectHandler) ConsumeClaim(_ sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		h.got = append(h.got, msg)
+	}
+	return nil
+}
+
+// produce sends msg through an encrypting producer and returns the record as a consumer would see it.
+func produce(t *testing.T, keys KeyProvider, msg proto.Message) *sarama.ConsumerMessage {
+	t.Helper()
+	var sent *sarama.ProducerMessage
+	sp := mocks.NewSyncProducer(t, nil)
+	sp.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(m *sarama.ProducerMessage) error {
+		sent = m
+		return nil
+	})
+	p := &Producer{topic: "msg", producer: sp}
+	WithPayloadEncryption(keys)(p)
+	if _, _, err := p.SendMessage(mcontext.NewCtx("op1"), "key", msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	value, err := sent.Value.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cMsg := &sarama.ConsumerMessage{Topic: sent.Topic, Value: value}
+	for i := range sent.Headers {
+		cMsg.Headers = append(cMsg.Headers, &sent.Headers[i])
+	}
+	return cMsg
+}
+
+func consume(ctx context.Context, h *decryptHandler, msgs ...*sarama.ConsumerMessage) []*sarama.ConsumerMessage {
+	collect := &collectHandler{}
+	h.ConsumerGroupHandler = collect
+	claim := &fakeClaim{msgs: make(chan *sarama.ConsumerMessage, len(msgs))}
+	for _, msg := range msgs {
+		claim.msgs <- msg
+	}
+	close(claim.msgs)
+	_ = h.ConsumeClaim(&fakeSession{ctx: ctx}, claim)
+	return collect.got
+}
+
+func TestPayloadEncryptionRoundTrip(t *testing.T) {
+	keys := &StaticKeyProvider{
+		Keys:    map[string][]byte{"k1": []byte("0123456789abcdef"), "k2": []byte("fedcba9876543210fedcba9876543210")},
+		Default: "k1",
+	}
+	old := produce(t, keys, &sdkws.MsgData{ClientMsgID: "client-msg-a"})
+	keys.Default = "k2"
+	rotated := produce(t, keys, &sdkws.MsgData{ClientMsgID: "client-msg-b"})
+
+	for _, m := range []*sarama.ConsumerMessage{old, rotated} {
+		if bytes.Contains(m.Value, []byte("client-msg")) {
+			t.Fatalf("payload stored in plaintext: %q", m.Value)
+		}
+	}
+	if id := string(rotated.Headers[len(rotated.Headers)-1].Value); id != "k2" {
+		t.Fatalf("rotated message key ID = %s, want k2", id)
+	}
+	if mcontext.GetOperationID(GetContextWithMQHeader(rotated.Headers)) != "op1" {
+		t.Fatal("context headers not readable next to the key header")
+	}
+
+	legacy := &sarama.ConsumerMessage{Topic: "msg", Value: []byte("plain")}
+	got := consume(context.Background(), &decryptHandler{keys: keys}, old, rotated, legacy)
+	if len(got) != 3 {
+		t.Fatalf("handler got %d messages, want 3", len(got))
+	}
+	for i, want := range []string{"client-msg-a", "client-msg-b"} {
+		var data sdkws.MsgData
+		if err := proto.Unmarshal(got[i].Value, &data); err != nil || data.ClientMsgID != want {
+			t.Fatalf("message %d decrypted to %+v (%v), want %s", i, &data, err, want)
+		}
+		for _, h := range got[i].Headers {
+			if string(h.Key) == HeaderKeyID {
+				t.Fatalf("key header handed to the handler")
+			}
+		}
+	}
+	if got[2] != legacy {
+		t.Fatal("legacy message was not passed through unchanged")
+	}
+}
+
+func TestPayloadDecryptFailure(t *testing.T) {
+	producerKeys := &StaticKeyProvider{Keys: map[string][]byte{"k1": []byte("0123456789abcdef")}, Default: "k1"}
+	unknownKey := produce(t, producerKeys, &sdkws.MsgData{ClientMsgID: "client-msg-a"})
+	tampered := produce(t, producerKeys, &sdkws.MsgData{ClientMsgID: "client-msg-b"})
+	tampered.Value[len(tampered.Value)-1] ^= 1
+
+	consumerKeys := &StaticKeyProvider{Keys: map[string][]byte{"k1": []byte("0123456789abcdef")}}
+	var dead []*sarama.ConsumerMessage
+	h := &decryptHandler{
+		keys: consumerKeys,
+		deadLetter: func(ctx context.Context, msg *sarama.ConsumerMessage, reason string, err error) {
+			if reason != ReasonDecryptFailed || err == nil || mcontext.GetOperationID(ctx) != "op1" {
+				t.Errorf("dead letter reason=%s err=%v", reason, err)
+			}
+			dead = append(dead, msg)
+		},
+	}
+	if got := consume(context.Background(), h, tampered); len(got) != 0 || len(dead) != 1 || dead[0] != tampered {
+		t.Fatalf("tampered message: handled %d, dead-lettered %d", len(got), len(dead))
+	}
+
+	if _, err := DecryptMessage(context.Background(), &StaticKeyProvider{}, unknownKey); !errors.Is(err, errs.ErrRecordNotFound) {
+		t.Fatalf("unknown key error = %v", err)
+	}
+}
+
+// flakyKeys fails to resolve keys until its failures run out, the way a key
+// store does during an outage.
+type flakyKeys struct {
+	KeyProvider
+	failures int
+}
+
+func (k *flakyKeys) Key(ctx context.Context, topic string, keyID string) ([]byte, error) {
+	if k.failures != 0 {
+		k.failures--
+		return nil, errs.ErrDependencyUnavailable.WrapMsg("key store unavailable")
+	}
+	return k.KeyProvider.Key(ctx, topic, keyID)
+}
+
+func TestPayloadKeyUnresolved(t *testing.T) {
+	keyRetryMin, keyRetryMax = time.Millisecond, time.Millisecond
+	t.Cleanup(func() { keyRetryMin, keyRetryMax = 100*time.Millisecond, 10*time.Second })
+	keys := &StaticKeyProvider{Keys: map[string][]byte{"k1": []byte("0123456789abcdef")}, Default: "k1"}
+	msg := produce(t, keys, &sdkws.MsgData{ClientMsgID: "client-msg-a"})
+	var dead int
+	deadLetter := func(context.Context, *sarama.ConsumerMessage, string, error) { dead++ }
+
+	// The message waits for the key store to come back.
+	h := &decryptHandler{keys: &flakyKeys{KeyProvider: keys, failures: 3}, deadLetter: deadLetter}
+	if got := consume(context.Background(), h, msg); len(got) != 1 || dead != 0 {
+		t.Fatalf("key store outage: handled %d, dead-lettered %d", len(got), dead)
+	}
+
+	// The session ends before: the message is left for the next one.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	h = &decryptHandler{keys: &flakyKeys{KeyProvider: keys, failures: -1}, deadLetter: deadLetter}
+	if got := consume(ctx, h, msg); len(got) != 0 || dead != 0 {
+		t.Fatalf("session ended: handled %d, dead-lettered %d", len(got), dead)
+	}
+}
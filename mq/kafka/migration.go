@@ -0,0 +1,469 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/idutil"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/proto"
+)
+
+// Migrating consumers from an old topic to a new one:
+//
+//  1. Producers switch to a DualWriteProducer, which writes every message to
+//     the old topic, then to the new one, with the same HeaderMessageID.
+//  2. Once every producer dual-writes, RecordCutover records the offsets of
+//     both topics.
+//  3. Consumers wrap their handler in a CutoverConsumer and consume both
+//     topics: the old one up to the recorded high-water mark, then the new
+//     one from its recorded offset.
+//  4. After the old topic is drained everywhere, producers and consumers
+//     drop it.
+//
+// Because the new topic offsets are recorded before the old ones, every
+// message written to both topics lies before the old high-water mark or
+// after the new start offset. Those on both sides were written around the
+// cutover and are deduplicated by message ID.
+
+// HeaderMessageID carries the ID that DualWriteProducer gives both copies of
+// a message.
+const HeaderMessageID = "openim-message-id"
+
+const (
+	defaultDedupWindow         = 5 * time.Minute
+	defaultCutoverPollInterval = time.Second
+)
+
+// DualWriteProducer writes every message to an old and a new topic. The old
+// topic is authoritative until switchAt: a failure to write it fails the send
+// and the new topic is not written. From switchAt on the new topic is
+// authoritative and failing to write the old one is only logged.
+type DualWriteProducer struct {
+	producer *Producer
+	oldTopic string
+	newTopic string
+	switchAt time.Time
+	now      func() time.Time
+
+	failed atomic.Int64
+}
+
+type DualWriteOption func(*DualWriteProducer)
+
+// WithDualWriteClock replaces time.Now, for tests.
+func WithDualWriteClock(now func() time.Time) DualWriteOption {
+	return func(p *DualWriteProducer) {
+		p.now = now
+	}
+}
+
+func NewDualWriteProducer(producer *Producer, oldTopic, newTopic string, switchAt time.Time, opts ...DualWriteOption) *DualWriteProducer {
+	p := &DualWriteProducer{
+		producer: producer,
+		oldTopic: oldTopic,
+		newTopic: newTopic,
+		switchAt: switchAt,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Failed returns how many writes to the non-authoritative topic failed.
+func (p *DualWriteProducer) Failed() int64 {
+	return p.failed.Load()
+}
+
+// SendMessage writes msg to both topics and returns the partition and offset
+// in the authoritative one.
+func (p *DualWriteProducer) SendMessage(ctx context.Context, key string, msg proto.Message) (int32, int64, error) {
+	id := sarama.RecordHeader{Key: []byte(HeaderMessageID), Value: []byte(idutil.GetMsgIDByMD5(key))}
+	oldMsg, err := p.producer.buildMessage(ctx, p.oldTopic, key, msg)
+	if err != nil {
+		return 0, 0, err
+	}
+	newMsg, err := p.producer.buildMessage(ctx, p.newTopic, key, msg)
+	if err != nil {
+		return 0, 0, err
+	}
+	oldMsg.Headers = append(oldMsg.Headers, id)
+	newMsg.Headers = append(newMsg.Headers, id)
+
+	oldAuthoritative := p.now().Before(p.switchAt)
+	oldPartition, oldOffset, err := p.producer.send(oldMsg)
+	if err != nil {
+		if oldAuthoritative {
+			return 0, 0, err
+		}
+		p.failed.Add(1)
+		log.ZWarn(ctx, "dual write to old topic failed", err, "topic", p.oldTopic, "key", key)
+	}
+	newPartition, newOffset, err := p.producer.send(newMsg)
+	if err != nil {
+		if !oldAuthoritative {
+			return 0, 0, err
+		}
+		p.failed.Add(1)
+		log.ZWarn(ctx, "dual write to new topic failed", err, "topic", p.newTopic, "key", key)
+	}
+	if oldAuthoritative {
+		return oldPartition, oldOffset, nil
+	}
+	return newPartition, newOffset, nil
+}
+
+// Cutover is the coordination record of a topic migration.
+type Cutover struct {
+	OldTopic   string    `json:"oldTopic"`
+	NewTopic   string    `json:"newTopic"`
+	RecordedAt time.Time `json:"recordedAt"`
+	// OldHighWater is, per partition of the old topic, the offset of the
+	// first message left to the new topic.
+	OldHighWater map[int32]int64 `json:"oldHighWater"`
+	// NewStart is, per partition of the new topic, the offset of the first
+	// message consumed from it. Partitions added later start at 0.
+	NewStart map[int32]int64 `json:"newStart"`
+}
+
+// MigrationStore persists the coordination state of topic migrations, shared
+// by every producer and consumer instance.
+type MigrationStore interface {
+	// SaveCutover stores c unless a cutover is already recorded under name,
+	// and reports whether it did.
+	SaveCutover(ctx context.Context, name string, c *Cutover) (bool, error)
+	// LoadCutover returns the cutover recorded under name, nil if none.
+	LoadCutover(ctx context.Context, name string) (*Cutover, error)
+	MarkDrained(ctx context.Context, name string, partition int32) error
+	DrainedPartitions(ctx context.Context, name string) ([]int32, error)
+	// MarkProcessed records message ID id for ttl and reports whether it
+	// was not recorded yet.
+	MarkProcessed(ctx context.Context, name string, id string, ttl time.Duration) (bool, error)
+}
+
+// OffsetReader reads topic offsets. sarama.Client implements it.
+type OffsetReader interface {
+	Partitions(topic string) ([]int32, error)
+	GetOffset(topic string, partitionID int32, time int64) (int64, error)
+}
+
+// RecordCutover records the current offsets of newTopic, then of oldTopic,
+// under name. If a cutover is already recorded, it is returned unchanged, so
+// that every instance may call RecordCutover.
+func RecordCutover(ctx context.Context, client OffsetReader, store MigrationStore, name, oldTopic, newTopic string) (*Cutover, error) {
+	if c, err := store.LoadCutover(ctx, name); err != nil || c != nil {
+		return c, err
+	}
+	c := &Cutover{OldTopic: oldTopic, NewTopic: newTopic, RecordedAt: time.Now()}
+	var err error
+	if c.NewStart, err = newestOffsets(client, newTopic); err != nil {
+		return nil, err
+	}
+	if c.OldHighWater, err = newestOffsets(client, oldTopic); err != nil {
+		return nil, err
+	}
+	saved, err := store.SaveCutover(ctx, name, c)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return store.LoadCutover(ctx, name)
+	}
+	return c, nil
+}
+
+func newestOffsets(client OffsetReader, topic string) (map[int32]int64, error) {
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "get partitions failed", "topic", topic)
+	}
+	offsets := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		offset, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, errs.WrapMsg(err, "get offset failed", "topic", topic, "partition", partition)
+		}
+		offsets[partition] = offset
+	}
+	return offsets, nil
+}
+
+type CutoverOption func(*CutoverConsumer)
+
+// WithDedupWindow sets how far from the cutover time messages are checked
+// for duplicates, and how long their IDs are remembered. The default is 5m.
+func WithDedupWindow(d time.Duration) CutoverOption {
+	return func(c *CutoverConsumer) {
+		if d > 0 {
+			c.window = d
+		}
+	}
+}
+
+// WithCutoverPollInterval sets how often consumers of the new topic check
+// whether the old topic is drained. The default is 1s.
+func WithCutoverPollInterval(d time.Duration) CutoverOption {
+	return func(c *CutoverConsumer) {
+		if d > 0 {
+			c.poll = d
+		}
+	}
+}
+
+// CutoverConsumer wraps the handler of a consumer group subscribed to both
+// topics of the migration recorded under name. The handler sees the messages
+// of the old topic below the high-water mark, then, once every partition of
+// the old topic is drained, those of the new topic from the recorded offsets.
+// Skipped messages are not marked, the offsets the handler marks cover them.
+type CutoverConsumer struct {
+	sarama.ConsumerGroupHandler
+	store  MigrationStore
+	name   string
+	window time.Duration
+	poll   time.Duration
+
+	lock    sync.Mutex
+	cutover *Cutover
+}
+
+func NewCutoverConsumer(store MigrationStore, name string, handler sarama.ConsumerGroupHandler, opts ...CutoverOption) *CutoverConsumer {
+	c := &CutoverConsumer{
+		ConsumerGroupHandler: handler,
+		store:                store,
+		name:                 name,
+		window:               defaultDedupWindow,
+		poll:                 defaultCutoverPollInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *CutoverConsumer) Setup(session sarama.ConsumerGroupSession) error {
+	cutover, err := c.store.LoadCutover(session.Context(), c.name)
+	if err != nil {
+		return err
+	}
+	if cutover == nil {
+		return errs.ErrConfig.WrapMsg("topic migration cutover not recorded", "name", c.name)
+	}
+	c.lock.Lock()
+	c.cutover = cutover
+	c.lock.Unlock()
+	return c.ConsumerGroupHandler.Setup(session)
+}
+
+func (c *CutoverConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	c.lock.Lock()
+	cutover := c.cutover
+	c.lock.Unlock()
+	ctx := session.Context()
+	switch claim.Topic() {
+	case cutover.OldTopic:
+		return c.ConsumerGroupHandler.ConsumeClaim(session, c.oldClaim(ctx, cutover, claim))
+	case cutover.NewTopic:
+		if err := c.waitDrained(ctx, cutover); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		return c.ConsumerGroupHandler.ConsumeClaim(session, c.newClaim(ctx, cutover, claim))
+	default:
+		return c.ConsumerGroupHandler.ConsumeClaim(session, claim)
+	}
+}
+
+func (c *CutoverConsumer) oldClaim(ctx context.Context, cutover *Cutover, claim sarama.ConsumerGroupClaim) sarama.ConsumerGroupClaim {
+	highWater := cutover.OldHighWater[claim.Partition()]
+	var drained bool
+	markDrained := func() {
+		if drained {
+			return
+		}
+		if err := c.store.MarkDrained(ctx, c.name, claim.Partition()); err != nil {
+			log.ZWarn(ctx, "mark old topic partition drained failed", err, "topic", claim.Topic(), "partition", claim.Partition())
+			return
+		}
+		drained = true
+	}
+	msgs := make(chan *sarama.ConsumerMessage)
+	go func() {
+		defer close(msgs)
+		if highWater <= 0 || claim.InitialOffset() >= highWater {
+			markDrained()
+		}
+		for msg := range claim.Messages() {
+			if msg.Offset < highWater && c.first(ctx, cutover, msg) {
+				select {
+				case msgs <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if msg.Offset >= highWater-1 {
+				markDrained()
+			}
+		}
+	}()
+	return &channelClaim{ConsumerGroupClaim: claim, msgs: msgs}
+}
+
+func (c *CutoverConsumer) newClaim(ctx context.Context, cutover *Cutover, claim sarama.ConsumerGroupClaim) sarama.ConsumerGroupClaim {
+	start := cutover.NewStart[claim.Partition()]
+	msgs := make(chan *sarama.ConsumerMessage)
+	go func() {
+		defer close(msgs)
+		for msg := range claim.Messages() {
+			if msg.Offset < start || !c.first(ctx, cutover, msg) {
+				continue
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return &channelClaim{ConsumerGroupClaim: claim, msgs: msgs}
+}
+
+// first reports whether msg is seen for the first time. Only messages written
+// within the dedup window around the cutover may be on both sides of the seam,
+// the others are not looked up.
+func (c *CutoverConsumer) first(ctx context.Context, cutover *Cutover, msg *sarama.ConsumerMessage) bool {
+	id := headerValue(msg.Headers, HeaderMessageID)
+	if id == "" {
+		return true
+	}
+	if !msg.Timestamp.IsZero() {
+		if d := msg.Timestamp.Sub(cutover.RecordedAt); d > c.window || d < -c.window {
+			return true
+		}
+	}
+	first, err := c.store.MarkProcessed(ctx, c.name, id, 2*c.window)
+	if err != nil {
+		log.ZWarn(ctx, "topic migration dedup failed", err, "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
+		return true
+	}
+	return first
+}
+
+// waitDrained blocks until every partition of the old topic is drained.
+func (c *CutoverConsumer) waitDrained(ctx context.Context, cutover *Cutover) error {
+	ticker := time.NewTicker(c.poll)
+	defer ticker.Stop()
+	for {
+		partitions, err := c.store.DrainedPartitions(ctx, c.name)
+		if err != nil {
+			log.ZWarn(ctx, "load drained partitions failed", err, "name", c.name)
+		} else {
+			drained := make(map[int32]bool, len(partitions))
+			for _, p := range partitions {
+				drained[p] = true
+			}
+			done := true
+			for p := range cutover.OldHighWater {
+				done = done && drained[p]
+			}
+			if done {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RedisMigrationStore is a MigrationStore in Redis.
+type RedisMigrationStore struct {
+	rdb    redis.UniversalClient
+	prefix string
+}
+
+func NewRedisMigrationStore(rdb redis.UniversalClient) *RedisMigrationStore {
+	return &RedisMigrationStore{rdb: rdb, prefix: "kafka:migration:"}
+}
+
+func (s *RedisMigrationStore) SaveCutover(ctx context.Context, name string, c *Cutover) (bool, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return false, errs.WrapMsg(err, "marshal cutover failed", "name", name)
+	}
+	saved, err := s.rdb.SetNX(ctx, s.prefix+name+":cutover", data, 0).Result()
+	if err != nil {
+		return false, errs.WrapMsg(err, "save cutover failed", "name", name)
+	}
+	return saved, nil
+}
+
+func (s *RedisMigrationStore) LoadCutover(ctx context.Context, name string) (*Cutover, error) {
+	data, err := s.rdb.Get(ctx, s.prefix+name+":cutover").Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errs.WrapMsg(err, "load cutover failed", "name", name)
+	}
+	var c Cutover
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errs.WrapMsg(err, "unmarshal cutover failed", "name", name)
+	}
+	return &c, nil
+}
+
+func (s *RedisMigrationStore) MarkDrained(ctx context.Context, name string, partition int32) error {
+	return errs.WrapMsg(s.rdb.SAdd(ctx, s.prefix+name+":drained", partition).Err(), "mark drained failed", "name", name, "partition", partition)
+}
+
+func (s *RedisMigrationStore) DrainedPartitions(ctx context.Context, name string) ([]int32, error) {
+	members, err := s.rdb.SMembers(ctx, s.prefix+name+":drained").Result()
+	if err != nil {
+		return nil, errs.WrapMsg(err, "load drained partitions failed", "name", name)
+	}
+	partitions := make([]int32, 0, len(members))
+	for _, m := range members {
+		p, err := strconv.ParseInt(m, 10, 32)
+		if err != nil {
+			return nil, errs.WrapMsg(err, "invalid drained partition", "name", name, "partition", m)
+		}
+		partitions = append(partitions, int32(p))
+	}
+	return partitions, nil
+}
+
+func (s *RedisMigrationStore) MarkProcessed(ctx context.Context, name string, id string, ttl time.Duration) (bool, error) {
+	first, err := s.rdb.SetNX(ctx, s.prefix+name+":seen:"+id, 1, ttl).Result()
+	if err != nil {
+		return false, errs.WrapMsg(err, "mark message processed failed", "name", name, "id", id)
+	}
+	return first, nil
+}
@@ -0,0 +1,224 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/openimsdk/protocol/sdkws"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/redis/go-redis/v9"
+)
+
+// flakyProducer fails every message sent to failTopic.
+type flakyProducer struct {
+	recordProducer
+	failTopic string
+}
+
+func (p *flakyProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	if msg.Topic == p.failTopic {
+		return 0, 0, errors.New("broker down")
+	}
+	return p.recordProducer.SendMessage(msg)
+}
+
+func TestDualWriteProducer(t *testing.T) {
+	ctx := mcontext.SetOperationID(context.Background(), "op")
+	switchAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	now := switchAt.Add(-time.Hour)
+	sent := &flakyProducer{}
+	p := NewDualWriteProducer(&Producer{producer: sent}, "msg", "msg-v2", switchAt, WithDualWriteClock(func() time.Time { return now }))
+
+	if _, _, err := p.SendMessage(ctx, "k", &sdkws.MsgData{ClientMsgID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	msgs := sent.messages()
+	if len(msgs) != 2 || msgs[0].Topic != "msg" || msgs[1].Topic != "msg-v2" {
+		t.Fatalf("sent %+v, want the old topic then the new one", msgs)
+	}
+	id := func(m *sarama.ProducerMessage) string {
+		for _, h := range m.Headers {
+			if string(h.Key) == HeaderMessageID {
+				return string(h.Value)
+			}
+		}
+		return ""
+	}
+	if id(msgs[0]) == "" || id(msgs[0]) != id(msgs[1]) {
+		t.Fatalf("message IDs %q and %q", id(msgs[0]), id(msgs[1]))
+	}
+
+	// Before switchAt only the old topic matters.
+	sent.failTopic = "msg-v2"
+	if _, _, err := p.SendMessage(ctx, "k", &sdkws.MsgData{ClientMsgID: "b"}); err != nil || p.Failed() != 1 {
+		t.Fatalf("new topic failure before switchAt: %v, failed %d", err, p.Failed())
+	}
+	sent.failTopic = "msg"
+	if _, _, err := p.SendMessage(ctx, "k", &sdkws.MsgData{ClientMsgID: "c"}); err == nil {
+		t.Fatal("old topic failure before switchAt not reported")
+	}
+	if n := len(sent.messages()); n != 3 {
+		t.Fatalf("new topic written after the old write failed: %d messages", n)
+	}
+
+	// Then only the new one.
+	now = switchAt
+	if _, _, err := p.SendMessage(ctx, "k", &sdkws.MsgData{ClientMsgID: "d"}); err != nil || p.Failed() != 2 {
+		t.Fatalf("old topic failure after switchAt: %v, failed %d", err, p.Failed())
+	}
+	sent.failTopic = "msg-v2"
+	if _, _, err := p.SendMessage(ctx, "k", &sdkws.MsgData{ClientMsgID: "e"}); err == nil {
+		t.Fatal("new topic failure after switchAt not reported")
+	}
+}
+
+type fakeOffsets map[string]int64
+
+func (f fakeOffsets) Partitions(string) ([]int32, error) { return []int32{0}, nil }
+
+func (f fakeOffsets) GetOffset(topic string, _ int32, _ int64) (int64, error) {
+	return f[topic], nil
+}
+
+func newMigrationStore(t *testing.T) *RedisMigrationStore {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return NewRedisMigrationStore(rdb)
+}
+
+type topicClaim struct {
+	fakeClaim
+	topic string
+}
+
+func (c *topicClaim) Topic() string        { return c.topic }
+func (c *topicClaim) Partition() int32     { return 0 }
+func (c *topicClaim) InitialOffset() int64 { return 0 }
+
+func claimOf(topic string, msgs ...*sarama.ConsumerMessage) *topicClaim {
+	c := &topicClaim{fakeClaim: fakeClaim{msgs: make(chan *sarama.ConsumerMessage, len(msgs))}, topic: topic}
+	for _, msg := range msgs {
+		c.msgs <- msg
+	}
+	close(c.msgs)
+	return c
+}
+
+type syncCollector struct {
+	nopHandler
+	lock sync.Mutex
+	ids  []string
+}
+
+func (h *syncCollector) ConsumeClaim(_ sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		h.lock.Lock()
+		h.ids = append(h.ids, msg.Topic+"/"+headerValue(msg.Headers, HeaderMessageID))
+		h.lock.Unlock()
+	}
+	return nil
+}
+
+func (h *syncCollector) processed() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]string(nil), h.ids...)
+}
+
+func dualWritten(topic string, offset int64, id string, at time.Time) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{
+		Topic:     topic,
+		Offset:    offset,
+		Timestamp: at,
+		Headers:   []*sarama.RecordHeader{{Key: []byte(HeaderMessageID), Value: []byte(id)}},
+	}
+}
+
+func TestCutoverSeam(t *testing.T) {
+	ctx := context.Background()
+	store := newMigrationStore(t)
+	// Dual writes started with m2: the new topic holds m2..m9 at offsets
+	// 0..7. When the cutover is recorded, the new topic ends after m5
+	// (offset 4) and the old one, checked right after, after m7 (offset 8).
+	cutover, err := RecordCutover(ctx, fakeOffsets{"msg": 8, "msg-v2": 4}, store, "msg", "msg", "msg-v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := RecordCutover(ctx, fakeOffsets{"msg": 100, "msg-v2": 100}, store, "msg", "msg", "msg-v2")
+	if err != nil || again.OldHighWater[0] != 8 || again.NewStart[0] != 4 {
+		t.Fatalf("second RecordCutover = %+v, %v, want the first record", again, err)
+	}
+
+	at := cutover.RecordedAt
+	var oldMsgs, newMsgs []*sarama.ConsumerMessage
+	for i := 0; i < 10; i++ {
+		oldMsgs = append(oldMsgs, dualWritten("msg", int64(i), fmt.Sprintf("m%d", i), at))
+	}
+	for i := 2; i < 10; i++ {
+		newMsgs = append(newMsgs, dualWritten("msg-v2", int64(i-2), fmt.Sprintf("m%d", i), at))
+	}
+
+	// Two instances: one owns the old partition, the other the new one.
+	oldHandler, newHandler := &syncCollector{}, &syncCollector{}
+	oldConsumer := NewCutoverConsumer(store, "msg", oldHandler)
+	newConsumer := NewCutoverConsumer(store, "msg", newHandler, WithCutoverPollInterval(time.Millisecond))
+	session := &fakeSession{ctx: ctx}
+	for _, c := range []*CutoverConsumer{oldConsumer, newConsumer} {
+		if err := c.Setup(session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newDone := make(chan error, 1)
+	go func() { newDone <- newConsumer.ConsumeClaim(session, claimOf("msg-v2", newMsgs...)) }()
+	time.Sleep(20 * time.Millisecond)
+	if got := newHandler.processed(); len(got) != 0 {
+		t.Fatalf("new topic consumed before the old one was drained: %v", got)
+	}
+	if err := oldConsumer.ConsumeClaim(session, claimOf("msg", oldMsgs...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-newDone; err != nil {
+		t.Fatal(err)
+	}
+
+	got := append(oldHandler.processed(), newHandler.processed()...)
+	want := []string{"msg/m0", "msg/m1", "msg/m2", "msg/m3", "msg/m4", "msg/m5", "msg/m6", "msg/m7", "msg-v2/m8", "msg-v2/m9"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("processed %v\nwant %v", got, want)
+	}
+}
+
+func TestCutoverDedupWindow(t *testing.T) {
+	ctx := context.Background()
+	store := newMigrationStore(t)
+	cutover, err := RecordCutover(ctx, fakeOffsets{"msg": 1, "msg-v2": 0}, store, "msg", "msg", "msg-v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Duplicates of a message written long before the cutover cannot come
+	// from dual writes and are left to the handler.
+	old := cutover.RecordedAt.Add(-time.Hour)
+	h := &syncCollector{}
+	c := NewCutoverConsumer(store, "msg", h, WithDedupWindow(time.Minute), WithCutoverPollInterval(time.Millisecond))
+	session := &fakeSession{ctx: ctx}
+	if err := c.Setup(session); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.ConsumeClaim(session, claimOf("msg", dualWritten("msg", 0, "x", old)))
+	_ = c.ConsumeClaim(session, claimOf("msg-v2", dualWritten("msg-v2", 0, "x", old)))
+	if got := h.processed(); len(got) != 2 {
+		t.Fatalf("processed %v, want both copies outside the window", got)
+	}
+
+	if err := NewCutoverConsumer(store, "other", h).Setup(session); err == nil {
+		t.Fatal("Setup without a recorded cutover succeeded")
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/network"
+)
+
+// DefaultProbeTopic is the topic ProbeReadWrite writes to when given none. It
+// must exist, or the cluster allow auto-creation.
+const DefaultProbeTopic = "openim-health"
+
+// probeKeyPrefix starts the key of every probe message, so that consumers of
+// a production topic can tell them apart.
+const probeKeyPrefix = "openim-health-probe-"
+
+// probeTimeout bounds the probe when ctx has no deadline.
+const probeTimeout = 10 * time.Second
+
+// newProbeID returns the unique part of a probe message; replaced in tests.
+var newProbeID = defaultProbeID
+
+var defaultProbeID = uuid.NewString
+
+// ProbeReadWrite produces a small message with a unique key to topic and
+// consumes it back through a throwaway consumer group, failing when it does
+// not come back unchanged before the deadline of ctx, 10 seconds by default.
+// It is the only check exercising the produce, fetch, group and offset commit
+// authorizations; refusals are errs.ErrNoPermission errors, and unreachable
+// brokers errs.ErrDependencyUnavailable ones.
+//
+// The group, named after the message key, is deleted afterwards. The message
+// stays in topic: consumers of a production topic must skip the keys starting
+// with "openim-health-probe-".
+func ProbeReadWrite(ctx context.Context, conf *Config, topic string) error {
+	if topic == "" {
+		topic = DefaultProbeTopic
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+	}
+	addrs, err := network.NormalizeAddrs(conf.Addr)
+	if err != nil {
+		return err
+	}
+	kfk, err := BuildProducerConfig(*conf)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	timeout := time.Until(deadline)
+	kfk.Net.DialTimeout, kfk.Net.ReadTimeout, kfk.Net.WriteTimeout = timeout, timeout, timeout
+	kfk.Producer.Retry.Max = 0
+	kfk.Version = consumerVersion
+	kfk.Consumer.Offsets.AutoCommit.Enable = false
+	kfk.Consumer.Return.Errors = true
+	cli, err := sarama.NewClient(addrs, kfk)
+	if err != nil {
+		// As in clientError, the error of the client hides the one of the
+		// brokers, such as a SASL authentication failure.
+		for _, addr := range addrs {
+			if probeErr := ProbeBroker(ctx, conf, addr); probeErr != nil {
+				return probeError(probeErr, "connect", topic, "broker", addr)
+			}
+		}
+		return probeError(err, "connect", topic, "addr", addrs)
+	}
+	defer cli.Close()
+	producer, err := sarama.NewSyncProducerFromClient(cli)
+	if err != nil {
+		return probeError(err, "produce", topic)
+	}
+	defer producer.Close()
+
+	id := newProbeID()
+	key, value := []byte(probeKeyPrefix+id), []byte(id)
+	partition, offset, err := producer.SendMessage(&sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.ByteEncoder(key),
+		Value: sarama.ByteEncoder(value),
+	})
+	if err != nil {
+		return probeError(err, "produce", topic)
+	}
+
+	groupID := probeKeyPrefix + id
+	group, err := sarama.NewConsumerGroupFromClient(groupID, cli)
+	if err != nil {
+		return probeError(err, "consume", topic, "group", groupID)
+	}
+	h := &probeHandler{topic: topic, partition: partition, offset: offset, key: key, value: value, result: make(chan error, 1)}
+	cctx, cancelConsume := context.WithCancel(ctx)
+	consumeErr := make(chan error, 1)
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		// A rebalance ends the session before the message is back.
+		for cctx.Err() == nil {
+			if err := group.Consume(cctx, []string{topic}, h); err != nil {
+				consumeErr <- err
+				return
+			}
+		}
+	}()
+
+	err = h.wait(ctx, group, consumeErr)
+	cancelConsume()
+	<-consumed
+	// Close leaves the group and returns the last error of the session, such
+	// as a refused offset commit.
+	if closeErr := group.Close(); err == nil && closeErr != nil {
+		err = probeError(closeErr, "consume", topic, "group", groupID)
+	}
+	deleteProbeGroup(ctx, cli, groupID)
+	return err
+}
+
+// wait returns the outcome of the probe message consumed by h.
+func (h *probeHandler) wait(ctx context.Context, group sarama.ConsumerGroup, consumeErr <-chan error) error {
+	select {
+	case err := <-h.result:
+		return err
+	case err := <-consumeErr:
+		if ctx.Err() == nil {
+			return probeError(err, "consume", h.topic)
+		}
+	case err := <-group.Errors():
+		if ctx.Err() == nil {
+			return probeError(err, "consume", h.topic)
+		}
+	case <-ctx.Done():
+	}
+	return errs.ErrTimeout.WrapMsg("kafka probe message not consumed back", "topic", h.topic,
+		"partition", h.partition, "offset", h.offset, "err", ctx.Err().Error())
+}
+
+// deleteProbeGroup removes the throwaway group of a probe, logging failures:
+// an empty group expires on its own anyway.
+func deleteProbeGroup(ctx context.Context, cli sarama.Client, groupID string) {
+	// The admin is not closed, as it would close cli, which the caller owns.
+	admin, err := sarama.NewClusterAdminFromClient(cli)
+	if err == nil {
+		err = admin.DeleteConsumerGroup(groupID)
+	}
+	if err != nil && !errors.Is(err, sarama.ErrGroupIDNotFound) {
+		log.ZWarn(ctx, "delete kafka probe group failed", err, "groupID", groupID)
+	}
+}
+
+// probeHandler consumes the probe message through the throwaway group of a
+// probe and commits its offset.
+type probeHandler struct {
+	topic      string
+	partition  int32
+	offset     int64
+	key, value []byte
+	result     chan error
+}
+
+func (h *probeHandler) Setup(sess sarama.ConsumerGroupSession) error {
+	// The group has no committed offset yet: start at the probe message.
+	sess.MarkOffset(h.topic, h.partition, h.offset, "")
+	return nil
+}
+
+func (h *probeHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *probeHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	if claim.Partition() != h.partition {
+		return nil
+	}
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !bytes.Equal(msg.Key, h.key) {
+				// Written concurrently by another service.
+				continue
+			}
+			var err error
+			if bytes.Equal(msg.Value, h.value) {
+				sess.MarkMessage(msg, "")
+				sess.Commit()
+			} else {
+				err = errs.New("kafka probe message corrupted", "topic", h.topic, "partition", h.partition,
+					"offset", msg.Offset, "sent", string(h.value), "received", string(msg.Value)).Wrap()
+			}
+			select {
+			case h.result <- err:
+			default:
+			}
+			return nil
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+// authorizationErrors are the broker errors of a refused request.
+var authorizationErrors = []error{
+	sarama.ErrTopicAuthorizationFailed,
+	sarama.ErrGroupAuthorizationFailed,
+	sarama.ErrClusterAuthorizationFailed,
+	sarama.ErrSASLAuthenticationFailed,
+}
+
+// probeError tells an authorization refusal of op from a connectivity failure.
+func probeError(err error, op, topic string, kv ...any) error {
+	kv = append([]any{"topic", topic}, kv...)
+	kv = append(kv, "err", errs.Unwrap(err).Error())
+	for _, target := range authorizationErrors {
+		if errors.Is(err, target) {
+			return errs.ErrNoPermission.WrapMsg("kafka "+op+" not authorized", kv...)
+		}
+	}
+	return errs.ErrDependencyUnavailable.WrapMsg("kafka "+op+" failed", kv...)
+}
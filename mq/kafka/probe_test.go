@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+)
+
+func newProbeBroker(t *testing.T, handlers map[string]sarama.MockResponse) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	all := map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()).
+			SetLeader(DefaultProbeTopic, 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset(DefaultProbeTopic, 0, sarama.OffsetOldest, 0).
+			SetOffset(DefaultProbeTopic, 0, sarama.OffsetNewest, 2),
+		// The throwaway group of the probe "id".
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, probeKeyPrefix+"id", broker),
+		"JoinGroupRequest": sarama.NewMockJoinGroupResponse(t).SetGroupProtocol(sarama.RangeBalanceStrategyName),
+		"SyncGroupRequest": sarama.NewMockSyncGroupResponse(t).SetMemberAssignment(
+			&sarama.ConsumerGroupMemberAssignment{Topics: map[string][]int32{DefaultProbeTopic: {0}}}),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset(probeKeyPrefix+"id", DefaultProbeTopic, 0, -1, "", sarama.ErrNoError).
+			SetError(sarama.ErrNoError),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t),
+		"HeartbeatRequest":    sarama.NewMockHeartbeatResponse(t),
+		"LeaveGroupRequest":   sarama.NewMockLeaveGroupResponse(t),
+		"DeleteGroupsRequest": sarama.NewMockDeleteGroupsRequest(t).SetDeletedGroups([]string{probeKeyPrefix + "id"}),
+	}
+	for k, v := range handlers {
+		all[k] = v
+	}
+	broker.SetHandlerByMap(all)
+	return broker
+}
+
+func TestProbeReadWrite(t *testing.T) {
+	newProbeID = func() string { return "id" }
+	t.Cleanup(func() { newProbeID = defaultProbeID })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var broker *sarama.MockBroker
+	probe := func(value string) error {
+		// Another probe message precedes the one of this probe.
+		fetch := sarama.NewMockFetchResponse(t, 1).
+			SetMessageWithKey(DefaultProbeTopic, 0, 0, sarama.StringEncoder(probeKeyPrefix+"other"), sarama.StringEncoder("other")).
+			SetMessageWithKey(DefaultProbeTopic, 0, 1, sarama.StringEncoder(probeKeyPrefix+"id"), sarama.StringEncoder(value)).
+			SetHighWaterMark(DefaultProbeTopic, 0, 2)
+		broker = newProbeBroker(t, map[string]sarama.MockResponse{"FetchRequest": fetch})
+		return ProbeReadWrite(ctx, &Config{Addr: []string{broker.Addr()}}, "")
+	}
+	if err := probe("id"); err != nil {
+		t.Fatal(err)
+	}
+	deleted := false
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*sarama.DeleteGroupsRequest); ok {
+			deleted = true
+		}
+	}
+	if !deleted {
+		t.Fatal("probe group not deleted")
+	}
+	err := probe("tampered")
+	if err == nil || errs.ErrNoPermission.Is(err) || errs.ErrDependencyUnavailable.Is(err) {
+		t.Fatalf("tampered payload: %v", err)
+	}
+}
+
+func TestProbeReadWriteErrors(t *testing.T) {
+	newProbeID = func() string { return "id" }
+	t.Cleanup(func() { newProbeID = defaultProbeID })
+	conf := func(broker *sarama.MockBroker) *Config { return &Config{Addr: []string{broker.Addr()}} }
+
+	denied := newProbeBroker(t, map[string]sarama.MockResponse{
+		"ProduceRequest": sarama.NewMockProduceResponse(t).SetError(DefaultProbeTopic, 0, sarama.ErrTopicAuthorizationFailed),
+	})
+	if err := ProbeReadWrite(context.Background(), conf(denied), ""); !errs.ErrNoPermission.Is(err) {
+		t.Fatalf("produce refused: %v", err)
+	}
+
+	broken := newProbeBroker(t, map[string]sarama.MockResponse{
+		"ProduceRequest": sarama.NewMockProduceResponse(t).SetError(DefaultProbeTopic, 0, sarama.ErrNotEnoughReplicas),
+	})
+	if err := ProbeReadWrite(context.Background(), conf(broken), ""); !errs.ErrDependencyUnavailable.Is(err) {
+		t.Fatalf("produce failed: %v", err)
+	}
+
+	// The group ACLs are checked as well as the topic ones.
+	fetch := sarama.NewMockFetchResponse(t, 1).
+		SetMessageWithKey(DefaultProbeTopic, 0, 0, sarama.StringEncoder(probeKeyPrefix+"id"), sarama.StringEncoder("id")).
+		SetHighWaterMark(DefaultProbeTopic, 0, 1)
+	groupDenied := newProbeBroker(t, map[string]sarama.MockResponse{
+		"FetchRequest":     fetch,
+		"JoinGroupRequest": sarama.NewMockJoinGroupResponse(t).SetError(sarama.ErrGroupAuthorizationFailed),
+	})
+	if err := ProbeReadWrite(context.Background(), conf(groupDenied), ""); !errs.ErrNoPermission.Is(err) {
+		t.Fatalf("group refused: %v", err)
+	}
+	offsetDenied := newProbeBroker(t, map[string]sarama.MockResponse{
+		"FetchRequest": fetch,
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t).
+			SetError(probeKeyPrefix+"id", DefaultProbeTopic, 0, sarama.ErrGroupAuthorizationFailed),
+	})
+	if err := ProbeReadWrite(context.Background(), conf(offsetDenied), ""); !errs.ErrNoPermission.Is(err) {
+		t.Fatalf("offset commit refused: %v", err)
+	}
+
+	// The message never comes back.
+	empty := newProbeBroker(t, map[string]sarama.MockResponse{
+		"FetchRequest": sarama.NewMockFetchResponse(t, 1).SetHighWaterMark(DefaultProbeTopic, 0, 0),
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := ProbeReadWrite(ctx, conf(empty), ""); !errs.ErrTimeout.Is(err) {
+		t.Fatalf("message lost: %v", err)
+	}
+}
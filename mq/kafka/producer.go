@@ -27,56 +27,118 @@ type Producer struct {
 	topic    string
 	config   *sarama.Config
 	producer sarama.SyncProducer
+	keys     KeyProvider
+
+	delayTiers []DelayTier
 }
 
-func NewKafkaProducer(config *sarama.Config, addr []string, topic string) (*Producer, error) {
+type ProducerOption func(*Producer)
+
+// WithPayloadEncryption encrypts every payload with AES-GCM after
+// serialization, using the current key of the topic.
+func WithPayloadEncryption(keys KeyProvider) ProducerOption {
+	return func(p *Producer) {
+		p.keys = keys
+	}
+}
+
+func NewKafkaProducer(config *sarama.Config, addr []string, topic string, opts ...ProducerOption) (*Producer, error) {
 	producer, err := NewProducer(config, addr)
 	if err != nil {
 		return nil, err
 	}
-	return &Producer{
+	p := &Producer{
 		addr:     addr,
 		topic:    topic,
 		config:   config,
 		producer: producer,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
 }
 
 // SendMessage sends a message to the Kafka topic configured in the Producer.
 func (p *Producer) SendMessage(ctx context.Context, key string, msg proto.Message) (int32, int64, error) {
+	kMsg, err := p.buildMessage(ctx, p.topic, key, msg)
+	if err != nil {
+		return 0, 0, err
+	}
+	return p.send(kMsg)
+}
+
+// buildMessage serializes msg for topic and attaches the context headers,
+// encrypting the payload when the Producer was created WithPayloadEncryption.
+func (p *Producer) buildMessage(ctx context.Context, topic string, key string, msg proto.Message) (*sarama.ProducerMessage, error) {
 	// Marshal the protobuf message
 	bMsg, err := proto.Marshal(msg)
 	if err != nil {
-		return 0, 0, errs.WrapMsg(err, "kafka proto Marshal err")
+		return nil, errs.WrapMsg(err, "kafka proto Marshal err")
 	}
 	if len(bMsg) == 0 {
-		return 0, 0, errs.WrapMsg(errEmptyMsg, "kafka proto Marshal err")
+		return nil, errs.WrapMsg(errEmptyMsg, "kafka proto Marshal err")
 	}
 
 	// Prepare Kafka message
 	kMsg := &sarama.ProducerMessage{
-		Topic: p.topic,
+		Topic: topic,
 		Key:   sarama.StringEncoder(key),
 		Value: sarama.ByteEncoder(bMsg),
 	}
 
 	// Validate message key and value
 	if kMsg.Key.Length() == 0 || kMsg.Value.Length() == 0 {
-		return 0, 0, errs.Wrap(errEmptyMsg)
+		return nil, errs.Wrap(errEmptyMsg)
 	}
 
 	// Attach context metadata as headers
 	header, err := GetMQHeaderWithContext(ctx)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
 	kMsg.Headers = header
 
-	// Send the message
+	if p.keys != nil {
+		data, keyHeader, err := encryptPayload(ctx, p.keys, topic, bMsg)
+		if err != nil {
+			return nil, err
+		}
+		kMsg.Value = sarama.ByteEncoder(data)
+		kMsg.Headers = append(kMsg.Headers, keyHeader)
+	}
+	return kMsg, nil
+}
+
+// SendBytes sends an already serialized message to topic with the given
+// headers, encrypting the payload when the Producer was created
+// WithPayloadEncryption. Unlike SendMessage it does not add the headers of
+// ctx: they are expected in headers, captured when the message was created.
+func (p *Producer) SendBytes(ctx context.Context, topic string, key string, value []byte, headers []sarama.RecordHeader) (int32, int64, error) {
+	if key == "" || len(value) == 0 {
+		return 0, 0, errs.Wrap(errEmptyMsg)
+	}
+	kMsg := &sarama.ProducerMessage{
+		Topic:   topic,
+		Key:     sarama.StringEncoder(key),
+		Value:   sarama.ByteEncoder(value),
+		Headers: headers,
+	}
+	if p.keys != nil {
+		data, keyHeader, err := encryptPayload(ctx, p.keys, topic, value)
+		if err != nil {
+			return 0, 0, err
+		}
+		kMsg.Value = sarama.ByteEncoder(data)
+		kMsg.Headers = append(kMsg.Headers[:len(kMsg.Headers):len(kMsg.Headers)], keyHeader)
+	}
+	return p.send(kMsg)
+}
+
+func (p *Producer) send(kMsg *sarama.ProducerMessage) (int32, int64, error) {
 	partition, offset, err := p.producer.SendMessage(kMsg)
 	if err != nil {
 		return 0, 0, errs.WrapMsg(err, "p.producer.SendMessage error")
 	}
-
 	return partition, offset, nil
 }
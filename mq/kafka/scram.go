@@ -0,0 +1,90 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/xdg-go/scram"
+)
+
+// SASL mechanisms of Config.SASLMechanism. An empty mechanism means PLAIN.
+const (
+	SASLPlain       = sarama.SASLTypePlaintext
+	SASLScramSHA256 = sarama.SASLTypeSCRAMSHA256
+	SASLScramSHA512 = sarama.SASLTypeSCRAMSHA512
+)
+
+var saslMechanisms = []string{SASLPlain, SASLScramSHA256, SASLScramSHA512}
+
+// setupSASL enables SASL on kfk when conf has credentials, with the mechanism
+// of conf. It is shared by the consumer and producer configurations.
+func setupSASL(kfk *sarama.Config, conf *Config) error {
+	mechanism := strings.ToUpper(strings.TrimSpace(conf.SASLMechanism))
+	var hash scram.HashGeneratorFcn
+	switch mechanism {
+	case "", SASLPlain:
+		mechanism = SASLPlain
+	case SASLScramSHA256:
+		hash = sha256.New
+	case SASLScramSHA512:
+		hash = sha512.New
+	default:
+		return errs.ErrConfig.WrapMsg("unsupported kafka saslMechanism", "saslMechanism", conf.SASLMechanism, "supported", saslMechanisms)
+	}
+	if conf.Username == "" && conf.Password == "" {
+		if conf.SASLMechanism != "" {
+			return errs.ErrConfig.WrapMsg("kafka saslMechanism is set without username and password", "saslMechanism", conf.SASLMechanism)
+		}
+		return nil
+	}
+	kfk.Net.SASL.Enable = true
+	kfk.Net.SASL.User = conf.Username
+	kfk.Net.SASL.Password = conf.Password
+	kfk.Net.SASL.Mechanism = sarama.SASLMechanism(mechanism)
+	if hash != nil {
+		kfk.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: hash}
+		}
+	}
+	return nil
+}
+
+// scramClient implements sarama.SCRAMClient with github.com/xdg-go/scram.
+type scramClient struct {
+	hash scram.HashGeneratorFcn
+	conv *scram.ClientConversation
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.hash.NewClient(userName, password, authzID)
+	if err != nil {
+		return errs.WrapMsg(err, "SCRAM client failed", "user", userName)
+	}
+	c.conv = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conv.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conv.Done()
+}
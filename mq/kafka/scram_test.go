@@ -0,0 +1,107 @@
+package kafka
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/xdg-go/scram"
+)
+
+// scramExchange runs the conversation of the client of kfk against a SCRAM
+// server knowing user with password.
+func scramExchange(t *testing.T, kfk *sarama.Config, hash scram.HashGeneratorFcn, user, password string) error {
+	t.Helper()
+	creds, err := hash.NewClient(user, password, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := creds.GetStoredCredentials(scram.KeyFactors{Salt: "openim-salt", Iters: 4096})
+	server, err := hash.NewServer(func(name string) (scram.StoredCredentials, error) {
+		if name != user {
+			return scram.StoredCredentials{}, errors.New("unknown user")
+		}
+		return stored, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConv := server.NewConversation()
+
+	client := kfk.Net.SASL.SCRAMClientGeneratorFunc()
+	if err := client.Begin(kfk.Net.SASL.User, kfk.Net.SASL.Password, ""); err != nil {
+		return err
+	}
+	challenge := ""
+	for !client.Done() {
+		msg, err := client.Step(challenge)
+		if err != nil {
+			return err
+		}
+		if client.Done() {
+			break
+		}
+		if challenge, err = serverConv.Step(msg); err != nil {
+			return err
+		}
+	}
+	if !serverConv.Valid() {
+		return errors.New("server did not authenticate the client")
+	}
+	return nil
+}
+
+func TestSCRAM(t *testing.T) {
+	for mechanism, hash := range map[string]scram.HashGeneratorFcn{
+		SASLScramSHA256: sha256.New,
+		"scram-sha-512": sha512.New,
+	} {
+		t.Run(mechanism, func(t *testing.T) {
+			conf := &Config{Username: "openim", Password: "s3cret", SASLMechanism: mechanism}
+			kfk, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !kfk.Net.SASL.Enable || string(kfk.Net.SASL.Mechanism) != strings.ToUpper(mechanism) {
+				t.Fatalf("SASL enable = %v, mechanism = %s", kfk.Net.SASL.Enable, kfk.Net.SASL.Mechanism)
+			}
+			if err := kfk.Validate(); err != nil {
+				t.Fatalf("sarama rejects the config: %v", err)
+			}
+			if err := scramExchange(t, kfk, hash, "openim", "s3cret"); err != nil {
+				t.Fatalf("exchange: %v", err)
+			}
+			if err := scramExchange(t, kfk, hash, "openim", "other"); err == nil {
+				t.Fatal("exchange succeeded with the wrong password")
+			}
+		})
+	}
+}
+
+func TestSASLMechanismConfig(t *testing.T) {
+	kfk, err := BuildProducerConfig(Config{Username: "u", Password: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kfk.Net.SASL.Mechanism != sarama.SASLTypePlaintext || kfk.Net.SASL.SCRAMClientGeneratorFunc != nil {
+		t.Fatalf("default mechanism = %s", kfk.Net.SASL.Mechanism)
+	}
+
+	_, err = BuildProducerConfig(Config{Username: "u", Password: "p", SASLMechanism: "SCRAM-SHA-1"})
+	if !errors.Is(err, errs.ErrConfig) {
+		t.Fatalf("err = %v, want ErrConfig", err)
+	}
+	for _, want := range []string{"SCRAM-SHA-1", SASLPlain, SASLScramSHA256, SASLScramSHA512} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want %q", err, want)
+		}
+	}
+
+	if _, err := BuildProducerConfig(Config{SASLMechanism: SASLScramSHA512}); !errors.Is(err, errs.ErrConfig) {
+		t.Fatalf("mechanism without credentials: err = %v, want ErrConfig", err)
+	}
+}
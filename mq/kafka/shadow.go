@@ -0,0 +1,321 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"google.golang.org/protobuf/proto"
+)
+
+const shadowGroupSuffix = "-shadow"
+
+// shadowRetryInterval is the delay before a new session after a failed one.
+const shadowRetryInterval = time.Second
+
+type shadowCtxKey struct{}
+
+// ShadowGroupID returns the consumer group a shadow consumer of primaryGroupID joins.
+func ShadowGroupID(primaryGroupID string) string {
+	return primaryGroupID + shadowGroupSuffix
+}
+
+// IsShadow reports whether ctx belongs to a message consumed by a ShadowConsumer.
+func IsShadow(ctx context.Context) bool {
+	v, _ := ctx.Value(shadowCtxKey{}).(bool)
+	return v
+}
+
+// MessageSender is the write surface handlers use to produce follow-up messages.
+// *Producer implements it.
+type MessageSender interface {
+	SendMessage(ctx context.Context, key string, msg proto.Message) (int32, int64, error)
+}
+
+type nopSender struct{}
+
+func (nopSender) SendMessage(context.Context, string, proto.Message) (int32, int64, error) {
+	return 0, 0, nil
+}
+
+// GuardSender returns a sender that drops every message when ctx comes from a shadow consumer,
+// so shadow handlers can share code with the primary without producing side effects.
+func GuardSender(ctx context.Context, sender MessageSender) MessageSender {
+	if IsShadow(ctx) {
+		return nopSender{}
+	}
+	return sender
+}
+
+// ShadowCompareFunc is called for every message the shadow handler marks as processed.
+// It should return false when the shadow result diverges from the primary one.
+type ShadowCompareFunc func(ctx context.Context, msg *sarama.ConsumerMessage) bool
+
+type ShadowOption func(*ShadowConsumer)
+
+// WithShadowMaxLag sets the lag (in messages per partition) above which the shadow consumer
+// seeks to maxLag messages behind the high water mark instead of processing every message.
+// Zero disables skipping.
+func WithShadowMaxLag(maxLag int64) ShadowOption {
+	return func(s *ShadowConsumer) {
+		s.maxLag = maxLag
+	}
+}
+
+// WithShadowCompare enables divergence counting.
+func WithShadowCompare(fn ShadowCompareFunc) ShadowOption {
+	return func(s *ShadowConsumer) {
+		s.compare = fn
+	}
+}
+
+// ShadowConsumer consumes the same topics as a primary consumer group without affecting it.
+// It joins its own group, starts every partition from the primary group's committed offset
+// on every rebalance and never commits offsets itself.
+type ShadowConsumer struct {
+	group          sarama.ConsumerGroup
+	client         sarama.Client
+	admin          sarama.ClusterAdmin
+	primaryGroupID string
+	groupID        string
+	topics         []string
+	maxLag         int64
+	compare        ShadowCompareFunc
+
+	lock   sync.Mutex
+	cancel context.CancelFunc // ends the current session, see resync
+
+	divergences atomic.Int64
+	skipped     atomic.Int64
+}
+
+func NewShadowConsumer(conf *Config, primaryGroupID string, topics []string, opts ...ShadowOption) (*ShadowConsumer, error) {
+	config, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, false)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sarama.NewClient(conf.Addr, config)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "NewClient failed", "addr", conf.Addr)
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, errs.WrapMsg(err, "NewClusterAdmin failed", "addr", conf.Addr)
+	}
+	groupID := ShadowGroupID(primaryGroupID)
+	group, err := NewConsumerGroup(config, conf.Addr, groupID)
+	if err != nil {
+		_ = admin.Close()
+		return nil, err
+	}
+	s := &ShadowConsumer{
+		group:          group,
+		client:         client,
+		admin:          admin,
+		primaryGroupID: primaryGroupID,
+		groupID:        groupID,
+		topics:         topics,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Divergences returns how many processed messages the compare callback reported as mismatched.
+func (s *ShadowConsumer) Divergences() int64 {
+	return s.divergences.Load()
+}
+
+// Skipped returns how many messages were skipped because the shadow lag exceeded the cap.
+func (s *ShadowConsumer) Skipped() int64 {
+	return s.skipped.Load()
+}
+
+func (s *ShadowConsumer) GetContextFromMsg(cMsg *sarama.ConsumerMessage) context.Context {
+	return context.WithValue(GetContextWithMQHeader(cMsg.Headers), shadowCtxKey{}, true)
+}
+
+func (s *ShadowConsumer) RegisterHandleAndConsumer(ctx context.Context, handler sarama.ConsumerGroupHandler) {
+	h := &shadowHandler{parent: s, handler: handler}
+	for {
+		sessCtx, cancel := context.WithCancel(ctx)
+		s.lock.Lock()
+		s.cancel = cancel
+		s.lock.Unlock()
+		err := s.group.Consume(sessCtx, s.topics, h)
+		cancel()
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.ZWarn(ctx, "shadow consume err", err, "topic", s.topics, "groupID", s.groupID)
+			// Setup fails on every session while the primary group cannot be
+			// read: retry after a delay rather than at once.
+			timer := time.NewTimer(shadowRetryInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+func (s *ShadowConsumer) Close() error {
+	err := s.group.Close()
+	if aErr := s.admin.Close(); err == nil {
+		err = aErr
+	}
+	return err
+}
+
+// resync ends the current session, so that the next one realigns every partition in Setup.
+func (s *ShadowConsumer) resync() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// primaryOffsets reads the committed offsets of group for the given partitions.
+// Partitions without a committed offset are omitted.
+func primaryOffsets(admin sarama.ClusterAdmin, group string, partitions map[string][]int32) (map[string]map[int32]int64, error) {
+	resp, err := admin.ListConsumerGroupOffsets(group, partitions)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "ListConsumerGroupOffsets failed", "group", group)
+	}
+	if !errors.Is(resp.Err, sarama.ErrNoError) {
+		return nil, errs.WrapMsg(resp.Err, "ListConsumerGroupOffsets failed", "group", group)
+	}
+	res := make(map[string]map[int32]int64)
+	for topic, ps := range partitions {
+		for _, partition := range ps {
+			block := resp.GetBlock(topic, partition)
+			if block == nil || !errors.Is(block.Err, sarama.ErrNoError) || block.Offset < 0 {
+				continue
+			}
+			if res[topic] == nil {
+				res[topic] = make(map[int32]int64)
+			}
+			res[topic][partition] = block.Offset
+		}
+	}
+	return res, nil
+}
+
+type shadowHandler struct {
+	parent  *ShadowConsumer
+	handler sarama.ConsumerGroupHandler
+}
+
+// Setup starts every claimed partition at the committed offset of the primary group. It reads
+// the offsets again on every rebalance, since a partition claimed earlier in the process may have
+// moved on in the primary group since. With a lag cap, a partition further behind its high water
+// mark starts maxLag messages before it instead.
+func (h *shadowHandler) Setup(session sarama.ConsumerGroupSession) error {
+	s := h.parent
+	claims := session.Claims()
+	offsets, err := primaryOffsets(s.admin, s.primaryGroupID, claims)
+	if err != nil {
+		return err
+	}
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			offset, ok := offsets[topic][partition]
+			if !ok {
+				// Without a committed offset the partition starts at the newest one anyway.
+				continue
+			}
+			if s.maxLag > 0 {
+				newest, err := s.client.GetOffset(topic, partition, sarama.OffsetNewest)
+				if err != nil {
+					return errs.WrapMsg(err, "GetOffset failed", "topic", topic, "partition", partition)
+				}
+				if floor := newest - s.maxLag; offset < floor {
+					s.skipped.Add(floor - offset)
+					offset = floor
+				}
+			}
+			// The shadow group never commits, so ResetOffset alone cannot move the session
+			// offset forward from its initial value: MarkOffset first.
+			session.MarkOffset(topic, partition, offset, "")
+			session.ResetOffset(topic, partition, offset, "")
+		}
+	}
+	return h.handler.Setup(&shadowSession{ConsumerGroupSession: session, parent: s})
+}
+
+func (h *shadowHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	return h.handler.Cleanup(&shadowSession{ConsumerGroupSession: session, parent: h.parent})
+}
+
+func (h *shadowHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	sess := &shadowSession{ConsumerGroupSession: session, parent: h.parent}
+	return h.handler.ConsumeClaim(sess, h.parent.wrapClaim(session.Context(), claim))
+}
+
+func (s *ShadowConsumer) wrapClaim(ctx context.Context, claim sarama.ConsumerGroupClaim) sarama.ConsumerGroupClaim {
+	msgs := make(chan *sarama.ConsumerMessage)
+	go func() {
+		defer close(msgs)
+		for msg := range claim.Messages() {
+			if s.maxLag > 0 && claim.HighWaterMarkOffset()-msg.Offset-1 > s.maxLag {
+				// The claim cannot seek, the next session starts within the cap.
+				s.resync()
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return &channelClaim{ConsumerGroupClaim: claim, msgs: msgs}
+}
+
+type channelClaim struct {
+	sarama.ConsumerGroupClaim
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (c *channelClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.msgs
+}
+
+// shadowSession swallows every offset operation so the shadow group never persists progress.
+type shadowSession struct {
+	sarama.ConsumerGroupSession
+	parent *ShadowConsumer
+}
+
+func (s *shadowSession) Context() context.Context {
+	return context.WithValue(s.ConsumerGroupSession.Context(), shadowCtxKey{}, true)
+}
+
+func (s *shadowSession) MarkOffset(string, int32, int64, string) {}
+
+func (s *shadowSession) ResetOffset(string, int32, int64, string) {}
+
+func (s *shadowSession) Commit() {}
+
+func (s *shadowSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	if s.parent.compare == nil {
+		return
+	}
+	if !s.parent.compare(s.parent.GetContextFromMsg(msg), msg) {
+		s.parent.divergences.Add(1)
+	}
+}
@@ -0,0 +1,306 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// newMockShadow returns a shadow consumer of the primary group "transfer", whose client and
+// admin talk to a mock broker. Topic "msg" has partitions 0 and 1, both with newest offset 100.
+func newMockShadow(t *testing.T, offsets map[int32]int64) *ShadowConsumer {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	offsetResp := sarama.NewMockOffsetFetchResponse(t)
+	for partition, offset := range offsets {
+		offsetResp.SetOffset("transfer", "msg", partition, offset, "", sarama.ErrNoError)
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()).
+			SetLeader("msg", 0, broker.BrokerID()).
+			SetLeader("msg", 1, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("msg", 0, sarama.OffsetNewest, 100).
+			SetOffset("msg", 1, sarama.OffsetNewest, 100),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "transfer", broker),
+		"OffsetFetchRequest": offsetResp,
+	})
+	conf := sarama.NewConfig()
+	conf.Version = sarama.V2_0_0_0
+	client, err := sarama.NewClient([]string{broker.Addr()}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+	return &ShadowConsumer{
+		client:         client,
+		admin:          admin,
+		primaryGroupID: "transfer",
+		groupID:        ShadowGroupID("transfer"),
+	}
+}
+
+// fakeSession records the offsets set through it, the way a session whose group never
+// committed applies them: ResetOffset only moves back from an offset set by MarkOffset.
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	claims  map[string][]int32
+	offsets map[int32]int64
+	resets  int
+	ctx     context.Context
+}
+
+func (f *fakeSession) Claims() map[string][]int32 { return f.claims }
+
+func (f *fakeSession) Context() context.Context { return f.ctx }
+
+func (f *fakeSession) MarkOffset(_ string, partition int32, offset int64, _ string) {
+	if cur, ok := f.offsets[partition]; !ok || offset > cur {
+		f.offsets[partition] = offset
+	}
+}
+
+func (f *fakeSession) ResetOffset(_ string, partition int32, offset int64, _ string) {
+	f.resets++
+	if cur, ok := f.offsets[partition]; ok && offset <= cur {
+		f.offsets[partition] = offset
+	}
+}
+
+type nopHandler struct{}
+
+func (nopHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (nopHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+func (nopHandler) ConsumeClaim(sarama.ConsumerGroupSession, sarama.ConsumerGroupClaim) error {
+	return nil
+}
+
+func TestShadowSetupCopiesPrimaryOffsets(t *testing.T) {
+	s := newMockShadow(t, map[int32]int64{0: 42, 1: 7})
+	if s.groupID != "transfer-shadow" {
+		t.Fatalf("unexpected shadow group %s", s.groupID)
+	}
+	h := &shadowHandler{parent: s, handler: nopHandler{}}
+	sess := &fakeSession{claims: map[string][]int32{"msg": {0, 1}}, offsets: map[int32]int64{}, ctx: context.Background()}
+	if err := h.Setup(sess); err != nil {
+		t.Fatal(err)
+	}
+	if sess.offsets[0] != 42 || sess.offsets[1] != 7 {
+		t.Fatalf("offsets not copied from primary: %v", sess.offsets)
+	}
+}
+
+func TestShadowSetupSeeksPastMaxLag(t *testing.T) {
+	s := newMockShadow(t, map[int32]int64{0: 42, 1: 95})
+	s.maxLag = 10
+	h := &shadowHandler{parent: s, handler: nopHandler{}}
+	sess := &fakeSession{claims: map[string][]int32{"msg": {0, 1}}, offsets: map[int32]int64{}, ctx: context.Background()}
+	if err := h.Setup(sess); err != nil {
+		t.Fatal(err)
+	}
+	if sess.offsets[0] != 90 || sess.offsets[1] != 95 {
+		t.Fatalf("offsets = %v, want 90 past the cap and 95 within it", sess.offsets)
+	}
+	if s.Skipped() != 48 {
+		t.Fatalf("skipped = %d, want 48", s.Skipped())
+	}
+}
+
+// firstOffsetHandler reports the first offset consumed in every session.
+type firstOffsetHandler struct {
+	nopHandler
+	first chan int64
+}
+
+func (h *firstOffsetHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	reported := false
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !reported {
+				reported = true
+				select {
+				case h.first <- msg.Offset:
+				default:
+				}
+			}
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+func TestShadowRebalanceResumesAtPrimaryOffset(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	handlers := func(primary int64) map[string]sarama.MockResponse {
+		fetch := sarama.NewMockFetchResponse(t, 1).SetHighWaterMark("msg", 0, 20)
+		for i := int64(0); i < 20; i++ {
+			fetch.SetMessage("msg", 0, i, sarama.StringEncoder("m"))
+		}
+		return map[string]sarama.MockResponse{
+			"MetadataRequest": sarama.NewMockMetadataResponse(t).
+				SetBroker(broker.Addr(), broker.BrokerID()).
+				SetController(broker.BrokerID()).
+				SetLeader("msg", 0, broker.BrokerID()),
+			"OffsetRequest": sarama.NewMockOffsetResponse(t).
+				SetOffset("msg", 0, sarama.OffsetOldest, 0).
+				SetOffset("msg", 0, sarama.OffsetNewest, 20),
+			"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+				SetCoordinator(sarama.CoordinatorGroup, "transfer", broker).
+				SetCoordinator(sarama.CoordinatorGroup, "transfer-shadow", broker),
+			"JoinGroupRequest": sarama.NewMockJoinGroupResponse(t).SetGroupProtocol(sarama.RangeBalanceStrategyName),
+			"SyncGroupRequest": sarama.NewMockSyncGroupResponse(t).SetMemberAssignment(
+				&sarama.ConsumerGroupMemberAssignment{Topics: map[string][]int32{"msg": {0}}}),
+			"FetchRequest":      fetch,
+			"HeartbeatRequest":  sarama.NewMockHeartbeatResponse(t),
+			"LeaveGroupRequest": sarama.NewMockLeaveGroupResponse(t),
+			"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+				SetOffset("transfer", "msg", 0, primary, "", sarama.ErrNoError).
+				SetOffset("transfer-shadow", "msg", 0, -1, "", sarama.ErrNoError).
+				SetError(sarama.ErrNoError),
+		}
+	}
+	broker.SetHandlerByMap(handlers(5))
+
+	conf := sarama.NewConfig()
+	conf.Version = sarama.V2_0_0_0
+	conf.Consumer.Offsets.Initial = sarama.OffsetNewest
+	conf.Consumer.Offsets.AutoCommit.Enable = false
+	client, err := sarama.NewClient([]string{broker.Addr()}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := sarama.NewConsumerGroup([]string{broker.Addr()}, "transfer-shadow", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ShadowConsumer{
+		group:          group,
+		client:         client,
+		admin:          admin,
+		primaryGroupID: "transfer",
+		groupID:        "transfer-shadow",
+		topics:         []string{"msg"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	h := &firstOffsetHandler{first: make(chan int64, 16)}
+	go func() {
+		defer close(done)
+		s.RegisterHandleAndConsumer(ctx, h)
+	}()
+	defer func() {
+		cancel()
+		_ = s.Close()
+		<-done
+	}()
+
+	first := func() int64 {
+		t.Helper()
+		select {
+		case offset := <-h.first:
+			return offset
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a session")
+			return 0
+		}
+	}
+	if offset := first(); offset != 5 {
+		t.Fatalf("first session started at %d, want the primary offset 5", offset)
+	}
+	// The primary moves on, then the member rejoins the group. The mock heartbeat cannot
+	// report a rebalance, so end the session the way a lagging claim does.
+	broker.SetHandlerByMap(handlers(12))
+	s.resync()
+	if offset := first(); offset != 12 {
+		t.Fatalf("session after the rebalance started at %d, want the primary offset 12", offset)
+	}
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	hwm  int64
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (f *fakeClaim) HighWaterMarkOffset() int64 { return f.hwm }
+
+func (f *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return f.msgs }
+
+func TestShadowSkipAhead(t *testing.T) {
+	resynced := false
+	s := &ShadowConsumer{maxLag: 10, cancel: func() { resynced = true }}
+	claim := &fakeClaim{hwm: 100, msgs: make(chan *sarama.ConsumerMessage, 100)}
+	for i := int64(85); i < 100; i++ {
+		claim.msgs <- &sarama.ConsumerMessage{Topic: "msg", Offset: i}
+	}
+	close(claim.msgs)
+
+	var delivered []int64
+	for msg := range s.wrapClaim(context.Background(), claim).Messages() {
+		delivered = append(delivered, msg.Offset)
+	}
+	if len(delivered) != 0 || !resynced {
+		t.Fatalf("delivered %v past the cap, resynced = %v; want the session ended", delivered, resynced)
+	}
+
+	// Within the cap every message is delivered.
+	resynced = false
+	claim = &fakeClaim{hwm: 100, msgs: make(chan *sarama.ConsumerMessage, 100)}
+	for i := int64(90); i < 100; i++ {
+		claim.msgs <- &sarama.ConsumerMessage{Topic: "msg", Offset: i}
+	}
+	close(claim.msgs)
+	delivered = nil
+	for msg := range s.wrapClaim(context.Background(), claim).Messages() {
+		delivered = append(delivered, msg.Offset)
+	}
+	if len(delivered) != 10 || resynced {
+		t.Fatalf("delivered %v, resynced = %v; want all 10 messages", delivered, resynced)
+	}
+}
+
+func TestShadowSessionIsSideEffectFree(t *testing.T) {
+	s := &ShadowConsumer{compare: func(ctx context.Context, msg *sarama.ConsumerMessage) bool {
+		if !IsShadow(ctx) {
+			t.Error("compare ctx is not marked as shadow")
+		}
+		return msg.Offset%2 == 0
+	}}
+	inner := &fakeSession{offsets: map[int32]int64{}, ctx: context.Background()}
+	sess := &shadowSession{ConsumerGroupSession: inner, parent: s}
+	for i := int64(0); i < 4; i++ {
+		sess.MarkMessage(&sarama.ConsumerMessage{Offset: i}, "")
+	}
+	sess.ResetOffset("msg", 0, 1, "")
+	sess.Commit()
+	if inner.resets != 0 {
+		t.Fatal("shadow session forwarded an offset reset")
+	}
+	if s.Divergences() != 2 {
+		t.Fatalf("divergences = %d, want 2", s.Divergences())
+	}
+	if !IsShadow(sess.Context()) {
+		t.Fatal("session context is not marked as shadow")
+	}
+	if _, ok := GuardSender(sess.Context(), nil).(nopSender); !ok {
+		t.Fatal("GuardSender must stub writes for shadow contexts")
+	}
+}
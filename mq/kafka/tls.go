@@ -16,68 +16,12 @@ package kafka
 
 import (
 	"crypto/tls"
-	"crypto/x509"
-	"encoding/pem"
-	"os"
 
-	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/tlsutil"
 )
 
-// decryptPEM decrypts a PEM block using a password.
-func decryptPEM(data []byte, passphrase []byte) ([]byte, error) {
-	if len(passphrase) == 0 {
-		return data, nil
-	}
-	b, _ := pem.Decode(data)
-	d, err := x509.DecryptPEMBlock(b, passphrase)
-	if err != nil {
-		return nil, errs.WrapMsg(err, "DecryptPEMBlock failed")
-	}
-	return pem.EncodeToMemory(&pem.Block{
-		Type:  b.Type,
-		Bytes: d,
-	}), nil
-}
-
-func readEncryptablePEMBlock(path string, pwd []byte) ([]byte, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, errs.WrapMsg(err, "ReadFile failed", "path", path)
-	}
-	return decryptPEM(data, pwd)
-}
-
-// newTLSConfig setup the TLS config from general config file.
+// newTLSConfig setup the TLS config from general config file, see
+// tlsutil.Load.
 func newTLSConfig(clientCertFile, clientKeyFile, caCertFile string, keyPwd []byte, insecureSkipVerify bool) (*tls.Config, error) {
-	var tlsConfig tls.Config
-	if clientCertFile != "" && clientKeyFile != "" {
-		certPEMBlock, err := os.ReadFile(clientCertFile)
-		if err != nil {
-			return nil, errs.WrapMsg(err, "ReadFile failed", "clientCertFile", clientCertFile)
-		}
-		keyPEMBlock, err := readEncryptablePEMBlock(clientKeyFile, keyPwd)
-		if err != nil {
-			return nil, err
-		}
-
-		cert, err := tls.X509KeyPair(certPEMBlock, keyPEMBlock)
-		if err != nil {
-			return nil, errs.WrapMsg(err, "X509KeyPair failed")
-		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
-	}
-
-	if caCertFile != "" {
-		caCert, err := os.ReadFile(caCertFile)
-		if err != nil {
-			return nil, errs.WrapMsg(err, "ReadFile failed", "caCertFile", caCertFile)
-		}
-		caCertPool := x509.NewCertPool()
-		if ok := caCertPool.AppendCertsFromPEM(caCert); !ok {
-			return nil, errs.New("AppendCertsFromPEM failed")
-		}
-		tlsConfig.RootCAs = caCertPool
-	}
-	tlsConfig.InsecureSkipVerify = insecureSkipVerify
-	return &tlsConfig, nil
+	return tlsutil.Load(caCertFile, clientCertFile, clientKeyFile, insecureSkipVerify, tlsutil.WithKeyPassword(keyPwd))
 }
@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/tlsutil/tlstest"
+)
+
+// startTLSBroker starts a mock broker accepting TLS only, see
+// tlstest.Listener, and returns the CA path.
+func startTLSBroker(t *testing.T, dir string) (*sarama.MockBroker, string) {
+	t.Helper()
+	lis, caPath := tlstest.Listener(t, dir, "kafka")
+	broker := sarama.NewMockBrokerListener(t, 1, lis)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()),
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+	})
+	t.Cleanup(broker.Close)
+	return broker, caPath
+}
+
+func TestCheckHealthTLS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+	broker, caPath := startTLSBroker(t, dir)
+
+	conf := &Config{Addr: []string{broker.Addr()}, TLS: TLSConfig{EnableTLS: true, CACrt: caPath}}
+	if err := CheckHealth(ctx, conf); err != nil {
+		t.Fatalf("CheckHealth: %v", err)
+	}
+
+	_, _, otherCA := tlstest.NewCA(t, dir, "other-ca")
+	conf.TLS.CACrt = otherCA
+	err := CheckHealth(ctx, conf)
+	if err == nil {
+		t.Fatal("CheckHealth succeeded with the wrong CA")
+	}
+	for _, want := range []string{broker.Addr(), "certificate"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want %q", err, want)
+		}
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, _, caPath := tlstest.NewCA(t, dir, "ca")
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+	tests := map[string]struct {
+		tls  TLSConfig
+		path string
+	}{
+		"missing ca":       {TLSConfig{CACrt: missing}, missing},
+		"malformed ca":     {TLSConfig{CACrt: garbage}, garbage},
+		"missing cert":     {TLSConfig{ClientCrt: missing, ClientKey: garbage}, missing},
+		"malformed pair":   {TLSConfig{ClientCrt: caPath, ClientKey: garbage}, garbage},
+		"encrypted key":    {TLSConfig{ClientCrt: caPath, ClientKey: garbage, ClientKeyPwd: "secret"}, garbage},
+		"cert without key": {TLSConfig{ClientCrt: caPath}, caPath},
+	}
+	for name, tt := range tests {
+		tt.tls.EnableTLS = true
+		_, err := BuildConsumerGroupConfig(&Config{TLS: tt.tls}, sarama.OffsetNewest, false)
+		if !errors.Is(err, errs.ErrConfig) {
+			t.Errorf("%s: err = %v, want ErrConfig", name, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.path) {
+			t.Errorf("%s: err = %v, want %s", name, err, tt.path)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	conf := &Config{Addr: []string{"127.0.0.1:9092"}}
+	t.Setenv("KAFKA_TLS", "true")
+	if err := conf.ApplyEnv(); err != nil || !conf.TLS.EnableTLS {
+		t.Fatalf("KAFKA_TLS=true: EnableTLS = %v, %v", conf.TLS.EnableTLS, err)
+	}
+	t.Setenv("KAFKA_TLS", "maybe")
+	if err := conf.ApplyEnv(); !errors.Is(err, errs.ErrConfig) {
+		t.Fatalf("err = %v, want ErrConfig", err)
+	}
+}
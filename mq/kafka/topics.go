@@ -0,0 +1,250 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/network"
+)
+
+// Defaults of TopicSpec, those of the topic scripts shipped with OpenIM.
+const (
+	DefaultTopicPartitions        = 8
+	DefaultTopicReplicationFactor = 1
+)
+
+// TopicSpec describes the topics created by EnsureTopics. Zero fields take
+// the defaults above.
+type TopicSpec struct {
+	Partitions        int32 `yaml:"partitions"`
+	ReplicationFactor int16 `yaml:"replicationFactor"`
+}
+
+func (s TopicSpec) withDefaults() TopicSpec {
+	if s.Partitions <= 0 {
+		s.Partitions = DefaultTopicPartitions
+	}
+	if s.ReplicationFactor <= 0 {
+		s.ReplicationFactor = DefaultTopicReplicationFactor
+	}
+	return s
+}
+
+// EnsureTopics creates the topics missing on the cluster with spec, then
+// verifies that every topic exists. Existing topics are left untouched, so
+// repeated runs are no-ops. It returns the topics it created, sorted.
+func EnsureTopics(ctx context.Context, conf *Config, topics []string, spec TopicSpec) ([]string, error) {
+	spec = spec.withDefaults()
+	addrs, err := network.NormalizeAddrs(conf.Addr)
+	if err != nil {
+		return nil, err
+	}
+	kfk, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, false)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := sarama.NewClient(addrs, kfk)
+	if err != nil {
+		return nil, clientError(ctx, conf, addrs, err)
+	}
+	admin, err := sarama.NewClusterAdminFromClient(cli)
+	if err != nil {
+		_ = cli.Close()
+		return nil, errs.WrapMsg(err, "NewClusterAdmin failed", "addr", addrs)
+	}
+	// Closing the admin closes cli.
+	defer admin.Close()
+
+	missing, err := missingTopics(cli, topics)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	if brokers := len(cli.Brokers()); int(spec.ReplicationFactor) > brokers {
+		return nil, errs.ErrConfig.WrapMsg("replication factor larger than available brokers",
+			"topics", missing, "replicationFactor", spec.ReplicationFactor, "brokers", brokers)
+	}
+	created := make([]string, 0, len(missing))
+	for _, topic := range missing {
+		if err := ctx.Err(); err != nil {
+			return created, errs.Wrap(err)
+		}
+		detail := &sarama.TopicDetail{NumPartitions: spec.Partitions, ReplicationFactor: spec.ReplicationFactor}
+		switch err := admin.CreateTopic(topic, detail, false); {
+		case err == nil:
+			created = append(created, topic)
+		case errors.Is(err, sarama.ErrTopicAlreadyExists):
+			// Created concurrently, by another service starting up.
+		case errors.Is(err, sarama.ErrInvalidReplicationFactor):
+			return created, errs.ErrConfig.WrapMsg("replication factor larger than available brokers",
+				"topic", topic, "replicationFactor", spec.ReplicationFactor, "err", err.Error())
+		default:
+			return created, errs.WrapMsg(err, "CreateTopic failed", "topic", topic,
+				"partitions", spec.Partitions, "replicationFactor", spec.ReplicationFactor)
+		}
+	}
+
+	// Refreshing all topics, as a topic still unknown fails a targeted refresh.
+	if err := cli.RefreshMetadata(); err != nil {
+		return created, errs.WrapMsg(err, "failed to refresh metadata")
+	}
+	if missing, err = missingTopics(cli, topics); err != nil {
+		return created, err
+	}
+	if len(missing) > 0 {
+		return created, errs.New("topic not exist after creation", "topics", missing).Wrap()
+	}
+	return created, nil
+}
+
+// missingTopics returns the topics the cluster does not know, sorted.
+func missingTopics(cli sarama.Client, topics []string) ([]string, error) {
+	existing, err := cli.Topics()
+	if err != nil {
+		return nil, errs.WrapMsg(err, "Failed to list topics")
+	}
+	known := make(map[string]bool, len(existing))
+	for _, t := range existing {
+		known[t] = true
+	}
+	var missing []string
+	for _, topic := range topics {
+		if !known[topic] {
+			known[topic] = true
+			missing = append(missing, topic)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// TopicLayout is the partitioning of a topic, see DescribeTopicLayouts.
+type TopicLayout struct {
+	Topic      string
+	Partitions int
+	// ReplicationFactor is the smallest replica count of the partitions.
+	ReplicationFactor int
+	// MinInsyncReplicas is the min.insync.replicas of the topic, 0 when the
+	// broker does not report it.
+	MinInsyncReplicas int
+}
+
+func (l TopicLayout) String() string {
+	s := fmt.Sprintf("partitions=%d replicationFactor=%d", l.Partitions, l.ReplicationFactor)
+	if l.MinInsyncReplicas > 0 {
+		s += fmt.Sprintf(" minInsyncReplicas=%d", l.MinInsyncReplicas)
+	}
+	return s
+}
+
+// TopicRequirements are the minimum layout expected of the topics of a
+// deployment. Zero fields are not checked.
+type TopicRequirements struct {
+	MinPartitions        int `yaml:"minPartitions"`
+	MinReplicationFactor int `yaml:"minReplicationFactor"`
+}
+
+// Problems returns the ways l falls short of req, and of its own
+// min.insync.replicas: with fewer replicas than that, writes with acks=all
+// fail, and with exactly as many they fail as soon as one broker is down.
+func (l TopicLayout) Problems(req TopicRequirements) []string {
+	var problems []string
+	if req.MinPartitions > 0 && l.Partitions < req.MinPartitions {
+		problems = append(problems, fmt.Sprintf("partitions %d below the minimum %d", l.Partitions, req.MinPartitions))
+	}
+	if req.MinReplicationFactor > 0 && l.ReplicationFactor < req.MinReplicationFactor {
+		problems = append(problems, fmt.Sprintf("replication factor %d below the minimum %d", l.ReplicationFactor, req.MinReplicationFactor))
+	}
+	switch {
+	case l.MinInsyncReplicas == 0:
+	case l.ReplicationFactor < l.MinInsyncReplicas:
+		problems = append(problems, fmt.Sprintf("replication factor %d below min.insync.replicas %d, acks=all writes fail",
+			l.ReplicationFactor, l.MinInsyncReplicas))
+	case l.ReplicationFactor == l.MinInsyncReplicas && l.ReplicationFactor > 1:
+		problems = append(problems, fmt.Sprintf("replication factor %d equals min.insync.replicas, acks=all writes fail when a broker is down",
+			l.ReplicationFactor))
+	}
+	return problems
+}
+
+// DescribeTopicLayouts returns the layout of each topic, in the order of
+// topics. Unknown topics fail.
+func DescribeTopicLayouts(ctx context.Context, conf *Config, topics []string) ([]TopicLayout, error) {
+	addrs, err := network.NormalizeAddrs(conf.Addr)
+	if err != nil {
+		return nil, err
+	}
+	kfk, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, false)
+	if err != nil {
+		return nil, err
+	}
+	admin, err := sarama.NewClusterAdmin(addrs, kfk)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "NewClusterAdmin failed", "addr", addrs)
+	}
+	defer admin.Close()
+
+	metadata, err := admin.DescribeTopics(topics)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "DescribeTopics failed", "topics", topics)
+	}
+	byName := make(map[string]*sarama.TopicMetadata, len(metadata))
+	for _, m := range metadata {
+		byName[m.Name] = m
+	}
+	layouts := make([]TopicLayout, 0, len(topics))
+	for _, topic := range topics {
+		if err := ctx.Err(); err != nil {
+			return nil, errs.Wrap(err)
+		}
+		m := byName[topic]
+		if m == nil {
+			return nil, errs.New("topic not exist", "topic", topic).Wrap()
+		}
+		if !errors.Is(m.Err, sarama.ErrNoError) {
+			return nil, errs.WrapMsg(m.Err, "DescribeTopics failed", "topic", topic)
+		}
+		layout := TopicLayout{Topic: topic, Partitions: len(m.Partitions)}
+		for i, p := range m.Partitions {
+			if i == 0 || len(p.Replicas) < layout.ReplicationFactor {
+				layout.ReplicationFactor = len(p.Replicas)
+			}
+		}
+		entries, err := admin.DescribeConfig(sarama.ConfigResource{
+			Type:        sarama.TopicResource,
+			Name:        topic,
+			ConfigNames: []string{"min.insync.replicas"},
+		})
+		if err != nil {
+			return nil, errs.WrapMsg(err, "DescribeConfig failed", "topic", topic)
+		}
+		for _, e := range entries {
+			if e.Name == "min.insync.replicas" {
+				layout.MinInsyncReplicas, _ = strconv.Atoi(e.Value)
+			}
+		}
+		layouts = append(layouts, layout)
+	}
+	return layouts, nil
+}
@@ -0,0 +1,149 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+)
+
+// newCreateTopicsBroker returns a broker knowing topics, and also created once the
+// client refreshes its metadata after the CreateTopics request.
+func newCreateTopicsBroker(t *testing.T, topics, created []string) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	metadata := func(topics []string) *sarama.MockMetadataResponse {
+		m := sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID())
+		for _, topic := range topics {
+			m.SetLeader(topic, 0, broker.BrokerID())
+		}
+		return m
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":     sarama.NewMockSequence(metadata(topics), metadata(append(topics, created...))),
+		"CreateTopicsRequest": sarama.NewMockCreateTopicsResponse(t),
+	})
+	return broker
+}
+
+// createRequests returns the topics of the CreateTopics requests broker received.
+func createRequests(t *testing.T, broker *sarama.MockBroker) []string {
+	var topics []string
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.CreateTopicsRequest); ok {
+			for topic, detail := range req.TopicDetails {
+				if detail.NumPartitions != DefaultTopicPartitions || detail.ReplicationFactor != DefaultTopicReplicationFactor {
+					t.Errorf("%s created with %+v", topic, detail)
+				}
+				topics = append(topics, topic)
+			}
+		}
+	}
+	return topics
+}
+
+func TestEnsureTopics(t *testing.T) {
+	ctx := context.Background()
+	topics := []string{"toRedis", "toPush", "toMongo"}
+
+	broker := newCreateTopicsBroker(t, []string{"toRedis"}, []string{"toPush", "toMongo"})
+	created, err := EnsureTopics(ctx, &Config{Addr: []string{broker.Addr()}}, topics, TopicSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(created, ",") != "toMongo,toPush" {
+		t.Fatalf("created = %v", created)
+	}
+	if got := len(createRequests(t, broker)); got != 2 {
+		t.Fatalf("%d CreateTopics requests, want 2", got)
+	}
+
+	broker = newCreateTopicsBroker(t, topics, nil)
+	if created, err := EnsureTopics(ctx, &Config{Addr: []string{broker.Addr()}}, topics, TopicSpec{}); err != nil || len(created) != 0 {
+		t.Fatalf("existing topics: created %v, %v", created, err)
+	}
+	if got := createRequests(t, broker); len(got) != 0 {
+		t.Fatalf("existing topics sent CreateTopics for %v", got)
+	}
+}
+
+func TestEnsureTopicsErrors(t *testing.T) {
+	ctx := context.Background()
+	broker := newCreateTopicsBroker(t, nil, nil)
+	conf := &Config{Addr: []string{broker.Addr()}}
+	_, err := EnsureTopics(ctx, conf, []string{"toPush"}, TopicSpec{ReplicationFactor: 3})
+	if !errs.ErrConfig.Is(err) || !strings.Contains(err.Error(), "replication factor larger than available brokers") {
+		t.Fatalf("replication factor: %v", err)
+	}
+
+	// The broker accepted the request but the topic never shows up.
+	_, err = EnsureTopics(ctx, conf, []string{"toPush"}, TopicSpec{})
+	if err == nil || !strings.Contains(err.Error(), "topic not exist after creation") {
+		t.Fatalf("re-verification: %v", err)
+	}
+
+	// Names with a reserved prefix are refused by the mock broker.
+	_, err = EnsureTopics(ctx, conf, []string{"__internal"}, TopicSpec{})
+	if !errors.Is(err, sarama.ErrTopicAuthorizationFailed) {
+		t.Fatalf("refused creation: %v", err)
+	}
+}
+
+func TestDescribeTopicLayouts(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()).
+			SetLeader("toPush", 0, broker.BrokerID()).
+			SetLeader("toRedis", 0, broker.BrokerID()).
+			SetLeader("toRedis", 1, broker.BrokerID()),
+		"DescribeConfigsRequest": sarama.NewMockDescribeConfigsResponse(t),
+	})
+	conf := &Config{Addr: []string{broker.Addr()}}
+	layouts, err := DescribeTopicLayouts(context.Background(), conf, []string{"toRedis", "toPush"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TopicLayout{{Topic: "toRedis", Partitions: 2, ReplicationFactor: 1}, {Topic: "toPush", Partitions: 1, ReplicationFactor: 1}}
+	if len(layouts) != len(want) || layouts[0] != want[0] || layouts[1] != want[1] {
+		t.Fatalf("layouts = %+v, want %+v", layouts, want)
+	}
+	if _, err := DescribeTopicLayouts(context.Background(), conf, []string{"toMongo"}); err == nil {
+		t.Fatal("unknown topic described")
+	}
+}
+
+func TestTopicLayoutProblems(t *testing.T) {
+	req := TopicRequirements{MinPartitions: 8, MinReplicationFactor: 3}
+	tests := []struct {
+		layout TopicLayout
+		req    TopicRequirements
+		want   []string
+	}{
+		{TopicLayout{Partitions: 8, ReplicationFactor: 3, MinInsyncReplicas: 2}, req, nil},
+		{TopicLayout{Partitions: 1, ReplicationFactor: 1}, req, []string{"partitions 1 below the minimum 8", "replication factor 1 below the minimum 3"}},
+		{TopicLayout{Partitions: 8, ReplicationFactor: 3, MinInsyncReplicas: 4}, req, []string{"replication factor 3 below min.insync.replicas 4"}},
+		{TopicLayout{Partitions: 8, ReplicationFactor: 3, MinInsyncReplicas: 3}, req, []string{"equals min.insync.replicas"}},
+		// A single broker development setup.
+		{TopicLayout{Partitions: 1, ReplicationFactor: 1, MinInsyncReplicas: 1}, TopicRequirements{}, nil},
+	}
+	for _, tt := range tests {
+		got := tt.layout.Problems(tt.req)
+		if len(got) != len(tt.want) {
+			t.Errorf("%v: problems = %q, want %q", tt.layout, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !strings.Contains(got[i], tt.want[i]) {
+				t.Errorf("%v: problem %q, want %q", tt.layout, got[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/network"
+)
+
+// Kinds of TopologyViolation.
+const (
+	ViolationGroupReused        = "group_reused"
+	ViolationTopicNotConsumed   = "topic_not_consumed"
+	ViolationTopicNotDeclared   = "topic_not_declared"
+	ViolationTopicMissingBroker = "topic_missing_on_broker"
+)
+
+// topologyDialTimeout bounds the broker lookup so that an unreachable cluster
+// only degrades validation to the config-only checks.
+const topologyDialTimeout = 3 * time.Second
+
+// GroupDeclaration states which topics a service consumes and under which
+// consumer group, and which topics it produces to. Producer-only services
+// leave GroupID empty.
+type GroupDeclaration struct {
+	Service  string   `yaml:"service"`
+	GroupID  string   `yaml:"groupID"`
+	Consumes []string `yaml:"consumes"`
+	Produces []string `yaml:"produces"`
+}
+
+// TopologyViolation is a single inconsistency found by ValidateTopology.
+type TopologyViolation struct {
+	Kind     string
+	Group    string
+	Topic    string
+	Services []string
+	Hint     string
+}
+
+func (v *TopologyViolation) Error() string {
+	var b strings.Builder
+	b.WriteString("kafka topology: ")
+	b.WriteString(v.Kind)
+	if v.Group != "" {
+		b.WriteString(", group=" + v.Group)
+	}
+	if v.Topic != "" {
+		b.WriteString(", topic=" + v.Topic)
+	}
+	if len(v.Services) > 0 {
+		b.WriteString(", services=" + strings.Join(v.Services, ","))
+	}
+	if v.Hint != "" {
+		b.WriteString(", hint: " + v.Hint)
+	}
+	return b.String()
+}
+
+// ValidateTopology checks that the consumer groups declared by each service and
+// the topics configured for the deployment are consistent:
+//
+//   - a group ID is only shared by declarations consuming the same topic set,
+//   - every configured topic is consumed by at least one group,
+//   - every produced or consumed topic is a configured topic,
+//   - every consumed topic exists on the broker.
+//
+// The broker check is skipped when the cluster cannot be reached. All
+// violations are returned together in an *errs.MultiError whose elements are
+// *TopologyViolation.
+func ValidateTopology(ctx context.Context, conf *Config, topics []string, declarations []GroupDeclaration) error {
+	violations := validateDeclarations(topics, declarations)
+	if existing, err := brokerTopics(ctx, conf); err == nil {
+		for _, id := range groupIDs(declarations) {
+			for _, topic := range unionTopics(declarations, id) {
+				if existing[topic] {
+					continue
+				}
+				violations.Append(&TopologyViolation{
+					Kind:  ViolationTopicMissingBroker,
+					Group: id,
+					Topic: topic,
+					Hint:  "create the topic on the broker before starting the consumers",
+				})
+			}
+		}
+	}
+	return violations.ErrorOrNil()
+}
+
+// ValidateTopologyOffline runs the checks of ValidateTopology that need no
+// broker connection.
+func ValidateTopologyOffline(topics []string, declarations []GroupDeclaration) error {
+	violations := validateDeclarations(topics, declarations)
+	return violations.ErrorOrNil()
+}
+
+func validateDeclarations(topics []string, declarations []GroupDeclaration) *errs.MultiError {
+	violations := &errs.MultiError{}
+	configured := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		configured[topic] = true
+	}
+	consumed := make(map[string]bool)
+	for _, decl := range declarations {
+		for _, topic := range decl.Consumes {
+			consumed[topic] = true
+		}
+	}
+
+	for _, id := range groupIDs(declarations) {
+		if v := checkGroupReuse(id, declarations); v != nil {
+			violations.Append(v)
+		}
+	}
+	for _, topic := range topics {
+		if !consumed[topic] {
+			violations.Append(&TopologyViolation{
+				Kind:  ViolationTopicNotConsumed,
+				Topic: topic,
+				Hint:  "declare a consumer group for the topic or remove it from the configuration",
+			})
+		}
+	}
+	reported := make(map[string]bool)
+	for _, decl := range declarations {
+		for _, topic := range append(append([]string(nil), decl.Produces...), decl.Consumes...) {
+			if configured[topic] || reported[topic] {
+				continue
+			}
+			reported[topic] = true
+			violations.Append(&TopologyViolation{
+				Kind:     ViolationTopicNotDeclared,
+				Topic:    topic,
+				Services: servicesUsing(declarations, topic),
+				Hint:     "add the topic to the kafka configuration or fix the topic name in the service",
+			})
+		}
+	}
+	return violations
+}
+
+// groupIDs returns the non-empty group IDs of declarations in order of first
+// appearance.
+func groupIDs(declarations []GroupDeclaration) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, decl := range declarations {
+		if decl.GroupID != "" && !seen[decl.GroupID] {
+			seen[decl.GroupID] = true
+			ids = append(ids, decl.GroupID)
+		}
+	}
+	return ids
+}
+
+func checkGroupReuse(id string, decls []GroupDeclaration) *TopologyViolation {
+	sets := make(map[string]bool)
+	var services []string
+	for _, decl := range decls {
+		if decl.GroupID != id {
+			continue
+		}
+		sets[topicSetKey(decl.Consumes)] = true
+		services = append(services, decl.Service)
+	}
+	if len(sets) < 2 {
+		return nil
+	}
+	return &TopologyViolation{
+		Kind:     ViolationGroupReused,
+		Group:    id,
+		Services: dedupSorted(services),
+		Hint:     "give each service its own group ID; a shared group splits partitions between services",
+	}
+}
+
+func topicSetKey(topics []string) string {
+	return strings.Join(dedupSorted(topics), "\x00")
+}
+
+func unionTopics(decls []GroupDeclaration, id string) []string {
+	var topics []string
+	for _, decl := range decls {
+		if decl.GroupID != id {
+			continue
+		}
+		topics = append(topics, decl.Consumes...)
+	}
+	return dedupSorted(topics)
+}
+
+func servicesUsing(decls []GroupDeclaration, topic string) []string {
+	var services []string
+	for _, decl := range decls {
+		for _, t := range append(append([]string(nil), decl.Produces...), decl.Consumes...) {
+			if t == topic {
+				services = append(services, decl.Service)
+				break
+			}
+		}
+	}
+	return dedupSorted(services)
+}
+
+func dedupSorted(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	out := make([]string, 0, len(s))
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func brokerTopics(ctx context.Context, conf *Config) (map[string]bool, error) {
+	if conf == nil || len(conf.Addr) == 0 {
+		return nil, errs.ErrConfig.WrapMsg("no kafka address")
+	}
+	addrs, err := network.NormalizeAddrs(conf.Addr)
+	if err != nil {
+		return nil, err
+	}
+	kfk, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, false)
+	if err != nil {
+		return nil, err
+	}
+	timeout := topologyDialTimeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+	kfk.Net.DialTimeout = timeout
+	kfk.Metadata.Retry.Max = 0
+	cli, err := sarama.NewClient(addrs, kfk)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "NewClient failed", "addr", addrs)
+	}
+	defer cli.Close()
+	list, err := cli.Topics()
+	if err != nil {
+		return nil, errs.WrapMsg(err, "Failed to list topics")
+	}
+	existing := make(map[string]bool, len(list))
+	for _, topic := range list {
+		existing[topic] = true
+	}
+	return existing, nil
+}
@@ -0,0 +1,92 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"gopkg.in/yaml.v3"
+)
+
+type topologyFixture struct {
+	Topics       []string           `yaml:"topics"`
+	BrokerTopics []string           `yaml:"brokerTopics"`
+	Declarations []GroupDeclaration `yaml:"declarations"`
+	Want         []struct {
+		Kind  string `yaml:"kind"`
+		Group string `yaml:"group"`
+		Topic string `yaml:"topic"`
+	} `yaml:"want"`
+}
+
+func newTopicsBroker(t *testing.T, topics []string) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	metadata := sarama.NewMockMetadataResponse(t).
+		SetBroker(broker.Addr(), broker.BrokerID()).
+		SetController(broker.BrokerID())
+	for _, topic := range topics {
+		metadata = metadata.SetLeader(topic, 0, broker.BrokerID())
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":    metadata,
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+	})
+	t.Cleanup(broker.Close)
+	return broker
+}
+
+func TestValidateTopologyFixtures(t *testing.T) {
+	files, err := filepath.Glob("testdata/topology/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".yaml"), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fx topologyFixture
+			if err := yaml.Unmarshal(data, &fx); err != nil {
+				t.Fatal(err)
+			}
+			// Nothing listens on port 1, so fixtures without broker topics run offline.
+			conf := &Config{Addr: []string{"127.0.0.1:1"}}
+			if fx.BrokerTopics != nil {
+				conf.Addr = []string{newTopicsBroker(t, fx.BrokerTopics).Addr()}
+			}
+
+			err = ValidateTopology(context.Background(), conf, fx.Topics, fx.Declarations)
+			var got []*TopologyViolation
+			if err != nil {
+				var multi *errs.MultiError
+				if !errors.As(err, &multi) {
+					t.Fatalf("err %T is not a MultiError", err)
+				}
+				for _, e := range multi.Errors {
+					v, ok := e.(*TopologyViolation)
+					if !ok {
+						t.Fatalf("unexpected element %T: %v", e, e)
+					}
+					if v.Hint == "" {
+						t.Errorf("violation without hint: %v", v)
+					}
+					got = append(got, v)
+				}
+			}
+			if len(got) != len(fx.Want) {
+				t.Fatalf("got %d violations, want %d: %v", len(got), len(fx.Want), err)
+			}
+			for i, want := range fx.Want {
+				if got[i].Kind != want.Kind || got[i].Group != want.Group || got[i].Topic != want.Topic {
+					t.Errorf("violation %d = %+v, want %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
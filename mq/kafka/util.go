@@ -25,10 +25,16 @@ func GetMQHeaderWithContext(ctx context.Context) ([]sarama.RecordHeader, error)
 }
 
 // GetContextWithMQHeader creates a context from message queue headers.
+// Headers other than the ones set by GetMQHeaderWithContext are ignored.
 func GetContextWithMQHeader(header []*sarama.RecordHeader) context.Context {
-	var values []string
+	values := make(map[string]string, len(header))
 	for _, recordHeader := range header {
-		values = append(values, string(recordHeader.Value))
+		values[string(recordHeader.Key)] = string(recordHeader.Value)
 	}
-	return mcontext.WithMustInfoCtx(values) // Attach extracted values to context
+	return mcontext.WithMustInfoCtx([]string{ // Attach extracted values to context
+		values[constant.OperationID],
+		values[constant.OpUserID],
+		values[constant.OpUserPlatform],
+		values[constant.ConnID],
+	})
 }
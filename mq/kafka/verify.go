@@ -16,18 +16,26 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/network"
 )
 
 func CheckTopics(ctx context.Context, conf *Config, topics []string) error {
+	addrs, err := network.NormalizeAddrs(conf.Addr)
+	if err != nil {
+		return err
+	}
 	kfk, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, false)
 	if err != nil {
 		return err
 	}
-	cli, err := sarama.NewClient(conf.Addr, kfk)
+	cli, err := sarama.NewClient(addrs, kfk)
 	if err != nil {
 		return errs.WrapMsg(err, "NewClient failed", "config: ", fmt.Sprintf("%+v", conf))
 	}
@@ -52,13 +60,17 @@ func CheckTopics(ctx context.Context, conf *Config, topics []string) error {
 }
 
 func CheckHealth(ctx context.Context, conf *Config) error {
+	addrs, err := network.NormalizeAddrs(conf.Addr)
+	if err != nil {
+		return err
+	}
 	kfk, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, false)
 	if err != nil {
 		return err
 	}
-	cli, err := sarama.NewClient(conf.Addr, kfk)
+	cli, err := sarama.NewClient(addrs, kfk)
 	if err != nil {
-		return errs.WrapMsg(err, "NewClient failed", "config: ", fmt.Sprintf("%+v", conf))
+		return clientError(ctx, conf, addrs, err)
 	}
 	defer cli.Close()
 
@@ -77,3 +89,147 @@ func CheckHealth(ctx context.Context, conf *Config) error {
 
 	return nil
 }
+
+// clientError explains a sarama.NewClient failure with the error of the
+// first broker of addrs that cannot be reached, see ProbeBroker: the client
+// only reports that it ran out of brokers, hiding the dial or TLS handshake
+// error.
+func clientError(ctx context.Context, conf *Config, addrs []string, err error) error {
+	for _, addr := range addrs {
+		if probeErr := ProbeBroker(ctx, conf, addr); probeErr != nil {
+			return errs.WrapMsg(err, "NewClient failed", "broker", addr, "err", errs.Unwrap(probeErr).Error())
+		}
+	}
+	return errs.WrapMsg(err, "NewClient failed", "addr", addrs)
+}
+
+const apiKeyFetch = 1
+
+// fetchVersions maps the highest Fetch request version a broker supports to
+// the first Kafka release supporting it, in descending order.
+var fetchVersions = []struct {
+	fetch   int16
+	release string
+}{
+	{16, "3.7.0"},
+	{15, "3.5.0"},
+	{13, "3.1.0"},
+	{12, "2.7.0"},
+	{11, "2.3.0"},
+	{10, "2.1.0"},
+	{8, "2.0.0"},
+	{7, "1.1.0"},
+	{6, "1.0.0"},
+	{4, "0.11.0"},
+}
+
+// inferBrokerVersion returns the oldest Kafka release that supports the API
+// versions a broker advertises. Brokers only report API versions, so the
+// result is a lower bound of the actual release.
+func inferBrokerVersion(keys []sarama.ApiVersionsResponseKey) string {
+	for _, k := range keys {
+		if k.ApiKey != apiKeyFetch {
+			continue
+		}
+		for _, v := range fetchVersions {
+			if k.MaxVersion >= v.fetch {
+				return v.release
+			}
+		}
+	}
+	return "0.10.0"
+}
+
+// BrokerVersions queries ApiVersions from every broker and returns the
+// inferred Kafka release of each, by broker address.
+func BrokerVersions(ctx context.Context, conf *Config) (map[string]string, error) {
+	addrs, err := network.NormalizeAddrs(conf.Addr)
+	if err != nil {
+		return nil, err
+	}
+	kfk, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, false)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := sarama.NewClient(addrs, kfk)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "NewClient failed", "addr", addrs)
+	}
+	defer cli.Close()
+
+	versions := make(map[string]string)
+	for _, broker := range cli.Brokers() {
+		if err := ctx.Err(); err != nil {
+			return nil, errs.Wrap(err)
+		}
+		if err := broker.Open(kfk); err != nil && !errors.Is(err, sarama.ErrAlreadyConnected) {
+			return nil, errs.WrapMsg(err, "failed to open broker", "broker", broker.Addr())
+		}
+		resp, err := broker.ApiVersions(&sarama.ApiVersionsRequest{})
+		if err != nil {
+			return nil, errs.WrapMsg(err, "ApiVersions failed", "broker", broker.Addr())
+		}
+		versions[broker.Addr()] = inferBrokerVersion(resp.ApiKeys)
+	}
+	return versions, nil
+}
+
+// DefaultMinVersion is the oldest Kafka release supporting the protocol the
+// consumers of BuildConsumerGroupConfig speak.
+var DefaultMinVersion = consumerVersion.String()
+
+// CheckMinVersion returns the inferred release of every broker, see
+// BrokerVersions, and an errs.ErrComponentStart error naming the first broker
+// older than conf.MinVersion, or DefaultMinVersion.
+func CheckMinVersion(ctx context.Context, conf *Config) (map[string]string, error) {
+	minVersion := conf.MinVersion
+	if minVersion == "" {
+		minVersion = DefaultMinVersion
+	}
+	required, err := sarama.ParseKafkaVersion(minVersion)
+	if err != nil {
+		return nil, errs.ErrConfig.WrapMsg("invalid Kafka minVersion", "minVersion", minVersion, "err", err.Error())
+	}
+	versions, err := BrokerVersions(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(versions))
+	for addr := range versions {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		v, err := sarama.ParseKafkaVersion(versions[addr])
+		if err != nil {
+			return versions, errs.WrapMsg(err, "unknown Kafka version", "broker", addr, "version", versions[addr])
+		}
+		if !v.IsAtLeast(required) {
+			return versions, errs.ErrComponentStart.WrapMsg("Kafka broker older than the minimum version",
+				"broker", addr, "version", versions[addr], "minVersion", minVersion)
+		}
+	}
+	return versions, nil
+}
+
+// ProbeBroker connects to the single broker at addr, bypassing the cluster
+// metadata, and checks that it answers an ApiVersions request.
+func ProbeBroker(ctx context.Context, conf *Config, addr string) error {
+	kfk, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, false)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline)
+		kfk.Net.DialTimeout, kfk.Net.ReadTimeout, kfk.Net.WriteTimeout = timeout, timeout, timeout
+	}
+	broker := sarama.NewBroker(addr)
+	if err := broker.Open(kfk); err != nil {
+		return errs.WrapMsg(err, "failed to open broker", "broker", addr)
+	}
+	defer broker.Close()
+	if _, err := broker.ApiVersions(&sarama.ApiVersionsRequest{}); err != nil {
+		return errs.WrapMsg(err, "ApiVersions failed", "broker", addr)
+	}
+	return nil
+}
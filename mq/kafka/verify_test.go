@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+)
+
+func TestCheckHealthAddressForms(t *testing.T) {
+	ctx := context.Background()
+	newBroker := func(t *testing.T, addr string) *sarama.MockBroker {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Skipf("cannot listen on %s: %v", addr, err)
+		}
+		broker := sarama.NewMockBrokerListener(t, 1, lis)
+		broker.SetHandlerByMap(map[string]sarama.MockResponse{
+			"MetadataRequest": sarama.NewMockMetadataResponse(t).
+				SetBroker(broker.Addr(), broker.BrokerID()).
+				SetController(broker.BrokerID()),
+			"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		})
+		t.Cleanup(broker.Close)
+		return broker
+	}
+
+	for name, addr := range map[string]string{"ipv4": "127.0.0.1:0", "ipv6": "[::1]:0"} {
+		t.Run(name, func(t *testing.T) {
+			broker := newBroker(t, addr)
+			if err := CheckHealth(ctx, &Config{Addr: []string{broker.Addr()}}); err != nil {
+				t.Fatalf("CheckHealth(%s): %v", broker.Addr(), err)
+			}
+		})
+	}
+	t.Run("unbracketed ipv6", func(t *testing.T) {
+		err := CheckHealth(ctx, &Config{Addr: []string{"fd00::1:9092"}})
+		var codeErr errs.CodeError
+		if !errors.As(err, &codeErr) || codeErr.Code() != errs.ConfigError {
+			t.Fatalf("err = %v, want ErrConfig", err)
+		}
+	})
+}
+
+func TestBrokerVersions(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()),
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+	})
+	versions, err := BrokerVersions(context.Background(), &Config{Addr: []string{broker.Addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := versions[broker.Addr()]; v != "2.3.0" {
+		t.Fatalf("versions = %v, want 2.3.0 for %s", versions, broker.Addr())
+	}
+}
+
+func TestInferBrokerVersion(t *testing.T) {
+	for fetch, want := range map[int16]string{3: "0.10.0", 7: "1.1.0", 9: "2.0.0", 12: "2.7.0", 17: "3.7.0"} {
+		if got := inferBrokerVersion([]sarama.ApiVersionsResponseKey{{ApiKey: 0, MaxVersion: 9}, {ApiKey: apiKeyFetch, MaxVersion: fetch}}); got != want {
+			t.Errorf("Fetch v%d: got %s, want %s", fetch, got, want)
+		}
+	}
+}
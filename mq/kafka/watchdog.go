@@ -0,0 +1,283 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/statreg"
+)
+
+// Metrics reported to the recorder of WithHandlerMetrics.
+const (
+	// MetricHandlerDuration is the histogram of the time the handler took by
+	// topic, the hard timeout for the invocations abandoned.
+	MetricHandlerDuration = "kafka_handler"
+	// MetricHandlerMessages counts the messages by topic and outcome.
+	MetricHandlerMessages = "kafka_handler_messages"
+)
+
+// Outcomes of a handler invocation, see MetricHandlerMessages.
+const (
+	HandlerOK      = "ok"
+	HandlerFailed  = "failed"
+	HandlerTimeout = "timeout"
+)
+
+// Dead-letter reasons of the messages a WatchedHandler gives up on.
+const (
+	ReasonHandlerFailed  = "handler_failed"
+	ReasonHandlerTimeout = "handler_timeout"
+)
+
+// DefaultSlowThreshold is the handler duration above which a WatchedHandler
+// logs the stack of the handler.
+const DefaultSlowThreshold = 10 * time.Second
+
+// MessageHandler processes a single message. Returning an error sends the
+// message to the dead-letter function.
+type MessageHandler func(ctx context.Context, msg *sarama.ConsumerMessage) error
+
+type WatchdogOption func(*WatchedHandler)
+
+// WithSlowThreshold replaces DefaultSlowThreshold.
+func WithSlowThreshold(d time.Duration) WatchdogOption {
+	return func(h *WatchedHandler) {
+		h.slow = d
+	}
+}
+
+// WithHardTimeout abandons an invocation running longer than d: its context
+// is cancelled, the message goes to the dead-letter function with
+// ReasonHandlerTimeout and the partition moves on.
+//
+// Go cannot stop a goroutine, so an abandoned invocation that ignores its
+// context keeps running, and holding whatever it is stuck on, until it
+// returns. It may then run concurrently with the following messages of its
+// partition. Abandoned reports how many are still running; a steadily growing
+// value is a goroutine leak, calling for a fix of the handler rather than a
+// longer timeout.
+func WithHardTimeout(d time.Duration) WatchdogOption {
+	return func(h *WatchedHandler) {
+		h.hardTimeout = d
+	}
+}
+
+// WithHandlerMetrics reports every message to recorder, see
+// MetricHandlerDuration and MetricHandlerMessages.
+func WithHandlerMetrics(recorder statreg.Recorder) WatchdogOption {
+	return func(h *WatchedHandler) {
+		h.metrics = recorder
+	}
+}
+
+// WithHandlerDeadLetter routes the messages the handler failed on or was
+// abandoned on to fn. Without it they are logged and skipped.
+func WithHandlerDeadLetter(fn DeadLetterFunc) WatchdogOption {
+	return func(h *WatchedHandler) {
+		h.deadLetter = fn
+	}
+}
+
+// WatchedHandler is a sarama.ConsumerGroupHandler calling a MessageHandler
+// for every message, and marking it once handled. It measures each
+// invocation and logs a warning with the stack of the handler goroutine when
+// one runs longer than the slow threshold, so that a handler stuck on a lock
+// does not stall its partition silently.
+type WatchedHandler struct {
+	handle      MessageHandler
+	slow        time.Duration
+	hardTimeout time.Duration
+	metrics     statreg.Recorder
+	deadLetter  DeadLetterFunc
+	// warn logs slow invocations; replaced in tests.
+	warn func(ctx context.Context, msg string, err error, keysAndValues ...any)
+
+	abandoned atomic.Int64
+}
+
+func NewWatchedHandler(handle MessageHandler, opts ...WatchdogOption) *WatchedHandler {
+	h := &WatchedHandler{handle: handle, slow: DefaultSlowThreshold, warn: log.ZWarn}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Abandoned returns the number of invocations abandoned after the hard
+// timeout that are still running.
+func (h *WatchedHandler) Abandoned() int64 {
+	return h.abandoned.Load()
+}
+
+func (h *WatchedHandler) Setup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *WatchedHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *WatchedHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	// Without a hard timeout the handler runs on this goroutine.
+	gid := goroutineID()
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			h.consume(msg, gid)
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (h *WatchedHandler) consume(msg *sarama.ConsumerMessage, gid uint64) {
+	ctx := GetContextWithMQHeader(msg.Headers)
+	start := time.Now()
+	var (
+		err       error
+		abandoned bool
+	)
+	outcome := HandlerOK
+	if h.hardTimeout > 0 {
+		abandoned, err = h.invokeWithTimeout(ctx, msg)
+	} else {
+		stop := h.watch(ctx, msg, gid)
+		err = h.handle(ctx, msg)
+		stop()
+	}
+	elapsed := time.Since(start)
+	reason := ReasonHandlerFailed
+	switch {
+	case abandoned:
+		outcome, reason, elapsed = HandlerTimeout, ReasonHandlerTimeout, h.hardTimeout
+	case err != nil:
+		outcome = HandlerFailed
+	}
+	if h.metrics != nil {
+		h.metrics.ObserveDuration(MetricHandlerDuration, elapsed, msg.Topic)
+		h.metrics.Inc(MetricHandlerMessages, msg.Topic, outcome)
+	}
+	if err == nil {
+		return
+	}
+	if h.deadLetter == nil {
+		log.ZError(ctx, "drop kafka message", err, "reason", reason, "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
+		return
+	}
+	h.deadLetter(ctx, msg, reason, err)
+}
+
+// Result states of an invocation run with a hard timeout.
+const (
+	invocationRunning int32 = iota
+	invocationReturned
+	invocationAbandoned
+)
+
+// invokeWithTimeout runs the handler on its own goroutine, so that it can be
+// abandoned, and reports whether it was.
+func (h *WatchedHandler) invokeWithTimeout(ctx context.Context, msg *sarama.ConsumerMessage) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.hardTimeout)
+	var state atomic.Int32
+	gids := make(chan uint64, 1)
+	done := make(chan error, 1)
+	go func() {
+		defer cancel()
+		gids <- goroutineID()
+		done <- h.handle(ctx, msg)
+		if state.Swap(invocationReturned) == invocationAbandoned {
+			h.abandoned.Add(-1)
+		}
+	}()
+	stop := h.watch(ctx, msg, <-gids)
+	defer stop()
+	timer := time.NewTimer(h.hardTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return false, err
+	case <-timer.C:
+	}
+	h.abandoned.Add(1)
+	if state.Swap(invocationAbandoned) == invocationReturned {
+		// The handler returned meanwhile.
+		h.abandoned.Add(-1)
+		return false, <-done
+	}
+	return true, errs.ErrTimeout.WrapMsg("kafka handler abandoned", "timeout", h.hardTimeout,
+		"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
+}
+
+// watch logs the stack of goroutine gid if the invocation is still running
+// after the slow threshold. The returned function stops the watch.
+func (h *WatchedHandler) watch(ctx context.Context, msg *sarama.ConsumerMessage, gid uint64) (stop func()) {
+	if h.slow <= 0 {
+		return func() {}
+	}
+	timer := time.AfterFunc(h.slow, func() {
+		h.warn(ctx, "slow kafka handler", nil, "threshold", h.slow, "topic", msg.Topic,
+			"partition", msg.Partition, "offset", msg.Offset, "stack", goroutineStack(gid))
+	})
+	return func() { timer.Stop() }
+}
+
+// goroutineID returns the ID of the calling goroutine, as printed in stack
+// traces.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// goroutineStack returns the stack trace of the goroutine id, or an empty
+// string when it no longer exists.
+func goroutineStack(id uint64) string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	header := []byte("goroutine " + strconv.FormatUint(id, 10) + " [")
+	for len(buf) > 0 {
+		block := buf
+		if i := bytes.Index(buf, []byte("\n\n")); i >= 0 {
+			block, buf = buf[:i], buf[i+2:]
+		} else {
+			buf = nil
+		}
+		if bytes.HasPrefix(block, header) {
+			return string(block)
+		}
+	}
+	return ""
+}
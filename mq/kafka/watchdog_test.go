@@ -0,0 +1,225 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/utils/statreg"
+)
+
+type markSession struct {
+	fakeSession
+	mu     sync.Mutex
+	marked []int64
+}
+
+func (s *markSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type recordedOutcome struct {
+	d       time.Duration
+	outcome string
+}
+
+// handlerRecorder pairs the duration and outcome reported for each message.
+type handlerRecorder struct {
+	mu       sync.Mutex
+	d        time.Duration
+	outcomes []recordedOutcome
+}
+
+func (r *handlerRecorder) ObserveDuration(name string, d time.Duration, _ ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == MetricHandlerDuration {
+		r.d = d
+	}
+}
+
+func (r *handlerRecorder) Inc(name string, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == MetricHandlerMessages {
+		r.outcomes = append(r.outcomes, recordedOutcome{r.d, labels[1]})
+	}
+}
+
+// consumeAll runs h over msgs until the claim is drained.
+func consumeAll(h *WatchedHandler, msgs ...*sarama.ConsumerMessage) *markSession {
+	claim := &fakeClaim{msgs: make(chan *sarama.ConsumerMessage, len(msgs))}
+	for _, msg := range msgs {
+		claim.msgs <- msg
+	}
+	close(claim.msgs)
+	session := &markSession{fakeSession: fakeSession{ctx: context.Background()}}
+	_ = h.ConsumeClaim(session, claim)
+	return session
+}
+
+var stuckLock sync.Mutex
+
+// stuckHandler blocks on stuckLock, held by the test.
+func stuckHandler(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	if msg.Offset == 0 {
+		stuckLock.Lock()
+		defer stuckLock.Unlock()
+	}
+	return nil
+}
+
+func captureWatchdog(h *WatchedHandler) <-chan []any {
+	warnings := make(chan []any, 4)
+	h.warn = func(_ context.Context, msg string, _ error, keysAndValues ...any) {
+		warnings <- keysAndValues
+	}
+	return warnings
+}
+
+func stackOf(t *testing.T, keysAndValues []any) string {
+	t.Helper()
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == "stack" {
+			return keysAndValues[i+1].(string)
+		}
+	}
+	t.Fatalf("no stack in %v", keysAndValues)
+	return ""
+}
+
+func TestWatchedHandlerSlow(t *testing.T) {
+	recorder := &handlerRecorder{}
+	h := NewWatchedHandler(stuckHandler, WithSlowThreshold(20*time.Millisecond), WithHandlerMetrics(recorder))
+	warnings := captureWatchdog(h)
+
+	stuckLock.Lock()
+	done := make(chan *markSession)
+	go func() { done <- consumeAll(h, &sarama.ConsumerMessage{Topic: "toPush", Offset: 0}) }()
+	select {
+	case kv := <-warnings:
+		stack := stackOf(t, kv)
+		if !strings.Contains(stack, "kafka.stuckHandler") || !strings.Contains(stack, "sync.(*Mutex).Lock") {
+			t.Errorf("stack is not the one of the handler:\n%s", stack)
+		}
+		if strings.Contains(stack, "\n\n") {
+			t.Errorf("stack of several goroutines:\n%s", stack)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watchdog warning")
+	}
+	stuckLock.Unlock()
+	session := <-done
+	if len(session.marked) != 1 {
+		t.Fatalf("marked %v", session.marked)
+	}
+	if len(recorder.outcomes) != 1 || recorder.outcomes[0].outcome != HandlerOK || recorder.outcomes[0].d < 20*time.Millisecond {
+		t.Fatalf("outcomes = %+v", recorder.outcomes)
+	}
+
+	// Fast invocations are not reported.
+	consumeAll(h, &sarama.ConsumerMessage{Offset: 1}, &sarama.ConsumerMessage{Offset: 2})
+	time.Sleep(40 * time.Millisecond)
+	select {
+	case kv := <-warnings:
+		t.Fatalf("warning for a fast handler: %v", kv)
+	default:
+	}
+}
+
+func TestWatchedHandlerHardTimeout(t *testing.T) {
+	recorder := &handlerRecorder{}
+	var reasons []string
+	h := NewWatchedHandler(stuckHandler,
+		WithSlowThreshold(10*time.Millisecond),
+		WithHardTimeout(50*time.Millisecond),
+		WithHandlerMetrics(recorder),
+		WithHandlerDeadLetter(func(_ context.Context, msg *sarama.ConsumerMessage, reason string, err error) {
+			reasons = append(reasons, reason)
+		}))
+	captureWatchdog(h)
+
+	stuckLock.Lock()
+	session := consumeAll(h, &sarama.ConsumerMessage{Offset: 0}, &sarama.ConsumerMessage{Offset: 1})
+	if len(session.marked) != 2 || session.marked[1] != 1 {
+		t.Fatalf("the partition did not move on: marked %v", session.marked)
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonHandlerTimeout {
+		t.Fatalf("dead letters = %v", reasons)
+	}
+	if len(recorder.outcomes) != 2 || recorder.outcomes[0] != (recordedOutcome{50 * time.Millisecond, HandlerTimeout}) || recorder.outcomes[1].outcome != HandlerOK {
+		t.Fatalf("outcomes = %+v", recorder.outcomes)
+	}
+	if n := h.Abandoned(); n != 1 {
+		t.Fatalf("abandoned = %d, want 1", n)
+	}
+
+	stuckLock.Unlock()
+	for deadline := time.Now().Add(5 * time.Second); h.Abandoned() != 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("abandoned invocation still counted after it returned")
+		}
+	}
+}
+
+func TestWatchedHandlerFailure(t *testing.T) {
+	var reasons []string
+	h := NewWatchedHandler(func(context.Context, *sarama.ConsumerMessage) error { return errors.New("bad payload") },
+		WithHandlerDeadLetter(func(_ context.Context, _ *sarama.ConsumerMessage, reason string, _ error) {
+			reasons = append(reasons, reason)
+		}))
+	if session := consumeAll(h, &sarama.ConsumerMessage{}); len(session.marked) != 1 {
+		t.Fatalf("marked %v", session.marked)
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonHandlerFailed {
+		t.Fatalf("dead letters = %v", reasons)
+	}
+}
+
+func TestWatchedHandlerMetrics(t *testing.T) {
+	registry := statreg.NewRegistry()
+	h := NewWatchedHandler(func(_ context.Context, msg *sarama.ConsumerMessage) error {
+		if msg.Offset == 1 {
+			return errors.New("bad payload")
+		}
+		return nil
+	}, WithHandlerMetrics(statreg.NewRecorder(registry)))
+	consumeAll(h,
+		&sarama.ConsumerMessage{Topic: "toPush", Offset: 0},
+		&sarama.ConsumerMessage{Topic: "toPush", Offset: 1},
+		&sarama.ConsumerMessage{Topic: "toMongo", Offset: 0})
+
+	got := make(map[string]statreg.Sample)
+	for _, s := range registry.Snapshot() {
+		got[s.Name] = s
+	}
+	for name, count := range map[string]uint64{"kafka_handler_toPush_seconds": 2, "kafka_handler_toMongo_seconds": 1} {
+		if s := got[name]; s.Kind != statreg.KindHistogram || s.Count != count {
+			t.Errorf("%s = %+v, want a histogram of %d", name, s, count)
+		}
+	}
+	for name, value := range map[string]float64{
+		"kafka_handler_messages_toPush_ok_total":     1,
+		"kafka_handler_messages_toPush_failed_total": 1,
+		"kafka_handler_messages_toMongo_ok_total":    1,
+	} {
+		if s := got[name]; s.Kind != statreg.KindCounter || s.Value != value {
+			t.Errorf("%s = %+v, want %v", name, s, value)
+		}
+	}
+}
+
+func TestGoroutineStack(t *testing.T) {
+	if goroutineStack(goroutineID()) == "" || !strings.Contains(goroutineStack(goroutineID()), "TestGoroutineStack") {
+		t.Fatal("stack of the current goroutine not found")
+	}
+	if goroutineStack(1<<62) != "" {
+		t.Fatal("stack of a missing goroutine")
+	}
+}
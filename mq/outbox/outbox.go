@@ -0,0 +1,140 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package outbox publishes Kafka messages reliably alongside MongoDB writes:
+// events are stored in an outbox collection in the transaction of the
+// business write, and a Relay publishes them afterwards. Delivery is at least
+// once, never exactly once: consumers deduplicate with the HeaderIdempotencyKey
+// header.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mq/kafka"
+	"github.com/openimsdk/tools/utils/datautil"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/protobuf/proto"
+)
+
+// HeaderIdempotencyKey carries the idempotency key of every published event.
+const HeaderIdempotencyKey = "openim-idempotency-key"
+
+// OutboxEvent is a message to publish once the transaction writing it commits.
+type OutboxEvent struct {
+	Topic string
+	// Key selects the partition. Events with the same topic and key written
+	// one after the other are published in that order; see Relay for events
+	// written concurrently.
+	Key string
+	Msg proto.Message
+	// Headers are sent in addition to the operation headers of the writing
+	// context.
+	Headers map[string]string
+	// IdempotencyKey identifies the event for consumers, it defaults to the
+	// id of the outbox row.
+	IdempotencyKey string
+}
+
+type header struct {
+	Key   string `bson:"k"`
+	Value string `bson:"v"`
+}
+
+// record is an outbox row. PublishedAt is nil until the event is published.
+type record struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Topic          string             `bson:"topic"`
+	Key            string             `bson:"key"`
+	Value          []byte             `bson:"value"`
+	Headers        []header           `bson:"headers,omitempty"`
+	IdempotencyKey string             `bson:"idempotency_key,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at"`
+	PublishedAt    *time.Time         `bson:"published_at"`
+}
+
+// WriteWithOutbox runs businessWrite and stores events in the outbox
+// collection coll within one transaction of sess, so the events exist if and
+// only if the business write committed. Like mongo.Session.WithTransaction it
+// retries on transient transaction errors, so businessWrite must be safe to
+// run again. The operation headers of ctx, see kafka.GetMQHeaderWithContext,
+// are stored with the events. Transactions need a replica set or a sharded
+// cluster.
+func WriteWithOutbox(ctx context.Context, sess mongo.Session, coll *mongo.Collection, businessWrite func(sessCtx mongo.SessionContext) error, events []OutboxEvent) error {
+	records, err := newRecords(ctx, events)
+	if err != nil {
+		return err
+	}
+	_, err = sess.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (any, error) {
+		if err := businessWrite(sessCtx); err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, nil
+		}
+		docs := make([]any, len(records))
+		for i := range records {
+			docs[i] = records[i]
+		}
+		if _, err := coll.InsertMany(sessCtx, docs); err != nil {
+			return nil, errs.WrapMsg(err, "insert outbox events failed", "collection", coll.Name())
+		}
+		return nil, nil
+	})
+	return errs.WrapMsg(err, "outbox transaction failed")
+}
+
+func newRecords(ctx context.Context, events []OutboxEvent) ([]*record, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+	ctxHeaders, err := kafka.GetMQHeaderWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	records := make([]*record, len(events))
+	for i, ev := range events {
+		if ev.Topic == "" || ev.Key == "" {
+			return nil, errs.ErrArgs.WrapMsg("outbox event needs a topic and a key", "index", i)
+		}
+		value, err := proto.Marshal(ev.Msg)
+		if err != nil {
+			return nil, errs.WrapMsg(err, "marshal outbox event failed", "topic", ev.Topic)
+		}
+		if len(value) == 0 {
+			return nil, errs.ErrArgs.WrapMsg("outbox event message is empty", "topic", ev.Topic)
+		}
+		headers := make([]header, 0, len(ctxHeaders)+len(ev.Headers))
+		for _, h := range ctxHeaders {
+			headers = append(headers, header{Key: string(h.Key), Value: string(h.Value)})
+		}
+		for _, k := range datautil.Sort(datautil.Keys(ev.Headers), true) {
+			headers = append(headers, header{Key: k, Value: ev.Headers[k]})
+		}
+		records[i] = &record{
+			ID:             primitive.NewObjectID(),
+			Topic:          ev.Topic,
+			Key:            ev.Key,
+			Value:          value,
+			Headers:        headers,
+			IdempotencyKey: ev.IdempotencyKey,
+			CreatedAt:      now,
+		}
+	}
+	return records, nil
+}
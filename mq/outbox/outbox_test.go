@@ -0,0 +1,312 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/openimsdk/tools/mq/kafka"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var _ Publisher = (*kafka.Producer)(nil)
+
+type sent struct {
+	topic, key, value, idempotencyKey string
+	headers                           map[string]string
+}
+
+// fakePublisher records the sent events and fails the ones whose value is in
+// fail.
+type fakePublisher struct {
+	mu   sync.Mutex
+	sent []sent
+	fail map[string]bool
+}
+
+func (p *fakePublisher) SendBytes(_ context.Context, topic string, key string, value []byte, headers []sarama.RecordHeader) (int32, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail[string(value)] {
+		return 0, 0, errors.New("broker unavailable")
+	}
+	s := sent{topic: topic, key: key, value: string(value), headers: make(map[string]string)}
+	for _, h := range headers {
+		s.headers[string(h.Key)] = string(h.Value)
+	}
+	s.idempotencyKey = s.headers[HeaderIdempotencyKey]
+	p.sent = append(p.sent, s)
+	return 0, int64(len(p.sent)), nil
+}
+
+func commands(mt *mtest.T) []string {
+	var names []string
+	for _, ev := range mt.GetAllStartedEvents() {
+		names = append(names, ev.CommandName)
+	}
+	return names
+}
+
+func testContext() context.Context {
+	ctx := mcontext.SetOperationID(context.Background(), "op-1")
+	return mcontext.SetOpUserID(ctx, "u1")
+}
+
+func TestWriteWithOutbox(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	events := []OutboxEvent{
+		{Topic: "toPush", Key: "u1", Msg: wrapperspb.String("hello"), Headers: map[string]string{"kind": "chat"}},
+		{Topic: "toPush", Key: "u1", Msg: wrapperspb.String("again"), IdempotencyKey: "msg-2"},
+	}
+	// write runs WriteWithOutbox with a business write inserting a message.
+	write := func(mt *mtest.T, events []OutboxEvent) error {
+		sess, err := mt.Client.StartSession()
+		if err != nil {
+			mt.Fatal(err)
+		}
+		defer sess.EndSession(context.Background())
+		business := func(sessCtx mongo.SessionContext) error {
+			_, err := mt.DB.Collection("msg").InsertOne(sessCtx, bson.M{"content": "hello"})
+			return err
+		}
+		return WriteWithOutbox(testContext(), sess, mt.Coll, business, events)
+	}
+
+	mt.Run("commits both writes", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+		err := write(mt, events)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		started := mt.GetAllStartedEvents()
+		if got := commands(mt); len(got) != 3 || got[0] != "insert" || got[1] != "insert" || got[2] != "commitTransaction" {
+			mt.Fatalf("commands = %v", got)
+		}
+		outboxInsert := started[1].Command
+		if _, err := outboxInsert.LookupErr("txnNumber"); err != nil {
+			mt.Error("outbox insert outside the transaction")
+		}
+		docs, _ := outboxInsert.Lookup("documents").Array().Values()
+		if len(docs) != 2 {
+			mt.Fatalf("outbox documents = %s", outboxInsert)
+		}
+		var rec record
+		if err := bson.Unmarshal(docs[0].Document(), &rec); err != nil {
+			mt.Fatal(err)
+		}
+		headers := make(map[string]string)
+		for _, h := range rec.Headers {
+			headers[h.Key] = h.Value
+		}
+		if rec.Topic != "toPush" || rec.PublishedAt != nil || headers[constant.OperationID] != "op-1" || headers["kind"] != "chat" {
+			mt.Errorf("outbox row = %+v", rec)
+		}
+	})
+
+	mt.Run("business write fails", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 11000, Message: "duplicate key"}))
+		err := write(mt, events)
+		if err == nil {
+			mt.Fatal("no error")
+		}
+		inserts := 0
+		for _, name := range commands(mt) {
+			switch name {
+			case "insert":
+				inserts++
+			case "commitTransaction":
+				mt.Fatalf("commands = %v, transaction committed", commands(mt))
+			}
+		}
+		if inserts != 1 {
+			mt.Fatalf("commands = %v, want no outbox insert", commands(mt))
+		}
+	})
+
+	// The process fails between the business write and the outbox insert:
+	// the transaction is aborted, so neither write is kept.
+	mt.Run("outbox insert fails", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 8000, Message: "outbox unavailable"}),
+			mtest.CreateSuccessResponse(), // abortTransaction
+		)
+		err := write(mt, events)
+		if err == nil {
+			mt.Fatal("no error")
+		}
+		if got := commands(mt); len(got) != 3 || got[2] != "abortTransaction" {
+			mt.Fatalf("commands = %v, want insert, insert, abortTransaction", got)
+		}
+	})
+
+	mt.Run("invalid event", func(mt *mtest.T) {
+		err := write(mt, []OutboxEvent{{Topic: "toPush", Msg: wrapperspb.String("x")}})
+		if err == nil || len(commands(mt)) != 0 {
+			mt.Fatalf("err = %v, commands = %v", err, commands(mt))
+		}
+	})
+}
+
+func outboxRows(mt *mtest.T, recs ...record) bson.D {
+	docs := make([]bson.D, 0, len(recs))
+	for _, rec := range recs {
+		data, err := bson.Marshal(rec)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		var d bson.D
+		if err := bson.Unmarshal(data, &d); err != nil {
+			mt.Fatal(err)
+		}
+		docs = append(docs, d)
+	}
+	return mtest.CreateCursorResponse(0, mt.DB.Name()+"."+mt.Coll.Name(), mtest.FirstBatch, docs...)
+}
+
+func row(key, value string) record {
+	return record{ID: primitive.NewObjectID(), Topic: "toPush", Key: key, Value: []byte(value), CreatedAt: time.Now()}
+}
+
+func markedIDs(mt *mtest.T) []primitive.ObjectID {
+	for _, ev := range mt.GetAllStartedEvents() {
+		if ev.CommandName != "update" {
+			continue
+		}
+		updates, _ := ev.Command.Lookup("updates").Array().Values()
+		values, _ := updates[0].Document().Lookup("q", "_id", "$in").Array().Values()
+		ids := make([]primitive.ObjectID, len(values))
+		for i, v := range values {
+			ids[i] = v.ObjectID()
+		}
+		return ids
+	}
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("per key ordering", func(mt *mtest.T) {
+		a1, a2, b1 := row("a", "a1"), row("a", "a2"), row("b", "b1")
+		pub := &fakePublisher{fail: map[string]bool{"a1": true}}
+		relay := NewRelay(mt.Coll, pub)
+		mt.AddMockResponses(outboxRows(mt, a1, a2, b1), mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+		n, err := relay.RunOnce(context.Background())
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if n != 1 || len(pub.sent) != 1 || pub.sent[0].value != "b1" {
+			mt.Fatalf("published %d: %+v, want b1 only", n, pub.sent)
+		}
+		if pub.sent[0].idempotencyKey != b1.ID.Hex() {
+			mt.Errorf("idempotency key = %q, want %s", pub.sent[0].idempotencyKey, b1.ID.Hex())
+		}
+		if ids := markedIDs(mt); len(ids) != 1 || ids[0] != b1.ID {
+			mt.Errorf("marked %v, want %s", ids, b1.ID.Hex())
+		}
+		if relay.Failed() != 1 || relay.Published() != 1 {
+			mt.Errorf("failed = %d, published = %d", relay.Failed(), relay.Published())
+		}
+	})
+
+	// The relay crashes after publishing and before marking the events: they
+	// are published again, with the same idempotency keys.
+	mt.Run("crash before mark", func(mt *mtest.T) {
+		a1 := row("a", "a1")
+		a1.IdempotencyKey = "msg-1"
+		pub := &fakePublisher{}
+		relay := NewRelay(mt.Coll, pub)
+		mt.AddMockResponses(
+			outboxRows(mt, a1),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 91, Message: "shutdown in progress"}),
+			outboxRows(mt, a1),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+		if _, err := relay.RunOnce(context.Background()); err == nil {
+			mt.Fatal("mark failure not reported")
+		}
+		if n, err := relay.RunOnce(context.Background()); err != nil || n != 1 {
+			mt.Fatalf("second round = %d, %v", n, err)
+		}
+		if len(pub.sent) != 2 || pub.sent[0].idempotencyKey != "msg-1" || pub.sent[1].idempotencyKey != "msg-1" {
+			mt.Errorf("sent = %+v, want msg-1 twice", pub.sent)
+		}
+	})
+
+	mt.Run("cleanup", func(mt *mtest.T) {
+		now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		relay := NewRelay(mt.Coll, &fakePublisher{}, WithRelayRetention(time.Hour), WithRelayClock(func() time.Time { return now }))
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 4}))
+		n, err := relay.Cleanup(context.Background())
+		if err != nil || n != 4 {
+			mt.Fatalf("cleanup = %d, %v", n, err)
+		}
+		ev := mt.GetStartedEvent()
+		deletes, _ := ev.Command.Lookup("deletes").Array().Values()
+		cutoff := deletes[0].Document().Lookup("q", "published_at", "$lt").Time()
+		if !cutoff.Equal(now.Add(-time.Hour)) {
+			mt.Errorf("cutoff = %s", cutoff)
+		}
+	})
+
+	mt.Run("run waits after a failed batch", func(mt *mtest.T) {
+		pub := &fakePublisher{fail: map[string]bool{"a1": true, "b1": true}}
+		relay := NewRelay(mt.Coll, pub, WithRelayBatchSize(2), WithRelayRetention(0), WithRelayInterval(time.Hour))
+		for i := 0; i < 5; i++ {
+			mt.AddMockResponses(outboxRows(mt, row("a", "a1"), row("b", "b1")))
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			relay.Run(ctx)
+		}()
+		deadline := time.Now().Add(2 * time.Second)
+		for relay.Failed() < 2 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		// Give a spinning relay the time to fetch the batch again.
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		<-done
+		if relay.Failed() != 2 {
+			mt.Fatalf("failed = %d, want one attempt per event before the next tick", relay.Failed())
+		}
+	})
+
+	mt.Run("run drains until done", func(mt *mtest.T) {
+		pub := &fakePublisher{}
+		relay := NewRelay(mt.Coll, pub, WithRelayBatchSize(2), WithRelayRetention(0), WithRelayInterval(time.Hour))
+		mt.AddMockResponses(
+			outboxRows(mt, row("a", "a1"), row("b", "b1")),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}),
+			outboxRows(mt, row("a", "a2")),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			relay.Run(ctx)
+		}()
+		deadline := time.Now().Add(2 * time.Second)
+		for relay.Published() < 3 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		cancel()
+		<-done
+		if relay.Published() != 3 {
+			mt.Fatalf("published = %d, want 3", relay.Published())
+		}
+	})
+}
@@ -0,0 +1,261 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outbox
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/openimsdk/tools/db/mongoutil"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultInterval  = time.Second
+	defaultBatchSize = 100
+	defaultRetention = 24 * time.Hour
+	cleanupInterval  = time.Minute
+)
+
+// Publisher sends serialized events. *kafka.Producer implements it.
+type Publisher interface {
+	SendBytes(ctx context.Context, topic string, key string, value []byte, headers []sarama.RecordHeader) (int32, int64, error)
+}
+
+// Expectation returns the indexes the relay needs on the outbox collection,
+// for mongoutil.EnsureIndexes.
+func Expectation(collection string) mongoutil.CollectionExpectation {
+	return mongoutil.CollectionExpectation{
+		Collection: collection,
+		Indexes: []mongoutil.IndexExpectation{
+			{Keys: bson.D{{Key: "published_at", Value: 1}, {Key: "_id", Value: 1}}},
+		},
+	}
+}
+
+type RelayOption func(*Relay)
+
+// WithRelayInterval sets how often the outbox is polled, 1s by default.
+func WithRelayInterval(interval time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.interval = interval
+	}
+}
+
+// WithRelayBatchSize sets how many events are read per query, 100 by default.
+func WithRelayBatchSize(n int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = n
+	}
+}
+
+// WithRelayRetention sets how long published rows are kept, 24h by default.
+// Zero keeps them forever.
+func WithRelayRetention(retention time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.retention = retention
+	}
+}
+
+// WithRelayChangeStream wakes the relay up on every insert into the outbox
+// when the deployment supports change streams, instead of waiting for the
+// next poll. Polling goes on anyway.
+func WithRelayChangeStream() RelayOption {
+	return func(r *Relay) {
+		r.changeStream = true
+	}
+}
+
+// WithRelayClock replaces time.Now, for tests.
+func WithRelayClock(now func() time.Time) RelayOption {
+	return func(r *Relay) {
+		r.now = now
+	}
+}
+
+// Relay publishes the events of an outbox collection approximately in
+// insertion order, per writer: it sorts them by _id, an ObjectID created by
+// the writing process with second precision, so the events a process writes
+// one after the other keep their order, while events written concurrently,
+// by one process or several, may be published in either order. When an event
+// fails, the later events with its topic and key wait for the next round so
+// that order holds. An event is marked published only after the whole batch
+// is sent, so a crash in between publishes it again. Run a single relay per
+// outbox collection.
+type Relay struct {
+	coll         *mongo.Collection
+	publisher    Publisher
+	interval     time.Duration
+	batchSize    int
+	retention    time.Duration
+	changeStream bool
+	now          func() time.Time
+
+	lastCleanup time.Time
+	published   atomic.Int64
+	failed      atomic.Int64
+}
+
+func NewRelay(coll *mongo.Collection, publisher Publisher, opts ...RelayOption) *Relay {
+	r := &Relay{
+		coll:      coll,
+		publisher: publisher,
+		interval:  defaultInterval,
+		batchSize: defaultBatchSize,
+		retention: defaultRetention,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Published returns the number of events published so far.
+func (r *Relay) Published() int64 {
+	return r.published.Load()
+}
+
+// Failed returns the number of failed publish attempts so far.
+func (r *Relay) Failed() int64 {
+	return r.failed.Load()
+}
+
+// Run publishes events until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	wake := make(chan struct{}, 1)
+	if r.changeStream {
+		go r.watch(ctx, wake)
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		for {
+			fetched, published, err := r.relay(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.ZWarn(ctx, "outbox relay failed", err, "collection", r.coll.Name())
+				}
+				break
+			}
+			// A full batch that published nothing would be fetched again at
+			// once, e.g. while Kafka is down: wait for the next tick instead.
+			if fetched < r.batchSize || published == 0 {
+				break
+			}
+		}
+		if r.retention > 0 && r.now().Sub(r.lastCleanup) >= cleanupInterval {
+			if _, err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				log.ZWarn(ctx, "outbox cleanup failed", err, "collection", r.coll.Name())
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// RunOnce publishes one batch of unpublished events and returns how many were
+// published.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	_, published, err := r.relay(ctx)
+	return published, err
+}
+
+func (r *Relay) relay(ctx context.Context) (fetched, published int, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(r.batchSize))
+	records, err := mongoutil.Find[*record](ctx, r.coll, bson.M{"published_at": nil}, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	blocked := make(map[string]struct{})
+	ids := make([]primitive.ObjectID, 0, len(records))
+	for _, rec := range records {
+		orderKey := rec.Topic + "\x00" + rec.Key
+		if _, ok := blocked[orderKey]; ok {
+			continue
+		}
+		if _, _, err := r.publisher.SendBytes(ctx, rec.Topic, rec.Key, rec.Value, rec.kafkaHeaders()); err != nil {
+			blocked[orderKey] = struct{}{}
+			r.failed.Add(1)
+			log.ZWarn(ctx, "outbox publish failed", err, "topic", rec.Topic, "key", rec.Key, "id", rec.ID.Hex())
+			continue
+		}
+		ids = append(ids, rec.ID)
+	}
+	if len(ids) == 0 {
+		return len(records), 0, nil
+	}
+	update := bson.M{"$set": bson.M{"published_at": r.now()}}
+	if _, err := r.coll.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, update); err != nil {
+		return len(records), 0, errs.WrapMsg(err, "mark outbox events published failed", "count", len(ids))
+	}
+	r.published.Add(int64(len(ids)))
+	return len(records), len(ids), nil
+}
+
+// Cleanup deletes the rows published longer than the retention ago.
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	now := r.now()
+	r.lastCleanup = now
+	res, err := r.coll.DeleteMany(ctx, bson.M{"published_at": bson.M{"$lt": now.Add(-r.retention)}})
+	if err != nil {
+		return 0, errs.WrapMsg(err, "delete published outbox events failed")
+	}
+	return res.DeletedCount, nil
+}
+
+// watch signals wake on every insert into the outbox, until ctx is done or
+// change streams turn out to be unavailable.
+func (r *Relay) watch(ctx context.Context, wake chan<- struct{}) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "operationType", Value: "insert"}}}}}
+	stream, err := r.coll.Watch(ctx, pipeline)
+	if err != nil {
+		log.ZInfo(ctx, "outbox change stream unavailable, polling only", "collection", r.coll.Name(), "err", err.Error())
+		return
+	}
+	defer stream.Close(context.Background())
+	for stream.Next(ctx) {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+	if err := stream.Err(); err != nil && ctx.Err() == nil {
+		log.ZWarn(ctx, "outbox change stream stopped, polling only", err, "collection", r.coll.Name())
+	}
+}
+
+func (rec *record) kafkaHeaders() []sarama.RecordHeader {
+	headers := make([]sarama.RecordHeader, 0, len(rec.Headers)+1)
+	for _, h := range rec.Headers {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(h.Key), Value: []byte(h.Value)})
+	}
+	idempotencyKey := rec.IdempotencyKey
+	if idempotencyKey == "" {
+		idempotencyKey = rec.ID.Hex()
+	}
+	return append(headers, sarama.RecordHeader{Key: []byte(HeaderIdempotencyKey), Value: []byte(idempotencyKey)})
+}
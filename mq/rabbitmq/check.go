@@ -0,0 +1,335 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rabbitmq
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/tlsutil"
+)
+
+// Kinds of CheckError.
+const (
+	FailureUnreachable = "unreachable"
+	FailureTLS         = "tls"
+	FailureAuth        = "auth"
+	FailureVHost       = "vhost_access"
+	FailureMissing     = "missing_entity"
+)
+
+// CheckError is a failure found by Check. It unwraps to the errs sentinel of
+// its kind, so errors.Is(err, errs.ErrNoPermission) holds for auth and vhost
+// failures. Entity never contains the password.
+type CheckError struct {
+	Kind string
+	// Entity is what failed: the broker URI with the password redacted, or
+	// "exchange <name>" and "queue <name>" for missing entities.
+	Entity string
+	Detail string
+}
+
+func (e *CheckError) Error() string {
+	var b strings.Builder
+	b.WriteString("rabbitmq check: ")
+	b.WriteString(e.Kind)
+	b.WriteString(", " + e.Entity)
+	if e.Detail != "" {
+		b.WriteString(", " + e.Detail)
+	}
+	return b.String()
+}
+
+func (e *CheckError) Unwrap() error {
+	switch e.Kind {
+	case FailureAuth, FailureVHost:
+		return errs.ErrNoPermission
+	case FailureTLS, FailureMissing:
+		return errs.ErrConfig
+	default:
+		return errs.ErrDependencyUnavailable
+	}
+}
+
+// ServerInfo identifies the broker, as advertised in the connection
+// properties.
+type ServerInfo struct {
+	Product     string
+	Version     string
+	Platform    string
+	ClusterName string
+}
+
+// Dialer opens AMQP connections. It is replaced in tests.
+type Dialer interface {
+	Dial(ctx context.Context, uri amqp.URI, conf amqp.Config) (Connection, error)
+}
+
+// Connection is the part of *amqp.Connection used by Check.
+type Connection interface {
+	ServerProperties() amqp.Table
+	Channel() (Channel, error)
+	Close() error
+}
+
+// Channel is the part of *amqp.Channel used by Check.
+type Channel interface {
+	ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	Close() error
+}
+
+type checkOptions struct {
+	dialer Dialer
+}
+
+type CheckOption func(o *checkOptions)
+
+// WithDialer replaces the dialer connecting to the broker.
+func WithDialer(dialer Dialer) CheckOption {
+	return func(o *checkOptions) {
+		o.dialer = dialer
+	}
+}
+
+// Check connects to the broker, opens a channel and passively declares the
+// configured exchanges and queues. Missing entities are all returned in an
+// *errs.MultiError of *CheckError, together with the server info; any other
+// failure is a single *CheckError.
+func Check(ctx context.Context, conf *Config, opts ...CheckOption) (*ServerInfo, error) {
+	o := checkOptions{dialer: amqpDialer{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c := *conf
+	if err := c.ValidateAndSetDefaults(); err != nil {
+		return nil, err
+	}
+	uri, err := c.amqpURI()
+	if err != nil {
+		return nil, err
+	}
+	var amqpConf amqp.Config
+	if uri.Scheme == "amqps" {
+		if amqpConf.TLSClientConfig, err = c.TLS.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.DialTimeout)
+	defer cancel()
+
+	entity := redact(uri)
+	conn, err := o.dialer.Dial(ctx, uri, amqpConf)
+	if err != nil {
+		return nil, c.classify(err, entity)
+	}
+	defer conn.Close()
+	info := serverInfo(conn.ServerProperties())
+
+	missing := &errs.MultiError{}
+	var ch Channel
+	declare := func(entity string, declare func(ch Channel) error) error {
+		if ch == nil {
+			if ch, err = conn.Channel(); err != nil {
+				return c.classify(err, entity)
+			}
+		}
+		err := declare(ch)
+		if err == nil {
+			return nil
+		}
+		// The broker closes the channel on any declare failure.
+		_ = ch.Close()
+		ch = nil
+		var amqpErr *amqp.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+			missing.Append(&CheckError{Kind: FailureMissing, Entity: entity, Detail: "not declared on vhost " + uri.Vhost})
+			return nil
+		}
+		return c.classify(err, entity)
+	}
+	for _, name := range c.Exchanges {
+		err := declare("exchange "+name, func(ch Channel) error {
+			return ch.ExchangeDeclarePassive(name, amqp.ExchangeDirect, true, false, false, false, nil)
+		})
+		if err != nil {
+			return info, err
+		}
+	}
+	for _, name := range c.Queues {
+		err := declare("queue "+name, func(ch Channel) error {
+			_, err := ch.QueueDeclarePassive(name, true, false, false, false, nil)
+			return err
+		})
+		if err != nil {
+			return info, err
+		}
+	}
+	if ch != nil {
+		_ = ch.Close()
+	}
+	return info, missing.ErrorOrNil()
+}
+
+// classify turns a connection or channel failure into a *CheckError.
+func (c *Config) classify(err error, entity string) error {
+	kind := FailureUnreachable
+	var (
+		amqpErr      *amqp.Error
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		certErr      x509.CertificateInvalidError
+	)
+	switch {
+	case errors.Is(err, amqp.ErrVhost):
+		kind = FailureVHost
+	case errors.Is(err, amqp.ErrCredentials), errors.Is(err, amqp.ErrSASL):
+		kind = FailureAuth
+	case errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotAllowed:
+		// Connection.Open on a vhost that does not exist.
+		kind = FailureVHost
+	case errors.As(err, &amqpErr) && amqpErr.Code == amqp.AccessRefused:
+		kind = FailureAuth
+		if strings.Contains(amqpErr.Reason, "vhost") {
+			kind = FailureVHost
+		}
+	case errors.As(err, &recordErr), errors.As(err, &alertErr), errors.As(err, &verifyErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &certErr):
+		kind = FailureTLS
+	}
+	detail := err.Error()
+	if c.Password != "" {
+		detail = strings.ReplaceAll(detail, c.Password, redacted)
+	}
+	return &CheckError{Kind: kind, Entity: entity, Detail: detail}
+}
+
+const redacted = "xxxxx"
+
+// redact returns uri as a string with the password masked.
+func redact(uri amqp.URI) string {
+	if uri.Password != "" {
+		uri.Password = redacted
+	}
+	return uri.String()
+}
+
+func (c *Config) amqpURI() (amqp.URI, error) {
+	if c.URI != "" {
+		uri, err := parseURI(c.URI)
+		if err != nil {
+			return amqp.URI{}, err
+		}
+		if c.TLS.EnableTLS {
+			uri.Scheme = "amqps"
+		}
+		return uri, nil
+	}
+	uri := amqp.URI{Scheme: "amqp", Host: c.Host, Port: c.Port, Username: c.Username, Password: c.Password, Vhost: c.VHost}
+	if c.TLS.EnableTLS {
+		uri.Scheme = "amqps"
+	}
+	return uri, nil
+}
+
+// parseURI parses an AMQP URI without echoing it in the error, which would
+// leak the password.
+func parseURI(s string) (amqp.URI, error) {
+	uri, err := amqp.ParseURI(s)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return amqp.URI{}, errs.ErrConfig.WrapMsg("invalid rabbitmq uri", "err", err.Error())
+	}
+	return uri, nil
+}
+
+func (t *TLSConfig) tlsConfig() (*tls.Config, error) {
+	conf, err := tlsutil.Load(t.CACrt, t.ClientCrt, t.ClientKey, t.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	conf.ServerName = t.ServerName
+	return conf, nil
+}
+
+func serverInfo(props amqp.Table) *ServerInfo {
+	str := func(key string) string {
+		switch v := props[key].(type) {
+		case string:
+			return v
+		case []byte:
+			return string(v)
+		}
+		return ""
+	}
+	return &ServerInfo{
+		Product:     str("product"),
+		Version:     str("version"),
+		Platform:    str("platform"),
+		ClusterName: str("cluster_name"),
+	}
+}
+
+// amqpDialer dials with amqp.DialConfig, bounded by ctx.
+type amqpDialer struct{}
+
+func (amqpDialer) Dial(ctx context.Context, uri amqp.URI, conf amqp.Config) (Connection, error) {
+	conf.Dial = func(network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		}
+		return conn, nil
+	}
+	conn, err := amqp.DialConfig(uri.String(), conf)
+	if err != nil {
+		return nil, err
+	}
+	return amqpConn{conn}, nil
+}
+
+type amqpConn struct {
+	*amqp.Connection
+}
+
+func (c amqpConn) ServerProperties() amqp.Table {
+	return c.Properties
+}
+
+func (c amqpConn) Channel() (Channel, error) {
+	ch, err := c.Connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
@@ -0,0 +1,106 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rabbitmq
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/openimsdk/tools/env"
+	"github.com/openimsdk/tools/errs"
+)
+
+const (
+	DefaultPort    = 5672
+	DefaultTLSPort = 5671
+	DefaultVHost   = "/"
+
+	defaultDialTimeout = 10 * time.Second
+)
+
+type TLSConfig struct {
+	EnableTLS          bool   `yaml:"enableTLS"`
+	CACrt              string `yaml:"caCrt"`
+	ClientCrt          string `yaml:"clientCrt"`
+	ClientKey          string `yaml:"clientKey"`
+	ServerName         string `yaml:"serverName"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+// Config is the rabbitmq block of the service configuration. The broker is
+// given either as an amqp:// or amqps:// URI, or by its parts.
+type Config struct {
+	URI      string    `yaml:"uri"`
+	Host     string    `yaml:"host"`
+	Port     int       `yaml:"port"`
+	VHost    string    `yaml:"vhost"`
+	Username string    `yaml:"username"`
+	Password string    `yaml:"password"`
+	TLS      TLSConfig `yaml:"tls"`
+
+	// Exchanges and Queues must exist on the broker, see Check. They are
+	// declared by the deployment, never by the check.
+	Exchanges []string `yaml:"exchanges"`
+	Queues    []string `yaml:"queues"`
+
+	// DialTimeout bounds the connection and the AMQP handshake.
+	DialTimeout time.Duration `yaml:"dialTimeout"`
+}
+
+// ApplyEnv overrides the configuration with the RABBITMQ_URI, RABBITMQ_HOST,
+// RABBITMQ_PORT, RABBITMQ_VHOST, RABBITMQ_USERNAME and RABBITMQ_PASSWORD
+// environment variables, when set.
+func (c *Config) ApplyEnv() error {
+	c.URI = env.GetString("RABBITMQ_URI", c.URI)
+	c.Host = env.GetString("RABBITMQ_HOST", c.Host)
+	port, err := env.GetInt("RABBITMQ_PORT", c.Port)
+	if err != nil {
+		return errs.ErrConfig.WrapMsg("invalid RABBITMQ_PORT", "err", errs.Unwrap(err).Error())
+	}
+	c.Port = port
+	c.VHost = env.GetString("RABBITMQ_VHOST", c.VHost)
+	c.Username = env.GetString("RABBITMQ_USERNAME", c.Username)
+	c.Password = env.GetString("RABBITMQ_PASSWORD", c.Password)
+	return nil
+}
+
+// ValidateAndSetDefaults validates the configuration and sets default values.
+func (c *Config) ValidateAndSetDefaults() error {
+	if c.URI != "" {
+		if _, err := parseURI(c.URI); err != nil {
+			return err
+		}
+	} else {
+		if c.Host == "" {
+			return errs.ErrConfig.WrapMsg("rabbitmq uri and host are empty")
+		}
+		if c.Port == 0 {
+			c.Port = DefaultPort
+			if c.TLS.EnableTLS {
+				c.Port = DefaultTLSPort
+			}
+		}
+		if c.Port < 0 || c.Port > 65535 {
+			return errs.ErrConfig.WrapMsg("invalid rabbitmq port", "port", strconv.Itoa(c.Port))
+		}
+		if c.VHost == "" {
+			c.VHost = DefaultVHost
+		}
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = defaultDialTimeout
+	}
+	return nil
+}
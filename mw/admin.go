@@ -0,0 +1,62 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/config"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/openimsdk/tools/utils/datautil"
+)
+
+// RoleAdmin is the role RequireAdmin accepts by default.
+const RoleAdmin = "admin"
+
+// RequireAdmin rejects requests whose operating user has none of roles, or
+// RoleAdmin when no role is given. The roles are read with
+// mcontext.GetOpUserRoles, so an authentication middleware setting them, such
+// as GinParseToken, must run first. Rejections are reported with ReportDenial
+// under DenialRuleAdmin.
+func RequireAdmin(roles ...string) gin.HandlerFunc {
+	if len(roles) == 0 {
+		roles = []string{RoleAdmin}
+	}
+	return func(c *gin.Context) {
+		for _, role := range mcontext.GetOpUserRoles(c) {
+			if datautil.Contain(role, roles...) {
+				c.Next()
+				return
+			}
+		}
+		who := c.GetString(constant.OpUserID)
+		if who == "" {
+			who = c.ClientIP()
+		}
+		ReportDenial(c, Denial{Rule: DenialRuleAdmin, Reason: "missing admin role", Identity: who, Resource: c.Request.URL.Path})
+		apiresp.GinError(c, errs.ErrNoPermission.WrapMsg("admin role required", "path", c.Request.URL.Path))
+		c.Abort()
+	}
+}
+
+// TunablesHandlers returns the handlers serving r.Handler behind RequireAdmin,
+// for a route matching GET and PATCH:
+//
+//	router.Match([]string{http.MethodGet, http.MethodPatch}, "/tunables", mw.TunablesHandlers(config.DefaultTunables)...)
+func TunablesHandlers(r *config.TunableRegistry, roles ...string) gin.HandlersChain {
+	return gin.HandlersChain{RequireAdmin(roles...), gin.WrapH(r.Handler())}
+}
@@ -0,0 +1,81 @@
+package mw
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/config"
+	"github.com/openimsdk/tools/mcontext"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	denials := captureDenials(t)
+	before := denialCount(DenialRuleAdmin)
+	registry := config.NewTunableRegistry()
+	qps, err := config.RegisterTunable(registry, "rateLimit.qps", 100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-User"); id != "" {
+			c.Set(constant.OpUserID, id)
+		}
+		if roles := c.GetHeader("X-Roles"); roles != "" {
+			c.Set(mcontext.OpUserRoles, strings.Split(roles, ","))
+		}
+	})
+	r.Match([]string{http.MethodGet, http.MethodPatch}, "/tunables", TunablesHandlers(registry)...)
+
+	for _, tc := range []struct {
+		user     string
+		roles    string
+		allowed  bool
+		identity string
+	}{
+		{"", "", false, "192.0.2.1"},
+		{"u1", "user", false, "u1"},
+		{"u2", "user,admin", true, ""},
+	} {
+		req := httptest.NewRequest(http.MethodPatch, "/tunables", strings.NewReader(`{"rateLimit.qps":500}`))
+		req.Header.Set("X-User", tc.user)
+		req.Header.Set("X-Roles", tc.roles)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		var resp struct {
+			ErrCode int `json:"errCode"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("roles %q: decode %q: %v", tc.roles, rec.Body.String(), err)
+		}
+		if allowed := resp.ErrCode == 0; allowed != tc.allowed {
+			t.Errorf("roles %q: allowed = %v, want %v", tc.roles, allowed, tc.allowed)
+		}
+		if !tc.allowed && qps.Get() != 100 {
+			t.Fatalf("roles %q: tunable changed to %d", tc.roles, qps.Get())
+		}
+		got := denials()
+		if tc.allowed {
+			if len(got) != 0 {
+				t.Errorf("roles %q: denials %+v", tc.roles, got)
+			}
+			continue
+		}
+		want := Denial{Rule: DenialRuleAdmin, Reason: "missing admin role", Identity: tc.identity, Resource: "/tunables"}
+		if len(got) != 1 || got[0] != want {
+			t.Errorf("roles %q: denials = %+v, want exactly %+v", tc.roles, got, want)
+		}
+	}
+	if n := denialCount(DenialRuleAdmin) - before; n != 2 {
+		t.Errorf("admin denials counted %v times, want 2", n)
+	}
+	if qps.Get() != 500 {
+		t.Errorf("admin patch not applied: %d", qps.Get())
+	}
+}
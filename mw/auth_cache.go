@@ -0,0 +1,239 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openimsdk/tools/tokenverify"
+)
+
+const (
+	// DefaultAuthCacheTTL caps how long a verified token is trusted without
+	// being verified again.
+	DefaultAuthCacheTTL = 30 * time.Second
+	// DefaultAuthCacheSize is the number of tokens an AuthCache remembers.
+	DefaultAuthCacheSize = 10000
+)
+
+// RevocationEvent identifies revoked tokens. A non-empty TokenHash revokes
+// that token only, see TokenHash. Otherwise every token of UserID is revoked,
+// restricted to PlatformID when it is not zero.
+type RevocationEvent struct {
+	UserID     string
+	PlatformID int
+	TokenHash  string
+}
+
+// Revoker reports tokens that were revoked before they expired, e.g. on kick
+// or logout. IsRevoked is consulted whenever a token is verified; Subscribe
+// registers fn to be called for every revocation and returns a function
+// cancelling the subscription.
+type Revoker interface {
+	IsRevoked(ctx context.Context, token string, claims *tokenverify.Claims) (bool, error)
+	Subscribe(fn func(RevocationEvent)) (cancel func())
+}
+
+// TokenHash returns the key identifying token in an AuthCache and in
+// revocation events, so that tokens themselves are never kept or broadcast.
+func TokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authIdentity is what a verified token resolves to. It deliberately holds
+// no part of the token.
+type authIdentity struct {
+	UserID     string
+	PlatformID int
+	Roles      []string
+	// ExpiresAt is the expiry of the token, zero if it never expires.
+	ExpiresAt time.Time
+}
+
+type authEntry struct {
+	hash     string
+	identity authIdentity
+	deadline time.Time
+}
+
+// AuthCacheStats is a snapshot of the counters of an AuthCache.
+type AuthCacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Evicted uint64
+	Revoked uint64
+	Entries int
+}
+
+// HitRate returns the share of lookups answered from the cache.
+func (s AuthCacheStats) HitRate() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+type AuthCacheOption func(*AuthCache)
+
+// WithAuthCacheTTL caps how long a verification is reused, DefaultAuthCacheTTL
+// by default. Entries never outlive the token itself.
+func WithAuthCacheTTL(ttl time.Duration) AuthCacheOption {
+	return func(c *AuthCache) {
+		if ttl > 0 {
+			c.ttl = ttl
+		}
+	}
+}
+
+// WithAuthCacheSize bounds the number of entries, the least recently used
+// ones being evicted first. DefaultAuthCacheSize by default.
+func WithAuthCacheSize(size int) AuthCacheOption {
+	return func(c *AuthCache) {
+		if size > 0 {
+			c.size = size
+		}
+	}
+}
+
+// AuthCache remembers successful token verifications in process, keyed by
+// TokenHash, see WithAuthCache. Failed verifications are never cached.
+type AuthCache struct {
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List // of *authEntry, most recently used first
+	entries map[string]*list.Element
+	// epoch counts revocations, so that a verification that was in flight
+	// while a revocation arrived is not cached.
+	epoch uint64
+
+	hits, misses, evicted, revoked atomic.Uint64
+}
+
+func NewAuthCache(opts ...AuthCacheOption) *AuthCache {
+	c := &AuthCache{
+		ttl:     DefaultAuthCacheTTL,
+		size:    DefaultAuthCacheSize,
+		now:     time.Now,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// get returns the identity cached for hash, together with the epoch to pass
+// to add on a miss.
+func (c *AuthCache) get(hash string) (authIdentity, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[hash]; ok {
+		entry := elem.Value.(*authEntry)
+		if c.now().Before(entry.deadline) {
+			c.lru.MoveToFront(elem)
+			c.hits.Add(1)
+			return entry.identity, c.epoch, true
+		}
+		c.remove(elem)
+	}
+	c.misses.Add(1)
+	return authIdentity{}, c.epoch, false
+}
+
+// add caches identity until the token expires or the TTL elapses, whichever
+// comes first. It does nothing if a revocation happened since epoch was read.
+func (c *AuthCache) add(hash string, identity authIdentity, epoch uint64) {
+	now := c.now()
+	deadline := now.Add(c.ttl)
+	if !identity.ExpiresAt.IsZero() && identity.ExpiresAt.Before(deadline) {
+		deadline = identity.ExpiresAt
+	}
+	if !now.Before(deadline) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if epoch != c.epoch {
+		return
+	}
+	if elem, ok := c.entries[hash]; ok {
+		c.remove(elem)
+	}
+	c.entries[hash] = c.lru.PushFront(&authEntry{hash: hash, identity: identity, deadline: deadline})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.evicted.Add(1)
+	}
+}
+
+func (c *AuthCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*authEntry).hash)
+}
+
+// Attach subscribes the cache to revoker, so that revoked tokens are dropped
+// immediately instead of being honoured until their entry expires. detach
+// cancels the subscription, e.g. when the router using the cache is torn down.
+func (c *AuthCache) Attach(revoker Revoker) (detach func()) {
+	return revoker.Subscribe(c.Invalidate)
+}
+
+// Invalidate drops the entries matching ev, see Attach.
+func (c *AuthCache) Invalidate(ev RevocationEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	if ev.TokenHash != "" {
+		if elem, ok := c.entries[ev.TokenHash]; ok {
+			c.remove(elem)
+			c.revoked.Add(1)
+		}
+		return
+	}
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		id := elem.Value.(*authEntry).identity
+		if id.UserID == ev.UserID && (ev.PlatformID == 0 || id.PlatformID == ev.PlatformID) {
+			c.remove(elem)
+			c.revoked.Add(1)
+		}
+		elem = next
+	}
+}
+
+// Stats returns the current counters, e.g. to export the hit rate.
+func (c *AuthCache) Stats() AuthCacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+	return AuthCacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Evicted: c.evicted.Load(),
+		Revoked: c.revoked.Load(),
+		Entries: entries,
+	}
+}
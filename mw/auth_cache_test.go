@@ -0,0 +1,245 @@
+package mw
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/tokenverify"
+)
+
+var authSecret = []byte("auth-cache-test")
+
+// countingKeyfunc returns the test secret and counts the verifications.
+func countingKeyfunc(n *atomic.Int32) jwt.Keyfunc {
+	return func(*jwt.Token) (any, error) {
+		n.Add(1)
+		return authSecret, nil
+	}
+}
+
+func signToken(t *testing.T, userID string, platformID int, ttlDays int64) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenverify.BuildClaims(userID, platformID, ttlDays)).SignedString(authSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+type fakeRevoker struct {
+	mu      sync.Mutex
+	revoked map[string]bool // by userID
+	subs    map[int]func(RevocationEvent)
+	nextSub int
+}
+
+func (r *fakeRevoker) IsRevoked(_ context.Context, _ string, claims *tokenverify.Claims) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.revoked[claims.UserID], nil
+}
+
+func (r *fakeRevoker) Subscribe(fn func(RevocationEvent)) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.subs == nil {
+		r.subs = make(map[int]func(RevocationEvent))
+	}
+	id := r.nextSub
+	r.nextSub++
+	r.subs[id] = fn
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.subs, id)
+	}
+}
+
+func (r *fakeRevoker) subscribers() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.subs)
+}
+
+func (r *fakeRevoker) revoke(userID string) {
+	r.mu.Lock()
+	if r.revoked == nil {
+		r.revoked = make(map[string]bool)
+	}
+	r.revoked[userID] = true
+	subs := make([]func(RevocationEvent), 0, len(r.subs))
+	for _, fn := range r.subs {
+		subs = append(subs, fn)
+	}
+	r.mu.Unlock()
+	for _, fn := range subs {
+		fn(RevocationEvent{UserID: userID})
+	}
+}
+
+func authRouter(keyfunc jwt.Keyfunc, opts ...AuthOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinParseToken(keyfunc, nil, opts...))
+	r.POST("/me", func(c *gin.Context) {
+		c.String(http.StatusOK, "%s/%s", c.GetString(constant.OpUserID), c.GetString(constant.OpUserPlatform))
+	})
+	return r
+}
+
+func callAuth(r *gin.Engine, token string) string {
+	req := httptest.NewRequest(http.MethodPost, "/me", nil)
+	req.Header.Set(constant.Token, token)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec.Body.String()
+}
+
+func TestAuthCache(t *testing.T) {
+	var verified atomic.Int32
+	cache := NewAuthCache()
+	r := authRouter(countingKeyfunc(&verified), WithAuthCache(cache))
+	token := signToken(t, "u1", constant.IOSPlatformID, 1)
+
+	for i := 0; i < 3; i++ {
+		if body := callAuth(r, token); body != "u1/IOS" {
+			t.Fatalf("response %d = %s", i, body)
+		}
+	}
+	if n := verified.Load(); n != 1 {
+		t.Errorf("token verified %d times, want 1", n)
+	}
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if rate := stats.HitRate(); rate < 0.66 || rate > 0.67 {
+		t.Errorf("hit rate = %v", rate)
+	}
+
+	// Failures are never cached.
+	verified.Store(0)
+	for i := 0; i < 2; i++ {
+		if body := callAuth(r, token+"x"); !strings.Contains(body, fmt.Sprintf(`"errCode":%d`, errs.ArgsError)) {
+			t.Fatalf("forged token: %s", body)
+		}
+	}
+	if n := verified.Load(); n != 2 {
+		t.Errorf("forged token verified %d times, want 2", n)
+	}
+	if n := cache.Stats().Entries; n != 1 {
+		t.Errorf("entries = %d after failures, want 1", n)
+	}
+
+	for elem := cache.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*authEntry)
+		if entry.hash == token || strings.Contains(fmt.Sprintf("%+v", entry.identity), token) {
+			t.Fatal("cache entry holds the token")
+		}
+	}
+}
+
+func TestAuthCacheRevocation(t *testing.T) {
+	var verified atomic.Int32
+	revoker := &fakeRevoker{}
+	cache := NewAuthCache(WithAuthCacheTTL(time.Hour))
+	detach := cache.Attach(revoker)
+	r := authRouter(countingKeyfunc(&verified), WithRevoker(revoker), WithAuthCache(cache))
+	authRouter(countingKeyfunc(&verified), WithRevoker(revoker), WithAuthCache(cache))
+	if n := revoker.subscribers(); n != 1 {
+		t.Fatalf("%d subscriptions, want only the one of Attach", n)
+	}
+	u1 := signToken(t, "u1", constant.IOSPlatformID, 1)
+	u2 := signToken(t, "u2", constant.IOSPlatformID, 1)
+	callAuth(r, u1)
+	callAuth(r, u2)
+
+	revoker.revoke("u1")
+	if body := callAuth(r, u1); !strings.Contains(body, fmt.Sprintf(`"errCode":%d`, errs.TokenKickedError)) {
+		t.Fatalf("revoked token within the TTL: %s", body)
+	}
+	if body := callAuth(r, u2); body != "u2/IOS" {
+		t.Fatalf("other user: %s", body)
+	}
+	if stats := cache.Stats(); stats.Revoked != 1 || stats.Entries != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	detach()
+	if n := revoker.subscribers(); n != 0 {
+		t.Fatalf("%d subscriptions after detach", n)
+	}
+	revoker.revoke("u2")
+	if stats := cache.Stats(); stats.Revoked != 1 || stats.Entries != 1 {
+		t.Errorf("detached cache invalidated: %+v", stats)
+	}
+}
+
+func TestAuthCacheInFlightRevocation(t *testing.T) {
+	cache := NewAuthCache()
+	hash := TokenHash("token")
+	_, epoch, _ := cache.get(hash)
+	// The revocation lands while the token is being verified.
+	cache.Invalidate(RevocationEvent{UserID: "u1"})
+	cache.add(hash, authIdentity{UserID: "u1"}, epoch)
+	if _, _, ok := cache.get(hash); ok {
+		t.Fatal("verification started before the revocation was cached")
+	}
+}
+
+func TestAuthCacheLRU(t *testing.T) {
+	cache := NewAuthCache(WithAuthCacheSize(2))
+	_, epoch, _ := cache.get("")
+	cache.add("a", authIdentity{UserID: "a"}, epoch)
+	cache.add("b", authIdentity{UserID: "b"}, epoch)
+	cache.get("a") // b becomes the least recently used
+	cache.add("c", authIdentity{UserID: "c"}, epoch)
+
+	if _, _, ok := cache.get("b"); ok {
+		t.Error("least recently used entry kept")
+	}
+	for _, hash := range []string{"a", "c"} {
+		if _, _, ok := cache.get(hash); !ok {
+			t.Errorf("%s evicted", hash)
+		}
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Evicted != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestAuthCacheTTL(t *testing.T) {
+	now := time.Now()
+	cache := NewAuthCache(WithAuthCacheTTL(30 * time.Second))
+	cache.now = func() time.Time { return now }
+	_, epoch, _ := cache.get("")
+	cache.add("short", authIdentity{ExpiresAt: now.Add(5 * time.Second)}, epoch)
+	cache.add("long", authIdentity{ExpiresAt: now.Add(time.Hour)}, epoch)
+	cache.add("expired", authIdentity{ExpiresAt: now.Add(-time.Second)}, epoch)
+
+	now = now.Add(6 * time.Second)
+	if _, _, ok := cache.get("short"); ok {
+		t.Error("entry outlived its token")
+	}
+	if _, _, ok := cache.get("long"); !ok {
+		t.Error("entry expired before the cap")
+	}
+	now = now.Add(30 * time.Second)
+	if _, _, ok := cache.get("long"); ok {
+		t.Error("entry outlived the cap")
+	}
+	if n := cache.Stats().Entries; n != 0 {
+		t.Errorf("entries = %d, want 0", n)
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/bufpool"
+)
+
+// DefaultCaptureMaxBody is the number of request and response bytes kept by
+// GinCaptureFailures.
+const DefaultCaptureMaxBody = 16 << 10
+
+// DefaultCaptureDenylist lists the JSON keys, compared case-insensitively,
+// whose values are masked in captured bodies.
+var DefaultCaptureDenylist = []string{
+	"password", "newPassword", "oldPassword", "token", "accessToken", "refreshToken",
+	"secret", "appSecret", "privateKey", "authorization", "verifyCode",
+}
+
+const capturedRedacted = `"xxxxx"`
+
+// Snapshot is the exchange of a request that failed with a server error.
+// Bodies are truncated to the capture cap and their denylisted keys masked;
+// bodies that are not JSON are not kept.
+type Snapshot struct {
+	OperationID       string    `json:"operationID"`
+	Method            string    `json:"method"`
+	Path              string    `json:"path"`
+	Route             string    `json:"route"`
+	Status            int       `json:"status"`
+	ErrCode           int       `json:"errCode,omitempty"`
+	Error             string    `json:"error"`
+	Request           string    `json:"request,omitempty"`
+	RequestTruncated  bool      `json:"requestTruncated,omitempty"`
+	Response          string    `json:"response,omitempty"`
+	ResponseTruncated bool      `json:"responseTruncated,omitempty"`
+	Time              time.Time `json:"time"`
+}
+
+// CaptureStore persists snapshots, keyed by their OperationID, for ttl.
+type CaptureStore interface {
+	SaveSnapshot(ctx context.Context, s *Snapshot, ttl time.Duration) error
+}
+
+type captureConfig struct {
+	maxBody int
+	deny    map[string]bool
+	skip    map[string]bool
+	store   CaptureStore
+	ttl     time.Duration
+}
+
+// CaptureOption configures GinCaptureFailures.
+type CaptureOption func(*captureConfig)
+
+// WithCaptureMaxBody sets the number of body bytes kept, see
+// DefaultCaptureMaxBody.
+func WithCaptureMaxBody(n int) CaptureOption {
+	return func(c *captureConfig) {
+		c.maxBody = n
+	}
+}
+
+// WithCaptureDenylist replaces DefaultCaptureDenylist.
+func WithCaptureDenylist(keys ...string) CaptureOption {
+	return func(c *captureConfig) {
+		c.deny = denySet(keys)
+	}
+}
+
+// WithCaptureSkipRoutes disables capture for routes, as registered with gin,
+// whose payloads must never be recorded.
+func WithCaptureSkipRoutes(routes ...string) CaptureOption {
+	return func(c *captureConfig) {
+		for _, route := range routes {
+			c.skip[route] = true
+		}
+	}
+}
+
+// WithCaptureStore saves snapshots to store for ttl, in addition to logging
+// them.
+func WithCaptureStore(store CaptureStore, ttl time.Duration) CaptureOption {
+	return func(c *captureConfig) {
+		c.store = store
+		c.ttl = ttl
+	}
+}
+
+func denySet(keys []string) map[string]bool {
+	deny := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		deny[strings.ToLower(key)] = true
+	}
+	return deny
+}
+
+// GinCaptureFailures buffers the request and response bodies of every request
+// and, when the handler panics or answers with a code whose category maps to
+// a 5xx status (see apiresp.HTTPStatus), logs the redacted exchange at Error
+// level. Buffers come from bufpool and are released once the request is
+// done, successful requests pay nothing more.
+func GinCaptureFailures(opts ...CaptureOption) gin.HandlerFunc {
+	conf := captureConfig{
+		maxBody: DefaultCaptureMaxBody,
+		deny:    denySet(DefaultCaptureDenylist),
+		skip:    make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return func(c *gin.Context) {
+		if conf.skip[c.FullPath()] {
+			c.Next()
+			return
+		}
+		req, reqTruncated, err := conf.bufferRequest(c.Request)
+		if err != nil {
+			apiresp.GinError(c, errs.ErrArgs.WrapMsg("read request body failed", "err", err.Error()))
+			c.Abort()
+			return
+		}
+		w := &captureWriter{ResponseWriter: c.Writer, buf: bufpool.Get(conf.maxBody), max: conf.maxBody}
+		c.Writer = w
+		defer func() {
+			r := recover()
+			if r != nil {
+				conf.capture(c, req, reqTruncated, w, errs.ErrPanic(r))
+			}
+			c.Writer = w.ResponseWriter
+			bufpool.Put(req)
+			bufpool.Put(w.buf)
+			if r != nil {
+				panic(r)
+			}
+		}()
+		c.Next()
+		if err := failure(c); err != nil {
+			conf.capture(c, req, reqTruncated, w, err)
+		}
+	}
+}
+
+// failure returns the server error the request ended with, if any.
+func failure(c *gin.Context) error {
+	if resp := apiresp.GetGinApiResponse(c); resp != nil && resp.ErrCode != 0 {
+		if apiresp.HTTPStatus(errs.CodeCategory(resp.ErrCode)) < http.StatusInternalServerError {
+			return nil
+		}
+		return errs.NewCodeError(resp.ErrCode, resp.ErrMsg).WithDetail(resp.ErrDlt)
+	}
+	if status := c.Writer.Status(); status >= http.StatusInternalServerError {
+		return errs.ErrInternalServer.WrapMsg("handler answered with a server error status", "status", status)
+	}
+	return nil
+}
+
+// bufferRequest reads up to maxBody bytes of the request body and puts them
+// back in front of the rest of the body for the handler.
+func (conf *captureConfig) bufferRequest(r *http.Request) (*bufpool.Buffer, bool, error) {
+	hint := int(r.ContentLength)
+	if hint < 0 || hint > conf.maxBody {
+		hint = conf.maxBody
+	}
+	buf := bufpool.Get(hint)
+	if r.Body == nil || r.Body == http.NoBody {
+		return buf, false, nil
+	}
+	if _, err := io.Copy(buf, io.LimitReader(r.Body, int64(conf.maxBody)+1)); err != nil {
+		bufpool.Put(buf)
+		return nil, false, err
+	}
+	r.Body = replayBody{Reader: io.MultiReader(bytes.NewReader(buf.Bytes()), r.Body), Closer: r.Body}
+	return buf, buf.Len() > conf.maxBody, nil
+}
+
+func (conf *captureConfig) capture(c *gin.Context, req *bufpool.Buffer, reqTruncated bool, w *captureWriter, err error) {
+	s := &Snapshot{
+		OperationID:       c.GetString(constant.OperationID),
+		Method:            c.Request.Method,
+		Path:              c.Request.URL.Path,
+		Route:             c.FullPath(),
+		Status:            w.Status(),
+		ErrCode:           errs.Code(err),
+		Error:             errs.Unwrap(err).Error(),
+		RequestTruncated:  reqTruncated,
+		ResponseTruncated: w.truncated,
+		Time:              time.Now(),
+	}
+	if s.OperationID == "" {
+		s.OperationID = c.GetHeader(constant.OperationID)
+	}
+	if isJSON(c.ContentType()) {
+		body := req.Bytes()
+		if len(body) > conf.maxBody {
+			body = body[:conf.maxBody]
+		}
+		s.Request = string(redactJSON(body, conf.deny))
+	}
+	if isJSON(w.Header().Get("Content-Type")) {
+		s.Response = string(redactJSON(w.buf.Bytes(), conf.deny))
+	}
+	log.ZError(c, "request failed, snapshot captured", err, "method", s.Method, "path", s.Path, "status", s.Status,
+		"request", s.Request, "requestTruncated", s.RequestTruncated, "response", s.Response, "responseTruncated", s.ResponseTruncated)
+	if conf.store == nil {
+		return
+	}
+	if err := conf.store.SaveSnapshot(c, s, conf.ttl); err != nil {
+		log.ZWarn(c, "save request snapshot failed", err, "operationID", s.OperationID)
+	}
+}
+
+func isJSON(contentType string) bool {
+	return contentType == "" || strings.Contains(contentType, "json")
+}
+
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// captureWriter keeps a copy of the first max bytes of the response.
+type captureWriter struct {
+	gin.ResponseWriter
+	buf       *bufpool.Buffer
+	max       int
+	truncated bool
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.keep(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) keep(p []byte) {
+	room := w.max - w.buf.Len()
+	if len(p) > room {
+		w.truncated = true
+		p = p[:room]
+	}
+	_, _ = w.buf.Write(p)
+}
+
+// redactJSON masks the values of the denylisted keys of data. It scans the
+// bytes rather than decoding them so that truncated bodies are redacted too.
+func redactJSON(data []byte, deny map[string]bool) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		if data[i] != '"' {
+			out = append(out, data[i])
+			i++
+			continue
+		}
+		end := jsonStringEnd(data, i)
+		out = append(out, data[i:end]...)
+		key := data[i:end]
+		i = end
+		colon := skipJSONSpace(data, i)
+		if colon >= len(data) || data[colon] != ':' || !deny[strings.ToLower(jsonKey(key))] {
+			continue
+		}
+		value := skipJSONSpace(data, colon+1)
+		out = append(out, data[i:value]...)
+		out = append(out, capturedRedacted...)
+		i = jsonValueEnd(data, value)
+	}
+	return out
+}
+
+func jsonKey(quoted []byte) string {
+	if s, err := strconv.Unquote(string(quoted)); err == nil {
+		return s
+	}
+	return strings.Trim(string(quoted), `"`)
+}
+
+// jsonStringEnd returns the index after the string starting at data[i].
+func jsonStringEnd(data []byte, i int) int {
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(data)
+}
+
+func skipJSONSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\r' || data[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// jsonValueEnd returns the index after the value starting at data[i].
+func jsonValueEnd(data []byte, i int) int {
+	if i >= len(data) {
+		return i
+	}
+	switch data[i] {
+	case '"':
+		return jsonStringEnd(data, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(data); j++ {
+			switch data[j] {
+			case '"':
+				j = jsonStringEnd(data, j) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(data)
+	default:
+		j := i
+		for j < len(data) && !strings.ContainsRune(",}] \t\r\n", rune(data[j])) {
+			j++
+		}
+		return j
+	}
+}
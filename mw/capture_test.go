@@ -0,0 +1,116 @@
+package mw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+)
+
+type memCaptureStore struct {
+	snapshots map[string]*Snapshot
+	ttl       time.Duration
+}
+
+func (s *memCaptureStore) SaveSnapshot(_ context.Context, snap *Snapshot, ttl time.Duration) error {
+	s.snapshots[snap.OperationID] = snap
+	s.ttl = ttl
+	return nil
+}
+
+type loginReq struct {
+	UserID   string `json:"userID"`
+	Password string `json:"password"`
+}
+
+func captureRouter(store CaptureStore, opts ...CaptureOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.CustomRecovery(func(c *gin.Context, err any) { c.AbortWithStatus(http.StatusInternalServerError) }))
+	r.Use(GinCaptureFailures(append([]CaptureOption{WithCaptureStore(store, time.Hour)}, opts...)...))
+	fail := func(err error) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			var req loginReq
+			if e := c.ShouldBindJSON(&req); e != nil {
+				apiresp.GinError(c, errs.ErrArgs.WrapMsg(e.Error()))
+				return
+			}
+			if req.UserID == "" {
+				apiresp.GinError(c, errs.ErrArgs.WrapMsg("body not replayed"))
+				return
+			}
+			apiresp.GinError(c, err)
+		}
+	}
+	r.POST("/internal", fail(errs.ErrInternalServer.WrapMsg("db down")))
+	r.POST("/args", fail(errs.ErrArgs.WrapMsg("bad userID")))
+	r.POST("/secret", fail(errs.ErrInternalServer.WrapMsg("db down")))
+	r.POST("/panic", func(c *gin.Context) { panic("boom") })
+	return r
+}
+
+func postCapture(r *gin.Engine, path, operationID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(constant.OperationID, operationID)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCaptureFailures(t *testing.T) {
+	store := &memCaptureStore{snapshots: make(map[string]*Snapshot)}
+	r := captureRouter(store, WithCaptureSkipRoutes("/secret"))
+	body := `{"userID":"u1","password":"hunter2","profile":{"token":{"v":"abc"},"nick":"n"}}`
+
+	postCapture(r, "/internal", "op-internal", body)
+	postCapture(r, "/args", "op-args", body)
+	postCapture(r, "/secret", "op-secret", body)
+	if w := postCapture(r, "/panic", "op-panic", body); w.Code != http.StatusInternalServerError {
+		t.Fatalf("panic status = %d, recovery did not see the panic", w.Code)
+	}
+
+	if len(store.snapshots) != 2 || store.ttl != time.Hour {
+		t.Fatalf("snapshots = %v, ttl %s; want op-internal and op-panic", store.snapshots, store.ttl)
+	}
+	s := store.snapshots["op-internal"]
+	if s == nil || s.ErrCode != errs.ServerInternalError || s.Route != "/internal" || s.Method != http.MethodPost {
+		t.Fatalf("snapshot = %+v", s)
+	}
+	want := `{"userID":"u1","password":"xxxxx","profile":{"token":"xxxxx","nick":"n"}}`
+	if s.Request != want {
+		t.Errorf("request = %s, want %s", s.Request, want)
+	}
+	if !strings.Contains(s.Response, `"errCode":500`) || s.RequestTruncated || s.ResponseTruncated {
+		t.Errorf("response = %s (truncated %v/%v)", s.Response, s.RequestTruncated, s.ResponseTruncated)
+	}
+	if p := store.snapshots["op-panic"]; p == nil || !strings.Contains(p.Error, "boom") || p.Request != want {
+		t.Errorf("panic snapshot = %+v", p)
+	}
+}
+
+func TestCaptureCap(t *testing.T) {
+	store := &memCaptureStore{snapshots: make(map[string]*Snapshot)}
+	r := captureRouter(store, WithCaptureMaxBody(32))
+	body := `{"userID":"u1","nick":"` + strings.Repeat("n", 100) + `","password":"hunter2"}`
+	postCapture(r, "/internal", "op", body)
+	s := store.snapshots["op"]
+	if s == nil {
+		t.Fatal("no snapshot: the handler did not get the full body back")
+	}
+	if !s.RequestTruncated || s.Request != body[:32] || !s.ResponseTruncated || len(s.Response) != 32 {
+		t.Fatalf("snapshot = %+v", s)
+	}
+
+	// Keys are masked even when the value is cut off.
+	if got := string(redactJSON([]byte(`{"nick":"a","password":"hun`), denySet(DefaultCaptureDenylist))); got != `{"nick":"a","password":"xxxxx"` {
+		t.Errorf("redact truncated = %s", got)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/mcontext"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultCoalesceMaxSize is the largest response shared between coalesced
+// requests unless WithCoalesceMaxSize says otherwise.
+const DefaultCoalesceMaxSize = 1 << 20
+
+type coalesceConfig struct {
+	maxSize int
+}
+
+type CoalesceOption func(*coalesceConfig)
+
+// WithCoalesceMaxSize sets the largest response, in bytes, shared between
+// coalesced requests. Larger responses are only returned to the request that
+// produced them, the others run the handler themselves.
+func WithCoalesceMaxSize(n int) CoalesceOption {
+	return func(c *coalesceConfig) {
+		c.maxSize = n
+	}
+}
+
+func newCoalesceConfig(opts []CoalesceOption) coalesceConfig {
+	cfg := coalesceConfig{maxSize: DefaultCoalesceMaxSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// coalescedResponse is a response produced once and replayed to every
+// request waiting on it. It is never modified after it is shared.
+type coalescedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// RequestHash hashes the method, query and body of the request, the default
+// request key of Coalesce. The body is read once and kept under
+// gin.BodyBytesKey so the handler can still bind it.
+func RequestHash(c *gin.Context) string {
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Request.URL.RawQuery))
+	h.Write([]byte{0})
+	h.Write(requestBody(c))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func requestBody(c *gin.Context) []byte {
+	if body, ok := c.Get(gin.BodyBytesKey); ok {
+		if b, ok := body.([]byte); ok {
+			return b
+		}
+	}
+	if c.Request.Body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Set(gin.BodyBytesKey, body)
+	return body
+}
+
+// Coalesce lets concurrent identical requests of the same user share one
+// execution of the handlers after it, for expensive idempotent reads such as
+// a full conversation sync that clients retry while it is still running.
+// Requests are identical when they have the same operating user, route and
+// keyFunc result, and ask for the same int64 encoding, see
+// apiresp.Int64AsString; keyFunc defaults to RequestHash and an empty result
+// opts a request out. Requests without an operating user are never
+// coalesced, so install Coalesce after token parsing.
+//
+// The first request runs the handlers; the others wait for it and receive a
+// copy of its status, headers and body. Responses other than 200 OK, apiresp
+// errors and responses larger than the WithCoalesceMaxSize limit are not
+// shared: the waiting requests then run the handlers themselves. A waiting
+// request whose context ends stops waiting and fails with ErrCallerCancelled
+// or ErrTimeout.
+func Coalesce(keyFunc func(c *gin.Context) string, opts ...CoalesceOption) gin.HandlerFunc {
+	cfg := newCoalesceConfig(opts)
+	if keyFunc == nil {
+		keyFunc = RequestHash
+	}
+	var group flightGroup
+	return func(c *gin.Context) {
+		userID := mcontext.GetOpUserID(c)
+		if userID == "" {
+			c.Next()
+			return
+		}
+		reqKey := keyFunc(c)
+		if reqKey == "" {
+			c.Next()
+			return
+		}
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		encoding := "int64"
+		if apiresp.Int64AsString(c) {
+			encoding = "string"
+		}
+		key := userID + "\x00" + path + "\x00" + encoding + "\x00" + reqKey
+		call, leader := group.join(key)
+		if leader {
+			var resp *coalescedResponse
+			defer func() { group.finish(key, call, resp) }()
+			w := &coalesceWriter{ResponseWriter: c.Writer, maxSize: cfg.maxSize}
+			c.Writer = w
+			c.Next()
+			c.Writer = w.ResponseWriter
+			if w.Status() == http.StatusOK && !w.overflow && !apiFailed(c) {
+				resp = &coalescedResponse{status: w.Status(), header: w.Header().Clone(), body: w.body.Bytes()}
+			}
+			return
+		}
+		select {
+		case <-call.done:
+		case <-c.Request.Context().Done():
+			ctx := c.Request.Context()
+			apiresp.GinError(c, contextError(ctx, ctx.Err()))
+			c.Abort()
+			return
+		}
+		resp, _ := call.val.(*coalescedResponse)
+		if resp == nil {
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		for k, v := range resp.header {
+			header[k] = append([]string(nil), v...)
+		}
+		c.Writer.WriteHeader(resp.status)
+		_, _ = c.Writer.Write(resp.body)
+		c.Abort()
+	}
+}
+
+// apiFailed reports whether the handlers answered with an apiresp error, which
+// is sent with status 200 as well.
+func apiFailed(c *gin.Context) bool {
+	resp := apiresp.GetGinApiResponse(c)
+	return resp != nil && resp.ErrCode != 0
+}
+
+// coalesceWriter keeps a copy of the response body until it exceeds maxSize.
+type coalesceWriter struct {
+	gin.ResponseWriter
+	maxSize  int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *coalesceWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *coalesceWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *coalesceWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(data) > w.maxSize {
+		w.overflow = true
+		w.body = bytes.Buffer{}
+		return
+	}
+	w.body.Write(data)
+}
+
+// RpcServerCoalesceInterceptor is the gRPC counterpart of Coalesce: concurrent
+// calls of the same method by the same operating user with the same keyFunc
+// result share one handler execution. keyFunc defaults to a hash of the
+// deterministic protobuf encoding of the request. Each caller receives its
+// own deep copy of the response, so later interceptors can modify it freely.
+// Errors and responses larger than the WithCoalesceMaxSize limit are not
+// shared, the waiting calls then run the handler themselves. Install it after
+// RpcServerInterceptor, which fills in the operating user.
+func RpcServerCoalesceInterceptor(keyFunc func(ctx context.Context, method string, req any) string, opts ...CoalesceOption) grpc.UnaryServerInterceptor {
+	cfg := newCoalesceConfig(opts)
+	if keyFunc == nil {
+		keyFunc = protoHash
+	}
+	var group flightGroup
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		userID := mcontext.GetOpUserID(ctx)
+		if userID == "" {
+			return handler(ctx, req)
+		}
+		reqKey := keyFunc(ctx, info.FullMethod, req)
+		if reqKey == "" {
+			return handler(ctx, req)
+		}
+		key := userID + "\x00" + info.FullMethod + "\x00" + reqKey
+		call, leader := group.join(key)
+		if leader {
+			var shared proto.Message
+			defer func() { group.finish(key, call, shared) }()
+			resp, err := handler(ctx, req)
+			if msg, ok := resp.(proto.Message); ok && err == nil && proto.Size(msg) <= cfg.maxSize {
+				shared = proto.Clone(msg)
+			}
+			return resp, err
+		}
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, contextError(ctx, ctx.Err())
+		}
+		shared, _ := call.val.(proto.Message)
+		if shared == nil {
+			return handler(ctx, req)
+		}
+		return proto.Clone(shared), nil
+	}
+}
+
+func protoHash(_ context.Context, _ string, req any) string {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return ""
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// flightGroup tracks the executions in flight per key. Unlike
+// x/sync/singleflight the leader runs in the goroutine of its request, so gin
+// handlers are never used from two goroutines and panics reach the usual
+// recovery middleware, and waiters can give up when their context ends.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	val  any
+}
+
+// join returns the call in flight for key, or starts one led by the caller.
+func (g *flightGroup) join(key string) (*flightCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if call, ok := g.calls[key]; ok {
+		return call, false
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	return call, true
+}
+
+// finish publishes the result of the call led by the caller, nil when it
+// must not be shared, and wakes its waiters.
+func (g *flightGroup) finish(key string, call *flightCall, val any) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	call.val = val
+	close(call.done)
+}
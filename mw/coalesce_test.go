@@ -0,0 +1,211 @@
+package mw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mcontext"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// coalesceRouter serves POST /sync with a slow handler behind Coalesce. The
+// operating user is taken from the X-User header.
+func coalesceRouter(handler gin.HandlerFunc, opts ...CoalesceOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set(constant.OpUserID, user)
+		}
+	}, Coalesce(nil, opts...))
+	r.POST("/sync", handler)
+	return r
+}
+
+// fire sends n concurrent requests and returns the response bodies.
+func fire(r *gin.Engine, n int, user func(i int) string, body string) []string {
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(body))
+			req.Header.Set("X-User", user(i))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			bodies[i] = rec.Body.String()
+		}(i)
+	}
+	wg.Wait()
+	return bodies
+}
+
+func sameUser(int) string { return "u1" }
+
+func TestCoalesce(t *testing.T) {
+	var runs atomic.Int32
+	r := coalesceRouter(func(c *gin.Context) {
+		n := runs.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		c.Header("X-Run", "1")
+		apiresp.GinSuccess(c, map[string]any{"run": n, "conversations": []string{"a", "b"}})
+	})
+
+	bodies := fire(r, 50, sameUser, `{"all":true}`)
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+	for i, body := range bodies {
+		if body != bodies[0] || !strings.Contains(body, `"run":1`) {
+			t.Fatalf("response %d = %s, want %s", i, body, bodies[0])
+		}
+	}
+
+	runs.Store(0)
+	fire(r, 10, func(i int) string { return []string{"u1", "u2"}[i%2] }, `{"all":true}`)
+	if n := runs.Load(); n != 2 {
+		t.Errorf("two users: handler ran %d times, want 2", n)
+	}
+
+	runs.Store(0)
+	fire(r, 10, func(int) string { return "" }, `{"all":true}`)
+	if n := runs.Load(); n != 10 {
+		t.Errorf("anonymous: handler ran %d times, want 10", n)
+	}
+}
+
+func TestCoalesceInt64Encoding(t *testing.T) {
+	var runs atomic.Int32
+	r := coalesceRouter(func(c *gin.Context) {
+		runs.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		apiresp.GinSuccess(c, map[string]int64{"seq": 1234567890123456789})
+	})
+	var wg sync.WaitGroup
+	bodies := make(map[string]string)
+	var mu sync.Mutex
+	for _, asString := range []string{"true", "false"} {
+		wg.Add(1)
+		go func(asString string) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(`{}`))
+			req.Header.Set("X-User", "u1")
+			req.Header.Set(apiresp.Int64AsStringHeader, asString)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			mu.Lock()
+			bodies[asString] = rec.Body.String()
+			mu.Unlock()
+		}(asString)
+	}
+	wg.Wait()
+	if n := runs.Load(); n != 2 {
+		t.Errorf("handler ran %d times, want once per encoding", n)
+	}
+	if body := bodies["true"]; !strings.Contains(body, `"seq":"1234567890123456789"`) {
+		t.Errorf("string encoding: %s", body)
+	}
+	if body := bodies["false"]; !strings.Contains(body, `"seq":1234567890123456789`) {
+		t.Errorf("number encoding: %s", body)
+	}
+}
+
+func TestCoalesceNotShared(t *testing.T) {
+	tests := map[string]gin.HandlerFunc{
+		"status": func(c *gin.Context) { c.String(http.StatusInternalServerError, "boom") },
+		"error":  func(c *gin.Context) { apiresp.GinError(c, errs.ErrInternalServer.WrapMsg("boom")) },
+		"large":  func(c *gin.Context) { c.String(http.StatusOK, strings.Repeat("x", 100)) },
+	}
+	for name, respond := range tests {
+		t.Run(name, func(t *testing.T) {
+			var runs atomic.Int32
+			r := coalesceRouter(func(c *gin.Context) {
+				runs.Add(1)
+				time.Sleep(50 * time.Millisecond)
+				respond(c)
+			}, WithCoalesceMaxSize(64))
+			fire(r, 20, sameUser, `{}`)
+			if n := runs.Load(); n != 20 {
+				t.Errorf("handler ran %d times, want 20", n)
+			}
+		})
+	}
+}
+
+func TestCoalesceWaiterCancelled(t *testing.T) {
+	release := make(chan struct{})
+	r := coalesceRouter(func(c *gin.Context) {
+		<-release
+		apiresp.GinSuccess(c, "done")
+	})
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		req := httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader("{}"))
+		req.Header.Set("X-User", "u1")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader("{}")).WithContext(ctx)
+	req.Header.Set("X-User", "u1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `"errCode":1006`) {
+		t.Errorf("waiter response = %s", rec.Body.String())
+	}
+	close(release)
+	<-leaderDone
+}
+
+func TestRpcServerCoalesceInterceptor(t *testing.T) {
+	var runs atomic.Int32
+	interceptor := RpcServerCoalesceInterceptor(nil)
+	info := &grpc.UnaryServerInfo{FullMethod: "/conversation/GetAllConversations"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		runs.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		return wrapperspb.String("conversations"), nil
+	}
+	ctx := mcontext.SetOpUserID(context.Background(), "u1")
+
+	var wg sync.WaitGroup
+	resps := make([]*wrapperspb.StringValue, 50)
+	for i := range resps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := interceptor(ctx, wrapperspb.String("all"), info, handler)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resps[i] = resp.(*wrapperspb.StringValue)
+			// Post-processing one response must not affect the others.
+			resps[i].Value += "!"
+		}(i)
+	}
+	wg.Wait()
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+	for i, resp := range resps {
+		if resp.GetValue() != "conversations!" {
+			t.Errorf("response %d = %q", i, resp.GetValue())
+		}
+	}
+}
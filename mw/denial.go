@@ -0,0 +1,119 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/statreg"
+)
+
+// Rules of the denials of the middlewares of this package.
+const (
+	DenialRuleToken = "token" // GinParseToken
+	DenialRuleAdmin = "admin" // RequireAdmin
+)
+
+// denialReportedKey marks a request whose denial was reported, see
+// WithDenialScope. It is a string so that gin.Context.Set works for it.
+const denialReportedKey = "mwDenialReported"
+
+// Denial describes a request rejected by an access control middleware.
+type Denial struct {
+	// Rule names the middleware or rule that rejected the request, such as
+	// DenialRuleToken. It names the counter mw_denials_<rule>_total, and so
+	// must be made of lowercase letters, digits and underscores.
+	Rule string
+	// Reason says why, without secrets such as the token.
+	Reason string
+	// Identity is the caller: the user ID when known, the client address
+	// otherwise.
+	Identity string
+	// Resource is what was denied, such as the request path.
+	Resource string
+}
+
+var (
+	denialLock     sync.Mutex
+	denialCounters = make(map[string]*statreg.Counter)
+	denialHook     atomic.Pointer[func(Denial)]
+)
+
+// SetDenialHook makes ReportDenial call hook with every denial it reports,
+// typically for a test to assert on them, and returns a function restoring
+// the previous hook.
+func SetDenialHook(hook func(Denial)) (restore func()) {
+	old := denialHook.Swap(&hook)
+	return func() { denialHook.Store(old) }
+}
+
+// WithDenialScope returns a context in which ReportDenial reports only the
+// first denial, for requests that several middlewares may reject. Gin
+// requests need no scope: the flag is kept in the gin.Context.
+func WithDenialScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, denialReportedKey, new(atomic.Bool))
+}
+
+// ReportDenial writes d to the audit log and counts it by rule in
+// mw_denials_<rule>_total, see statreg.Snapshot. Only the first denial of a
+// request is reported, the others are dropped: the middleware that rejected
+// the request first is the one attributed.
+func ReportDenial(ctx context.Context, d Denial) {
+	if !claimDenial(ctx) {
+		return
+	}
+	log.ZWarn(ctx, "access denied", nil, "audit", true, "rule", d.Rule, "reason", d.Reason, "identity", d.Identity, "resource", d.Resource)
+	if c := denialCounter(d.Rule); c != nil {
+		c.Inc()
+	}
+	if hook := denialHook.Load(); hook != nil && *hook != nil {
+		(*hook)(d)
+	}
+}
+
+// claimDenial reports whether the denial of the request of ctx is still to be
+// reported, and marks it reported.
+func claimDenial(ctx context.Context) bool {
+	if c, ok := ctx.(*gin.Context); ok {
+		if c.GetBool(denialReportedKey) {
+			return false
+		}
+		c.Set(denialReportedKey, true)
+		return true
+	}
+	if flag, ok := ctx.Value(denialReportedKey).(*atomic.Bool); ok {
+		return flag.CompareAndSwap(false, true)
+	}
+	return true
+}
+
+func denialCounter(rule string) *statreg.Counter {
+	denialLock.Lock()
+	defer denialLock.Unlock()
+	if c, ok := denialCounters[rule]; ok {
+		return c
+	}
+	c, err := statreg.NewCounter("mw_denials_" + rule + "_total")
+	if err != nil {
+		log.ZWarn(context.Background(), "denial counter not registered", err, "rule", rule)
+	}
+	// A nil counter is kept too, so that an invalid rule is logged once.
+	denialCounters[rule] = c
+	return c
+}
@@ -0,0 +1,102 @@
+package mw
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/utils/statreg"
+)
+
+func captureDenials(t *testing.T) func() []Denial {
+	var (
+		mu      sync.Mutex
+		denials []Denial
+	)
+	t.Cleanup(SetDenialHook(func(d Denial) {
+		mu.Lock()
+		defer mu.Unlock()
+		denials = append(denials, d)
+	}))
+	return func() []Denial {
+		mu.Lock()
+		defer mu.Unlock()
+		got := denials
+		denials = nil
+		return got
+	}
+}
+
+func denialCount(rule string) float64 {
+	for _, s := range statreg.Snapshot() {
+		if s.Name == "mw_denials_"+rule+"_total" {
+			return s.Value
+		}
+	}
+	return 0
+}
+
+func TestGinParseTokenDenials(t *testing.T) {
+	denials := captureDenials(t)
+	revoker := &fakeRevoker{}
+	r := authRouter(func(*jwt.Token) (any, error) { return authSecret, nil }, WithRevoker(revoker))
+	token := signToken(t, "u1", constant.IOSPlatformID, 1)
+	revoked := signToken(t, "u2", constant.IOSPlatformID, 1)
+	revoker.revoke("u2")
+	before := denialCount(DenialRuleToken)
+
+	if body := callAuth(r, token); body != "u1/IOS" {
+		t.Fatalf("valid token: %s", body)
+	}
+	if got := denials(); len(got) != 0 {
+		t.Fatalf("valid token reported %+v", got)
+	}
+	for _, tc := range []struct {
+		token    string
+		reason   string
+		identity string
+	}{
+		{"", "missing token", "192.0.2.1"},
+		{token + "x", "invalid token", "192.0.2.1"},
+		{revoked, "token revoked", "u2"},
+	} {
+		callAuth(r, tc.token)
+		got := denials()
+		want := Denial{Rule: DenialRuleToken, Reason: tc.reason, Identity: tc.identity, Resource: "/me"}
+		if len(got) != 1 || got[0] != want {
+			t.Errorf("denials = %+v, want exactly %+v", got, want)
+		}
+	}
+	if n := denialCount(DenialRuleToken) - before; n != 3 {
+		t.Errorf("token denials counted %v times, want 3", n)
+	}
+}
+
+func TestReportDenialOncePerRequest(t *testing.T) {
+	denials := captureDenials(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// A rule in report only mode rejects first, then the token check.
+	r.Use(func(c *gin.Context) {
+		ReportDenial(c, Denial{Rule: "ip_filter", Reason: "address not allowed", Identity: c.ClientIP(), Resource: c.Request.URL.Path})
+		c.Next()
+	}, GinParseToken(func(*jwt.Token) (any, error) { return authSecret, nil }, nil))
+	r.POST("/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+	callAuth(r, "")
+	if got := denials(); len(got) != 1 || got[0].Rule != "ip_filter" {
+		t.Fatalf("denials = %+v, want the ip_filter one only", got)
+	}
+
+	ctx := WithDenialScope(context.Background())
+	ReportDenial(ctx, Denial{Rule: "signature", Reason: "bad signature"})
+	ReportDenial(ctx, Denial{Rule: "admin", Reason: "not an admin"})
+	ReportDenial(context.Background(), Denial{Rule: "admin", Reason: "unscoped"})
+	got := denials()
+	if len(got) != 2 || got[0].Rule != "signature" || got[1].Reason != "unscoped" {
+		t.Fatalf("denials = %+v, want the first scoped one and the unscoped one", got)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"context"
+
+	"github.com/openimsdk/tools/apiresp"
+	"google.golang.org/grpc"
+)
+
+// RpcServerFieldFilterInterceptor removes the response fields that the roles
+// of the operating user do not grant, see apiresp.FilterFields. Only install
+// it on services called on behalf of end users: calls that carry no roles see
+// none of the permission tagged fields.
+func RpcServerFieldFilterInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return apiresp.FilterFieldsContext(ctx, resp), nil
+}
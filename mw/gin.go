@@ -15,11 +15,14 @@
 package mw
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
 	"github.com/openimsdk/tools/tokenverify"
 
 	"github.com/gin-gonic/gin"
@@ -31,25 +34,7 @@ import (
 // CorsHandler gin cross-domain configuration.
 func CorsHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "*")
-		c.Header("Access-Control-Allow-Headers", "*")
-		c.Header(
-			"Access-Control-Expose-Headers",
-			"Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers,Cache-Control,Content-Language,Content-Type,Expires,Last-Modified,Pragma,FooBar",
-		) // Cross-domain key settings allow browsers to resolve.
-		c.Header(
-			"Access-Control-Max-Age",
-			"172800",
-		) // Cache request information in seconds.
-		c.Header(
-			"Access-Control-Allow-Credentials",
-			"false",
-		) //  Whether cross-domain requests need to carry cookie information, the default setting is true.
-		c.Header(
-			"content-type",
-			"application/json",
-		) // Set the return format to json.
+		setCorsHeaders(c)
 		// Release all option pre-requests
 		if c.Request.Method == http.MethodOptions {
 			c.JSON(http.StatusOK, "Options Request!")
@@ -60,6 +45,30 @@ func CorsHandler() gin.HandlerFunc {
 	}
 }
 
+// setCorsHeaders sets the cross-domain headers of CorsHandler, see also
+// MethodRouting which answers OPTIONS the same way.
+func setCorsHeaders(c *gin.Context) {
+	c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+	c.Header("Access-Control-Allow-Methods", "*")
+	c.Header("Access-Control-Allow-Headers", "*")
+	c.Header(
+		"Access-Control-Expose-Headers",
+		"Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers,Cache-Control,Content-Language,Content-Type,Expires,Last-Modified,Pragma,FooBar",
+	) // Cross-domain key settings allow browsers to resolve.
+	c.Header(
+		"Access-Control-Max-Age",
+		"172800",
+	) // Cache request information in seconds.
+	c.Header(
+		"Access-Control-Allow-Credentials",
+		"false",
+	) //  Whether cross-domain requests need to carry cookie information, the default setting is true.
+	c.Header(
+		"content-type",
+		"application/json",
+	) // Set the return format to json.
+}
+
 func GinParseOperationID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.Method == http.MethodPost {
@@ -76,7 +85,40 @@ func GinParseOperationID() gin.HandlerFunc {
 	}
 }
 
-func GinParseToken(secretKey jwt.Keyfunc, whitelist []string) gin.HandlerFunc {
+// AuthOption configures GinParseToken.
+type AuthOption func(*authConfig)
+
+type authConfig struct {
+	revoker Revoker
+	cache   *AuthCache
+}
+
+// WithRevoker rejects tokens that revoker reports revoked with
+// errs.ErrTokenKicked.
+func WithRevoker(revoker Revoker) AuthOption {
+	return func(c *authConfig) {
+		c.revoker = revoker
+	}
+}
+
+// WithAuthCache reuses successful verifications remembered by cache instead
+// of verifying the same token on every request. Unless the cache is attached
+// to the revoker of WithRevoker with AuthCache.Attach, a revoked token is
+// honoured until its entry expires.
+func WithAuthCache(cache *AuthCache) AuthOption {
+	return func(c *authConfig) {
+		c.cache = cache
+	}
+}
+
+// GinParseToken verifies the token of POST requests, except on the whitelist
+// path prefixes, and sets the identity it carries on the context. Rejected
+// requests are reported with ReportDenial.
+func GinParseToken(secretKey jwt.Keyfunc, whitelist []string, opts ...AuthOption) gin.HandlerFunc {
+	var conf authConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
 	return func(c *gin.Context) {
 		switch c.Request.Method {
 		case http.MethodPost:
@@ -89,26 +131,85 @@ func GinParseToken(secretKey jwt.Keyfunc, whitelist []string) gin.HandlerFunc {
 
 			token := c.Request.Header.Get(constant.Token)
 			if token == "" {
+				ReportDenial(c, Denial{Rule: DenialRuleToken, Reason: "missing token", Identity: c.ClientIP(), Resource: c.Request.URL.Path})
 				apiresp.GinError(c, errs.ErrArgs.WrapMsg("header must have token"))
 				c.Abort()
 				return
 			}
 
-			claims, err := tokenverify.GetClaimFromToken(token, secretKey)
+			identity, err := conf.verify(c, token, secretKey)
 			if err != nil {
-				log.ZWarn(c, "header get token error", errs.ErrArgs.WrapMsg("header must have token"))
-				apiresp.GinError(c, errs.ErrArgs.WrapMsg("header must have token"))
+				if reason := tokenDenialReason(err); reason != "" {
+					who := identity.UserID
+					if who == "" {
+						who = c.ClientIP()
+					}
+					ReportDenial(c, Denial{Rule: DenialRuleToken, Reason: reason, Identity: who, Resource: c.Request.URL.Path})
+				}
+				apiresp.GinError(c, err)
 				c.Abort()
 				return
 			}
 
-			c.Set(constant.OpUserPlatform, constant.PlatformIDToName(claims.PlatformID))
-			c.Set(constant.OpUserID, claims.UserID)
+			c.Set(constant.OpUserPlatform, constant.PlatformIDToName(identity.PlatformID))
+			c.Set(constant.OpUserID, identity.UserID)
+			if len(identity.Roles) > 0 {
+				c.Set(mcontext.OpUserRoles, identity.Roles)
+			}
 			c.Next()
 		}
 	}
 }
 
+func (conf *authConfig) verify(ctx context.Context, token string, secretKey jwt.Keyfunc) (authIdentity, error) {
+	var (
+		hash  string
+		epoch uint64
+	)
+	if conf.cache != nil {
+		hash = TokenHash(token)
+		identity, e, ok := conf.cache.get(hash)
+		if ok {
+			return identity, nil
+		}
+		epoch = e
+	}
+	claims, err := tokenverify.GetClaimFromToken(token, secretKey)
+	if err != nil {
+		log.ZWarn(ctx, "header get token error", errs.ErrArgs.WrapMsg("header must have token"))
+		return authIdentity{}, errs.ErrArgs.WrapMsg("header must have token")
+	}
+	if conf.revoker != nil {
+		revoked, err := conf.revoker.IsRevoked(ctx, token, claims)
+		if err != nil {
+			return authIdentity{}, errs.WrapMsg(err, "token revocation check failed", "userID", claims.UserID)
+		}
+		if revoked {
+			return authIdentity{UserID: claims.UserID}, errs.ErrTokenKicked.WrapMsg("token was revoked", "userID", claims.UserID)
+		}
+	}
+	identity := authIdentity{UserID: claims.UserID, PlatformID: claims.PlatformID, Roles: claims.Roles}
+	if claims.ExpiresAt != nil {
+		identity.ExpiresAt = claims.ExpiresAt.Time
+	}
+	if conf.cache != nil {
+		conf.cache.add(hash, identity, epoch)
+	}
+	return identity, nil
+}
+
+// tokenDenialReason returns the reason of the denial err of verify, or "" when
+// err is not a denial, such as a failure of the revocation backend.
+func tokenDenialReason(err error) string {
+	switch {
+	case errors.Is(err, errs.ErrTokenKicked):
+		return "token revoked"
+	case errors.Is(err, errs.ErrArgs):
+		return "invalid token"
+	}
+	return ""
+}
+
 func CreateToken(userID string, accessSecret string, accessExpire int64, platformID int) (string, error) {
 	claims := tokenverify.BuildClaims(userID, platformID, accessExpire)
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -0,0 +1,205 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/statreg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// MirrorHeader is the metadata key marking mirrored calls, see
+// GrpcMirrorInterceptor and IsMirrorCall.
+const MirrorHeader = "openim-mirror"
+
+const (
+	// DefaultMirrorTimeout bounds a mirrored call unless WithMirrorTimeout says
+	// otherwise.
+	DefaultMirrorTimeout = 2 * time.Second
+	// DefaultMirrorMaxInFlight is the number of mirrored calls running at once
+	// unless WithMirrorMaxInFlight says otherwise.
+	DefaultMirrorMaxInFlight = 64
+)
+
+// The mirror counters are reported by statreg.Snapshot.
+var (
+	mirrorCalls       = statreg.MustNewCounter("mw_mirror_calls_total")
+	mirrorFailures    = statreg.MustNewCounter("mw_mirror_failures_total")
+	mirrorDivergences = statreg.MustNewCounter("mw_mirror_divergences_total")
+	mirrorDropped     = statreg.MustNewCounter("mw_mirror_dropped_total")
+)
+
+// mirrorSample returns a number in [0, 1) deciding whether a call is
+// mirrored; replaced in tests.
+var mirrorSample = rand.Float64
+
+// MirrorResolver returns the connection of the shadow service mirrored calls
+// are sent to, such as discovery.Conn.GetConn of its service name.
+type MirrorResolver func(ctx context.Context) (grpc.ClientConnInterface, error)
+
+// MirrorCompareFunc reports whether the shadow service answered a mirrored
+// call like the primary one. The responses must not be modified.
+type MirrorCompareFunc func(method string, primary, shadow any, primaryErr, shadowErr error) bool
+
+type mirrorConfig struct {
+	methods     map[string]struct{}
+	timeout     time.Duration
+	maxInFlight int
+}
+
+type MirrorOption func(*mirrorConfig)
+
+// WithMirrorMethods adds full method names, such as
+// "/openim.user.user/GetDesignateUsers", to the calls that may be mirrored.
+func WithMirrorMethods(methods ...string) MirrorOption {
+	return func(c *mirrorConfig) {
+		for _, method := range methods {
+			c.methods[method] = struct{}{}
+		}
+	}
+}
+
+// WithMirrorTimeout sets the deadline of mirrored calls, which is independent
+// of the deadline of the primary call.
+func WithMirrorTimeout(d time.Duration) MirrorOption {
+	return func(c *mirrorConfig) {
+		c.timeout = d
+	}
+}
+
+// WithMirrorMaxInFlight sets the number of mirrored calls running at once.
+// Sampled calls beyond it are not mirrored.
+func WithMirrorMaxInFlight(n int) MirrorOption {
+	return func(c *mirrorConfig) {
+		c.maxInFlight = n
+	}
+}
+
+// GrpcMirrorInterceptor sends a copy of percent percent of the calls of the
+// methods given by WithMirrorMethods to the shadow service returned by
+// resolver, to compare a new version of a service against production traffic
+// before switching to it. Calls of other methods are never mirrored.
+//
+// The copy is sent in the background once the primary call returned, with
+// the values of its context and a deadline of its own, so neither the latency
+// nor the outcome of the primary call depend on the shadow service. Its
+// response is discarded: shadow errors are logged and, when compareFn is not
+// nil, both outcomes are compared and divergences are logged and counted.
+// Mirrored calls carry MirrorHeader so that the shadow service can skip side
+// effects, see IsMirrorCall. Requests and responses must be proto messages.
+func GrpcMirrorInterceptor(resolver MirrorResolver, percent float64, compareFn MirrorCompareFunc, opts ...MirrorOption) grpc.UnaryClientInterceptor {
+	cfg := mirrorConfig{methods: make(map[string]struct{}), timeout: DefaultMirrorTimeout, maxInFlight: DefaultMirrorMaxInFlight}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	inFlight := make(chan struct{}, cfg.maxInFlight)
+	return func(ctx context.Context, method string, req, resp any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, resp, cc, opts...)
+		if _, ok := cfg.methods[method]; !ok || mirrorSample()*100 >= percent || IsMirrorCall(ctx) {
+			return err
+		}
+		reqMsg, ok := req.(proto.Message)
+		if !ok {
+			return err
+		}
+		respMsg, ok := resp.(proto.Message)
+		if !ok {
+			return err
+		}
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			mirrorDropped.Inc()
+			return err
+		}
+		m := &mirrorCall{
+			method:     method,
+			req:        proto.Clone(reqMsg),
+			primary:    proto.Clone(respMsg),
+			primaryErr: err,
+		}
+		mirrorCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.timeout)
+		go func() {
+			defer func() { <-inFlight }()
+			defer cancel()
+			m.run(mirrorCtx, resolver, compareFn)
+		}()
+		return err
+	}
+}
+
+type mirrorCall struct {
+	method     string
+	req        proto.Message
+	primary    proto.Message
+	primaryErr error
+}
+
+func (m *mirrorCall) run(ctx context.Context, resolver MirrorResolver, compareFn MirrorCompareFunc) {
+	mirrorCalls.Inc()
+	ctx = withMirrorCall(ctx)
+	shadow := m.primary.ProtoReflect().New().Interface()
+	conn, err := resolver(ctx)
+	if err == nil {
+		err = conn.Invoke(ctx, m.method, m.req, shadow)
+	}
+	if err != nil {
+		mirrorFailures.Inc()
+		log.ZWarn(ctx, "mirrored call failed", err, "method", m.method)
+	}
+	if compareFn == nil {
+		return
+	}
+	var primary any = m.primary
+	if m.primaryErr != nil {
+		primary = nil
+	}
+	var shadowResp any = shadow
+	if err != nil {
+		shadowResp = nil
+	}
+	if !compareFn(m.method, primary, shadowResp, m.primaryErr, err) {
+		mirrorDivergences.Inc()
+		log.ZWarn(ctx, "mirrored call diverged", err, "method", m.method, "req", m.req,
+			"primary", primary, "primaryErr", m.primaryErr, "shadow", shadowResp)
+	}
+}
+
+type mirrorCallKey struct{}
+
+// withMirrorCall marks an outgoing call as mirrored. The context value keeps
+// the mark when RpcClientInterceptor replaces the outgoing metadata.
+func withMirrorCall(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, mirrorCallKey{}, true)
+	return metadata.AppendToOutgoingContext(ctx, MirrorHeader, "1")
+}
+
+// IsMirrorCall reports whether ctx belongs to a call mirrored by
+// GrpcMirrorInterceptor, on the client side or, through MirrorHeader, on the
+// server side. Services should skip side effects such as writes and pushes
+// for such calls.
+func IsMirrorCall(ctx context.Context) bool {
+	if mirrored, _ := ctx.Value(mirrorCallKey{}).(bool); mirrored {
+		return true
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return len(md.Get(MirrorHeader)) > 0
+}
@@ -0,0 +1,204 @@
+package mw
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+const healthCheck = "/grpc.health.v1.Health/Check"
+
+// shadowHealth answers Check with status after delay and counts the calls
+// carrying MirrorHeader.
+type shadowHealth struct {
+	healthpb.UnimplementedHealthServer
+	status   healthpb.HealthCheckResponse_ServingStatus
+	delay    time.Duration
+	calls    atomic.Int64
+	mirrored atomic.Int64
+}
+
+func (s *shadowHealth) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.calls.Add(1)
+	if IsMirrorCall(ctx) {
+		s.mirrored.Add(1)
+	}
+	time.Sleep(s.delay)
+	return &healthpb.HealthCheckResponse{Status: s.status}, nil
+}
+
+func dialHealth(t *testing.T, srv healthpb.HealthServer, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, srv)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	opts = append(opts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// setMirrorSample makes successive calls sample 0.00, 0.01, ... 0.99, 0.00.
+func setMirrorSample(t *testing.T) {
+	var mu sync.Mutex
+	var n int
+	mirrorSample = func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		v := float64(n%100) / 100
+		n++
+		return v
+	}
+	t.Cleanup(func() { mirrorSample = defaultMirrorSample })
+}
+
+var defaultMirrorSample = mirrorSample
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGrpcMirrorInterceptor(t *testing.T) {
+	setMirrorSample(t)
+	shadow := &shadowHealth{status: healthpb.HealthCheckResponse_NOT_SERVING, delay: time.Second}
+	shadowConn := dialHealth(t, shadow)
+	resolver := func(context.Context) (grpc.ClientConnInterface, error) { return shadowConn, nil }
+	var compared atomic.Int64
+	compare := func(method string, primary, shadow any, primaryErr, shadowErr error) bool {
+		compared.Add(1)
+		if primaryErr != nil || shadowErr != nil {
+			return primaryErr != nil && shadowErr != nil
+		}
+		return proto.Equal(primary.(proto.Message), shadow.(proto.Message))
+	}
+	primary := &shadowHealth{status: healthpb.HealthCheckResponse_SERVING}
+	conn := dialHealth(t, primary, grpc.WithChainUnaryInterceptor(
+		GrpcMirrorInterceptor(resolver, 30, compare, WithMirrorMethods(healthCheck), WithMirrorMaxInFlight(100))))
+	client := healthpb.NewHealthClient(conn)
+
+	divergences := mirrorDivergences.Load()
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "user"})
+		if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("primary call %d: %v %v", i, resp, err)
+		}
+	}
+	// One slow shadow call alone would exceed this if mirroring blocked.
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("100 primary calls took %s, shadow latency leaked", elapsed)
+	}
+	waitFor(t, "mirrored calls", func() bool { return compared.Load() == 30 })
+	if n := shadow.calls.Load(); n != 30 {
+		t.Errorf("shadow received %d calls, want 30", n)
+	}
+	if n := shadow.mirrored.Load(); n != 30 {
+		t.Errorf("%d shadow calls carried %s, want 30", n, MirrorHeader)
+	}
+	if n := mirrorDivergences.Load() - divergences; n != 30 {
+		t.Errorf("divergences = %d, want 30", n)
+	}
+	if n := primary.mirrored.Load(); n != 0 {
+		t.Errorf("%d primary calls marked as mirrored", n)
+	}
+
+	// Other methods are never mirrored.
+	calls := shadow.calls.Load()
+	noMethods := dialHealth(t, primary, grpc.WithChainUnaryInterceptor(GrpcMirrorInterceptor(resolver, 100, compare)))
+	for i := 0; i < 10; i++ {
+		if _, err := healthpb.NewHealthClient(noMethods).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := shadow.calls.Load() - calls; n != 0 {
+		t.Errorf("%d calls of a method not allowed mirrored", n)
+	}
+}
+
+func TestGrpcMirrorInterceptorShadowTimeout(t *testing.T) {
+	setMirrorSample(t)
+	shadow := &shadowHealth{status: healthpb.HealthCheckResponse_SERVING, delay: time.Second}
+	shadowConn := dialHealth(t, shadow)
+	resolver := func(context.Context) (grpc.ClientConnInterface, error) { return shadowConn, nil }
+	shadowErrs := make(chan error, 1)
+	compare := func(method string, primary, shadow any, primaryErr, shadowErr error) bool {
+		shadowErrs <- shadowErr
+		return primaryErr == nil && shadowErr == nil
+	}
+	conn := dialHealth(t, &shadowHealth{status: healthpb.HealthCheckResponse_SERVING}, grpc.WithChainUnaryInterceptor(
+		GrpcMirrorInterceptor(resolver, 100, compare, WithMirrorMethods(healthCheck), WithMirrorTimeout(50*time.Millisecond))))
+
+	failures, divergences := mirrorFailures.Load(), mirrorDivergences.Load()
+	// Cancelling the primary context does not end the mirrored call, its own
+	// deadline does.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	cancel()
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("primary call: %v %v", resp, err)
+	}
+	select {
+	case err := <-shadowErrs:
+		if err == nil {
+			t.Fatal("shadow call outlived its deadline")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("mirrored call not compared")
+	}
+	waitFor(t, "divergence", func() bool { return mirrorDivergences.Load()-divergences == 1 })
+	if n := mirrorFailures.Load() - failures; n != 1 {
+		t.Errorf("failures = %d, want 1", n)
+	}
+}
+
+func TestMirrorHeaderPropagates(t *testing.T) {
+	var leafMirrored atomic.Bool
+	leaf, leafCodes := startUnaryServer(t, func(ctx context.Context) error {
+		leafMirrored.Store(IsMirrorCall(ctx))
+		return nil
+	})
+	middle, middleCodes := startUnaryServer(t, func(ctx context.Context) error {
+		err := call(ctx, leaf)
+		<-leafCodes
+		return err
+	})
+
+	for _, mirrored := range []bool{true, false} {
+		ctx := context.Background()
+		if mirrored {
+			ctx = withMirrorCall(ctx)
+		}
+		if err := call(ctx, middle); err != nil {
+			t.Fatal(err)
+		}
+		<-middleCodes
+		if got := leafMirrored.Load(); got != mirrored {
+			t.Errorf("mirrored call %v: second hop mirrored = %v", mirrored, got)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+)
+
+// MethodRouting makes engine tell a missing path from a wrong method: a
+// registered path requested with another method is answered 405 with an
+// Allow header listing its methods, instead of gin's default 404. OPTIONS
+// requests to a registered path are answered like CorsHandler does, and HEAD
+// requests to a GET route are served by that route without a body. Both the
+// 404 and the 405 answers carry the apiresp envelope.
+//
+// The route table is read once, so MethodRouting must be called after every
+// route is registered: later routes are answered 404.
+func MethodRouting(engine *gin.Engine) {
+	table := newRouteTable(engine.Routes())
+	handler := func(c *gin.Context) {
+		path := c.Request.URL.Path
+		methods := table.allowed(path)
+		if len(methods) == 0 {
+			apiresp.GinErrorStatus(c, http.StatusNotFound, errs.ErrRecordNotFound.WrapMsg("route not found", "path", path))
+			c.Abort()
+			return
+		}
+		allow := strings.Join(methods, ", ")
+		switch c.Request.Method {
+		case http.MethodOptions:
+			setCorsHeaders(c)
+			c.Header("Allow", allow)
+			c.JSON(http.StatusOK, "Options Request!")
+		case http.MethodHead:
+			if !table.has(http.MethodGet, path) {
+				break
+			}
+			req := c.Request.Clone(c.Request.Context())
+			req.Method = http.MethodGet
+			engine.ServeHTTP(headWriter{c.Writer}, req)
+		}
+		if !c.Writer.Written() {
+			c.Header("Allow", allow)
+			apiresp.GinErrorStatus(c, http.StatusMethodNotAllowed, errs.ErrArgs.WrapMsg("method not allowed", "method", c.Request.Method, "path", path, "allow", allow))
+		}
+		c.Abort()
+	}
+	// Without HandleMethodNotAllowed gin sends a wrong method to NoRoute, with
+	// it to NoMethod: both are handled so the setting does not matter.
+	engine.NoRoute(handler)
+	engine.NoMethod(handler)
+}
+
+// headWriter drops the body written by a GET route serving a HEAD request.
+type headWriter struct {
+	gin.ResponseWriter
+}
+
+func (w headWriter) WriteHeader(code int) {
+	w.ResponseWriter.WriteHeader(code)
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w headWriter) Write(b []byte) (int, error) {
+	w.ResponseWriter.WriteHeaderNow()
+	return len(b), nil
+}
+
+func (w headWriter) WriteString(s string) (int, error) {
+	w.ResponseWriter.WriteHeaderNow()
+	return len(s), nil
+}
+
+type route struct {
+	method   string
+	segments []string
+}
+
+// routeTable matches request paths against the registered gin patterns:
+// ":name" matches one non-empty segment and "*name" the rest of the path.
+type routeTable []route
+
+func newRouteTable(routes gin.RoutesInfo) routeTable {
+	table := make(routeTable, 0, len(routes))
+	for _, r := range routes {
+		table = append(table, route{method: r.Method, segments: strings.Split(r.Path, "/")})
+	}
+	return table
+}
+
+func (t routeTable) has(method, path string) bool {
+	segments := strings.Split(path, "/")
+	for _, r := range t {
+		if r.method == method && r.match(segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed returns the sorted methods path is registered for, with HEAD for
+// GET routes and OPTIONS, or nil if the path is not registered.
+func (t routeTable) allowed(path string) []string {
+	segments := strings.Split(path, "/")
+	set := make(map[string]struct{})
+	for _, r := range t {
+		if r.match(segments) {
+			set[r.method] = struct{}{}
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	if _, ok := set[http.MethodGet]; ok {
+		set[http.MethodHead] = struct{}{}
+	}
+	set[http.MethodOptions] = struct{}{}
+	methods := make([]string, 0, len(set))
+	for method := range set {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (r route) match(segments []string) bool {
+	for i, s := range r.segments {
+		if strings.HasPrefix(s, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(s, ":") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if s != segments[i] {
+			return false
+		}
+	}
+	return len(segments) == len(r.segments)
+}
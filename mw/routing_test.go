@@ -0,0 +1,72 @@
+package mw
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+)
+
+func TestMethodRouting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/user/:id", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"id": c.Param("id")}) })
+	r.POST("/user/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.POST("/msg/send", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/static/*file", func(c *gin.Context) { c.String(http.StatusOK, c.Param("file")) })
+	MethodRouting(r)
+	// Registered after MethodRouting: unknown to the route table.
+	r.GET("/late", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		method, path string
+		code         int
+		allow        string
+		errCode      int
+		empty        bool
+	}{
+		{method: http.MethodGet, path: "/user/1", code: http.StatusOK},
+		{method: http.MethodPost, path: "/user/1", code: http.StatusNoContent, empty: true},
+		{method: http.MethodDelete, path: "/user/1", code: http.StatusMethodNotAllowed, allow: "GET, HEAD, OPTIONS, POST", errCode: errs.ArgsError},
+		{method: http.MethodGet, path: "/msg/send", code: http.StatusMethodNotAllowed, allow: "OPTIONS, POST", errCode: errs.ArgsError},
+		{method: http.MethodHead, path: "/msg/send", code: http.StatusMethodNotAllowed, allow: "OPTIONS, POST", errCode: errs.ArgsError},
+		{method: http.MethodOptions, path: "/msg/send", code: http.StatusOK, allow: "OPTIONS, POST"},
+		{method: http.MethodHead, path: "/user/1", code: http.StatusOK, empty: true},
+		{method: http.MethodPut, path: "/static/a/b.css", code: http.StatusMethodNotAllowed, allow: "GET, HEAD, OPTIONS", errCode: errs.ArgsError},
+		{method: http.MethodGet, path: "/missing", code: http.StatusNotFound, errCode: errs.RecordNotFoundError},
+		{method: http.MethodPost, path: "/user", code: http.StatusNotFound, errCode: errs.RecordNotFoundError},
+		{method: http.MethodOptions, path: "/missing", code: http.StatusNotFound, errCode: errs.RecordNotFoundError},
+		{method: http.MethodHead, path: "/msg/send/extra", code: http.StatusNotFound, errCode: errs.RecordNotFoundError},
+		{method: http.MethodPost, path: "/late", code: http.StatusNotFound, errCode: errs.RecordNotFoundError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		name := tt.method + " " + tt.path
+		if w.Code != tt.code {
+			t.Errorf("%s: status %d, want %d (body %s)", name, w.Code, tt.code, w.Body)
+			continue
+		}
+		if got := w.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s: Allow %q, want %q", name, got, tt.allow)
+		}
+		if tt.empty && w.Body.Len() != 0 {
+			t.Errorf("%s: body %q, want none", name, w.Body)
+		}
+		if tt.method == http.MethodOptions && tt.code == http.StatusOK && w.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Errorf("%s: CORS headers missing: %v", name, w.Header())
+		}
+		if tt.errCode == 0 {
+			continue
+		}
+		var resp struct {
+			ErrCode int `json:"errCode"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.ErrCode != tt.errCode {
+			t.Errorf("%s: body %s, want errCode %d", name, w.Body, tt.errCode)
+		}
+	}
+}
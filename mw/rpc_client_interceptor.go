@@ -23,6 +23,7 @@ import (
 	"github.com/openimsdk/protocol/errinfo"
 	"github.com/openimsdk/tools/errs"
 	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
@@ -47,11 +48,17 @@ func RpcClientInterceptor(ctx context.Context, method string, req, resp any, cc
 		}
 	}()
 	log.ZInfo(ctx, "rpc client request", "method", method, "target", cc.Target(), "req", req)
-	err = invoker(ctx, method, req, resp, cc, opts...)
+	var trailer metadata.MD
+	err = invoker(ctx, method, req, resp, cc, append(opts, grpc.Trailer(&trailer))...)
+	errs.GetWarnings(ctx).Append(trailerWarnings(ctx, trailer)...)
 	if err == nil {
 		log.ZInfo(ctx, "rpc client response success", "method", method, "resp", resp)
 		return nil
 	}
+	if errs.ClassifyContextError(ctx, err) == errs.CallerCancelled {
+		log.ZInfo(ctx, "rpc client request cancelled by caller", "method", method)
+		return errs.ErrCallerCancelled.WrapMsg(err.Error())
+	}
 	if errors.As(err, new(errs.CodeError)) {
 		return err
 	}
@@ -112,6 +119,18 @@ func getRpcContext(ctx context.Context) (context.Context, error) {
 	if ok {
 		md.Set(constant.ConnID, connID)
 	}
+	if workerID := mcontext.GetWorkerID(ctx); workerID != "" {
+		md.Set(mcontext.WorkerID, workerID)
+	}
+	if roles := mcontext.GetOpUserRoles(ctx); len(roles) > 0 {
+		md.Set(mcontext.OpUserRoles, roles...)
+	}
+	if tenantID := mcontext.GetTenantID(ctx); tenantID != "" {
+		md.Set(mcontext.TenantID, tenantID)
+	}
+	if mirrored, _ := ctx.Value(mirrorCallKey{}).(bool); mirrored {
+		md.Set(MirrorHeader, "1")
+	}
 	return metadata.NewOutgoingContext(ctx, md), nil
 }
 
@@ -0,0 +1,51 @@
+package mw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/openimsdk/tools/utils/leaktest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRpcClientInterceptorWorkerID(t *testing.T) {
+	leaktest.Check(t)
+	ctx := mcontext.SetTenantID(log.NewWorkerContext("msgCleaner"), "tenant1")
+	cc, err := grpc.Dial("passthrough:///test", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	if err := RpcClientInterceptor(ctx, "/test/Method", nil, nil, cc, invoker); err != nil {
+		t.Fatal(err)
+	}
+	workerID := mcontext.GetWorkerID(ctx)
+	if got := md.Get(mcontext.WorkerID); len(got) != 1 || got[0] != workerID {
+		t.Fatalf("workerID metadata = %v, want %s", got, workerID)
+	}
+	if got := md.Get(constant.OperationID); len(got) != 1 || got[0] != workerID {
+		t.Fatalf("operationID metadata = %v, want %s", got, workerID)
+	}
+
+	serverCtx, err := enrichContextWithMetadata(context.Background(), md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mcontext.GetWorkerID(serverCtx) != workerID {
+		t.Fatalf("server side workerID = %q", mcontext.GetWorkerID(serverCtx))
+	}
+	if mcontext.GetTenantID(serverCtx) != "tenant1" {
+		t.Fatalf("server side tenantID = %q", mcontext.GetTenantID(serverCtx))
+	}
+}
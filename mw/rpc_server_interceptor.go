@@ -10,6 +10,7 @@ import (
 	"github.com/openimsdk/tools/checker"
 	"github.com/openimsdk/tools/errs"
 	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
 	"github.com/openimsdk/tools/mw/specialerror"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -37,11 +38,18 @@ func RpcServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerIn
 	if err := checker.Validate(req); err != nil {
 		return nil, handleError(ctx, method, req, err)
 	}
+	warnings := errs.NewWarnings(0)
+	ctx = errs.WithWarnings(ctx, warnings)
 	resp, err := handler(ctx, req)
 	if err != nil {
 		return nil, handleError(ctx, method, req, err)
 	}
-	log.ZInfo(ctx, "rpc server response success", "method", method, "req", req, "resp", resp)
+	if list := warnings.List(); len(list) > 0 {
+		setWarningsTrailer(ctx, list)
+		log.ZInfo(ctx, "rpc server response success", "method", method, "req", req, "resp", resp, "warnings", list)
+	} else {
+		log.ZInfo(ctx, "rpc server response success", "method", method, "req", req, "resp", resp)
+	}
 	return resp, nil
 }
 
@@ -77,10 +85,25 @@ func enrichContextWithMetadata(ctx context.Context, md metadata.MD) (context.Con
 	if opts := md.Get(constant.ConnID); len(opts) == 1 {
 		ctx = context.WithValue(ctx, constant.ConnID, opts[0])
 	}
+	if opts := md.Get(mcontext.WorkerID); len(opts) == 1 {
+		ctx = mcontext.SetWorkerID(ctx, opts[0])
+	}
+	if roles := md.Get(mcontext.OpUserRoles); len(roles) > 0 {
+		ctx = mcontext.SetOpUserRoles(ctx, roles)
+	}
+	if opts := md.Get(mcontext.TenantID); len(opts) == 1 {
+		ctx = mcontext.SetTenantID(ctx, opts[0])
+	}
+	if len(md.Get(MirrorHeader)) > 0 {
+		// Forwarded by RpcClientInterceptor, so the calls made while serving a
+		// mirrored call are marked mirrored too.
+		ctx = context.WithValue(ctx, mirrorCallKey{}, true)
+	}
 	return ctx, nil
 }
 
 func handleError(ctx context.Context, method string, req any, err error) error {
+	err = contextError(ctx, err)
 	codeErr := getErrData(err)
 	log.ZAdaptive(ctx, "rpc server response failed", err, "method", method, "req", req)
 	grpcStatus := status.New(codes.Code(codeErr.Code()), codeErr.Msg())
@@ -93,6 +116,19 @@ func handleError(ctx context.Context, method string, req any, err error) error {
 	return details.Err()
 }
 
+// contextError replaces the errors caused by the request context with
+// ErrCallerCancelled, logged at Info and not alerting, or ErrTimeout.
+func contextError(ctx context.Context, err error) error {
+	switch errs.ClassifyContextError(ctx, err) {
+	case errs.CallerCancelled:
+		return errs.ErrCallerCancelled.WrapMsg(errs.Unwrap(err).Error())
+	case errs.DeadlineExceeded:
+		return errs.ErrTimeout.WrapMsg(errs.Unwrap(err).Error())
+	default:
+		return err
+	}
+}
+
 func getErrData(err error) errs.CodeError {
 	var (
 		code        int
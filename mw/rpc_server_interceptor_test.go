@@ -0,0 +1,142 @@
+package mw
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/openimsdk/tools/utils/leaktest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// startUnaryServer serves /test.Echo/Call with handler behind the server
+// interceptor, and reports the gRPC code each call ended with on codes.
+func startUnaryServer(t *testing.T, handler func(ctx context.Context) error) (*grpc.ClientConn, <-chan int) {
+	t.Helper()
+	codes := make(chan int, 1)
+	record := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		resp, err := next(ctx, req)
+		codes <- int(status.Code(err))
+		return resp, err
+	}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(record, RpcServerInterceptor))
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Echo",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Call",
+			Handler: func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				var req wrapperspb.StringValue
+				if err := dec(&req); err != nil {
+					return nil, err
+				}
+				info := &grpc.UnaryServerInfo{FullMethod: "/test.Echo/Call"}
+				return interceptor(ctx, &req, info, func(ctx context.Context, req any) (any, error) {
+					if err := handler(ctx); err != nil {
+						return nil, err
+					}
+					return req, nil
+				})
+			},
+		}},
+	}, struct{}{})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.Dial("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		GrpcClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc, codes
+}
+
+func call(ctx context.Context, cc *grpc.ClientConn) error {
+	ctx = mcontext.SetOperationID(ctx, "ctx-test")
+	return cc.Invoke(ctx, "/test.Echo/Call", wrapperspb.String("hi"), new(wrapperspb.StringValue))
+}
+
+func code(err error) int {
+	var codeErr errs.CodeError
+	if errors.As(errs.Unwrap(err), &codeErr) {
+		return codeErr.Code()
+	}
+	return -1
+}
+
+func TestRpcServerContextErrors(t *testing.T) {
+	leaktest.Check(t)
+	t.Run("caller cancelled", func(t *testing.T) {
+		started := make(chan struct{})
+		cc, codes := startUnaryServer(t, func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			// The handler reports the cancellation as a CodeError.
+			return errs.ErrInternalServer.WrapMsg(ctx.Err().Error())
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-started
+			cancel()
+		}()
+		err := call(ctx, cc)
+		if c := code(err); c != errs.CallerCancelledError {
+			t.Errorf("client code = %d (%v), want %d", c, err, errs.CallerCancelledError)
+		}
+		if c := <-codes; c != errs.CallerCancelledError {
+			t.Errorf("server code = %d, want %d", c, errs.CallerCancelledError)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		cc, codes := startUnaryServer(t, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := call(ctx, cc); err == nil {
+			t.Fatal("call succeeded")
+		}
+		if c := <-codes; c != errs.TimeoutError {
+			t.Errorf("server code = %d, want %d", c, errs.TimeoutError)
+		}
+	})
+
+	t.Run("genuine failure", func(t *testing.T) {
+		cc, codes := startUnaryServer(t, func(ctx context.Context) error {
+			return errs.ErrRecordNotFound.WrapMsg("user not found")
+		})
+		err := call(context.Background(), cc)
+		if c := code(err); c != errs.RecordNotFoundError {
+			t.Errorf("client code = %d, want %d", c, errs.RecordNotFoundError)
+		}
+		if c := <-codes; c != errs.RecordNotFoundError {
+			t.Errorf("server code = %d, want %d", c, errs.RecordNotFoundError)
+		}
+	})
+
+	t.Run("server abort", func(t *testing.T) {
+		cc, codes := startUnaryServer(t, func(ctx context.Context) error {
+			inner, cancel := context.WithCancel(ctx)
+			cancel()
+			return errs.Wrap(inner.Err())
+		})
+		_ = call(context.Background(), cc)
+		if c := <-codes; c != errs.ServerInternalError {
+			t.Errorf("server code = %d, want %d", c, errs.ServerInternalError)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/openimsdk/protocol/errinfo"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamAbortReason marks the status detail that carries the resume token of
+// an errs.StreamAbortError.
+const (
+	streamAbortReason = "STREAM_ABORT"
+	streamAbortDomain = "openim"
+	resumeTokenKey    = "resumeToken"
+)
+
+func GrpcServerStream() grpc.ServerOption {
+	return grpc.ChainStreamInterceptor(RpcServerStreamInterceptor)
+}
+
+func GrpcClientStream() grpc.DialOption {
+	return grpc.WithChainStreamInterceptor(RpcClientStreamInterceptor)
+}
+
+// RpcServerStreamInterceptor is the streaming counterpart of
+// RpcServerInterceptor. Handler errors are returned as coded statuses, and an
+// errs.StreamAbortError also carries its resume token to the client.
+func RpcServerStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	method := info.FullMethod
+	md, err := validateMetadata(ss.Context())
+	if err != nil {
+		return err
+	}
+	ctx, err := enrichContextWithMetadata(ss.Context(), md)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = errs.ErrPanic(r)
+			log.ZPanic(ctx, "rpc server stream panic", err, "method", method)
+			err = handleStreamError(ctx, method, err)
+		}
+	}()
+	log.ZInfo(ctx, "rpc server stream start", "method", method)
+	if err := handler(srv, WrapServerStream(ctx, ss)); err != nil {
+		return handleStreamError(ctx, method, err)
+	}
+	log.ZInfo(ctx, "rpc server stream done", "method", method)
+	return nil
+}
+
+func handleStreamError(ctx context.Context, method string, err error) error {
+	abort, ok := errs.ParseStreamAbort(err)
+	if !ok {
+		return handleError(ctx, method, nil, err)
+	}
+	codeErr := getErrData(err)
+	log.ZAdaptive(ctx, "rpc server stream aborted", err, "method", method, "resumeToken", abort.ResumeToken)
+	grpcStatus, err := status.New(codes.Code(codeErr.Code()), codeErr.Msg()).WithDetails(
+		&errinfo.ErrorInfo{Cause: codeErr.Detail()},
+		&errdetails.ErrorInfo{
+			Reason:   streamAbortReason,
+			Domain:   streamAbortDomain,
+			Metadata: map[string]string{resumeTokenKey: abort.ResumeToken},
+		},
+	)
+	if err != nil {
+		log.ZError(ctx, "rpc server stream WithDetails failed", err, "method", method)
+		return errs.WrapMsg(err, "rpc server stream WithDetails error", "err", err)
+	}
+	return grpcStatus.Err()
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// WrapServerStream returns ss with its context replaced by ctx, so that
+// handlers see the values set by interceptors.
+func WrapServerStream(ctx context.Context, ss grpc.ServerStream) grpc.ServerStream {
+	return &serverStream{ServerStream: ss, ctx: ctx}
+}
+
+// RpcClientStreamInterceptor is the streaming counterpart of
+// RpcClientInterceptor. Errors received from the stream are converted back
+// into code errors, see WrapClientStream.
+func RpcClientStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if ctx == nil {
+		return nil, errs.ErrInternalServer.WrapMsg("call rpc request context is nil")
+	}
+	ctx, err := getRpcContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.ZInfo(ctx, "rpc client stream start", "method", method, "target", cc.Target())
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, parseStreamError(err)
+	}
+	return WrapClientStream(cs), nil
+}
+
+type clientStream struct {
+	grpc.ClientStream
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	return parseStreamError(s.ClientStream.RecvMsg(m))
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	return parseStreamError(s.ClientStream.SendMsg(m))
+}
+
+// WrapClientStream converts the errors of cs into code errors. A stream
+// aborted with errs.StreamAbort yields an error from which errs.ParseStreamAbort
+// recovers the resume token. io.EOF is returned unchanged.
+func WrapClientStream(cs grpc.ClientStream) grpc.ClientStream {
+	return &clientStream{ClientStream: cs}
+}
+
+func parseStreamError(err error) error {
+	if err == nil || err == io.EOF || errors.As(err, new(errs.CodeError)) {
+		return err
+	}
+	sta, ok := status.FromError(err)
+	if !ok {
+		return errs.ErrInternalServer.WrapMsg(err.Error())
+	}
+	if sta.Code() == codes.OK {
+		return errs.NewCodeError(errs.ServerInternalError, err.Error()).Wrap()
+	}
+	var (
+		detail string
+		abort  *errdetails.ErrorInfo
+	)
+	for _, d := range sta.Details() {
+		switch d := d.(type) {
+		case *errinfo.ErrorInfo:
+			detail = strings.Join(d.Warp, "->") + d.Cause
+		case *errdetails.ErrorInfo:
+			if d.Reason == streamAbortReason && d.Domain == streamAbortDomain {
+				abort = d
+			}
+		}
+	}
+	codeErr := errs.NewCodeError(int(sta.Code()), sta.Message())
+	if detail != "" {
+		codeErr = codeErr.WithDetail(detail)
+	}
+	if abort != nil {
+		return errs.Wrap(&errs.StreamAbortError{CodeError: codeErr, ResumeToken: abort.Metadata[resumeTokenKey]})
+	}
+	return codeErr.Wrap()
+}
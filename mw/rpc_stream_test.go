@@ -0,0 +1,123 @@
+package mw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/openimsdk/tools/utils/leaktest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var syncStreamDesc = grpc.StreamDesc{StreamName: "Pull", ServerStreams: true}
+
+// startSyncServer serves a stream sending total messages, then failing with
+// fail when it is not nil.
+func startSyncServer(t *testing.T, total int, fail func(sent int) error) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(GrpcServerStream())
+	desc := syncStreamDesc
+	desc.Handler = func(_ any, stream grpc.ServerStream) error {
+		if mcontext.GetOperationID(stream.Context()) == "" {
+			return errs.ErrArgs.WrapMsg("operationID not propagated")
+		}
+		var req wrapperspb.StringValue
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		for i := 1; i <= total; i++ {
+			if err := stream.SendMsg(wrapperspb.Int64(int64(i))); err != nil {
+				return err
+			}
+		}
+		if fail != nil {
+			return fail(total)
+		}
+		return nil
+	}
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Sync",
+		HandlerType: (*any)(nil),
+		Streams:     []grpc.StreamDesc{desc},
+	}, struct{}{})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.Dial("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		GrpcClientStream())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+// pull reads the stream until it ends and returns the received values.
+func pull(t *testing.T, cc *grpc.ClientConn) ([]int64, error) {
+	t.Helper()
+	ctx := mcontext.SetOperationID(context.Background(), "stream-test")
+	stream, err := cc.NewStream(ctx, &syncStreamDesc, "/test.Sync/Pull")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(wrapperspb.String("conversation")); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	var got []int64
+	for {
+		var msg wrapperspb.Int64Value
+		if err := stream.RecvMsg(&msg); err != nil {
+			return got, err
+		}
+		got = append(got, msg.Value)
+	}
+}
+
+func TestStreamAbortResumeToken(t *testing.T) {
+	leaktest.Check(t)
+	cc := startSyncServer(t, 3, func(sent int) error {
+		return errs.WrapMsg(errs.StreamAbort(errs.ServerInternalError, "storage unavailable", "seq:"+strconv.Itoa(sent)), "pull failed")
+	})
+	got, err := pull(t, cc)
+	if len(got) != 3 {
+		t.Fatalf("received %v before the abort, want 3 messages", got)
+	}
+	abort, ok := errs.ParseStreamAbort(err)
+	if !ok {
+		t.Fatalf("err = %v, want a stream abort", err)
+	}
+	if abort.ResumeToken != "seq:3" || abort.Code() != errs.ServerInternalError || abort.Msg() != "storage unavailable" {
+		t.Fatalf("abort = code %d msg %q token %q", abort.Code(), abort.Msg(), abort.ResumeToken)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	leaktest.Check(t)
+	got, err := pull(t, startSyncServer(t, 2, nil))
+	if err != io.EOF || len(got) != 2 {
+		t.Fatalf("completed stream: %v, %v; want 2 messages and io.EOF", got, err)
+	}
+
+	_, err = pull(t, startSyncServer(t, 1, func(int) error { return errs.ErrRecordNotFound.WrapMsg("no such conversation") }))
+	if _, ok := errs.ParseStreamAbort(err); ok {
+		t.Fatal("plain error parsed as stream abort")
+	}
+	var codeErr errs.CodeError
+	if !errors.As(err, &codeErr) || codeErr.Code() != errs.RecordNotFoundError {
+		t.Fatalf("err = %v, want code %d", err, errs.RecordNotFoundError)
+	}
+}
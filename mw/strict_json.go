@@ -0,0 +1,274 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+)
+
+type strictJSONKey struct{}
+
+// IsStrictJSON reports whether the request of ctx passed the StrictJSON
+// middleware, in which case its body must be decoded with DecodeStrictJSON.
+// a2r binds such requests strictly.
+func IsStrictJSON(ctx context.Context) bool {
+	strict, _ := ctx.Value(strictJSONKey{}).(bool)
+	return strict
+}
+
+type strictJSONConfig struct {
+	lenient map[string]struct{}
+}
+
+type StrictJSONOption func(*strictJSONConfig)
+
+// WithLenientPaths exempts legacy routes from StrictJSON. Paths are matched
+// against the gin route pattern, or the request path for unmatched routes.
+func WithLenientPaths(paths ...string) StrictJSONOption {
+	return func(c *strictJSONConfig) {
+		for _, path := range paths {
+			c.lenient[path] = struct{}{}
+		}
+	}
+}
+
+// StrictJSON rejects request bodies that are not sent as application/json
+// and marks the request so that binding rejects unknown fields. The body is
+// read once and put back, and is also kept under gin.BodyBytesKey, so
+// middlewares after it, such as signature verification, still see the raw
+// bytes.
+func StrictJSON(opts ...StrictJSONOption) gin.HandlerFunc {
+	cfg := strictJSONConfig{lenient: make(map[string]struct{})}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		if _, ok := cfg.lenient[path]; ok {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				apiresp.GinError(c, errs.ErrArgs.WrapMsg("read request body failed", "err", err.Error()))
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Set(gin.BodyBytesKey, body)
+		}
+		if len(body) > 0 {
+			if mediaType, _, _ := mime.ParseMediaType(c.ContentType()); mediaType != binding.MIMEJSON {
+				apiresp.GinError(c, errs.ErrArgs.WrapMsg("Content-Type must be application/json", "contentType", c.ContentType()))
+				c.Abort()
+				return
+			}
+		}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), strictJSONKey{}, true))
+		c.Next()
+	}
+}
+
+// DecodeStrictJSON decodes body into obj, rejecting unknown fields and
+// trailing data. Errors are ArgsErrors naming the offending field and its
+// position in body.
+func DecodeStrictJSON(body []byte, obj any) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(obj); err != nil {
+		return strictJSONError(body, err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		line, column := position(body, dec.InputOffset())
+		return errs.ErrArgs.WrapMsg(fmt.Sprintf("unexpected data after the JSON body at line %d, column %d", line, column))
+	}
+	// encoding/json matches keys case-insensitively, so "groupId" fills
+	// GroupID `json:"groupID"`. Walk the body again to reject such keys.
+	keys := json.NewDecoder(bytes.NewReader(body))
+	if name, offset, ok := inexactKey(keys, reflect.TypeOf(obj)); ok {
+		line, column := position(body, offset)
+		return errs.ErrArgs.WrapMsg(fmt.Sprintf("unknown field %q at line %d, column %d (offset %d)", name, line, column, offset))
+	}
+	return nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// inexactKey walks the next value of dec as decoded into t and returns the
+// first object key that does not match a field name exactly. The body has
+// already been decoded successfully, so token errors cannot occur.
+func inexactKey(dec *json.Decoder, t reflect.Type) (string, int64, bool) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && reflect.PointerTo(t).Implements(unmarshalerType) {
+		t = nil
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return "", 0, false
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return "", 0, false
+	}
+	switch delim {
+	case '{':
+		var fields map[string]reflect.Type
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = make(map[string]reflect.Type)
+			jsonFields(t, fields)
+		}
+		for dec.More() {
+			tok, _ := dec.Token()
+			key, _ := tok.(string)
+			var elem reflect.Type
+			switch {
+			case fields != nil:
+				var ok bool
+				if elem, ok = fields[key]; !ok {
+					quoted, _ := json.Marshal(key)
+					return key, dec.InputOffset() - int64(len(quoted)), true
+				}
+			case t != nil && t.Kind() == reflect.Map:
+				elem = t.Elem()
+			}
+			if name, offset, ok := inexactKey(dec, elem); ok {
+				return name, offset, true
+			}
+		}
+	case '[':
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for dec.More() {
+			if name, offset, ok := inexactKey(dec, elem); ok {
+				return name, offset, true
+			}
+		}
+	}
+	_, _ = dec.Token() // closing delimiter
+	return "", 0, false
+}
+
+// jsonFields adds the JSON names of the fields of struct t, including the
+// ones promoted from embedded structs, to fields.
+func jsonFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				jsonFields(ft, fields)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+}
+
+func strictJSONError(body []byte, err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset counts the bytes read, including the invalid one.
+		offset := max(syntaxErr.Offset-1, 0)
+		line, column := position(body, offset)
+		return errs.ErrArgs.WrapMsg(fmt.Sprintf("invalid JSON at line %d, column %d (offset %d): %s", line, column, offset, syntaxErr.Error()))
+	case errors.As(err, &typeErr):
+		line, column := position(body, typeErr.Offset)
+		return errs.ErrArgs.WrapMsg(fmt.Sprintf("field %q must be %s, got %s at line %d, column %d (offset %d)", typeErr.Field, typeErr.Type, typeErr.Value, line, column, typeErr.Offset))
+	}
+	// encoding/json reports unknown fields with a plain error.
+	if name, ok := strings.CutPrefix(err.Error(), `json: unknown field "`); ok {
+		name = strings.TrimSuffix(name, `"`)
+		offset := fieldOffset(body, name)
+		line, column := position(body, offset)
+		return errs.ErrArgs.WrapMsg(fmt.Sprintf("unknown field %q at line %d, column %d (offset %d)", name, line, column, offset))
+	}
+	return errs.ErrArgs.WrapMsg("invalid JSON body: " + err.Error())
+}
+
+// fieldOffset returns the offset of the first object key name in body.
+func fieldOffset(body []byte, name string) int64 {
+	key, _ := json.Marshal(name)
+	for from := 0; ; {
+		i := bytes.Index(body[from:], key)
+		if i < 0 {
+			return 0
+		}
+		start := from + i
+		rest := bytes.TrimLeft(body[start+len(key):], " \t\r\n")
+		if len(rest) > 0 && rest[0] == ':' {
+			return int64(start)
+		}
+		from = start + len(key)
+	}
+}
+
+// position converts a byte offset of body to a 1-based line and column.
+func position(body []byte, offset int64) (line, column int) {
+	if offset > int64(len(body)) {
+		offset = int64(len(body))
+	}
+	before := body[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
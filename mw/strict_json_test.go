@@ -0,0 +1,110 @@
+package mw
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+)
+
+type strictItem struct {
+	GroupID string `json:"groupID"`
+}
+
+type strictReq struct {
+	strictItem
+	Count int           `json:"count"`
+	Items []*strictItem `json:"items"`
+	Extra map[string]strictItem
+}
+
+func strictEngine(opts ...StrictJSONOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(StrictJSON(opts...))
+	handler := func(c *gin.Context) {
+		// Stands in for a signature check that reads the raw body after StrictJSON.
+		raw, _ := io.ReadAll(c.Request.Body)
+		cached, _ := c.Get(gin.BodyBytesKey)
+		cachedBody, _ := cached.([]byte)
+		c.JSON(http.StatusOK, gin.H{"strict": IsStrictJSON(c.Request.Context()), "raw": string(raw), "cached": string(cachedBody)})
+	}
+	engine.POST("/group/get", handler)
+	engine.POST("/legacy/get", handler)
+	return engine
+}
+
+func postJSON(engine *gin.Engine, path, contentType, body string) map[string]any {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	return out
+}
+
+func TestStrictJSONContentType(t *testing.T) {
+	engine := strictEngine()
+	body := `{"groupID":"g1"}`
+	out := postJSON(engine, "/group/get", "application/json; charset=utf-8", body)
+	if out["strict"] != true || out["raw"] != body || out["cached"] != body {
+		t.Fatalf("strict request = %v", out)
+	}
+	for _, ct := range []string{"application/x-www-form-urlencoded", "text/plain", ""} {
+		out := postJSON(engine, "/group/get", ct, body)
+		if out["errCode"] != float64(errs.ArgsError) {
+			t.Errorf("Content-Type %q accepted: %v", ct, out)
+		}
+	}
+}
+
+func TestStrictJSONLenientPaths(t *testing.T) {
+	engine := strictEngine(WithLenientPaths("/legacy/get"))
+	out := postJSON(engine, "/legacy/get", "application/x-www-form-urlencoded", "groupID=g1")
+	if out["strict"] != false || out["raw"] != "groupID=g1" {
+		t.Fatalf("lenient route = %v", out)
+	}
+	if out := postJSON(engine, "/group/get", "text/plain", "{}"); out["errCode"] != float64(errs.ArgsError) {
+		t.Fatalf("strict route accepted text/plain: %v", out)
+	}
+}
+
+func TestDecodeStrictJSON(t *testing.T) {
+	var req strictReq
+	if err := DecodeStrictJSON([]byte(`{"groupID":"g1","count":2,"items":[{"groupID":"g2"}],"Extra":{"anyKey":{"groupID":"g3"}}}`), &req); err != nil || req.GroupID != "g1" || req.Count != 2 {
+		t.Fatalf("valid body: %+v, %v", req, err)
+	}
+
+	cases := map[string]struct {
+		body, want string
+	}{
+		"unknown field": {"{\n  \"groupID\": \"g1\",\n  \"groupId\": \"g2\"\n}", `unknown field "groupId" at line 3, column 3 (offset 23)`},
+		"misspelled":    {`{"groupID":"g1","grupID":"g2"}`, `unknown field "grupID" at line 1, column 17 (offset 16)`},
+		"value matches": {`{"count":1,"x":"groupID"}`, `unknown field "x" at line 1, column 12 (offset 11)`},
+		"nested":        {`{"items":[{"groupID":"g1"},{"GroupID":"g2"}]}`, `unknown field "GroupID" at line 1, column 29 (offset 28)`},
+		"wrong type":    {`{"count":"2"}`, `field "count" must be int, got string`},
+		"syntax":        {"{\n\"groupID\" \"g1\"}", "invalid JSON at line 2, column 11 (offset 12)"},
+		"trailing":      {`{"groupID":"g1"} {}`, "unexpected data after the JSON body at line 1, column 19"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := DecodeStrictJSON([]byte(c.body), &strictReq{})
+			var codeErr errs.CodeError
+			if !errors.As(err, &codeErr) || codeErr.Code() != errs.ArgsError {
+				t.Fatalf("err = %v, want ArgsError", err)
+			}
+			if !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("message %q does not contain %q", err.Error(), c.want)
+			}
+		})
+	}
+}
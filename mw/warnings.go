@@ -0,0 +1,88 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mw
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// warningsTrailer is the trailer carrying the warnings of an RPC, one JSON
+// encoded wireWarning per value. It is binary so that messages need not be
+// ASCII.
+const warningsTrailer = "openim-warnings-bin"
+
+// wireWarning is an errs.Warning with its fingerprint, so that the caller
+// deduplicates it with its own warnings.
+type wireWarning struct {
+	errs.Warning
+	Fingerprint string `json:"fingerprint"`
+}
+
+// GinWarnings attaches an errs.Warnings keeping at most max warnings to the
+// requests, which apiresp writes in the warnings array of the envelope and
+// the rpc client interceptor fills with the warnings of the downstream calls.
+func GinWarnings(max int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := errs.NewWarnings(max)
+		c.Set(errs.WarningsKey, w)
+		c.Request = c.Request.WithContext(errs.WithWarnings(c.Request.Context(), w))
+		c.Next()
+	}
+}
+
+// setWarningsTrailer sends the warnings recorded while serving ctx to the
+// caller.
+func setWarningsTrailer(ctx context.Context, warnings []errs.Warning) {
+	if len(warnings) == 0 {
+		return
+	}
+	values := make([]string, 0, len(warnings))
+	for _, w := range warnings {
+		b, err := json.Marshal(wireWarning{Warning: w, Fingerprint: w.Fingerprint})
+		if err != nil {
+			continue
+		}
+		values = append(values, string(b))
+	}
+	if err := grpc.SetTrailer(ctx, metadata.MD{warningsTrailer: values}); err != nil {
+		log.ZWarn(ctx, "rpc server set warnings trailer failed", err)
+	}
+}
+
+// trailerWarnings decodes the warnings of a call from its trailer.
+func trailerWarnings(ctx context.Context, md metadata.MD) []errs.Warning {
+	values := md.Get(warningsTrailer)
+	if len(values) == 0 {
+		return nil
+	}
+	warnings := make([]errs.Warning, 0, len(values))
+	for _, v := range values {
+		var w wireWarning
+		if err := json.Unmarshal([]byte(v), &w); err != nil {
+			log.ZWarn(ctx, "rpc client invalid warnings trailer", err, "value", v)
+			continue
+		}
+		w.Warning.Fingerprint = w.Fingerprint
+		warnings = append(warnings, w.Warning)
+	}
+	return warnings
+}
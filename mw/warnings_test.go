@@ -0,0 +1,95 @@
+package mw
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+)
+
+func TestRpcWarnings(t *testing.T) {
+	cc, codes := startUnaryServer(t, func(ctx context.Context) error {
+		for _, userID := range []string{"u1", "u2"} {
+			errs.AddWarning(ctx, errs.ErrRecordNotFound.WrapMsg("offline push skipped, no device token", "userID", userID))
+		}
+		return nil
+	})
+
+	warnings := errs.NewWarnings(0)
+	if err := call(errs.WithWarnings(context.Background(), warnings), cc); err != nil {
+		t.Fatal(err)
+	}
+	<-codes
+	list := warnings.List()
+	if len(list) != 1 || list[0].ErrCode != errs.RecordNotFoundError || list[0].ErrDlt != "offline push skipped, no device token, userID=u1" ||
+		list[0].Count != 2 || list[0].Fingerprint == "" {
+		t.Fatalf("client warnings = %+v", list)
+	}
+
+	// The caller merges the same warning from a second call.
+	if err := call(errs.WithWarnings(context.Background(), warnings), cc); err != nil {
+		t.Fatal(err)
+	}
+	<-codes
+	if list := warnings.List(); len(list) != 1 || list[0].Count != 4 {
+		t.Fatalf("client warnings after two calls = %+v", list)
+	}
+
+	if err := call(context.Background(), cc); err != nil {
+		t.Fatalf("call without accumulator: %v", err)
+	}
+	<-codes
+}
+
+func TestGinWarningsEnvelope(t *testing.T) {
+	cc, codes := startUnaryServer(t, func(ctx context.Context) error {
+		errs.AddWarning(ctx, errs.ErrRecordNotFound.WrapMsg("offline push skipped"))
+		return nil
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinWarnings(0))
+	r.POST("/send", func(c *gin.Context) {
+		if err := call(c, cc); err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+		apiresp.GinSuccess(c, map[string]string{"msgID": "m1"})
+	})
+	r.POST("/quiet", func(c *gin.Context) {
+		apiresp.GinSuccess(c, nil)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/send", nil))
+	<-codes
+	var body struct {
+		ErrCode  int `json:"errCode"`
+		Warnings []struct {
+			ErrCode int    `json:"errCode"`
+			ErrMsg  string `json:"errMsg"`
+			Count   int    `json:"count"`
+		} `json:"warnings"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %s: %v", rec.Body, err)
+	}
+	if body.ErrCode != 0 || len(body.Warnings) != 1 || body.Warnings[0].ErrCode != errs.RecordNotFoundError || body.Warnings[0].Count != 1 {
+		t.Fatalf("body = %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/quiet", nil))
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw["warnings"]; ok {
+		t.Errorf("envelope without warnings has the field: %s", rec.Body)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package minio
+
+import (
+	"os"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/openimsdk/tools/errs"
+)
+
+// Credential providers, see Config.CredentialsProvider.
+const (
+	// CredentialsStatic uses AccessKeyID, SecretAccessKey and SessionToken of
+	// the configuration.
+	CredentialsStatic = "static"
+	// CredentialsEnv reads MINIO_ROOT_USER and MINIO_ROOT_PASSWORD, or
+	// MINIO_ACCESS_KEY, MINIO_SECRET_KEY and MINIO_SESSION_TOKEN, then the
+	// AWS_* variables.
+	CredentialsEnv = "env"
+	// CredentialsIAM uses the IAM role of the host, for MinIO fronting S3:
+	// web identity, ECS task role or EC2 instance profile.
+	CredentialsIAM = "iam"
+)
+
+// envMinio is credentials.EnvMinio with the session token of temporary
+// credentials.
+type envMinio struct {
+	credentials.EnvMinio
+}
+
+func (e *envMinio) Retrieve() (credentials.Value, error) {
+	v, err := e.EnvMinio.Retrieve()
+	if err == nil && v.SignerType != credentials.SignatureAnonymous {
+		v.SessionToken = os.Getenv("MINIO_SESSION_TOKEN")
+	}
+	return v, err
+}
+
+type namedProvider struct {
+	name string
+	credentials.Provider
+}
+
+// newCredentials returns the credentials of conf. The env and iam providers
+// are resolved upfront, so that a missing credential fails here, with the name
+// of every provider attempted, instead of as an anonymous request denied later.
+func newCredentials(conf *Config) (*credentials.Credentials, error) {
+	var providers []namedProvider
+	switch conf.CredentialsProvider {
+	case "", CredentialsStatic:
+		return credentials.NewStaticV4(conf.AccessKeyID, conf.SecretAccessKey, conf.SessionToken), nil
+	case CredentialsEnv:
+		providers = []namedProvider{{"minio-env", &envMinio{}}, {"aws-env", &credentials.EnvAWS{}}}
+	case CredentialsIAM:
+		providers = []namedProvider{{"iam", &credentials.IAM{}}}
+	default:
+		return nil, errs.ErrConfig.WrapMsg("unknown minio credentials provider", "provider", conf.CredentialsProvider)
+	}
+	attempted := make([]string, 0, len(providers))
+	for _, p := range providers {
+		v, err := p.Retrieve()
+		if err == nil && v.AccessKeyID != "" && v.SecretAccessKey != "" {
+			return credentials.New(p.Provider), nil
+		}
+		if err != nil {
+			attempted = append(attempted, p.name+": "+err.Error())
+		} else {
+			attempted = append(attempted, p.name+": not set")
+		}
+	}
+	return nil, errs.ErrConfig.WrapMsg("no minio credentials resolved", "provider", conf.CredentialsProvider, "attempted", attempted)
+}
@@ -32,10 +32,10 @@ import (
 	"github.com/minio/minio-go/v7"
 	"github.com/openimsdk/tools/s3"
 
-	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/minio/minio-go/v7/pkg/signer"
 	"github.com/openimsdk/tools/errs"
 	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/network"
 )
 
 const (
@@ -67,16 +67,44 @@ type Config struct {
 	SessionToken    string
 	SignEndpoint    string
 	PublicRead      bool
+	// CredentialsProvider selects where the credentials come from, see
+	// CredentialsStatic, CredentialsEnv and CredentialsIAM. Empty is static.
+	CredentialsProvider string
+	// Region is the location the bucket is created in when missing, and the
+	// region the client signs for. Empty lets the server pick its default.
+	Region string
+}
+
+// parseEndpoint parses a MinIO endpoint URL. Endpoints without a scheme, such
+// as [fd00::1]:9000, are treated as http.
+func parseEndpoint(endpoint string) (*url.URL, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errs.ErrConfig.WrapMsg("invalid minio endpoint", "endpoint", endpoint, "err", err.Error())
+	}
+	if err := network.ValidateHost(u.Host); err != nil {
+		return nil, errs.WrapMsg(err, "invalid minio endpoint", "endpoint", endpoint)
+	}
+	return u, nil
 }
 
 func NewMinio(ctx context.Context, cache Cache, conf Config) (*Minio, error) {
-	u, err := url.Parse(conf.Endpoint)
+	u, err := parseEndpoint(conf.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := newCredentials(&conf)
 	if err != nil {
 		return nil, err
 	}
 	opts := &minio.Options{
-		Creds:  credentials.NewStaticV4(conf.AccessKeyID, conf.SecretAccessKey, conf.SessionToken),
+		Creds:  creds,
 		Secure: u.Scheme == "https",
+		Region: conf.Region,
 	}
 	client, err := minio.New(u.Host, opts)
 	if err != nil {
@@ -98,13 +126,14 @@ func NewMinio(ctx context.Context, cache Cache, conf Config) (*Minio, error) {
 		conf.Endpoint = u.String()
 		m.signEndpoint = conf.Endpoint
 	} else {
-		su, err := url.Parse(conf.SignEndpoint)
+		su, err := parseEndpoint(conf.SignEndpoint)
 		if err != nil {
 			return nil, err
 		}
 		m.opts = &minio.Options{
-			Creds:  credentials.NewStaticV4(conf.AccessKeyID, conf.SecretAccessKey, conf.SessionToken),
+			Creds:  creds,
 			Secure: su.Scheme == "https",
+			Region: conf.Region,
 		}
 		m.sign, err = minio.New(su.Host, m.opts)
 		if err != nil {
@@ -149,7 +178,7 @@ func (m *Minio) initMinio(ctx context.Context) error {
 		return fmt.Errorf("check bucket exists error: %w", err)
 	}
 	if !exists {
-		if err = m.core.Client.MakeBucket(ctx, m.conf.Bucket, minio.MakeBucketOptions{}); err != nil {
+		if err = m.core.Client.MakeBucket(ctx, m.conf.Bucket, minio.MakeBucketOptions{Region: m.conf.Region}); err != nil {
 			return fmt.Errorf("make bucket error: %w", err)
 		}
 	}
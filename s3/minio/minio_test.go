@@ -0,0 +1,172 @@
+package minio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/openimsdk/tools/errs"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	for endpoint, host := range map[string]string{
+		"http://10.0.0.1:9000":     "10.0.0.1:9000",
+		"https://minio.local":      "minio.local",
+		"http://[fd00::1]:9000":    "[fd00::1]:9000",
+		"[fd00::1]:9000":           "[fd00::1]:9000",
+		"minio:9000":               "minio:9000",
+		"http://[fd00::1]/openim/": "[fd00::1]",
+	} {
+		u, err := parseEndpoint(endpoint)
+		if err != nil {
+			t.Errorf("parseEndpoint(%q): %v", endpoint, err)
+			continue
+		}
+		if u.Host != host {
+			t.Errorf("parseEndpoint(%q).Host = %q, want %q", endpoint, u.Host, host)
+		}
+	}
+	for _, endpoint := range []string{"http://fd00::1:9000", "fd00::1:9000", "http://"} {
+		_, err := parseEndpoint(endpoint)
+		var codeErr errs.CodeError
+		if !errors.As(err, &codeErr) || codeErr.Code() != errs.ConfigError {
+			t.Errorf("parseEndpoint(%q) err = %v, want ErrConfig", endpoint, err)
+		}
+	}
+}
+
+// fakeS3 answers the bucket requests of Check: exists tells whether the bucket
+// is there, denied refuses every request.
+func fakeS3(t *testing.T, exists, denied bool) (*httptest.Server, *[]string) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch {
+		case denied:
+			w.WriteHeader(http.StatusForbidden)
+		case r.Method == http.MethodHead && !exists:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(body), "<LocationConstraint>eu-west-1</LocationConstraint>") {
+				t.Errorf("MakeBucket body = %s, want the configured region", body)
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestCheck(t *testing.T) {
+	conf := func(srv *httptest.Server) *Config {
+		return &Config{Bucket: "openim", Endpoint: srv.URL, AccessKeyID: "ak", SecretAccessKey: "s3cr3t", Region: "eu-west-1"}
+	}
+	t.Run("exists", func(t *testing.T) {
+		srv, _ := fakeS3(t, true, false)
+		if created, err := CheckBucket(context.Background(), conf(srv), true); err != nil || created {
+			t.Fatalf("CheckBucket = %v, %v, want false, nil", created, err)
+		}
+	})
+	t.Run("missing", func(t *testing.T) {
+		srv, requests := fakeS3(t, false, false)
+		_, err := CheckBucket(context.Background(), conf(srv), false)
+		if !errors.Is(err, errs.ErrComponentStart) || !strings.Contains(err.Error(), "does not exist") {
+			t.Fatalf("err = %v, want bucket does not exist", err)
+		}
+		for _, r := range *requests {
+			if strings.HasPrefix(r, http.MethodPut) {
+				t.Errorf("bucket created without create: %s", r)
+			}
+		}
+	})
+	t.Run("create", func(t *testing.T) {
+		srv, requests := fakeS3(t, false, false)
+		// Check creates the bucket, as it always did.
+		if err := Check(context.Background(), conf(srv)); err != nil {
+			t.Fatal(err)
+		}
+		if last := (*requests)[len(*requests)-1]; last != "PUT /openim/" {
+			t.Errorf("last request = %s, want PUT /openim/", last)
+		}
+	})
+	t.Run("denied", func(t *testing.T) {
+		srv, _ := fakeS3(t, true, true)
+		_, err := CheckBucket(context.Background(), conf(srv), true)
+		if !errors.Is(err, errs.ErrComponentStart) || !strings.Contains(err.Error(), "access denied") {
+			t.Fatalf("err = %v, want access denied", err)
+		}
+		if strings.Contains(err.Error(), "s3cr3t") {
+			t.Errorf("err leaks the secret key: %v", err)
+		}
+	})
+}
+
+func TestNewCredentials(t *testing.T) {
+	for _, k := range []string{"MINIO_ROOT_USER", "MINIO_ROOT_PASSWORD", "MINIO_ACCESS_KEY", "MINIO_SECRET_KEY", "MINIO_SESSION_TOKEN",
+		"AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY", "AWS_SESSION_TOKEN"} {
+		t.Setenv(k, "")
+	}
+	_, err := newCredentials(&Config{CredentialsProvider: CredentialsEnv, AccessKeyID: "ignored", SecretAccessKey: "ignored"})
+	if !errors.Is(err, errs.ErrConfig) || !strings.Contains(err.Error(), "minio-env") || !strings.Contains(err.Error(), "aws-env") {
+		t.Fatalf("err = %v, want ErrConfig naming the attempted providers", err)
+	}
+	if _, err := newCredentials(&Config{CredentialsProvider: "vault"}); !errors.Is(err, errs.ErrConfig) {
+		t.Fatalf("unknown provider err = %v, want ErrConfig", err)
+	}
+
+	t.Setenv("MINIO_ACCESS_KEY", "ak")
+	t.Setenv("MINIO_SECRET_KEY", "sk")
+	t.Setenv("MINIO_SESSION_TOKEN", "sts-token")
+	for provider, want := range map[string]credentials.Value{
+		CredentialsEnv:    {AccessKeyID: "ak", SecretAccessKey: "sk", SessionToken: "sts-token"},
+		CredentialsStatic: {AccessKeyID: "static-ak", SecretAccessKey: "static-sk", SessionToken: "static-token"},
+		"":                {AccessKeyID: "static-ak", SecretAccessKey: "static-sk", SessionToken: "static-token"},
+	} {
+		creds, err := newCredentials(&Config{CredentialsProvider: provider, AccessKeyID: "static-ak", SecretAccessKey: "static-sk", SessionToken: "static-token"})
+		if err != nil {
+			t.Fatalf("%q: %v", provider, err)
+		}
+		v, err := creds.Get()
+		if err != nil {
+			t.Fatalf("%q: %v", provider, err)
+		}
+		if v.AccessKeyID != want.AccessKeyID || v.SecretAccessKey != want.SecretAccessKey || v.SessionToken != want.SessionToken {
+			t.Errorf("%q: credentials = %+v, want %+v", provider, v, want)
+		}
+	}
+}
+
+func TestProbeSignEndpoint(t *testing.T) {
+	var paths []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		// A proxy serving only the bucket paths answers 404 on the health path.
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer proxy.Close()
+	if err := probeSignEndpoint(context.Background(), proxy.URL+"/s3/"); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/s3/minio/health/live" {
+		t.Errorf("probed paths = %v", paths)
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	srv, _ := fakeS3(t, true, false)
+	err := Check(context.Background(), &Config{Bucket: "openim", Endpoint: srv.URL, SignEndpoint: closed.URL, AccessKeyID: "ak", SecretAccessKey: "s3cr3t", Region: "eu-west-1"})
+	if !errors.Is(err, errs.ErrComponentStart) || !strings.Contains(err.Error(), "sign endpoint unreachable") {
+		t.Fatalf("err = %v, want sign endpoint unreachable", err)
+	}
+}
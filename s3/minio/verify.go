@@ -1,11 +1,107 @@
 package minio
 
-import "context"
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/minio/minio-go/v7"
+	"github.com/openimsdk/tools/errs"
+)
+
+// Check verifies that MinIO accepts the credentials of config and that its
+// bucket exists, creating it in config.Region when missing, as NewMinio does.
+// A SignEndpoint other than the endpoint must be reachable too.
 func Check(ctx context.Context, config *Config) error {
-	m, err := NewMinio(ctx, nil, *config)
+	_, err := CheckBucket(ctx, config, true)
+	return err
+}
+
+// CheckBucket is Check, except that without create a missing bucket fails
+// the check, leaving the server unchanged. created reports whether the
+// bucket was created.
+func CheckBucket(ctx context.Context, config *Config, create bool) (created bool, err error) {
+	if config.Bucket == "" {
+		return false, errs.ErrConfig.WrapMsg("minio bucket is empty")
+	}
+	u, err := parseEndpoint(config.Endpoint)
+	if err != nil {
+		return false, err
+	}
+	creds, err := newCredentials(config)
+	if err != nil {
+		return false, err
+	}
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  creds,
+		Secure: u.Scheme == "https",
+		Region: config.Region,
+	})
+	if err != nil {
+		return false, errs.WrapMsg(err, "minio client creation failed", "endpoint", config.Endpoint)
+	}
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return false, bucketError(err, "minio bucket check failed", config)
+	}
+	if config.SignEndpoint != "" && config.SignEndpoint != config.Endpoint {
+		if err := probeSignEndpoint(ctx, config.SignEndpoint); err != nil {
+			return false, err
+		}
+	}
+	if exists {
+		return false, nil
+	}
+	if !create {
+		return false, errs.ErrComponentStart.WrapMsg("minio bucket does not exist", "bucket", config.Bucket, "endpoint", config.Endpoint)
+	}
+	if err := client.MakeBucket(ctx, config.Bucket, minio.MakeBucketOptions{Region: config.Region}); err != nil {
+		// Another instance may have created it in the meantime.
+		if code := minio.ToErrorResponse(err).Code; code == "BucketAlreadyOwnedByYou" {
+			return false, nil
+		}
+		return false, bucketError(err, "minio bucket creation failed", config)
+	}
+	return true, nil
+}
+
+// signProbeTimeout bounds the reachability probe of the sign endpoint.
+var signProbeTimeout = 5 * time.Second
+
+// probeSignEndpoint checks that the endpoint of the presigned URLs answers a
+// GET of the MinIO liveness path. Any status will do, since a proxy in front
+// of MinIO may not serve that path. It cannot tell whether clients outside the
+// network of the service reach the endpoint too.
+func probeSignEndpoint(ctx context.Context, signEndpoint string) error {
+	u, err := parseEndpoint(signEndpoint)
 	if err != nil {
 		return err
 	}
-	return m.initMinio(ctx)
+	ctx, cancel := context.WithTimeout(ctx, signProbeTimeout)
+	defer cancel()
+	health := *u
+	health.Path = strings.TrimSuffix(u.Path, "/") + "/minio/health/live"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, health.String(), nil)
+	if err != nil {
+		return errs.WrapMsg(err, "invalid minio sign endpoint", "signEndpoint", signEndpoint)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errs.ErrComponentStart.WrapMsg("minio sign endpoint unreachable", "signEndpoint", signEndpoint, "err", err.Error())
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// bucketError wraps err with msg, telling a refusal of the credentials apart
+// from other failures.
+func bucketError(err error, msg string, config *Config) error {
+	resp := minio.ToErrorResponse(err)
+	switch resp.Code {
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+		return errs.ErrComponentStart.WrapMsg("minio access denied", "bucket", config.Bucket, "endpoint", config.Endpoint,
+			"accessKeyID", config.AccessKeyID, "code", resp.Code)
+	}
+	return errs.WrapMsg(err, msg, "bucket", config.Bucket, "endpoint", config.Endpoint, "region", config.Region)
 }
@@ -0,0 +1,75 @@
+package tokenverify
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/openimsdk/tools/errs"
+)
+
+// DeviceRegistry reports devices that must no longer be trusted.
+type DeviceRegistry interface {
+	IsCompromised(ctx context.Context, userID, deviceID, deviceHash string) (bool, error)
+}
+
+type DeviceOption func(*DeviceVerifier)
+
+// WithDeviceRegistry consults registry for every token bound to a device.
+func WithDeviceRegistry(registry DeviceRegistry) DeviceOption {
+	return func(v *DeviceVerifier) {
+		v.registry = registry
+	}
+}
+
+// WithStrictDevice rejects tokens issued without device claims.
+func WithStrictDevice(strict bool) DeviceOption {
+	return func(v *DeviceVerifier) {
+		v.strict = strict
+	}
+}
+
+// DeviceVerifier verifies tokens together with the device presenting them.
+type DeviceVerifier struct {
+	secret   jwt.Keyfunc
+	registry DeviceRegistry
+	strict   bool
+}
+
+func NewDeviceVerifier(secret jwt.Keyfunc, opts ...DeviceOption) *DeviceVerifier {
+	v := &DeviceVerifier{secret: secret}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// VerifyWithDevice parses token and checks that it was issued to
+// presentedDeviceID. Device mismatches, compromised devices and, in strict
+// mode, tokens without device claims fail with errs.ErrTokenDeviceMismatch so
+// that clients can ask the user to log in again.
+func (v *DeviceVerifier) VerifyWithDevice(ctx context.Context, token, presentedDeviceID string) (*Claims, error) {
+	claims, err := GetClaimFromToken(token, v.secret)
+	if err != nil {
+		return nil, err
+	}
+	if claims.DeviceID == "" {
+		if v.strict {
+			return nil, errs.ErrTokenDeviceMismatch.WrapMsg("token is not bound to a device", "userID", claims.UserID)
+		}
+		return claims, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.DeviceID), []byte(presentedDeviceID)) != 1 {
+		return nil, errs.ErrTokenDeviceMismatch.WrapMsg("token was issued to another device", "userID", claims.UserID)
+	}
+	if v.registry != nil {
+		compromised, err := v.registry.IsCompromised(ctx, claims.UserID, claims.DeviceID, claims.DeviceHash)
+		if err != nil {
+			return nil, errs.WrapMsg(err, "device registry check failed", "userID", claims.UserID, "deviceID", claims.DeviceID)
+		}
+		if compromised {
+			return nil, errs.ErrTokenDeviceMismatch.WrapMsg("device is marked compromised", "userID", claims.UserID, "deviceID", claims.DeviceID)
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,103 @@
+package tokenverify
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/tools/errs"
+)
+
+type fakeRegistry struct {
+	compromised map[string]bool
+	err         error
+	calls       int
+}
+
+func (f *fakeRegistry) IsCompromised(ctx context.Context, userID, deviceID, deviceHash string) (bool, error) {
+	f.calls++
+	return f.compromised[deviceID+"/"+deviceHash], f.err
+}
+
+func signClaims(t *testing.T, claims Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerifyWithDevice(t *testing.T) {
+	bound := signClaims(t, BuildClaims("123456", constant.IOSPlatformID, 10, WithDevice("dev-a", "hash-a")))
+	legacy := signClaims(t, BuildClaims("123456", constant.IOSPlatformID, 10))
+	registry := &fakeRegistry{compromised: map[string]bool{"dev-b/hash-b": true}}
+	compromised := signClaims(t, BuildClaims("123456", constant.IOSPlatformID, 10, WithDevice("dev-b", "hash-b")))
+
+	tests := []struct {
+		name      string
+		strict    bool
+		registry  DeviceRegistry
+		token     string
+		presented string
+		want      error // nil, ErrTokenDeviceMismatch or another sentinel
+	}{
+		{"bound match", false, nil, bound, "dev-a", nil},
+		{"bound match strict", true, nil, bound, "dev-a", nil},
+		{"bound mismatch", false, nil, bound, "dev-x", errs.ErrTokenDeviceMismatch},
+		{"bound mismatch strict", true, nil, bound, "dev-x", errs.ErrTokenDeviceMismatch},
+		{"bound no device presented", false, nil, bound, "", errs.ErrTokenDeviceMismatch},
+		{"legacy", false, nil, legacy, "dev-a", nil},
+		{"legacy no device presented", false, nil, legacy, "", nil},
+		{"legacy strict", true, nil, legacy, "dev-a", errs.ErrTokenDeviceMismatch},
+		{"registry clean", true, registry, bound, "dev-a", nil},
+		{"registry compromised", false, registry, compromised, "dev-b", errs.ErrTokenDeviceMismatch},
+		{"registry compromised strict", true, registry, compromised, "dev-b", errs.ErrTokenDeviceMismatch},
+		{"registry skipped for legacy", false, registry, legacy, "", nil},
+		{"invalid token", false, nil, bound + "x", "dev-a", errs.ErrTokenUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []DeviceOption{WithStrictDevice(tt.strict)}
+			if tt.registry != nil {
+				opts = append(opts, WithDeviceRegistry(tt.registry))
+			}
+			claims, err := NewDeviceVerifier(secretFun(), opts...).VerifyWithDevice(context.Background(), tt.token, tt.presented)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if claims.UserID != "123456" {
+					t.Errorf("claims = %+v", claims)
+				}
+				return
+			}
+			var codeErr errs.CodeError
+			if !errors.As(err, &codeErr) || codeErr.Code() != tt.want.(errs.CodeError).Code() {
+				t.Fatalf("err = %v, want code %d", err, tt.want.(errs.CodeError).Code())
+			}
+		})
+	}
+}
+
+func TestVerifyWithDeviceRegistryError(t *testing.T) {
+	bound := signClaims(t, BuildClaims("123456", constant.IOSPlatformID, 10, WithDevice("dev-a", "hash-a")))
+	registryErr := errors.New("redis down")
+	v := NewDeviceVerifier(secretFun(), WithDeviceRegistry(&fakeRegistry{err: registryErr}))
+	if _, err := v.VerifyWithDevice(context.Background(), bound, "dev-a"); !errors.Is(err, registryErr) {
+		t.Fatalf("err = %v, want registry error", err)
+	}
+}
+
+func TestDeviceClaimsRoundTrip(t *testing.T) {
+	token := signClaims(t, BuildClaims("123456", constant.IOSPlatformID, 10, WithDevice("dev-a", "hash-a")))
+	claims, err := GetClaimFromToken(token, secretFun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.DeviceID != "dev-a" || claims.DeviceHash != "hash-a" {
+		t.Fatalf("device claims lost: %+v", claims)
+	}
+}
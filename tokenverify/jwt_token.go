@@ -27,13 +27,39 @@ const secondBefore = 5
 type Claims struct {
 	UserID     string
 	PlatformID int // login platform
+	// DeviceID and DeviceHash bind the token to a device, see WithDevice.
+	DeviceID   string `json:",omitempty"`
+	DeviceHash string `json:",omitempty"`
+	// Roles are the permission roles of the user, see WithRoles.
+	Roles []string `json:",omitempty"`
 	jwt.RegisteredClaims
 }
 
-func BuildClaims(uid string, platformID int, ttl int64) Claims {
+// ClaimsOption sets optional claims in BuildClaims.
+type ClaimsOption func(*Claims)
+
+// WithDevice binds the token to the device that logged in. deviceHash is a
+// fingerprint of the device attributes, passed to the DeviceRegistry on
+// verification.
+func WithDevice(deviceID, deviceHash string) ClaimsOption {
+	return func(c *Claims) {
+		c.DeviceID = deviceID
+		c.DeviceHash = deviceHash
+	}
+}
+
+// WithRoles sets the roles granted to the token holder. They are exposed to
+// handlers through mcontext.GetOpUserRoles.
+func WithRoles(roles ...string) ClaimsOption {
+	return func(c *Claims) {
+		c.Roles = roles
+	}
+}
+
+func BuildClaims(uid string, platformID int, ttl int64, opts ...ClaimsOption) Claims {
 	now := time.Now()
 	before := now.Add(-time.Second * time.Duration(secondBefore))
-	return Claims{
+	claims := Claims{
 		UserID:     uid,
 		PlatformID: platformID,
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -42,6 +68,10 @@ func BuildClaims(uid string, platformID int, ttl int64) Claims {
 			//NotBefore: jwt.NewNumericDate(before),                                              // Begin Effective time
 		},
 	}
+	for _, opt := range opts {
+		opt(&claims)
+	}
+	return claims
 }
 
 func GetClaimFromToken(tokensString string, secretFunc jwt.Keyfunc) (*Claims, error) {
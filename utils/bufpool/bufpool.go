@@ -0,0 +1,220 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bufpool provides size-classed pools of byte buffers for hot
+// serialization paths.
+package bufpool
+
+import (
+	"io"
+	"sync"
+
+	"github.com/openimsdk/tools/utils/statreg"
+)
+
+// Size classes served by the pool. Buffers whose capacity outgrows MaxPooledCap
+// are dropped on Put so one large message does not pin its memory forever.
+const (
+	class1K  = 1 << 10
+	class4K  = 4 << 10
+	class16K = 16 << 10
+	class64K = 64 << 10
+
+	MaxPooledCap = class64K
+)
+
+var classes = [...]int{class1K, class4K, class16K, class64K}
+
+var pools [len(classes)]sync.Pool
+
+// wrappers recycles the Buffer headers detached by GetBytes so that the
+// raw-slice helpers do not allocate on the steady-state path.
+var wrappers sync.Pool
+
+// The counters are also reported by statreg.Snapshot.
+var (
+	hits     = statreg.MustNewCounter("bufpool_hits_total")
+	misses   = statreg.MustNewCounter("bufpool_misses_total")
+	discards = statreg.MustNewCounter("bufpool_discards_total")
+)
+
+// Stats holds the pool counters since process start.
+type Stats struct {
+	Hits     uint64 // Get served from a pool
+	Misses   uint64 // Get had to allocate
+	Discards uint64 // Put dropped a buffer that was too large to keep
+}
+
+// GetStats returns the current counters.
+func GetStats() Stats {
+	return Stats{
+		Hits:     hits.Load(),
+		Misses:   misses.Load(),
+		Discards: discards.Load(),
+	}
+}
+
+// Buffer is a growable byte buffer obtained from Get.
+type Buffer struct {
+	buf []byte
+}
+
+// Write appends p to the buffer. It never returns an error.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// WriteString appends s to the buffer. It never returns an error.
+func (b *Buffer) WriteString(s string) (int, error) {
+	b.buf = append(b.buf, s...)
+	return len(s), nil
+}
+
+// WriteByte appends c to the buffer. It never returns an error.
+func (b *Buffer) WriteByte(c byte) error {
+	b.buf = append(b.buf, c)
+	return nil
+}
+
+// ReadFrom appends the data read from r until EOF, growing the buffer as
+// needed. It lets io.Copy fill the buffer without an intermediate one.
+func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for {
+		if len(b.buf) == cap(b.buf) {
+			b.buf = append(b.buf, 0)[:len(b.buf)]
+		}
+		n, err := r.Read(b.buf[len(b.buf):cap(b.buf)])
+		b.buf = b.buf[:len(b.buf)+n]
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Bytes returns the buffered data.
+//
+// WARNING: the returned slice aliases the buffer's memory. It is only valid
+// until the next write to the buffer or until the buffer is passed to Put.
+// Copy it if it must outlive the buffer, e.g. when handing it to an
+// asynchronous producer.
+func (b *Buffer) Bytes() []byte {
+	return b.buf
+}
+
+// Len returns the number of buffered bytes.
+func (b *Buffer) Len() int {
+	return len(b.buf)
+}
+
+// Cap returns the capacity of the underlying memory.
+func (b *Buffer) Cap() int {
+	return cap(b.buf)
+}
+
+// Reset empties the buffer, keeping its memory.
+func (b *Buffer) Reset() {
+	b.buf = b.buf[:0]
+}
+
+// classFor returns the index of the smallest class holding size bytes, or -1.
+func classFor(size int) int {
+	for i, c := range classes {
+		if size <= c {
+			return i
+		}
+	}
+	return -1
+}
+
+// classOf returns the index of the largest class not bigger than capacity, or
+// -1 when the capacity is below the smallest class.
+func classOf(capacity int) int {
+	for i := len(classes) - 1; i >= 0; i-- {
+		if capacity >= classes[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns an empty buffer with capacity for at least sizeHint bytes.
+// Hints above MaxPooledCap are served by a fresh allocation.
+func Get(sizeHint int) *Buffer {
+	idx := classFor(sizeHint)
+	if idx < 0 {
+		misses.Add(1)
+		return &Buffer{buf: make([]byte, 0, sizeHint)}
+	}
+	if v := pools[idx].Get(); v != nil {
+		hits.Add(1)
+		b := v.(*Buffer)
+		b.Reset()
+		return b
+	}
+	misses.Add(1)
+	return &Buffer{buf: make([]byte, 0, classes[idx])}
+}
+
+// Put returns b to the pool. b and any slice obtained from b.Bytes must not be
+// used afterwards.
+func Put(b *Buffer) {
+	if b == nil {
+		return
+	}
+	c := cap(b.buf)
+	if c > MaxPooledCap {
+		discards.Add(1)
+		return
+	}
+	idx := classOf(c)
+	if idx < 0 {
+		return
+	}
+	b.buf = b.buf[:0]
+	pools[idx].Put(b)
+}
+
+// GetBytes returns an empty slice with capacity for at least sizeHint bytes.
+// It follows the same rules as Get.
+func GetBytes(sizeHint int) []byte {
+	b := Get(sizeHint)
+	buf := b.buf
+	b.buf = nil
+	wrappers.Put(b)
+	return buf
+}
+
+// PutBytes returns a slice obtained from GetBytes to the pool. The slice must
+// not be used afterwards.
+func PutBytes(p []byte) {
+	if cap(p) > MaxPooledCap {
+		discards.Add(1)
+		return
+	}
+	if classOf(cap(p)) < 0 {
+		return
+	}
+	b, _ := wrappers.Get().(*Buffer)
+	if b == nil {
+		b = new(Buffer)
+	}
+	b.buf = p
+	Put(b)
+}
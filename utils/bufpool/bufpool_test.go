@@ -0,0 +1,162 @@
+package bufpool
+
+import (
+	"encoding/json"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var _ io.Writer = (*Buffer)(nil)
+
+func TestGetCapacity(t *testing.T) {
+	for _, tt := range []struct{ hint, want int }{
+		{0, class1K},
+		{1000, class1K},
+		{class1K + 1, class4K},
+		{10 << 10, class16K},
+		{class64K, class64K},
+		{class64K + 1, class64K + 1},
+	} {
+		b := Get(tt.hint)
+		if b.Len() != 0 || b.Cap() < tt.want {
+			t.Errorf("Get(%d): len=%d cap=%d, want len 0 cap>=%d", tt.hint, b.Len(), b.Cap(), tt.want)
+		}
+		Put(b)
+	}
+}
+
+func TestPutReuse(t *testing.T) {
+	b := Get(100)
+	_, _ = b.WriteString("hello")
+	Put(b)
+	// sync.Pool may drop items at any time, so only check what we do get.
+	got := Get(100)
+	if got.Len() != 0 {
+		t.Fatalf("reused buffer not reset: %q", got.Bytes())
+	}
+	Put(got)
+}
+
+func TestStats(t *testing.T) {
+	before := GetStats()
+	Put(Get(class64K + 1))
+	PutBytes(make([]byte, 0, 2*MaxPooledCap))
+	after := GetStats()
+	if d := after.Discards - before.Discards; d != 2 {
+		t.Errorf("discards delta = %d, want 2", d)
+	}
+	if after.Misses <= before.Misses {
+		t.Errorf("oversized Get not counted as miss")
+	}
+}
+
+func TestGetBytes(t *testing.T) {
+	p := GetBytes(3000)
+	if len(p) != 0 || cap(p) < 3000 {
+		t.Fatalf("len=%d cap=%d", len(p), cap(p))
+	}
+	PutBytes(append(p, "data"...))
+}
+
+func TestPutDoesNotRetainOversized(t *testing.T) {
+	b := Get(128 << 10)
+	_, _ = b.Write(make([]byte, 128<<10))
+	freed := make(chan struct{})
+	runtime.SetFinalizer(b, func(*Buffer) { close(freed) })
+	Put(b)
+	b = nil
+	waitFreed(t, "oversized buffer", freed)
+}
+
+// waitFreed runs the garbage collector until freed is closed. Pooled items
+// survive at most two collections, see sync.Pool.
+func waitFreed(t *testing.T, what string, freed <-chan struct{}) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case <-freed:
+			return
+		case <-deadline:
+			t.Fatalf("%s still referenced after the pool was cleared", what)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestPutDoesNotRetainPooled(t *testing.T) {
+	for _, c := range classes {
+		// Fresh values, as SetFinalizer cannot be called twice on one.
+		b := &Buffer{buf: make([]byte, 0, c)}
+		_, _ = b.Write(make([]byte, c))
+		freed := make(chan struct{})
+		runtime.SetFinalizer(b, func(*Buffer) { close(freed) })
+		Put(b)
+		b = nil
+		waitFreed(t, "buffer", freed)
+
+		p := append(make([]byte, 0, c), "data"...)
+		freed = make(chan struct{})
+		runtime.SetFinalizer(&p[0], func(*byte) { close(freed) })
+		PutBytes(p)
+		p = nil
+		waitFreed(t, "slice", freed)
+	}
+}
+
+type benchMsg struct {
+	SendID  string `json:"sendID"`
+	RecvID  string `json:"recvID"`
+	Content string `json:"content"`
+	Seq     int64  `json:"seq"`
+}
+
+var msg = benchMsg{SendID: "u1000", RecvID: "u2000", Content: string(make([]byte, 900)), Seq: 42}
+
+// The serialize benchmarks encode the same way, so that they only differ by
+// where the buffer comes from.
+
+func BenchmarkSerializeAlloc(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := make([]byte, 0, class4K)
+		data, _ := json.Marshal(msg)
+		buf = append(buf, data...)
+		_, _ = io.Discard.Write(buf)
+	}
+}
+
+func BenchmarkSerializePooled(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := Get(class4K)
+		data, _ := json.Marshal(msg)
+		_, _ = buf.Write(data)
+		_, _ = io.Discard.Write(buf.Bytes())
+		Put(buf)
+	}
+}
+
+func BenchmarkGetBytes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := GetBytes(class1K)
+		p = append(p, msg.Content...)
+		_, _ = io.Discard.Write(p)
+		PutBytes(p)
+	}
+}
+
+func TestReadFrom(t *testing.T) {
+	data := strings.Repeat("x", 3*class1K+7)
+	b := Get(0)
+	defer Put(b)
+	n, err := io.Copy(b, io.LimitReader(strings.NewReader(data), 2*class1K))
+	if err != nil || n != 2*class1K || string(b.Bytes()) != data[:2*class1K] {
+		t.Fatalf("copied %d bytes, err %v", n, err)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clockutiltest provides a fake clockutil.Clock for tests.
+package clockutiltest
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/utils/clockutil"
+)
+
+var _ clockutil.Clock = (*Fake)(nil)
+
+type timer struct {
+	clock *Fake
+	at    time.Time
+	ch    chan time.Time
+}
+
+func (t *timer) C() <-chan time.Time { return t.ch }
+
+func (t *timer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	for i, w := range t.clock.timers {
+		if w == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Fake is a clockutil.Clock for tests that only moves when advanced.
+type Fake struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*timer
+}
+
+// NewFake returns a fake clock reading now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (c *Fake) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *Fake) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *Fake) NewTimer(d time.Duration) clockutil.Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	t := &timer{clock: c, at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward and fires the timers that expired.
+func (c *Fake) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	kept := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			kept = append(kept, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.timers = kept
+}
+
+// FireNext moves the clock to the earliest pending timer, if it is not
+// already past it, and fires that timer. It reports false when no timer is
+// pending.
+func (c *Fake) FireNext() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.timers) == 0 {
+		return false
+	}
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+	t := c.timers[0]
+	c.timers = c.timers[1:]
+	if t.at.After(c.now) {
+		c.now = t.at
+	}
+	t.ch <- c.now
+	return true
+}
+
+// WaitBlocked waits until n timers are pending, failing t after 5 seconds.
+func (c *Fake) WaitBlocked(t testing.TB, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.lock.Lock()
+		got := len(c.timers)
+		c.lock.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d blocked timers", n)
+}
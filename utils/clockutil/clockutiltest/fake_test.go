@@ -0,0 +1,48 @@
+package clockutiltest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	soon, later := c.After(time.Second), c.After(time.Minute)
+	c.WaitBlocked(t, 2)
+	c.Advance(time.Second)
+	select {
+	case at := <-soon:
+		if !at.Equal(start.Add(time.Second)) {
+			t.Fatalf("fired at %s", at)
+		}
+	default:
+		t.Fatal("expired timer did not fire")
+	}
+	select {
+	case <-later:
+		t.Fatal("pending timer fired")
+	default:
+	}
+	if got := c.Now(); !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("Now = %s", got)
+	}
+}
+
+func TestFakeFireNext(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	stopped, later := c.NewTimer(time.Second), c.NewTimer(time.Minute)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Fatal("Stop should report true once")
+	}
+	if !c.FireNext() {
+		t.Fatal("FireNext found no timer")
+	}
+	if at := <-later.C(); !at.Equal(start.Add(time.Minute)) {
+		t.Fatalf("fired at %s", at)
+	}
+	if c.FireNext() {
+		t.Fatal("FireNext fired a stopped timer")
+	}
+}
@@ -0,0 +1,190 @@
+package contentutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGraphemeCount(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"你好", 2},
+		{"é", 1},      // e + combining acute
+		{"👍🏽", 1},      // skin tone modifier
+		{"👨‍👩‍👧‍👦", 1}, // family ZWJ sequence
+		{"🇨🇳🇺🇸", 2},    // two flags
+		{"🇨", 1},       // lone regional indicator
+		{"1️⃣", 1},     // keycap
+		{"❤️", 1},      // variation selector
+		{"🏴\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", 1}, // tag sequence
+		{"각", 1}, // Hangul jamo
+		{"a\r\nb", 3},
+		{"\xff\xfe", 2},
+	}
+	for _, tt := range tests {
+		if got := GraphemeCount(tt.s); got != tt.want {
+			t.Errorf("GraphemeCount(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTruncatePreview(t *testing.T) {
+	tests := []struct {
+		s        string
+		max      int
+		ellipsis string
+		want     string
+	}{
+		{"short", 10, "...", "short"},
+		{"exact", 5, "…", "exact"},
+		{"hello world", 8, "…", "hello w…"},
+		{"hello world", 7, "…", "hello…"}, // trailing space dropped
+		{"👨‍👩‍👧👍🏽🇨🇳🇺🇸", 3, "…", "👨‍👩‍👧👍🏽…"},
+		{"👍🏽👍🏽👍🏽", 2, "...", "👍🏽👍🏽"}, // ellipsis does not fit
+		{"anything", 0, "…", ""},
+	}
+	for _, tt := range tests {
+		if got := TruncatePreview(tt.s, tt.max, tt.ellipsis); got != tt.want {
+			t.Errorf("TruncatePreview(%q, %d, %q) = %q, want %q", tt.s, tt.max, tt.ellipsis, got, tt.want)
+		}
+		if got := GraphemeCount(TruncatePreview(tt.s, tt.max, tt.ellipsis)); got > tt.max {
+			t.Errorf("TruncatePreview(%q, %d) has %d graphemes", tt.s, tt.max, got)
+		}
+	}
+}
+
+func TestEstimateJSONSize(t *testing.T) {
+	values := []any{
+		nil, true, false, "", "plain", "quote\" back\\ <tag> & \n\t\x01   \xff 😀",
+		0, -12, int8(-128), uint64(1 << 63), 3.5, 1e21, 1e-7, 0.000001, float32(1e-7), float32(3.14), 123456789.0,
+		json.Number("12.50"), []byte("binary\x00data"), []byte(nil), []string{"a", "b"}, []string{},
+		[]any{1, "x", nil, []any{}}, map[string]string{"k": "v", "<": ">"},
+		map[string]any{"text": "hi", "nested": map[string]any{"list": []any{1.5, false}}, "empty": map[string]any{}},
+		struct {
+			A string `json:"a"`
+			B []int  `json:"b,omitempty"`
+		}{A: "x"},
+		map[string]any{"raw": json.RawMessage(`{ "a" : 1 }`)},
+	}
+	for _, v := range values {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := EstimateJSONSize(v); got != len(data) {
+			t.Errorf("EstimateJSONSize(%#v) = %d, want %d (%s)", v, got, len(data), data)
+		}
+	}
+	if got := EstimateJSONSize(make(chan int)); got != 0 {
+		t.Errorf("unmarshalable value size = %d", got)
+	}
+}
+
+func TestEstimateJSONSizeAllocs(t *testing.T) {
+	v := map[string]any{"text": "hello", "seq": int64(42), "at": []any{1.5, true}}
+	if n := testing.AllocsPerRun(100, func() { EstimateJSONSize(v) }); n != 0 {
+		t.Errorf("EstimateJSONSize allocates %v times", n)
+	}
+}
+
+func TestTruncateJSONString(t *testing.T) {
+	t.Run("already small", func(t *testing.T) {
+		raw := []byte(`{ "a": "b" }`)
+		got, err := TruncateJSONString(raw, len(raw))
+		if err != nil || string(got) != string(raw) {
+			t.Fatalf("got %s, %v; want input unchanged", got, err)
+		}
+	})
+
+	t.Run("compaction is enough", func(t *testing.T) {
+		raw := []byte("{\n  \"a\": \"b\",\n  \"n\": [1, 2]\n}")
+		got, err := TruncateJSONString(raw, 20)
+		if err != nil || string(got) != `{"a":"b","n":[1,2]}` {
+			t.Fatalf("got %s, %v", got, err)
+		}
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		raw := []byte(`{"event":"msg","seq":12345678901234567890,"ok":true,"none":null,` +
+			`"data":{"text":"` + strings.Repeat("long text ", 30) + `","tags":["` + strings.Repeat("t", 40) + `","x"],` +
+			`"empty":{},"list":[],"summary":"` + strings.Repeat("s", 60) + `"}}`)
+		for _, limit := range []int{300, 200, 160, 150} {
+			got, err := TruncateJSONString(raw, limit)
+			if err != nil {
+				t.Fatalf("limit %d: %v", limit, err)
+			}
+			if len(got) > limit || !json.Valid(got) {
+				t.Fatalf("limit %d: %d bytes, valid %t: %s", limit, len(got), json.Valid(got), got)
+			}
+			var out map[string]any
+			dec := json.NewDecoder(strings.NewReader(string(got)))
+			dec.UseNumber()
+			if err := dec.Decode(&out); err != nil {
+				t.Fatal(err)
+			}
+			if out["seq"] != json.Number("12345678901234567890") || out["event"] != "msg" || out["ok"] != true {
+				t.Errorf("limit %d: short fields changed: %s", limit, got)
+			}
+			data := out["data"].(map[string]any)
+			if tags := data["tags"].([]any); tags[1] != "x" {
+				t.Errorf("limit %d: short string in array changed: %s", limit, got)
+			}
+			if !strings.HasPrefix(strings.Repeat("long text ", 30), data["text"].(string)) {
+				t.Errorf("limit %d: text is not a prefix: %s", limit, got)
+			}
+			if !reflect.DeepEqual(data["empty"], map[string]any{}) || !reflect.DeepEqual(data["list"], []any{}) {
+				t.Errorf("limit %d: structure changed: %s", limit, got)
+			}
+		}
+	})
+
+	t.Run("longest first", func(t *testing.T) {
+		raw := []byte(`{"a":"` + strings.Repeat("a", 50) + `","b":"` + strings.Repeat("b", 10) + `"}`)
+		got, err := TruncateJSONString(raw, len(raw)-20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out map[string]string
+		if err := json.Unmarshal(got, &out); err != nil {
+			t.Fatal(err)
+		}
+		if len(out["a"]) != 30 || len(out["b"]) != 10 {
+			t.Errorf("a = %d bytes, b = %d bytes, want 30 and 10", len(out["a"]), len(out["b"]))
+		}
+	})
+
+	t.Run("emoji", func(t *testing.T) {
+		family := "👨‍👩‍👧‍👦"
+		text := strings.Repeat(family+"👍🏽🇨🇳", 20)
+		raw, _ := json.Marshal(map[string]string{"text": text, "escaped": strings.Repeat("\"\n", 20)})
+		got, err := TruncateJSONString(raw, 150)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out map[string]string
+		if err := json.Unmarshal(got, &out); err != nil || len(got) > 150 {
+			t.Fatalf("%d bytes, %v: %s", len(got), err, got)
+		}
+		if out["text"] == "" || TruncateGraphemes(text, GraphemeCount(out["text"])) != out["text"] {
+			t.Errorf("text cut inside a grapheme cluster: %q", out["text"])
+		}
+		if !strings.HasPrefix(strings.Repeat("\"\n", 20), out["escaped"]) {
+			t.Errorf("escaped = %q", out["escaped"])
+		}
+	})
+
+	t.Run("does not fit", func(t *testing.T) {
+		if _, err := TruncateJSONString([]byte(`{"key":"`+strings.Repeat("v", 100)+`","other":1}`), 10); err == nil {
+			t.Error("no error for a document that cannot fit")
+		}
+		if _, err := TruncateJSONString([]byte(`{"a":`), 100); err == nil {
+			t.Error("no error for invalid JSON")
+		}
+	})
+}
@@ -0,0 +1,183 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package contentutil estimates and bounds the size of message content for
+// push notifications, conversation previews and webhook payloads, so that
+// every service truncates the same way and never splits an emoji or breaks a
+// JSON document.
+package contentutil
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const zwj = '\u200d'
+
+// NextGrapheme returns the first user-perceived character of s and its length
+// in bytes. It follows the extended grapheme cluster rules of UAX #29 for the
+// cases found in chat content: combining marks, variation selectors, emoji
+// modifiers, ZWJ sequences, emoji tag sequences, keycaps, regional indicator
+// flags, Hangul jamo and CRLF. Other scripts fall back to one rune per
+// cluster, a cut between them is always valid UTF-8.
+func NextGrapheme(s string) (cluster string, size int) {
+	if s == "" {
+		return "", 0
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	if r == '\r' && n < len(s) && s[n] == '\n' {
+		return s[:2], 2
+	}
+	if r < 0x20 || r == 0x7f || r == utf8.RuneError && n == 1 {
+		return s[:n], n
+	}
+	size = n
+	prev := r
+	if isRegionalIndicator(r) {
+		if r2, n2 := utf8.DecodeRuneInString(s[size:]); isRegionalIndicator(r2) {
+			size += n2
+			prev = r2
+		}
+	}
+	for size < len(s) {
+		r, n := utf8.DecodeRuneInString(s[size:])
+		switch {
+		case isExtend(r):
+		case prev == zwj && isPictographic(r):
+		case jamoFollows(prev, r):
+		default:
+			return s[:size], size
+		}
+		size += n
+		prev = r
+	}
+	return s, size
+}
+
+// GraphemeCount returns the number of user-perceived characters in s.
+func GraphemeCount(s string) int {
+	count := 0
+	for s != "" {
+		_, n := NextGrapheme(s)
+		s = s[n:]
+		count++
+	}
+	return count
+}
+
+// TruncateGraphemes returns the longest prefix of s holding at most max
+// grapheme clusters.
+func TruncateGraphemes(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	end := 0
+	for i := 0; i < max && end < len(s); i++ {
+		_, n := NextGrapheme(s[end:])
+		end += n
+	}
+	return s[:end]
+}
+
+// TruncatePreview shortens s to at most maxGraphemes user-perceived
+// characters, ellipsis included, for notification texts and conversation
+// previews. s is returned unchanged when it fits. Otherwise trailing white
+// space of the kept prefix is dropped and ellipsis appended; when ellipsis
+// alone does not fit in maxGraphemes it is left out. The cut never splits a
+// grapheme cluster.
+func TruncatePreview(s string, maxGraphemes int, ellipsis string) string {
+	if maxGraphemes <= 0 {
+		return ""
+	}
+	end, count := 0, 0
+	for end < len(s) && count <= maxGraphemes {
+		_, n := NextGrapheme(s[end:])
+		end += n
+		count++
+	}
+	if count <= maxGraphemes {
+		return s
+	}
+	keep := maxGraphemes - GraphemeCount(ellipsis)
+	if keep <= 0 {
+		return TruncateGraphemes(s, maxGraphemes)
+	}
+	return strings.TrimRightFunc(TruncateGraphemes(s, keep), unicode.IsSpace) + ellipsis
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// isExtend reports whether r attaches to the preceding character.
+func isExtend(r rune) bool {
+	switch {
+	case r == zwj,
+		r >= 0xfe00 && r <= 0xfe0f,   // variation selectors
+		r >= 0xe0100 && r <= 0xe01ef, // variation selectors supplement
+		r >= 0x1f3fb && r <= 0x1f3ff, // emoji skin tone modifiers
+		r >= 0xe0020 && r <= 0xe007f, // emoji tag sequences
+		r == 0x20e3:                  // combining enclosing keycap
+		return true
+	}
+	return r >= 0x300 && unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc)
+}
+
+// isPictographic approximates Extended_Pictographic with the emoji blocks.
+func isPictographic(r rune) bool {
+	switch {
+	case r == 0xa9, r == 0xae,
+		r >= 0x2000 && r <= 0x2bff,
+		r >= 0x3030 && r <= 0x303d,
+		r >= 0x3297 && r <= 0x3299,
+		r >= 0x1f000 && r <= 0x1faff:
+		return true
+	}
+	return false
+}
+
+// jamoFollows reports whether the Hangul jamo r continues the syllable ended
+// by prev.
+func jamoFollows(prev, r rune) bool {
+	switch {
+	case isJamoL(prev):
+		return isJamoL(r) || isJamoV(r) || isHangulSyllable(r)
+	case isJamoV(prev) || isHangulLV(prev):
+		return isJamoV(r) || isJamoT(r)
+	case isJamoT(prev) || isHangulSyllable(prev):
+		return isJamoT(r)
+	}
+	return false
+}
+
+func isJamoL(r rune) bool {
+	return r >= 0x1100 && r <= 0x115f || r >= 0xa960 && r <= 0xa97c
+}
+
+func isJamoV(r rune) bool {
+	return r >= 0x1160 && r <= 0x11a7 || r >= 0xd7b0 && r <= 0xd7c6
+}
+
+func isJamoT(r rune) bool {
+	return r >= 0x11a8 && r <= 0x11ff || r >= 0xd7cb && r <= 0xd7fb
+}
+
+func isHangulSyllable(r rune) bool {
+	return r >= 0xac00 && r <= 0xd7a3
+}
+
+func isHangulLV(r rune) bool {
+	return isHangulSyllable(r) && (r-0xac00)%28 == 0
+}
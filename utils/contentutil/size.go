@@ -0,0 +1,215 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contentutil
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// EstimateJSONSize returns the number of bytes json.Marshal produces for v.
+// Strings, numbers, booleans, nil, []byte, json.RawMessage, json.Number and
+// slices and maps of those are measured without encoding anything, the result
+// is exact for them. Other values, structs in particular, are marshaled; 0 is
+// returned when that fails.
+func EstimateJSONSize(v any) int {
+	if n, ok := fastSize(v); ok {
+		return n
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+func fastSize(v any) (int, bool) {
+	switch v := v.(type) {
+	case nil:
+		return 4, true
+	case string:
+		return stringSize(v, true), true
+	case bool:
+		if v {
+			return 4, true
+		}
+		return 5, true
+	case int:
+		return intSize(int64(v)), true
+	case int8:
+		return intSize(int64(v)), true
+	case int16:
+		return intSize(int64(v)), true
+	case int32:
+		return intSize(int64(v)), true
+	case int64:
+		return intSize(v), true
+	case uint:
+		return uintSize(uint64(v)), true
+	case uint8:
+		return uintSize(uint64(v)), true
+	case uint16:
+		return uintSize(uint64(v)), true
+	case uint32:
+		return uintSize(uint64(v)), true
+	case uint64:
+		return uintSize(v), true
+	case float32:
+		return floatSize(float64(v), 32)
+	case float64:
+		return floatSize(v, 64)
+	case json.Number:
+		if v == "" {
+			return 1, true
+		}
+		return len(v), true
+	case json.RawMessage:
+		if v == nil {
+			return 4, true
+		}
+		return 0, false // marshaled compacted
+	case []byte:
+		if v == nil {
+			return 4, true
+		}
+		return base64.StdEncoding.EncodedLen(len(v)) + 2, true
+	case []string:
+		if v == nil {
+			return 4, true
+		}
+		n := 2 + commas(len(v))
+		for _, s := range v {
+			n += stringSize(s, true)
+		}
+		return n, true
+	case []any:
+		if v == nil {
+			return 4, true
+		}
+		n := 2 + commas(len(v))
+		for _, e := range v {
+			size, ok := fastSize(e)
+			if !ok {
+				return 0, false
+			}
+			n += size
+		}
+		return n, true
+	case map[string]string:
+		if v == nil {
+			return 4, true
+		}
+		n := 2 + commas(len(v))
+		for k, s := range v {
+			n += stringSize(k, true) + 1 + stringSize(s, true)
+		}
+		return n, true
+	case map[string]any:
+		if v == nil {
+			return 4, true
+		}
+		n := 2 + commas(len(v))
+		for k, e := range v {
+			size, ok := fastSize(e)
+			if !ok {
+				return 0, false
+			}
+			n += stringSize(k, true) + 1 + size
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+func commas(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return n - 1
+}
+
+func intSize(v int64) int {
+	var buf [20]byte
+	return len(strconv.AppendInt(buf[:0], v, 10))
+}
+
+func uintSize(v uint64) int {
+	var buf [20]byte
+	return len(strconv.AppendUint(buf[:0], v, 10))
+}
+
+// floatSize mirrors the float formatting of encoding/json.
+func floatSize(f float64, bits int) (int, bool) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, false
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	var buf [32]byte
+	b := strconv.AppendFloat(buf[:0], f, format, -1, bits)
+	n := len(b)
+	if format == 'e' && n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+		n-- // e-09 is written as e-9
+	}
+	return n, true
+}
+
+// stringSize returns the encoded size of s including the quotes. escapeHTML
+// selects the escaping of json.Marshal, otherwise that of an Encoder with
+// SetEscapeHTML(false).
+func stringSize(s string, escapeHTML bool) int {
+	n := 2
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			n += asciiSize(b, escapeHTML)
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			n += 3 // replaced by U+FFFD
+		case r == '\u2028', r == '\u2029':
+			n += 6
+		default:
+			n += size
+		}
+		i += size
+	}
+	return n
+}
+
+func asciiSize(b byte, escapeHTML bool) int {
+	switch b {
+	case '"', '\\', '\b', '\f', '\n', '\r', '\t':
+		return 2
+	case '<', '>', '&':
+		if escapeHTML {
+			return 6
+		}
+		return 1
+	}
+	if b < 0x20 {
+		return 6
+	}
+	return 1
+}
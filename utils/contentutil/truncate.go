@@ -0,0 +1,287 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contentutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+type tokenKind uint8
+
+const (
+	tokenDelim tokenKind = iota
+	tokenKey
+	tokenString
+	tokenLiteral
+)
+
+type jsonToken struct {
+	kind tokenKind
+	text string // delimiter, literal or decoded string
+}
+
+// TruncateJSONString shrinks the JSON document raw to at most maxBytes by
+// shortening its longest string values, for webhook bodies with hard size
+// limits. raw is returned unchanged when it already fits. Otherwise the
+// document is compacted and the longest string values are cut to a common
+// length, as little as needed, and the result guarantees:
+//   - it is valid JSON with the same structure, keys, numbers, booleans and
+//     nulls as raw, in the same order;
+//   - only string values are shortened, keeping a prefix of each, and cuts
+//     never split a grapheme cluster or an escape sequence;
+//   - shorter strings are only touched once every longer one is cut down to
+//     their length.
+//
+// An error is returned when raw is not valid JSON or when it does not fit even
+// with every string value emptied.
+func TruncateJSONString(raw []byte, maxBytes int) ([]byte, error) {
+	if !json.Valid(raw) {
+		return nil, errs.ErrArgs.WrapMsg("invalid JSON document")
+	}
+	if len(raw) <= maxBytes {
+		return raw, nil
+	}
+	tokens, err := tokenize(raw)
+	if err != nil {
+		return nil, err
+	}
+	total, fixed := 0, 0
+	var values []int
+	for i, t := range tokens {
+		size := tokenSize(t)
+		total += size
+		if t.kind == tokenString {
+			values = append(values, i)
+			fixed += 2
+		} else {
+			fixed += size
+		}
+	}
+	total += separators(tokens)
+	fixed += separators(tokens)
+	if total > maxBytes {
+		if fixed > maxBytes {
+			return nil, errs.ErrArgs.WrapMsg("JSON document does not fit", "size", total, "minSize", fixed, "maxBytes", maxBytes)
+		}
+		shrink(tokens, values, total-maxBytes)
+	}
+	return encode(tokens), nil
+}
+
+func tokenize(raw []byte) ([]jsonToken, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var (
+		tokens []jsonToken
+		// objects tracks, for each open container, whether it is an object
+		// and whether its next string is a key.
+		objects []bool
+		keyNext []bool
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return nil, errs.WrapMsg(err, "tokenize JSON document")
+		}
+		depth := len(objects) - 1
+		inObject := depth >= 0 && objects[depth]
+		switch v := tok.(type) {
+		case json.Delim:
+			tokens = append(tokens, jsonToken{kind: tokenDelim, text: v.String()})
+			switch v {
+			case '{', '[':
+				if inObject {
+					keyNext[depth] = true
+				}
+				objects = append(objects, v == '{')
+				keyNext = append(keyNext, v == '{')
+			default:
+				objects, keyNext = objects[:depth], keyNext[:depth]
+			}
+			continue
+		case string:
+			if inObject && keyNext[depth] {
+				tokens = append(tokens, jsonToken{kind: tokenKey, text: v})
+				keyNext[depth] = false
+				continue
+			}
+			tokens = append(tokens, jsonToken{kind: tokenString, text: v})
+		case json.Number:
+			tokens = append(tokens, jsonToken{kind: tokenLiteral, text: v.String()})
+		case bool:
+			tokens = append(tokens, jsonToken{kind: tokenLiteral, text: strconv.FormatBool(v)})
+		case nil:
+			tokens = append(tokens, jsonToken{kind: tokenLiteral, text: "null"})
+		}
+		if inObject {
+			keyNext[depth] = true
+		}
+	}
+}
+
+func tokenSize(t jsonToken) int {
+	if t.kind == tokenKey || t.kind == tokenString {
+		return stringSize(t.text, false)
+	}
+	return len(t.text)
+}
+
+// separators counts the commas and colons of the compact encoding.
+func separators(tokens []jsonToken) int {
+	n := 0
+	for i, t := range tokens {
+		if t.kind == tokenKey {
+			n++ // colon
+		}
+		if i > 0 && needsComma(tokens[i-1], t) {
+			n++
+		}
+	}
+	return n
+}
+
+func needsComma(prev, t jsonToken) bool {
+	if prev.kind == tokenKey || t.kind == tokenDelim && (t.text == "}" || t.text == "]") {
+		return false
+	}
+	return !(prev.kind == tokenDelim && (prev.text == "{" || prev.text == "["))
+}
+
+// shrink cuts the longest string values to a common encoded length so that
+// the document loses at least excess bytes.
+func shrink(tokens []jsonToken, values []int, excess int) {
+	sizes := make([]int, len(values))
+	for i, idx := range values {
+		sizes[i] = stringSize(tokens[idx].text, false) - 2
+	}
+	// Find the largest limit that removes enough bytes.
+	sorted := append([]int(nil), sizes...)
+	sort.Ints(sorted)
+	limit := sort.Search(sorted[len(sorted)-1]+1, func(limit int) bool {
+		return saved(sorted, limit) < excess
+	}) - 1
+	for {
+		removed := 0
+		for i, idx := range values {
+			if sizes[i] > limit {
+				tokens[idx].text = cutString(tokens[idx].text, limit)
+				removed += sizes[i] - (stringSize(tokens[idx].text, false) - 2)
+				sizes[i] = stringSize(tokens[idx].text, false) - 2
+			}
+		}
+		// Cuts on cluster boundaries remove at least the planned bytes, the
+		// loop only repeats if rounding left the document too large.
+		if removed >= excess || limit == 0 {
+			return
+		}
+		excess -= removed
+		limit--
+	}
+}
+
+func saved(sorted []int, limit int) int {
+	n := 0
+	for i := len(sorted) - 1; i >= 0 && sorted[i] > limit; i-- {
+		n += sorted[i] - limit
+	}
+	return n
+}
+
+// cutString returns the longest prefix of s, on a grapheme cluster boundary,
+// whose encoding without quotes has at most limit bytes.
+func cutString(s string, limit int) string {
+	end, size := 0, 0
+	for end < len(s) {
+		cluster, n := NextGrapheme(s[end:])
+		if size += stringSize(cluster, false) - 2; size > limit {
+			break
+		}
+		end += n
+	}
+	return s[:end]
+}
+
+func encode(tokens []jsonToken) []byte {
+	var buf []byte
+	for i, t := range tokens {
+		if i > 0 && needsComma(tokens[i-1], t) {
+			buf = append(buf, ',')
+		}
+		switch t.kind {
+		case tokenKey:
+			buf = append(appendString(buf, t.text), ':')
+		case tokenString:
+			buf = appendString(buf, t.text)
+		default:
+			buf = append(buf, t.text...)
+		}
+	}
+	return buf
+}
+
+const hex = "0123456789abcdef"
+
+// appendString encodes s like an Encoder with SetEscapeHTML(false).
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			switch b {
+			case '"', '\\':
+				buf = append(buf, '\\', b)
+			case '\b':
+				buf = append(buf, '\\', 'b')
+			case '\f':
+				buf = append(buf, '\\', 'f')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				if b < 0x20 {
+					buf = append(buf, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xf])
+				} else {
+					buf = append(buf, b)
+				}
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf = utf8.AppendRune(buf, utf8.RuneError)
+		case r == '\u2028', r == '\u2029':
+			buf = append(buf, '\\', 'u', '2', '0', '2', hex[r&0xf])
+		default:
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(buf, '"')
+}
@@ -19,7 +19,10 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/md5"
+	"crypto/rand"
 	"encoding/hex"
+	"io"
+
 	"github.com/openimsdk/tools/errs"
 )
 
@@ -66,6 +69,50 @@ func AesDecrypt(data []byte, key []byte) ([]byte, error) {
 	return crypted, nil
 }
 
+// AesGcmEncrypt encrypts and authenticates data with AES-GCM. The random nonce
+// is prepended to the returned ciphertext.
+func AesGcmEncrypt(data []byte, key []byte) ([]byte, error) {
+	gcm, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(data)+gcm.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errs.WrapMsg(err, "read nonce failed")
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// AesGcmDecrypt decrypts data produced by AesGcmEncrypt. It fails if the data
+// was modified or encrypted with another key.
+func AesGcmDecrypt(data []byte, key []byte) ([]byte, error) {
+	gcm, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, errs.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "gcm open failed")
+	}
+	return plain, nil
+}
+
+func newGcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "NewCipher failed", "keyLen", len(key))
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "NewGCM failed")
+	}
+	return gcm, nil
+}
+
 // pkcs7Padding PKCS7 padding
 func pkcs7Padding(data []byte, blockSize int) []byte {
 	padding := blockSize - len(data)%blockSize
@@ -54,3 +54,36 @@ func TestAesEncryptionDecryption(t *testing.T) {
 		t.Errorf("AesDecrypt = %v, want %v", string(decrypted), originalText)
 	}
 }
+
+func TestAesGcmEncryptionDecryption(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	encrypted, err := AesGcmEncrypt([]byte("Hello, World!"), key)
+	if err != nil {
+		t.Fatalf("AesGcmEncrypt error: %v", err)
+	}
+	again, err := AesGcmEncrypt([]byte("Hello, World!"), key)
+	if err != nil {
+		t.Fatalf("AesGcmEncrypt error: %v", err)
+	}
+	if string(encrypted) == string(again) {
+		t.Errorf("AesGcmEncrypt reused a nonce")
+	}
+	decrypted, err := AesGcmDecrypt(encrypted, key)
+	if err != nil {
+		t.Fatalf("AesGcmDecrypt error: %v", err)
+	}
+	if string(decrypted) != "Hello, World!" {
+		t.Errorf("AesGcmDecrypt = %v, want %v", string(decrypted), "Hello, World!")
+	}
+
+	encrypted[len(encrypted)-1] ^= 1
+	if _, err := AesGcmDecrypt(encrypted, key); err == nil {
+		t.Errorf("AesGcmDecrypt accepted tampered data")
+	}
+	if _, err := AesGcmDecrypt(again, []byte("fedcba9876543210fedcba9876543210")); err == nil {
+		t.Errorf("AesGcmDecrypt accepted the wrong key")
+	}
+	if _, err := AesGcmDecrypt([]byte("short"), key); err == nil {
+		t.Errorf("AesGcmDecrypt accepted truncated data")
+	}
+}
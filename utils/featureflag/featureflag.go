@@ -0,0 +1,228 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package featureflag evaluates process-level feature flags for gradual
+// rollouts, targeting the operating user and platform carried by the context.
+package featureflag
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openimsdk/tools/config"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
+	"gopkg.in/yaml.v2"
+)
+
+// unknownWarnInterval throttles the warning logged for an unknown flag name.
+const unknownWarnInterval = time.Minute
+
+// Flag is the definition of a single flag. Rules are applied in order: a
+// disabled flag is off for everyone, then the denylist, the allowlist, the
+// platform targeting and finally the percentage rollout.
+type Flag struct {
+	Name    string `yaml:"name" json:"name"`
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	// Percentage of users, in [0, 100], the flag is enabled for. The same
+	// user always lands in the same bucket of a given flag.
+	Percentage float64  `yaml:"percentage" json:"percentage"`
+	Allow      []string `yaml:"allow" json:"allow"`
+	Deny       []string `yaml:"deny" json:"deny"`
+	// Platforms restricts the flag to the listed platform names, matched case
+	// insensitively against mcontext's opUserPlatform. Empty means all.
+	Platforms []string `yaml:"platforms" json:"platforms"`
+}
+
+// Config is the document parsed by LoadFlags.
+type Config struct {
+	Flags []Flag `yaml:"flags" json:"flags"`
+}
+
+type flag struct {
+	enabled   bool
+	threshold uint64
+	allow     map[string]struct{}
+	deny      map[string]struct{}
+	platforms map[string]struct{}
+}
+
+// Set is an immutable snapshot of loaded flags.
+type Set struct {
+	flags map[string]*flag
+}
+
+var (
+	current    atomic.Pointer[Set]
+	unknownMu  sync.Mutex
+	unknownLog = make(map[string]time.Time)
+)
+
+func init() {
+	current.Store(&Set{})
+}
+
+// buckets is the resolution of the percentage rollout: 0.01%.
+const buckets = 10000
+
+// NewSet validates the definitions and builds a snapshot from them.
+func NewSet(flags []Flag) (*Set, error) {
+	s := &Set{flags: make(map[string]*flag, len(flags))}
+	for _, f := range flags {
+		if f.Name == "" {
+			return nil, errs.ErrArgs.WrapMsg("feature flag without a name")
+		}
+		if _, ok := s.flags[f.Name]; ok {
+			return nil, errs.ErrArgs.WrapMsg("duplicate feature flag", "name", f.Name)
+		}
+		if f.Percentage < 0 || f.Percentage > 100 {
+			return nil, errs.ErrArgs.WrapMsg("feature flag percentage out of [0, 100]", "name", f.Name, "percentage", f.Percentage)
+		}
+		s.flags[f.Name] = &flag{
+			enabled:   f.Enabled,
+			threshold: uint64(f.Percentage * buckets / 100),
+			allow:     toSet(f.Allow, false),
+			deny:      toSet(f.Deny, false),
+			platforms: toSet(f.Platforms, true),
+		}
+	}
+	return s, nil
+}
+
+func toSet(values []string, fold bool) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+	m := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		if fold {
+			v = strings.ToLower(v)
+		}
+		m[v] = struct{}{}
+	}
+	return m
+}
+
+// LoadFlags parses a YAML (or JSON) Config document and atomically replaces
+// the flags used by Eval. On error the previous flags are kept.
+func LoadFlags(data []byte) error {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return errs.WrapMsg(err, "parse feature flags failed")
+	}
+	return Replace(cfg.Flags)
+}
+
+// LoadFile reads and loads the flags stored in a file, see LoadFlags.
+func LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errs.WrapMsg(err, "read feature flags failed", "path", path)
+	}
+	return LoadFlags(data)
+}
+
+// Replace atomically replaces the flags used by Eval.
+func Replace(flags []Flag) error {
+	s, err := NewSet(flags)
+	if err != nil {
+		return err
+	}
+	current.Store(s)
+	return nil
+}
+
+// Watch reloads the flags each time source changes, see config.Watch.
+// Invalid content is logged and the previous flags are kept.
+func Watch(ctx context.Context, source config.ConfigSource, interval time.Duration) error {
+	return config.Watch(ctx, source, interval, LoadFlags)
+}
+
+// Current returns the snapshot used by Eval. Evaluating several flags on the
+// same snapshot gives results consistent with a single load even when a
+// reload happens concurrently.
+func Current() *Set {
+	return current.Load()
+}
+
+// Eval reports whether flagName is enabled for the operating user and
+// platform of ctx, using the current flags.
+func Eval(ctx context.Context, flagName string) bool {
+	return Current().Eval(ctx, flagName)
+}
+
+// Eval reports whether flagName is enabled for the operating user and
+// platform of ctx. An override set by OverrideHandler takes precedence over
+// every rule. Unknown flags are off.
+func (s *Set) Eval(ctx context.Context, flagName string) bool {
+	if on, ok := overrideFrom(ctx, flagName); ok {
+		return on
+	}
+	f, ok := s.flags[flagName]
+	if !ok {
+		warnUnknown(ctx, flagName)
+		return false
+	}
+	if !f.enabled {
+		return false
+	}
+	userID := mcontext.GetOpUserID(ctx)
+	if _, ok := f.deny[userID]; ok {
+		return false
+	}
+	if _, ok := f.allow[userID]; ok {
+		return true
+	}
+	if f.platforms != nil {
+		if _, ok := f.platforms[strings.ToLower(mcontext.GetOpUserPlatform(ctx))]; !ok {
+			return false
+		}
+	}
+	if f.threshold >= buckets {
+		return true
+	}
+	if f.threshold == 0 || userID == "" {
+		return false
+	}
+	return bucket(flagName, userID) < f.threshold
+}
+
+// bucket maps a user to a stable bucket in [0, buckets). The flag name is part
+// of the key so that the users of one rollout are not those of every other.
+func bucket(flagName, userID string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(flagName))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(userID))
+	return h.Sum64() % buckets
+}
+
+func warnUnknown(ctx context.Context, flagName string) {
+	now := time.Now()
+	unknownMu.Lock()
+	last, seen := unknownLog[flagName]
+	if seen && now.Sub(last) < unknownWarnInterval {
+		unknownMu.Unlock()
+		return
+	}
+	unknownLog[flagName] = now
+	unknownMu.Unlock()
+	log.ZWarn(ctx, "unknown feature flag evaluated as off", nil, "flag", flagName)
+}
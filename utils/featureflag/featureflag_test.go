@@ -0,0 +1,204 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/config"
+	"github.com/openimsdk/tools/mcontext"
+)
+
+func userCtx(userID, platform string) context.Context {
+	ctx := mcontext.SetOpUserID(context.Background(), userID)
+	return mcontext.WithOpUserPlatformContext(ctx, platform)
+}
+
+func mustSet(t *testing.T, flags ...Flag) *Set {
+	t.Helper()
+	s, err := NewSet(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPrecedence(t *testing.T) {
+	s := mustSet(t,
+		Flag{Name: "index", Enabled: true, Percentage: 100, Allow: []string{"both"}, Deny: []string{"denied", "both"}},
+		Flag{Name: "push", Enabled: true, Percentage: 0, Allow: []string{"tester"}},
+		Flag{Name: "off", Enabled: false, Percentage: 100, Allow: []string{"tester"}},
+		Flag{Name: "ios", Enabled: true, Percentage: 100, Allow: []string{"tester"}, Platforms: []string{"IOS"}},
+	)
+	cases := []struct {
+		flag, user, platform string
+		want                 bool
+	}{
+		{"index", "denied", "IOS", false}, // denylist beats percentage
+		{"index", "both", "IOS", false},   // denylist beats allowlist
+		{"index", "anyone", "IOS", true},
+		{"push", "tester", "IOS", true}, // allowlist beats percentage
+		{"push", "anyone", "IOS", false},
+		{"off", "tester", "IOS", false},
+		{"ios", "anyone", "ios", true},
+		{"ios", "anyone", "Android", false},
+		{"ios", "tester", "Android", true}, // allowlist beats platform
+		{"missing", "tester", "IOS", false},
+	}
+	for _, c := range cases {
+		if got := s.Eval(userCtx(c.user, c.platform), c.flag); got != c.want {
+			t.Errorf("%s for %s on %s = %v, want %v", c.flag, c.user, c.platform, got, c.want)
+		}
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	s := mustSet(t, Flag{Name: "rollout", Enabled: true, Percentage: 25}, Flag{Name: "other", Enabled: true, Percentage: 25})
+	const n = 20000
+	var on, both int
+	for i := 0; i < n; i++ {
+		ctx := userCtx(fmt.Sprintf("user-%d", i), "")
+		a := s.Eval(ctx, "rollout")
+		if a != s.Eval(ctx, "rollout") {
+			t.Fatal("evaluation is not stable")
+		}
+		if a {
+			on++
+			if s.Eval(ctx, "other") {
+				both++
+			}
+		}
+	}
+	if on < n*23/100 || on > n*27/100 {
+		t.Errorf("%d of %d users enabled, want about 25%%", on, n)
+	}
+	// Independent buckets per flag: about a quarter of the enabled users.
+	if both < on*20/100 || both > on*30/100 {
+		t.Errorf("%d of %d users share both rollouts", both, on)
+	}
+	if s.Eval(userCtx("", ""), "rollout") {
+		t.Error("anonymous context enabled by a partial rollout")
+	}
+}
+
+func TestNewSetErrors(t *testing.T) {
+	for _, flags := range [][]Flag{
+		{{Name: ""}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Percentage: 101}},
+	} {
+		if _, err := NewSet(flags); err == nil {
+			t.Errorf("NewSet(%v) succeeded", flags)
+		}
+	}
+}
+
+func TestReloadConsistency(t *testing.T) {
+	on := []byte("flags:\n- {name: a, enabled: true, percentage: 100}\n- {name: b, enabled: true, percentage: 100}\n")
+	off := []byte("flags:\n- {name: a, enabled: false}\n- {name: b, enabled: false}\n")
+	if err := LoadFlags(on); err != nil {
+		t.Fatal(err)
+	}
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := userCtx("u", "")
+			for !stop.Load() {
+				s := Current()
+				if s.Eval(ctx, "a") != s.Eval(ctx, "b") {
+					t.Error("mixed snapshot observed")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		data := on
+		if i%2 == 0 {
+			data = off
+		}
+		if err := LoadFlags(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+
+	if err := LoadFlags([]byte("flags:\n- {name: a, percentage: 200}\n")); err == nil {
+		t.Fatal("invalid flags loaded")
+	}
+	if !Eval(userCtx("u", ""), "a") {
+		t.Error("invalid load replaced the previous flags")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.yaml")
+	if err := os.WriteFile(path, []byte("flags:\n- {name: w, enabled: false}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- Watch(ctx, &config.FileSystemSource{FilePath: path}, 5*time.Millisecond) }()
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("flags:\n- {name: w, enabled: true, percentage: 100}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !Eval(userCtx("u", ""), "w") {
+		if time.Now().After(deadline) {
+			t.Fatal("change not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGinOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := Replace([]Flag{{Name: "push", Enabled: true}}); err != nil {
+		t.Fatal(err)
+	}
+	serve := func(roles []string) string {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set(mcontext.OpUserRoles, roles)
+			c.Next()
+		})
+		r.Use(GinOverride(nil))
+		r.GET("/", func(c *gin.Context) {
+			c.String(http.StatusOK, "%v %v", Eval(c, "push"), Eval(c.Request.Context(), "push"))
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(OverrideHeader, "push=on, bogus, index=off")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	if got := serve([]string{"user", AdminRole}); got != "true true" {
+		t.Errorf("admin override = %q", got)
+	}
+	if got := serve([]string{"user"}); got != "false false" {
+		t.Errorf("non admin override = %q", got)
+	}
+	if got := parseOverrides("a=on,b=off,c=maybe,=on"); len(got) != 2 || !got["a"] || got["b"] {
+		t.Errorf("parseOverrides = %v", got)
+	}
+}
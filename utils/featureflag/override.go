@@ -0,0 +1,96 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featureflag
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
+)
+
+// OverrideHeader forces flags for a single request, for testing, as a comma
+// separated list of name=on or name=off.
+const OverrideHeader = "X-Feature-Flags"
+
+// AdminRole is the token role allowed to send OverrideHeader by default.
+const AdminRole = "admin"
+
+// overridesKey is the context key carrying the overrides of a request. It is a
+// string so that flags evaluated on the *gin.Context itself see them too.
+const overridesKey = "featureFlagOverrides"
+
+// IsAdmin reports whether the roles of the token of the request include
+// AdminRole, see tokenverify.WithRoles.
+func IsAdmin(ctx context.Context) bool {
+	return slices.Contains(mcontext.GetOpUserRoles(ctx), AdminRole)
+}
+
+// GinOverride returns a middleware honoring OverrideHeader on requests for
+// which isAdmin returns true, IsAdmin when nil. It must run after the token
+// has been parsed. The header is ignored, and logged, for any other request.
+func GinOverride(isAdmin func(ctx context.Context) bool) gin.HandlerFunc {
+	if isAdmin == nil {
+		isAdmin = IsAdmin
+	}
+	return func(c *gin.Context) {
+		header := c.GetHeader(OverrideHeader)
+		if header == "" {
+			c.Next()
+			return
+		}
+		if !isAdmin(c) {
+			log.ZWarn(c, "feature flag override ignored for non admin token", nil, "userID", mcontext.GetOpUserID(c))
+			c.Next()
+			return
+		}
+		overrides := parseOverrides(header)
+		c.Set(overridesKey, overrides)
+		c.Request = c.Request.WithContext(WithOverrides(c.Request.Context(), overrides))
+		c.Next()
+	}
+}
+
+// WithOverrides returns a context for which Eval reports the given values
+// regardless of the loaded flags.
+func WithOverrides(ctx context.Context, overrides map[string]bool) context.Context {
+	return context.WithValue(ctx, overridesKey, overrides)
+}
+
+func overrideFrom(ctx context.Context, flagName string) (on, ok bool) {
+	overrides, _ := ctx.Value(overridesKey).(map[string]bool)
+	on, ok = overrides[flagName]
+	return
+}
+
+func parseOverrides(header string) map[string]bool {
+	overrides := make(map[string]bool)
+	for _, item := range strings.Split(header, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(item), "=")
+		if !found || name == "" {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "on", "true", "1":
+			overrides[name] = true
+		case "off", "false", "0":
+			overrides[name] = false
+		}
+	}
+	return overrides
+}
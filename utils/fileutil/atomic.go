@@ -0,0 +1,52 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// WriteFileAtomic writes data to the file at path with permissions perm: it is
+// written to a temporary file of the same directory, synced, then renamed over
+// path, so that readers and crashes see either the old or the new content.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return errs.WrapMsg(err, "create temporary file failed", "path", path)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errs.WrapMsg(err, "write file failed", "path", path)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return errs.WrapMsg(err, "chmod file failed", "path", path)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return errs.WrapMsg(err, "sync file failed", "path", path)
+	}
+	if err := tmp.Close(); err != nil {
+		return errs.WrapMsg(err, "write file failed", "path", path)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errs.WrapMsg(err, "rename file failed", "path", path)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// Checkpoint persists the offset of the last processed line of an import, see
+// Lines.Offset.
+type Checkpoint struct {
+	path string
+}
+
+// NewCheckpoint returns the checkpoint stored in the file at path.
+func NewCheckpoint(path string) *Checkpoint {
+	return &Checkpoint{path: path}
+}
+
+// Load returns the saved offset, 0 when nothing was saved.
+func (c *Checkpoint) Load() (int64, error) {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errs.WrapMsg(err, "read checkpoint failed", "path", c.path)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errs.ErrArgs.WrapMsg("invalid checkpoint", "path", c.path, "content", string(data))
+	}
+	return offset, nil
+}
+
+// Save stores offset atomically, see WriteFileAtomic, so that a crash leaves
+// either the old or the new offset.
+func (c *Checkpoint) Save(offset int64) error {
+	if err := WriteFileAtomic(c.path, []byte(strconv.FormatInt(offset, 10)+"\n"), 0o600); err != nil {
+		return errs.WrapMsg(err, "save checkpoint failed", "path", c.path)
+	}
+	return nil
+}
+
+// Remove deletes the checkpoint, once the import completed.
+func (c *Checkpoint) Remove() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errs.WrapMsg(err, "remove checkpoint failed", "path", c.path)
+	}
+	return nil
+}
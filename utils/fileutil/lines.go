@@ -0,0 +1,270 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fileutil reads large input files line by line, with offsets to
+// resume an interrupted import.
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+const (
+	// DefaultMaxLineSize is the default longest line, terminator excluded.
+	DefaultMaxLineSize = 1 << 20
+
+	bufferSize = 64 << 10
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// LinesOption configures LinesReader.
+type LinesOption func(*Lines)
+
+// WithMaxLineSize sets the longest line accepted, DefaultMaxLineSize by
+// default. Next fails on longer lines.
+func WithMaxLineSize(n int) LinesOption {
+	return func(l *Lines) {
+		if n > 0 {
+			l.maxLine = n
+		}
+	}
+}
+
+// Lines iterates over the lines of a file:
+//
+//	lines, err := fileutil.LinesReader(path, checkpoint)
+//	...
+//	defer lines.Close()
+//	for lines.Next() {
+//		handle(lines.Line())
+//		checkpoint = lines.Offset()
+//	}
+//	err = lines.Err()
+type Lines struct {
+	file    *os.File
+	counter *countingReader
+	gz      *gzip.Reader
+	r       *bufio.Reader
+	maxLine int
+	size    int64
+
+	line   []byte
+	buf    []byte
+	offset int64
+	err    error
+}
+
+// LinesReader opens path and positions it at startOffset, which must be 0 or
+// an offset returned by Lines.Offset. Lines end with \n or \r\n, and the
+// last one may have no terminator.
+//
+// Gzip compressed files, recognized by their magic number, are decompressed
+// transparently. Their offsets count the decompressed bytes, and resuming
+// decompresses and discards the data before startOffset, as gzip streams have
+// no sync points to seek to.
+func LinesReader(path string, startOffset int64, opts ...LinesOption) (*Lines, error) {
+	if startOffset < 0 {
+		return nil, errs.ErrArgs.WrapMsg("negative start offset", "offset", startOffset)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "open lines file failed", "path", path)
+	}
+	l := &Lines{file: f, maxLine: DefaultMaxLineSize, offset: startOffset}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if err := l.open(path, startOffset); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Lines) open(path string, startOffset int64) error {
+	info, err := l.file.Stat()
+	if err != nil {
+		return errs.WrapMsg(err, "stat lines file failed", "path", path)
+	}
+	l.size = info.Size()
+	magic := make([]byte, len(gzipMagic))
+	n, err := io.ReadFull(l.file, magic)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return errs.WrapMsg(err, "read lines file failed", "path", path)
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return errs.WrapMsg(err, "seek lines file failed", "path", path)
+	}
+	l.counter = &countingReader{r: l.file}
+
+	if n == len(gzipMagic) && bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(l.counter)
+		if err != nil {
+			return errs.WrapMsg(err, "invalid gzip file", "path", path)
+		}
+		l.gz = gz
+		l.r = bufio.NewReaderSize(gz, bufferSize)
+		if startOffset == 0 {
+			return nil
+		}
+		// Discard all but the last byte before startOffset, which must end
+		// a line.
+		if _, err := l.r.Discard(int(startOffset - 1)); err != nil {
+			return errs.ErrArgs.WrapMsg("start offset is past the end of the decompressed file", "path", path, "offset", startOffset)
+		}
+		last, err := l.r.ReadByte()
+		if err != nil {
+			return errs.ErrArgs.WrapMsg("start offset is past the end of the decompressed file", "path", path, "offset", startOffset)
+		}
+		if last != '\n' {
+			return errs.ErrArgs.WrapMsg("start offset is not at the start of a line", "path", path, "offset", startOffset)
+		}
+		return nil
+	}
+
+	if startOffset > l.size {
+		return errs.ErrArgs.WrapMsg("start offset is past the end of the file", "path", path, "offset", startOffset, "size", l.size)
+	}
+	if startOffset > 0 {
+		last := make([]byte, 1)
+		if _, err := l.file.ReadAt(last, startOffset-1); err != nil {
+			return errs.WrapMsg(err, "read lines file failed", "path", path)
+		}
+		if last[0] != '\n' {
+			return errs.ErrArgs.WrapMsg("start offset is not at the start of a line", "path", path, "offset", startOffset)
+		}
+		if _, err := l.file.Seek(startOffset, io.SeekStart); err != nil {
+			return errs.WrapMsg(err, "seek lines file failed", "path", path)
+		}
+		l.counter.n = startOffset
+	}
+	l.r = bufio.NewReaderSize(l.counter, bufferSize)
+	return nil
+}
+
+// Next reads the next line. It returns false at the end of the file or on
+// error, see Err.
+func (l *Lines) Next() bool {
+	if l.err != nil {
+		return false
+	}
+	l.buf = l.buf[:0]
+	var read int64
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		read += int64(len(chunk))
+		var line []byte
+		switch {
+		case err == nil:
+			line = trimEOL(l.buf, chunk)
+		case errors.Is(err, bufio.ErrBufferFull):
+			// A terminator takes at most two bytes.
+			if len(l.buf)+len(chunk) > l.maxLine+2 {
+				l.err = errs.ErrArgs.WrapMsg("line too long", "offset", l.offset, "max", l.maxLine)
+				return false
+			}
+			l.buf = append(l.buf, chunk...)
+			continue
+		case errors.Is(err, io.EOF):
+			if len(l.buf)+len(chunk) == 0 {
+				l.err = io.EOF
+				return false
+			}
+			line = append(l.buf, chunk...)
+		default:
+			l.err = errs.WrapMsg(err, "read lines file failed", "offset", l.offset)
+			return false
+		}
+		if len(line) > l.maxLine {
+			l.err = errs.ErrArgs.WrapMsg("line too long", "offset", l.offset, "max", l.maxLine)
+			return false
+		}
+		l.line = line
+		l.offset += read
+		return true
+	}
+}
+
+// trimEOL returns buf followed by chunk, without the \n or \r\n ending chunk.
+// buf is only appended to when not empty, chunk is returned otherwise.
+func trimEOL(buf, chunk []byte) []byte {
+	line := chunk
+	if len(buf) > 0 {
+		line = append(buf, chunk...)
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line
+}
+
+// Line returns the line read by Next, without its terminator. It is only
+// valid until the next call to Next.
+func (l *Lines) Line() []byte {
+	return l.line
+}
+
+// Offset returns the offset following the line read by Next, from which
+// LinesReader resumes after it.
+func (l *Lines) Offset() int64 {
+	return l.offset
+}
+
+// BytesRead returns the number of bytes read from the file, including the
+// bytes skipped to the start offset. Compared to Size, it measures the
+// progress also for compressed files.
+func (l *Lines) BytesRead() int64 {
+	return l.counter.n
+}
+
+// Size returns the size of the file.
+func (l *Lines) Size() int64 {
+	return l.size
+}
+
+// Err returns the error that stopped Next, nil at the end of the file.
+func (l *Lines) Err() error {
+	if errors.Is(l.err, io.EOF) {
+		return nil
+	}
+	return l.err
+}
+
+// Close closes the file.
+func (l *Lines) Close() error {
+	if l.gz != nil {
+		_ = l.gz.Close()
+	}
+	return l.file.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
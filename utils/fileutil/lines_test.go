@@ -0,0 +1,189 @@
+package fileutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type readLine struct {
+	line   string
+	offset int64
+}
+
+func readAll(t *testing.T, path string, start int64, opts ...LinesOption) []readLine {
+	t.Helper()
+	lines, err := LinesReader(path, start, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lines.Close()
+	var got []readLine
+	for lines.Next() {
+		got = append(got, readLine{string(lines.Line()), lines.Offset()})
+	}
+	if err := lines.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if lines.BytesRead() != lines.Size() {
+		t.Errorf("bytes read = %d, want the file size %d", lines.BytesRead(), lines.Size())
+	}
+	return got
+}
+
+// boundaryContent has lines ending exactly at the end of the read buffer, one
+// byte after it, and a \r\n split across it. Every line starts at the start
+// of the buffer, as the reader moves the partial line there before refilling.
+func boundaryContent() ([]byte, []string) {
+	want := []string{
+		strings.Repeat("a", bufferSize-1),
+		strings.Repeat("b", bufferSize),
+		"short",
+		strings.Repeat("c", bufferSize-1),
+		"",
+		"last without terminator",
+	}
+	var b bytes.Buffer
+	b.WriteString(want[0] + "\n")
+	b.WriteString(want[1] + "\n")
+	b.WriteString(want[2] + "\n")
+	b.WriteString(want[3] + "\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(want[5])
+	return b.Bytes(), want
+}
+
+func TestLinesReaderBoundaries(t *testing.T) {
+	data, want := boundaryContent()
+	for name, content := range map[string][]byte{"plain": data, "gzip": gzipped(t, data)} {
+		t.Run(name, func(t *testing.T) {
+			got := readAll(t, writeFile(t, "in", content), 0)
+			if len(got) != len(want) {
+				t.Fatalf("%d lines, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i].line != want[i] {
+					t.Errorf("line %d = %.20q (%d bytes), want %.20q (%d bytes)", i, got[i].line, len(got[i].line), want[i], len(want[i]))
+				}
+			}
+			if end := got[len(got)-1].offset; end != int64(len(data)) {
+				t.Errorf("last offset = %d, want %d", end, len(data))
+			}
+		})
+	}
+}
+
+func TestLinesReaderResume(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 5000; i++ {
+		b.WriteString(`{"userID":"u`)
+		b.WriteString(strings.Repeat("x", i%50))
+		b.WriteString("\"}\n")
+	}
+	data := []byte(b.String())
+	for name, content := range map[string][]byte{"plain": data, "gzip": gzipped(t, data)} {
+		t.Run(name, func(t *testing.T) {
+			path := writeFile(t, "in", content)
+			all := readAll(t, path, 0)
+			middle := all[len(all)/2-1]
+			rest := readAll(t, path, middle.offset)
+			if len(rest) != len(all)/2 {
+				t.Fatalf("resumed %d lines, want %d", len(rest), len(all)/2)
+			}
+			for i, l := range rest {
+				if l != all[len(all)/2+i] {
+					t.Fatalf("resumed line %d = %+v, want %+v", i, l, all[len(all)/2+i])
+				}
+			}
+
+			if _, err := LinesReader(path, middle.offset+1); !errors.Is(err, errs.ErrArgs) {
+				t.Errorf("offset inside a line: err = %v, want ErrArgs", err)
+			}
+			if _, err := LinesReader(path, int64(len(data))+1); !errors.Is(err, errs.ErrArgs) {
+				t.Errorf("offset past the end: err = %v, want ErrArgs", err)
+			}
+		})
+	}
+}
+
+func TestLinesReaderMaxLineSize(t *testing.T) {
+	for name, content := range map[string]string{
+		"buffered":      strings.Repeat("a", 8) + "\n" + strings.Repeat("b", bufferSize*2) + "\n",
+		"terminated":    "ok\n" + strings.Repeat("b", 11) + "\n",
+		"unterminated":  "ok\n" + strings.Repeat("b", 11),
+		"at max \\r\\n": "ok\n" + strings.Repeat("b", 10) + "\r\n" + strings.Repeat("b", 11),
+	} {
+		lines, err := LinesReader(writeFile(t, "in", []byte(content)), 0, WithMaxLineSize(10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for lines.Next() {
+			if len(lines.Line()) > 10 {
+				t.Errorf("%s: returned a %d bytes line", name, len(lines.Line()))
+			}
+		}
+		if err := lines.Err(); !errors.Is(err, errs.ErrArgs) {
+			t.Errorf("%s: err = %v, want ErrArgs", name, err)
+		}
+		_ = lines.Close()
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "import.offset")
+	c := NewCheckpoint(path)
+	if offset, err := c.Load(); err != nil || offset != 0 {
+		t.Fatalf("Load without checkpoint = %d, %v", offset, err)
+	}
+	for _, offset := range []int64{10, 1 << 40} {
+		if err := c.Save(offset); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := c.Load(); err != nil || got != offset {
+			t.Fatalf("Load = %d, %v, want %d", got, err, offset)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("temporary files left: %v", entries)
+	}
+	if err := c.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if offset, err := c.Load(); err != nil || offset != 0 {
+		t.Fatalf("Load after Remove = %d, %v", offset, err)
+	}
+	if err := os.WriteFile(path, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Load(); !errors.Is(err, errs.ErrArgs) {
+		t.Fatalf("Load of garbage: err = %v, want ErrArgs", err)
+	}
+}
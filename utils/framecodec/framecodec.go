@@ -0,0 +1,261 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package framecodec encodes the frames exchanged between msggateway and the
+// SDKs, so that both sides share one binary layout. A frame is, big-endian:
+//
+//	length  uint32  number of bytes after the length field
+//	version uint8   layout version, see Version1
+//	flags   uint8   FlagCRC: a CRC-32C of version to body follows the body
+//	cmd     uint16
+//	seq     uint32
+//	body    []byte
+//	crc     uint32  present with FlagCRC
+//
+// The length comes first so that a reader can skip a frame of a version it
+// does not know and stay in sync with the stream.
+package framecodec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strconv"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// Version1 is the only layout version this package decodes.
+const Version1 byte = 1
+
+// FlagCRC marks a frame followed by a CRC-32C checksum.
+const FlagCRC byte = 1 << 0
+
+// DefaultMaxFrameSize bounds the length field of a decoded frame, and so the
+// memory a single frame can make the decoder allocate.
+const DefaultMaxFrameSize = 4 << 20
+
+const (
+	lengthSize = 4
+	headerSize = 1 + 1 + 2 + 4 // version, flags, cmd, seq
+	crcSize    = 4
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrUnsupportedVersion is matched by the *UnsupportedVersionError of a
+	// frame of an unknown version.
+	ErrUnsupportedVersion = errs.New("unsupported frame version")
+	ErrFrameTooLarge      = errs.New("frame too large")
+	ErrMalformedFrame     = errs.New("malformed frame")
+	ErrChecksum           = errs.New("frame checksum mismatch")
+)
+
+// UnsupportedVersionError is returned for a frame of a version other than
+// Version1, so that the gateway can answer with an upgrade required message.
+// The frame has been consumed: the stream can go on with the next one.
+type UnsupportedVersionError struct {
+	Version byte
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return "unsupported frame version " + strconv.Itoa(int(e.Version))
+}
+
+func (e *UnsupportedVersionError) Is(target error) bool {
+	return target == ErrUnsupportedVersion
+}
+
+// Frame is a decoded frame.
+type Frame struct {
+	Version byte
+	Flags   byte
+	Cmd     uint16
+	Seq     uint32
+	Body    []byte
+}
+
+type options struct {
+	crc          bool
+	maxFrameSize int
+}
+
+type Option func(o *options)
+
+// WithCRC makes Encode append a checksum, and Decode reject the frames
+// without one. Decode always verifies the checksum of a frame that has one.
+func WithCRC() Option {
+	return func(o *options) {
+		o.crc = true
+	}
+}
+
+// WithMaxFrameSize replaces DefaultMaxFrameSize.
+func WithMaxFrameSize(n int) Option {
+	return func(o *options) {
+		o.maxFrameSize = n
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{maxFrameSize: DefaultMaxFrameSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Encode returns the frame of body. Only WithCRC applies.
+func Encode(version byte, cmd uint16, seq uint32, body []byte, opts ...Option) []byte {
+	return Append(nil, version, cmd, seq, body, opts...)
+}
+
+// Append appends the frame of body to dst, like Encode.
+func Append(dst []byte, version byte, cmd uint16, seq uint32, body []byte, opts ...Option) []byte {
+	o := newOptions(opts)
+	length := headerSize + len(body)
+	var flags byte
+	if o.crc {
+		flags |= FlagCRC
+		length += crcSize
+	}
+	dst = binary.BigEndian.AppendUint32(dst, uint32(length))
+	start := len(dst)
+	dst = append(dst, version, flags)
+	dst = binary.BigEndian.AppendUint16(dst, cmd)
+	dst = binary.BigEndian.AppendUint32(dst, seq)
+	dst = append(dst, body...)
+	if o.crc {
+		dst = binary.BigEndian.AppendUint32(dst, crc32.Checksum(dst[start:], crcTable))
+	}
+	return dst
+}
+
+// Decode decodes data, which must hold exactly one frame. The body of the
+// frame is a copy and does not alias data.
+func Decode(data []byte, opts ...Option) (Frame, error) {
+	o := newOptions(opts)
+	if len(data) < lengthSize {
+		return Frame{}, ErrMalformedFrame.WrapMsg("frame shorter than its length field", "size", len(data))
+	}
+	length, err := checkLength(binary.BigEndian.Uint32(data), o)
+	if err != nil {
+		return Frame{}, err
+	}
+	if len(data)-lengthSize != length {
+		return Frame{}, ErrMalformedFrame.WrapMsg("frame length does not match the data", "length", length, "size", len(data)-lengthSize)
+	}
+	payload := make([]byte, length)
+	copy(payload, data[lengthSize:])
+	return parse(payload, o)
+}
+
+// Decoder reads frames from a stream, such as a TCP connection, through a
+// bufio.Reader to save read calls.
+type Decoder struct {
+	r    *bufio.Reader
+	opts options
+}
+
+// NewDecoder returns a Decoder reading from r. r is not wrapped again when it
+// is already a *bufio.Reader.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Decoder{r: br, opts: newOptions(opts)}
+}
+
+// Decode reads the next frame. It returns io.EOF at the end of the stream
+// between two frames, and io.ErrUnexpectedEOF inside a frame. After an
+// *UnsupportedVersionError or an ErrChecksum the stream is still in sync;
+// after other errors it is not and should be closed.
+func (d *Decoder) Decode() (Frame, error) {
+	var prefix [lengthSize + 1]byte
+	if _, err := io.ReadFull(d.r, prefix[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return Frame{}, err
+		}
+		return Frame{}, errs.WrapMsg(err, "read frame failed")
+	}
+	length, err := checkLength(binary.BigEndian.Uint32(prefix[:]), d.opts)
+	if err != nil {
+		return Frame{}, err
+	}
+	if version := prefix[lengthSize]; version != Version1 {
+		if _, err := d.r.Discard(length - 1); err != nil {
+			return Frame{}, unexpectedEOF(err)
+		}
+		return Frame{}, &UnsupportedVersionError{Version: version}
+	}
+	payload := make([]byte, length)
+	payload[0] = prefix[lengthSize]
+	if _, err := io.ReadFull(d.r, payload[1:]); err != nil {
+		return Frame{}, unexpectedEOF(err)
+	}
+	return parse(payload, d.opts)
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return errs.WrapMsg(err, "read frame failed")
+}
+
+// checkLength validates the length field before anything is allocated.
+func checkLength(length uint32, o options) (int, error) {
+	if o.maxFrameSize > 0 && int64(length) > int64(o.maxFrameSize) {
+		return 0, ErrFrameTooLarge.WrapMsg("frame exceeds the maximum size", "length", length, "max", o.maxFrameSize)
+	}
+	// The version byte is needed to tell an unknown layout.
+	if length < 1 {
+		return 0, ErrMalformedFrame.WrapMsg("empty frame")
+	}
+	return int(length), nil
+}
+
+// parse decodes payload, the bytes after the length field.
+func parse(payload []byte, o options) (Frame, error) {
+	if payload[0] != Version1 {
+		return Frame{}, &UnsupportedVersionError{Version: payload[0]}
+	}
+	if len(payload) < headerSize {
+		return Frame{}, ErrMalformedFrame.WrapMsg("frame shorter than its header", "length", len(payload))
+	}
+	f := Frame{
+		Version: payload[0],
+		Flags:   payload[1],
+		Cmd:     binary.BigEndian.Uint16(payload[2:]),
+		Seq:     binary.BigEndian.Uint32(payload[4:]),
+	}
+	end := len(payload)
+	if f.Flags&FlagCRC != 0 {
+		if end < headerSize+crcSize {
+			return Frame{}, ErrMalformedFrame.WrapMsg("frame shorter than its checksum", "length", len(payload))
+		}
+		end -= crcSize
+		if crc32.Checksum(payload[:end], crcTable) != binary.BigEndian.Uint32(payload[end:]) {
+			return Frame{}, ErrChecksum.WrapMsg("frame checksum mismatch", "cmd", f.Cmd, "seq", f.Seq)
+		}
+	} else if o.crc {
+		return Frame{}, ErrChecksum.WrapMsg("frame has no checksum", "cmd", f.Cmd, "seq", f.Seq)
+	}
+	f.Body = payload[headerSize:end:end]
+	return f, nil
+}
@@ -0,0 +1,174 @@
+package framecodec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCRC()}} {
+		for _, body := range [][]byte{{}, []byte("hello"), bytes.Repeat([]byte{0}, 70000)} {
+			data := Encode(Version1, 1003, 42, body, opts...)
+			f, err := Decode(data, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Version != Version1 || f.Cmd != 1003 || f.Seq != 42 || !bytes.Equal(f.Body, body) {
+				t.Fatalf("decoded %+v", f)
+			}
+			data[len(data)-1] = ^data[len(data)-1]
+			if _, err := Decode(data, opts...); len(opts) > 0 && !errors.Is(err, ErrChecksum) {
+				t.Fatalf("corrupted frame: err = %v, want ErrChecksum", err)
+			}
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	got := Encode(Version1, 0x0102, 0x03040506, []byte{0xAA})
+	want := []byte{0, 0, 0, 9, 1, 0, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0xAA}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Encode = % x, want % x", got, want)
+	}
+	if _, err := Decode(Encode(Version1, 1, 1, nil), WithCRC()); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("frame without CRC accepted under WithCRC: %v", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	huge := binary.BigEndian.AppendUint32(nil, 1<<31)
+	tests := map[string]struct {
+		data []byte
+		want error
+	}{
+		"short":        {[]byte{0, 0}, ErrMalformedFrame},
+		"empty":        {[]byte{0, 0, 0, 0}, ErrMalformedFrame},
+		"too large":    {append(huge, 1), ErrFrameTooLarge},
+		"truncated":    {Encode(Version1, 1, 1, []byte("body"))[:10], ErrMalformedFrame},
+		"trailing":     {append(Encode(Version1, 1, 1, nil), 0), ErrMalformedFrame},
+		"short header": {[]byte{0, 0, 0, 3, 1, 0, 0}, ErrMalformedFrame},
+		"version":      {Encode(7, 1, 1, []byte("v7")), ErrUnsupportedVersion},
+	}
+	for name, tt := range tests {
+		if _, err := Decode(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, tt.want)
+		}
+	}
+	_, err := Decode(Encode(9, 1, 1, nil))
+	var versionErr *UnsupportedVersionError
+	if !errors.As(err, &versionErr) || versionErr.Version != 9 {
+		t.Fatalf("err = %v, want an UnsupportedVersionError for 9", err)
+	}
+}
+
+func TestDecoderStream(t *testing.T) {
+	var stream []byte
+	stream = Append(stream, Version1, 1, 1, []byte("first"))
+	stream = Append(stream, 2, 1, 2, []byte("from a newer SDK"))
+	stream = Append(stream, Version1, 1, 3, []byte("third"), WithCRC())
+	d := NewDecoder(bytes.NewReader(stream))
+
+	if f, err := d.Decode(); err != nil || string(f.Body) != "first" {
+		t.Fatalf("first = %+v, %v", f, err)
+	}
+	var versionErr *UnsupportedVersionError
+	if _, err := d.Decode(); !errors.As(err, &versionErr) || versionErr.Version != 2 {
+		t.Fatalf("second: err = %v, want version 2", err)
+	}
+	if f, err := d.Decode(); err != nil || f.Seq != 3 || string(f.Body) != "third" {
+		t.Fatalf("third = %+v, %v: the stream lost sync", f, err)
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Fatalf("end: err = %v, want io.EOF", err)
+	}
+
+	d = NewDecoder(bytes.NewReader(stream[:7]))
+	if _, err := d.Decode(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("truncated: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+	br := bufio.NewReader(bytes.NewReader(nil))
+	if NewDecoder(br).r != br {
+		t.Fatal("bufio.Reader wrapped again")
+	}
+}
+
+// allocated returns the bytes allocated by fn.
+func allocated(fn func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(Encode(Version1, 1, 1, []byte("hello")))
+	f.Add(Encode(Version1, 2, 2, []byte("crc"), WithCRC()))
+	f.Add(Encode(3, 1, 1, nil))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 1})
+	f.Add([]byte{0, 0, 0, 1, 1})
+	const maxFrameSize = 1024
+	// The frame, the bufio buffer and bookkeeping.
+	const budget = maxFrameSize + 8<<10
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if n := allocated(func() { _, _ = Decode(data, WithMaxFrameSize(maxFrameSize)) }); n > uint64(len(data))+budget {
+			t.Fatalf("Decode allocated %d bytes for %d bytes of input", n, len(data))
+		}
+		d := NewDecoder(bytes.NewReader(data), WithMaxFrameSize(maxFrameSize))
+		for i := 0; i < 16; i++ {
+			var frame Frame
+			var err error
+			if n := allocated(func() { frame, err = d.Decode() }); n > budget {
+				t.Fatalf("Decoder allocated %d bytes", n)
+			}
+			if len(frame.Body) > maxFrameSize {
+				t.Fatalf("body of %d bytes", len(frame.Body))
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF || errors.Is(err, ErrFrameTooLarge) || errors.Is(err, ErrMalformedFrame) {
+				break
+			}
+		}
+	})
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add(byte(1), uint16(1), uint32(1), []byte("x"), false)
+	f.Fuzz(func(t *testing.T, version byte, cmd uint16, seq uint32, body []byte, crc bool) {
+		var opts []Option
+		if crc {
+			opts = append(opts, WithCRC())
+		}
+		frame, err := Decode(Encode(version, cmd, seq, body, opts...), opts...)
+		if version != Version1 {
+			if !errors.Is(err, ErrUnsupportedVersion) {
+				t.Fatalf("version %d: err = %v", version, err)
+			}
+			return
+		}
+		if err != nil || frame.Cmd != cmd || frame.Seq != seq || !bytes.Equal(frame.Body, body) {
+			t.Fatalf("round trip = %+v, %v", frame, err)
+		}
+	})
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	frame := Encode(Version1, 1, 1, bytes.Repeat([]byte("m"), 512))
+	stream := bytes.Repeat(frame, 1024)
+	b.SetBytes(int64(len(frame)))
+	b.ReportAllocs()
+	r := bytes.NewReader(stream)
+	d := NewDecoder(r)
+	for i := 0; i < b.N; i++ {
+		if _, err := d.Decode(); err == io.EOF {
+			r.Reset(stream)
+			continue
+		} else if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
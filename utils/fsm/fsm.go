@@ -0,0 +1,246 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fsm implements a small finite state machine for lifecycles such as
+// gateway connections or call invitations.
+//
+// A Machine is not safe for concurrent use; wrap it with NewSafe when several
+// goroutines fire events.
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+const defaultHistorySize = 8
+
+type State string
+
+type Event string
+
+// Transition moves the machine from From to To when Event is fired and Guard,
+// if set, returns true. Several transitions may share From and Event; the
+// first whose guard passes is taken.
+type Transition struct {
+	From  State
+	Event Event
+	To    State
+	Guard func() bool
+}
+
+// Record is a transition that took place.
+type Record struct {
+	From  State
+	Event Event
+	To    State
+	Time  time.Time
+}
+
+func (r Record) String() string {
+	return fmt.Sprintf("%s -%s-> %s", r.From, r.Event, r.To)
+}
+
+// TransitionError is returned by Fire when event cannot be fired in State.
+// It wraps errs.ErrArgs.
+type TransitionError struct {
+	State State
+	Event Event
+	// GuardRejected is set when transitions exist but all guards refused.
+	GuardRejected bool
+	// History holds the last transitions, oldest first.
+	History []Record
+}
+
+func (e *TransitionError) Error() string {
+	var b strings.Builder
+	if e.GuardRejected {
+		fmt.Fprintf(&b, "fsm: event %q rejected by guard in state %q", e.Event, e.State)
+	} else {
+		fmt.Fprintf(&b, "fsm: event %q not allowed in state %q", e.Event, e.State)
+	}
+	if len(e.History) > 0 {
+		b.WriteString(", history: ")
+		for i, r := range e.History {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(r.String())
+		}
+	}
+	return b.String()
+}
+
+func (e *TransitionError) Unwrap() error {
+	return errs.ErrArgs
+}
+
+type Option func(m *Machine)
+
+// WithHistory sets how many transitions are kept for History and error
+// messages. The default is 8; 0 disables the history.
+func WithHistory(n int) Option {
+	return func(m *Machine) {
+		m.historySize = n
+	}
+}
+
+type transitionKey struct {
+	from  State
+	event Event
+}
+
+// Hook is called with the transition that enters or leaves a state.
+type Hook func(r Record)
+
+// Machine is a finite state machine.
+type Machine struct {
+	current     State
+	transitions []Transition
+	index       map[transitionKey][]int
+	onEnter     map[State][]Hook
+	onExit      map[State][]Hook
+
+	historySize int
+	history     []Record
+	next        int
+	now         func() time.Time
+}
+
+// New returns a machine in state initial that accepts transitions.
+func New(initial State, transitions []Transition, opts ...Option) *Machine {
+	m := &Machine{
+		current:     initial,
+		transitions: append([]Transition(nil), transitions...),
+		index:       make(map[transitionKey][]int),
+		onEnter:     make(map[State][]Hook),
+		onExit:      make(map[State][]Hook),
+		historySize: defaultHistorySize,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	for i, t := range m.transitions {
+		key := transitionKey{t.From, t.Event}
+		m.index[key] = append(m.index[key], i)
+	}
+	return m
+}
+
+// OnEnter registers fn to run after the machine enters state. Hooks run in
+// registration order.
+func (m *Machine) OnEnter(state State, fn Hook) {
+	m.onEnter[state] = append(m.onEnter[state], fn)
+}
+
+// OnExit registers fn to run before the machine leaves state.
+func (m *Machine) OnExit(state State, fn Hook) {
+	m.onExit[state] = append(m.onExit[state], fn)
+}
+
+// Current returns the current state.
+func (m *Machine) Current() State {
+	return m.current
+}
+
+// Can reports whether event would be accepted now, guards included.
+func (m *Machine) Can(event Event) bool {
+	_, ok := m.find(event)
+	return ok
+}
+
+func (m *Machine) find(event Event) (Transition, bool) {
+	for _, i := range m.index[transitionKey{m.current, event}] {
+		if t := m.transitions[i]; t.Guard == nil || t.Guard() {
+			return t, true
+		}
+	}
+	return Transition{}, false
+}
+
+// Fire applies event. The exit hooks of the current state run first, then the
+// state changes and the enter hooks of the new state run. A *TransitionError
+// is returned when no transition accepts event.
+func (m *Machine) Fire(event Event) error {
+	t, ok := m.find(event)
+	if !ok {
+		return &TransitionError{
+			State:         m.current,
+			Event:         event,
+			GuardRejected: len(m.index[transitionKey{m.current, event}]) > 0,
+			History:       m.History(),
+		}
+	}
+	r := Record{From: m.current, Event: event, To: t.To, Time: m.now()}
+	for _, fn := range m.onExit[r.From] {
+		fn(r)
+	}
+	m.current = t.To
+	m.record(r)
+	for _, fn := range m.onEnter[r.To] {
+		fn(r)
+	}
+	return nil
+}
+
+func (m *Machine) record(r Record) {
+	if m.historySize <= 0 {
+		return
+	}
+	if len(m.history) < m.historySize {
+		m.history = append(m.history, r)
+		return
+	}
+	m.history[m.next] = r
+	m.next = (m.next + 1) % m.historySize
+}
+
+// History returns the last transitions, oldest first.
+func (m *Machine) History() []Record {
+	res := make([]Record, 0, len(m.history))
+	res = append(res, m.history[m.next:]...)
+	return append(res, m.history[:m.next]...)
+}
+
+// Transitions returns the transition table.
+func (m *Machine) Transitions() []Transition {
+	return append([]Transition(nil), m.transitions...)
+}
+
+// Table formats the transition table as Markdown, sorted by state and event,
+// for documentation. Guarded transitions are marked.
+func (m *Machine) Table() string {
+	rows := m.Transitions()
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].From != rows[j].From {
+			return rows[i].From < rows[j].From
+		}
+		return rows[i].Event < rows[j].Event
+	})
+	var b strings.Builder
+	b.WriteString("| From | Event | To | Guarded |\n|---|---|---|---|\n")
+	for _, t := range rows {
+		guarded := ""
+		if t.Guard != nil {
+			guarded = "yes"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", t.From, t.Event, t.To, guarded)
+	}
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package fsm
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+const (
+	idle       State = "idle"
+	connecting State = "connecting"
+	connected  State = "connected"
+	closed     State = "closed"
+)
+
+func connTransitions(authorized *bool) []Transition {
+	return []Transition{
+		{From: idle, Event: "dial", To: connecting},
+		{From: connecting, Event: "established", To: connected, Guard: func() bool { return *authorized }},
+		{From: connecting, Event: "fail", To: closed},
+		{From: connected, Event: "close", To: closed},
+		{From: closed, Event: "dial", To: connecting},
+	}
+}
+
+func TestFireAndHooks(t *testing.T) {
+	authorized := true
+	m := New(idle, connTransitions(&authorized))
+	var calls []string
+	m.OnExit(idle, func(r Record) { calls = append(calls, "exit "+string(r.From)+" "+string(m.Current())) })
+	m.OnEnter(connecting, func(r Record) { calls = append(calls, "enter "+string(r.To)+" "+string(m.Current())) })
+	m.OnEnter(connecting, func(r Record) { calls = append(calls, "enter2 "+string(r.Event)) })
+
+	if err := m.Fire("dial"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"exit idle idle", "enter connecting connecting", "enter2 dial"}
+	if strings.Join(calls, ";") != strings.Join(want, ";") {
+		t.Fatalf("hooks ran as %v, want %v", calls, want)
+	}
+	for _, e := range []Event{"established", "close", "dial"} {
+		if err := m.Fire(e); err != nil {
+			t.Fatalf("Fire(%s): %v", e, err)
+		}
+	}
+	if m.Current() != connecting {
+		t.Fatalf("state = %s, want connecting", m.Current())
+	}
+}
+
+func TestFireRejected(t *testing.T) {
+	authorized := false
+	m := New(idle, connTransitions(&authorized))
+	if err := m.Fire("dial"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.Fire("established")
+	var terr *TransitionError
+	if !errors.As(err, &terr) || !terr.GuardRejected || terr.State != connecting || terr.Event != "established" {
+		t.Fatalf("guarded Fire = %v, want guard rejection", err)
+	}
+	if m.Current() != connecting || m.Can("established") {
+		t.Fatal("guard rejection changed the state")
+	}
+
+	err = m.Fire("close")
+	if !errors.As(err, &terr) || terr.GuardRejected {
+		t.Fatalf("undefined Fire = %v, want undefined transition", err)
+	}
+	if !errors.Is(err, errs.ErrArgs) {
+		t.Error("TransitionError does not wrap ErrArgs")
+	}
+	want := `fsm: event "close" not allowed in state "connecting", history: idle -dial-> connecting`
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err.Error(), want)
+	}
+
+	authorized = true
+	if err := m.Fire("established"); err != nil {
+		t.Fatalf("guard should pass now: %v", err)
+	}
+}
+
+func TestHistoryRing(t *testing.T) {
+	authorized := true
+	m := New(idle, connTransitions(&authorized), WithHistory(3))
+	for _, e := range []Event{"dial", "fail", "dial", "established", "close"} {
+		if err := m.Fire(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, r := range m.History() {
+		got = append(got, r.String())
+	}
+	want := "closed -dial-> connecting,connecting -established-> connected,connected -close-> closed"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("history = %v, want %s", got, want)
+	}
+	if len(New(idle, nil, WithHistory(0)).History()) != 0 {
+		t.Fatal("history kept with WithHistory(0)")
+	}
+}
+
+func TestTable(t *testing.T) {
+	authorized := true
+	want := "| From | Event | To | Guarded |\n|---|---|---|---|\n" +
+		"| closed | dial | connecting |  |\n" +
+		"| connected | close | closed |  |\n" +
+		"| connecting | established | connected | yes |\n" +
+		"| connecting | fail | closed |  |\n" +
+		"| idle | dial | connecting |  |\n"
+	if got := New(idle, connTransitions(&authorized)).Table(); got != want {
+		t.Fatalf("Table() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSafeMachineConcurrentFire(t *testing.T) {
+	authorized := true
+	m := NewSafe(idle, connTransitions(&authorized))
+	var entered int
+	m.OnEnter(connecting, func(Record) { entered++ })
+
+	const n = 100
+	var (
+		wg        sync.WaitGroup
+		lock      sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Fire("dial"); err == nil {
+				lock.Lock()
+				succeeded++
+				lock.Unlock()
+			} else if !errors.As(err, new(*TransitionError)) {
+				t.Errorf("unexpected error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 || entered != 1 || m.Current() != connecting {
+		t.Fatalf("succeeded=%d entered=%d state=%s, want exactly one dial", succeeded, entered, m.Current())
+	}
+}
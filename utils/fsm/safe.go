@@ -0,0 +1,74 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "sync"
+
+// SafeMachine is a Machine safe for concurrent use. Events are applied one at
+// a time and hooks run while the machine is locked, so they must not call
+// back into it.
+type SafeMachine struct {
+	lock sync.Mutex
+	m    *Machine
+}
+
+// NewSafe is like New but returns a machine safe for concurrent use.
+func NewSafe(initial State, transitions []Transition, opts ...Option) *SafeMachine {
+	return &SafeMachine{m: New(initial, transitions, opts...)}
+}
+
+func (s *SafeMachine) OnEnter(state State, fn Hook) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.m.OnEnter(state, fn)
+}
+
+func (s *SafeMachine) OnExit(state State, fn Hook) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.m.OnExit(state, fn)
+}
+
+func (s *SafeMachine) Current() State {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.m.Current()
+}
+
+func (s *SafeMachine) Can(event Event) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.m.Can(event)
+}
+
+func (s *SafeMachine) Fire(event Event) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.m.Fire(event)
+}
+
+func (s *SafeMachine) History() []Record {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.m.History()
+}
+
+func (s *SafeMachine) Transitions() []Transition {
+	return s.m.Transitions()
+}
+
+func (s *SafeMachine) Table() string {
+	return s.m.Table()
+}
@@ -0,0 +1,252 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hashring implements a consistent hashing ring, to spread keys over
+// a changing set of nodes, such as the scheduled jobs of a service over its
+// instances, while moving as few keys as possible when a node joins or
+// leaves.
+package hashring
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// DefaultReplicas is the number of virtual nodes of a node of weight 1. The
+// share of the keys a node owns deviates by about 1/sqrt(replicas) from the
+// mean, so the spread between the most and the least loaded node grows with
+// the number of nodes: 1000 keeps the max/min ratio below 1.3 up to about
+// 200 nodes, where 200 only does below 10.
+const DefaultReplicas = 1000
+
+// ErrEmptyRing is returned by Get and GetN when the ring has no node.
+var ErrEmptyRing = errs.New("hash ring is empty")
+
+// HashFunc hashes the keys and the virtual nodes of a Ring. Rings that must
+// agree across processes need the same HashFunc and replicas.
+type HashFunc func(key string) uint64
+
+// Hash is the default HashFunc: the 64-bit FNV-1a hash of key, followed by
+// the fmix64 finalizer of MurmurHash3, which FNV needs to spread the
+// similar names of the virtual nodes. Its values never change between
+// versions or processes.
+func Hash(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// point is a virtual node. It refers to its node by index in the sorted
+// names of its state, so that the ring holds no pointer for the garbage
+// collector to scan.
+type point struct {
+	hash uint64
+	node int32
+}
+
+// state is an immutable ring, replaced as a whole on every change.
+type state struct {
+	points  []point
+	names   []string
+	weights map[string]int
+}
+
+// Ring is a consistent hashing ring. Get and GetN are lock free and safe to
+// call concurrently with each other and with Add and Remove, which rebuild
+// the ring and are meant to be infrequent.
+type Ring struct {
+	replicas int
+	hash     HashFunc
+	mu       sync.Mutex // serializes the writers
+	state    atomic.Pointer[state]
+}
+
+// New returns an empty ring giving replicas virtual nodes to each node of
+// weight 1. A replicas below 1 means DefaultReplicas and a nil hash means
+// Hash.
+func New(replicas int, hash HashFunc) *Ring {
+	if replicas < 1 {
+		replicas = DefaultReplicas
+	}
+	if hash == nil {
+		hash = Hash
+	}
+	r := &Ring{replicas: replicas, hash: hash}
+	r.state.Store(&state{weights: map[string]int{}})
+	return r
+}
+
+// Add adds node with weight 1, or sets the weight of node back to 1.
+func (r *Ring) Add(node string) {
+	r.AddWeighted(node, 1)
+}
+
+// AddWeighted adds node with weight times the virtual nodes of a node of
+// weight 1, so that it receives about weight times as many keys. A weight
+// below 1 means 1. Adding a node already in the ring changes its weight.
+func (r *Ring) AddWeighted(node string, weight int) {
+	r.update(func(weights map[string]int) { weights[node] = max(weight, 1) })
+}
+
+// Remove removes node from the ring, if present.
+func (r *Ring) Remove(node string) {
+	r.update(func(weights map[string]int) { delete(weights, node) })
+}
+
+func (r *Ring) update(change func(weights map[string]int)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.state.Load()
+	weights := make(map[string]int, len(old.weights)+1)
+	for node, w := range old.weights {
+		weights[node] = w
+	}
+	change(weights)
+	r.state.Store(r.build(old, weights))
+}
+
+// build returns the ring of weights, reusing the points of old for the nodes
+// whose weight did not change. Its points only depend on weights, not on the
+// order the nodes were added in.
+func (r *Ring) build(old *state, weights map[string]int) *state {
+	names := make([]string, 0, len(weights))
+	for node := range weights {
+		names = append(names, node)
+	}
+	sort.Strings(names)
+	index := make(map[string]int32, len(names))
+	for i, node := range names {
+		index[node] = int32(i)
+	}
+	// remap maps the old index of a node to its new one, -1 for the nodes
+	// removed or reweighted, whose points are rebuilt.
+	remap := make([]int32, len(old.names))
+	for i, node := range old.names {
+		remap[i] = -1
+		if weights[node] == old.weights[node] {
+			remap[i] = index[node]
+		}
+	}
+	var added []point
+	for i, node := range names {
+		if w, ok := old.weights[node]; ok && w == weights[node] {
+			continue
+		}
+		for v := 0; v < weights[node]*r.replicas; v++ {
+			added = append(added, point{hash: r.hash(node + "#" + strconv.Itoa(v)), node: int32(i)})
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].less(added[j]) })
+	kept := make([]point, 0, len(old.points))
+	for _, p := range old.points {
+		if i := remap[p.node]; i >= 0 {
+			kept = append(kept, point{hash: p.hash, node: i})
+		}
+	}
+	// Merge the new points into the kept ones a run at a time, so that an
+	// update costs little more than copying the ring.
+	points := make([]point, 0, len(kept)+len(added))
+	for _, p := range added {
+		i := sort.Search(len(kept), func(i int) bool { return p.less(kept[i]) })
+		points = append(points, kept[:i]...)
+		points = append(points, p)
+		kept = kept[i:]
+	}
+	points = append(points, kept...)
+	return &state{points: points, names: names, weights: weights}
+}
+
+// less orders the points by hash, then by node name, which the index of the
+// node follows.
+func (p point) less(q point) bool {
+	if p.hash != q.hash {
+		return p.hash < q.hash
+	}
+	return p.node < q.node
+}
+
+// Get returns the node owning key: the node of the first virtual node at or
+// after the hash of key.
+func (r *Ring) Get(key string) (string, error) {
+	s := r.state.Load()
+	if len(s.points) == 0 {
+		return "", ErrEmptyRing.Wrap()
+	}
+	return s.names[s.points[s.search(r.hash(key))].node], nil
+}
+
+// GetN returns up to n distinct nodes for key, in ring order from the node
+// Get returns, to place replicas of key. It returns every node when the ring
+// has fewer than n.
+func (r *Ring) GetN(key string, n int) ([]string, error) {
+	s := r.state.Load()
+	if len(s.points) == 0 {
+		return nil, ErrEmptyRing.Wrap()
+	}
+	n = min(n, len(s.weights))
+	nodes := make([]string, 0, max(n, 0))
+	for i, start := 0, s.search(r.hash(key)); len(nodes) < n && i < len(s.points); i++ {
+		node := s.names[s.points[(start+i)%len(s.points)].node]
+		if !contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// Nodes returns the nodes of the ring, sorted.
+func (r *Ring) Nodes() []string {
+	s := r.state.Load()
+	return append(make([]string, 0, len(s.names)), s.names...)
+}
+
+// Len returns the number of nodes of the ring.
+func (r *Ring) Len() int {
+	return len(r.state.Load().weights)
+}
+
+// search returns the index of the first point at or after hash, wrapping
+// around to 0.
+func (s *state) search(hash uint64) int {
+	i := sort.Search(len(s.points), func(i int) bool { return s.points[i].hash >= hash })
+	if i == len(s.points) {
+		return 0
+	}
+	return i
+}
+
+func contains(nodes []string, node string) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
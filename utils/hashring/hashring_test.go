@@ -0,0 +1,194 @@
+package hashring
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func keys(n int) []string {
+	k := make([]string, n)
+	for i := range k {
+		k[i] = "user:" + strconv.Itoa(i)
+	}
+	return k
+}
+
+func newRing(nodes int) *Ring {
+	r := New(DefaultReplicas, nil)
+	for i := 0; i < nodes; i++ {
+		r.Add("node-" + strconv.Itoa(i))
+	}
+	return r
+}
+
+func assign(t *testing.T, r *Ring, keys []string) map[string]string {
+	t.Helper()
+	owners := make(map[string]string, len(keys))
+	for _, k := range keys {
+		node, err := r.Get(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		owners[k] = node
+	}
+	return owners
+}
+
+func TestEmptyRing(t *testing.T) {
+	r := New(0, nil)
+	if _, err := r.Get("k"); !errors.Is(err, ErrEmptyRing) {
+		t.Fatalf("Get err = %v, want ErrEmptyRing", err)
+	}
+	if _, err := r.GetN("k", 2); !errors.Is(err, ErrEmptyRing) {
+		t.Fatalf("GetN err = %v, want ErrEmptyRing", err)
+	}
+	r.Add("a")
+	r.Remove("a")
+	if _, err := r.Get("k"); !errors.Is(err, ErrEmptyRing) {
+		t.Fatalf("Get after Remove err = %v, want ErrEmptyRing", err)
+	}
+}
+
+// TestBalance checks the spread of the keys over clusters of a realistic
+// size. It grows with the number of nodes, the share of a node deviating by
+// about 1/sqrt(replicas) from the mean.
+func TestBalance(t *testing.T) {
+	k := keys(400000)
+	for _, nodes := range []int{3, 5, 10, 20, 50, 100, 200} {
+		load := map[string]int{}
+		for _, node := range assign(t, newRing(nodes), k) {
+			load[node]++
+		}
+		lo, hi := len(k), 0
+		for _, n := range load {
+			lo, hi = min(lo, n), max(hi, n)
+		}
+		if len(load) != nodes || float64(hi)/float64(lo) >= 1.3 {
+			t.Errorf("%d nodes: max/min = %.2f", nodes, float64(hi)/float64(lo))
+		}
+	}
+}
+
+func TestMinimalDisruption(t *testing.T) {
+	k := keys(50000)
+	r := newRing(10)
+	before := assign(t, r, k)
+
+	r.Add("node-10")
+	after := assign(t, r, k)
+	moved := 0
+	for _, key := range k {
+		if before[key] != after[key] {
+			moved++
+			if after[key] != "node-10" {
+				t.Fatalf("%s moved from %s to %s, not to the new node", key, before[key], after[key])
+			}
+		}
+	}
+	// About 1/11 of the keys should move.
+	if moved == 0 || moved > len(k)/11*13/10 {
+		t.Fatalf("%d of %d keys moved on Add", moved, len(k))
+	}
+
+	r.Remove("node-3")
+	for key, node := range assign(t, r, k) {
+		if after[key] != "node-3" && node != after[key] {
+			t.Fatalf("%s moved from %s to %s on the removal of node-3", key, after[key], node)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	a, b := New(50, nil), New(50, nil)
+	for _, n := range []string{"a", "b", "c"} {
+		a.Add(n)
+	}
+	for _, n := range []string{"c", "a", "b"} {
+		b.Add(n)
+	}
+	for _, key := range keys(1000) {
+		na, _ := a.Get(key)
+		nb, _ := b.Get(key)
+		if na != nb {
+			t.Fatalf("%s: %s != %s depending on the insertion order", key, na, nb)
+		}
+	}
+	// Hash is documented to never change.
+	if got := Hash("openim"); got != 0xcb2b6879858d5863 {
+		t.Fatalf("Hash(openim) = %#x, the hash changed", got)
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	r := New(DefaultReplicas, nil)
+	r.Add("small")
+	r.AddWeighted("big", 3)
+	load := map[string]int{}
+	for _, node := range assign(t, r, keys(40000)) {
+		load[node]++
+	}
+	if ratio := float64(load["big"]) / float64(load["small"]); ratio < 2.5 || ratio > 3.5 {
+		t.Fatalf("load = %v, ratio %.2f, want about 3", load, ratio)
+	}
+}
+
+func TestGetN(t *testing.T) {
+	r := newRing(5)
+	for _, key := range keys(100) {
+		nodes, err := r.GetN(key, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first, _ := r.Get(key)
+		if len(nodes) != 3 || nodes[0] != first || nodes[0] == nodes[1] || nodes[1] == nodes[2] || nodes[0] == nodes[2] {
+			t.Fatalf("%s: GetN = %v, Get = %s", key, nodes, first)
+		}
+	}
+	if nodes, _ := r.GetN("k", 10); len(nodes) != 5 {
+		t.Fatalf("GetN(10) = %v, want the 5 nodes", nodes)
+	}
+	if nodes, _ := r.GetN("k", 0); len(nodes) != 0 {
+		t.Fatalf("GetN(0) = %v", nodes)
+	}
+}
+
+func TestConcurrentGet(t *testing.T) {
+	r := newRing(3)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := r.Get("k"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		r.Add("extra-" + strconv.Itoa(i))
+		r.Remove("extra-" + strconv.Itoa(i-1))
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func BenchmarkGet1000Nodes(b *testing.B) {
+	r := newRing(1000)
+	k := keys(1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = r.Get(k[i%len(k)])
+	}
+}
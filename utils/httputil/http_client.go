@@ -75,6 +75,13 @@ func (c *HTTPClient) Get(url string) ([]byte, error) {
 
 // Post sends a JSON-encoded POST request and returns the response body.
 func (c *HTTPClient) Post(ctx context.Context, url string, headers map[string]string, data any, timeout int) ([]byte, error) {
+	_, result, err := c.post(ctx, url, headers, data, timeout)
+	return result, err
+}
+
+// post sends a JSON-encoded POST request and returns the response status and
+// body.
+func (c *HTTPClient) post(ctx context.Context, url string, headers map[string]string, data any, timeout int) (int, []byte, error) {
 	if timeout > 0 {
 		var cancel func()
 		ctx, cancel = context.WithTimeout(ctx, time.Second*time.Duration(timeout))
@@ -83,13 +90,13 @@ func (c *HTTPClient) Post(ctx context.Context, url string, headers map[string]st
 	body := bytes.NewBuffer(nil)
 	if data != nil {
 		if err := json.NewEncoder(body).Encode(data); err != nil {
-			return nil, errs.WrapMsg(err, "JSON encode failed", "data", data)
+			return 0, nil, errs.WrapMsg(err, "JSON encode failed", "data", data)
 		}
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
 	if err != nil {
-		return nil, errs.WrapMsg(err, "NewRequestWithContext failed", "url", url, "method", http.MethodPost)
+		return 0, nil, errs.WrapMsg(err, "NewRequestWithContext failed", "url", url, "method", http.MethodPost)
 	}
 
 	for key, value := range headers {
@@ -99,16 +106,16 @@ func (c *HTTPClient) Post(ctx context.Context, url string, headers map[string]st
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, errs.WrapMsg(err, "HTTP request failed")
+		return 0, nil, errs.WrapMsg(err, "HTTP request failed")
 	}
 	defer resp.Body.Close()
 
 	result, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errs.WrapMsg(err, "failed to read response body")
+		return 0, nil, errs.WrapMsg(err, "failed to read response body")
 	}
 
-	return result, nil
+	return resp.StatusCode, result, nil
 }
 
 // PostReturn sends a JSON-encoded POST request and decodes the JSON response into  output parameter.
@@ -122,3 +129,25 @@ func (c *HTTPClient) PostReturn(ctx context.Context, url string, headers map[str
 	}
 	return nil
 }
+
+// PostCallback sends a JSON-encoded POST request to a webhook callback server
+// and checks the response with errs.ParseCallbackError, so callers can switch
+// on errs.ErrCallbackContinue, errs.ErrCallbackRefused and
+// errs.ErrCallbackMalformed. The response is decoded into output, when not
+// nil, only if the callback accepted the operation.
+func (c *HTTPClient) PostCallback(ctx context.Context, url string, headers map[string]string, input, output any, timeout int) error {
+	status, body, err := c.post(ctx, url, headers, input, timeout)
+	if err != nil {
+		return err
+	}
+	if err := errs.ParseCallbackError(status, body); err != nil {
+		return errs.WrapMsg(err, "callback failed", "url", url)
+	}
+	if output == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, output); err != nil {
+		return errs.WrapMsg(err, "JSON unmarshal failed")
+	}
+	return nil
+}
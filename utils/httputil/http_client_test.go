@@ -17,11 +17,14 @@ package httputil
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+
+	"github.com/openimsdk/tools/errs"
 )
 
 func TestHTTPClient_Get(t *testing.T) {
@@ -95,3 +98,41 @@ func TestHTTPClient_PostReturn(t *testing.T) {
 		t.Fatalf("Expected %s, got %s", expectedOutput.Key, actualOutput.Key)
 	}
 }
+
+func TestHTTPClient_PostCallback(t *testing.T) {
+	responses := map[string]struct {
+		status int
+		body   string
+	}{
+		"/ok":       {http.StatusOK, `{"actionCode":0,"errCode":0,"nextCode":0,"content":"rewritten"}`},
+		"/continue": {http.StatusOK, `{"actionCode":0,"errCode":0,"nextCode":1}`},
+		"/refuse":   {http.StatusOK, `{"actionCode":1,"errCode":5001,"errMsg":"sensitive word"}`},
+		"/proxy":    {http.StatusBadGateway, `<html><body>502 Bad Gateway</body></html>`},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := responses[r.URL.Path]
+		w.WriteHeader(resp.status)
+		w.Write([]byte(resp.body))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(NewClientConfig())
+	ctx := context.Background()
+	var out struct {
+		Content string `json:"content"`
+	}
+	if err := client.PostCallback(ctx, server.URL+"/ok", nil, map[string]string{"a": "b"}, &out, 5); err != nil || out.Content != "rewritten" {
+		t.Fatalf("ok: %v %+v", err, out)
+	}
+	if err := client.PostCallback(ctx, server.URL+"/continue", nil, nil, nil, 5); !errs.ErrCallbackContinue.Is(err) {
+		t.Errorf("continue: %v", err)
+	}
+	err := client.PostCallback(ctx, server.URL+"/refuse", nil, nil, nil, 5)
+	var cbErr *errs.CallbackError
+	if !errs.ErrCallbackRefused.Is(err) || !errors.As(err, &cbErr) || cbErr.RemoteCode != 5001 {
+		t.Errorf("refuse: %v", err)
+	}
+	if err := client.PostCallback(ctx, server.URL+"/proxy", nil, nil, nil, 5); !errs.ErrCallbackMalformed.Is(err) {
+		t.Errorf("proxy: %v", err)
+	}
+}
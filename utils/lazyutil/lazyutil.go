@@ -0,0 +1,175 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lazyutil provides lazy initialization that, unlike sync.Once, does
+// not cache failures: a client whose first dial failed is dialed again.
+package lazyutil
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/clockutil"
+)
+
+// DefaultBackoff is the time a failed initialization is reported to callers
+// before the factory is tried again.
+const DefaultBackoff = time.Second
+
+type options struct {
+	backoff time.Duration
+	clock   clockutil.Clock
+}
+
+type Option func(*options)
+
+// WithBackoff sets the time after a failure during which Get returns that
+// failure instead of calling the factory again. Zero retries on every Get.
+func WithBackoff(d time.Duration) Option {
+	return func(o *options) {
+		o.backoff = d
+	}
+}
+
+// WithClock replaces the wall clock, for tests.
+func WithClock(clock clockutil.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+type call[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// Value holds a T created on first use by a factory. Success is cached until
+// Reset; a failure is cached for the backoff only. Concurrent Gets share a
+// single factory call.
+type Value[T any] struct {
+	factory func(ctx context.Context) (T, error)
+	opts    options
+	val     atomic.Pointer[T]
+
+	lock     sync.Mutex
+	inflight *call[T]
+	gen      uint64
+	lastErr  error
+	retryAt  time.Time
+}
+
+// New returns a Value initialized by factory. The factory runs detached from
+// the cancellation of the Get that started it, since other callers may be
+// waiting for it, so it should bound its own duration.
+func New[T any](factory func(ctx context.Context) (T, error), opts ...Option) *Value[T] {
+	v := &Value[T]{
+		factory: factory,
+		opts:    options{backoff: DefaultBackoff, clock: clockutil.Real},
+	}
+	for _, opt := range opts {
+		opt(&v.opts)
+	}
+	return v
+}
+
+// Get returns the value, calling the factory if it is not initialized yet
+// and the backoff of the last failure has passed. Once initialized, Get is a
+// single atomic load.
+func (v *Value[T]) Get(ctx context.Context) (T, error) {
+	if p := v.val.Load(); p != nil {
+		return *p, nil
+	}
+	return v.slowGet(ctx)
+}
+
+// MustGet is like Get but panics if the value cannot be initialized.
+func (v *Value[T]) MustGet(ctx context.Context) T {
+	val, err := v.Get(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// Reset drops the cached value or failure, the next Get calls the factory
+// again. A factory call in flight is not cancelled but its result is only
+// returned to the callers already waiting for it.
+func (v *Value[T]) Reset() {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.val.Store(nil)
+	v.gen++
+	v.inflight = nil
+	v.lastErr = nil
+}
+
+func (v *Value[T]) slowGet(ctx context.Context) (T, error) {
+	v.lock.Lock()
+	if p := v.val.Load(); p != nil {
+		v.lock.Unlock()
+		return *p, nil
+	}
+	if v.lastErr != nil && v.opts.clock.Now().Before(v.retryAt) {
+		err := v.lastErr
+		v.lock.Unlock()
+		var zero T
+		return zero, err
+	}
+	c := v.inflight
+	if c == nil {
+		c = &call[T]{done: make(chan struct{})}
+		v.inflight = c
+		go v.run(context.WithoutCancel(ctx), c, v.gen)
+	}
+	v.lock.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		var zero T
+		return zero, errs.Wrap(ctx.Err())
+	}
+}
+
+func (v *Value[T]) run(ctx context.Context, c *call[T], gen uint64) {
+	defer close(c.done)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.err = errs.ErrPanic(r)
+			}
+		}()
+		c.val, c.err = v.factory(ctx)
+	}()
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if gen != v.gen {
+		return
+	}
+	v.inflight = nil
+	if c.err != nil {
+		v.lastErr = c.err
+		v.retryAt = v.opts.clock.Now().Add(v.opts.backoff)
+		return
+	}
+	val := c.val
+	v.val.Store(&val)
+	v.lastErr = nil
+}
@@ -0,0 +1,158 @@
+package lazyutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/utils/clockutil/clockutiltest"
+)
+
+type client struct{ addr string }
+
+func TestRetryAfterFailures(t *testing.T) {
+	var attempts atomic.Int32
+	v := New(func(ctx context.Context) (*client, error) {
+		if attempts.Add(1) <= 2 {
+			return nil, errors.New("dial tcp: connection refused")
+		}
+		return &client{addr: "redis:6379"}, nil
+	}, WithBackoff(0))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				c, err := v.Get(context.Background())
+				if err == nil {
+					if c.addr != "redis:6379" {
+						t.Errorf("addr = %q", c.addr)
+					}
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := attempts.Load(); n != 3 {
+		t.Fatalf("factory called %d times, want 3", n)
+	}
+}
+
+func TestSharedAttempt(t *testing.T) {
+	var attempts atomic.Int32
+	release := make(chan struct{})
+	v := New(func(ctx context.Context) (int, error) {
+		attempts.Add(1)
+		<-release
+		return 42, nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if n := v.MustGet(context.Background()); n != 42 {
+				t.Errorf("got %d", n)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := attempts.Load(); n != 1 {
+		t.Fatalf("factory called %d times, want 1", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	clock := clockutiltest.NewFake(time.Unix(0, 0))
+	var attempts int
+	dialErr := errors.New("dial failed")
+	v := New(func(ctx context.Context) (int, error) {
+		attempts++
+		if attempts == 1 {
+			return 0, dialErr
+		}
+		return 7, nil
+	}, WithBackoff(time.Minute), WithClock(clock))
+
+	for i := 0; i < 3; i++ {
+		if _, err := v.Get(context.Background()); !errors.Is(err, dialErr) {
+			t.Fatalf("Get %d: err = %v, want the cached failure", i, err)
+		}
+	}
+	if attempts != 1 {
+		t.Fatalf("factory called %d times during backoff", attempts)
+	}
+	clock.Advance(time.Minute)
+	if n, err := v.Get(context.Background()); err != nil || n != 7 {
+		t.Fatalf("after backoff: %d, %v", n, err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	var attempts atomic.Int32
+	v := New(func(ctx context.Context) (int32, error) {
+		return attempts.Add(1), nil
+	})
+	if n := v.MustGet(context.Background()); n != 1 {
+		t.Fatalf("got %d", n)
+	}
+	if n := v.MustGet(context.Background()); n != 1 {
+		t.Fatalf("success not cached: %d", n)
+	}
+	v.Reset()
+	if n := v.MustGet(context.Background()); n != 2 {
+		t.Fatalf("after Reset: %d", n)
+	}
+}
+
+func TestWaiterCancelled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	v := New(func(ctx context.Context) (int, error) {
+		<-release
+		return 1, ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := v.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestFactoryPanic(t *testing.T) {
+	v := New(func(ctx context.Context) (int, error) {
+		panic("boom")
+	}, WithBackoff(0))
+	if _, err := v.Get(context.Background()); err == nil {
+		t.Fatal("panic not reported")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustGet did not panic")
+		}
+	}()
+	v.MustGet(context.Background())
+}
+
+// BenchmarkGet measures the steady-state hit path.
+func BenchmarkGet(b *testing.B) {
+	v := New(func(ctx context.Context) (*client, error) { return &client{}, nil })
+	ctx := context.Background()
+	v.MustGet(ctx)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := v.Get(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package leaktest detects the goroutines, tickers and timers a test leaves
+// behind.
+package leaktest
+
+import (
+	"bytes"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// DefaultIgnores are the stack substrings of goroutines never reported: the
+// test framework itself and known background goroutines of drivers, started
+// once per process.
+var DefaultIgnores = []string{
+	"testing.(*T).Run",
+	"testing.tRunner(",
+	"testing.runTests",
+	"testing.(*M).",
+	"os/signal.signal_recv",
+	"go.opencensus.io/stats/view.(*worker).start",
+	"github.com/golang/glog.(*fileSink).flushDaemon",
+	"k8s.io/klog/v2.(*flushDaemon).run",
+}
+
+type options struct {
+	ignores []string
+	timeout time.Duration
+}
+
+type Option func(*options)
+
+// Ignore adds stack substrings of goroutines not to report, typically the
+// background loop of a driver the test does not own.
+func Ignore(patterns ...string) Option {
+	return func(o *options) {
+		o.ignores = append(o.ignores, patterns...)
+	}
+}
+
+// WithTimeout sets how long goroutines are given to exit after the test,
+// 2 seconds by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// Check snapshots the running goroutines and fails t at cleanup with the
+// stacks of the goroutines started since, and of the tracked tickers and
+// timers created since and not stopped. Call it first in the test so that it
+// runs after the other cleanups. It cannot tell tests apart: do not use it in
+// tests running in parallel.
+func Check(t testing.TB, opts ...Option) {
+	t.Helper()
+	o := &options{ignores: DefaultIgnores, timeout: 2 * time.Second}
+	for _, opt := range opts {
+		opt(o)
+	}
+	before := make(map[uint64]struct{})
+	for _, g := range goroutines() {
+		before[g.id] = struct{}{}
+	}
+	since := trackedSeq()
+	t.Cleanup(func() {
+		var leaked []goroutine
+		deadline := time.Now().Add(o.timeout)
+		for {
+			leaked = leaked[:0]
+			for _, g := range goroutines() {
+				if _, ok := before[g.id]; !ok && !g.ignored(o.ignores) {
+					leaked = append(leaked, g)
+				}
+			}
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		for _, g := range leaked {
+			t.Errorf("leaked goroutine %d:\n%s", g.id, g.stack)
+		}
+		for _, r := range unstopped(since) {
+			t.Errorf("leaked %s created at:\n%s", r.kind, r.stack)
+		}
+	})
+}
+
+type goroutine struct {
+	id    uint64
+	stack string
+}
+
+func (g goroutine) ignored(patterns []string) bool {
+	for _, p := range patterns {
+		if strings.Contains(g.stack, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// goroutines returns the stacks of all goroutines but the calling one.
+func goroutines() []goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var res []goroutine
+	for i, block := range bytes.Split(buf, []byte("\n\n")) {
+		if i == 0 {
+			continue // the goroutine calling runtime.Stack comes first
+		}
+		header, _, _ := bytes.Cut(block, []byte("\n"))
+		fields := bytes.Fields(header)
+		if len(fields) < 2 || string(fields[0]) != "goroutine" {
+			continue
+		}
+		id, err := strconv.ParseUint(string(fields[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		res = append(res, goroutine{id: id, stack: string(block)})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].id < res[j].id })
+	return res
+}
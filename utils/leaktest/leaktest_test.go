@@ -0,0 +1,103 @@
+package leaktest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder is a testing.TB collecting the failures of Check.
+type recorder struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Cleanup(fn func()) { r.cleanups = append(r.cleanups, fn) }
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func leakingWorker(stop chan struct{}) {
+	<-stop
+}
+
+func TestDetectsLeakedGoroutine(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	r := &recorder{}
+	Check(r, WithTimeout(50*time.Millisecond))
+	go leakingWorker(stop)
+	r.finish()
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "leakingWorker") {
+		t.Fatalf("errors = %q, want the leaked worker stack", r.errors)
+	}
+}
+
+func TestIgnoreAndExitedGoroutines(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	r := &recorder{}
+	Check(r, WithTimeout(time.Second), Ignore("leaktest.leakingWorker"))
+	go leakingWorker(stop)
+	// Exits after the test body, within the timeout.
+	done := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(done)
+	}()
+	r.finish()
+	<-done
+	if len(r.errors) != 0 {
+		t.Fatalf("unexpected reports %q", r.errors)
+	}
+}
+
+func TestDetectsUnstoppedTickersAndTimers(t *testing.T) {
+	r := &recorder{}
+	Check(r, WithTimeout(10*time.Millisecond))
+	leaked := NewTrackedTicker(time.Hour)
+	defer leaked.Stop()
+	NewTrackedTicker(time.Hour).Stop()
+	pending := NewTrackedTimer(time.Hour)
+	defer pending.Stop()
+	fired := NewTrackedTimer(time.Millisecond)
+	<-fired.C
+	stopped := NewTrackedTimer(time.Hour)
+	stopped.Stop()
+	r.finish()
+	if len(r.errors) != 2 || !strings.HasPrefix(r.errors[0], "leaked ticker") || !strings.HasPrefix(r.errors[1], "leaked timer") {
+		t.Fatalf("errors = %q, want one ticker and one timer", r.errors)
+	}
+	if !strings.Contains(r.errors[0], "TestDetectsUnstoppedTickersAndTimers") {
+		t.Errorf("report misses the creation stack: %s", r.errors[0])
+	}
+
+	// Trackers created before Check belong to someone else.
+	r = &recorder{}
+	Check(r, WithTimeout(10*time.Millisecond))
+	r.finish()
+	if len(r.errors) != 0 {
+		t.Fatalf("reported trackers created before Check: %q", r.errors)
+	}
+}
+
+func TestCheckPasses(t *testing.T) {
+	Check(t)
+	done := make(chan struct{})
+	go close(done)
+	<-done
+	ticker := NewTrackedTicker(time.Millisecond)
+	<-ticker.C
+	ticker.Stop()
+}
@@ -0,0 +1,126 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaktest
+
+import (
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+var registry = struct {
+	sync.Mutex
+	seq     uint64
+	running map[*tracked]struct{}
+}{running: make(map[*tracked]struct{})}
+
+type tracked struct {
+	seq   uint64
+	kind  string
+	stack string
+	// deadline is when a timer fires, it no longer needs stopping after it.
+	// Zero for tickers.
+	deadline time.Time
+}
+
+func track(kind string, deadline time.Time) *tracked {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.seq++
+	r := &tracked{seq: registry.seq, kind: kind, stack: string(debug.Stack()), deadline: deadline}
+	registry.running[r] = struct{}{}
+	return r
+}
+
+func (r *tracked) stop() {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.running, r)
+}
+
+func (r *tracked) reset(deadline time.Time) {
+	registry.Lock()
+	defer registry.Unlock()
+	r.deadline = deadline
+	registry.running[r] = struct{}{}
+}
+
+func trackedSeq() uint64 {
+	registry.Lock()
+	defer registry.Unlock()
+	return registry.seq
+}
+
+// unstopped returns the tickers and pending timers created after seq.
+func unstopped(seq uint64) []*tracked {
+	now := time.Now()
+	registry.Lock()
+	defer registry.Unlock()
+	var res []*tracked
+	for r := range registry.running {
+		if !r.deadline.IsZero() && !r.deadline.After(now) {
+			delete(registry.running, r)
+			continue
+		}
+		if r.seq <= seq {
+			continue
+		}
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].seq < res[j].seq })
+	return res
+}
+
+// TrackedTicker is a time.Ticker reported by Check when it is not stopped.
+type TrackedTicker struct {
+	*time.Ticker
+	r *tracked
+}
+
+func NewTrackedTicker(d time.Duration) *TrackedTicker {
+	return &TrackedTicker{Ticker: time.NewTicker(d), r: track("ticker", time.Time{})}
+}
+
+func (t *TrackedTicker) Stop() {
+	t.Ticker.Stop()
+	t.r.stop()
+}
+
+func (t *TrackedTicker) Reset(d time.Duration) {
+	t.Ticker.Reset(d)
+	t.r.reset(time.Time{})
+}
+
+// TrackedTimer is a time.Timer reported by Check when it is neither stopped
+// nor fired.
+type TrackedTimer struct {
+	*time.Timer
+	r *tracked
+}
+
+func NewTrackedTimer(d time.Duration) *TrackedTimer {
+	return &TrackedTimer{Timer: time.NewTimer(d), r: track("timer", time.Now().Add(d))}
+}
+
+func (t *TrackedTimer) Stop() bool {
+	t.r.stop()
+	return t.Timer.Stop()
+}
+
+func (t *TrackedTimer) Reset(d time.Duration) bool {
+	t.r.reset(time.Now().Add(d))
+	return t.Timer.Reset(d)
+}
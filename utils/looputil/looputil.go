@@ -0,0 +1,213 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package looputil runs a function periodically until its context is done.
+package looputil
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/clockutil"
+)
+
+// Overlap decides what happens to the ticks that pass while fn is running.
+type Overlap int
+
+const (
+	// OverlapSkip drops the ticks that pass while fn is running: the next run
+	// waits for the first tick after the end of the previous one.
+	OverlapSkip Overlap = iota
+	// OverlapQueue keeps them: fn runs again right away, once per missed tick,
+	// until the loop catches up. A fn slower than the interval never does.
+	OverlapQueue
+)
+
+// Iteration is reported to the metrics callback after every run of fn.
+type Iteration struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+	// Failures is the number of consecutive failed runs, this one included.
+	Failures int
+	// Interval is the wait until the next tick, widened by the backoff.
+	Interval time.Duration
+}
+
+// Clock abstracts time for tests.
+type Clock = clockutil.Clock
+
+type Option func(*loop)
+
+// WithName names the loop in logs and metrics.
+func WithName(name string) Option {
+	return func(l *loop) {
+		l.name = name
+	}
+}
+
+// WithImmediate runs fn once when the loop starts, instead of after the first
+// interval.
+func WithImmediate() Option {
+	return func(l *loop) {
+		l.immediate = true
+	}
+}
+
+// WithTimeout bounds every run of fn.
+func WithTimeout(timeout time.Duration) Option {
+	return func(l *loop) {
+		l.timeout = timeout
+	}
+}
+
+// WithOverlap sets what happens to the ticks that pass while fn is running,
+// OverlapSkip by default.
+func WithOverlap(overlap Overlap) Option {
+	return func(l *loop) {
+		l.overlap = overlap
+	}
+}
+
+// WithBackoff multiplies the interval by factor after every consecutive
+// failure, up to max. The first success restores the interval.
+func WithBackoff(factor float64, max time.Duration) Option {
+	return func(l *loop) {
+		l.factor = factor
+		l.maxInterval = max
+	}
+}
+
+// WithMetrics sets a callback receiving every iteration.
+func WithMetrics(fn func(Iteration)) Option {
+	return func(l *loop) {
+		l.metrics = fn
+	}
+}
+
+// WithClock replaces the wall clock, for tests.
+func WithClock(clock Clock) Option {
+	return func(l *loop) {
+		l.clock = clock
+	}
+}
+
+type loop struct {
+	name        string
+	interval    time.Duration
+	immediate   bool
+	timeout     time.Duration
+	overlap     Overlap
+	factor      float64
+	maxInterval time.Duration
+	metrics     func(Iteration)
+	clock       Clock
+}
+
+// Every runs fn every interval until ctx is done, and returns the error of the
+// last run, nil if it succeeded or fn never ran. Runs never overlap, see
+// WithOverlap. Errors are logged, and a panic in fn is recovered as
+// errs.ErrPanic and counts as a failure.
+func Every(ctx context.Context, interval time.Duration, fn func(ctx context.Context) error, opts ...Option) error {
+	if interval <= 0 {
+		return errs.ErrArgs.WrapMsg("loop interval must be positive", "interval", interval)
+	}
+	l := &loop{interval: interval, clock: clockutil.Real}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.factor != 0 && (l.factor < 1 || l.maxInterval < interval) {
+		return errs.ErrArgs.WrapMsg("invalid loop backoff", "factor", l.factor, "max", l.maxInterval, "interval", interval)
+	}
+	var (
+		last     error
+		failures int
+	)
+	next := l.clock.Now()
+	if !l.immediate {
+		next = next.Add(interval)
+	}
+	for {
+		if !l.sleepUntil(ctx, next) {
+			return last
+		}
+		start := l.clock.Now()
+		last = l.call(ctx, fn)
+		end := l.clock.Now()
+		if last != nil {
+			failures++
+			log.ZWarn(ctx, "loop iteration failed", last, "name", l.name, "failures", failures)
+		} else {
+			failures = 0
+		}
+		period := l.period(failures)
+		if l.metrics != nil {
+			l.metrics(Iteration{Name: l.name, Start: start, Duration: end.Sub(start), Err: last, Failures: failures, Interval: period})
+		}
+		next = next.Add(period)
+		if l.overlap == OverlapSkip && !next.After(end) {
+			next = next.Add((end.Sub(next)/period + 1) * period)
+		}
+	}
+}
+
+// period returns the interval after failures consecutive failures.
+func (l *loop) period(failures int) time.Duration {
+	if l.factor == 0 || failures == 0 {
+		return l.interval
+	}
+	d := float64(l.interval) * math.Pow(l.factor, float64(failures))
+	if d >= float64(l.maxInterval) {
+		return l.maxInterval
+	}
+	return time.Duration(d)
+}
+
+// sleepUntil waits for the clock to reach at. It returns false when ctx is
+// done.
+func (l *loop) sleepUntil(ctx context.Context, at time.Time) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	d := at.Sub(l.clock.Now())
+	if d <= 0 {
+		return true
+	}
+	timer := l.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C():
+		return true
+	}
+}
+
+func (l *loop) call(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = errs.ErrPanic(r)
+		}
+	}()
+	return fn(ctx)
+}
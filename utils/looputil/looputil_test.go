@@ -0,0 +1,226 @@
+package looputil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/clockutil/clockutiltest"
+	"github.com/openimsdk/tools/utils/leaktest"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type runner struct {
+	t          *testing.T
+	clock      *clockutiltest.Fake
+	iterations chan Iteration
+	stop       func() error
+}
+
+func start(t *testing.T, interval time.Duration, fn func(ctx context.Context, clock *clockutiltest.Fake) error, opts ...Option) *runner {
+	t.Helper()
+	r := &runner{t: t, clock: clockutiltest.NewFake(epoch), iterations: make(chan Iteration, 64)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	opts = append(opts, WithClock(r.clock), WithMetrics(func(it Iteration) { r.iterations <- it }))
+	go func() {
+		done <- Every(ctx, interval, func(ctx context.Context) error { return fn(ctx, r.clock) }, opts...)
+	}()
+	var once sync.Once
+	var err error
+	r.stop = func() error {
+		once.Do(func() {
+			cancel()
+			err = <-done
+		})
+		return err
+	}
+	t.Cleanup(func() { _ = r.stop() })
+	return r
+}
+
+// next drives the clock until the loop reports an iteration.
+func (r *runner) next() Iteration {
+	r.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case it := <-r.iterations:
+			return it
+		default:
+		}
+		if !r.clock.FireNext() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	r.t.Fatal("timed out waiting for an iteration")
+	return Iteration{}
+}
+
+// starts returns the start of the next n iterations, as offsets from epoch.
+func (r *runner) starts(n int) []time.Duration {
+	r.t.Helper()
+	starts := make([]time.Duration, n)
+	for i := range starts {
+		starts[i] = r.next().Start.Sub(epoch)
+	}
+	return starts
+}
+
+func equal(a, b []time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEverySchedule(t *testing.T) {
+	leaktest.Check(t)
+	const s = time.Second
+	for _, tc := range []struct {
+		name      string
+		immediate bool
+		overlap   Overlap
+		want      []time.Duration
+	}{
+		// The second run takes 25s, across the ticks at 20s and 30s.
+		{"skip", false, OverlapSkip, []time.Duration{10 * s, 20 * s, 50 * s, 60 * s}},
+		{"queue", false, OverlapQueue, []time.Duration{10 * s, 20 * s, 45 * s, 45 * s, 50 * s}},
+		{"immediate skip", true, OverlapSkip, []time.Duration{0, 10 * s, 40 * s, 50 * s}},
+		{"immediate queue", true, OverlapQueue, []time.Duration{0, 10 * s, 35 * s, 35 * s, 40 * s}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []Option{WithOverlap(tc.overlap)}
+			if tc.immediate {
+				opts = append(opts, WithImmediate())
+			}
+			runs := 0
+			r := start(t, 10*s, func(ctx context.Context, clock *clockutiltest.Fake) error {
+				if runs++; runs == 2 {
+					clock.Advance(25 * s)
+				}
+				return nil
+			}, opts...)
+			if got := r.starts(len(tc.want)); !equal(got, tc.want) {
+				t.Fatalf("starts = %v, want %v", got, tc.want)
+			}
+			if err := r.stop(); err != nil {
+				t.Fatalf("Every = %v, want nil after a success", err)
+			}
+		})
+	}
+}
+
+func TestEveryBackoff(t *testing.T) {
+	leaktest.Check(t)
+	const s = time.Second
+	for _, overlap := range []Overlap{OverlapSkip, OverlapQueue} {
+		runs := 0
+		r := start(t, 10*s, func(ctx context.Context, clock *clockutiltest.Fake) error {
+			if runs++; runs <= 3 {
+				return errors.New("down")
+			}
+			return nil
+		}, WithBackoff(2, 30*s), WithOverlap(overlap))
+		var starts, intervals []time.Duration
+		var failures []int
+		for i := 0; i < 5; i++ {
+			it := r.next()
+			starts = append(starts, it.Start.Sub(epoch))
+			intervals = append(intervals, it.Interval)
+			failures = append(failures, it.Failures)
+		}
+		if want := []time.Duration{10 * s, 30 * s, 60 * s, 90 * s, 100 * s}; !equal(starts, want) {
+			t.Errorf("overlap %d: starts = %v, want %v", overlap, starts, want)
+		}
+		if want := []time.Duration{20 * s, 30 * s, 30 * s, 10 * s, 10 * s}; !equal(intervals, want) {
+			t.Errorf("overlap %d: intervals = %v, want %v", overlap, intervals, want)
+		}
+		if failures[2] != 3 || failures[3] != 0 {
+			t.Errorf("overlap %d: failures = %v", overlap, failures)
+		}
+		_ = r.stop()
+	}
+}
+
+func TestEveryPanicAndTimeout(t *testing.T) {
+	leaktest.Check(t)
+	runs := 0
+	r := start(t, time.Second, func(ctx context.Context, clock *clockutiltest.Fake) error {
+		runs++
+		switch runs {
+		case 1:
+			panic("boom")
+		case 2:
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}, WithImmediate(), WithTimeout(10*time.Millisecond), WithName("sync"))
+	it := r.next()
+	var codeErr errs.CodeError
+	if !errors.As(it.Err, &codeErr) || codeErr.Code() != errs.ServerInternalError || !strings.Contains(it.Err.Error(), "boom") {
+		t.Fatalf("panic iteration err = %v, want ErrPanic", it.Err)
+	}
+	if it.Name != "sync" || it.Failures != 1 {
+		t.Errorf("iteration = %+v", it)
+	}
+	if it = r.next(); !errors.Is(it.Err, context.DeadlineExceeded) || it.Failures != 2 {
+		t.Fatalf("timed out iteration = %+v, want DeadlineExceeded", it)
+	}
+	if it = r.next(); it.Err != nil {
+		t.Fatalf("iteration err = %v, want recovery", it.Err)
+	}
+}
+
+func TestEveryReturnsLastError(t *testing.T) {
+	leaktest.Check(t)
+	boom := errors.New("boom")
+	r := start(t, time.Second, func(ctx context.Context, clock *clockutiltest.Fake) error { return boom })
+	r.next()
+	if err := r.stop(); err != boom {
+		t.Fatalf("Every = %v, want the last error", err)
+	}
+	r = start(t, time.Second, func(ctx context.Context, clock *clockutiltest.Fake) error { return boom })
+	if err := r.stop(); err != nil {
+		t.Fatalf("Every = %v, want nil when fn never ran", err)
+	}
+}
+
+func TestEveryRealClock(t *testing.T) {
+	leaktest.Check(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	err := Every(ctx, time.Millisecond, func(ctx context.Context) error {
+		if runs++; runs == 3 {
+			cancel()
+		}
+		return nil
+	}, WithImmediate())
+	if err != nil || runs != 3 {
+		t.Fatalf("Every = %v after %d runs, want nil after 3", err, runs)
+	}
+}
+
+func TestEveryInvalid(t *testing.T) {
+	fn := func(ctx context.Context) error { return nil }
+	for name, err := range map[string]error{
+		"interval": Every(context.Background(), 0, fn),
+		"factor":   Every(context.Background(), time.Second, fn, WithBackoff(0.5, time.Minute)),
+		"max":      Every(context.Background(), time.Second, fn, WithBackoff(2, time.Millisecond)),
+	} {
+		if !errors.Is(err, errs.ErrArgs) {
+			t.Errorf("%s: err = %v, want ErrArgs", name, err)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"time"
+
+	"github.com/openimsdk/tools/utils/termutil"
 )
 
 const (
@@ -13,44 +15,59 @@ const (
 	ColorReset = "\033[0m"
 )
 
+// Prefixes of the messages written to terminals without colors.
+const (
+	PrefixInfo    = "[INFO] "
+	PrefixSuccess = "[OK] "
+	PrefixError   = "[ERROR] "
+)
+
+// paint colors message when f renders colors, and prefixes it otherwise.
+func paint(f *os.File, color, prefix, message string) string {
+	if termutil.ColorEnabled(f) {
+		return color + message + ColorReset
+	}
+	return prefix + message
+}
+
 func PrintBlueTwoLine(message string) {
 	currentTime := time.Now().Format("[2006-01-02 15:04:05 MST]")
 	fmt.Println(currentTime)
-	fmt.Printf("%s%s%s\n", ColorBlue, message, ColorReset)
+	fmt.Println(paint(os.Stdout, ColorBlue, PrefixInfo, message))
 }
 
 func PrintBlue(message string) {
 	currentTime := time.Now().Format("[2006-01-02 15:04:05 MST]")
-	fmt.Printf("%s %s%s%s\n", currentTime, ColorBlue, message, ColorReset)
+	fmt.Println(currentTime, paint(os.Stdout, ColorBlue, PrefixInfo, message))
 }
 
 func PrintGreenTwoLine(message string) {
 	currentTime := time.Now().Format("[2006-01-02 15:04:05 MST]")
 	fmt.Println(currentTime)
-	fmt.Printf("%s%s%s\n", ColorGreen, message, ColorReset)
+	fmt.Println(paint(os.Stdout, ColorGreen, PrefixSuccess, message))
 }
 
 func PrintGreen(message string) {
 	currentTime := time.Now().Format("[2006-01-02 15:04:05 MST]")
-	fmt.Printf("%s %s%s%s\n", currentTime, ColorGreen, message, ColorReset)
+	fmt.Println(currentTime, paint(os.Stdout, ColorGreen, PrefixSuccess, message))
 }
 
 func PrintRed(message string) {
 	currentTime := time.Now().Format("[2006-01-02 15:04:05 MST]")
-	fmt.Printf("%s %s%s%s\n", currentTime, ColorRed, message, ColorReset)
+	fmt.Println(currentTime, paint(os.Stdout, ColorRed, PrefixError, message))
 }
 
 func PrintRedNoTimeStamp(message string) {
-	fmt.Printf("%s%s%s\n", ColorRed, message, ColorReset)
+	fmt.Println(paint(os.Stdout, ColorRed, PrefixError, message))
 }
 
 func PrintGreenNoTimeStamp(message string) {
-	fmt.Printf("%s%s%s\n", ColorGreen, message, ColorReset)
+	fmt.Println(paint(os.Stdout, ColorGreen, PrefixSuccess, message))
 }
 
 func PrintRedToStdErr(a ...interface{}) (n int, err error) {
-	return fmt.Fprint(os.Stderr, "\033[31m", fmt.Sprint(a...), "\033[0m")
+	return fmt.Fprint(os.Stderr, paint(os.Stderr, "\033[31m", PrefixError, fmt.Sprint(a...)))
 }
 func PrintGreenToStdOut(a ...interface{}) (n int, err error) {
-	return fmt.Fprint(os.Stdout, "\033[32m", fmt.Sprint(a...), "\033[0m")
+	return fmt.Fprint(os.Stdout, paint(os.Stdout, "\033[32m", PrefixSuccess, fmt.Sprint(a...)))
 }
@@ -0,0 +1,112 @@
+package network
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// happyEyeballsDelay is how long a dial waits on the preferred address family
+// before racing the other one (RFC 6555).
+const happyEyeballsDelay = 300 * time.Millisecond
+
+// SplitHostPort is net.SplitHostPort for configuration values. It rejects
+// unbracketed IPv6 literals such as fd00::1:6379, whose port cannot be told
+// apart from the address, and invalid ports, with errs.ErrConfig.
+func SplitHostPort(addr string) (host, port string, err error) {
+	addr = strings.TrimSpace(addr)
+	if strings.Count(addr, ":") > 1 && !strings.HasPrefix(addr, "[") {
+		return "", "", errs.ErrConfig.WrapMsg("IPv6 address must be bracketed, e.g. [fd00::1]:6379", "addr", addr)
+	}
+	host, port, err = net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", errs.ErrConfig.WrapMsg("address must be host:port", "addr", addr, "err", err.Error())
+	}
+	if host == "" {
+		return "", "", errs.ErrConfig.WrapMsg("address has no host", "addr", addr)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return "", "", errs.ErrConfig.WrapMsg("invalid port", "addr", addr)
+	}
+	return host, port, nil
+}
+
+// SplitHostPortList splits a comma separated list of addresses, validating
+// each with SplitHostPort. Entries are returned as canonical host:port, with
+// IPv6 hosts bracketed. Empty entries are ignored.
+func SplitHostPortList(list string) ([]string, error) {
+	var addrs []string
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		host, port, err := SplitHostPort(item)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, net.JoinHostPort(host, port))
+	}
+	return addrs, nil
+}
+
+// NormalizeAddrs applies SplitHostPortList to every element of a configured
+// address list, so both ["a:1", "b:2"] and ["a:1,b:2"] are accepted.
+func NormalizeAddrs(addrs []string) ([]string, error) {
+	var res []string
+	for _, item := range addrs {
+		list, err := SplitHostPortList(item)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, list...)
+	}
+	if len(res) == 0 {
+		return nil, errs.ErrConfig.WrapMsg("address list is empty")
+	}
+	return res, nil
+}
+
+// ValidateHost checks the host of a URL, for which the port is optional.
+func ValidateHost(hostport string) error {
+	if hostport == "" {
+		return errs.ErrConfig.WrapMsg("host is empty")
+	}
+	if strings.HasPrefix(hostport, "[") {
+		if strings.HasSuffix(hostport, "]") {
+			if net.ParseIP(hostport[1:len(hostport)-1]) == nil {
+				return errs.ErrConfig.WrapMsg("invalid bracketed IPv6 address", "host", hostport)
+			}
+			return nil
+		}
+		_, _, err := SplitHostPort(hostport)
+		return err
+	}
+	switch strings.Count(hostport, ":") {
+	case 0:
+		return nil
+	case 1:
+		_, _, err := SplitHostPort(hostport)
+		return err
+	}
+	return errs.ErrConfig.WrapMsg("IPv6 address must be bracketed, e.g. [fd00::1]:9000", "host", hostport)
+}
+
+// ProbeTCP checks that addr accepts TCP connections. Host names resolving to
+// both A and AAAA records are dialed happy-eyeballs style: the first family
+// returned by the resolver is tried first and the other one joins the race
+// after a short delay.
+func ProbeTCP(ctx context.Context, addr string, timeout time.Duration) error {
+	if _, _, err := SplitHostPort(addr); err != nil {
+		return err
+	}
+	d := net.Dialer{Timeout: timeout, FallbackDelay: happyEyeballsDelay}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return errs.WrapMsg(err, "tcp probe failed", "addr", addr)
+	}
+	return conn.Close()
+}
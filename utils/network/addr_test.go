@@ -0,0 +1,155 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+func isConfigErr(err error) bool {
+	var codeErr errs.CodeError
+	return errors.As(err, &codeErr) && codeErr.Code() == errs.ConfigError
+}
+
+// listenLoopback listens on the loopback address of the given network, or
+// skips the test when the family is not available.
+func listenLoopback(t *testing.T, network string) net.Listener {
+	t.Helper()
+	addr := "127.0.0.1:0"
+	if network == "tcp6" {
+		addr = "[::1]:0"
+	}
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		t.Skipf("%s loopback unavailable: %v", network, err)
+	}
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	return lis
+}
+
+func TestSplitHostPort(t *testing.T) {
+	tests := []struct {
+		addr, host, port string
+		ok               bool
+	}{
+		{"127.0.0.1:6379", "127.0.0.1", "6379", true},
+		{" redis.local:6379 ", "redis.local", "6379", true},
+		{"[fd00::1]:9000", "fd00::1", "9000", true},
+		{"[::1]:2181", "::1", "2181", true},
+		{"[fe80::1%eth0]:9092", "fe80::1%eth0", "9092", true},
+		{"fd00::1:9000", "", "", false},
+		{"::1", "", "", false},
+		{"[fd00::1]", "", "", false},
+		{"127.0.0.1", "", "", false},
+		{":6379", "", "", false},
+		{"redis:0", "", "", false},
+		{"redis:65536", "", "", false},
+		{"redis:port", "", "", false},
+	}
+	for _, tt := range tests {
+		host, port, err := SplitHostPort(tt.addr)
+		if !tt.ok {
+			if !isConfigErr(err) {
+				t.Errorf("SplitHostPort(%q) err = %v, want ErrConfig", tt.addr, err)
+			}
+			continue
+		}
+		if err != nil || host != tt.host || port != tt.port {
+			t.Errorf("SplitHostPort(%q) = %q, %q, %v", tt.addr, host, port, err)
+		}
+	}
+}
+
+func TestSplitHostPortList(t *testing.T) {
+	got, err := SplitHostPortList("10.0.0.1:6379, [fd00::1]:6380,,redis-2:6381")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1:6379", "[fd00::1]:6380", "redis-2:6381"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := SplitHostPortList("10.0.0.1:6379,fd00::1:6380"); !isConfigErr(err) {
+		t.Errorf("unbracketed v6 in list: err = %v", err)
+	}
+}
+
+func TestNormalizeAddrs(t *testing.T) {
+	got, err := NormalizeAddrs([]string{"[::1]:9092,[::2]:9092", "kafka:9092"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"[::1]:9092", "[::2]:9092", "kafka:9092"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := NormalizeAddrs(nil); !isConfigErr(err) {
+		t.Errorf("empty list: err = %v", err)
+	}
+	if _, err := NormalizeAddrs([]string{"fd00::1:9092"}); !isConfigErr(err) {
+		t.Errorf("unbracketed v6: err = %v", err)
+	}
+}
+
+func TestValidateHost(t *testing.T) {
+	for host, ok := range map[string]bool{
+		"minio":          true,
+		"minio:9000":     true,
+		"10.0.0.1":       true,
+		"10.0.0.1:9000":  true,
+		"[fd00::1]":      true,
+		"[fd00::1]:9000": true,
+		"fd00::1:9000":   false,
+		"fd00::1":        false,
+		"[zz::1]":        false,
+		"minio:port":     false,
+		"":               false,
+	} {
+		if err := ValidateHost(host); (err == nil) != ok {
+			t.Errorf("ValidateHost(%q) = %v, want ok=%v", host, err, ok)
+		}
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	ctx := context.Background()
+	for _, network := range []string{"tcp4", "tcp6"} {
+		t.Run(network, func(t *testing.T) {
+			lis := listenLoopback(t, network)
+			if err := ProbeTCP(ctx, lis.Addr().String(), time.Second); err != nil {
+				t.Fatalf("ProbeTCP(%s): %v", lis.Addr(), err)
+			}
+		})
+	}
+	t.Run("hostname", func(t *testing.T) {
+		lis := listenLoopback(t, "tcp4")
+		_, port, _ := net.SplitHostPort(lis.Addr().String())
+		if err := ProbeTCP(ctx, net.JoinHostPort("localhost", port), time.Second); err != nil {
+			t.Fatalf("ProbeTCP(localhost): %v", err)
+		}
+	})
+	t.Run("closed", func(t *testing.T) {
+		lis := listenLoopback(t, "tcp4")
+		addr := lis.Addr().String()
+		_ = lis.Close()
+		if err := ProbeTCP(ctx, addr, time.Second); err == nil {
+			t.Fatal("probe of closed port succeeded")
+		}
+	})
+	if err := ProbeTCP(ctx, "::1:80", time.Second); !isConfigErr(err) {
+		t.Errorf("unbracketed v6: err = %v", err)
+	}
+}
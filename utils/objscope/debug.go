@@ -0,0 +1,41 @@
+//go:build objscope_debug
+
+package objscope
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+const debug = true
+
+// Guard marks an object as released when it is embedded in a registered type.
+type Guard struct {
+	poisoned atomic.Bool
+}
+
+// Check panics if the object embedding g was released with its scope.
+func (g *Guard) Check() {
+	if g.poisoned.Load() {
+		panic("objscope: object used after its scope was released")
+	}
+}
+
+func poison(obj any) {
+	if g, ok := obj.(guarded); ok {
+		g.objscopeGuard().poisoned.Store(true)
+	}
+}
+
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
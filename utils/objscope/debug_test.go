@@ -0,0 +1,45 @@
+//go:build objscope_debug
+
+package objscope
+
+import (
+	"context"
+	"testing"
+)
+
+func mustPanic(t *testing.T, what string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s did not panic", what)
+		}
+	}()
+	f()
+}
+
+func TestGuardAfterRelease(t *testing.T) {
+	scope := New(context.Background())
+	m := Get[msgMeta](scope)
+	m.Check()
+	scope.Release()
+	mustPanic(t, "Check after Release", m.Check)
+	mustPanic(t, "Get after Release", func() { Get[msgMeta](scope) })
+
+	// Released objects are never handed out again.
+	scope = New(context.Background())
+	defer scope.Release()
+	if Get[msgMeta](scope) == m {
+		t.Fatal("released object reused")
+	}
+}
+
+func TestScopeOtherGoroutine(t *testing.T) {
+	scope := New(context.Background())
+	defer scope.Release()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mustPanic(t, "Get from another goroutine", func() { Get[seqRange](scope) })
+	}()
+	<-done
+}
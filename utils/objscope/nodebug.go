@@ -0,0 +1,17 @@
+//go:build !objscope_debug
+
+package objscope
+
+const debug = false
+
+// Guard marks an object as released when it is embedded in a registered type.
+// It is empty unless built with the objscope_debug tag.
+type Guard struct{}
+
+// Check panics if the object embedding g was released with its scope. It does
+// nothing unless built with the objscope_debug tag.
+func (*Guard) Check() {}
+
+func poison(any) {}
+
+func goroutineID() uint64 { return 0 }
@@ -0,0 +1,177 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package objscope recycles the small structs allocated while handling one
+// request or message. Objects taken from a Scope with Get are returned to
+// per-type pools all at once by Release, instead of one Put per object:
+//
+//	scope := objscope.New(ctx)
+//	defer scope.Release()
+//	meta := objscope.Get[MsgMeta](scope)
+//
+// Only types registered with Register are recycled; Get allocates the others.
+// A Scope belongs to the goroutine that created it and must not be shared with
+// helper goroutines, and nothing taken from it may be used after Release.
+// Builds with the objscope_debug tag detect both: the scope then panics when
+// used by another goroutine or after Release, released objects are never
+// reused, and objects embedding Guard panic on Check.
+package objscope
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// releaser returns an object of its type to the pool.
+type releaser interface {
+	release(obj any)
+}
+
+type typePool[T any] struct {
+	pool  sync.Pool
+	reset func(*T)
+}
+
+func (p *typePool[T]) get() *T {
+	if v, _ := p.pool.Get().(*T); v != nil {
+		return v
+	}
+	return new(T)
+}
+
+func (p *typePool[T]) release(obj any) {
+	v := obj.(*T)
+	p.reset(v)
+	if debug {
+		poison(v)
+		return
+	}
+	p.pool.Put(v)
+}
+
+// types maps the registered types to their *typePool.
+var types sync.Map
+
+// Register lets Get recycle objects of type T. reset must zero the object,
+// or at least every field its users rely on being empty, and drop its
+// references to other memory. Register is meant to be called from init and
+// panics if T is already registered.
+func Register[T any](reset func(*T)) {
+	if reset == nil {
+		panic("objscope: nil reset function")
+	}
+	t := reflect.TypeFor[T]()
+	if _, loaded := types.LoadOrStore(t, &typePool[T]{reset: reset}); loaded {
+		panic(fmt.Sprintf("objscope: %s registered twice", t))
+	}
+}
+
+type tracked struct {
+	pool releaser
+	obj  any
+}
+
+// Scope tracks the objects taken by Get until Release.
+type Scope struct {
+	ctx      context.Context
+	objs     []tracked
+	released bool
+	owner    uint64
+}
+
+var scopes = sync.Pool{New: func() any { return new(Scope) }}
+
+// New returns an empty scope for the request of ctx.
+func New(ctx context.Context) *Scope {
+	s := scopes.Get().(*Scope)
+	s.ctx = ctx
+	s.released = false
+	if debug {
+		s.owner = goroutineID()
+	}
+	return s
+}
+
+func (s *Scope) check() {
+	if s.released {
+		panic("objscope: scope used after Release")
+	}
+	if debug && s.owner != goroutineID() {
+		panic("objscope: scope used by another goroutine than the one that created it")
+	}
+}
+
+// Get returns a zeroed *T, reused from an earlier scope when T is registered,
+// newly allocated otherwise. A nil scope always allocates, so helpers work with
+// and without one, see FromContext.
+func Get[T any](s *Scope) *T {
+	if s == nil {
+		return new(T)
+	}
+	s.check()
+	p, ok := types.Load(reflect.TypeFor[T]())
+	if !ok {
+		return new(T)
+	}
+	pool := p.(*typePool[T])
+	v := pool.get()
+	s.objs = append(s.objs, tracked{pool: pool, obj: v})
+	return v
+}
+
+// Len returns the number of objects Release will recycle.
+func (s *Scope) Len() int {
+	return len(s.objs)
+}
+
+// Release resets the objects taken by Get and returns them to their pools,
+// then recycles the scope itself. Neither may be used afterwards.
+func (s *Scope) Release() {
+	s.check()
+	for i, t := range s.objs {
+		t.pool.release(t.obj)
+		s.objs[i] = tracked{}
+	}
+	s.objs = s.objs[:0]
+	s.ctx = nil
+	s.released = true
+	if !debug {
+		scopes.Put(s)
+	}
+}
+
+type scopeKey struct{}
+
+// Context returns the context of the scope carrying the scope, for FromContext.
+func (s *Scope) Context() context.Context {
+	s.check()
+	return context.WithValue(s.ctx, scopeKey{}, s)
+}
+
+// FromContext returns the scope carried by ctx, nil if none.
+func FromContext(ctx context.Context) *Scope {
+	s, _ := ctx.Value(scopeKey{}).(*Scope)
+	return s
+}
+
+// guarded is implemented by the types embedding Guard.
+type guarded interface {
+	objscopeGuard() *Guard
+}
+
+func (g *Guard) objscopeGuard() *Guard {
+	return g
+}
@@ -0,0 +1,166 @@
+package objscope
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// msgMeta, pushTarget and seqRange mimic the per-message structs of
+// msgtransfer.
+type msgMeta struct {
+	Guard
+	ConversationID string
+	SendID         string
+	Seq            int64
+	SendTime       int64
+	SessionType    int32
+	ContentType    int32
+	Options        map[string]bool
+}
+
+type pushTarget struct {
+	UserID     string
+	PlatformID int32
+	Online     bool
+}
+
+type seqRange struct {
+	Begin, End int64
+}
+
+type unregistered struct {
+	N int
+}
+
+func init() {
+	Register(func(m *msgMeta) { *m = msgMeta{} })
+	Register(func(p *pushTarget) { *p = pushTarget{} })
+	Register(func(r *seqRange) { *r = seqRange{} })
+}
+
+func TestScope(t *testing.T) {
+	scope := New(context.Background())
+	m := Get[msgMeta](scope)
+	m.ConversationID = "si_1_2"
+	m.Seq = 7
+	Get[pushTarget](scope).UserID = "1"
+	if u := Get[unregistered](scope); u == nil || u.N != 0 {
+		t.Fatalf("unregistered = %v", u)
+	}
+	if n := scope.Len(); n != 2 {
+		t.Fatalf("Len = %d, want 2 registered objects", n)
+	}
+	scope.Release()
+
+	// Recycled objects, if any, come back zeroed.
+	scope = New(context.Background())
+	defer scope.Release()
+	for i := 0; i < 10; i++ {
+		m := Get[msgMeta](scope)
+		if m.ConversationID != "" || m.Seq != 0 {
+			t.Fatalf("object not reset: %+v", m)
+		}
+		if p := Get[pushTarget](scope); p.UserID != "" {
+			t.Fatalf("object not reset: %+v", p)
+		}
+	}
+}
+
+func TestNilScope(t *testing.T) {
+	if m := Get[msgMeta](nil); m == nil {
+		t.Fatal("nil scope returned nil")
+	}
+	if FromContext(context.Background()) != nil {
+		t.Fatal("scope found in empty context")
+	}
+}
+
+func TestContext(t *testing.T) {
+	type key struct{}
+	parent := context.WithValue(context.Background(), key{}, "v")
+	scope := New(parent)
+	defer scope.Release()
+	ctx := scope.Context()
+	if FromContext(ctx) != scope || ctx.Value(key{}) != "v" {
+		t.Fatal("context does not carry the scope and the parent values")
+	}
+}
+
+func TestUseAfterRelease(t *testing.T) {
+	scope := New(context.Background())
+	scope.Release()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Release twice did not panic")
+		}
+	}()
+	scope.Release()
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("second Register did not panic")
+		}
+	}()
+	Register(func(r *seqRange) {})
+}
+
+// TestConcurrentScopes runs one scope per goroutine, the supported use, so
+// that the race detector checks the pools shared between scopes.
+func TestConcurrentScopes(t *testing.T) {
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				scope := New(context.Background())
+				m := Get[msgMeta](scope)
+				if m.SendID != "" {
+					t.Errorf("object shared between scopes: %+v", m)
+				}
+				m.SendID = strconv.Itoa(g)
+				Get[seqRange](scope).End = int64(i)
+				scope.Release()
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+var sink *msgMeta
+
+// handleMessage allocates the structs of one message the way msgtransfer
+// does: one metadata struct, a seq range and a push target per recipient.
+func handleMessage(scope *Scope) {
+	m := Get[msgMeta](scope)
+	m.ConversationID = "sg_1"
+	m.Seq = 1
+	r := Get[seqRange](scope)
+	r.Begin, r.End = 1, 2
+	for i := 0; i < 4; i++ {
+		p := Get[pushTarget](scope)
+		p.PlatformID = int32(i)
+	}
+	sink = m
+}
+
+func BenchmarkMessageAlloc(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		handleMessage(nil)
+	}
+}
+
+func BenchmarkMessageScope(b *testing.B) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		scope := New(ctx)
+		handleMessage(scope)
+		scope.Release()
+	}
+}
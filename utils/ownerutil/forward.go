@@ -0,0 +1,163 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ownerutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
+)
+
+const (
+	defaultQueueSize = 1024
+	defaultTimeout   = 5 * time.Second
+)
+
+// Event is the JSON body posted to an owner's webhook.
+type Event struct {
+	Owner       string    `json:"owner"`
+	Message     string    `json:"message"`
+	Error       string    `json:"error,omitempty"`
+	OperationID string    `json:"operationID,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Forwarder posts errors logged at Error level to the webhook of the team
+// owning them. Events are sent from a background goroutine; when the queue is
+// full new events are dropped rather than blocking the logger.
+type Forwarder struct {
+	registry *Registry
+	webhooks map[string]string
+	client   *http.Client
+	queue    chan forwardItem
+	dropped  atomic.Uint64
+	remove   func()
+	lock     sync.RWMutex
+	closed   bool
+	done     chan struct{}
+}
+
+type forwardItem struct {
+	url   string
+	event Event
+}
+
+type ForwarderOption func(f *Forwarder)
+
+// WithRegistry uses r instead of the default registry.
+func WithRegistry(r *Registry) ForwarderOption {
+	return func(f *Forwarder) {
+		f.registry = r
+	}
+}
+
+// WithHTTPClient sets the client used to post events.
+func WithHTTPClient(client *http.Client) ForwarderOption {
+	return func(f *Forwarder) {
+		f.client = client
+	}
+}
+
+// WithQueueSize sets how many events may wait to be sent.
+func WithQueueSize(n int) ForwarderOption {
+	return func(f *Forwarder) {
+		if n > 0 {
+			f.queue = make(chan forwardItem, n)
+		}
+	}
+}
+
+// NewForwarder registers a log error hook forwarding errors to webhooks,
+// which maps owners to URLs. Errors of owners without a webhook are not sent.
+// Call Close to remove the hook and flush pending events.
+func NewForwarder(webhooks map[string]string, opts ...ForwarderOption) *Forwarder {
+	f := &Forwarder{
+		registry: defaultRegistry,
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: defaultTimeout},
+		queue:    make(chan forwardItem, defaultQueueSize),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	go f.run()
+	f.remove = log.AddErrorHook(f.hook)
+	return f
+}
+
+func (f *Forwarder) hook(ctx context.Context, msg string, err error) {
+	owner := f.registry.Owner(err)
+	url, ok := f.webhooks[owner]
+	if !ok {
+		return
+	}
+	event := Event{Owner: owner, Message: msg, Time: time.Now()}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if ctx != nil {
+		event.OperationID = mcontext.GetOperationID(ctx)
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.closed {
+		return
+	}
+	select {
+	case f.queue <- forwardItem{url: url, event: event}:
+	default:
+		f.dropped.Add(1)
+	}
+}
+
+func (f *Forwarder) run() {
+	defer close(f.done)
+	for item := range f.queue {
+		body, err := json.Marshal(item.event)
+		if err != nil {
+			continue
+		}
+		resp, err := f.client.Post(item.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+	}
+}
+
+// Dropped returns the number of events discarded because the queue was full.
+func (f *Forwarder) Dropped() uint64 {
+	return f.dropped.Load()
+}
+
+// Close removes the log hook and waits until the queued events are sent.
+func (f *Forwarder) Close() {
+	f.remove()
+	f.lock.Lock()
+	if !f.closed {
+		f.closed = true
+		close(f.queue)
+	}
+	f.lock.Unlock()
+	<-f.done
+}
@@ -0,0 +1,160 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ownerutil maps errors to the team owning the code they come from,
+// using the stack trace recorded by the errs package.
+package ownerutil
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// DefaultOwner is returned for errors without a stack or whose frames match no
+// registered prefix, unless changed with SetDefaultOwner.
+const DefaultOwner = "unknown"
+
+type node struct {
+	children map[byte]*node
+	owner    string
+}
+
+// Registry maps Go package path prefixes to owners. It is safe for concurrent use.
+type Registry struct {
+	lock         sync.RWMutex
+	root         node
+	defaultOwner string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{defaultOwner: DefaultOwner}
+}
+
+var defaultRegistry = NewRegistry()
+
+// RegisterOwner assigns the code under pathPrefix, such as
+// "github.com/openimsdk/open-im-server/v3/internal/rpc/msg", to owner in the
+// default registry.
+func RegisterOwner(pathPrefix string, owner string) {
+	defaultRegistry.RegisterOwner(pathPrefix, owner)
+}
+
+// SetDefaultOwner sets the owner of unmatched errors in the default registry.
+func SetDefaultOwner(owner string) {
+	defaultRegistry.SetDefaultOwner(owner)
+}
+
+// Owner returns the owner of err according to the default registry.
+func Owner(err error) string {
+	return defaultRegistry.Owner(err)
+}
+
+// RegisterOwner assigns the code under pathPrefix to owner. A prefix only
+// matches whole path elements: "a/b" matches "a/b.F" and "a/b/c.F" but not
+// "a/bc.F". The longest matching prefix wins.
+func (r *Registry) RegisterOwner(pathPrefix string, owner string) {
+	pathPrefix = strings.TrimSuffix(pathPrefix, "/")
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	n := &r.root
+	for i := 0; i < len(pathPrefix); i++ {
+		if n.children == nil {
+			n.children = make(map[byte]*node)
+		}
+		child, ok := n.children[pathPrefix[i]]
+		if !ok {
+			child = &node{}
+			n.children[pathPrefix[i]] = child
+		}
+		n = child
+	}
+	n.owner = owner
+}
+
+func (r *Registry) SetDefaultOwner(owner string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.defaultOwner = owner
+}
+
+// Owner returns the owner of the innermost frame of err's stack that matches
+// a registered prefix, or the default owner.
+func (r *Registry) Owner(err error) string {
+	var s interface{ Callers() []uintptr }
+	if err == nil || !errors.As(err, &s) {
+		return r.getDefault()
+	}
+	pcs := s.Callers()
+	if len(pcs) == 0 {
+		return r.getDefault()
+	}
+	frames := runtime.CallersFrames(pcs)
+	funcs := make([]string, 0, len(pcs))
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			funcs = append(funcs, frame.Function)
+		}
+		if !more {
+			break
+		}
+	}
+	return r.OwnerOfFrames(funcs)
+}
+
+// OwnerOfFrames returns the owner of the first function, innermost first, that
+// matches a registered prefix. Functions are fully qualified names as reported
+// by runtime.Frame.Function.
+func (r *Registry) OwnerOfFrames(funcs []string) string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, fn := range funcs {
+		if owner, ok := r.match(fn); ok {
+			return owner
+		}
+	}
+	return r.defaultOwner
+}
+
+func (r *Registry) getDefault() string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.defaultOwner
+}
+
+// match returns the owner of the longest prefix of fn ending at a path element
+// boundary.
+func (r *Registry) match(fn string) (string, bool) {
+	var (
+		owner string
+		found bool
+	)
+	n := &r.root
+	for i := 0; i <= len(fn); i++ {
+		if n.owner != "" && (i == len(fn) || fn[i] == '/' || fn[i] == '.') {
+			owner, found = n.owner, true
+		}
+		if i == len(fn) {
+			break
+		}
+		next, ok := n.children[fn[i]]
+		if !ok {
+			break
+		}
+		n = next
+	}
+	return owner, found
+}
@@ -0,0 +1,128 @@
+package ownerutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+)
+
+func newTestRegistry() *Registry {
+	r := NewRegistry()
+	r.RegisterOwner("github.com/openimsdk/open-im-server/v3/internal/rpc/msg", "messaging")
+	r.RegisterOwner("github.com/openimsdk/open-im-server/v3/internal/rpc/group", "groups")
+	r.RegisterOwner("github.com/openimsdk/open-im-server/v3/pkg/common/storage/", "storage")
+	return r
+}
+
+func TestOwnerOfFrames(t *testing.T) {
+	r := newTestRegistry()
+	r.RegisterOwner("github.com/openimsdk/open-im-server/v3/internal/rpc/msg/sync", "sync")
+	tests := []struct {
+		name   string
+		frames []string
+		want   string
+	}{
+		{"innermost wins", []string{
+			"github.com/openimsdk/open-im-server/v3/pkg/common/storage/database/mgo.(*MsgMgo).FindSeqs",
+			"github.com/openimsdk/open-im-server/v3/internal/rpc/msg.(*msgServer).SendMsg",
+		}, "storage"},
+		{"skips unregistered frames", []string{
+			"github.com/openimsdk/tools/errs.Wrap",
+			"go.mongodb.org/mongo-driver/mongo.(*Collection).Find",
+			"github.com/openimsdk/open-im-server/v3/internal/rpc/group.(*groupServer).CreateGroup",
+		}, "groups"},
+		{"longest prefix", []string{"github.com/openimsdk/open-im-server/v3/internal/rpc/msg/sync.Pull"}, "sync"},
+		{"element boundary", []string{"github.com/openimsdk/open-im-server/v3/internal/rpc/msgx.F"}, DefaultOwner},
+		{"unknown", []string{"main.main"}, DefaultOwner},
+		{"empty", nil, DefaultOwner},
+	}
+	for _, tt := range tests {
+		if got := r.OwnerOfFrames(tt.frames); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOwnerFromStack(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterOwner("github.com/openimsdk/tools/utils/ownerutil", "tools")
+	r.SetDefaultOwner("oncall")
+	if got := r.Owner(errs.ErrArgs.Wrap()); got != "tools" {
+		t.Errorf("wrapped error owner %q", got)
+	}
+	if got := r.Owner(errs.ErrArgs); got != "oncall" {
+		t.Errorf("error without stack owner %q", got)
+	}
+	if got := r.Owner(nil); got != "oncall" {
+		t.Errorf("nil error owner %q", got)
+	}
+}
+
+func TestOwnerOfPanic(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterOwner("github.com/openimsdk/tools/utils/ownerutil.panicky", "panics")
+	var err error
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				err = errs.Wrap(errs.ErrPanic(p))
+			}
+		}()
+		panicky()
+	}()
+	if got := r.Owner(err); got != "panics" {
+		t.Fatalf("panic owner %q", got)
+	}
+}
+
+//go:noinline
+func panicky() {
+	var m map[string]int
+	m["x"] = 1
+}
+
+func TestForwarder(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		received = map[string][]Event{}
+	)
+	newWebhook := func(team string) string {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var e Event
+			if err := json.NewDecoder(req.Body).Decode(&e); err != nil {
+				t.Errorf("decode: %v", err)
+			}
+			lock.Lock()
+			received[team] = append(received[team], e)
+			lock.Unlock()
+		}))
+		t.Cleanup(srv.Close)
+		return srv.URL
+	}
+
+	r := NewRegistry()
+	r.RegisterOwner("github.com/openimsdk/tools/utils/ownerutil", "tools")
+	f := NewForwarder(map[string]string{"tools": newWebhook("tools"), DefaultOwner: newWebhook(DefaultOwner)}, WithRegistry(r))
+
+	ctx := context.Background()
+	log.ZError(ctx, "send failed", errs.ErrArgs.Wrap())
+	log.ZError(ctx, "no stack", errs.ErrArgs)
+	log.ZWarn(ctx, "warnings are not forwarded", errs.ErrArgs.Wrap())
+	f.Close()
+	log.ZError(ctx, "after close", errs.ErrArgs.Wrap())
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(received["tools"]) != 1 || received["tools"][0].Message != "send failed" || received["tools"][0].Owner != "tools" {
+		t.Errorf("tools webhook got %+v", received["tools"])
+	}
+	if len(received[DefaultOwner]) != 1 || received[DefaultOwner][0].Message != "no stack" {
+		t.Errorf("default webhook got %+v", received[DefaultOwner])
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package progressutil reports the progress of long-running batch operations,
+// such as imports and migrations, at a bounded rate.
+package progressutil
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/clockutil"
+)
+
+const (
+	DefaultInterval = 10 * time.Second
+	DefaultWindow   = time.Minute
+)
+
+// Report is a snapshot of the progress of an operation.
+type Report struct {
+	Name  string
+	Done  int64
+	Total int64 // zero or negative when unknown
+	// Percent and ETA are zero when the total is unknown. ETA is also zero
+	// while nothing progresses.
+	Percent float64
+	ETA     time.Duration
+	// Rate is in items per second, measured over the sliding window. In the
+	// final report it is the average over the whole operation.
+	Rate    float64
+	Elapsed time.Duration
+	Final   bool
+}
+
+// Clock abstracts time for tests.
+type Clock = clockutil.Clock
+
+type Option func(*Reporter)
+
+// WithName names the operation in reports.
+func WithName(name string) Option {
+	return func(r *Reporter) {
+		r.name = name
+	}
+}
+
+// WithInterval sets how often reports are emitted, DefaultInterval by default.
+func WithInterval(interval time.Duration) Option {
+	return func(r *Reporter) {
+		if interval > 0 {
+			r.interval = interval
+		}
+	}
+}
+
+// WithWindow sets the duration over which the rate and the ETA are computed,
+// DefaultWindow by default.
+func WithWindow(window time.Duration) Option {
+	return func(r *Reporter) {
+		if window > 0 {
+			r.window = window
+		}
+	}
+}
+
+// WithCallback replaces the default callback, which logs every report with
+// log.ZInfo on the context set by WithContext.
+func WithCallback(fn func(Report)) Option {
+	return func(r *Reporter) {
+		r.callback = fn
+	}
+}
+
+// WithContext sets the context the default callback logs with.
+func WithContext(ctx context.Context) Option {
+	return func(r *Reporter) {
+		r.ctx = ctx
+	}
+}
+
+// WithClock replaces the wall clock, for tests.
+func WithClock(clock Clock) Option {
+	return func(r *Reporter) {
+		r.clock = clock
+	}
+}
+
+type sample struct {
+	at   time.Time
+	done int64
+}
+
+// Reporter counts the items processed by an operation and reports the
+// progress periodically from a background goroutine, so that Add costs a
+// single atomic addition. It is safe for concurrent use.
+type Reporter struct {
+	name     string
+	total    int64
+	interval time.Duration
+	window   time.Duration
+	callback func(Report)
+	ctx      context.Context
+	clock    Clock
+
+	done    atomic.Int64
+	start   time.Time
+	samples []sample // owned by the loop until it exits
+
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+	final    Report
+}
+
+// New starts reporting the progress of an operation processing total items.
+// A total of zero or less is unknown: reports then only carry the rate. Done
+// must be called when the operation ends.
+func New(total int64, opts ...Option) *Reporter {
+	r := &Reporter{
+		total:    total,
+		interval: DefaultInterval,
+		window:   DefaultWindow,
+		ctx:      context.Background(),
+		clock:    clockutil.Real,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.callback == nil {
+		r.callback = r.log
+	}
+	r.start = r.clock.Now()
+	r.samples = []sample{{at: r.start}}
+	go r.loop()
+	return r
+}
+
+// Add records n more processed items.
+func (r *Reporter) Add(n int64) {
+	r.done.Add(n)
+}
+
+// Done stops the periodic reports and emits the final summary, which it also
+// returns. Further calls return the same summary.
+func (r *Reporter) Done() Report {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		<-r.stopped
+		now := r.clock.Now()
+		r.final = r.report(now, r.done.Load())
+		r.final.Final = true
+		if elapsed := r.final.Elapsed.Seconds(); elapsed > 0 {
+			r.final.Rate = float64(r.final.Done) / elapsed
+		}
+		r.final.ETA = 0
+		r.callback(r.final)
+	})
+	return r.final
+}
+
+func (r *Reporter) loop() {
+	defer close(r.stopped)
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-r.clock.After(r.interval):
+			report := r.report(now, r.done.Load())
+			r.samples = append(r.samples, sample{at: now, done: report.Done})
+			r.callback(report)
+		}
+	}
+}
+
+// report computes the progress at now. The rate is measured against the
+// oldest sample still inside the window.
+func (r *Reporter) report(now time.Time, done int64) Report {
+	cut := 0
+	for cut < len(r.samples)-1 && now.Sub(r.samples[cut+1].at) >= r.window {
+		cut++
+	}
+	r.samples = r.samples[cut:]
+	rep := Report{Name: r.name, Done: done, Total: r.total, Elapsed: now.Sub(r.start)}
+	if base := r.samples[0]; now.After(base.at) {
+		rep.Rate = float64(done-base.done) / now.Sub(base.at).Seconds()
+	}
+	if r.total > 0 {
+		rep.Percent = float64(done) * 100 / float64(r.total)
+		if remaining := r.total - done; remaining > 0 && rep.Rate > 0 {
+			rep.ETA = time.Duration(float64(remaining) / rep.Rate * float64(time.Second))
+		}
+	}
+	return rep
+}
+
+func (r *Reporter) log(rep Report) {
+	kv := []any{"name", rep.Name, "done", rep.Done, "rate", rep.Rate, "elapsed", rep.Elapsed}
+	if rep.Total > 0 {
+		kv = append(kv, "total", rep.Total, "percent", rep.Percent)
+		if !rep.Final {
+			kv = append(kv, "eta", rep.ETA)
+		}
+	}
+	if rep.Final {
+		log.ZInfo(r.ctx, "progress done", kv...)
+		return
+	}
+	log.ZInfo(r.ctx, "progress", kv...)
+}
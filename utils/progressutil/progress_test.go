@@ -0,0 +1,142 @@
+package progressutil
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/utils/clockutil/clockutiltest"
+)
+
+// advance waits for the reporter to arm its timer, then moves the clock
+// forward.
+func advance(t *testing.T, clock *clockutiltest.Fake, d time.Duration) {
+	t.Helper()
+	clock.WaitBlocked(t, 1)
+	clock.Advance(d)
+}
+
+func newTestReporter(total int64, opts ...Option) (*Reporter, *clockutiltest.Fake, chan Report) {
+	clock := clockutiltest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	reports := make(chan Report, 16)
+	opts = append([]Option{WithClock(clock), WithInterval(10 * time.Second), WithCallback(func(r Report) { reports <- r })}, opts...)
+	return New(total, opts...), clock, reports
+}
+
+func next(t *testing.T, reports chan Report) Report {
+	t.Helper()
+	select {
+	case r := <-reports:
+		return r
+	case <-time.After(time.Second):
+		t.Fatal("no report")
+		return Report{}
+	}
+}
+
+func noReport(t *testing.T, reports chan Report) {
+	t.Helper()
+	select {
+	case r := <-reports:
+		t.Fatalf("unexpected report %+v", r)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestCadence(t *testing.T) {
+	r, clock, reports := newTestReporter(1000, WithName("import"))
+	for i := 0; i < 100; i++ {
+		r.Add(1)
+	}
+	noReport(t, reports)
+	advance(t, clock, 5*time.Second)
+	noReport(t, reports)
+	advance(t, clock, 5*time.Second)
+	rep := next(t, reports)
+	if rep.Name != "import" || rep.Done != 100 || rep.Percent != 10 || rep.Rate != 10 || rep.ETA != 90*time.Second {
+		t.Fatalf("report = %+v", rep)
+	}
+	advance(t, clock, 10*time.Second)
+	if rep := next(t, reports); rep.Done != 100 || rep.Elapsed != 20*time.Second {
+		t.Fatalf("stalled report = %+v", rep)
+	}
+	noReport(t, reports)
+	r.Done()
+}
+
+func TestSlidingWindowETA(t *testing.T) {
+	r, clock, reports := newTestReporter(1000, WithWindow(20*time.Second))
+	for _, n := range []int64{100, 300, 300} {
+		r.Add(n)
+		advance(t, clock, 10*time.Second)
+		next(t, reports)
+	}
+	advance(t, clock, 10*time.Second)
+	rep := next(t, reports)
+	// At t=40s the window starts at t=20s (400 done): 300 items in 20s.
+	if rep.Rate != 15 || rep.ETA != 20*time.Second {
+		t.Errorf("at 40s: rate %v eta %v, want 15/s 20s", rep.Rate, rep.ETA)
+	}
+
+	final := r.Done()
+	if !final.Final || final.Done != 700 || final.Rate != 700.0/40 || final.ETA != 0 || final.Percent != 70 {
+		t.Errorf("final = %+v", final)
+	}
+	if next(t, reports) != final || r.Done() != final {
+		t.Error("final summary not emitted exactly once")
+	}
+	noReport(t, reports)
+}
+
+func TestWindowRate(t *testing.T) {
+	r, clock, reports := newTestReporter(1000, WithWindow(20*time.Second))
+	r.Add(100)
+	advance(t, clock, 10*time.Second)
+	next(t, reports)
+	r.Add(300)
+	advance(t, clock, 10*time.Second)
+	next(t, reports)
+	r.Add(300)
+	advance(t, clock, 10*time.Second)
+	// The window spans from t=10s (100 done) to t=30s (700 done): 30/s, so
+	// the remaining 300 items take 10s. The slow first interval is ignored.
+	rep := next(t, reports)
+	if rep.Rate != 30 || rep.ETA != 10*time.Second {
+		t.Errorf("rate %v eta %v, want 30/s 10s", rep.Rate, rep.ETA)
+	}
+	r.Done()
+}
+
+func TestUnknownTotal(t *testing.T) {
+	r, clock, reports := newTestReporter(0)
+	r.Add(250)
+	advance(t, clock, 10*time.Second)
+	rep := next(t, reports)
+	if rep.Rate != 25 || rep.Percent != 0 || rep.ETA != 0 || rep.Total != 0 {
+		t.Errorf("report = %+v", rep)
+	}
+	if final := r.Done(); final.Done != 250 || final.Rate != 25 {
+		t.Errorf("final = %+v", final)
+	}
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	r, _, _ := newTestReporter(8000)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				r.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := testing.AllocsPerRun(100, func() { r.Add(0) }); n != 0 {
+		t.Errorf("Add allocates %v times", n)
+	}
+	if final := r.Done(); final.Done != 8000 || final.Percent != 100 {
+		t.Errorf("final = %+v", final)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sanitizeutil cleans user supplied rich text before it is stored or
+// sent to web clients.
+package sanitizeutil
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// Policy describes which markup survives sanitization.
+type Policy struct {
+	// Tags maps each allowed element to its allowed attributes. Elements not
+	// listed are removed but their text content is kept.
+	Tags map[string][]string
+	// URLAttrs lists attributes whose values must pass ValidURL.
+	URLAttrs []string
+	// AllowImageDataURI accepts base64 data URIs of raster images in URL
+	// attributes.
+	AllowImageDataURI bool
+	// MaxLen limits the output to MaxLen bytes, 0 means no limit. Truncated
+	// output is still well-formed.
+	MaxLen int
+}
+
+// StrictPolicy keeps text only and escapes every entity.
+func StrictPolicy() *Policy {
+	return &Policy{}
+}
+
+// BasicPolicy allows b, i, u, br and links with http, https or mailto targets.
+func BasicPolicy() *Policy {
+	return &Policy{
+		Tags: map[string][]string{
+			"b":  nil,
+			"i":  nil,
+			"u":  nil,
+			"br": nil,
+			"a":  {"href"},
+		},
+		URLAttrs: []string{"href"},
+	}
+}
+
+func (p *Policy) allowed(tag string) ([]string, bool) {
+	attrs, ok := p.Tags[tag]
+	return attrs, ok
+}
+
+func (p *Policy) isURLAttr(name string) bool {
+	for _, a := range p.URLAttrs {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// voidTags are emitted without a closing tag.
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true, "wbr": true}
+
+// dropContent are elements whose content is removed together with the tags.
+// The tokenizer reads the content of most of them as raw text.
+var dropContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "noembed": true, "noframes": true,
+	"noscript": true, "plaintext": true, "template": true, "textarea": true, "title": true, "xmp": true,
+}
+
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+var imageDataPrefixes = []string{
+	"data:image/png;base64,",
+	"data:image/gif;base64,",
+	"data:image/jpeg;base64,",
+	"data:image/webp;base64,",
+}
+
+// ValidURL reports whether raw is a relative URL or uses the http, https or
+// mailto scheme. If allowImageData is set, base64 encoded png, gif, jpeg and
+// webp data URIs are accepted as well. Whitespace and control characters are
+// ignored when reading the scheme, as browsers do.
+func ValidURL(raw string, allowImageData bool) bool {
+	u := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, raw)
+	i := strings.IndexAny(u, ":/?#")
+	if i < 0 || u[i] != ':' {
+		return true
+	}
+	scheme := strings.ToLower(u[:i])
+	if safeSchemes[scheme] {
+		return true
+	}
+	if scheme != "data" || !allowImageData {
+		return false
+	}
+	lower := strings.ToLower(u)
+	for _, prefix := range imageDataPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return isBase64(u[len(prefix):])
+		}
+	}
+	return false
+}
+
+func isBase64(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '+', c == '/', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// SanitizeHTML returns s reduced to the markup allowed by policy. Text is
+// always escaped, disallowed elements are dropped, and every emitted element is
+// closed. A nil policy is treated as StrictPolicy.
+func SanitizeHTML(s string, policy *Policy) string {
+	if policy == nil {
+		policy = StrictPolicy()
+	}
+	w := &writer{max: policy.MaxLen}
+	z := nethtml.NewTokenizer(strings.NewReader(s))
+	skip := ""
+	for !w.full {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		if skip != "" {
+			if tt == nethtml.EndTagToken && tok.Data == skip {
+				skip = ""
+			}
+			continue
+		}
+		switch tt {
+		case nethtml.TextToken:
+			w.text(tok.Data)
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if dropContent[tok.Data] {
+				if tt == nethtml.StartTagToken {
+					skip = tok.Data
+				}
+				continue
+			}
+			attrs, ok := policy.allowed(tok.Data)
+			if !ok {
+				continue
+			}
+			w.open(tok.Data, filterAttrs(policy, tok.Attr, attrs))
+		case nethtml.EndTagToken:
+			if _, ok := policy.allowed(tok.Data); ok {
+				w.close(tok.Data)
+			}
+		}
+	}
+	return w.finish()
+}
+
+func filterAttrs(policy *Policy, in []nethtml.Attribute, allowed []string) []nethtml.Attribute {
+	var out []nethtml.Attribute
+	for _, a := range in {
+		if a.Namespace != "" || !contains(allowed, a.Key) || containsKey(out, a.Key) {
+			continue
+		}
+		if policy.isURLAttr(a.Key) && !ValidURL(a.Val, policy.AllowImageDataURI) {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsKey(attrs []nethtml.Attribute, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// writer builds the output, keeping room for the closing tags of every open
+// element so that truncation never leaves markup unbalanced.
+type writer struct {
+	buf      strings.Builder
+	stack    []string
+	closeLen int
+	max      int
+	full     bool
+}
+
+func (w *writer) fits(n int) bool {
+	if w.max <= 0 || w.buf.Len()+n+w.closeLen <= w.max {
+		return true
+	}
+	w.full = true
+	return false
+}
+
+func (w *writer) text(s string) {
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		var piece string
+		if r == utf8.RuneError && size == 1 {
+			piece = string(utf8.RuneError)
+		} else {
+			piece = html.EscapeString(s[:size])
+		}
+		if !w.fits(len(piece)) {
+			return
+		}
+		w.buf.WriteString(piece)
+		s = s[size:]
+	}
+}
+
+func (w *writer) open(tag string, attrs []nethtml.Attribute) {
+	var b strings.Builder
+	b.WriteString("<")
+	b.WriteString(tag)
+	for _, a := range attrs {
+		b.WriteString(" ")
+		b.WriteString(a.Key)
+		b.WriteString(`="`)
+		b.WriteString(html.EscapeString(a.Val))
+		b.WriteString(`"`)
+	}
+	b.WriteString(">")
+	closing := 0
+	if !voidTags[tag] {
+		closing = len(tag) + len("</>")
+	}
+	if !w.fits(b.Len() + closing) {
+		return
+	}
+	w.buf.WriteString(b.String())
+	if closing > 0 {
+		w.stack = append(w.stack, tag)
+		w.closeLen += closing
+	}
+}
+
+// close closes tag and every element opened after it. End tags without a
+// matching open element are dropped.
+func (w *writer) close(tag string) {
+	for i := len(w.stack) - 1; i >= 0; i-- {
+		if w.stack[i] == tag {
+			for len(w.stack) > i {
+				w.pop()
+			}
+			return
+		}
+	}
+}
+
+func (w *writer) pop() {
+	tag := w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+	w.closeLen -= len(tag) + len("</>")
+	w.buf.WriteString("</" + tag + ">")
+}
+
+func (w *writer) finish() string {
+	for len(w.stack) > 0 {
+		w.pop()
+	}
+	return w.buf.String()
+}
@@ -0,0 +1,165 @@
+package sanitizeutil
+
+import (
+	"strings"
+	"testing"
+
+	nethtml "golang.org/x/net/html"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *Policy
+		in     string
+		want   string
+	}{
+		{"strict text", StrictPolicy(), `a < b & "c"`, `a &lt; b &amp; &#34;c&#34;`},
+		{"strict tags", StrictPolicy(), `<b>bold</b><br>`, `bold`},
+		{"strict script", StrictPolicy(), `hi<script>alert(1)</script>!`, `hi!`},
+		{"basic keeps", BasicPolicy(), `<b>x</b><i>y</i><u>z</u><br/>`, `<b>x</b><i>y</i><u>z</u><br>`},
+		{"basic drops attrs", BasicPolicy(), `<b onclick="x()" class="c">x</b>`, `<b>x</b>`},
+		{"basic link", BasicPolicy(), `<a href="https://openim.io?a=1&b=2" title="t">go</a>`, `<a href="https://openim.io?a=1&amp;b=2">go</a>`},
+		{"basic relative link", BasicPolicy(), `<a href="/group/1">g</a>`, `<a href="/group/1">g</a>`},
+		{"javascript link", BasicPolicy(), `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"obfuscated scheme", BasicPolicy(), `<a href=" jav&#x09;ascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"entity scheme", BasicPolicy(), `<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"data link", BasicPolicy(), `<a href="data:text/html,<script>">x</a>`, `<a>x</a>`},
+		{"unknown tag", BasicPolicy(), `<div><img src=x onerror=alert(1)>text</div>`, `text`},
+		{"style content", BasicPolicy(), `<style>b{}</style>ok`, `ok`},
+		{"unclosed", BasicPolicy(), `<b><i>x`, `<b><i>x</i></b>`},
+		{"misnested", BasicPolicy(), `<b><i>x</b>y</i>`, `<b><i>x</i></b>y`},
+		{"stray end", BasicPolicy(), `x</b></a>`, `x`},
+		{"comment", BasicPolicy(), `a<!-- <script> -->b`, `ab`},
+		{"nil policy", nil, `<b>x</b>`, `x`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeHTML(tt.in, tt.policy); got != tt.want {
+				t.Errorf("SanitizeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTMLMaxLen(t *testing.T) {
+	p := BasicPolicy()
+	p.MaxLen = 20
+	got := SanitizeHTML(`<b><i>hello world, this is long</i></b>`, p)
+	if got != `<b><i>hello </i></b>` {
+		t.Errorf("got %q", got)
+	}
+	p.MaxLen = 8
+	if got := SanitizeHTML(`a&b&c`, p); got != `a&amp;b` {
+		t.Errorf("entity split: %q", got)
+	}
+	if got := SanitizeHTML(`你好世界`, p); got != `你好` {
+		t.Errorf("rune split: %q", got)
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	tests := []struct {
+		url       string
+		imageData bool
+		want      bool
+	}{
+		{"https://openim.io", false, true},
+		{"HTTP://openim.io", false, true},
+		{"mailto:a@b.c", false, true},
+		{"/path?x=y:z", false, true},
+		{"page#a:b", false, true},
+		{"javascript:alert(1)", false, false},
+		{"JavaScript:alert(1)", false, false},
+		{"\x01javascript:alert(1)", false, false},
+		{"vbscript:x", false, false},
+		{"data:image/png;base64,iVBORw0KGgo=", false, false},
+		{"data:image/png;base64,iVBORw0KGgo=", true, true},
+		{"data:image/svg+xml;base64,PHN2Zz4=", true, false},
+		{"data:image/png;base64,<script>", true, false},
+		{"data:text/html;base64,PHNjcmlwdD4=", true, false},
+	}
+	for _, tt := range tests {
+		if got := ValidURL(tt.url, tt.imageData); got != tt.want {
+			t.Errorf("ValidURL(%q, %v) = %v, want %v", tt.url, tt.imageData, got, tt.want)
+		}
+	}
+}
+
+// checkWellFormed re-tokenizes out and verifies that only allowed elements and
+// attributes appear and that every element is closed in order.
+func checkWellFormed(t *testing.T, p *Policy, out string) {
+	t.Helper()
+	var stack []string
+	z := nethtml.NewTokenizer(strings.NewReader(out))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			attrs, ok := p.allowed(tok.Data)
+			if !ok {
+				t.Fatalf("disallowed tag %q in %q", tok.Data, out)
+			}
+			for _, a := range tok.Attr {
+				if !contains(attrs, a.Key) {
+					t.Fatalf("disallowed attribute %q in %q", a.Key, out)
+				}
+				if p.isURLAttr(a.Key) && !ValidURL(a.Val, p.AllowImageDataURI) {
+					t.Fatalf("unsafe URL %q in %q", a.Val, out)
+				}
+			}
+			if !voidTags[tok.Data] {
+				stack = append(stack, tok.Data)
+			}
+		case nethtml.EndTagToken:
+			if len(stack) == 0 || stack[len(stack)-1] != tok.Data {
+				t.Fatalf("unbalanced </%s> in %q", tok.Data, out)
+			}
+			stack = stack[:len(stack)-1]
+		case nethtml.CommentToken, nethtml.DoctypeToken:
+			t.Fatalf("unexpected %v in %q", tt, out)
+		}
+	}
+	if len(stack) != 0 {
+		t.Fatalf("unclosed %v in %q", stack, out)
+	}
+}
+
+func FuzzSanitizeHTML(f *testing.F) {
+	for _, seed := range []string{
+		`<b>x</b>`,
+		`<script>alert(1)</script>`,
+		`<scr<script>ipt>alert(1)</script>`,
+		`<SCRIPT SRC=//x></SCRIPT>`,
+		`<a href="javascript:alert(1)">x</a>`,
+		`<a href="java&#x0A;script:x">x</a>`,
+		`<<b>script>`,
+		`&lt;script&gt;`,
+		`<svg><script>x</script></svg>`,
+		`<b><i><u>x</b></i>`,
+		`<!--<script>-->`,
+	} {
+		f.Add(seed, 0)
+		f.Add(seed, 16)
+	}
+	basic := BasicPolicy()
+	f.Fuzz(func(t *testing.T, in string, maxLen int) {
+		for _, p := range []*Policy{StrictPolicy(), basic, {Tags: basic.Tags, URLAttrs: basic.URLAttrs, AllowImageDataURI: true, MaxLen: maxLen % 256}} {
+			out := SanitizeHTML(in, p)
+			if strings.Contains(strings.ToLower(out), "<script") {
+				t.Fatalf("SanitizeHTML(%q) = %q contains a script tag", in, out)
+			}
+			if p.MaxLen > 0 && len(out) > p.MaxLen {
+				t.Fatalf("output length %d exceeds %d", len(out), p.MaxLen)
+			}
+			if len(p.Tags) == 0 && strings.ContainsAny(out, "<>") {
+				t.Fatalf("strict output %q contains markup", out)
+			}
+			checkWellFormed(t, p, out)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package schedutil
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLocker implements Locker with SET NX PX.
+type RedisLocker struct {
+	rdb   redis.UniversalClient
+	owner string
+}
+
+// NewRedisLocker returns a Locker backed by rdb. The lock value records the
+// host and pid of the holder to help debugging.
+func NewRedisLocker(rdb redis.UniversalClient) *RedisLocker {
+	host, _ := os.Hostname()
+	return &RedisLocker{rdb: rdb, owner: host + ":" + strconv.Itoa(os.Getpid())}
+}
+
+func (l *RedisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := l.rdb.SetNX(ctx, key, l.owner, ttl).Result()
+	if err != nil {
+		return false, errs.WrapMsg(err, "redis SetNX failed", "key", key)
+	}
+	return ok, nil
+}
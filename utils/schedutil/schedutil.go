@@ -0,0 +1,266 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schedutil runs periodic background jobs from cron expressions.
+package schedutil
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/openimsdk/tools/utils/clockutil"
+)
+
+// maxSleep caps a single wait so that a wall clock jump, such as after the
+// process was suspended, is noticed promptly.
+const maxSleep = time.Minute
+
+// MissedRunPolicy decides what happens to a run that could not start on time.
+type MissedRunPolicy int
+
+const (
+	// MissedSkip drops late runs and waits for the next activation.
+	MissedSkip MissedRunPolicy = iota
+	// MissedRunOnce runs the job once immediately, however many activations were missed.
+	MissedRunOnce
+)
+
+// Status is the result of one activation of a job.
+type Status string
+
+const (
+	StatusSuccess Status = "success"
+	StatusFailed  Status = "failed"
+	StatusPanic   Status = "panic"
+	StatusLocked  Status = "locked" // another replica holds the run
+	StatusMissed  Status = "missed" // dropped by MissedSkip
+)
+
+// Outcome is reported to the metrics callback after every activation.
+type Outcome struct {
+	Name      string
+	Scheduled time.Time
+	Status    Status
+	Duration  time.Duration
+	Err       error
+}
+
+// Clock abstracts time for tests.
+type Clock = clockutil.Clock
+
+// Locker grants a run of a job to a single replica.
+type Locker interface {
+	// TryLock reports whether key was acquired. The lock is released by its
+	// TTL, so a slot can never run twice even if a replica is slow.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+type Option func(*task)
+
+// WithName names the job in logs, metrics and lock keys.
+func WithName(name string) Option {
+	return func(t *task) {
+		t.name = name
+	}
+}
+
+// WithJitter delays every run by a random duration in [0, max).
+func WithJitter(max time.Duration) Option {
+	return func(t *task) {
+		t.jitter = max
+	}
+}
+
+// WithDistributedLock makes replicas compete for every activation through
+// locker, so that only one of them runs it. ttl should be longer than the
+// clock skew between replicas plus the jitter. WithName is required.
+func WithDistributedLock(locker Locker, ttl time.Duration) Option {
+	return func(t *task) {
+		t.locker = locker
+		t.lockTTL = ttl
+	}
+}
+
+// WithMissedRunPolicy sets the policy for runs that start more than grace
+// after their activation time. The default is MissedSkip with a grace of 10s.
+func WithMissedRunPolicy(policy MissedRunPolicy, grace time.Duration) Option {
+	return func(t *task) {
+		t.missed = policy
+		t.grace = grace
+	}
+}
+
+// WithMetrics sets a callback receiving the outcome of every activation.
+func WithMetrics(fn func(Outcome)) Option {
+	return func(t *task) {
+		t.metrics = fn
+	}
+}
+
+// WithClock replaces the wall clock, for tests.
+func WithClock(clock Clock) Option {
+	return func(t *task) {
+		t.clock = clock
+	}
+}
+
+type task struct {
+	name    string
+	spec    Spec
+	job     func(ctx context.Context) error
+	jitter  time.Duration
+	locker  Locker
+	lockTTL time.Duration
+	missed  MissedRunPolicy
+	grace   time.Duration
+	metrics func(Outcome)
+	clock   Clock
+	done    chan struct{}
+}
+
+// Task is a scheduled job.
+type Task struct {
+	done <-chan struct{}
+}
+
+// Done is closed when the job has stopped after its context was canceled.
+func (t *Task) Done() <-chan struct{} {
+	return t.done
+}
+
+// Schedule runs job at every activation of spec until ctx is canceled. Runs of
+// one job never overlap: an activation that passes while the job is still
+// running is treated like any other late run.
+func Schedule(ctx context.Context, spec string, job func(ctx context.Context) error, opts ...Option) (*Task, error) {
+	s, err := ParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	t := &task{
+		spec:  s,
+		job:   job,
+		grace: 10 * time.Second,
+		clock: clockutil.Real,
+		done:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.name == "" {
+		if t.locker != nil {
+			return nil, errs.ErrArgs.WrapMsg("WithDistributedLock requires WithName", "spec", spec)
+		}
+		t.name = spec
+	}
+	if t.spec.Next(t.clock.Now()).IsZero() {
+		return nil, errs.ErrArgs.WrapMsg("cron expression never fires", "spec", spec)
+	}
+	if mcontext.GetOperationID(ctx) == "" {
+		ctx = mcontext.SetOperationID(ctx, "sched-"+t.name)
+	}
+	go t.loop(ctx)
+	return &Task{done: t.done}, nil
+}
+
+func (t *task) loop(ctx context.Context) {
+	defer close(t.done)
+	next := t.spec.Next(t.clock.Now())
+	for !next.IsZero() {
+		fireAt := next
+		if t.jitter > 0 {
+			fireAt = fireAt.Add(time.Duration(rand.Int63n(int64(t.jitter))))
+		}
+		if !t.sleepUntil(ctx, fireAt) {
+			return
+		}
+		now := t.clock.Now()
+		if now.Sub(fireAt) > t.grace && t.missed == MissedSkip {
+			log.ZWarn(ctx, "scheduled run missed", nil, "name", t.name, "scheduled", next, "now", now)
+			t.report(Outcome{Name: t.name, Scheduled: next, Status: StatusMissed})
+		} else {
+			t.run(ctx, next)
+		}
+		// The following activation is computed from this one, so that neither
+		// the jitter nor the run time shifts the schedule. Activations that
+		// passed before this one was handled are covered by it.
+		following := t.spec.Next(next)
+		if following.Before(now) {
+			following = t.spec.Next(now)
+		}
+		next = following
+	}
+}
+
+// sleepUntil waits for the wall clock to reach at. It returns false when ctx is done.
+func (t *task) sleepUntil(ctx context.Context, at time.Time) bool {
+	for {
+		d := at.Sub(t.clock.Now())
+		if d <= 0 {
+			return true
+		}
+		if d > maxSleep {
+			d = maxSleep
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.clock.After(d):
+		}
+	}
+}
+
+func (t *task) run(ctx context.Context, scheduled time.Time) {
+	if t.locker != nil {
+		key := "schedutil:" + t.name + ":" + strconv.FormatInt(scheduled.Unix(), 10)
+		ok, err := t.locker.TryLock(ctx, key, t.lockTTL)
+		if err != nil {
+			log.ZError(ctx, "scheduled job lock failed", err, "name", t.name, "key", key)
+			t.report(Outcome{Name: t.name, Scheduled: scheduled, Status: StatusFailed, Err: err})
+			return
+		}
+		if !ok {
+			t.report(Outcome{Name: t.name, Scheduled: scheduled, Status: StatusLocked})
+			return
+		}
+	}
+	start := t.clock.Now()
+	status, err := t.call(ctx)
+	if err != nil {
+		log.ZError(ctx, "scheduled job failed", err, "name", t.name, "scheduled", scheduled)
+	}
+	t.report(Outcome{Name: t.name, Scheduled: scheduled, Status: status, Duration: t.clock.Now().Sub(start), Err: err})
+}
+
+func (t *task) call(ctx context.Context) (status Status, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			status, err = StatusPanic, errs.ErrPanic(r)
+		}
+	}()
+	if err := t.job(ctx); err != nil {
+		return StatusFailed, err
+	}
+	return StatusSuccess, nil
+}
+
+func (t *task) report(o Outcome) {
+	if t.metrics != nil {
+		t.metrics(o)
+	}
+}
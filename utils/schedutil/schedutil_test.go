@@ -0,0 +1,246 @@
+package schedutil
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/openimsdk/tools/utils/clockutil/clockutiltest"
+	"github.com/redis/go-redis/v9"
+)
+
+func newFakeClock() *clockutiltest.Fake {
+	return clockutiltest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func nextOutcome(t *testing.T, ch <-chan Outcome) Outcome {
+	t.Helper()
+	select {
+	case o := <-ch:
+		return o
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for job outcome")
+		return Outcome{}
+	}
+}
+
+func startTask(t *testing.T, clock *clockutiltest.Fake, spec string, job func(ctx context.Context) error, opts ...Option) <-chan Outcome {
+	t.Helper()
+	outcomes := make(chan Outcome, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	opts = append(opts, WithClock(clock), WithMetrics(func(o Outcome) { outcomes <- o }))
+	task, err := Schedule(ctx, spec, job, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		<-task.Done()
+	})
+	return outcomes
+}
+
+func TestScheduleRuns(t *testing.T) {
+	clock := newFakeClock()
+	var opID atomic.Value
+	outcomes := startTask(t, clock, "*/5 * * * *", func(ctx context.Context) error {
+		opID.Store(mcontext.GetOperationID(ctx))
+		return nil
+	}, WithName("cleanup"))
+
+	for i := 1; i <= 3; i++ {
+		clock.WaitBlocked(t, 1)
+		clock.Advance(5 * time.Minute)
+		o := nextOutcome(t, outcomes)
+		want := time.Date(2024, 1, 1, 0, 5*i, 0, 0, time.UTC)
+		if o.Status != StatusSuccess || !o.Scheduled.Equal(want) || o.Name != "cleanup" {
+			t.Fatalf("run %d: %+v", i, o)
+		}
+	}
+	if opID.Load() != "sched-cleanup" {
+		t.Errorf("job context operationID = %v", opID.Load())
+	}
+}
+
+func TestScheduleFailureAndPanic(t *testing.T) {
+	clock := newFakeClock()
+	var calls atomic.Int32
+	jobErr := errors.New("db down")
+	outcomes := startTask(t, clock, "@every 1m", func(ctx context.Context) error {
+		switch calls.Add(1) {
+		case 1:
+			panic("boom")
+		case 2:
+			return jobErr
+		}
+		return nil
+	})
+
+	want := []Status{StatusPanic, StatusFailed, StatusSuccess}
+	for i, status := range want {
+		clock.WaitBlocked(t, 1)
+		clock.Advance(time.Minute)
+		o := nextOutcome(t, outcomes)
+		if o.Status != status {
+			t.Fatalf("run %d: status %s, want %s (err %v)", i, o.Status, status, o.Err)
+		}
+		switch status {
+		case StatusPanic:
+			var codeErr errs.CodeError
+			if !errors.As(o.Err, &codeErr) || codeErr.Detail() != "boom" {
+				t.Errorf("panic not converted by errs.ErrPanic: %v", o.Err)
+			}
+		case StatusFailed:
+			if !errors.Is(o.Err, jobErr) {
+				t.Errorf("err = %v", o.Err)
+			}
+		}
+	}
+}
+
+func TestScheduleJitter(t *testing.T) {
+	clock := newFakeClock()
+	outcomes := startTask(t, clock, "@every 1m", func(ctx context.Context) error { return nil },
+		WithJitter(30*time.Second))
+
+	clock.WaitBlocked(t, 1)
+	clock.Advance(time.Minute + 30*time.Second)
+	o := nextOutcome(t, outcomes)
+	if !o.Scheduled.Equal(clock.Now().Add(-30 * time.Second)) {
+		t.Errorf("scheduled = %v, want the unjittered activation", o.Scheduled)
+	}
+}
+
+func TestScheduleMissedRun(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		policy MissedRunPolicy
+		status Status
+		calls  int32
+	}{
+		{"skip", MissedSkip, StatusMissed, 0},
+		{"run once", MissedRunOnce, StatusSuccess, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			var calls atomic.Int32
+			outcomes := startTask(t, clock, "0 * * * *", func(ctx context.Context) error {
+				calls.Add(1)
+				return nil
+			}, WithMissedRunPolicy(tt.policy, time.Minute))
+
+			// The process is suspended for five hours: the timer fires late, once.
+			clock.WaitBlocked(t, 1)
+			clock.Advance(5 * time.Hour)
+			o := nextOutcome(t, outcomes)
+			if o.Status != tt.status || !o.Scheduled.Equal(time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)) {
+				t.Fatalf("outcome after suspension: %+v", o)
+			}
+			if calls.Load() != tt.calls {
+				t.Fatalf("job called %d times, want %d", calls.Load(), tt.calls)
+			}
+
+			// Regular scheduling resumes at the next activation after now.
+			clock.WaitBlocked(t, 1)
+			clock.Advance(time.Hour)
+			o = nextOutcome(t, outcomes)
+			if o.Status != StatusSuccess || !o.Scheduled.Equal(time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)) {
+				t.Fatalf("outcome after resume: %+v", o)
+			}
+			select {
+			case o := <-outcomes:
+				t.Fatalf("unexpected extra outcome %+v", o)
+			default:
+			}
+		})
+	}
+}
+
+func TestScheduleDistributedLock(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	clock := newFakeClock()
+	var calls atomic.Int32
+	job := func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}
+	// Two replicas share the lock through Redis.
+	a := startTask(t, clock, "* * * * *", job, WithName("purge"), WithDistributedLock(NewRedisLocker(rdb), time.Hour))
+	b := startTask(t, clock, "* * * * *", job, WithName("purge"), WithDistributedLock(NewRedisLocker(rdb), time.Hour))
+
+	for i := 1; i <= 3; i++ {
+		clock.WaitBlocked(t, 2)
+		clock.Advance(time.Minute)
+		got := map[Status]int{}
+		got[nextOutcome(t, a).Status]++
+		got[nextOutcome(t, b).Status]++
+		if got[StatusSuccess] != 1 || got[StatusLocked] != 1 {
+			t.Fatalf("slot %d: outcomes %v", i, got)
+		}
+	}
+	if calls.Load() != 3 {
+		t.Errorf("job ran %d times, want 3", calls.Load())
+	}
+	if !mr.Exists("schedutil:purge:" + "1704067260") {
+		t.Errorf("lock key missing, keys: %v", mr.Keys())
+	}
+}
+
+func TestScheduleEveryDistributedLock(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	clock := newFakeClock()
+	var calls atomic.Int32
+	job := func(ctx context.Context) error {
+		calls.Add(1)
+		clock.Advance(5 * time.Second) // the run takes time
+		return nil
+	}
+	// The replicas start 20 seconds apart but agree on the activations.
+	opts := []Option{WithName("compact"), WithDistributedLock(NewRedisLocker(rdb), time.Hour)}
+	a := startTask(t, clock, "@every 1m", job, opts...)
+	clock.Advance(20 * time.Second)
+	b := startTask(t, clock, "@every 1m", job, opts...)
+
+	for i := 1; i <= 3; i++ {
+		want := time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC)
+		clock.WaitBlocked(t, 2)
+		clock.Advance(want.Sub(clock.Now()))
+		got := map[Status]int{}
+		for _, o := range []Outcome{nextOutcome(t, a), nextOutcome(t, b)} {
+			if !o.Scheduled.Equal(want) {
+				t.Fatalf("slot %d: scheduled %v, want %v", i, o.Scheduled, want)
+			}
+			got[o.Status]++
+		}
+		if got[StatusSuccess] != 1 || got[StatusLocked] != 1 {
+			t.Fatalf("slot %d: outcomes %v", i, got)
+		}
+	}
+	if calls.Load() != 3 {
+		t.Errorf("job ran %d times, want 3", calls.Load())
+	}
+}
+
+func TestScheduleInvalid(t *testing.T) {
+	job := func(ctx context.Context) error { return nil }
+	if _, err := Schedule(context.Background(), "bad spec", job); err == nil {
+		t.Error("invalid spec accepted")
+	}
+	if _, err := Schedule(context.Background(), "0 0 30 2 *", job); err == nil {
+		t.Error("spec that never fires accepted")
+	}
+	if _, err := Schedule(context.Background(), "@hourly", job, WithDistributedLock(NewRedisLocker(nil), time.Minute)); err == nil {
+		t.Error("distributed lock without name accepted")
+	}
+}
@@ -0,0 +1,62 @@
+package schedutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSpecNext(t *testing.T) {
+	base := time.Date(2024, 3, 16, 10, 7, 30, 0, time.UTC) // Saturday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 3, 16, 10, 15, 0, 0, time.UTC)},
+		{"5 * * * *", time.Date(2024, 3, 16, 11, 5, 0, 0, time.UTC)},
+		{"0 9 * * MON-FRI", time.Date(2024, 3, 18, 9, 0, 0, 0, time.UTC)},
+		{"30 2 1,15 * *", time.Date(2024, 4, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		// A restricted day-of-month and day-of-week match when either does.
+		{"0 0 20 * fri", time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 3, 16, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", time.Date(2024, 3, 16, 10, 9, 0, 0, time.UTC)},
+		{"@every 1h", time.Date(2024, 3, 16, 11, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := ParseSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(base); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%v) = %v, want %v", tt.spec, base, got, tt.want)
+		}
+	}
+}
+
+func TestParseSpecInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every",
+		"@every -1s",
+		"@every soon",
+	} {
+		if _, err := ParseSpec(spec); err == nil {
+			t.Errorf("ParseSpec(%q) accepted", spec)
+		}
+	}
+}
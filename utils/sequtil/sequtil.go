@@ -0,0 +1,122 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sequtil provides overflow-safe arithmetic on inclusive ranges of
+// message seqs. Ranges are always returned as [begin, end] intervals and never
+// expanded to one element per seq, so ranges spanning millions of seqs cost
+// no more than their number of intervals.
+package sequtil
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// maxPrealloc bounds the capacity RangeSplit allocates up front.
+const maxPrealloc = 1 << 16
+
+// span returns end-begin+1 for begin <= end without overflowing.
+func span(begin, end int64) uint64 {
+	return uint64(end) - uint64(begin) + 1
+}
+
+// RangeSplit splits [begin, end] into consecutive intervals of at most step
+// seqs, for pulling a large range in fixed-size batches.
+func RangeSplit(begin, end, step int64) ([][2]int64, error) {
+	if begin > end {
+		return nil, errs.ErrArgs.WrapMsg("begin is greater than end", "begin", begin, "end", end)
+	}
+	if step <= 0 {
+		return nil, errs.ErrArgs.WrapMsg("step must be positive", "step", step)
+	}
+	count := (span(begin, end)-1)/uint64(step) + 1
+	parts := make([][2]int64, 0, min(count, maxPrealloc))
+	for cur := begin; ; cur += step {
+		if uint64(end)-uint64(cur) < uint64(step) {
+			return append(parts, [2]int64{cur, end}), nil
+		}
+		parts = append(parts, [2]int64{cur, cur + step - 1})
+	}
+}
+
+// MissingRanges returns the intervals of [begin, end] not covered by have,
+// for pulling the messages missing from a local store. have may contain
+// duplicates and seqs outside the range; if it is not sorted, a sorted copy
+// is made.
+func MissingRanges(have []int64, begin, end int64) [][2]int64 {
+	if begin > end {
+		return nil
+	}
+	if !slices.IsSorted(have) {
+		have = slices.Clone(have)
+		slices.Sort(have)
+	}
+	var gaps [][2]int64
+	next := begin
+	for _, seq := range have {
+		if seq < next {
+			continue
+		}
+		if seq > end {
+			break
+		}
+		if seq > next {
+			gaps = append(gaps, [2]int64{next, seq - 1})
+		}
+		if seq == end {
+			return gaps
+		}
+		next = seq + 1
+	}
+	return append(gaps, [2]int64{next, end})
+}
+
+// ClampRange returns the intersection of [begin, end] and [lo, hi]. ok is
+// false if they do not overlap.
+func ClampRange(begin, end, lo, hi int64) (int64, int64, bool) {
+	if begin < lo {
+		begin = lo
+	}
+	if end > hi {
+		end = hi
+	}
+	if begin > end {
+		return 0, 0, false
+	}
+	return begin, end, true
+}
+
+// MergeIntervals merges overlapping and adjacent intervals and drops empty
+// ones (begin > end). The result is sorted by begin. It reuses the storage of
+// intervals, which is reordered in place.
+func MergeIntervals(intervals [][2]int64) [][2]int64 {
+	slices.SortFunc(intervals, func(a, b [2]int64) int { return cmp.Compare(a[0], b[0]) })
+	merged := intervals[:0]
+	for _, iv := range intervals {
+		if iv[0] > iv[1] {
+			continue
+		}
+		if last := len(merged) - 1; last >= 0 && (merged[last][1] == math.MaxInt64 || iv[0] <= merged[last][1]+1) {
+			if iv[1] > merged[last][1] {
+				merged[last][1] = iv[1]
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
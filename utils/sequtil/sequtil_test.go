@@ -0,0 +1,194 @@
+package sequtil
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// expand returns the seqs covered by intervals, for comparing with the naive references.
+func expand(intervals [][2]int64) []int64 {
+	var seqs []int64
+	for _, iv := range intervals {
+		for s := iv[0]; s <= iv[1]; s++ {
+			seqs = append(seqs, s)
+		}
+	}
+	return seqs
+}
+
+func TestRangeSplit(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		begin := r.Int63n(50) - 25
+		end := begin + r.Int63n(60)
+		step := r.Int63n(12) + 1
+		parts, err := RangeSplit(begin, end, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want [][2]int64
+		for s := begin; s <= end; s += step {
+			want = append(want, [2]int64{s, min(s+step-1, end)})
+		}
+		if !reflect.DeepEqual(parts, want) {
+			t.Fatalf("RangeSplit(%d, %d, %d) = %v, want %v", begin, end, step, parts, want)
+		}
+	}
+
+	parts, err := RangeSplit(math.MaxInt64-5, math.MaxInt64, 4)
+	if err != nil || !reflect.DeepEqual(parts, [][2]int64{{math.MaxInt64 - 5, math.MaxInt64 - 2}, {math.MaxInt64 - 1, math.MaxInt64}}) {
+		t.Fatalf("RangeSplit near MaxInt64 = %v, %v", parts, err)
+	}
+	parts, err = RangeSplit(math.MinInt64, math.MaxInt64, math.MaxInt64)
+	if err != nil || len(parts) != 3 || parts[2] != [2]int64{math.MaxInt64 - 1, math.MaxInt64} {
+		t.Fatalf("RangeSplit over the full range = %v, %v", parts, err)
+	}
+	if _, err := RangeSplit(2, 1, 1); err == nil {
+		t.Error("begin > end accepted")
+	}
+	if _, err := RangeSplit(1, 2, 0); err == nil {
+		t.Error("zero step accepted")
+	}
+}
+
+func TestMissingRanges(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 1000; i++ {
+		begin := r.Int63n(40)
+		end := begin + r.Int63n(40) - 5
+		have := make([]int64, r.Intn(30))
+		for j := range have {
+			have[j] = r.Int63n(90) - 5
+		}
+		present := make(map[int64]bool)
+		for _, s := range have {
+			present[s] = true
+		}
+		var want []int64
+		for s := begin; s <= end; s++ {
+			if !present[s] {
+				want = append(want, s)
+			}
+		}
+		gaps := MissingRanges(have, begin, end)
+		if got := expand(gaps); !reflect.DeepEqual(got, want) {
+			t.Fatalf("MissingRanges(%v, %d, %d) = %v, want seqs %v", have, begin, end, gaps, want)
+		}
+		for j := 1; j < len(gaps); j++ {
+			if gaps[j][0] <= gaps[j-1][1]+1 {
+				t.Fatalf("gaps %v are not compact", gaps)
+			}
+		}
+	}
+
+	if gaps := MissingRanges([]int64{math.MaxInt64}, math.MaxInt64-2, math.MaxInt64); !reflect.DeepEqual(gaps, [][2]int64{{math.MaxInt64 - 2, math.MaxInt64 - 1}}) {
+		t.Fatalf("MissingRanges near MaxInt64 = %v", gaps)
+	}
+	have := []int64{5, 3, 1}
+	MissingRanges(have, 1, 5)
+	if !reflect.DeepEqual(have, []int64{5, 3, 1}) {
+		t.Fatal("MissingRanges reordered its input")
+	}
+}
+
+func TestClampRange(t *testing.T) {
+	cases := []struct {
+		begin, end, lo, hi int64
+		want               [2]int64
+		ok                 bool
+	}{
+		{1, 10, 3, 7, [2]int64{3, 7}, true},
+		{1, 10, 0, 100, [2]int64{1, 10}, true},
+		{1, 10, 10, 20, [2]int64{10, 10}, true},
+		{1, 10, 11, 20, [2]int64{}, false},
+		{5, 4, 0, 10, [2]int64{}, false},
+		{math.MinInt64, math.MaxInt64, -1, 1, [2]int64{-1, 1}, true},
+	}
+	for _, c := range cases {
+		b, e, ok := ClampRange(c.begin, c.end, c.lo, c.hi)
+		if ok != c.ok || [2]int64{b, e} != c.want {
+			t.Errorf("ClampRange(%d, %d, %d, %d) = %d, %d, %v", c.begin, c.end, c.lo, c.hi, b, e, ok)
+		}
+	}
+}
+
+func TestMergeIntervals(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 1000; i++ {
+		intervals := make([][2]int64, r.Intn(10))
+		covered := make(map[int64]bool)
+		for j := range intervals {
+			b := r.Int63n(60)
+			e := b + r.Int63n(10) - 2
+			intervals[j] = [2]int64{b, e}
+			for s := b; s <= e; s++ {
+				covered[s] = true
+			}
+		}
+		var want []int64
+		for s := int64(0); s < 80; s++ {
+			if covered[s] {
+				want = append(want, s)
+			}
+		}
+		merged := MergeIntervals(intervals)
+		if got := expand(merged); !reflect.DeepEqual(got, want) {
+			t.Fatalf("MergeIntervals = %v, want seqs %v", merged, want)
+		}
+		for j := 1; j < len(merged); j++ {
+			if merged[j][0] <= merged[j-1][1]+1 {
+				t.Fatalf("intervals %v are overlapping or adjacent", merged)
+			}
+		}
+	}
+
+	merged := MergeIntervals([][2]int64{{math.MaxInt64 - 1, math.MaxInt64}, {math.MaxInt64, math.MaxInt64}, {1, 2}})
+	if !reflect.DeepEqual(merged, [][2]int64{{1, 2}, {math.MaxInt64 - 1, math.MaxInt64}}) {
+		t.Fatalf("MergeIntervals near MaxInt64 = %v", merged)
+	}
+}
+
+// wideRange returns a sorted seq list of [1, 10M] with 1k gaps.
+func wideRange() []int64 {
+	const n, gaps = 10_000_000, 1_000
+	have := make([]int64, 0, n)
+	for s := int64(1); s <= n; s++ {
+		if s%(n/gaps) != 0 {
+			have = append(have, s)
+		}
+	}
+	return have
+}
+
+func BenchmarkMissingRanges(b *testing.B) {
+	have := wideRange()
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if gaps := MissingRanges(have, 1, 10_000_000); len(gaps) != 1_000 {
+			b.Fatalf("got %d gaps", len(gaps))
+		}
+	}
+}
+
+func BenchmarkRangeSplit(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := RangeSplit(1, 10_000_000, 100); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMergeIntervals(b *testing.B) {
+	gaps := MissingRanges(wideRange(), 1, 10_000_000)
+	intervals := make([][2]int64, len(gaps))
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		copy(intervals, gaps)
+		MergeIntervals(intervals)
+	}
+}
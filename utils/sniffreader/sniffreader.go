@@ -0,0 +1,200 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sniffreader reads payloads that may or may not be compressed,
+// recognizing the compression from the magic number instead of a file name or
+// a header the sender may not set.
+package sniffreader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/openimsdk/tools/errs"
+)
+
+// Encoding is the compression of a payload.
+type Encoding string
+
+const (
+	Plain Encoding = "plain"
+	Gzip  Encoding = "gzip"
+	Zstd  Encoding = "zstd"
+)
+
+const (
+	// DefaultMaxSize is the largest payload read, once decompressed, unless
+	// WithMaxSize says otherwise.
+	DefaultMaxSize = 64 << 20
+	// DefaultMaxRatio is the largest ratio of the decompressed size to the
+	// compressed size unless WithMaxRatio says otherwise.
+	DefaultMaxRatio = 100
+
+	// ratioGrace is the decompressed size below which the ratio is not
+	// enforced: small payloads of repeated bytes legitimately compress far
+	// better than the ratio.
+	ratioGrace = 1 << 20
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+var (
+	ErrTooLarge  = errs.New("payload too large")
+	ErrBomb      = errs.New("payload compression ratio too high")
+	ErrMalformed = errs.New("malformed compressed payload")
+)
+
+type options struct {
+	maxSize  int64
+	maxRatio int64
+}
+
+type Option func(*options)
+
+// WithMaxSize sets the largest payload read, once decompressed, for every
+// encoding, DefaultMaxSize by default.
+func WithMaxSize(n int64) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxSize = n
+		}
+	}
+}
+
+// WithMaxRatio sets the largest ratio of the decompressed size to the
+// compressed size, DefaultMaxRatio by default. It is only enforced past the
+// first MiB decompressed.
+func WithMaxRatio(ratio int) Option {
+	return func(o *options) {
+		if ratio > 0 {
+			o.maxRatio = int64(ratio)
+		}
+	}
+}
+
+// New peeks at the first bytes of r and returns a reader of its decompressed
+// content, along with the encoding detected. r need not be seekable: the
+// bytes peeked are buffered, and a plain payload is returned byte for byte.
+//
+// The reader fails with ErrTooLarge once more than the maximum size was
+// decompressed, and with ErrBomb when the payload expands more than the
+// maximum ratio. A corrupt payload fails with ErrMalformed, from New when its
+// header is invalid.
+func New(r io.Reader, opts ...Option) (io.Reader, Encoding, error) {
+	o := options{maxSize: DefaultMaxSize, maxRatio: DefaultMaxRatio}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, "", errs.WrapMsg(err, "read payload failed")
+	}
+	src := &countingReader{r: br}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(src)
+		if err != nil {
+			return nil, Gzip, malformed(Gzip, err)
+		}
+		return &limitReader{r: gz, enc: Gzip, src: src, opts: o}, Gzip, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(src, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(o.maxSize)))
+		if err != nil {
+			return nil, Zstd, malformed(Zstd, err)
+		}
+		return &limitReader{r: zr, enc: Zstd, src: src, opts: o, close: zr.Close}, Zstd, nil
+	}
+	return &limitReader{r: br, enc: Plain, opts: o}, Plain, nil
+}
+
+func malformed(enc Encoding, err error) error {
+	return ErrMalformed.WrapMsg("invalid "+string(enc)+" payload", "err", err.Error())
+}
+
+// countingReader counts the compressed bytes read, and remembers the read
+// error to tell it from a decompression error.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// limitReader enforces the size and ratio limits on the decompressed reader r
+// of the compressed src, nil for plain payloads.
+type limitReader struct {
+	r     io.Reader
+	enc   Encoding
+	src   *countingReader
+	opts  options
+	close func()
+	n     int64
+	err   error
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	// Read one byte past the limit, to tell a payload of exactly the maximum
+	// size from a larger one.
+	if left := l.opts.maxSize - l.n + 1; int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	switch {
+	case l.n > l.opts.maxSize:
+		n -= int(l.n - l.opts.maxSize)
+		l.n = l.opts.maxSize
+		err = ErrTooLarge.WrapMsg("payload exceeds the maximum size", "encoding", l.enc, "max", l.opts.maxSize)
+	case l.src != nil && l.n > ratioGrace && l.n > l.opts.maxRatio*l.src.n:
+		err = ErrBomb.WrapMsg("payload expands more than the maximum ratio", "encoding", l.enc,
+			"compressed", l.src.n, "decompressed", l.n, "maxRatio", l.opts.maxRatio)
+	case errors.Is(err, zstd.ErrDecoderSizeExceeded):
+		// The frame declares a content size above the limit: zstd refuses it
+		// upfront, before decompressing anything.
+		err = ErrTooLarge.WrapMsg("payload exceeds the maximum size", "encoding", l.enc, "max", l.opts.maxSize)
+	case err != nil && err != io.EOF && l.src != nil:
+		if l.src.err != nil && l.src.err != io.EOF {
+			err = errs.WrapMsg(l.src.err, "read payload failed", "encoding", l.enc)
+		} else {
+			err = malformed(l.enc, err)
+		}
+	}
+	if err != nil {
+		l.err = err
+		if l.close != nil {
+			l.close()
+			l.close = nil
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,184 @@
+package sniffreader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/openimsdk/tools/utils/leaktest"
+)
+
+func gzipped(t testing.TB, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstded(t testing.TB, data []byte) []byte {
+	t.Helper()
+	w, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	return w.EncodeAll(data, nil)
+}
+
+func TestNew(t *testing.T) {
+	leaktest.Check(t)
+	payload := []byte(strings.Repeat(`{"userID":"u1","content":"hello"}`, 100))
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want []byte
+		enc  Encoding
+	}{
+		{"plain", payload, payload, Plain},
+		{"empty", nil, nil, Plain},
+		{"short plain", []byte{0x1f}, []byte{0x1f}, Plain},
+		{"plain like zstd", []byte{0x28, 0xb5, 0x2f}, []byte{0x28, 0xb5, 0x2f}, Plain},
+		{"gzip", gzipped(t, payload), payload, Gzip},
+		{"zstd", zstded(t, payload), payload, Zstd},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// A non seekable source returning one byte at a time.
+			r, enc, err := New(iotest.OneByteReader(bytes.NewReader(tc.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if enc != tc.enc {
+				t.Errorf("encoding = %s, want %s", enc, tc.enc)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("read %d bytes, want %d", len(got), len(tc.want))
+			}
+		})
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 2000)
+	for name, data := range map[string][]byte{"plain": payload, "gzip": gzipped(t, payload), "zstd": zstded(t, payload)} {
+		r, _, err := New(bytes.NewReader(data), WithMaxSize(1000))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := io.ReadAll(r)
+		// zstd frames declaring their size are refused before any byte.
+		want := 1000
+		if name == "zstd" {
+			want = 0
+		}
+		if !errors.Is(err, ErrTooLarge) || len(got) != want {
+			t.Errorf("%s: read %d bytes, err %v, want %d and ErrTooLarge", name, len(got), err, want)
+		}
+		r, _, _ = New(bytes.NewReader(data), WithMaxSize(2000))
+		if got, err := io.ReadAll(r); err != nil || len(got) != 2000 {
+			t.Errorf("%s: payload of exactly the maximum size: %d bytes, %v", name, len(got), err)
+		}
+	}
+}
+
+func TestBomb(t *testing.T) {
+	leaktest.Check(t)
+	zeros := make([]byte, 16<<20)
+	for name, data := range map[string][]byte{"gzip": gzipped(t, zeros), "zstd": zstded(t, zeros)} {
+		r, _, err := New(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		n, err := io.Copy(io.Discard, r)
+		if !errors.Is(err, ErrBomb) {
+			t.Fatalf("%s: err = %v, want ErrBomb", name, err)
+		}
+		if n >= int64(len(zeros)) {
+			t.Errorf("%s: bomb fully decompressed", name)
+		}
+		r, _, _ = New(bytes.NewReader(data), WithMaxRatio(len(zeros)))
+		if n, err := io.Copy(io.Discard, r); err != nil || n != int64(len(zeros)) {
+			t.Errorf("%s: with a high ratio: %d bytes, %v", name, n, err)
+		}
+	}
+	// Small payloads are not subject to the ratio.
+	r, _, _ := New(bytes.NewReader(gzipped(t, make([]byte, 64<<10))))
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Errorf("small payload: %v", err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	leaktest.Check(t)
+	payload := gzipped(t, []byte(strings.Repeat("hello ", 1000)))
+	if _, enc, err := New(bytes.NewReader(payload[:5])); !errors.Is(err, ErrMalformed) || enc != Gzip {
+		t.Errorf("truncated gzip header: %s, %v", enc, err)
+	}
+	r, _, err := New(bytes.NewReader(payload[:len(payload)/2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrMalformed) {
+		t.Errorf("truncated gzip body: %v", err)
+	}
+	corrupt := append([]byte(nil), zstded(t, []byte(strings.Repeat("hello ", 1000)))...)
+	for i := 6; i < len(corrupt); i++ {
+		corrupt[i] ^= 0xff
+	}
+	if r, _, err := New(bytes.NewReader(corrupt)); err == nil {
+		if _, err = io.ReadAll(r); !errors.Is(err, ErrMalformed) {
+			t.Errorf("corrupt zstd: %v", err)
+		}
+	} else if !errors.Is(err, ErrMalformed) {
+		t.Errorf("corrupt zstd: %v", err)
+	}
+
+	// Errors of the source are not reported as corruption.
+	boom := errors.New("connection reset")
+	r, _, err = New(io.MultiReader(bytes.NewReader(payload[:20]), iotest.ErrReader(boom)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, boom) || errors.Is(err, ErrMalformed) {
+		t.Errorf("source error: %v", err)
+	}
+}
+
+func FuzzNew(f *testing.F) {
+	payload := []byte(strings.Repeat("openim ", 200))
+	gz, zs := gzipped(f, payload), zstded(f, payload)
+	for _, seed := range [][]byte{nil, payload, gz, zs, gz[:2], gz[:10], zs[:4], zs[:8], gz[:len(gz)-4], zs[:len(zs)-1]} {
+		f.Add(seed)
+	}
+	const maxSize = 4096
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, _, err := New(bytes.NewReader(data), WithMaxSize(maxSize))
+		if err != nil {
+			if !errors.Is(err, ErrMalformed) {
+				t.Fatalf("New: unexpected error %v", err)
+			}
+			return
+		}
+		got, err := io.ReadAll(r)
+		if len(got) > maxSize {
+			t.Fatalf("read %d bytes past the maximum size", len(got))
+		}
+		if err != nil && !errors.Is(err, ErrMalformed) && !errors.Is(err, ErrTooLarge) && !errors.Is(err, ErrBomb) {
+			t.Fatalf("Read: unexpected error %v", err)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statreg
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recorder receives the measurements of the packages that take one, such as
+// discovery.HedgedCall and kafka.WatchedHandler, for export to a metrics
+// backend. Each metric name is documented by the package reporting it, with
+// the dimensions of its label values, in order.
+type Recorder interface {
+	// Inc adds one to the counter name for the given label values.
+	Inc(name string, labels ...string)
+	// ObserveDuration records d in the duration histogram name for the given
+	// label values.
+	ObserveDuration(name string, d time.Duration, labels ...string)
+}
+
+// DurationBuckets are the upper bounds, in seconds, of the histograms of the
+// Recorder returned by NewRecorder.
+var DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+type recorder struct {
+	registry    *Registry
+	lock        sync.Mutex
+	instruments sync.Map // instrument name to *Counter or *Histogram
+}
+
+// NewRecorder returns a Recorder keeping the measurements in registry, one
+// instrument per metric name and label values. The instrument is named after
+// the metric and its label values joined with underscores, with the characters
+// not allowed in names replaced by underscores, then _total for a counter or
+// _seconds for a histogram: the handler durations of the topic "orders" go to
+// kafka_handler_orders_seconds. Measurements are dropped for an instrument
+// whose name is taken by another one. A nil registry stands for Default.
+func NewRecorder(registry *Registry) Recorder {
+	if registry == nil {
+		registry = Default
+	}
+	return &recorder{registry: registry}
+}
+
+func (r *recorder) Inc(name string, labels ...string) {
+	c, _ := r.instrument(instrumentName(name, labels, "_total"), func(n string) (any, error) {
+		return r.registry.NewCounter(n)
+	}).(*Counter)
+	if c != nil {
+		c.Inc()
+	}
+}
+
+func (r *recorder) ObserveDuration(name string, d time.Duration, labels ...string) {
+	h, _ := r.instrument(instrumentName(name, labels, "_seconds"), func(n string) (any, error) {
+		return r.registry.NewHistogram(n, DurationBuckets)
+	}).(*Histogram)
+	if h != nil {
+		h.Observe(d.Seconds())
+	}
+}
+
+// instrument returns the instrument registered under name, registering it
+// with register on first use. A failed registration is kept as nil, so that
+// it is not retried on every measurement.
+func (r *recorder) instrument(name string, register func(name string) (any, error)) any {
+	if i, ok := r.instruments.Load(name); ok {
+		return i
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if i, ok := r.instruments.Load(name); ok {
+		return i
+	}
+	i, err := register(name)
+	if err != nil {
+		i = nil
+	}
+	r.instruments.Store(name, i)
+	return i
+}
+
+func instrumentName(name string, labels []string, suffix string) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, label := range labels {
+		sb.WriteByte('_')
+		for _, c := range label {
+			if c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+				sb.WriteRune(c)
+			} else {
+				sb.WriteByte('_')
+			}
+		}
+	}
+	sb.WriteString(suffix)
+	return sb.String()
+}
@@ -0,0 +1,34 @@
+package statreg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRegistry()
+	if _, err := r.NewGauge("taken_total"); err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder(r)
+	rec.Inc("calls", "msg", "/openim.msg/Pull")
+	rec.Inc("calls", "msg", "/openim.msg/Pull")
+	rec.ObserveDuration("handler", 20*time.Millisecond, "orders")
+	rec.ObserveDuration("handler", 2*time.Second, "orders")
+	rec.Inc("taken")
+
+	got := make(map[string]Sample)
+	for _, s := range r.Snapshot() {
+		got[s.Name] = s
+	}
+	if s := got["calls_msg__openim_msg_Pull_total"]; s.Kind != KindCounter || s.Value != 2 {
+		t.Errorf("counter = %+v, snapshot %+v", s, got)
+	}
+	h := got["handler_orders_seconds"]
+	if h.Kind != KindHistogram || h.Count != 2 || h.Sum != 2.02 {
+		t.Errorf("histogram = %+v", h)
+	}
+	if s := got["taken_total"]; s.Kind != KindGauge || s.Value != 0 {
+		t.Errorf("instrument taken by a gauge = %+v", s)
+	}
+}
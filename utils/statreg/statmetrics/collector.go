@@ -0,0 +1,59 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statmetrics exposes a statreg registry as Prometheus metrics. It is
+// kept apart from statreg so that only services that export metrics depend
+// on the Prometheus client.
+package statmetrics
+
+import (
+	"github.com/openimsdk/tools/utils/statreg"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type collector struct {
+	registry *statreg.Registry
+}
+
+// NewCollector returns a collector of the instruments of registry, under
+// their registered names, to be registered with the service's Prometheus
+// registry. A nil registry stands for statreg.Default.
+func NewCollector(registry *statreg.Registry) prometheus.Collector {
+	if registry == nil {
+		registry = statreg.Default
+	}
+	return collector{registry: registry}
+}
+
+// Describe sends no descriptor: instruments are registered at any time, so
+// the collector is unchecked.
+func (collector) Describe(chan<- *prometheus.Desc) {}
+
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.registry.Snapshot() {
+		desc := prometheus.NewDesc(s.Name, "statreg "+s.Kind+" "+s.Name+".", nil, nil)
+		switch s.Kind {
+		case statreg.KindCounter:
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, s.Value)
+		case statreg.KindGauge:
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, s.Value)
+		case statreg.KindHistogram:
+			buckets := make(map[float64]uint64, len(s.Buckets))
+			for _, b := range s.Buckets {
+				buckets[b.UpperBound] = b.Count
+			}
+			ch <- prometheus.MustNewConstHistogram(desc, s.Count, s.Sum, buckets)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package statmetrics
+
+import (
+	"testing"
+
+	"github.com/openimsdk/tools/utils/statreg"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectorMirrorsSnapshot(t *testing.T) {
+	r := statreg.NewRegistry()
+	requests, _ := r.NewCounter("test_requests_total")
+	inflight, _ := r.NewGauge("test_inflight")
+	latency, _ := r.NewHistogram("test_latency_seconds", []float64{0.01, 0.1, 1})
+	requests.Add(7)
+	inflight.Set(-3)
+	for _, v := range []float64{0.005, 0.05, 0.5, 5} {
+		latency.Observe(v)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewCollector(r))
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := r.Snapshot()
+	if len(families) != len(snapshot) {
+		t.Fatalf("%d families, %d samples", len(families), len(snapshot))
+	}
+	// Both are sorted by name.
+	for i, s := range snapshot {
+		f := families[i]
+		if f.GetName() != s.Name || len(f.GetMetric()) != 1 {
+			t.Fatalf("family %s, sample %s", f.GetName(), s.Name)
+		}
+		m := f.GetMetric()[0]
+		switch s.Kind {
+		case statreg.KindCounter:
+			if m.GetCounter().GetValue() != s.Value {
+				t.Errorf("%s = %v, want %v", s.Name, m.GetCounter().GetValue(), s.Value)
+			}
+		case statreg.KindGauge:
+			if m.GetGauge().GetValue() != s.Value {
+				t.Errorf("%s = %v, want %v", s.Name, m.GetGauge().GetValue(), s.Value)
+			}
+		case statreg.KindHistogram:
+			h := m.GetHistogram()
+			if h.GetSampleCount() != s.Count || h.GetSampleSum() != s.Sum || len(h.GetBucket()) != len(s.Buckets) {
+				t.Fatalf("%s = %v, want %+v", s.Name, h, s)
+			}
+			for j, b := range h.GetBucket() {
+				if b.GetUpperBound() != s.Buckets[j].UpperBound || b.GetCumulativeCount() != s.Buckets[j].Count {
+					t.Errorf("%s bucket %d = %v, want %+v", s.Name, j, b, s.Buckets[j])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statreg provides atomic counters, gauges and histograms for the
+// internal statistics of the utility packages, collected in a registry that
+// can be snapshotted without Prometheus. Package statmetrics exports a
+// registry to Prometheus.
+package statreg
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// Kinds of Sample.
+const (
+	KindCounter   = "counter"
+	KindGauge     = "gauge"
+	KindHistogram = "histogram"
+)
+
+// namePattern is the one of Prometheus metric names, so that every
+// registered name can be exported unchanged.
+var namePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Default is the registry of the package level constructors.
+var Default = NewRegistry()
+
+type instrument interface {
+	sample(name string) Sample
+}
+
+// Registry holds instruments by unique name.
+type Registry struct {
+	mu          sync.RWMutex
+	instruments map[string]instrument
+}
+
+func NewRegistry() *Registry {
+	return &Registry{instruments: make(map[string]instrument)}
+}
+
+func (r *Registry) register(name string, i instrument) error {
+	if !namePattern.MatchString(name) {
+		return errs.ErrArgs.WrapMsg("invalid stat name", "name", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.instruments[name]; ok {
+		return errs.ErrArgs.WrapMsg("stat already registered", "name", name)
+	}
+	r.instruments[name] = i
+	return nil
+}
+
+// NewCounter registers a counter under name, which must be unused.
+func (r *Registry) NewCounter(name string) (*Counter, error) {
+	c := &Counter{}
+	if err := r.register(name, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewGauge registers a gauge under name, which must be unused.
+func (r *Registry) NewGauge(name string) (*Gauge, error) {
+	g := &Gauge{}
+	if err := r.register(name, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// NewHistogram registers a histogram under name, which must be unused, with
+// the given upper bounds in increasing order. Values above the last bound
+// are only counted in the total.
+func (r *Registry) NewHistogram(name string, buckets []float64) (*Histogram, error) {
+	if len(buckets) == 0 || !sort.Float64sAreSorted(buckets) {
+		return nil, errs.ErrArgs.WrapMsg("histogram buckets must be increasing", "name", name, "buckets", buckets)
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] == buckets[i-1] {
+			return nil, errs.ErrArgs.WrapMsg("histogram buckets must be increasing", "name", name, "buckets", buckets)
+		}
+	}
+	h := &Histogram{bounds: append([]float64(nil), buckets...), counts: make([]atomic.Uint64, len(buckets)+1)}
+	if err := r.register(name, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Snapshot returns the current value of every instrument, sorted by name.
+func (r *Registry) Snapshot() []Sample {
+	r.mu.RLock()
+	samples := make([]Sample, 0, len(r.instruments))
+	for name, i := range r.instruments {
+		samples = append(samples, i.sample(name))
+	}
+	r.mu.RUnlock()
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Name < samples[j].Name })
+	return samples
+}
+
+// NewCounter registers a counter in Default.
+func NewCounter(name string) (*Counter, error) { return Default.NewCounter(name) }
+
+// NewGauge registers a gauge in Default.
+func NewGauge(name string) (*Gauge, error) { return Default.NewGauge(name) }
+
+// NewHistogram registers a histogram in Default.
+func NewHistogram(name string, buckets []float64) (*Histogram, error) {
+	return Default.NewHistogram(name, buckets)
+}
+
+// Snapshot returns the samples of Default.
+func Snapshot() []Sample { return Default.Snapshot() }
+
+// MustNewCounter is like NewCounter but panics on error, for package level
+// variables.
+func MustNewCounter(name string) *Counter { return must(NewCounter(name)) }
+
+// MustNewGauge is like NewGauge but panics on error.
+func MustNewGauge(name string) *Gauge { return must(NewGauge(name)) }
+
+// MustNewHistogram is like NewHistogram but panics on error.
+func MustNewHistogram(name string, buckets []float64) *Histogram {
+	return must(NewHistogram(name, buckets))
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Sample is the value of an instrument at Snapshot time. Counters and gauges
+// set Value, histograms Count, Sum and Buckets.
+type Sample struct {
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"`
+	Value   float64  `json:"value"`
+	Count   uint64   `json:"count,omitempty"`
+	Sum     float64  `json:"sum,omitempty"`
+	Buckets []Bucket `json:"buckets,omitempty"`
+}
+
+// Bucket counts the observations up to UpperBound, inclusive and cumulative.
+type Bucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Add increments the counter by n.
+func (c *Counter) Add(n uint64) { c.v.Add(n) }
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() { c.v.Add(1) }
+
+// Load returns the current count.
+func (c *Counter) Load() uint64 { return c.v.Load() }
+
+func (c *Counter) sample(name string) Sample {
+	return Sample{Name: name, Kind: KindCounter, Value: float64(c.Load())}
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	v atomic.Int64
+}
+
+// Set replaces the value.
+func (g *Gauge) Set(v int64) { g.v.Store(v) }
+
+// Add adds delta, which may be negative.
+func (g *Gauge) Add(delta int64) { g.v.Add(delta) }
+
+// Load returns the current value.
+func (g *Gauge) Load() int64 { return g.v.Load() }
+
+func (g *Gauge) sample(name string) Sample {
+	return Sample{Name: name, Kind: KindGauge, Value: float64(g.Load())}
+}
+
+// Histogram counts observations in buckets. Observe costs an atomic add for
+// the bucket and a compare-and-swap loop for the sum.
+type Histogram struct {
+	bounds []float64
+	// counts has one more entry than bounds, for values above the last.
+	counts  []atomic.Uint64
+	sumBits atomic.Uint64
+}
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i].Add(1)
+	for {
+		old := h.sumBits.Load()
+		if h.sumBits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (h *Histogram) sample(name string) Sample {
+	s := Sample{Name: name, Kind: KindHistogram, Buckets: make([]Bucket, len(h.bounds))}
+	for i, bound := range h.bounds {
+		s.Count += h.counts[i].Load()
+		s.Buckets[i] = Bucket{UpperBound: bound, Count: s.Count}
+	}
+	s.Count += h.counts[len(h.bounds)].Load()
+	s.Sum = math.Float64frombits(h.sumBits.Load())
+	return s
+}
@@ -0,0 +1,138 @@
+package statreg
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c, err := r.NewCounter("hits_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, _ := r.NewGauge("queue_depth")
+	h, _ := r.NewHistogram("size_bytes", []float64{1024, 4096})
+	c.Add(2)
+	c.Inc()
+	g.Set(10)
+	g.Add(-4)
+	for _, v := range []float64{10, 1024, 2000, 1 << 20} {
+		h.Observe(v)
+	}
+
+	got := r.Snapshot()
+	want := []Sample{
+		{Name: "hits_total", Kind: KindCounter, Value: 3},
+		{Name: "queue_depth", Kind: KindGauge, Value: 6},
+		{Name: "size_bytes", Kind: KindHistogram, Count: 4, Sum: 10 + 1024 + 2000 + 1<<20,
+			Buckets: []Bucket{{UpperBound: 1024, Count: 2}, {UpperBound: 4096, Count: 3}}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("snapshot = %+v", got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Name != w.Name || g.Kind != w.Kind || g.Value != w.Value || g.Count != w.Count || g.Sum != w.Sum || len(g.Buckets) != len(w.Buckets) {
+			t.Errorf("sample %d = %+v, want %+v", i, g, w)
+			continue
+		}
+		for j := range w.Buckets {
+			if g.Buckets[j] != w.Buckets[j] {
+				t.Errorf("%s bucket %d = %+v, want %+v", g.Name, j, g.Buckets[j], w.Buckets[j])
+			}
+		}
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	r := NewRegistry()
+	if _, err := r.NewCounter("dup"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.NewGauge("dup"); err == nil {
+		t.Error("name collision across kinds accepted")
+	}
+	if _, err := r.NewCounter("dup"); err == nil {
+		t.Error("name collision accepted")
+	}
+	for _, name := range []string{"", "1st", "with space", "dash-ed"} {
+		if _, err := r.NewCounter(name); err == nil {
+			t.Errorf("invalid name %q accepted", name)
+		}
+	}
+	for _, buckets := range [][]float64{nil, {2, 1}, {1, 1}} {
+		if _, err := r.NewHistogram("h", buckets); err == nil {
+			t.Errorf("buckets %v accepted", buckets)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustNewCounter did not panic on a collision")
+		}
+	}()
+	MustNewCounter("statreg_test_must")
+	MustNewCounter("statreg_test_must")
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	r := NewRegistry()
+	c, _ := r.NewCounter("c")
+	h, _ := r.NewHistogram("h", []float64{1})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc()
+				h.Observe(0.5)
+				_ = r.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+	if c.Load() != 8000 {
+		t.Fatalf("counter = %d", c.Load())
+	}
+	if s := r.Snapshot()[1]; s.Count != 8000 || s.Sum != 4000 {
+		t.Fatalf("histogram = %+v", s)
+	}
+}
+
+func TestHotPathAllocations(t *testing.T) {
+	r := NewRegistry()
+	c, _ := r.NewCounter("c")
+	g, _ := r.NewGauge("g")
+	h, _ := r.NewHistogram("h", []float64{1, 2, 4})
+	if n := testing.AllocsPerRun(100, func() { c.Add(1); g.Set(2); h.Observe(3) }); n != 0 {
+		t.Errorf("updates allocate %v times", n)
+	}
+}
+
+func BenchmarkCounterAdd(b *testing.B) {
+	c, _ := NewRegistry().NewCounter("c")
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Add(1)
+		}
+	})
+}
+
+func BenchmarkGaugeSet(b *testing.B) {
+	g, _ := NewRegistry().NewGauge("g")
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.Set(1)
+		}
+	})
+}
+
+func BenchmarkHistogramObserve(b *testing.B) {
+	h, _ := NewRegistry().NewHistogram("h", []float64{0.001, 0.01, 0.1, 1, 10})
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			h.Observe(0.05)
+		}
+	})
+}
@@ -0,0 +1,218 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package swr caches the results of expensive loads with
+// stale-while-revalidate semantics: a value older than its freshness window
+// is still served at once while a single background load refreshes it.
+package swr
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/clockutil"
+)
+
+type options struct {
+	clock   clockutil.Clock
+	onError func(key any, err error)
+}
+
+type Option func(*options)
+
+// WithClock replaces the wall clock, for tests.
+func WithClock(clock clockutil.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithRefreshError sets a callback receiving the errors of background
+// refreshes, which keep serving the stale value and are otherwise silent.
+func WithRefreshError(fn func(key any, err error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// Stats counts the Gets of a Cache by the freshness of the value they found.
+type Stats struct {
+	// Hits are the Gets served a fresh value.
+	Hits uint64
+	// Stale are the Gets served a value past its freshness window, while it
+	// was refreshed in the background.
+	Stale uint64
+	// Misses are the Gets that waited for a load, the value being absent or
+	// past its hard TTL.
+	Misses uint64
+	// RefreshErrors are the failed background refreshes.
+	RefreshErrors uint64
+}
+
+type entry[V any] struct {
+	val      V
+	loadedAt time.Time
+}
+
+type call[V any] struct {
+	done       chan struct{}
+	val        V
+	err        error
+	background bool
+}
+
+// Cache memoizes values by key. A value younger than freshTTL is served as
+// it is; until hardTTL it is served stale and refreshed in the background;
+// after that, or when absent, Get waits for the load. Concurrent loads of a
+// key are shared. Entries are kept until Delete, so keys must come from a
+// bounded set.
+type Cache[K comparable, V any] struct {
+	freshTTL time.Duration
+	hardTTL  time.Duration
+	opts     options
+
+	lock     sync.Mutex
+	entries  map[K]entry[V]
+	inflight map[K]*call[V]
+
+	hits          atomic.Uint64
+	stale         atomic.Uint64
+	misses        atomic.Uint64
+	refreshErrors atomic.Uint64
+}
+
+// New returns a cache serving values stale between freshTTL and hardTTL. A
+// hardTTL below freshTTL is raised to it, disabling stale values.
+func New[K comparable, V any](freshTTL, hardTTL time.Duration, opts ...Option) *Cache[K, V] {
+	if hardTTL < freshTTL {
+		hardTTL = freshTTL
+	}
+	c := &Cache[K, V]{
+		freshTTL: freshTTL,
+		hardTTL:  hardTTL,
+		opts:     options{clock: clockutil.Real},
+		entries:  make(map[K]entry[V]),
+		inflight: make(map[K]*call[V]),
+	}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	return c
+}
+
+// Get returns the value of key, calling loader as described on Cache. The
+// loader runs detached from the cancellation of ctx, since other callers may
+// be waiting for it, so it should bound its own duration. Load errors are
+// returned to the waiting callers and are not cached.
+func (c *Cache[K, V]) Get(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	now := c.opts.clock.Now()
+	c.lock.Lock()
+	if e, ok := c.entries[key]; ok {
+		age := now.Sub(e.loadedAt)
+		if age < c.freshTTL {
+			c.lock.Unlock()
+			c.hits.Add(1)
+			return e.val, nil
+		}
+		if age < c.hardTTL {
+			c.loadLocked(ctx, key, loader, true)
+			c.lock.Unlock()
+			c.stale.Add(1)
+			return e.val, nil
+		}
+	}
+	cl := c.loadLocked(ctx, key, loader, false)
+	c.lock.Unlock()
+	c.misses.Add(1)
+
+	select {
+	case <-cl.done:
+		return cl.val, cl.err
+	case <-ctx.Done():
+		var zero V
+		return zero, errs.Wrap(ctx.Err())
+	}
+}
+
+// Delete drops the value of key, so that the next Get loads it again, for
+// instance after a write. A load in flight is not cancelled but its result is
+// only returned to the callers already waiting for it, never stored.
+func (c *Cache[K, V]) Delete(key K) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, key)
+	delete(c.inflight, key)
+}
+
+// Len returns the number of cached values, including those past hardTTL.
+func (c *Cache[K, V]) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.entries)
+}
+
+// Stats returns the counters since the cache was created.
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:          c.hits.Load(),
+		Stale:         c.stale.Load(),
+		Misses:        c.misses.Load(),
+		RefreshErrors: c.refreshErrors.Load(),
+	}
+}
+
+// loadLocked returns the load of key in flight, starting one if there is
+// none. It must be called with c.lock held.
+func (c *Cache[K, V]) loadLocked(ctx context.Context, key K, loader func(ctx context.Context) (V, error), background bool) *call[V] {
+	if cl, ok := c.inflight[key]; ok {
+		return cl
+	}
+	cl := &call[V]{done: make(chan struct{}), background: background}
+	c.inflight[key] = cl
+	go c.run(context.WithoutCancel(ctx), key, loader, cl)
+	return cl
+}
+
+func (c *Cache[K, V]) run(ctx context.Context, key K, loader func(ctx context.Context) (V, error), cl *call[V]) {
+	defer close(cl.done)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				cl.err = errs.ErrPanic(r)
+			}
+		}()
+		cl.val, cl.err = loader(ctx)
+	}()
+
+	c.lock.Lock()
+	// A call no longer in flight was dropped by Delete and its result is
+	// older than the deletion.
+	if c.inflight[key] == cl {
+		delete(c.inflight, key)
+		if cl.err == nil {
+			c.entries[key] = entry[V]{val: cl.val, loadedAt: c.opts.clock.Now()}
+		}
+	}
+	c.lock.Unlock()
+
+	if cl.err != nil && cl.background {
+		c.refreshErrors.Add(1)
+		if c.opts.onError != nil {
+			c.opts.onError(key, cl.err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package swr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openimsdk/tools/utils/clockutil/clockutiltest"
+)
+
+// counter is a loader returning 1, 2, 3... When release is set, each load
+// waits to receive from it.
+type counter struct {
+	calls   atomic.Int32
+	release chan struct{}
+	err     error
+}
+
+func (l *counter) load(context.Context) (int, error) {
+	n := l.calls.Add(1)
+	if l.release != nil {
+		<-l.release
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+	return int(n), nil
+}
+
+func newCache(opts ...Option) (*Cache[string, int], *clockutiltest.Fake) {
+	clock := clockutiltest.NewFake(time.Unix(1700000000, 0))
+	return New[string, int](time.Minute, time.Hour, append([]Option{WithClock(clock)}, opts...)...), clock
+}
+
+func mustGet(t *testing.T, c *Cache[string, int], loader func(context.Context) (int, error)) int {
+	t.Helper()
+	v, err := c.Get(context.Background(), "group-1", loader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestFresh(t *testing.T) {
+	c, clock := newCache()
+	var l counter
+	if v := mustGet(t, c, l.load); v != 1 {
+		t.Fatalf("first Get = %d", v)
+	}
+	clock.Advance(59 * time.Second)
+	if v := mustGet(t, c, l.load); v != 1 || l.calls.Load() != 1 {
+		t.Fatalf("fresh Get = %d after %d loads", v, l.calls.Load())
+	}
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 || s.Stale != 0 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	c, clock := newCache()
+	l := counter{release: make(chan struct{}, 1)}
+	l.release <- struct{}{}
+	mustGet(t, c, l.load)
+	clock.Advance(2 * time.Minute)
+
+	// Concurrent Gets during the refresh are all served the stale value and
+	// share one load.
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v := mustGet(t, c, l.load); v != 1 {
+				t.Errorf("Get during refresh = %d, want the stale 1", v)
+			}
+		}()
+	}
+	wg.Wait()
+	waitFor(t, func() bool { return l.calls.Load() >= 2 })
+	if n := l.calls.Load(); n != 2 {
+		t.Fatalf("loads = %d, want 1 refresh", n)
+	}
+	l.release <- struct{}{}
+	waitFor(t, func() bool { return mustGet(t, c, l.load) == 2 })
+	if s := c.Stats(); s.Stale < 16 {
+		t.Fatalf("stats = %+v, want 16 stale", s)
+	}
+}
+
+func TestHardTTL(t *testing.T) {
+	c, clock := newCache()
+	var l counter
+	mustGet(t, c, l.load)
+	clock.Advance(time.Hour)
+	if v := mustGet(t, c, l.load); v != 2 {
+		t.Fatalf("Get past hardTTL = %d, want the reloaded 2", v)
+	}
+
+	clock.Advance(time.Hour)
+	l.err = errors.New("mongo unavailable")
+	if _, err := c.Get(context.Background(), "group-1", l.load); !errors.Is(err, l.err) {
+		t.Fatalf("Get past hardTTL with a failing loader = %v", err)
+	}
+	if s := c.Stats(); s.Misses != 3 || s.RefreshErrors != 0 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestRefreshErrorKeepsStale(t *testing.T) {
+	reported := make(chan error, 1)
+	c, clock := newCache(WithRefreshError(func(key any, err error) {
+		if key != "group-1" {
+			t.Errorf("key = %v", key)
+		}
+		reported <- err
+	}))
+	var l counter
+	mustGet(t, c, l.load)
+	clock.Advance(2 * time.Minute)
+	l.err = errors.New("mongo unavailable")
+	if v := mustGet(t, c, l.load); v != 1 {
+		t.Fatalf("stale Get = %d", v)
+	}
+	if err := <-reported; !errors.Is(err, l.err) {
+		t.Fatalf("reported %v", err)
+	}
+	if v := mustGet(t, c, l.load); v != 1 {
+		t.Fatalf("Get after a failed refresh = %d, want the stale 1", v)
+	}
+	waitFor(t, func() bool { return c.Stats().RefreshErrors == 2 })
+}
+
+func TestGetCancelled(t *testing.T) {
+	c, _ := newCache()
+	l := counter{release: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Get(ctx, "group-1", l.load); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+	// The load goes on for the next callers.
+	close(l.release)
+	waitFor(t, func() bool { return c.Len() == 1 })
+	if v := mustGet(t, c, l.load); v != 1 || l.calls.Load() != 1 {
+		t.Fatalf("Get = %d after %d loads", v, l.calls.Load())
+	}
+}
+
+func TestDeleteDuringLoad(t *testing.T) {
+	c, _ := newCache()
+	l := counter{release: make(chan struct{}, 2)}
+	first := make(chan int, 1)
+	go func() { first <- mustGet(t, c, l.load) }()
+	waitFor(t, func() bool { return l.calls.Load() == 1 })
+
+	// The value is written while the first load runs: its result is stale.
+	c.Delete("group-1")
+	l.release <- struct{}{}
+	if v := <-first; v != 1 {
+		t.Fatalf("waiting Get = %d, want the result of its load", v)
+	}
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Len = %d, the load started before Delete was stored", n)
+	}
+	l.release <- struct{}{}
+	if v := mustGet(t, c, l.load); v != 2 {
+		t.Fatalf("Get after Delete = %d, want the reloaded 2", v)
+	}
+}
+
+func TestLoaderPanic(t *testing.T) {
+	c, _ := newCache()
+	_, err := c.Get(context.Background(), "group-1", func(context.Context) (int, error) { panic("boom") })
+	if err == nil {
+		t.Fatal("panic not returned as an error")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
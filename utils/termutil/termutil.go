@@ -0,0 +1,127 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package termutil detects whether the terminal a process writes to renders
+// ANSI colors.
+package termutil
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// ColorMode overrides the detection of ColorEnabled.
+type ColorMode uint32
+
+const (
+	// ColorAuto follows the environment and the output stream.
+	ColorAuto ColorMode = iota
+	// ColorAlways writes colors, also to files and pipes.
+	ColorAlways
+	// ColorNever writes plain text.
+	ColorNever
+)
+
+func (m ColorMode) String() string {
+	switch m {
+	case ColorAlways:
+		return "always"
+	case ColorNever:
+		return "never"
+	default:
+		return "auto"
+	}
+}
+
+// ParseColorMode parses auto, always or never. The empty string is auto.
+func ParseColorMode(s string) (ColorMode, error) {
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return ColorAuto, nil
+	case "always":
+		return ColorAlways, nil
+	case "never":
+		return ColorNever, nil
+	}
+	return ColorAuto, errs.ErrArgs.WrapMsg("unknown color mode, want auto, always or never", "mode", s)
+}
+
+var colorMode atomic.Uint32
+
+// SetColorMode overrides the detection for the whole process.
+func SetColorMode(mode ColorMode) {
+	colorMode.Store(uint32(mode))
+}
+
+// GetColorMode returns the mode set by SetColorMode, ColorAuto by default.
+func GetColorMode() ColorMode {
+	return ColorMode(colorMode.Load())
+}
+
+// ColorEnabled reports whether colors written to f are rendered. Unless
+// SetColorMode overrides it, colors are disabled by a non-empty NO_COLOR, by
+// TERM=dumb and when f is not a terminal, and FORCE_COLOR enables them except
+// when it is 0 or false. NO_COLOR wins over FORCE_COLOR. On Windows, the
+// virtual terminal processing of the console is enabled, and colors are
+// disabled on consoles that do not support it.
+func ColorEnabled(f *os.File) bool {
+	return detect(GetColorMode(), os.LookupEnv, isTerminal(f), func() bool { return enableVirtualTerminal(f) })
+}
+
+// ColorAllowed reports whether colors may be written to outputs that are not
+// terminals, such as log files: only SetColorMode and NO_COLOR disable them.
+func ColorAllowed() bool {
+	switch GetColorMode() {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	return os.Getenv("NO_COLOR") == ""
+}
+
+func detect(mode ColorMode, lookupEnv func(string) (string, bool), terminal bool, enableVT func() bool) bool {
+	switch mode {
+	case ColorAlways:
+		enableVT()
+		return true
+	case ColorNever:
+		return false
+	}
+	if v, _ := lookupEnv("NO_COLOR"); v != "" {
+		return false
+	}
+	if v, _ := lookupEnv("FORCE_COLOR"); v != "" {
+		if v == "0" || strings.EqualFold(v, "false") {
+			return false
+		}
+		enableVT()
+		return true
+	}
+	if v, _ := lookupEnv("TERM"); v == "dumb" {
+		return false
+	}
+	return terminal && enableVT()
+}
+
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,104 @@
+package termutil
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     ColorMode
+		env      map[string]string
+		terminal bool
+		vt       bool
+		want     bool
+	}{
+		{name: "terminal", terminal: true, vt: true, want: true},
+		{name: "pipe", vt: true},
+		{name: "no virtual terminal", terminal: true},
+		{name: "NO_COLOR", env: map[string]string{"NO_COLOR": "1"}, terminal: true, vt: true},
+		{name: "empty NO_COLOR", env: map[string]string{"NO_COLOR": ""}, terminal: true, vt: true, want: true},
+		{name: "FORCE_COLOR pipe", env: map[string]string{"FORCE_COLOR": "1"}, want: true},
+		{name: "FORCE_COLOR 0", env: map[string]string{"FORCE_COLOR": "0"}, terminal: true, vt: true},
+		{name: "FORCE_COLOR false", env: map[string]string{"FORCE_COLOR": "false"}, terminal: true, vt: true},
+		{name: "NO_COLOR wins", env: map[string]string{"NO_COLOR": "1", "FORCE_COLOR": "1"}, terminal: true, vt: true},
+		{name: "dumb", env: map[string]string{"TERM": "dumb"}, terminal: true, vt: true},
+		{name: "FORCE_COLOR dumb", env: map[string]string{"TERM": "dumb", "FORCE_COLOR": "3"}, want: true},
+		{name: "always", mode: ColorAlways, env: map[string]string{"NO_COLOR": "1"}, want: true},
+		{name: "never", mode: ColorNever, env: map[string]string{"FORCE_COLOR": "1"}, terminal: true, vt: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detect(tt.mode, env(tt.env), tt.terminal, func() bool { return tt.vt }); got != tt.want {
+				t.Errorf("detect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColorEnabledFile(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "")
+	f, err := os.CreateTemp(t.TempDir(), "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if ColorEnabled(f) {
+		t.Error("colors enabled on a regular file")
+	}
+	if ColorEnabled(nil) {
+		t.Error("colors enabled without a file")
+	}
+	if !ColorAllowed() {
+		t.Error("colors not allowed in files by default")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if ColorAllowed() {
+		t.Error("colors allowed with NO_COLOR")
+	}
+	SetColorMode(ColorAlways)
+	defer SetColorMode(ColorAuto)
+	if !ColorEnabled(f) || !ColorAllowed() {
+		t.Error("ColorAlways does not override the detection")
+	}
+}
+
+func TestParseColorMode(t *testing.T) {
+	for s, want := range map[string]ColorMode{"": ColorAuto, "auto": ColorAuto, "Always": ColorAlways, "never": ColorNever} {
+		if got, err := ParseColorMode(s); err != nil || got != want {
+			t.Errorf("ParseColorMode(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseColorMode("yes"); err == nil {
+		t.Error("ParseColorMode accepted yes")
+	}
+}
+
+// TestWindowsBuild compiles the console API code path, which only the Windows
+// builds exercise.
+func TestWindowsBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("cross compilation is slow")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	for _, arch := range []string{"amd64", "386"} {
+		cmd := exec.Command(goBin, "vet", ".")
+		cmd.Env = append(os.Environ(), "GOOS=windows", "GOARCH="+arch)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("windows/%s: %v\n%s", arch, err, out)
+		}
+	}
+}
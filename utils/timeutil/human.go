@@ -0,0 +1,222 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timeutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLanguage is the last language of every fallback chain.
+const DefaultLanguage = "en"
+
+const day = 24 * time.Hour
+
+type plural struct {
+	one, other string
+}
+
+func (p plural) format(n int) string {
+	if n == 1 {
+		return fmt.Sprintf(p.one, n)
+	}
+	return fmt.Sprintf(p.other, n)
+}
+
+// timeCatalog holds the messages of one language. Layouts use the time
+// package reference time.
+type timeCatalog struct {
+	justNow    string
+	minutesAgo plural
+	hoursAgo   plural
+	daysAgo    plural
+	inMinutes  plural
+	inHours    plural
+	inDays     plural
+	// yesterday and tomorrow take the clock time.
+	yesterday string
+	tomorrow  string
+
+	clock     string
+	date      string // date layout within the current year
+	dateYear  string // date layout with the year
+	dateClock string // joins a date and a clock time
+}
+
+var timeCatalogs = map[string]*timeCatalog{
+	"en": {
+		justNow:    "just now",
+		minutesAgo: plural{"%d minute ago", "%d minutes ago"},
+		hoursAgo:   plural{"%d hour ago", "%d hours ago"},
+		daysAgo:    plural{"%d day ago", "%d days ago"},
+		inMinutes:  plural{"in %d minute", "in %d minutes"},
+		inHours:    plural{"in %d hour", "in %d hours"},
+		inDays:     plural{"in %d day", "in %d days"},
+		yesterday:  "yesterday %s",
+		tomorrow:   "tomorrow %s",
+		clock:      "15:04",
+		date:       "Jan 2",
+		dateYear:   "Jan 2, 2006",
+		dateClock:  "%s, %s",
+	},
+	"zh": {
+		justNow:    "刚刚",
+		minutesAgo: plural{"%d分钟前", "%d分钟前"},
+		hoursAgo:   plural{"%d小时前", "%d小时前"},
+		daysAgo:    plural{"%d天前", "%d天前"},
+		inMinutes:  plural{"%d分钟后", "%d分钟后"},
+		inHours:    plural{"%d小时后", "%d小时后"},
+		inDays:     plural{"%d天后", "%d天后"},
+		yesterday:  "昨天 %s",
+		tomorrow:   "明天 %s",
+		clock:      "15:04",
+		date:       "1月2日",
+		dateYear:   "2006年1月2日",
+		dateClock:  "%s %s",
+	},
+}
+
+// LanguageChain returns the languages tried for lang, from the most to the
+// least specific: "zh-Hans-CN" gives zh-hans-cn, zh-hans, zh and en.
+func LanguageChain(lang string) []string {
+	lang = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+	var chain []string
+	for lang != "" {
+		chain = append(chain, lang)
+		i := strings.LastIndexByte(lang, '-')
+		if i < 0 {
+			break
+		}
+		lang = lang[:i]
+	}
+	if len(chain) == 0 || chain[len(chain)-1] != DefaultLanguage {
+		chain = append(chain, DefaultLanguage)
+	}
+	return chain
+}
+
+func catalog(lang string) *timeCatalog {
+	for _, l := range LanguageChain(lang) {
+		if c, ok := timeCatalogs[l]; ok {
+			return c
+		}
+	}
+	return timeCatalogs[DefaultLanguage]
+}
+
+// HumanDuration formats d with its two most significant units, truncating
+// the rest: "3d4h", "2h15m", "1m30s", "45s". The second unit is left out
+// when it is zero. Durations under a second use time.Duration.String.
+func HumanDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + HumanDuration(-d)
+	}
+	if d < time.Second {
+		return d.String()
+	}
+	units := []struct {
+		size time.Duration
+		name string
+	}{{day, "d"}, {time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"}}
+	for i, u := range units {
+		if d < u.size {
+			continue
+		}
+		s := strconv.FormatInt(int64(d/u.size), 10) + u.name
+		if i+1 < len(units) {
+			next := units[i+1]
+			if n := (d % u.size) / next.size; n > 0 {
+				s += strconv.FormatInt(int64(n), 10) + next.name
+			}
+		}
+		return s
+	}
+	return d.String()
+}
+
+// RelativeTime describes t relative to now in lang, e.g. "5 minutes ago",
+// "昨天 14:02" or "in 3 days". Calendar days are those of now's location.
+// Times more than a week away are formatted as a date.
+func RelativeTime(t, now time.Time, lang string) string {
+	c := catalog(lang)
+	t = t.In(now.Location())
+	diff := now.Sub(t)
+	past := diff >= 0
+	if !past {
+		diff = -diff
+	}
+	days := calendarDays(t, now)
+	switch {
+	case diff < time.Minute:
+		return c.justNow
+	case diff < time.Hour:
+		if past {
+			return c.minutesAgo.format(int(diff / time.Minute))
+		}
+		return c.inMinutes.format(int(diff / time.Minute))
+	case days == 0:
+		if past {
+			return c.hoursAgo.format(int(diff / time.Hour))
+		}
+		return c.inHours.format(int(diff / time.Hour))
+	case days == 1:
+		return fmt.Sprintf(c.yesterday, t.Format(c.clock))
+	case days == -1:
+		return fmt.Sprintf(c.tomorrow, t.Format(c.clock))
+	case days > 1 && days < 7:
+		return c.daysAgo.format(days)
+	case days < -1 && days > -7:
+		return c.inDays.format(-days)
+	}
+	return formatDate(c, t, now.Year())
+}
+
+// FormatRange formats the interval from start to end in loc, writing the date
+// only once when both are on the same day: "May 3, 09:00–11:30". The year is
+// included when the range is not within a single year. A nil loc uses the
+// location of start.
+func FormatRange(start, end time.Time, loc *time.Location, lang string) string {
+	c := catalog(lang)
+	if loc == nil {
+		loc = start.Location()
+	}
+	start, end = start.In(loc), end.In(loc)
+	startDate := formatDate(c, start, end.Year())
+	if calendarDays(start, end) == 0 {
+		return fmt.Sprintf(c.dateClock, startDate, start.Format(c.clock)+"–"+end.Format(c.clock))
+	}
+	return fmt.Sprintf(c.dateClock, startDate, start.Format(c.clock)) + " – " +
+		fmt.Sprintf(c.dateClock, formatDate(c, end, start.Year()), end.Format(c.clock))
+}
+
+func formatDate(c *timeCatalog, t time.Time, refYear int) string {
+	if t.Year() == refYear {
+		return t.Format(c.date)
+	}
+	return t.Format(c.dateYear)
+}
+
+// calendarDays returns how many calendar days t is before now, negative when
+// t is after it. Both are taken in now's location.
+func calendarDays(t, now time.Time) int {
+	t = t.In(now.Location())
+	ty, tm, td := t.Date()
+	ny, nm, nd := now.Date()
+	a := time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC)
+	b := time.Date(ny, nm, nd, 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a) / day)
+}
@@ -0,0 +1,119 @@
+package timeutil
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{350 * time.Millisecond, "350ms"},
+		{time.Second, "1s"},
+		{45 * time.Second, "45s"},
+		{90 * time.Second, "1m30s"},
+		{time.Hour, "1h"},
+		{2*time.Hour + 15*time.Minute + 59*time.Second, "2h15m"},
+		{2*time.Hour + 30*time.Second, "2h"},
+		{3*day + 4*time.Hour + 5*time.Minute, "3d4h"},
+		{400 * day, "400d"},
+		{-90 * time.Minute, "-1h30m"},
+	}
+	for _, tt := range tests {
+		if got := HumanDuration(tt.d); got != tt.want {
+			t.Errorf("HumanDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	now := time.Date(2024, 5, 3, 15, 30, 0, 0, shanghai)
+	tests := []struct {
+		t    time.Time
+		lang string
+		want string
+	}{
+		{now, "en", "just now"},
+		{now.Add(-59 * time.Second), "en", "just now"},
+		{now.Add(30 * time.Second), "en", "just now"},
+		{now.Add(-time.Minute), "en", "1 minute ago"},
+		{now.Add(-5 * time.Minute), "en", "5 minutes ago"},
+		{now.Add(-5 * time.Minute), "zh", "5分钟前"},
+		{now.Add(5 * time.Minute), "en", "in 5 minutes"},
+		{now.Add(5 * time.Minute), "zh", "5分钟后"},
+		{now.Add(-59 * time.Minute), "en", "59 minutes ago"},
+		{now.Add(-time.Hour), "en", "1 hour ago"},
+		{now.Add(-15 * time.Hour), "zh", "15小时前"},
+		{now.Add(3 * time.Hour), "en", "in 3 hours"},
+		// 15:31 the day before is a different calendar day.
+		{now.Add(-24*time.Hour + time.Minute), "en", "yesterday 15:31"},
+		{time.Date(2024, 5, 2, 14, 2, 0, 0, shanghai), "zh", "昨天 14:02"},
+		{time.Date(2024, 5, 2, 23, 59, 0, 0, shanghai), "en", "yesterday 23:59"},
+		{time.Date(2024, 5, 4, 9, 0, 0, 0, shanghai), "en", "tomorrow 09:00"},
+		{time.Date(2024, 5, 4, 9, 0, 0, 0, shanghai), "zh", "明天 09:00"},
+		{time.Date(2024, 5, 1, 9, 0, 0, 0, shanghai), "en", "2 days ago"},
+		{time.Date(2024, 4, 27, 9, 0, 0, 0, shanghai), "zh", "6天前"},
+		{time.Date(2024, 5, 6, 9, 0, 0, 0, shanghai), "en", "in 3 days"},
+		{time.Date(2024, 4, 26, 9, 0, 0, 0, shanghai), "en", "Apr 26"},
+		{time.Date(2024, 4, 26, 9, 0, 0, 0, shanghai), "zh", "4月26日"},
+		{time.Date(2023, 12, 31, 9, 0, 0, 0, shanghai), "en", "Dec 31, 2023"},
+		{time.Date(2023, 12, 31, 9, 0, 0, 0, shanghai), "zh", "2023年12月31日"},
+		// Times are compared in now's location: 20:00 UTC on May 2 is May 3 in Shanghai.
+		{time.Date(2024, 5, 2, 20, 0, 0, 0, time.UTC), "en", "11 hours ago"},
+		// Fallback chain.
+		{now.Add(-5 * time.Minute), "zh-Hans-CN", "5分钟前"},
+		{now.Add(-5 * time.Minute), "zh_TW", "5分钟前"},
+		{now.Add(-5 * time.Minute), "fr", "5 minutes ago"},
+		{now.Add(-5 * time.Minute), "", "5 minutes ago"},
+	}
+	for _, tt := range tests {
+		if got := RelativeTime(tt.t, now, tt.lang); got != tt.want {
+			t.Errorf("RelativeTime(%v, %s) = %q, want %q", tt.t, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestFormatRange(t *testing.T) {
+	utc := time.UTC
+	shanghai := time.FixedZone("CST", 8*3600)
+	at := func(y int, m time.Month, d, h, min int) time.Time { return time.Date(y, m, d, h, min, 0, 0, utc) }
+	tests := []struct {
+		start, end time.Time
+		loc        *time.Location
+		lang       string
+		want       string
+	}{
+		{at(2024, 5, 3, 9, 0), at(2024, 5, 3, 11, 30), utc, "en", "May 3, 09:00–11:30"},
+		{at(2024, 5, 3, 9, 0), at(2024, 5, 3, 11, 30), utc, "zh", "5月3日 09:00–11:30"},
+		{at(2024, 5, 3, 22, 0), at(2024, 5, 4, 1, 0), utc, "en", "May 3, 22:00 – May 4, 01:00"},
+		// The same instants fall on a single day in Shanghai.
+		{at(2024, 5, 3, 22, 0), at(2024, 5, 4, 1, 0), shanghai, "en", "May 4, 06:00–09:00"},
+		{at(2023, 12, 31, 22, 0), at(2024, 1, 1, 2, 0), utc, "en", "Dec 31, 2023, 22:00 – Jan 1, 2024, 02:00"},
+		{at(2023, 12, 31, 22, 0), at(2024, 1, 1, 2, 0), utc, "zh-CN", "2023年12月31日 22:00 – 2024年1月1日 02:00"},
+		{at(2024, 5, 3, 9, 0), at(2024, 5, 3, 9, 0), nil, "fr", "May 3, 09:00–09:00"},
+	}
+	for _, tt := range tests {
+		if got := FormatRange(tt.start, tt.end, tt.loc, tt.lang); got != tt.want {
+			t.Errorf("FormatRange(%v, %v, %v, %s) = %q, want %q", tt.start, tt.end, tt.loc, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageChain(t *testing.T) {
+	tests := map[string][]string{
+		"zh-Hans-CN": {"zh-hans-cn", "zh-hans", "zh", "en"},
+		"en_US":      {"en-us", "en"},
+		"en":         {"en"},
+		"":           {"en"},
+	}
+	for lang, want := range tests {
+		if got := LanguageChain(lang); !reflect.DeepEqual(got, want) {
+			t.Errorf("LanguageChain(%q) = %v, want %v", lang, got, want)
+		}
+	}
+}
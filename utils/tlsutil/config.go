@@ -0,0 +1,63 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlsutil
+
+import (
+	"crypto/tls"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// Config is the TLS section of the configuration of a component client. The
+// files are PEM encoded.
+type Config struct {
+	EnableTLS bool
+	// CAFile verifies the server certificates instead of the system roots,
+	// for servers signed by a private CA.
+	CAFile string
+	// CertFile and KeyFile are the client certificate, for servers that
+	// require one, such as mutual TLS or MongoDB X.509 authentication.
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// Validate checks that the files are only set with EnableTLS, and the client
+// certificate and key together. It does not read the files, see Load.
+func (c *Config) Validate() error {
+	if !c.EnableTLS {
+		if c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" {
+			return errs.ErrConfig.WrapMsg("TLS files are set but enableTLS is false",
+				"caFile", c.CAFile, "certFile", c.CertFile, "keyFile", c.KeyFile)
+		}
+		return nil
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errs.ErrConfig.WrapMsg("TLS certFile and keyFile must be set together", "certFile", c.CertFile, "keyFile", c.KeyFile)
+	}
+	return nil
+}
+
+// Load validates c and returns the client TLS configuration it describes, see
+// the package function Load, or nil when TLS is disabled.
+func (c *Config) Load(opts ...Option) (*tls.Config, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if !c.EnableTLS {
+		return nil, nil
+	}
+	return Load(c.CAFile, c.CertFile, c.KeyFile, c.InsecureSkipVerify, opts...)
+}
@@ -0,0 +1,46 @@
+package tlsutil
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+func TestConfigLoad(t *testing.T) {
+	caPath, certPath, keyPath := writePair(t, t.TempDir())
+	conf, err := (&Config{EnableTLS: true, CAFile: caPath, CertFile: certPath, KeyFile: keyPath}).Load()
+	if err != nil || conf.RootCAs == nil || len(conf.Certificates) != 1 {
+		t.Fatalf("conf = %+v, %v", conf, err)
+	}
+	if conf, err := (&Config{}).Load(); conf != nil || err != nil {
+		t.Fatalf("disabled TLS: %+v, %v", conf, err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	for name, tt := range map[string]struct {
+		conf Config
+		msg  string
+	}{
+		"files without enableTLS": {Config{CAFile: "ca.pem"}, "ca.pem"},
+		"cert without key":        {Config{EnableTLS: true, CertFile: "client.pem"}, "client.pem"},
+		"key without cert":        {Config{EnableTLS: true, KeyFile: "client.key"}, "client.key"},
+	} {
+		err := tt.conf.Validate()
+		if !errors.Is(err, errs.ErrConfig) || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: err = %v, want ErrConfig naming %s", name, err, tt.msg)
+		}
+	}
+	// Validate does not read the files, Load does.
+	conf := Config{EnableTLS: true, CAFile: missing}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conf.Load(); !errors.Is(err, errs.ErrConfig) {
+		t.Fatalf("missing caFile: err = %v, want ErrConfig", err)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tlsutil builds the client TLS configurations of the components from
+// their PEM files.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+type options struct {
+	keyPassword []byte
+}
+
+type Option func(*options)
+
+// WithKeyPassword decrypts a client key encrypted with the legacy PEM
+// encryption. Unencrypted keys are unaffected.
+func WithKeyPassword(password []byte) Option {
+	return func(o *options) {
+		o.keyPassword = password
+	}
+}
+
+// Load returns a client TLS configuration that verifies the servers with the
+// CA certificates of the file ca, or the system roots when ca is empty, and
+// presents the certificate of the files cert and key, which must be set
+// together. A file that cannot be read or parsed is reported as
+// errs.ErrConfig, with its path.
+func Load(ca, cert, key string, insecureSkipVerify bool, opts ...Option) (*tls.Config, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if (cert == "") != (key == "") {
+		return nil, errs.ErrConfig.WrapMsg("TLS certFile and keyFile must be set together", "certFile", cert, "keyFile", key)
+	}
+	conf := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if cert != "" {
+		certPEM, err := readFile(cert)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM, err := readFile(key)
+		if err != nil {
+			return nil, err
+		}
+		if keyPEM, err = decryptPEM(keyPEM, o.keyPassword); err != nil {
+			return nil, errs.ErrConfig.WrapMsg("cannot decrypt TLS keyFile", "file", key, "err", err.Error())
+		}
+		pair, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, errs.ErrConfig.WrapMsg("invalid TLS client certificate", "certFile", cert, "keyFile", key, "err", err.Error())
+		}
+		conf.Certificates = []tls.Certificate{pair}
+	}
+	if ca != "" {
+		caPEM, err := readFile(ca)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errs.ErrConfig.WrapMsg("no certificate in TLS caFile", "file", ca)
+		}
+		conf.RootCAs = pool
+	}
+	return conf, nil
+}
+
+func readFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errs.ErrConfig.WrapMsg("cannot read TLS file", "file", path, "err", err.Error())
+	}
+	return data, nil
+}
+
+// decryptPEM decrypts the first PEM block of data with password.
+func decryptPEM(data, password []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return data, nil
+	}
+	b, _ := pem.Decode(data)
+	if b == nil {
+		return nil, errs.New("no PEM block").Wrap()
+	}
+	d, err := x509.DecryptPEMBlock(b, password)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "DecryptPEMBlock failed")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: b.Type, Bytes: d}), nil
+}
@@ -0,0 +1,68 @@
+package tlsutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/tlsutil/tlstest"
+)
+
+// writePair writes a client certificate signed by a new CA to dir and returns
+// the CA, certificate and key paths.
+func writePair(t *testing.T, dir string) (string, string, string) {
+	t.Helper()
+	ca, caKey, caPath := tlstest.NewCA(t, dir, "ca")
+	certPath, keyPath := tlstest.ClientCert(t, ca, caKey, dir, "client")
+	return caPath, certPath, keyPath
+}
+
+func TestLoad(t *testing.T) {
+	caPath, certPath, keyPath := writePair(t, t.TempDir())
+	conf, err := Load(caPath, certPath, keyPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.RootCAs == nil || len(conf.Certificates) != 1 || conf.InsecureSkipVerify {
+		t.Fatalf("conf = %+v", conf)
+	}
+	if conf, err := Load("", "", "", true); err != nil || conf.RootCAs != nil || !conf.InsecureSkipVerify {
+		t.Fatalf("empty files: %+v, %v", conf, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	caPath, certPath, keyPath := writePair(t, dir)
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+	tests := map[string]struct {
+		ca, cert, key string
+		opts          []Option
+		file          string
+	}{
+		"missing ca":       {ca: missing, file: missing},
+		"malformed ca":     {ca: garbage, file: garbage},
+		"missing cert":     {cert: missing, key: keyPath, file: missing},
+		"missing key":      {cert: certPath, key: missing, file: missing},
+		"malformed pair":   {cert: caPath, key: garbage, file: garbage},
+		"encrypted key":    {cert: certPath, key: garbage, opts: []Option{WithKeyPassword([]byte("secret"))}, file: garbage},
+		"cert without key": {cert: certPath, file: certPath},
+	}
+	for name, tt := range tests {
+		_, err := Load(tt.ca, tt.cert, tt.key, false, tt.opts...)
+		if !errors.Is(err, errs.ErrConfig) {
+			t.Errorf("%s: err = %v, want ErrConfig", name, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.file) {
+			t.Errorf("%s: err = %v, want %s", name, err, tt.file)
+		}
+	}
+}
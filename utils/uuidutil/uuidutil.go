@@ -0,0 +1,179 @@
+// Copyright © 2024 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package uuidutil generates and parses time-ordered UUIDv7 values (RFC 9562)
+// for keys that benefit from insertion order, such as object storage keys
+// indexed in MongoDB.
+package uuidutil
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openimsdk/tools/errs"
+)
+
+// UUID is a 128 bit UUID, as in github.com/google/uuid.
+type UUID = uuid.UUID
+
+// ShortLen is the length of the base64url form returned by Short.
+const ShortLen = 22
+
+const maxCounter = 0xfff // the 12 bit rand_a field
+
+// Generator produces UUIDv7 values that strictly increase within the
+// generator, even when the clock does not advance or steps back: the rand_a
+// field holds a counter seeded randomly on every new millisecond, and the
+// timestamp is moved forward by one millisecond when the counter overflows.
+type Generator struct {
+	lock    sync.Mutex
+	now     func() time.Time
+	rand    io.Reader
+	lastMS  int64
+	counter uint16
+}
+
+// NewGenerator returns a Generator reading the system clock and crypto/rand.
+func NewGenerator() *Generator {
+	return &Generator{now: time.Now, rand: rand.Reader}
+}
+
+var defaultGenerator = NewGenerator()
+
+// NewV7 returns a new UUIDv7 from the process-wide generator. It panics if
+// the system random source fails, as uuid.New does.
+func NewV7() UUID {
+	u, err := defaultGenerator.New()
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// NewV7Batch returns n increasing UUIDv7 values from the process-wide
+// generator.
+func NewV7Batch(n int) []UUID {
+	us, err := defaultGenerator.NewBatch(n)
+	if err != nil {
+		panic(err)
+	}
+	return us
+}
+
+// New returns a UUIDv7 greater than every value the generator returned before.
+func (g *Generator) New() (UUID, error) {
+	var buf [10]byte
+	if _, err := io.ReadFull(g.rand, buf[:]); err != nil {
+		return UUID{}, errs.WrapMsg(err, "read random bytes failed")
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.next(buf), nil
+}
+
+// NewBatch returns n increasing UUIDv7 values.
+func (g *Generator) NewBatch(n int) ([]UUID, error) {
+	random := make([]byte, 10*n)
+	if _, err := io.ReadFull(g.rand, random); err != nil {
+		return nil, errs.WrapMsg(err, "read random bytes failed")
+	}
+	us := make([]UUID, n)
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for i := range us {
+		us[i] = g.next([10]byte(random[10*i:]))
+	}
+	return us, nil
+}
+
+// next builds a UUID from buf, whose first 2 bytes seed the counter on a new
+// millisecond and last 8 bytes fill rand_b. g.lock must be held.
+func (g *Generator) next(buf [10]byte) UUID {
+	ms := g.now().UnixMilli()
+	if ms > g.lastMS {
+		g.lastMS = ms
+		g.counter = binary.BigEndian.Uint16(buf[:2]) & maxCounter
+	} else if g.counter++; g.counter > maxCounter {
+		g.lastMS++
+		g.counter = 0
+	}
+
+	var u UUID
+	binary.BigEndian.PutUint64(u[:8], uint64(g.lastMS)<<16)
+	u[6] = 0x70 | byte(g.counter>>8)
+	u[7] = byte(g.counter)
+	copy(u[8:], buf[2:])
+	u[8] = 0x80 | u[8]&0x3f
+	return u
+}
+
+// Parse parses s in the canonical 36 character form and verifies that it is
+// an RFC 9562 version 7 UUID.
+func Parse(s string) (UUID, error) {
+	u, err := uuid.Parse(s)
+	if err != nil {
+		return UUID{}, errs.ErrArgs.WrapMsg("invalid UUID", "uuid", s, "err", err.Error())
+	}
+	if err := check(u); err != nil {
+		return UUID{}, err
+	}
+	return u, nil
+}
+
+// Validate reports whether s is a UUIDv7 in the canonical form.
+func Validate(s string) error {
+	_, err := Parse(s)
+	return err
+}
+
+func check(u UUID) error {
+	if u.Version() != 7 || u.Variant() != uuid.RFC4122 {
+		return errs.ErrArgs.WrapMsg("not a version 7 UUID", "uuid", u.String(), "version", int(u.Version()))
+	}
+	return nil
+}
+
+// TimeOf returns the creation time of u, with millisecond precision. Retention
+// jobs can compare it with a cutoff without an extra timestamp field.
+func TimeOf(u UUID) (time.Time, error) {
+	if err := check(u); err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(u[:8]) >> 16)), nil
+}
+
+// Short returns the 22 character base64url form of u, for URLs.
+func Short(u UUID) string {
+	return base64.RawURLEncoding.EncodeToString(u[:])
+}
+
+// ParseShort parses the form returned by Short.
+func ParseShort(s string) (UUID, error) {
+	var u UUID
+	if len(s) != ShortLen {
+		return u, errs.ErrArgs.WrapMsg("invalid short UUID length", "uuid", s, "len", len(s))
+	}
+	if _, err := base64.RawURLEncoding.Decode(u[:], []byte(s)); err != nil {
+		return UUID{}, errs.ErrArgs.WrapMsg("invalid short UUID", "uuid", s, "err", err.Error())
+	}
+	if err := check(u); err != nil {
+		return UUID{}, err
+	}
+	return u, nil
+}
@@ -0,0 +1,149 @@
+package uuidutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openimsdk/tools/errs"
+)
+
+// rfcVector is the UUIDv7 example of RFC 9562, appendix A.6.
+const rfcVector = "017f22e2-79b0-7cc3-98c4-dc0c0c07398f"
+
+var rfcTime = time.UnixMilli(0x017F22E279B0)
+
+func fixedGenerator(now func() time.Time, random []byte) *Generator {
+	return &Generator{now: now, rand: bytes.NewReader(random)}
+}
+
+func TestRFCVector(t *testing.T) {
+	random, _ := hex.DecodeString("0cc398c4dc0c0c07398f")
+	g := fixedGenerator(func() time.Time { return rfcTime }, random)
+	u, err := g.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.String() != rfcVector {
+		t.Fatalf("New() = %s, want %s", u, rfcVector)
+	}
+
+	parsed, err := Parse(strings.ToUpper(rfcVector))
+	if err != nil || parsed != u {
+		t.Fatalf("Parse = %s, %v", parsed, err)
+	}
+	if ts, err := TimeOf(parsed); err != nil || !ts.Equal(rfcTime) {
+		t.Fatalf("TimeOf = %s, %v; want %s", ts, err, rfcTime)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"not-a-uuid",
+		uuid.NewString(),                       // v4
+		"017f22e2-79b0-7cc3-18c4-dc0c0c07398f", // NCS variant
+	} {
+		if err := Validate(s); !errors.Is(err, errs.ErrArgs) {
+			t.Errorf("Validate(%q) = %v, want ErrArgs", s, err)
+		}
+	}
+}
+
+func TestMonotonicStuckClock(t *testing.T) {
+	now := rfcTime
+	g := fixedGenerator(func() time.Time { return now }, bytes.Repeat([]byte{0xff}, 10*(maxCounter+10)))
+	us, err := g.NewBatch(maxCounter + 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The counter starts at its maximum: the second value already borrows the
+	// next millisecond.
+	for i := 1; i < len(us); i++ {
+		if bytes.Compare(us[i-1][:], us[i][:]) >= 0 {
+			t.Fatalf("uuid %d (%s) not after %s", i, us[i], us[i-1])
+		}
+	}
+	if ts, _ := TimeOf(us[1]); !ts.Equal(rfcTime.Add(time.Millisecond)) {
+		t.Errorf("overflowed counter did not advance the timestamp: %s", ts)
+	}
+
+	now = now.Add(-time.Hour)
+	back, err := g.New()
+	if err != nil || bytes.Compare(us[len(us)-1][:], back[:]) >= 0 {
+		t.Fatalf("clock step back produced %s after %s (%v)", back, us[len(us)-1], err)
+	}
+}
+
+func TestMonotonicConcurrent(t *testing.T) {
+	g := NewGenerator()
+	const workers, perWorker = 8, 2000
+	results := make([][]UUID, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				u, err := g.New()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if n := len(results[w]); n > 0 && bytes.Compare(results[w][n-1][:], u[:]) >= 0 {
+					t.Errorf("worker %d: %s not after %s", w, u, results[w][n-1])
+				}
+				results[w] = append(results[w], u)
+			}
+		}(w)
+	}
+	wg.Wait()
+	var all []string
+	for _, us := range results {
+		for _, u := range us {
+			all = append(all, u.String())
+		}
+	}
+	sort.Strings(all)
+	for i := 1; i < len(all); i++ {
+		if all[i] == all[i-1] {
+			t.Fatalf("duplicate %s", all[i])
+		}
+	}
+}
+
+func TestShort(t *testing.T) {
+	u := NewV7()
+	s := Short(u)
+	if len(s) != ShortLen || strings.ContainsAny(s, "+/=") {
+		t.Fatalf("Short = %q", s)
+	}
+	back, err := ParseShort(s)
+	if err != nil || back != u {
+		t.Fatalf("ParseShort(%q) = %s, %v; want %s", s, back, err, u)
+	}
+	if _, err := ParseShort(Short(uuid.New())); !errors.Is(err, errs.ErrArgs) {
+		t.Errorf("ParseShort accepted a v4 UUID: %v", err)
+	}
+	if _, err := ParseShort(s[1:]); !errors.Is(err, errs.ErrArgs) {
+		t.Errorf("ParseShort accepted a truncated value: %v", err)
+	}
+}
+
+func BenchmarkNewV7(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = NewV7()
+	}
+}
+
+func BenchmarkNewV4(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = uuid.New()
+	}
+}